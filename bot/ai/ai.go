@@ -2,20 +2,46 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 
+	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
 )
 
 type Config struct {
 	OpenAIAPIKey string
+	Model        string // Default model, empty uses the client library default
+
+	// Providers are tried in order when a call fails or times out. Empty uses OpenAI with
+	// OpenAIAPIKey and Model. Only the first provider honors per-call models.
+	Providers []ProviderConfig
+
+	// Endpoints are named provider chains features can be routed to, e.g. a cheap local
+	// model for shower thoughts and a premium one for persona chat
+	Endpoints map[string]EndpointConfig
+	// Routes maps a feature in Features to an endpoint name. Unrouted features use Providers.
+	Routes map[string]string
+}
+
+// Enabled reports whether any LLM is configured
+func (c Config) Enabled() bool {
+	return c.OpenAIAPIKey != "" || len(c.Providers) > 0 || len(c.Endpoints) > 0
 }
 
+// Features that can be routed to an endpoint
+var Features = []string{"aichat", "showerthought", "vibecheck", "suggestions", "selftest", "personas", "scheduler"}
+
 type AI struct {
-	log    *zap.Logger
-	config Config
-	llm    *openai.LLM
+	log       *zap.Logger
+	config    Config
+	llm       llms.Model
+	endpoints map[string]llms.Model // Endpoint name -> failover chain
+	providers []*provider
 }
 
 func NewAI(log *zap.Logger, c Config) *AI {
@@ -26,18 +52,156 @@ func NewAI(log *zap.Logger, c Config) *AI {
 }
 
 func (a *AI) Start(ctx context.Context) error {
-	model, err := openai.New(openai.WithToken(a.config.OpenAIAPIKey))
+	configs := a.config.Providers
+	if len(configs) == 0 {
+		configs = []ProviderConfig{{Name: "openai"}}
+	}
+	chain, err := a.newChain("", configs)
 	if err != nil {
-		return fmt.Errorf("create OpenAI model: %w", err)
+		return err
 	}
-	a.llm = model
+	providers := chain.providers
+
+	endpoints := make(map[string]llms.Model, len(a.config.Endpoints))
+	for _, name := range slices.Sorted(maps.Keys(a.config.Endpoints)) {
+		configs := a.config.Endpoints[name].Providers
+		if len(configs) == 0 {
+			return &errs.ConfigError{Key: "ai.endpoints." + name, Err: errors.New("at least one provider is required")}
+		}
+		chain, err := a.newChain(name, configs)
+		if err != nil {
+			return err
+		}
+		endpoints[name] = chain
+		providers = append(providers, chain.providers...)
+	}
+	for feature, endpoint := range a.config.Routes {
+		if _, ok := endpoints[endpoint]; !ok {
+			return &errs.ConfigError{Key: "ai.routes." + feature, Err: fmt.Errorf("unknown endpoint %q", endpoint)}
+		}
+	}
+
+	a.providers = providers
+	a.endpoints = endpoints
+	a.llm = &failover{log: a.log, providers: chain.providers}
 	return nil
 }
 
+// newChain creates the providers of an endpoint, or the default chain when endpoint is empty
+func (a *AI) newChain(endpoint string, configs []ProviderConfig) (*failover, error) {
+	providers := make([]*provider, 0, len(configs))
+	for i, pc := range configs {
+		p, err := a.newProvider(endpoint, i, pc)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return &failover{log: a.log.With(zap.String("endpoint", withDefault(endpoint, "default"))), providers: providers}, nil
+}
+
+func (a *AI) newProvider(endpoint string, i int, pc ProviderConfig) (*provider, error) {
+	pc.Name = providerLabel(endpoint, i, pc)
+	key := fmt.Sprintf("ai.providers[%d]", i)
+	if endpoint != "" {
+		key = fmt.Sprintf("ai.endpoints.%s.providers[%d]", endpoint, i)
+	}
+	if pc.BaseURL == "" {
+		pc.APIKey = withDefault(pc.APIKey, a.config.OpenAIAPIKey)
+		pc.Model = withDefault(pc.Model, a.config.Model)
+	} else if pc.Model == "" {
+		return nil, &errs.ConfigError{Key: key, Err: errors.New("model is required with base_url")}
+	} else if pc.APIKey == "" {
+		pc.APIKey = "unused" // Local servers like Ollama ignore the key, but the client requires one
+	}
+
+	opts := []openai.Option{openai.WithToken(pc.APIKey)}
+	if pc.BaseURL != "" {
+		opts = append(opts, openai.WithBaseURL(pc.BaseURL))
+	}
+	if pc.Model != "" {
+		opts = append(opts, openai.WithModel(pc.Model))
+	}
+	model, err := openai.New(opts...)
+	if err != nil {
+		return nil, errs.NewLLMError(fmt.Sprintf("create %s model", pc.Name), err)
+	}
+	return &provider{config: pc, model: model, ownModel: i > 0 && pc.Model != ""}, nil
+}
+
 func (a *AI) Stop(ctx context.Context) error {
 	return nil
 }
 
-func (a *AI) LLM() *openai.LLM {
+// LLM returns the default model, which fails over through the configured providers
+func (a *AI) LLM() llms.Model {
 	return a.llm
 }
+
+// Route is the AI a feature uses: the endpoint it's routed to, or the default providers
+type Route struct {
+	ai      *AI
+	feature string
+}
+
+// For returns the feature's route. The route resolves its model on each call, so it can
+// be handed to features before the AI is started.
+func (a *AI) For(feature string) Route {
+	return Route{ai: a, feature: feature}
+}
+
+// LLM returns the model of the feature's endpoint
+func (r Route) LLM() llms.Model {
+	if endpoint, ok := r.ai.config.Routes[r.feature]; ok {
+		if model, ok := r.ai.endpoints[endpoint]; ok {
+			return model
+		}
+	}
+	return r.ai.llm
+}
+
+// ProviderNames lists the providers in failover order, default providers first. Endpoint
+// providers are named endpoint/provider.
+func (a *AI) ProviderNames() []string {
+	configs := a.config.Providers
+	if len(configs) == 0 {
+		configs = []ProviderConfig{{Name: "openai"}}
+	}
+	var names []string
+	for i, pc := range configs {
+		names = append(names, providerLabel("", i, pc))
+	}
+	for _, endpoint := range slices.Sorted(maps.Keys(a.config.Endpoints)) {
+		for i, pc := range a.config.Endpoints[endpoint].Providers {
+			names = append(names, providerLabel(endpoint, i, pc))
+		}
+	}
+	return names
+}
+
+// providerLabel names a provider in logs and health checks
+func providerLabel(endpoint string, i int, pc ProviderConfig) string {
+	name := withDefault(pc.Name, fmt.Sprintf("provider_%d", i+1))
+	if endpoint == "" {
+		return name
+	}
+	return endpoint + "/" + name
+}
+
+// ProviderHealthCheck reports the last error of a provider whose most recent call failed
+func (a *AI) ProviderHealthCheck(name string) error {
+	for _, p := range a.providers {
+		if p.config.Name == name {
+			return p.healthCheck()
+		}
+	}
+	return nil
+}
+
+// withDefault returns val unless it's empty
+func withDefault(val, def string) string {
+	if val == "" {
+		return def
+	}
+	return val
+}
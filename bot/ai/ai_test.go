@@ -2,9 +2,12 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/tmc/langchaingo/llms"
 	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/errs"
 )
 
 func TestNewAI(t *testing.T) {
@@ -203,6 +206,133 @@ func TestAI_ConfigValidation(t *testing.T) {
 	}
 }
 
+// stubModel answers with its name or fails with err, recording the model it was asked for
+type stubModel struct {
+	name      string
+	err       error
+	lastModel string
+}
+
+func (m *stubModel) GenerateContent(_ context.Context, _ []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	m.lastModel = opts.Model
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.name}}}, nil
+}
+
+func (m *stubModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func TestFailover(t *testing.T) {
+	primary := &stubModel{name: "primary", err: errors.New("503 service unavailable")}
+	fallback := &stubModel{name: "fallback"}
+	f := &failover{
+		log: zaptest.NewLogger(t),
+		providers: []*provider{
+			{config: ProviderConfig{Name: "openai"}, model: primary},
+			{config: ProviderConfig{Name: "ollama", Model: "llama3.1"}, model: fallback, ownModel: true},
+		},
+	}
+
+	resp, err := f.GenerateContent(context.Background(), nil, llms.WithModel("gpt-4o-mini"))
+	if err != nil {
+		t.Fatalf("GenerateContent() error = %v", err)
+	}
+	if got := resp.Choices[0].Content; got != "fallback" {
+		t.Errorf("GenerateContent() = %q, want the fallback's answer", got)
+	}
+	if primary.lastModel != "gpt-4o-mini" || fallback.lastModel != "llama3.1" {
+		t.Errorf("models = %q, %q; want the per-call model only for the primary", primary.lastModel, fallback.lastModel)
+	}
+	if err := f.providers[0].healthCheck(); err == nil {
+		t.Error("primary should be unhealthy after a failure")
+	}
+	if err := f.providers[1].healthCheck(); err != nil {
+		t.Errorf("fallback should be healthy, got %v", err)
+	}
+
+	primary.err = nil
+	if _, err := f.GenerateContent(context.Background(), nil); err != nil {
+		t.Fatalf("GenerateContent() error = %v", err)
+	}
+	if err := f.providers[0].healthCheck(); err != nil {
+		t.Errorf("primary should recover after a success, got %v", err)
+	}
+
+	primary.err, fallback.err = errors.New("down"), errors.New("also down")
+	if _, err := f.GenerateContent(context.Background(), nil); err == nil || !errors.Is(err, fallback.err) {
+		t.Errorf("GenerateContent() error = %v, want the last provider's error", err)
+	}
+}
+
+func TestAI_StartProviders(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	a := NewAI(logger, Config{Providers: []ProviderConfig{{Name: "ollama", BaseURL: "http://localhost:11434/v1"}}})
+	var configErr *errs.ConfigError
+	if err := a.Start(context.Background()); !errors.As(err, &configErr) {
+		t.Errorf("Start() error = %v, want a ConfigError for a provider without a model", err)
+	}
+
+	a = NewAI(logger, Config{
+		OpenAIAPIKey: "sk-test-key",
+		Providers: []ProviderConfig{
+			{Name: "openai", Model: "gpt-4o"},
+			{BaseURL: "http://localhost:11434/v1", Model: "llama3.1"},
+		},
+	})
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := a.ProviderNames(); len(got) != 2 || got[1] != "provider_2" {
+		t.Errorf("ProviderNames() = %v, want [openai provider_2]", got)
+	}
+	if err := a.ProviderHealthCheck("openai"); err != nil {
+		t.Errorf("ProviderHealthCheck() = %v, want nil before any call", err)
+	}
+}
+
+func TestAI_Routes(t *testing.T) {
+	a := NewAI(zaptest.NewLogger(t), Config{
+		OpenAIAPIKey: "sk-test-key",
+		Endpoints: map[string]EndpointConfig{
+			"cheap": {Providers: []ProviderConfig{{Name: "ollama", BaseURL: "http://localhost:11434/v1", Model: "llama3.1"}}},
+		},
+		Routes: map[string]string{"showerthought": "cheap"},
+	})
+	route := a.For("showerthought")
+	if route.LLM() != nil {
+		t.Error("Route.LLM() should be nil before Start()")
+	}
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if route.LLM() == nil || route.LLM() == a.LLM() {
+		t.Error("A routed feature should use its endpoint's model")
+	}
+	if a.For("aichat").LLM() != a.LLM() {
+		t.Error("An unrouted feature should use the default model")
+	}
+	if got := a.ProviderNames(); len(got) != 2 || got[1] != "cheap/ollama" {
+		t.Errorf("ProviderNames() = %v, want [openai cheap/ollama]", got)
+	}
+	if err := a.ProviderHealthCheck("cheap/ollama"); err != nil {
+		t.Errorf("ProviderHealthCheck() = %v, want nil before any call", err)
+	}
+
+	a = NewAI(zaptest.NewLogger(t), Config{OpenAIAPIKey: "sk-test-key", Routes: map[string]string{"aichat": "premium"}})
+	var configErr *errs.ConfigError
+	if err := a.Start(context.Background()); !errors.As(err, &configErr) || configErr.Key != "ai.routes.aichat" {
+		t.Errorf("Start() error = %v, want a ConfigError for the unknown endpoint", err)
+	}
+}
+
 func BenchmarkNewAI(b *testing.B) {
 	logger := zaptest.NewLogger(b)
 	config := Config{
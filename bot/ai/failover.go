@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+)
+
+// ProviderConfig is an OpenAI-compatible endpoint in the failover chain, e.g. OpenAI or a
+// local Ollama server at http://localhost:11434/v1
+type ProviderConfig struct {
+	Name    string        `json:"name" yaml:"name"`         // Label used in logs and /health
+	BaseURL string        `json:"base_url" yaml:"base_url"` // Empty uses the OpenAI API
+	APIKey  string        `json:"api_key" yaml:"api_key"`   // Defaults to OPENAI_API_KEY for the OpenAI API
+	Model   string        `json:"model" yaml:"model"`       // Defaults to OPENAI_MODEL for the OpenAI API
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`   // Per-call timeout, 0 uses the caller's deadline
+}
+
+// EndpointConfig is a named chain of providers features can be routed to
+type EndpointConfig struct {
+	Providers []ProviderConfig `json:"providers" yaml:"providers"`
+}
+
+type FileConfig struct {
+	Providers []ProviderConfig          `json:"providers" yaml:"providers"`
+	Endpoints map[string]EndpointConfig `json:"endpoints" yaml:"endpoints"`
+	// Routes maps a feature (aichat, showerthought, vibecheck, suggestions, selftest) to an endpoint
+	Routes map[string]string `json:"routes" yaml:"routes"`
+}
+
+// provider is a model in the failover chain with its health
+type provider struct {
+	config ProviderConfig
+	model  llms.Model
+	// ownModel forces the provider's model, ignoring per-call models meant for the primary
+	ownModel bool
+
+	mu          sync.Mutex
+	failures    int // Consecutive failed calls
+	lastErr     error
+	lastFailure time.Time
+}
+
+func (p *provider) record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		p.failures = 0
+		return
+	}
+	p.failures++
+	p.lastErr = err
+	p.lastFailure = time.Now()
+}
+
+// healthCheck returns the last error while the provider's most recent call failed
+func (p *provider) healthCheck() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d consecutive failures since %s: %w", p.failures, p.lastFailure.Format(time.RFC3339), p.lastErr)
+}
+
+// failover is a model that tries each provider in order until one succeeds
+type failover struct {
+	log       *zap.Logger
+	providers []*provider
+}
+
+func (f *failover) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var err error
+	for i, p := range f.providers {
+		if i > 0 && ctx.Err() != nil {
+			break
+		}
+		var resp *llms.ContentResponse
+		resp, err = p.generate(ctx, messages, options)
+		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+			// The caller gave up, which says nothing about the provider's health
+			return nil, err
+		}
+		p.record(err)
+		if err == nil {
+			if i > 0 {
+				f.log.Info("LLM call served by fallback provider", zap.String("provider", p.config.Name))
+			}
+			return resp, nil
+		}
+		if i < len(f.providers)-1 {
+			f.log.Warn("LLM provider failed, trying the next one",
+				zap.String("provider", p.config.Name),
+				zap.String("next", f.providers[i+1].config.Name),
+				zap.Error(errs.NewLLMError("generate content", err)),
+			)
+		}
+	}
+	if len(f.providers) > 1 {
+		return nil, fmt.Errorf("all %d LLM providers failed, last error: %w", len(f.providers), err)
+	}
+	return nil, err
+}
+
+func (p *provider) generate(ctx context.Context, messages []llms.MessageContent, options []llms.CallOption) (*llms.ContentResponse, error) {
+	if p.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+		defer cancel()
+	}
+	if p.ownModel {
+		options = append(options[:len(options):len(options)], llms.WithModel(p.config.Model))
+	}
+	return p.model.GenerateContent(ctx, messages, options...)
+}
+
+func (f *failover) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
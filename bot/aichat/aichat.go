@@ -4,7 +4,7 @@ package aichat
 import (
 	"context"
 	"fmt"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,10 +14,16 @@ import (
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"go.uber.org/zap"
-	"golang.org/x/time/rate"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/handoff"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/subtype"
+	"slackbot.arpa/bot/trigger"
 	"slackbot.arpa/tools/random"
+	"slackbot.arpa/tools/timeformat"
 )
 
 // slackContextMessage represents a message fetched from live Slack thread or channel history.
@@ -48,36 +54,120 @@ func parseSlackTimestamp(ts string) time.Time {
 const eventChannelSize = 10
 
 type aiService interface {
-	LLM() *openai.LLM
+	LLM() llms.Model
 }
 
 type slackService interface {
 	Client() *slack.Client
 	BotUserID() string
+	Available() bool
+	PostDM(ctx context.Context, userID, kind string, opts ...slack.MsgOption) (string, error)
 }
 
 type FileConfig struct {
-	StickyDuration     *time.Duration    `json:"sticky_duration" yaml:"sticky_duration"`
-	MaxContextMessages *int              `json:"max_context_messages" yaml:"max_context_messages"`
-	MaxContextAge      *time.Duration    `json:"max_context_age" yaml:"max_context_age"`
-	MaxContextTokens   *int              `json:"max_context_tokens" yaml:"max_context_tokens"`
-	RateLimitEnabled   *bool             `json:"rate_limit_enabled" yaml:"rate_limit_enabled"`
-	Personas           map[string]string `json:"personas" yaml:"personas"`
+	StickyDuration     *time.Duration `json:"sticky_duration" yaml:"sticky_duration"`
+	MaxTrackedUsers    *int           `json:"max_tracked_users" yaml:"max_tracked_users"`
+	MaxContextMessages *int           `json:"max_context_messages" yaml:"max_context_messages"`
+	MaxContextAge      *time.Duration `json:"max_context_age" yaml:"max_context_age"`
+	MaxContextTokens   *int           `json:"max_context_tokens" yaml:"max_context_tokens"`
+	RateLimitEnabled   *bool          `json:"rate_limit_enabled" yaml:"rate_limit_enabled"`
+	// MessageRate is how often each channel earns another unprompted reply, defaults to 3m
+	MessageRate *time.Duration `json:"message_rate" yaml:"message_rate"`
+	// MessageBurst is how many unprompted replies a channel can send at once, defaults to 5
+	MessageBurst *int `json:"message_burst" yaml:"message_burst"`
+	// MentionRate is how often each channel earns another answered mention, defaults to 0
+	// for unlimited
+	MentionRate *time.Duration `json:"mention_rate" yaml:"mention_rate"`
+	// MentionBurst is how many mentions a channel can have answered at once, defaults to 5
+	MentionBurst *int `json:"mention_burst" yaml:"mention_burst"`
+	// QueueSize is how many unprompted messages each channel holds while it's rate limited,
+	// to answer the most engaging once it can reply again, defaults to 10. 0 drops them.
+	QueueSize *int `json:"queue_size" yaml:"queue_size"`
+	// QueueMaxAge is how long a held message is worth answering, defaults to 5m
+	QueueMaxAge  *time.Duration     `json:"queue_max_age" yaml:"queue_max_age"`
+	Personas     map[string]Persona `json:"personas" yaml:"personas"`
+	PersonaRules []PersonaRule      `json:"persona_rules" yaml:"persona_rules"`
+	// ChannelPersonas maps channel IDs to the persona that always replies there, instead of
+	// each user's sticky persona
+	ChannelPersonas map[string]string `json:"channel_personas" yaml:"channel_personas"`
+	Engagement      EngagementPolicy  `json:"engagement" yaml:"engagement"`
+	// ChannelEngagement overrides the engagement policy per channel ID
+	ChannelEngagement map[string]EngagementPolicy `json:"channel_engagement" yaml:"channel_engagement"`
+	Language          LanguagePolicy              `json:"language" yaml:"language"`
+	// ChannelLanguage overrides the language policy per channel ID
+	ChannelLanguage map[string]LanguagePolicy `json:"channel_language" yaml:"channel_language"`
+	// NameMentions treats the bot's name written plainly, e.g. "ok slackbot, what do you
+	// think", like a mention, defaults to true
+	NameMentions *bool `json:"name_mentions" yaml:"name_mentions"`
 }
 
 type Config struct {
 	DataDir            string
 	Personas           map[string]string
+	PersonaOverrides   map[string]PersonaOverrides // persona name -> model and parameter overrides
+	PersonaWeights     map[string]float64          // persona name -> selection weight, defaults to 1
+	PersonaRules       []PersonaRule               // per-channel and time-of-day weight rules
+	ChannelPersonas    map[string]string           // channel ID -> persona that always replies there
+	Engagement         EngagementPolicy            // drop-chance policy for non-mention messages
+	ChannelEngagement  map[string]EngagementPolicy // channel ID -> engagement policy overrides
+	Language           LanguagePolicy              // which language replies are written in
+	ChannelLanguage    map[string]LanguagePolicy   // channel ID -> language policy overrides
 	StickyDuration     time.Duration
+	MaxTrackedUsers    int           // Users each kind of in-memory per-user state holds, defaults to DefaultMaxTrackedUsers
 	MaxContextMessages int           // Maximum number of messages to include in context
 	MaxContextAge      time.Duration // Maximum age of messages to include in context
 	MaxContextTokens   int           // Approximate maximum tokens for context (rough estimate)
-	RateLimitEnabled   bool          // When false, the eventlimiter is bypassed entirely
+	RateLimitEnabled   bool          // When false, the rate limits are bypassed entirely
+	MessageRate        RateLimit     // Unprompted replies allowed per channel
+	MentionRate        RateLimit     // Answered mentions allowed per channel, unlimited by default
+	QueueSize          int           // Rate limited messages held per channel, 0 drops them
+	QueueMaxAge        time.Duration // How long a held message is worth answering, 0 never expires
+	TriggerAliases     []string      // Words treated like a mention, defaults to trigger.DefaultAliases
+	NameMentions       bool          // Treat the bot's own names like a mention, resolved when it starts
+	MessageSubtypes    []string      // Subtypes handled besides plain messages, defaults to subtype.DefaultAllowed
 }
 
-type personaAssignment struct {
-	Name      string    // The name of the persona
-	Timestamp time.Time // When the persona was assigned
+// outbox holds replies for a send delay so an operator can cancel them
+type outbox interface {
+	Send(ctx context.Context, feature, channelID, triggerTS, preview string, send func(ctx context.Context))
+}
+
+// handoffs reports threads handed over to people, where the bot stays quiet
+type handoffs interface {
+	Active(channelID, threadTS string) bool
+}
+
+// answerer reports mentions another feature replies to, e.g. the facts keeper's "@bot what
+// is the wifi password"
+type answerer interface {
+	Answers(channelID, text string) bool
+}
+
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
+// silencer reports channels where unprompted replies should stop, e.g. during an incident
+type silencer interface {
+	Silenced(channelID string) bool
+}
+
+// claimer limits how many features respond to one message
+type claimer interface {
+	Claim(ctx context.Context, feature, channelID, ts string) bool
+}
+
+// tracer keeps what went into each reply, so an admin can have it explained
+type tracer interface {
+	Record(t explain.Trace)
+}
+
+// replyLog remembers the events already replied to, so an event Slack retries isn't
+// answered twice
+type replyLog interface {
+	Replied(feature, eventID string) (string, bool, error)
+	Record(feature, eventID, channelID, ts string) error
 }
 
 type AIChat struct {
@@ -87,11 +177,29 @@ type AIChat struct {
 	ai             aiService
 	context        *ContextStorage
 	stopCh         chan struct{}
-	eventsCh       chan slackevents.EventsAPIEvent
+	eventsCh       chan event.Event
+	interactionsCh chan slack.InteractionCallback
 	isConnected    atomic.Bool
-	eventlimiter   *rate.Limiter
-	stickyPersonas map[string]personaAssignment // userID -> personaAssignment
+	messageLimits  *channelLimiters
+	mentionLimits  *channelLimiters
+	queue          *replyQueue        // Unprompted messages held while their channel is rate limited
+	stickyPersonas *userState[string] // userID -> assigned persona name
+	userLanguages  *userState[string] // userID -> language they last wrote in
 	mutex          sync.Mutex
+	status         *status.Tracker
+	triggers       *trigger.Matcher
+	names          atomic.Pointer[trigger.Matcher] // The bot's own names, once resolved
+	subtypes       *subtype.Filter
+	silencer       silencer
+	presence       presence
+	outbox         outbox
+	handoffs       handoffs
+	answerers      []answerer
+	replies        replyLog
+	claims         claimer
+	tracer         tracer
+	personasMu     sync.RWMutex      // Guards added and the persona config, which SetPersonas replaces
+	added          map[string]string // Personas added at runtime, over configured ones
 }
 
 func NewAIChat(log *zap.Logger, c Config, s slackService, a aiService) *AIChat {
@@ -102,19 +210,79 @@ func NewAIChat(log *zap.Logger, c Config, s slackService, a aiService) *AIChat {
 		contextStorage = nil
 	}
 
+	aliases := c.TriggerAliases
+	if len(aliases) == 0 {
+		aliases = trigger.DefaultAliases
+	}
+
 	return &AIChat{
 		log:            log,
 		config:         c,
+		triggers:       trigger.NewMatcher(aliases),
+		subtypes:       subtype.NewFilter(c.MessageSubtypes),
 		slack:          s,
 		ai:             a,
 		context:        contextStorage,
-		eventlimiter:   rate.NewLimiter(rate.Every(3*time.Minute), 5),
-		stickyPersonas: make(map[string]personaAssignment),
+		messageLimits:  newChannelLimiters(c.MessageRate, c.MaxTrackedUsers),
+		mentionLimits:  newChannelLimiters(c.MentionRate, c.MaxTrackedUsers),
+		queue:          newReplyQueue(c.QueueSize, c.QueueMaxAge),
+		stickyPersonas: newUserState[string](c.MaxTrackedUsers, c.StickyDuration),
+		userLanguages:  newUserState[string](c.MaxTrackedUsers, languageMemory),
 		stopCh:         make(chan struct{}),
-		eventsCh:       make(chan slackevents.EventsAPIEvent, eventChannelSize),
+		eventsCh:       make(chan event.Event, eventChannelSize),
+		interactionsCh: make(chan slack.InteractionCallback, eventChannelSize),
 	}
 }
 
+// SetSilencer stops unprompted replies in channels the silencer reports as silenced.
+// Direct mentions are still answered.
+func (a *AIChat) SetSilencer(s silencer) {
+	a.silencer = s
+}
+
+// SetPresence skips channels the bot was removed from
+func (a *AIChat) SetPresence(p presence) {
+	a.presence = p
+}
+
+// SetOutbox sends replies through the outbox, which holds them when a send delay is set
+func (a *AIChat) SetOutbox(o outbox) {
+	a.outbox = o
+}
+
+// SetHandoffs stops replies in threads handed over to people, and leaves requests for a
+// human to the handoff desk
+func (a *AIChat) SetHandoffs(h handoffs) {
+	a.handoffs = h
+}
+
+// AddAnswerer leaves mentions another feature replies to, such as the facts keeper's
+// "@bot remember ...", to it
+func (a *AIChat) AddAnswerer(r answerer) {
+	a.answerers = append(a.answerers, r)
+}
+
+// SetReplyLog skips events already replied to and records each reply as it's posted
+func (a *AIChat) SetReplyLog(r replyLog) {
+	a.replies = r
+}
+
+// SetClaimer has the feature claim a message before generating a reply, so other
+// features replying to the same message don't pile on
+func (a *AIChat) SetClaimer(c claimer) {
+	a.claims = c
+}
+
+// SetTracer records what went into each reply, to explain it on request
+func (a *AIChat) SetTracer(t tracer) {
+	a.tracer = t
+}
+
+// SetStatusTracker sets the tracker that records the feature's activity
+func (a *AIChat) SetStatusTracker(t *status.Tracker) {
+	a.status = t
+}
+
 // ProcessorType returns a description of the processor type
 func (c *AIChat) ProcessorType() string {
 	return "aichat"
@@ -124,6 +292,10 @@ func (a *AIChat) Start(ctx context.Context) error {
 	a.isConnected.Store(true)
 
 	go a.handleEvents(ctx)
+	go a.sweepUserState(ctx)
+	if a.config.NameMentions {
+		go a.resolveNames(ctx)
+	}
 
 	return nil
 }
@@ -142,42 +314,45 @@ func (a *AIChat) Stop(ctx context.Context) error {
 }
 
 // PushEvent adds an event to be processed by the AIChat feature
-func (a *AIChat) PushEvent(event slackevents.EventsAPIEvent) {
+func (a *AIChat) PushEvent(e event.Event) {
 	if !a.isConnected.Load() {
 		return
 	}
 
 	select {
-	case a.eventsCh <- event:
+	case a.eventsCh <- e:
 		// Event pushed successfully
 	default:
 		a.log.Warn("AIChat events channel full, dropping event.")
+		a.status.Dropped(e.Channel, status.DropQueueFull)
 	}
 }
 
 // handleEvents processes Slack events
 func (a *AIChat) handleEvents(ctx context.Context) {
+	ticker := time.NewTicker(queueCheckInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-a.stopCh:
 			return
 		case <-ctx.Done():
 			return
-		case event := <-a.eventsCh:
-			a.processEvent(ctx, event)
+		case e := <-a.eventsCh:
+			a.processEvent(e.Context(ctx), e)
+		case callback := <-a.interactionsCh:
+			a.processInteraction(ctx, callback)
+		case <-ticker.C:
+			a.replyQueued(ctx, time.Now())
 		}
 	}
 }
 
-// botWordPattern matches the literal word "bot" (case-insensitive) with word
-// boundaries. Triggers on "bot", "@bot", "Bot,", "BOT!" — but not on substrings
-// like "robot", "bottom", or Slack user IDs like "UBOTID".
-var botWordPattern = regexp.MustCompile(`(?i)\bbot\b`)
-
 // isBotMentioned checks if the bot is mentioned in the message text — either via
-// a proper Slack @-mention (<@USERID>) or by the literal word "bot".
+// a proper Slack @-mention (<@USERID>), by a trigger alias such as the word "bot", or
+// by one of the bot's names.
 func (a *AIChat) isBotMentioned(text string) bool {
-	if botWordPattern.MatchString(text) {
+	if a.triggers.Match(text) || a.names.Load().Match(text) {
 		return true
 	}
 	botUserID := a.slack.BotUserID()
@@ -188,72 +363,159 @@ func (a *AIChat) isBotMentioned(text string) bool {
 }
 
 // processEvent handles a single Slack event
-func (a *AIChat) processEvent(ctx context.Context, event slackevents.EventsAPIEvent) {
-	switch event.Type {
-	case slackevents.CallbackEvent:
-		innerEvent := event.InnerEvent
-		switch ev := innerEvent.Data.(type) {
-		case *slackevents.AppMentionEvent:
-			a.log.Debug("Processing AppMentionEvent (direct bot mention)",
-				zap.String("user", ev.User),
-				zap.String("channel", ev.Channel),
-				zap.String("text", ev.Text),
-				zap.String("type", a.ProcessorType()),
-			)
-			// Ignore bot messages to prevent loops
-			if ev.BotID != "" || ev.User == "" {
+func (a *AIChat) processEvent(ctx context.Context, e event.Event) {
+	// Replies are non-essential; stay quiet while the Slack API is failing
+	if !a.slack.Available() {
+		a.log.Debug("Slack API unavailable, skipping event", zap.String("type", a.ProcessorType()))
+		return
+	}
+	a.status.Event()
+	if a.handoffs != nil {
+		if a.handoffs.Active(e.Channel, e.ThreadTS) {
+			a.log.Debug("Thread handed off, skipping event", zap.String("channel", e.Channel), zap.String("thread", e.ThreadTS))
+			return
+		}
+		// Answered by the handoff desk
+		if handoff.IsRequest(e.Text) && a.isBotMentioned(e.Text) {
+			return
+		}
+	}
+	// Answered by another feature, e.g. the facts keeper
+	if len(a.answerers) > 0 && a.isBotMentioned(e.Text) && slices.ContainsFunc(a.answerers, func(r answerer) bool {
+		return r.Answers(e.Channel, e.Text)
+	}) {
+		return
+	}
+	switch ev := e.Data().(type) {
+	case *slackevents.AppMentionEvent:
+		a.log.Debug("Processing AppMentionEvent (direct bot mention)",
+			zap.String("user", e.User),
+			zap.String("channel", e.Channel),
+			zap.String("text", e.Text),
+			zap.String("type", a.ProcessorType()),
+		)
+		// Ignore bot messages to prevent loops
+		if e.FromBot() {
+			return
+		}
+		if isMemoryRequest(e.Text) {
+			a.handleMemoryRequest(ctx, e.User)
+			return
+		}
+		if isStatsRequest(e.Text) {
+			a.handleStatsRequest(ctx, e.Channel, e.ThreadTS)
+			return
+		}
+		// Answered by the status responder
+		if status.IsRequest(e.Text) {
+			return
+		}
+		a.handleMessageEvent(ctx, eventMessage{
+			EventID:         e.ID,
+			UserID:          e.User,
+			Channel:         e.Channel,
+			Text:            e.Text,
+			Username:        "",
+			ThreadTimeStamp: e.ThreadTS,
+			TimeStamp:       e.TS,
+		})
+	case *slackevents.MessageEvent:
+		a.log.Debug("Processing MessageEvent",
+			zap.String("user", e.User),
+			zap.String("channel", e.Channel),
+			zap.String("text", e.Text),
+			zap.String("type", a.ProcessorType()),
+		)
+		if !a.subtypes.Allow(ev) {
+			return
+		}
+		// Memory requests are answered from the app_mention event in channels; direct
+		// messages don't produce one, so handle them here.
+		if isMemoryRequest(e.Text) && (e.ChannelType == "im" || a.isBotMentioned(e.Text)) {
+			if e.ChannelType == "im" {
+				a.handleMemoryRequest(ctx, e.User)
+			}
+			return
+		}
+		// Stats requests with a mention are answered from the app_mention event; handle
+		// direct messages and trigger aliases like "hey bot, stats" here
+		if rest, aliased := a.triggers.TrimPrefix(e.Text); (aliased && isStatsRequest(rest)) || (e.ChannelType == "im" && isStatsRequest(e.Text)) {
+			a.handleStatsRequest(ctx, e.Channel, e.ThreadTS)
+			return
+		}
+		if isStatsRequest(e.Text) && a.isBotMentioned(e.Text) {
+			return
+		}
+		if status.IsAddressedRequest(e.Text, a.triggers) && a.isBotMentioned(e.Text) {
+			return
+		}
+		// Direct mentions bypass the message rate limit and drop chance, like AppMentionEvent.
+		m := eventMessage{
+			EventID:         e.ID,
+			UserID:          e.User,
+			Channel:         e.Channel,
+			Text:            e.Text,
+			Username:        ev.Username,
+			ThreadTimeStamp: e.ThreadTS,
+			TimeStamp:       e.TS,
+			Unprompted:      !a.isBotMentioned(e.Text),
+		}
+		if m.Unprompted {
+			if a.silencer != nil && a.silencer.Silenced(e.Channel) {
+				a.log.Debug("Channel silenced, skipping unprompted reply", zap.String("channel", e.Channel))
 				return
 			}
-			a.handleMessageEvent(ctx, eventMessage{
-				UserID:          ev.User,
-				Channel:         ev.Channel,
-				Text:            ev.Text,
-				Username:        "",
-				ThreadTimeStamp: ev.ThreadTimeStamp,
-			})
-		case *slackevents.MessageEvent:
-			a.log.Debug("Processing MessageEvent",
-				zap.String("user", ev.User),
-				zap.String("channel", ev.Channel),
-				zap.String("text", ev.Text),
+			// Messages join the channel's queue while it has one, so they're answered in
+			// order of how engaging they are rather than whichever comes in next
+			now := time.Now()
+			if a.config.RateLimitEnabled && (a.queue.waiting(e.Channel, now) || !a.messageLimits.allow(e.Channel, now)) {
+				a.status.RateLimited(e.Channel)
+				msg := "Rate limit exceeded, dropping event"
+				if a.queueMessage(m, now) {
+					msg = "Rate limit exceeded, queueing event"
+				}
+				a.log.Debug(msg,
+					zap.String("user", e.User),
+					zap.String("channel", e.Channel),
+					zap.String("text", e.Text),
+					zap.String("type", a.ProcessorType()),
+				)
+				return
+			}
+			m.DropChance = a.calculateDropChance(e.User, e.Channel, e.Text)
+			m.Roll = random.Float(0, 1)
+			dropped := m.Roll < m.DropChance
+			a.log.Debug("Computed engagement drop chance",
+				zap.String("user", e.User),
+				zap.String("channel", e.Channel),
+				zap.Float64("drop_chance", m.DropChance),
+				zap.Bool("dropped", dropped),
 				zap.String("type", a.ProcessorType()),
 			)
-			if ev.BotID != "" || ev.User == "" {
+			if dropped {
 				return
 			}
-			// Direct mentions bypass rate limit and drop chance, like AppMentionEvent.
-			if !a.isBotMentioned(ev.Text) {
-				if a.config.RateLimitEnabled && !a.eventlimiter.Allow() {
-					a.log.Debug("Rate limit exceeded, dropping event",
-						zap.String("user", ev.User),
-						zap.String("channel", ev.Channel),
-						zap.String("text", ev.Text),
-						zap.String("type", a.ProcessorType()),
-					)
-					return
-				}
-				dropChance := a.calculateDropChance(ev.User, ev.Channel, ev.Text)
-				if random.Bool(dropChance) {
-					return
-				}
-			}
-			a.handleMessageEvent(ctx, eventMessage{
-				UserID:          ev.User,
-				Channel:         ev.Channel,
-				Text:            ev.Text,
-				Username:        ev.Username,
-				ThreadTimeStamp: ev.ThreadTimeStamp,
-			})
 		}
+		a.handleMessageEvent(ctx, m)
 	}
 }
 
 type eventMessage struct {
+	EventID         string
 	UserID          string
 	Username        string
 	Channel         string
 	Text            string
 	ThreadTimeStamp string
+	TimeStamp       string
+	// Unprompted is set for a message that doesn't mention the bot, which is only
+	// answered when Roll is at least DropChance
+	Unprompted bool
+	DropChance float64
+	Roll       float64
+	// Queued is set for an unprompted message held while its channel was rate limited,
+	// which is answered without a roll
+	Queued bool
 }
 
 // fetchThreadContext retrieves all messages in a Slack thread for LLM context.
@@ -346,6 +608,28 @@ func (a *AIChat) fetchChannelContext(ctx context.Context, channelID string) []sl
 
 // handleMessageEvent processes a message event and generates a response
 func (a *AIChat) handleMessageEvent(ctx context.Context, m eventMessage) {
+	if a.presence != nil && !a.presence.Present(ctx, m.Channel) {
+		a.log.Debug("Bot was removed from channel, skipping message", zap.String("channel", m.Channel))
+		return
+	}
+	// Checked before calling the LLM, so a retried event costs nothing
+	if a.replied(m) {
+		return
+	}
+	if a.claims != nil && !a.claims.Claim(ctx, a.ProcessorType(), m.Channel, m.TimeStamp) {
+		a.status.Dropped(m.Channel, status.DropClaimed)
+		return
+	}
+	// Checked after the claim, so an event another instance answers uses none of the budget
+	if !m.Unprompted && a.config.RateLimitEnabled && !a.mentionLimits.allow(m.Channel, time.Now()) {
+		a.status.RateLimited(m.Channel)
+		a.log.Debug("Mention rate limit exceeded, dropping event",
+			zap.String("user", m.UserID),
+			zap.String("channel", m.Channel),
+			zap.String("type", a.ProcessorType()),
+		)
+		return
+	}
 	eventMessage := strings.TrimSpace(m.Text)
 
 	a.log.Debug("Processing eventMessage",
@@ -371,7 +655,8 @@ func (a *AIChat) handleMessageEvent(ctx context.Context, m eventMessage) {
 		userDetails = UserDetails{UserID: m.UserID, Username: m.Username}
 	}
 
-	personaName := a.userPersona(m.UserID)
+	personaName := a.userPersona(m.UserID, m.Channel)
+	userDetails.Language = a.replyLanguage(m.UserID, m.Channel, m.Text)
 
 	// Fetch live Slack context for richer, thread-aware responses.
 	// For threads, the thread history IS the full conversation — use it directly and skip
@@ -430,18 +715,31 @@ func (a *AIChat) handleMessageEvent(ctx context.Context, m eventMessage) {
 		temperature = random.Float(0.1, 2.0)
 	}
 
-	resp, err := a.ai.LLM().GenerateContent(ctx, messages,
+	overrides := a.personaOverrides(personaName)
+	maxTokens, temperature = applyPersonaOverrides(overrides, maxTokens, temperature)
+
+	callOptions := []llms.CallOption{
 		llms.WithTemperature(temperature),
 		llms.WithMaxTokens(maxTokens),
 		llms.WithTopP(0.9),
 		llms.WithFrequencyPenalty(1.0),
 		llms.WithPresencePenalty(0.6),
-		llms.WithStopWords(stopWords))
+		llms.WithStopWords(stopWords),
+	}
+	if overrides.Model != "" {
+		callOptions = append(callOptions, llms.WithModel(overrides.Model))
+	}
+
+	started := time.Now()
+	resp, err := a.generateContent(ctx, messages, callOptions...)
+	llmLatency := time.Since(started)
 	if err != nil {
+		a.status.Error(err)
 		a.log.Error("Failed to generate content",
 			zap.String("user", m.UserID),
 			zap.String("channel", m.Channel),
 			zap.String("text", eventMessage),
+			zap.String("category", errs.Category(err)),
 			zap.Error(err),
 		)
 		return
@@ -463,6 +761,35 @@ func (a *AIChat) handleMessageEvent(ctx context.Context, m eventMessage) {
 		completion = strings.TrimSpace(completion)
 	}
 
+	trace := explain.Trace{
+		Trigger:    "mention",
+		Persona:    personaName,
+		Rolled:     m.Unprompted && !m.Queued,
+		DropChance: m.DropChance,
+		Roll:       m.Roll,
+		Model:      overrides.Model,
+		LLMLatency: llmLatency,
+	}
+	if m.Unprompted {
+		trace.Trigger = "unprompted"
+	}
+	if m.Queued {
+		trace.Trigger = "unprompted, queued while rate limited"
+	}
+	trace.PromptTokens, trace.CompletionTokens = explain.Tokens(resp.Choices[0].GenerationInfo)
+
+	if a.outbox == nil {
+		a.postReply(ctx, m, personaName, completion, trace)
+		return
+	}
+	a.outbox.Send(ctx, a.ProcessorType(), m.Channel, m.TimeStamp, completion, func(ctx context.Context) {
+		a.postReply(ctx, m, personaName, completion, trace)
+	})
+}
+
+// postReply posts the completion and stores the exchange in the conversation context.
+// The trace of what went into the completion is recorded once it's posted.
+func (a *AIChat) postReply(ctx context.Context, m eventMessage, personaName, completion string, trace explain.Trace) {
 	msgOptions := []slack.MsgOption{
 		slack.MsgOptionText(completion, false),
 		slack.MsgOptionAsUser(true),
@@ -473,18 +800,32 @@ func (a *AIChat) handleMessageEvent(ctx context.Context, m eventMessage) {
 		msgOptions = append(msgOptions, slack.MsgOptionTS(m.ThreadTimeStamp))
 	}
 
-	_, _, err = a.slack.Client().PostMessageContext(
+	channel, ts, err := a.slack.Client().PostMessageContext(
 		ctx,
 		m.Channel,
 		msgOptions...,
 	)
 	if err != nil {
+		a.status.PostFailed(m.Channel, err)
 		a.log.Error("Failed to post response",
 			zap.String("channel", m.Channel),
 			zap.Error(err),
 		)
 		return
 	}
+	a.status.Posted()
+	// Recorded before anything else, so a crash while storing context doesn't leave a
+	// retried event to be answered again
+	a.recordReply(m, channel, ts)
+	if a.tracer != nil {
+		trace.Feature = a.ProcessorType()
+		trace.Channel = channel
+		trace.TS = ts
+		trace.ThreadTS = m.ThreadTimeStamp
+		trace.TriggerTS = m.TimeStamp
+		trace.EventID = m.EventID
+		a.tracer.Record(trace)
+	}
 
 	// Store conversation context
 	if a.context != nil {
@@ -526,47 +867,117 @@ func (a *AIChat) handleMessageEvent(ctx context.Context, m eventMessage) {
 	}
 }
 
-// userPersona assigns a persona to a user and returns the persona name.
-func (a *AIChat) userPersona(userID string) string {
-	a.mutex.Lock()
-	defer a.mutex.Unlock()
+// replied reports whether the event was already answered, e.g. before a restart
+func (a *AIChat) replied(m eventMessage) bool {
+	if a.replies == nil || m.EventID == "" {
+		return false
+	}
+	ts, ok, err := a.replies.Replied(a.ProcessorType(), m.EventID)
+	if err != nil {
+		// Answering twice is better than not answering at all
+		a.log.Warn("Failed to check reply log", zap.String("event_id", m.EventID), zap.Error(err))
+		return false
+	}
+	if ok {
+		a.status.Dropped(m.Channel, status.DropReplied)
+		a.log.Info("Event already replied to, skipping",
+			zap.String("event_id", m.EventID),
+			zap.String("channel", m.Channel),
+			zap.String("reply_ts", ts))
+	}
+	return ok
+}
+
+func (a *AIChat) recordReply(m eventMessage, channelID, ts string) {
+	if a.replies == nil || m.EventID == "" {
+		return
+	}
+	if err := a.replies.Record(a.ProcessorType(), m.EventID, channelID, ts); err != nil {
+		a.log.Warn("Failed to record reply", zap.String("event_id", m.EventID), zap.Error(err))
+	}
+}
 
-	if assignment, ok := a.stickyPersonas[userID]; ok {
-		if time.Since(assignment.Timestamp) < a.config.StickyDuration {
-			return assignment.Name
+// llmRetryDelay is how long to wait before retrying a retryable LLM failure
+var llmRetryDelay = 2 * time.Second
+
+// generateContent calls the LLM, retrying once when the failure is retryable such as a
+// rate limit or provider outage
+func (a *AIChat) generateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	resp, err := a.ai.LLM().GenerateContent(ctx, messages, options...)
+	if err == nil {
+		return resp, nil
+	}
+	llmErr := errs.NewLLMError("generate content", err)
+	if !errs.Retryable(llmErr) {
+		return nil, llmErr
+	}
+
+	a.log.Warn("Retrying LLM call after retryable error",
+		zap.String("code", string(llmErr.Code)),
+		zap.Error(err),
+	)
+	select {
+	case <-ctx.Done():
+		return nil, llmErr
+	case <-time.After(llmRetryDelay):
+	}
+
+	resp, err = a.ai.LLM().GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, errs.NewLLMError("generate content", err)
+	}
+	return resp, nil
+}
+
+// userPersona assigns a persona to a user and returns the persona name. A channel with a
+// persona of its own always gets it, and the user's sticky persona is left alone. Weights
+// and persona rules are evaluated for the channel the assignment happens in.
+func (a *AIChat) userPersona(userID, channelID string) string {
+	a.personasMu.RLock()
+	name, ok := a.config.ChannelPersonas[channelID]
+	a.personasMu.RUnlock()
+	if ok {
+		if a.personaPrompt(name) != "" {
+			return name
 		}
-		delete(a.stickyPersonas, userID)
+		a.log.Warn("Channel persona doesn't exist, assigning one at random",
+			zap.String("channel", channelID),
+			zap.String("persona", name))
 	}
 
-	personaName := a.randomPersonaName()
-	a.stickyPersonas[userID] = personaAssignment{
-		Name:      personaName,
-		Timestamp: time.Now(),
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.config.StickyDuration <= 0 {
+		return a.randomPersonaName(channelID)
 	}
+	now := time.Now()
+	if personaName, ok := a.stickyPersonas.get(userID, now); ok {
+		return personaName
+	}
+
+	personaName := a.randomPersonaName(channelID)
+	a.stickyPersonas.set(userID, personaName, now)
 	return personaName
 }
 
-// randomPersonaName returns a random persona name from the configured personas
-func (a *AIChat) randomPersonaName() string {
-	if len(a.config.Personas) == 0 {
+// randomPersonaName returns a weighted random persona name from the configured personas
+func (a *AIChat) randomPersonaName(channelID string) string {
+	if len(a.PersonaNames()) == 0 {
 		// Fallback to default persona if no personas configured
 		return "default"
 	}
 
-	personaNames := make([]string, 0, len(a.config.Personas))
-	for name := range a.config.Personas {
-		personaNames = append(personaNames, name)
-	}
-
-	return random.String(personaNames)
+	return a.pickPersona(channelID, time.Now())
 }
 
 type UserDetails struct {
-	UserID    string
-	Username  string
-	FirstName string
-	LastName  string
-	TZ        string
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	TZ        string `json:"tz"`
+	Language  string `json:"language"` // ISO 639-1 code to reply in, empty leaves it to the model
 }
 
 // formatContextAge formats a duration for display in the system prompt recency note.
@@ -590,13 +1001,21 @@ func formatContextAge(d time.Duration) string {
 // and is placed first so the LLM sees the full conversational flow.
 // storedContext contains the bot's own conversation history with this user from SQLite.
 func (a *AIChat) buildMessages(input string, u UserDetails, personaName string, storedContext []ConversationContext, liveContext []slackContextMessage) []llms.MessageContent {
-	persona := a.config.Personas[personaName]
+	return a.buildMessagesAt(time.Now(), input, u, personaName, storedContext, liveContext)
+}
+
+// buildMessagesAt is buildMessages with the context's age measured from now
+func (a *AIChat) buildMessagesAt(now time.Time, input string, u UserDetails, personaName string, storedContext []ConversationContext, liveContext []slackContextMessage) []llms.MessageContent {
+	persona := a.personaPrompt(personaName)
 	if persona == "" {
 		persona = personas[personaName]
 		if persona == "" {
 			persona = glazerPrompt
 		}
 	}
+	if preamble := a.personaOverrides(personaName).Preamble; preamble != "" {
+		persona = preamble + "\n\n" + persona
+	}
 
 	// Determine conversation state for system prompt guidance
 	activeConvo := len(liveContext) > 0
@@ -605,7 +1024,7 @@ func (a *AIChat) buildMessages(input string, u UserDetails, personaName string,
 
 	if !activeConvo {
 		for _, ctx := range storedContext {
-			if time.Since(ctx.Timestamp) < 10*time.Minute {
+			if now.Sub(ctx.Timestamp) < 10*time.Minute {
 				activeConvo = true
 				break
 			}
@@ -636,8 +1055,8 @@ func (a *AIChat) buildMessages(input string, u UserDetails, personaName string,
 		guidance += "\nDon't repeat a punchline, roast, or observation you've already made in this conversation."
 	}
 	// When context spans a significant window, signal that older messages carry less weight.
-	if !oldestLive.IsZero() && time.Since(oldestLive) > 30*time.Minute {
-		age := time.Since(oldestLive).Round(time.Minute)
+	if !oldestLive.IsZero() && now.Sub(oldestLive) > 30*time.Minute {
+		age := now.Sub(oldestLive).Round(time.Minute)
 		guidance += fmt.Sprintf("\nContext spans up to %s back; weight recent messages more heavily than older ones.", formatContextAge(age))
 	}
 
@@ -665,13 +1084,26 @@ func (a *AIChat) buildMessages(input string, u UserDetails, personaName string,
 		)
 	}
 
+	languageHint := ""
+	if name, ok := LanguageName(u.Language); ok {
+		languageHint = fmt.Sprintf("\nReply in %s.", name)
+	}
+
+	// Their local time, so greetings and "tonight" fit their day rather than the server's
+	timeHint := ""
+	if u.TZ != "" {
+		timeHint = fmt.Sprintf("\nIt's %s where they are.", timeformat.In(now, u.TZ, "Monday 3:04 PM"))
+	}
+
 	systemPrompt := fmt.Sprintf(`%s
 
-You're in a Slack chat. Keep replies SHORT — one sentence usually, two max. Never write paragraphs, lists, or essays. This is casual chat, not a support ticket. Be funny, absurd, or very wise.%s%s%s%s`,
+You're in a Slack chat. Keep replies SHORT — one sentence usually, two max. Never write paragraphs, lists, or essays. This is casual chat, not a support ticket. Be funny, absurd, or very wise.%s%s%s%s%s%s`,
 		persona,
 		targetHint,
 		nameHint,
 		mentionHint,
+		languageHint,
+		timeHint,
 		guidance,
 	)
 
@@ -709,6 +1141,19 @@ You're in a Slack chat. Keep replies SHORT — one sentence usually, two max. Ne
 	return messages
 }
 
+// applyPersonaOverrides adjusts the randomly chosen generation parameters with the
+// persona's overrides. A temperature range replaces the default range and max tokens
+// caps the length variation so short replies stay short.
+func applyPersonaOverrides(o PersonaOverrides, maxTokens int, temperature float64) (int, float64) {
+	if o.MaxTemperature > 0 {
+		temperature = random.Float(o.MinTemperature, o.MaxTemperature)
+	}
+	if o.MaxTokens > 0 && maxTokens > o.MaxTokens {
+		maxTokens = o.MaxTokens
+	}
+	return maxTokens, temperature
+}
+
 // stopWordsForVariation returns stop sequences for the given length-variation bucket.
 // OpenAI enforces a maximum of 4 stop sequences — this function must never exceed that.
 // Role-label stops ("Human:", "Assistant:") are no longer needed since GenerateContent
@@ -722,12 +1167,14 @@ func stopWordsForVariation(v float64) []string {
 	return []string{"\n\n"}
 }
 
-// calculateDropChance determines the probability of dropping a message based on engagement factors
+// calculateDropChance determines the probability of dropping a message based on the
+// channel's engagement policy
 func (a *AIChat) calculateDropChance(userID, channelID, text string) float64 {
-	baseDropChance := 0.25
+	policy := a.engagementFor(channelID)
+	dropChance := policy.BaseDropChance
 
 	if a.context != nil {
-		personaName := a.userPersona(userID)
+		personaName := a.userPersona(userID, channelID)
 		recentContext, err := a.context.GetRecentContext(userID, channelID, personaName, &a.config)
 		if err == nil && len(recentContext) > 0 {
 			var lastBotResponseTime time.Time
@@ -741,67 +1188,37 @@ func (a *AIChat) calculateDropChance(userID, channelID, text string) float64 {
 			if !lastBotResponseTime.IsZero() {
 				timeSinceLastReply := time.Since(lastBotResponseTime)
 
-				switch {
-				case timeSinceLastReply < 2*time.Minute:
-					baseDropChance = 0.05
-				case timeSinceLastReply < 10*time.Minute:
-					baseDropChance = 0.10
-				case timeSinceLastReply < 30*time.Minute:
-					baseDropChance = 0.20
+				for _, window := range policy.MomentumWindows {
+					if timeSinceLastReply < window.Within {
+						dropChance = window.DropChance
+						break
+					}
 				}
 
 				// Back off if we've been too chatty — preserves anti-spam safety valve.
 				recentBotMessages := 0
 				for _, ctx := range recentContext {
-					if ctx.Role == "assistant" && time.Since(ctx.Timestamp) < 5*time.Minute {
+					if ctx.Role == "assistant" && time.Since(ctx.Timestamp) < policy.ChattyWindow {
 						recentBotMessages++
 					}
 				}
-				if recentBotMessages >= 3 {
-					baseDropChance += 0.2
+				if policy.ChattyThreshold > 0 && recentBotMessages >= policy.ChattyThreshold {
+					dropChance += policy.ChattyPenalty
 				}
 			}
 		}
 	}
 
 	// Engagement factors based on message content
-	textLower := strings.ToLower(text)
-
-	// More likely to respond to questions
-	if strings.Contains(textLower, "?") {
-		baseDropChance -= 0.15
-	}
-
-	// More likely to respond to emotional content
-	emotionalWords := []string{"excited", "frustrated", "confused", "help", "stuck", "wow", "amazing", "terrible", "annoying"}
-	for _, word := range emotionalWords {
-		if strings.Contains(textLower, word) {
-			baseDropChance -= 0.1
-			break
-		}
-	}
-
-	// More likely to respond to conversational cues
-	conversationalCues := []string{"thoughts", "think", "opinion", "anyone", "what do you", "how about"}
-	for _, cue := range conversationalCues {
-		if strings.Contains(textLower, cue) {
-			baseDropChance -= 0.1
-			break
-		}
-	}
-
-	// Less likely to respond to very short messages (unless they're questions)
-	if len(strings.TrimSpace(text)) < 10 && !strings.Contains(text, "?") {
-		baseDropChance += 0.2
-	}
+	dropChance -= policy.contentBonus(text)
 
 	// Clamp between reasonable bounds
-	if baseDropChance < 0.05 {
-		baseDropChance = 0.05 // Always some chance of not responding
+	if dropChance < policy.MinDropChance {
+		dropChance = policy.MinDropChance
 	}
-	if baseDropChance > 0.8 {
-		baseDropChance = 0.8 // Always some chance of responding
+	if dropChance > policy.MaxDropChance {
+		dropChance = policy.MaxDropChance
 	}
 
-	return baseDropChance
+	return dropChance
 }
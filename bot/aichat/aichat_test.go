@@ -1,9 +1,17 @@
 package aichat
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -11,24 +19,49 @@ import (
 	"github.com/goccy/go-yaml"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
-	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/tmc/langchaingo/llms"
 	"go.uber.org/zap"
-	"golang.org/x/time/rate"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/trigger"
 )
 
 // --- Mocks ---
 
 type mockSlack struct {
-	botUserID string
-	client    *slack.Client
+	botUserID   string
+	client      *slack.Client
+	unavailable bool
 }
 
 func (m *mockSlack) Client() *slack.Client { return m.client }
 func (m *mockSlack) BotUserID() string     { return m.botUserID }
+func (m *mockSlack) Available() bool       { return !m.unavailable }
+func (m *mockSlack) PostDM(ctx context.Context, userID, kind string, opts ...slack.MsgOption) (string, error) {
+	channel, _, _, err := m.client.OpenConversationContext(ctx, &slack.OpenConversationParameters{Users: []string{userID}})
+	if err != nil {
+		return "", err
+	}
+	_, ts, err := m.client.PostMessageContext(ctx, channel.ID, opts...)
+	return ts, err
+}
 
 type mockAI struct{}
 
-func (m *mockAI) LLM() *openai.LLM { return nil }
+type mockReplyLog map[string]string
+
+func (m mockReplyLog) Replied(feature, eventID string) (string, bool, error) {
+	ts, ok := m[feature+"/"+eventID]
+	return ts, ok, nil
+}
+
+func (m mockReplyLog) Record(feature, eventID, channelID, ts string) error {
+	m[feature+"/"+eventID] = ts
+	return nil
+}
+
+func (m *mockAI) LLM() llms.Model { return nil }
 
 func newTestAIChat(t *testing.T, cfg Config) *AIChat {
 	t.Helper()
@@ -40,10 +73,15 @@ func newTestAIChat(t *testing.T, cfg Config) *AIChat {
 		config:         cfg,
 		slack:          &mockSlack{botUserID: "UBOTID"},
 		ai:             &mockAI{},
-		eventlimiter:   rate.NewLimiter(rate.Inf, 1000),
-		stickyPersonas: make(map[string]personaAssignment),
+		messageLimits:  newChannelLimiters(cfg.MessageRate, cfg.MaxTrackedUsers),
+		mentionLimits:  newChannelLimiters(cfg.MentionRate, cfg.MaxTrackedUsers),
+		queue:          newReplyQueue(cfg.QueueSize, cfg.QueueMaxAge),
+		stickyPersonas: newUserState[string](cfg.MaxTrackedUsers, cfg.StickyDuration),
+		userLanguages:  newUserState[string](cfg.MaxTrackedUsers, languageMemory),
 		stopCh:         make(chan struct{}),
-		eventsCh:       make(chan slackevents.EventsAPIEvent, eventChannelSize),
+		eventsCh:       make(chan event.Event, eventChannelSize),
+		interactionsCh: make(chan slack.InteractionCallback, eventChannelSize),
+		triggers:       trigger.NewMatcher(trigger.DefaultAliases),
 	}
 }
 
@@ -257,7 +295,7 @@ func TestAIChat_RandomPersonaName(t *testing.T) {
 		},
 	})
 
-	persona := a.randomPersonaName()
+	persona := a.randomPersonaName("C1")
 	if persona != "test1" && persona != "test2" {
 		t.Errorf("expected 'test1' or 'test2', got '%s'", persona)
 	}
@@ -265,7 +303,7 @@ func TestAIChat_RandomPersonaName(t *testing.T) {
 
 func TestAIChat_RandomPersonaName_FallsBackToDefault(t *testing.T) {
 	a := newTestAIChat(t, Config{Personas: map[string]string{}})
-	if got := a.randomPersonaName(); got != "default" {
+	if got := a.randomPersonaName("C1"); got != "default" {
 		t.Errorf("expected 'default', got '%s'", got)
 	}
 }
@@ -276,15 +314,38 @@ func TestAIChat_UserPersona_Sticky(t *testing.T) {
 		StickyDuration: 30 * time.Minute,
 	})
 
-	first := a.userPersona("UABC")
+	first := a.userPersona("UABC", "C1")
 	// Same user should get same persona while sticky
 	for i := 0; i < 5; i++ {
-		if got := a.userPersona("UABC"); got != first {
+		if got := a.userPersona("UABC", "C1"); got != first {
 			t.Errorf("expected sticky persona %q, got %q on call %d", first, got, i+1)
 		}
 	}
 }
 
+func TestAIChat_UserPersona_ChannelPersona(t *testing.T) {
+	a := newTestAIChat(t, Config{
+		Personas:        map[string]string{"p1": "persona1", "grumpy_mentor": "grumpy"},
+		PersonaWeights:  map[string]float64{"grumpy_mentor": 0},
+		ChannelPersonas: map[string]string{"C1": "grumpy_mentor", "C2": "deleted"},
+		StickyDuration:  30 * time.Minute,
+	})
+
+	for i := 0; i < 5; i++ {
+		if got := a.userPersona("U"+string(rune('A'+i)), "C1"); got != "grumpy_mentor" {
+			t.Errorf("userPersona() in a channel with a persona = %q, want grumpy_mentor", got)
+		}
+	}
+	// Other channels, and channels mapped to a persona that doesn't exist, keep the
+	// user's sticky persona, which the channel persona didn't replace
+	if got := a.userPersona("UA", "C3"); got != "p1" {
+		t.Errorf("userPersona() elsewhere = %q, want the random sticky persona", got)
+	}
+	if got := a.userPersona("UA", "C2"); got != "p1" {
+		t.Errorf("userPersona() with a missing channel persona = %q, want the sticky persona", got)
+	}
+}
+
 func TestAIChat_UserPersona_DifferentUsersCanDiffer(t *testing.T) {
 	a := newTestAIChat(t, Config{
 		Personas:       map[string]string{"p1": "p1", "p2": "p2", "p3": "p3"},
@@ -293,7 +354,7 @@ func TestAIChat_UserPersona_DifferentUsersCanDiffer(t *testing.T) {
 
 	seen := make(map[string]bool)
 	for i := 0; i < 20; i++ {
-		seen[a.userPersona("U"+string(rune('A'+i)))] = true
+		seen[a.userPersona("U"+string(rune('A'+i)), "C1")] = true
 	}
 	// With 20 different users, we should have seen more than 1 distinct persona
 	if len(seen) < 2 {
@@ -307,14 +368,14 @@ func TestAIChat_UserPersona_ExpiresAfterDuration(t *testing.T) {
 		StickyDuration: 1 * time.Millisecond,
 	})
 
-	first := a.userPersona("UABC")
+	first := a.userPersona("UABC", "C1")
 	time.Sleep(5 * time.Millisecond)
 
 	// After expiry, persona may change (statistically; run a few times)
 	changed := false
 	for i := 0; i < 20; i++ {
 		time.Sleep(2 * time.Millisecond)
-		if next := a.userPersona("UABC"); next != first {
+		if next := a.userPersona("UABC", "C1"); next != first {
 			changed = true
 			break
 		}
@@ -370,6 +431,63 @@ func TestAIChat_IsBotMentioned_EmptyBotID(t *testing.T) {
 	}
 }
 
+func TestAIChat_IsBotMentioned_Aliases(t *testing.T) {
+	a := newTestAIChat(t, Config{})
+	a.triggers = trigger.NewMatcher([]string{"hey jeeves", "robot"})
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Hey Jeeves, what's for lunch?", true},
+		{"the robot said hi", true},
+		{"jeeves alone", false},
+		{"robots everywhere", false},
+		// Aliases replace the default "bot" word
+		{"hey bot", false},
+		{"<@UBOTID> hi", true},
+	}
+	for _, tt := range tests {
+		if got := a.isBotMentioned(tt.text); got != tt.want {
+			t.Errorf("isBotMentioned(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAIChat_IsBotMentioned_Names(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users.info" {
+			t.Errorf("unexpected call to %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "user": {"id": "UBOTID", "name": "slackbot", "real_name": "Office Helper",
+			"profile": {"display_name": "", "real_name": "Office Helper"}}}`))
+	}))
+	defer srv.Close()
+
+	a := newTestAIChat(t, Config{NameMentions: true})
+	a.slack = &mockSlack{botUserID: "UBOTID", client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	if a.isBotMentioned("ok slackbot, what do you think") {
+		t.Fatal("isBotMentioned() matched the name before it was resolved")
+	}
+	a.resolveNames(context.Background())
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"ok slackbot, what do you think", true},
+		{"Office Helper thoughts?", true},
+		{"slackbots are neat", false},
+		{"the office helper is cold", true},
+		{"the office is cold", false},
+	}
+	for _, tt := range tests {
+		if got := a.isBotMentioned(tt.text); got != tt.want {
+			t.Errorf("isBotMentioned(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
 // --- calculateDropChance Tests ---
 
 func TestAIChat_CalculateDropChance_BaseRate(t *testing.T) {
@@ -448,7 +566,7 @@ func TestAIChat_CalculateDropChance_WithRecentContext(t *testing.T) {
 		Timestamp: time.Now().Add(-30 * time.Second),
 	})
 	// Assign persona so calculateDropChance uses it
-	a.stickyPersonas["U1"] = personaAssignment{Name: "p1", Timestamp: time.Now()}
+	a.stickyPersonas.set("U1", "p1", time.Now())
 
 	dropWithContext := a.calculateDropChance("U1", "C1", "this is a normal message today")
 	dropWithout := newTestAIChat(t, cfg).calculateDropChance("U1", "C1", "this is a normal message today")
@@ -810,37 +928,25 @@ func TestAIChat_ProcessEvent_IgnoresBotMessages(t *testing.T) {
 
 	// Bot messages (BotID set) should be silently dropped.
 	// We verify this by ensuring no panic and the event channel stays empty.
-	event := slackevents.EventsAPIEvent{
-		Type: slackevents.CallbackEvent,
-		InnerEvent: slackevents.EventsAPIInnerEvent{
-			Type: "app_mention",
-			Data: &slackevents.AppMentionEvent{
-				BotID:   "BBOT",
-				Channel: "C1",
-				Text:    "some text",
-			},
-		},
-	}
+	e := event.Callback(&slackevents.AppMentionEvent{
+		BotID:   "BBOT",
+		Channel: "C1",
+		Text:    "some text",
+	})
 	// processEvent should return without doing anything for bot messages
-	a.processEvent(nil, event) //nolint:staticcheck
+	a.processEvent(nil, e) //nolint:staticcheck
 }
 
 func TestAIChat_ProcessEvent_IgnoresMessageEventFromBot(t *testing.T) {
 	a := newTestAIChat(t, Config{Personas: map[string]string{"p": "test"}})
 	a.isConnected.Store(true)
 
-	event := slackevents.EventsAPIEvent{
-		Type: slackevents.CallbackEvent,
-		InnerEvent: slackevents.EventsAPIInnerEvent{
-			Type: "message",
-			Data: &slackevents.MessageEvent{
-				BotID:   "BBOT",
-				Channel: "C1",
-				Text:    "bot talking to itself",
-			},
-		},
-	}
-	a.processEvent(nil, event) //nolint:staticcheck
+	e := event.Callback(&slackevents.MessageEvent{
+		BotID:   "BBOT",
+		Channel: "C1",
+		Text:    "bot talking to itself",
+	})
+	a.processEvent(nil, e) //nolint:staticcheck
 }
 
 // --- Stop Word Limit Tests ---
@@ -917,3 +1023,1097 @@ func TestPersonas_AllNonEmpty(t *testing.T) {
 		}
 	}
 }
+
+// --- Persona Override Tests ---
+
+func TestPersona_UnmarshalYAML(t *testing.T) {
+	yamlConfig := `
+glazer: "Gen-Z hype beast persona"
+computer:
+  prompt: "Sarcastic AI persona"
+  model: gpt-4o-mini
+  preamble: "Never apologize."
+  min_temperature: 0.2
+  max_temperature: 0.6
+  max_tokens: 50
+`
+	var personasData map[string]Persona
+	if err := yaml.Unmarshal([]byte(yamlConfig), &personasData); err != nil {
+		t.Fatalf("failed to parse YAML config: %v", err)
+	}
+
+	if got := personasData["glazer"]; got.Prompt != "Gen-Z hype beast persona" || got.Model != "" {
+		t.Errorf("unexpected glazer persona: %+v", got)
+	}
+
+	computer := personasData["computer"].Overrides()
+	want := PersonaOverrides{
+		Model:          "gpt-4o-mini",
+		Preamble:       "Never apologize.",
+		MinTemperature: 0.2,
+		MaxTemperature: 0.6,
+		MaxTokens:      50,
+	}
+	if personasData["computer"].Prompt != "Sarcastic AI persona" {
+		t.Errorf("unexpected computer prompt: %q", personasData["computer"].Prompt)
+	}
+	if computer != want {
+		t.Errorf("computer overrides = %+v, want %+v", computer, want)
+	}
+}
+
+func TestPersona_UnmarshalJSON(t *testing.T) {
+	jsonConfig := `{"glazer": "hype", "argue": {"prompt": "debate", "model": "gpt-4o"}}`
+	var personasData map[string]Persona
+	if err := json.Unmarshal([]byte(jsonConfig), &personasData); err != nil {
+		t.Fatalf("failed to parse JSON config: %v", err)
+	}
+	if personasData["glazer"].Prompt != "hype" {
+		t.Errorf("unexpected glazer persona: %+v", personasData["glazer"])
+	}
+	if personasData["argue"].Prompt != "debate" || personasData["argue"].Model != "gpt-4o" {
+		t.Errorf("unexpected argue persona: %+v", personasData["argue"])
+	}
+}
+
+func TestPersona_Validate(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	if err := (Persona{Prompt: "p", MinTemperature: f(0.2), MaxTemperature: f(0.8), Weight: f(0)}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	for _, p := range []Persona{
+		{Prompt: "min only", MinTemperature: f(0.2)},
+		{Prompt: "min over max", MinTemperature: f(0.9), MaxTemperature: f(0.5)},
+		{Prompt: "zero max", MaxTemperature: f(0)},
+		{Prompt: "negative weight", Weight: f(-1)},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%s) = nil, want an error", p.Prompt)
+		}
+	}
+}
+
+func TestApplyPersonaOverrides(t *testing.T) {
+	maxTokens, temperature := applyPersonaOverrides(PersonaOverrides{}, 150, 1.5)
+	if maxTokens != 150 || temperature != 1.5 {
+		t.Errorf("empty overrides should keep defaults, got maxTokens=%d temperature=%f", maxTokens, temperature)
+	}
+
+	o := PersonaOverrides{MinTemperature: 0.2, MaxTemperature: 0.4, MaxTokens: 60}
+	for range 20 {
+		maxTokens, temperature = applyPersonaOverrides(o, 150, 1.5)
+		if maxTokens != 60 {
+			t.Errorf("max tokens should be capped at 60, got %d", maxTokens)
+		}
+		if temperature < 0.2 || temperature > 0.4 {
+			t.Errorf("temperature %f outside persona range [0.2, 0.4]", temperature)
+		}
+	}
+
+	maxTokens, _ = applyPersonaOverrides(o, 40, 1.0)
+	if maxTokens != 40 {
+		t.Errorf("max tokens below the cap should be kept, got %d", maxTokens)
+	}
+}
+
+func TestAIChat_BuildMessages_IncludesPersonaPreamble(t *testing.T) {
+	a := newTestAIChat(t, Config{
+		Personas:         map[string]string{"p": "you are a test"},
+		PersonaOverrides: map[string]PersonaOverrides{"p": {Preamble: "PREAMBLE FIRST"}},
+	})
+	msgs := a.buildMessages("hi", UserDetails{}, "p", nil, nil)
+	system := fmt.Sprintf("%v", msgs[0].Parts[0])
+	if !strings.HasPrefix(system, "PREAMBLE FIRST") {
+		t.Errorf("system prompt should start with the persona preamble, got %q", system)
+	}
+	if !strings.Contains(system, "you are a test") {
+		t.Errorf("system prompt should still contain the persona prompt, got %q", system)
+	}
+}
+
+// --- Persona Rule Tests ---
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestPersonaRule_Matches(t *testing.T) {
+	friday6pm := time.Date(2024, 5, 10, 18, 0, 0, 0, time.Local)
+	friday9am := time.Date(2024, 5, 10, 9, 0, 0, 0, time.Local)
+	monday6pm := time.Date(2024, 5, 13, 18, 0, 0, 0, time.Local)
+	monday1am := time.Date(2024, 5, 13, 1, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		rule    PersonaRule
+		channel string
+		now     time.Time
+		want    bool
+	}{
+		{"empty rule matches", PersonaRule{Persona: "p"}, "C1", friday9am, true},
+		{"channel match", PersonaRule{Persona: "p", Channels: []string{"C1"}}, "C1", friday9am, true},
+		{"channel mismatch", PersonaRule{Persona: "p", Channels: []string{"C2"}}, "C1", friday9am, false},
+		{"friday evening", PersonaRule{Persona: "p", Days: []string{"Friday"}, After: "17:00"}, "C1", friday6pm, true},
+		{"friday morning", PersonaRule{Persona: "p", Days: []string{"friday"}, After: "17:00"}, "C1", friday9am, false},
+		{"short day name", PersonaRule{Persona: "p", Days: []string{"mon"}}, "C1", monday6pm, true},
+		{"wrong day", PersonaRule{Persona: "p", Days: []string{"fri"}, After: "17:00"}, "C1", monday6pm, false},
+		{"before bound exclusive", PersonaRule{Persona: "p", Before: "18:00"}, "C1", friday6pm, false},
+		{"overnight window late", PersonaRule{Persona: "p", After: "22:00", Before: "02:00"}, "C1", monday1am, true},
+		{"overnight window outside", PersonaRule{Persona: "p", After: "22:00", Before: "02:00"}, "C1", monday6pm, false},
+		{"invalid time never matches", PersonaRule{Persona: "p", After: "5pm"}, "C1", friday6pm, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(tt.channel, tt.now); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPersonaRule_Validate(t *testing.T) {
+	if err := (PersonaRule{Persona: "p", Days: []string{"fri"}, After: "17:00"}).Validate(); err != nil {
+		t.Errorf("expected valid rule, got %v", err)
+	}
+	invalid := []PersonaRule{
+		{},
+		{Persona: "p", Days: []string{"someday"}},
+		{Persona: "p", Before: "25:00"},
+		{Persona: "p", Weight: floatPtr(-1)},
+	}
+	for _, rule := range invalid {
+		if err := rule.Validate(); err == nil {
+			t.Errorf("expected rule %+v to be invalid", rule)
+		}
+	}
+}
+
+func TestAIChat_PersonaWeights(t *testing.T) {
+	a := newTestAIChat(t, Config{
+		Personas:       map[string]string{"comedian": "c", "unhinged": "u", "zen": "z"},
+		PersonaWeights: map[string]float64{"unhinged": 0},
+		PersonaRules: []PersonaRule{
+			{Persona: "unhinged", Days: []string{"friday"}, After: "17:00"},
+			{Persona: "comedian", Channels: []string{"CRANDOM"}, Weight: floatPtr(3)},
+		},
+	})
+
+	weightsFor := func(channel string, now time.Time) map[string]float64 {
+		names, weights := a.personaWeights(channel, now)
+		result := make(map[string]float64, len(names))
+		for i, name := range names {
+			result[name] = weights[i]
+		}
+		return result
+	}
+
+	monday := time.Date(2024, 5, 13, 18, 0, 0, 0, time.Local)
+	got := weightsFor("COTHER", monday)
+	if got["unhinged"] != 0 || got["comedian"] != 1 || got["zen"] != 1 {
+		t.Errorf("unexpected weights on monday in other channel: %v", got)
+	}
+
+	friday := time.Date(2024, 5, 10, 18, 0, 0, 0, time.Local)
+	got = weightsFor("CRANDOM", friday)
+	if got["unhinged"] != 1 || got["comedian"] != 3 {
+		t.Errorf("unexpected weights on friday evening in #random: %v", got)
+	}
+
+	for range 50 {
+		if name := a.pickPersona("COTHER", monday); name == "unhinged" {
+			t.Fatal("zero-weight persona should never be picked outside its rule window")
+		}
+	}
+}
+
+func TestAIChat_PickPersona_AllWeightedOutFallsBackToUniform(t *testing.T) {
+	a := newTestAIChat(t, Config{
+		Personas:       map[string]string{"p1": "p1", "p2": "p2"},
+		PersonaWeights: map[string]float64{"p1": 0, "p2": 0},
+	})
+	if got := a.pickPersona("C1", time.Now()); got != "p1" && got != "p2" {
+		t.Errorf("expected a configured persona, got %q", got)
+	}
+}
+
+// --- Engagement Policy Tests ---
+
+func TestAIChat_EngagementFor_MergesChannelOverrides(t *testing.T) {
+	base := 0.5
+	channelBase := 0.1
+	a := newTestAIChat(t, Config{
+		Engagement: EngagementPolicy{BaseDropChance: &base},
+		ChannelEngagement: map[string]EngagementPolicy{
+			"CQUIET": {BaseDropChance: &channelBase, EmotionalWords: []string{"yikes"}},
+		},
+	})
+
+	global := a.engagementFor("COTHER")
+	if global.BaseDropChance != 0.5 {
+		t.Errorf("expected global base drop chance 0.5, got %f", global.BaseDropChance)
+	}
+	if global.QuestionBonus != defaultEngagement.QuestionBonus {
+		t.Errorf("unset fields should inherit defaults, got question bonus %f", global.QuestionBonus)
+	}
+
+	channel := a.engagementFor("CQUIET")
+	if channel.BaseDropChance != 0.1 {
+		t.Errorf("expected channel base drop chance 0.1, got %f", channel.BaseDropChance)
+	}
+	if len(channel.EmotionalWords) != 1 || channel.EmotionalWords[0] != "yikes" {
+		t.Errorf("expected channel emotional words override, got %v", channel.EmotionalWords)
+	}
+}
+
+func TestAIChat_CalculateDropChance_UsesChannelPolicy(t *testing.T) {
+	maxDrop := 1.0
+	base := 0.9
+	bonus := 0.5
+	a := newTestAIChat(t, Config{
+		ChannelEngagement: map[string]EngagementPolicy{
+			"CQUIET": {BaseDropChance: &base, MaxDropChance: &maxDrop},
+			"CLOUD":  {EmotionalWords: []string{"yikes"}, EmotionalBonus: &bonus},
+		},
+	})
+
+	text := "this is a normal length message"
+	if got := a.calculateDropChance("U1", "CQUIET", text); got != 0.9 {
+		t.Errorf("expected channel base drop chance 0.9, got %f", got)
+	}
+
+	normal := a.calculateDropChance("U1", "CLOUD", text)
+	custom := a.calculateDropChance("U1", "CLOUD", "yikes that deploy went sideways")
+	if normal != defaultEngagement.BaseDropChance || custom != defaultEngagement.MinDropChance {
+		t.Errorf("custom emotional word should lower drop chance to the floor: normal=%f custom=%f", normal, custom)
+	}
+}
+
+func TestEngagementPolicy_UnmarshalYAML(t *testing.T) {
+	yamlConfig := `
+base_drop_chance: 0.4
+momentum_windows:
+  - within: 1m
+    drop_chance: 0.01
+chatty_window: 10m
+`
+	var policy EngagementPolicy
+	if err := yaml.Unmarshal([]byte(yamlConfig), &policy); err != nil {
+		t.Fatalf("failed to parse YAML policy: %v", err)
+	}
+	e := defaultEngagement.with(policy)
+	if e.BaseDropChance != 0.4 || e.ChattyWindow != 10*time.Minute {
+		t.Errorf("unexpected resolved policy: %+v", e)
+	}
+	if len(e.MomentumWindows) != 1 || e.MomentumWindows[0].Within != time.Minute {
+		t.Errorf("unexpected momentum windows: %+v", e.MomentumWindows)
+	}
+}
+
+// --- Memory Summary Tests ---
+
+func TestContextStorage_SummarizeAndPurgeUser(t *testing.T) {
+	storage, err := NewContextStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create context storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	oldest := time.Now().Add(-48 * time.Hour)
+	newest := time.Now().Add(-time.Hour)
+	for _, c := range []ConversationContext{
+		{UserID: "U1", ChannelID: "C1", PersonaName: "glazer", Message: "pizza talk", Role: "human", Timestamp: oldest},
+		{UserID: "U1", ChannelID: "C1", PersonaName: "glazer", Message: "reply", Role: "assistant", Timestamp: oldest.Add(time.Millisecond)},
+		{UserID: "U1", ChannelID: "C2", PersonaName: "argue", Message: "more pizza", Role: "human", Timestamp: newest},
+		{UserID: "U2", ChannelID: "C1", PersonaName: "glazer", Message: "someone else", Role: "human", Timestamp: newest},
+	} {
+		if err := storage.StoreContext(c); err != nil {
+			t.Fatalf("failed to store context: %v", err)
+		}
+	}
+
+	summary, err := storage.SummarizeUser("U1")
+	if err != nil {
+		t.Fatalf("failed to summarize user: %v", err)
+	}
+	if summary.HumanMessages != 2 || summary.AssistantMessages != 1 || summary.Channels != 2 {
+		t.Errorf("unexpected counts: %+v", summary)
+	}
+	if strings.Join(summary.Personas, ",") != "argue,glazer" {
+		t.Errorf("expected personas argue,glazer, got %v", summary.Personas)
+	}
+	if !summary.Oldest.Equal(oldest) || !summary.Newest.Equal(newest) {
+		t.Errorf("unexpected time bounds: oldest %v newest %v", summary.Oldest, summary.Newest)
+	}
+
+	deleted, err := storage.PurgeUser("U1")
+	if err != nil {
+		t.Fatalf("failed to purge user: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted messages, got %d", deleted)
+	}
+
+	summary, err = storage.SummarizeUser("U1")
+	if err != nil {
+		t.Fatalf("failed to summarize user: %v", err)
+	}
+	if summary.Total() != 0 {
+		t.Errorf("expected nothing stored after purge, got %d", summary.Total())
+	}
+	other, err := storage.SummarizeUser("U2")
+	if err != nil {
+		t.Fatalf("failed to summarize user: %v", err)
+	}
+	if other.Total() != 1 {
+		t.Errorf("expected other users to be unaffected, got %d", other.Total())
+	}
+}
+
+func TestIsMemoryRequest(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"<@UBOTID> what do you remember about me?", true},
+		{"bot, What do you KNOW about me", true},
+		{"what do you remember about meetings", false},
+		{"do you remember me", false},
+	}
+	for _, tt := range tests {
+		if got := isMemoryRequest(tt.text); got != tt.want {
+			t.Errorf("isMemoryRequest(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeTopics(t *testing.T) {
+	messages := []string{
+		"<@UBOTID> pizza is the best food",
+		"pizza again tonight, and tacos tomorrow",
+		"tacos or pizza? that is the question",
+		"nothing else to say",
+	}
+
+	topics := summarizeTopics(messages, 5)
+	if len(topics) != 2 || topics[0] != "pizza" || topics[1] != "tacos" {
+		t.Errorf("expected [pizza tacos], got %v", topics)
+	}
+}
+
+func TestFormatMemorySummary_OmitsMessageContent(t *testing.T) {
+	summary := ContextSummary{
+		HumanMessages:     2,
+		AssistantMessages: 2,
+		Channels:          1,
+		Personas:          []string{"glazer"},
+		Oldest:            time.Now().Add(-time.Hour),
+		Newest:            time.Now(),
+		Messages:          []string{"my secret pizza recipe", "secret pizza again"},
+	}
+
+	text := formatMemorySummary(summary)
+	if strings.Contains(text, "my secret pizza recipe") {
+		t.Error("summary should not quote stored messages")
+	}
+	if !strings.Contains(text, "2 of your messages") || !strings.Contains(text, "glazer") {
+		t.Errorf("summary missing counts or personas: %s", text)
+	}
+
+	blocks := memoryBlocks("U1", summary)
+	if len(blocks) != 2 {
+		t.Fatalf("expected summary and action blocks, got %d", len(blocks))
+	}
+	if len(memoryBlocks("U1", ContextSummary{})) != 1 {
+		t.Error("expected no purge button when nothing is stored")
+	}
+}
+
+func TestAIChat_ProcessInteraction_IgnoresOtherUsersPurge(t *testing.T) {
+	a, storage := newTestAIChatWithStorage(t, Config{})
+	if err := storage.StoreContext(ConversationContext{
+		UserID: "U1", ChannelID: "C1", PersonaName: "glazer", Message: "hi", Role: "human", Timestamp: time.Now(),
+	}); err != nil {
+		t.Fatalf("failed to store context: %v", err)
+	}
+
+	callback := slack.InteractionCallback{
+		Type: slack.InteractionTypeBlockActions,
+		User: slack.User{ID: "U2"},
+	}
+	callback.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: purgeMemoryActionID, Value: "U1"}}
+	a.processInteraction(t.Context(), callback)
+
+	summary, err := storage.SummarizeUser("U1")
+	if err != nil {
+		t.Fatalf("failed to summarize user: %v", err)
+	}
+	if summary.Total() != 1 {
+		t.Errorf("expected U1's context to survive another user's purge, got %d", summary.Total())
+	}
+}
+
+func TestContextStorage_ChannelStats(t *testing.T) {
+	storage, err := NewContextStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create context storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	now := time.Now()
+	for _, c := range []ConversationContext{
+		{UserID: "U1", ChannelID: "C1", PersonaName: "glazer", Message: "hi there", Role: "human", Timestamp: now},
+		{UserID: "U1", ChannelID: "C1", PersonaName: "glazer", Message: "1234", Role: "assistant", Timestamp: now},
+		{UserID: "U2", ChannelID: "C1", PersonaName: "glazer", Message: "123456", Role: "assistant", Timestamp: now},
+		{UserID: "U2", ChannelID: "C1", PersonaName: "pirate", Message: "12345678", Role: "assistant", Timestamp: now},
+		{UserID: "U1", ChannelID: "C1", PersonaName: "pirate", Message: "yesterday", Role: "assistant", Timestamp: now.Add(-48 * time.Hour)},
+		{UserID: "U1", ChannelID: "C2", PersonaName: "pirate", Message: "elsewhere", Role: "assistant", Timestamp: now},
+	} {
+		if err := storage.StoreContext(c); err != nil {
+			t.Fatalf("StoreContext() error = %v", err)
+		}
+	}
+
+	stats, err := storage.ChannelStats("C1", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ChannelStats() error = %v", err)
+	}
+	want := ChannelStats{Responses: 3, AverageLength: 6, TopPersona: "glazer", TopPersonaReplies: 2}
+	if stats != want {
+		t.Errorf("ChannelStats() = %+v, want %+v", stats, want)
+	}
+
+	empty, err := storage.ChannelStats("C3", now.Add(-time.Hour))
+	if err != nil || empty != (ChannelStats{}) {
+		t.Errorf("ChannelStats() for an idle channel = %+v, %v", empty, err)
+	}
+}
+
+func TestIsStatsRequest(t *testing.T) {
+	for text, want := range map[string]bool{
+		"<@U123> stats":        true,
+		"stats?":               true,
+		"<@U123> stats please": false,
+		"my stats are great":   false,
+		"<@U123> status":       false,
+	} {
+		if got := isStatsRequest(text); got != want {
+			t.Errorf("isStatsRequest(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestFormatChannelStats(t *testing.T) {
+	got := formatChannelStats(
+		ChannelStats{Responses: 3, AverageLength: 120, TopPersona: "pirate", TopPersonaReplies: 2},
+		rateBudget{Enabled: true, Available: 4, Burst: 5, Refill: 3 * time.Minute},
+	)
+	for _, want := range []string{"Replies sent: 3", "120 characters", "pirate (2 replies)", "4 of 5, one more every 3m0s"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatChannelStats() missing %q:\n%s", want, got)
+		}
+	}
+
+	got = formatChannelStats(ChannelStats{}, rateBudget{})
+	if strings.Contains(got, "persona") || !strings.Contains(got, "unlimited") {
+		t.Errorf("formatChannelStats() for an idle channel:\n%s", got)
+	}
+}
+
+func TestContextStorage_SearchContext(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, err := NewContextStorage(tempDir)
+	if err != nil {
+		t.Fatalf("failed to create context storage: %v", err)
+	}
+
+	now := time.Now()
+	for i, c := range []ConversationContext{
+		{UserID: "U1", ChannelID: "C1", Message: "should we deploy on friday?", Role: "human"},
+		{UserID: "U1", ChannelID: "C1", Message: "Never deploy on a Friday.", Role: "assistant"},
+		{UserID: "U2", ChannelID: "C2", Message: "friday deploy went fine", Role: "human"},
+		{UserID: "U2", ChannelID: "C2", Message: "lunch on friday", Role: "human"},
+	} {
+		c.PersonaName = "test"
+		c.Timestamp = now.Add(time.Duration(i) * time.Second)
+		if err := storage.StoreContext(c); err != nil {
+			t.Fatalf("failed to store context: %v", err)
+		}
+	}
+
+	matches, err := storage.SearchContext("deploy friday", SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchContext() error = %v", err)
+	}
+	if len(matches) != 3 || matches[0].Message != "friday deploy went fine" {
+		t.Fatalf("expected 3 matches newest first, got %+v", matches)
+	}
+	if !strings.Contains(matches[0].Snippet, "[deploy]") {
+		t.Errorf("expected highlighted snippet, got %q", matches[0].Snippet)
+	}
+
+	matches, err = storage.SearchContext("Deploy FRIDAY?", SearchOptions{ChannelID: "C1"})
+	if err != nil {
+		t.Fatalf("SearchContext() with punctuation error = %v", err)
+	}
+	if len(matches) != 2 || matches[0].Role != "assistant" {
+		t.Errorf("expected 2 matches in C1, got %+v", matches)
+	}
+
+	if _, err := storage.PurgeUser("U2"); err != nil {
+		t.Fatalf("PurgeUser() error = %v", err)
+	}
+	matches, err = storage.SearchContext("friday", SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchContext() error = %v", err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("expected purged messages to be removed from the index, got %+v", matches)
+	}
+	_ = storage.Close()
+}
+
+func TestContextStorage_MigratesUnversionedDatabase(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "aichat_context.db")
+
+	// A database created before migrations existed
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE conversation_context (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			persona_name TEXT NOT NULL,
+			message TEXT NOT NULL,
+			role TEXT NOT NULL CHECK (role IN ('human', 'assistant')),
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`INSERT INTO conversation_context (user_id, channel_id, persona_name, message, role) VALUES ('U1', 'C1', 'test', 'deploy on friday', 'human');`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create legacy schema: %v", err)
+		}
+	}
+	_ = db.Close()
+
+	storage, err := NewContextStorage(tempDir)
+	if err != nil {
+		t.Fatalf("failed to migrate context storage: %v", err)
+	}
+	version, err := storage.schemaVersion()
+	if err != nil || version != migrations[len(migrations)-1].version {
+		t.Errorf("schemaVersion() = %d, %v; want %d", version, err, migrations[len(migrations)-1].version)
+	}
+	if matches, err := storage.SearchContext("friday", SearchOptions{}); err != nil || len(matches) != 1 {
+		t.Errorf("expected existing messages to be indexed, got %+v, %v", matches, err)
+	}
+	_ = storage.Close()
+
+	backups, _ := filepath.Glob(dbPath + ".v0-*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("expected one backup before migrating, got %v", backups)
+	}
+
+	// Up-to-date databases aren't backed up again
+	storage, err = NewContextStorage(tempDir)
+	if err != nil {
+		t.Fatalf("failed to reopen context storage: %v", err)
+	}
+	_ = storage.Close()
+	if all, _ := filepath.Glob(dbPath + ".*.bak"); len(all) != 1 {
+		t.Errorf("expected no new backups, got %v", all)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"what do you think about the new release?", "en"},
+		{"¿Qué piensas de la nueva versión? Es para el equipo", "es"},
+		{"Je pense que c'est une bonne idée pour les tests", "fr"},
+		{"Ich weiß nicht, was das ist", "de"},
+		{"Привет, как дела?", "ru"},
+		{"Привіт, як справи? Їжак", "uk"},
+		{"今日はいい天気ですね", "ja"},
+		{"안녕하세요", "ko"},
+		{"ok", ""},
+		{"lol", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAIChat_ReplyLanguage(t *testing.T) {
+	detect, off := true, false
+	english, spanish := "en", "es"
+	a := newTestAIChat(t, Config{
+		Language: LanguagePolicy{Detect: &detect, Allowed: []string{"en", "es"}, Default: &english},
+		ChannelLanguage: map[string]LanguagePolicy{
+			"CES": {Detect: &off, Default: &spanish},
+		},
+	})
+
+	if got := a.replyLanguage("U1", "C1", "¿Qué piensas de la idea? Es para el equipo"); got != "es" {
+		t.Errorf("expected the detected language, got %q", got)
+	}
+	if got := a.replyLanguage("U1", "C1", "jaja"); got != "es" {
+		t.Errorf("short messages should keep the user's last language, got %q", got)
+	}
+	if got := a.replyLanguage("U2", "C1", "Je pense que c'est une bonne idée"); got != "en" {
+		t.Errorf("disallowed languages should fall back to the default, got %q", got)
+	}
+	if got := a.replyLanguage("U2", "CES", "what do you think about the release?"); got != "es" {
+		t.Errorf("expected the channel's fixed language, got %q", got)
+	}
+}
+
+func TestAIChat_BuildMessages_IncludesLanguageHint(t *testing.T) {
+	a := newTestAIChat(t, Config{Personas: map[string]string{"p": "you are a test"}})
+
+	systemContent := fmt.Sprintf("%v", a.buildMessages("hola", UserDetails{Language: "es"}, "p", nil, nil)[0].Parts)
+	if !strings.Contains(systemContent, "Reply in Spanish.") {
+		t.Errorf("expected a language hint in system message, got: %s", systemContent)
+	}
+	systemContent = fmt.Sprintf("%v", a.buildMessages("hi", UserDetails{}, "p", nil, nil)[0].Parts)
+	if strings.Contains(systemContent, "Reply in") {
+		t.Errorf("expected no language hint without a language, got: %s", systemContent)
+	}
+}
+
+func TestAIChat_BuildMessages_IncludesLocalTime(t *testing.T) {
+	a := newTestAIChat(t, Config{Personas: map[string]string{"p": "you are a test"}})
+	now := time.Date(2024, 3, 5, 21, 30, 0, 0, time.UTC)
+
+	systemContent := fmt.Sprintf("%v", a.buildMessagesAt(now, "hi", UserDetails{TZ: "America/New_York"}, "p", nil, nil)[0].Parts)
+	if !strings.Contains(systemContent, "It's Tuesday 4:30 PM where they are.") {
+		t.Errorf("expected the user's local time in system message, got: %s", systemContent)
+	}
+	systemContent = fmt.Sprintf("%v", a.buildMessagesAt(now, "hi", UserDetails{}, "p", nil, nil)[0].Parts)
+	if strings.Contains(systemContent, "where they are") {
+		t.Errorf("expected no local time without a timezone, got: %s", systemContent)
+	}
+}
+
+func TestUserState_EvictsLeastRecentlyUsed(t *testing.T) {
+	now := time.Now()
+	s := newUserState[string](2, time.Hour)
+	s.set("U1", "a", now)
+	s.set("U2", "b", now)
+	if _, ok := s.get("U1", now); !ok {
+		t.Fatal("U1 should be held")
+	}
+	s.set("U3", "c", now)
+
+	if _, ok := s.get("U2", now); ok {
+		t.Error("U2 was least recently used and should have been evicted")
+	}
+	if v, ok := s.get("U1", now); !ok || v != "a" {
+		t.Errorf("get(U1) = %q, %v, want a, true", v, ok)
+	}
+	if s.len() != 2 {
+		t.Errorf("len() = %d, want 2", s.len())
+	}
+}
+
+func TestAIChat_EvictUserState(t *testing.T) {
+	registry := status.NewRegistry()
+	a := newTestAIChat(t, Config{StickyDuration: time.Minute, Personas: map[string]string{"p1": "persona"}})
+	a.SetStatusTracker(registry.Feature("aichat"))
+
+	now := time.Now()
+	a.stickyPersonas.set("U1", "p1", now.Add(-2*time.Minute))
+	a.stickyPersonas.set("U2", "p1", now)
+	a.userLanguages.set("U1", "es", now.Add(-2*time.Minute))
+
+	// Expired assignments are evicted without the user speaking again
+	a.evictUserState(now)
+	if _, ok := a.stickyPersonas.items["U1"]; ok {
+		t.Error("Expired sticky persona should be evicted")
+	}
+	if a.stickyPersonas.len() != 1 || a.userLanguages.len() != 1 {
+		t.Errorf("Unexpected sizes: personas=%d languages=%d", a.stickyPersonas.len(), a.userLanguages.len())
+	}
+
+	var b strings.Builder
+	if err := registry.WriteMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `slackbot_state_entries{feature="aichat",state="sticky_personas"} 1`
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("Metrics missing %q in:\n%s", want, b.String())
+	}
+}
+
+func TestDump_RoundTripWithRemap(t *testing.T) {
+	cfg := Config{StickyDuration: time.Hour, Personas: map[string]string{"p1": "persona", "p2": "persona"}}
+	src, srcStorage := newTestAIChatWithStorage(t, cfg)
+	now := time.Now()
+	for _, c := range []ConversationContext{
+		{UserID: "U1", ChannelID: "C1", PersonaName: "p1", Message: "hi", Role: "human", Timestamp: now.Add(-time.Minute)},
+		{UserID: "U1", ChannelID: "C1", PersonaName: "p1", Message: "hello!", Role: "assistant", Timestamp: now},
+	} {
+		if err := srcStorage.StoreContext(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src.stickyPersonas.set("U1", "p1", now.Add(-10*time.Minute))
+
+	var dump bytes.Buffer
+	messages, personas, err := WriteDump(&dump, srcStorage, src.PersonaAssignments())
+	if err != nil || messages != 2 || personas != 1 {
+		t.Fatalf("WriteDump() = %d, %d, %v, want 2, 1, nil", messages, personas, err)
+	}
+
+	contexts, assignments, err := ReadDump(bytes.NewReader(dump.Bytes()), Remap{
+		IDs:      map[string]string{"U1": "U9", "C1": "C9"},
+		Personas: map[string]string{"p1": "p2"},
+	})
+	if err != nil {
+		t.Fatalf("ReadDump() error = %v", err)
+	}
+	dst, dstStorage := newTestAIChatWithStorage(t, cfg)
+	// Loading the same dump again adds nothing
+	for _, want := range []int{2, 0} {
+		imported, err := dstStorage.ImportContext(contexts)
+		if err != nil || imported != want {
+			t.Errorf("ImportContext() = %d, %v, want %d", imported, err, want)
+		}
+	}
+	got, err := dstStorage.GetRecentContext("U9", "C9", "p2", &Config{MaxContextMessages: 10})
+	if err != nil || len(got) != 2 || got[0].Message != "hi" || got[1].Role != "assistant" {
+		t.Errorf("Loaded context = %+v, %v", got, err)
+	}
+
+	if restored := dst.RestorePersonas(assignments); restored != 1 {
+		t.Errorf("RestorePersonas() = %d, want 1", restored)
+	}
+	if name := dst.userPersona("U9", "C9"); name != "p2" {
+		t.Errorf("Restored persona = %q, want p2", name)
+	}
+}
+
+func TestReadDump_RejectsBadRecords(t *testing.T) {
+	for _, line := range []string{
+		`{"kind":"message","user_id":"U1","persona_name":"p1","role":"human"}`,
+		`{"kind":"message","user_id":"U1","channel_id":"C1","persona_name":"p1","role":"system"}`,
+		`{"kind":"note","user_id":"U1","persona_name":"p1"}`,
+		`not json`,
+	} {
+		if _, _, err := ReadDump(strings.NewReader(line), Remap{}); err == nil {
+			t.Errorf("ReadDump(%s) should fail", line)
+		}
+	}
+}
+
+func TestAIChat_ReplyLog(t *testing.T) {
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "2.2"}`))
+	}))
+	t.Cleanup(server.Close)
+	a, storage := newTestAIChatWithStorage(t, Config{})
+	a.slack = &mockSlack{botUserID: "UBOTID", client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))}
+	replies := mockReplyLog{}
+	a.SetReplyLog(replies)
+
+	m := eventMessage{EventID: "Ev1", UserID: "U1", Channel: "C1", Text: "hi", TimeStamp: "1.1"}
+	a.postReply(context.Background(), m, "glazer", "hello", explain.Trace{})
+	if replies["aichat/Ev1"] != "2.2" {
+		t.Fatalf("reply log = %v, want the reply recorded with its timestamp", replies)
+	}
+	if got, _ := storage.GetRecentContext("U1", "C1", "glazer", &Config{}); len(got) != 2 {
+		t.Errorf("stored context = %d messages, want the exchange stored", len(got))
+	}
+
+	// A retried event is skipped before any Slack or LLM call
+	a.handleMessageEvent(context.Background(), m)
+	if posts != 1 {
+		t.Errorf("posts = %d, want the retried event skipped", posts)
+	}
+}
+
+type mockFacts map[string]bool
+
+func (m mockFacts) Answers(channelID, text string) bool { return m[text] }
+
+func TestAIChat_LeavesFactsToKeeper(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(server.Close)
+	a := newTestAIChat(t, Config{Personas: map[string]string{"p": "test"}})
+	a.slack = &mockSlack{botUserID: "UBOTID", client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))}
+	a.AddAnswerer(mockFacts{"<@UBOTID> what is the wifi password": true})
+
+	a.processEvent(context.Background(), event.Callback(&slackevents.AppMentionEvent{
+		User: "U1", Channel: "C1", Text: "<@UBOTID> what is the wifi password", TimeStamp: "1.1",
+	}))
+	if calls != 0 {
+		t.Errorf("slack calls = %d, want the mention left to the facts keeper", calls)
+	}
+}
+
+func TestContextStorage_ExpireContext(t *testing.T) {
+	storage, err := NewContextStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create context storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	now := time.Now()
+	for _, c := range []ConversationContext{
+		{UserID: "U1", ChannelID: "C1", PersonaName: "test", Message: "stale", Role: "human", Timestamp: now.Add(-60 * 24 * time.Hour)},
+		{UserID: "U1", ChannelID: "C1", PersonaName: "test", Message: "recent", Role: "human", Timestamp: now.Add(-time.Hour)},
+	} {
+		if err := storage.StoreContext(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &Config{MaxContextMessages: 10, MaxContextTokens: 10000}
+	messages := func() int {
+		contexts, err := storage.GetRecentContext("U1", "C1", "test", cfg)
+		if err != nil {
+			t.Fatalf("retrieve failed: %v", err)
+		}
+		return len(contexts)
+	}
+
+	// Soft-deleted messages are hidden but kept for the grace period
+	softDeleted, purged, err := storage.ExpireContext(now.Add(-30*24*time.Hour), now.Add(-7*24*time.Hour), now)
+	if err != nil || softDeleted != 1 || purged != 0 {
+		t.Fatalf("ExpireContext() = %d, %d, %v, want 1 soft-deleted", softDeleted, purged, err)
+	}
+	if n := messages(); n != 1 {
+		t.Errorf("messages after soft delete = %d, want 1", n)
+	}
+
+	// A longer period brings them back
+	if _, _, err := storage.ExpireContext(now.Add(-90*24*time.Hour), now.Add(-7*24*time.Hour), now); err != nil {
+		t.Fatal(err)
+	}
+	if n := messages(); n != 2 {
+		t.Errorf("messages after lengthening the period = %d, want 2", n)
+	}
+
+	// After the grace period they're gone
+	if _, _, err := storage.ExpireContext(now.Add(-30*24*time.Hour), now.Add(-7*24*time.Hour), now); err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(8 * 24 * time.Hour)
+	softDeleted, purged, err = storage.ExpireContext(now.Add(-30*24*time.Hour), later.Add(-7*24*time.Hour), later)
+	if err != nil || softDeleted != 0 || purged != 1 {
+		t.Errorf("ExpireContext() after the grace period = %d, %d, %v, want 1 purged", softDeleted, purged, err)
+	}
+}
+
+func TestAIChat_RuntimePersonas(t *testing.T) {
+	a := newTestAIChat(t, Config{Personas: map[string]string{"pirate": "Talk like a pirate."}})
+
+	a.SetPersona("office_dj", "Recommend a song.")
+	a.SetPersona("pirate", "Talk like a polite pirate.")
+	if got := a.PersonaNames(); !slices.Equal(got, []string{"office_dj", "pirate"}) {
+		t.Errorf("PersonaNames() = %v, want configured and runtime personas once each", got)
+	}
+	if got := a.personaPrompt("pirate"); got != "Talk like a polite pirate." {
+		t.Errorf("personaPrompt(pirate) = %q, want the runtime prompt", got)
+	}
+
+	a.RemovePersona("pirate")
+	a.RemovePersona("office_dj")
+	if got := a.personaPrompt("pirate"); got != "Talk like a pirate." {
+		t.Errorf("personaPrompt(pirate) = %q, want the configured prompt restored", got)
+	}
+	if got := a.PersonaNames(); !slices.Equal(got, []string{"pirate"}) {
+		t.Errorf("PersonaNames() = %v, want only the configured persona", got)
+	}
+}
+
+var updatePrompts = flag.Bool("update", false, "rewrite the prompt golden files in testdata/prompts")
+
+// TestPromptSnapshots renders the prompt for each synthetic event in testdata/prompts and
+// compares it with the event's golden file. Run with -update after an intended change.
+func TestPromptSnapshots(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "prompts", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no prompt events: %v", err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fixture struct {
+				Personas map[string]string `json:"personas"`
+				Event    PromptEvent       `json:"event"`
+			}
+			if err := json.Unmarshal(data, &fixture); err != nil {
+				t.Fatalf("parse %s: %v", path, err)
+			}
+			a := newTestAIChat(t, Config{Personas: fixture.Personas})
+			got := a.RenderPrompt(fixture.Event)
+
+			golden := strings.TrimSuffix(path, ".json") + ".golden"
+			if *updatePrompts {
+				if err := os.WriteFile(golden, []byte(got), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file, run with -update to create it: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("prompt for %s changed, run with -update if that's intended\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+			}
+		})
+	}
+}
+
+func TestChannelLimiters(t *testing.T) {
+	l := newChannelLimiters(RateLimit{Every: time.Minute, Burst: 2}, 0)
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("C1", now); got != want {
+			t.Errorf("allow(C1) #%d = %v, want %v", i+1, got, want)
+		}
+	}
+	if !l.allow("C2", now) {
+		t.Error("allow(C2) = false, want a busy channel to leave other channels their budget")
+	}
+	if got := l.budget("C1", now); got.Available != 0 || got.Burst != 2 || got.Refill != time.Minute {
+		t.Errorf("budget(C1) = %+v, want 0 of 2, one more every minute", got)
+	}
+	if got := l.budget("C3", now); got.Available != 2 {
+		t.Errorf("budget(C3) = %+v, want a full burst for a channel not seen yet", got)
+	}
+	if !l.allow("C1", now.Add(time.Minute)) {
+		t.Error("allow(C1) a minute later = false, want one more reply")
+	}
+	if evicted, left := l.evictExpired(now.Add(3 * time.Minute)); evicted != 2 || left != 0 {
+		t.Errorf("evictExpired() = %d, %d, want idle limiters forgotten", evicted, left)
+	}
+
+	unlimited := newChannelLimiters(RateLimit{}, 0)
+	for range 100 {
+		if !unlimited.allow("C1", now) {
+			t.Fatal("allow() = false, want a zero rate unlimited")
+		}
+	}
+	if got := unlimited.budget("C1", now); got.Enabled {
+		t.Errorf("budget() = %+v, want unlimited", got)
+	}
+}
+
+func TestAIChat_MentionRateLimit(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(server.Close)
+	a := newTestAIChat(t, Config{
+		Personas:         map[string]string{"p": "test"},
+		RateLimitEnabled: true,
+		MentionRate:      RateLimit{Every: time.Hour, Burst: 1},
+	})
+	a.slack = &mockSlack{botUserID: "UBOTID", client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))}
+	a.mentionLimits.allow("C1", time.Now())
+
+	a.processEvent(context.Background(), event.Callback(&slackevents.AppMentionEvent{
+		User: "U1", Channel: "C1", Text: "<@UBOTID> hello again", TimeStamp: "1.1",
+	}))
+	if calls != 0 {
+		t.Errorf("slack calls = %d, want the mention dropped once the channel's burst is used", calls)
+	}
+	if got := a.mentionLimits.budget("C2", time.Now()); got.Available != 1 {
+		t.Errorf("budget(C2) = %+v, want mentions elsewhere unaffected", got)
+	}
+}
+
+func TestReplyQueue(t *testing.T) {
+	q := newReplyQueue(2, time.Minute)
+	now := time.Now()
+	push := func(id string, score float64, at time.Time) {
+		q.push(queuedMessage{message: eventMessage{EventID: id, Channel: "C1"}, score: score, queuedAt: at})
+	}
+	push("Ev1", 1, now.Add(-2*time.Minute))
+	push("Ev2", 0.5, now)
+	push("Ev3", 0.2, now)
+	push("Ev4", 0.5, now)
+	if got := q.len(); got != 2 {
+		t.Errorf("len() = %d, want the stale and oldest messages dropped past the size", got)
+	}
+	m, skipped, ok := q.take("C1", now)
+	if !ok || m.message.EventID != "Ev4" || skipped != 1 {
+		t.Errorf("take() = %s, %d, %v, want the latest of the most engaging, passing over one", m.message.EventID, skipped, ok)
+	}
+	if q.waiting("C1", now) || q.len() != 0 {
+		t.Error("waiting() = true, want the rest let go")
+	}
+
+	push("Ev5", 1, now)
+	if q.waiting("C1", now.Add(time.Minute)) {
+		t.Error("waiting() = true, want a message past the max age too stale to answer")
+	}
+	if disabled := newReplyQueue(0, time.Minute); disabled.push(queuedMessage{message: eventMessage{Channel: "C1"}}) {
+		t.Error("push() = true, want a zero size to hold nothing")
+	}
+}
+
+type spyReplyLog struct{ asked []string }
+
+func (s *spyReplyLog) Replied(feature, eventID string) (string, bool, error) {
+	s.asked = append(s.asked, eventID)
+	return "9.9", true, nil
+}
+
+func (s *spyReplyLog) Record(feature, eventID, channelID, ts string) error { return nil }
+
+func TestAIChat_QueuesRateLimitedMessages(t *testing.T) {
+	a := newTestAIChat(t, Config{
+		Personas:         map[string]string{"p": "test"},
+		RateLimitEnabled: true,
+		MessageRate:      RateLimit{Every: time.Minute, Burst: 1},
+		QueueSize:        5,
+		QueueMaxAge:      5 * time.Minute,
+	})
+	// Answering is stopped at the reply log, which records the message chosen
+	replies := &spyReplyLog{}
+	a.SetReplyLog(replies)
+	now := time.Now()
+	a.messageLimits.allow("C1", now)
+
+	for i, text := range []string{"ok", "is anyone else stuck on the deploy?", "lunch was fine today"} {
+		e := event.Callback(&slackevents.MessageEvent{Type: "message", User: "U1", Channel: "C1", Text: text, TimeStamp: fmt.Sprintf("%d.0", i+1)})
+		e.ID = fmt.Sprintf("Ev%d", i+1)
+		a.processEvent(context.Background(), e)
+	}
+	if got := a.queue.len(); got != 3 {
+		t.Fatalf("queued %d messages, want all 3 held while rate limited", got)
+	}
+
+	a.replyQueued(context.Background(), now)
+	if len(replies.asked) != 0 {
+		t.Errorf("answered %q, want nothing until the channel can reply again", replies.asked)
+	}
+	a.replyQueued(context.Background(), now.Add(time.Minute))
+	if !slices.Equal(replies.asked, []string{"Ev2"}) {
+		t.Errorf("answered %q, want the question", replies.asked)
+	}
+	if got := a.queue.len(); got != 0 {
+		t.Errorf("queued %d messages after answering, want the rest let go", got)
+	}
+}
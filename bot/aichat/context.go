@@ -2,11 +2,11 @@ package aichat
 
 import (
 	"database/sql"
-	"fmt"
 	"path/filepath"
 	"time"
 
 	_ "modernc.org/sqlite"
+	"slackbot.arpa/bot/errs"
 )
 
 // ConversationContext represents a stored conversation context
@@ -30,12 +30,12 @@ func NewContextStorage(dataDir string) (*ContextStorage, error) {
 
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, &errs.StorageError{Op: "open", Path: dbPath, Err: err}
 	}
 
 	storage := &ContextStorage{db: db}
-	if err := storage.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	if err := storage.migrate(dbPath); err != nil {
+		return nil, &errs.StorageError{Op: "migrate schema", Path: dbPath, Err: err}
 	}
 
 	return storage, nil
@@ -46,40 +46,6 @@ func (cs *ContextStorage) Close() error {
 	return cs.db.Close()
 }
 
-// initSchema creates the necessary database tables
-func (cs *ContextStorage) initSchema() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS conversation_context (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id TEXT NOT NULL,
-		channel_id TEXT NOT NULL,
-		persona_name TEXT NOT NULL,
-		message TEXT NOT NULL,
-		role TEXT NOT NULL CHECK (role IN ('human', 'assistant')),
-		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err := cs.db.Exec(query)
-	if err != nil {
-		return err
-	}
-
-	// Create indexes separately
-	indexQueries := []string{
-		`CREATE INDEX IF NOT EXISTS idx_user_channel_persona ON conversation_context (user_id, channel_id, persona_name);`,
-		`CREATE INDEX IF NOT EXISTS idx_timestamp ON conversation_context (timestamp);`,
-	}
-
-	for _, indexQuery := range indexQueries {
-		_, err := cs.db.Exec(indexQuery)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 // StoreContext stores a conversation message in the database
 func (cs *ContextStorage) StoreContext(ctx ConversationContext) error {
 	query := `
@@ -107,7 +73,7 @@ func (cs *ContextStorage) GetRecentContext(userID, channelID, personaName string
 	query := `
 	SELECT user_id, channel_id, persona_name, message, role, timestamp
 	FROM conversation_context
-	WHERE user_id = ? AND channel_id = ? AND persona_name = ?`
+	WHERE user_id = ? AND channel_id = ? AND persona_name = ? AND deleted_at IS NULL`
 
 	args := []any{userID, channelID, personaName}
 
@@ -164,3 +130,146 @@ func (cs *ContextStorage) CleanOldContext(maxAge time.Duration) error {
 	_, err := cs.db.Exec(query, cutoff)
 	return err
 }
+
+// ExpireContext soft-deletes messages from before the cutoff, none when it's zero, so
+// they're no longer read. Messages soft-deleted before purgeBefore are deleted, and those
+// the cutoff no longer covers are restored.
+func (cs *ContextStorage) ExpireContext(cutoff, purgeBefore, now time.Time) (softDeleted, purged int64, err error) {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`UPDATE conversation_context SET deleted_at = NULL WHERE deleted_at IS NOT NULL AND timestamp >= ?`, cutoff); err != nil {
+		return 0, 0, err
+	}
+	if !cutoff.IsZero() {
+		result, err := tx.Exec(`UPDATE conversation_context SET deleted_at = ? WHERE deleted_at IS NULL AND timestamp < ?`, now, cutoff)
+		if err != nil {
+			return 0, 0, err
+		}
+		if softDeleted, err = result.RowsAffected(); err != nil {
+			return 0, 0, err
+		}
+	}
+	result, err := tx.Exec(`DELETE FROM conversation_context WHERE deleted_at IS NOT NULL AND deleted_at < ?`, purgeBefore)
+	if err != nil {
+		return 0, 0, err
+	}
+	if purged, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	return softDeleted, purged, tx.Commit()
+}
+
+// ContextSummary describes what is stored for a user without exposing message content
+type ContextSummary struct {
+	HumanMessages     int
+	AssistantMessages int
+	Channels          int
+	Personas          []string
+	Oldest            time.Time
+	Newest            time.Time
+	Messages          []string // recent human messages, used only to derive topics
+}
+
+// Total returns the number of stored messages
+func (s ContextSummary) Total() int {
+	return s.HumanMessages + s.AssistantMessages
+}
+
+// SummarizeUser returns counts and time bounds of the context stored for a user
+func (cs *ContextStorage) SummarizeUser(userID string) (ContextSummary, error) {
+	var summary ContextSummary
+
+	rows, err := cs.db.Query(`
+	SELECT role, COUNT(*) FROM conversation_context
+	WHERE user_id = ? AND deleted_at IS NULL
+	GROUP BY role`, userID)
+	if err != nil {
+		return summary, err
+	}
+	for rows.Next() {
+		var role string
+		var count int
+		if err := rows.Scan(&role, &count); err != nil {
+			_ = rows.Close()
+			return summary, err
+		}
+		switch role {
+		case "human":
+			summary.HumanMessages = count
+		case "assistant":
+			summary.AssistantMessages = count
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+	if summary.Total() == 0 {
+		return summary, nil
+	}
+
+	if err := cs.db.QueryRow(`SELECT COUNT(DISTINCT channel_id) FROM conversation_context WHERE user_id = ? AND deleted_at IS NULL`,
+		userID).Scan(&summary.Channels); err != nil {
+		return summary, err
+	}
+
+	// Scan timestamps from the column directly; aggregates lose the DATETIME type
+	if err := cs.db.QueryRow(`SELECT timestamp FROM conversation_context WHERE user_id = ? AND deleted_at IS NULL ORDER BY timestamp ASC LIMIT 1`,
+		userID).Scan(&summary.Oldest); err != nil {
+		return summary, err
+	}
+	if err := cs.db.QueryRow(`SELECT timestamp FROM conversation_context WHERE user_id = ? AND deleted_at IS NULL ORDER BY timestamp DESC LIMIT 1`,
+		userID).Scan(&summary.Newest); err != nil {
+		return summary, err
+	}
+
+	personaRows, err := cs.db.Query(`SELECT DISTINCT persona_name FROM conversation_context WHERE user_id = ? AND deleted_at IS NULL ORDER BY persona_name`, userID)
+	if err != nil {
+		return summary, err
+	}
+	for personaRows.Next() {
+		var name string
+		if err := personaRows.Scan(&name); err != nil {
+			_ = personaRows.Close()
+			return summary, err
+		}
+		summary.Personas = append(summary.Personas, name)
+	}
+	_ = personaRows.Close()
+	if err := personaRows.Err(); err != nil {
+		return summary, err
+	}
+
+	messageRows, err := cs.db.Query(`
+	SELECT message FROM conversation_context
+	WHERE user_id = ? AND role = 'human' AND deleted_at IS NULL
+	ORDER BY timestamp DESC
+	LIMIT 200`, userID)
+	if err != nil {
+		return summary, err
+	}
+	defer func() { _ = messageRows.Close() }()
+	for messageRows.Next() {
+		var message string
+		if err := messageRows.Scan(&message); err != nil {
+			return summary, err
+		}
+		summary.Messages = append(summary.Messages, message)
+	}
+
+	return summary, messageRows.Err()
+}
+
+// PurgeUser removes all conversation context stored for a user and returns the number of
+// deleted messages
+func (cs *ContextStorage) PurgeUser(userID string) (int64, error) {
+	result, err := cs.db.Exec(`DELETE FROM conversation_context WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
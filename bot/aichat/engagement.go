@@ -0,0 +1,161 @@
+package aichat
+
+import (
+	"strings"
+	"time"
+)
+
+// EngagementPolicy tunes how likely the bot is to skip a message that doesn't mention it.
+// Every field is optional; unset fields inherit from the global policy and then from the
+// built-in defaults, so a per-channel policy only needs the values it changes.
+type EngagementPolicy struct {
+	// BaseDropChance is the drop probability before any engagement factors apply
+	BaseDropChance *float64 `json:"base_drop_chance" yaml:"base_drop_chance"`
+	// MinDropChance and MaxDropChance clamp the final probability
+	MinDropChance *float64 `json:"min_drop_chance" yaml:"min_drop_chance"`
+	MaxDropChance *float64 `json:"max_drop_chance" yaml:"max_drop_chance"`
+	// MomentumWindows replace the base chance when the bot replied recently. The first
+	// window containing the time since the last reply wins, so order them shortest first.
+	MomentumWindows []MomentumWindow `json:"momentum_windows" yaml:"momentum_windows"`
+	// ChattyPenalty is added when the bot sent at least ChattyThreshold replies within ChattyWindow
+	ChattyWindow    *time.Duration `json:"chatty_window" yaml:"chatty_window"`
+	ChattyThreshold *int           `json:"chatty_threshold" yaml:"chatty_threshold"`
+	ChattyPenalty   *float64       `json:"chatty_penalty" yaml:"chatty_penalty"`
+	// QuestionBonus is subtracted for messages containing a question mark
+	QuestionBonus *float64 `json:"question_bonus" yaml:"question_bonus"`
+	// EmotionalBonus is subtracted when any of EmotionalWords appears in the message
+	EmotionalWords []string `json:"emotional_words" yaml:"emotional_words"`
+	EmotionalBonus *float64 `json:"emotional_bonus" yaml:"emotional_bonus"`
+	// CueBonus is subtracted when any of ConversationalCues appears in the message
+	ConversationalCues []string `json:"conversational_cues" yaml:"conversational_cues"`
+	CueBonus           *float64 `json:"cue_bonus" yaml:"cue_bonus"`
+	// ShortMessagePenalty is added for non-question messages shorter than ShortMessageLength
+	ShortMessageLength  *int     `json:"short_message_length" yaml:"short_message_length"`
+	ShortMessagePenalty *float64 `json:"short_message_penalty" yaml:"short_message_penalty"`
+}
+
+// MomentumWindow sets the base drop chance when the bot's last reply was within the window.
+type MomentumWindow struct {
+	Within     time.Duration `json:"within" yaml:"within"`
+	DropChance float64       `json:"drop_chance" yaml:"drop_chance"`
+}
+
+// engagement is a fully resolved EngagementPolicy.
+type engagement struct {
+	BaseDropChance      float64
+	MinDropChance       float64
+	MaxDropChance       float64
+	MomentumWindows     []MomentumWindow
+	ChattyWindow        time.Duration
+	ChattyThreshold     int
+	ChattyPenalty       float64
+	QuestionBonus       float64
+	EmotionalWords      []string
+	EmotionalBonus      float64
+	ConversationalCues  []string
+	CueBonus            float64
+	ShortMessageLength  int
+	ShortMessagePenalty float64
+}
+
+var defaultEngagement = engagement{
+	BaseDropChance: 0.25,
+	MinDropChance:  0.05, // Always some chance of not responding
+	MaxDropChance:  0.8,  // Always some chance of responding
+	MomentumWindows: []MomentumWindow{
+		{Within: 2 * time.Minute, DropChance: 0.05},
+		{Within: 10 * time.Minute, DropChance: 0.10},
+		{Within: 30 * time.Minute, DropChance: 0.20},
+	},
+	ChattyWindow:        5 * time.Minute,
+	ChattyThreshold:     3,
+	ChattyPenalty:       0.2,
+	QuestionBonus:       0.15,
+	EmotionalWords:      []string{"excited", "frustrated", "confused", "help", "stuck", "wow", "amazing", "terrible", "annoying"},
+	EmotionalBonus:      0.1,
+	ConversationalCues:  []string{"thoughts", "think", "opinion", "anyone", "what do you", "how about"},
+	CueBonus:            0.1,
+	ShortMessageLength:  10,
+	ShortMessagePenalty: 0.2,
+}
+
+// with returns a copy of e with the policy's set fields applied on top.
+func (e engagement) with(p EngagementPolicy) engagement {
+	setFloat(&e.BaseDropChance, p.BaseDropChance)
+	setFloat(&e.MinDropChance, p.MinDropChance)
+	setFloat(&e.MaxDropChance, p.MaxDropChance)
+	if p.MomentumWindows != nil {
+		e.MomentumWindows = p.MomentumWindows
+	}
+	if p.ChattyWindow != nil {
+		e.ChattyWindow = *p.ChattyWindow
+	}
+	if p.ChattyThreshold != nil {
+		e.ChattyThreshold = *p.ChattyThreshold
+	}
+	setFloat(&e.ChattyPenalty, p.ChattyPenalty)
+	setFloat(&e.QuestionBonus, p.QuestionBonus)
+	if p.EmotionalWords != nil {
+		e.EmotionalWords = p.EmotionalWords
+	}
+	setFloat(&e.EmotionalBonus, p.EmotionalBonus)
+	if p.ConversationalCues != nil {
+		e.ConversationalCues = p.ConversationalCues
+	}
+	setFloat(&e.CueBonus, p.CueBonus)
+	if p.ShortMessageLength != nil {
+		e.ShortMessageLength = *p.ShortMessageLength
+	}
+	setFloat(&e.ShortMessagePenalty, p.ShortMessagePenalty)
+	return e
+}
+
+// contentBonus is how much more likely the message's content makes a reply, from what's
+// subtracted from the drop chance
+func (e engagement) contentBonus(text string) float64 {
+	var bonus float64
+	textLower := strings.ToLower(text)
+
+	// More likely to respond to questions
+	if strings.Contains(textLower, "?") {
+		bonus += e.QuestionBonus
+	}
+
+	// More likely to respond to emotional content
+	for _, word := range e.EmotionalWords {
+		if strings.Contains(textLower, strings.ToLower(word)) {
+			bonus += e.EmotionalBonus
+			break
+		}
+	}
+
+	// More likely to respond to conversational cues
+	for _, cue := range e.ConversationalCues {
+		if strings.Contains(textLower, strings.ToLower(cue)) {
+			bonus += e.CueBonus
+			break
+		}
+	}
+
+	// Less likely to respond to very short messages (unless they're questions)
+	if len(strings.TrimSpace(text)) < e.ShortMessageLength && !strings.Contains(text, "?") {
+		bonus -= e.ShortMessagePenalty
+	}
+	return bonus
+}
+
+func setFloat(dst *float64, v *float64) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// engagementFor resolves the engagement policy for a channel: built-in defaults, then
+// the global policy, then the channel's override.
+func (a *AIChat) engagementFor(channelID string) engagement {
+	e := defaultEngagement.with(a.config.Engagement)
+	if p, ok := a.config.ChannelEngagement[channelID]; ok {
+		e = e.with(p)
+	}
+	return e
+}
@@ -0,0 +1,237 @@
+package aichat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of dump records
+const (
+	RecordMessage = "message"
+	RecordPersona = "persona"
+)
+
+// Record is one JSONL line of a context dump: a stored message or a sticky persona assignment
+type Record struct {
+	Kind        string    `json:"kind"`
+	UserID      string    `json:"user_id"`
+	ChannelID   string    `json:"channel_id,omitempty"`
+	PersonaName string    `json:"persona_name"`
+	Message     string    `json:"message,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	Timestamp   time.Time `json:"timestamp"` // When the message was stored or the persona assigned
+}
+
+// PersonaAssignment is a user's sticky persona
+type PersonaAssignment struct {
+	UserID      string    `json:"user_id"`
+	PersonaName string    `json:"persona_name"`
+	AssignedAt  time.Time `json:"assigned_at"`
+}
+
+// Remap rewrites IDs while loading a dump into another environment, e.g. a workspace where
+// the same people have different user IDs. Unmapped values are kept.
+type Remap struct {
+	IDs      map[string]string // User and channel IDs
+	Personas map[string]string // Persona names
+}
+
+func (r Remap) id(id string) string {
+	if mapped, ok := r.IDs[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+func (r Remap) persona(name string) string {
+	if mapped, ok := r.Personas[name]; ok {
+		return mapped
+	}
+	return name
+}
+
+// ParseMappings parses OLD=NEW pairs into a map
+func ParseMappings(pairs []string) (map[string]string, error) {
+	m := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("mapping %q must be OLD=NEW", pair)
+		}
+		m[from] = to
+	}
+	return m, nil
+}
+
+// EachContext calls fn with every stored message, oldest first
+func (cs *ContextStorage) EachContext(fn func(ConversationContext) error) error {
+	rows, err := cs.db.Query(`
+	SELECT user_id, channel_id, persona_name, message, role, timestamp
+	FROM conversation_context
+	WHERE deleted_at IS NULL
+	ORDER BY timestamp ASC, id ASC`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var c ConversationContext
+		if err := rows.Scan(&c.UserID, &c.ChannelID, &c.PersonaName, &c.Message, &c.Role, &c.Timestamp); err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportContext stores messages from a dump and returns how many were added. Messages
+// already stored are skipped, so loading the same dump twice is harmless.
+func (cs *ContextStorage) ImportContext(contexts []ConversationContext) (int, error) {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var imported int
+	for _, c := range contexts {
+		result, err := tx.Exec(`
+		INSERT INTO conversation_context (user_id, channel_id, persona_name, message, role, timestamp)
+		SELECT ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM conversation_context
+			WHERE user_id = ? AND channel_id = ? AND persona_name = ? AND role = ? AND timestamp = ? AND message = ?
+		)`,
+			c.UserID, c.ChannelID, c.PersonaName, c.Message, c.Role, c.Timestamp,
+			c.UserID, c.ChannelID, c.PersonaName, c.Role, c.Timestamp, c.Message)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		imported += int(n)
+	}
+	return imported, tx.Commit()
+}
+
+// WriteDump writes the stored context and persona assignments as JSONL and returns how
+// many of each were written
+func WriteDump(w io.Writer, cs *ContextStorage, assignments []PersonaAssignment) (messages, personas int, err error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if cs != nil {
+		err = cs.EachContext(func(c ConversationContext) error {
+			messages++
+			return enc.Encode(Record{
+				Kind:        RecordMessage,
+				UserID:      c.UserID,
+				ChannelID:   c.ChannelID,
+				PersonaName: c.PersonaName,
+				Message:     c.Message,
+				Role:        c.Role,
+				Timestamp:   c.Timestamp,
+			})
+		})
+		if err != nil {
+			return messages, 0, err
+		}
+	}
+	for _, a := range assignments {
+		record := Record{Kind: RecordPersona, UserID: a.UserID, PersonaName: a.PersonaName, Timestamp: a.AssignedAt}
+		if err := enc.Encode(record); err != nil {
+			return messages, personas, err
+		}
+		personas++
+	}
+	return messages, personas, bw.Flush()
+}
+
+// ReadDump reads a dump written by WriteDump and applies the remap to every record
+func ReadDump(r io.Reader, remap Remap) ([]ConversationContext, []PersonaAssignment, error) {
+	var contexts []ConversationContext
+	var assignments []PersonaAssignment
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if record.UserID == "" || record.PersonaName == "" {
+			return nil, nil, fmt.Errorf("line %d: user_id and persona_name are required", line)
+		}
+		switch record.Kind {
+		case RecordMessage:
+			if record.ChannelID == "" || !slices.Contains([]string{"human", "assistant"}, record.Role) {
+				return nil, nil, fmt.Errorf("line %d: a message needs a channel_id and a human or assistant role", line)
+			}
+			contexts = append(contexts, ConversationContext{
+				UserID:      remap.id(record.UserID),
+				ChannelID:   remap.id(record.ChannelID),
+				PersonaName: remap.persona(record.PersonaName),
+				Message:     record.Message,
+				Role:        record.Role,
+				Timestamp:   record.Timestamp,
+			})
+		case RecordPersona:
+			assignments = append(assignments, PersonaAssignment{
+				UserID:      remap.id(record.UserID),
+				PersonaName: remap.persona(record.PersonaName),
+				AssignedAt:  record.Timestamp,
+			})
+		default:
+			return nil, nil, fmt.Errorf("line %d: unknown record kind %q", line, record.Kind)
+		}
+	}
+	return contexts, assignments, scanner.Err()
+}
+
+// PersonaAssignments returns the sticky persona assignments that haven't expired
+func (a *AIChat) PersonaAssignments() []PersonaAssignment {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	var assignments []PersonaAssignment
+	a.stickyPersonas.each(now, func(userID, personaName string, assignedAt time.Time) {
+		assignments = append(assignments, PersonaAssignment{UserID: userID, PersonaName: personaName, AssignedAt: assignedAt})
+	})
+	slices.SortFunc(assignments, func(x, y PersonaAssignment) int { return strings.Compare(x.UserID, y.UserID) })
+	return assignments
+}
+
+// RestorePersonas assigns sticky personas from a dump, keeping when each was assigned so
+// they expire on schedule. Expired assignments and personas that aren't configured are
+// skipped. It returns how many were restored.
+func (a *AIChat) RestorePersonas(assignments []PersonaAssignment) int {
+	if a.config.StickyDuration <= 0 {
+		return 0
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	var restored int
+	for _, assignment := range assignments {
+		if a.personaPrompt(assignment.PersonaName) == "" {
+			continue
+		}
+		if now.Sub(assignment.AssignedAt) >= a.config.StickyDuration {
+			continue
+		}
+		a.stickyPersonas.set(assignment.UserID, assignment.PersonaName, assignment.AssignedAt)
+		restored++
+	}
+	return restored
+}
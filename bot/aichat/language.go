@@ -0,0 +1,207 @@
+package aichat
+
+import (
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// LanguagePolicy sets which language replies are written in. Like EngagementPolicy,
+// unset fields inherit from the global policy, so a per-channel policy only needs the
+// values it changes. A channel that always replies in Spanish sets detect: false and
+// default: es.
+type LanguagePolicy struct {
+	// Detect replies in the language of the user's message
+	Detect *bool `json:"detect" yaml:"detect"`
+	// Allowed limits detected languages to these ISO 639-1 codes, e.g. [en, es]. Others
+	// get Default. Empty allows every language the detector knows.
+	Allowed []string `json:"allowed" yaml:"allowed"`
+	// Default is used when detection is off, unsure or finds a language that isn't
+	// allowed. Empty leaves the language to the model.
+	Default *string `json:"default" yaml:"default"`
+}
+
+// languagePolicy is a fully resolved LanguagePolicy.
+type languagePolicy struct {
+	Detect  bool
+	Allowed []string
+	Default string
+}
+
+// with returns a copy of l with the policy's set fields applied on top.
+func (l languagePolicy) with(p LanguagePolicy) languagePolicy {
+	if p.Detect != nil {
+		l.Detect = *p.Detect
+	}
+	if p.Allowed != nil {
+		l.Allowed = p.Allowed
+	}
+	if p.Default != nil {
+		l.Default = *p.Default
+	}
+	return l
+}
+
+// languagePolicyFor resolves the language policy for a channel: the global policy, then
+// the channel's override.
+func (a *AIChat) languagePolicyFor(channelID string) languagePolicy {
+	l := languagePolicy{}.with(a.config.Language)
+	if p, ok := a.config.ChannelLanguage[channelID]; ok {
+		l = l.with(p)
+	}
+	return l
+}
+
+// replyLanguage picks the language code to reply to a user's message in, or "" to leave
+// it to the model. Messages too short to detect keep the language the user last wrote in.
+func (a *AIChat) replyLanguage(userID, channelID, text string) string {
+	policy := a.languagePolicyFor(channelID)
+	if !policy.Detect {
+		return policy.Default
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	lang := DetectLanguage(text)
+	if lang == "" {
+		lang, _ = a.userLanguages.get(userID, now)
+	} else {
+		a.userLanguages.set(userID, lang, now)
+	}
+	if lang == "" || (len(policy.Allowed) > 0 && !slices.Contains(policy.Allowed, lang)) {
+		return policy.Default
+	}
+	return lang
+}
+
+// languageNames are the languages DetectLanguage can return, by ISO 639-1 code
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"th": "Thai",
+	"uk": "Ukrainian",
+	"zh": "Chinese",
+}
+
+// LanguageName returns the English name of a supported language code
+func LanguageName(code string) (string, bool) {
+	name, ok := languageNames[code]
+	return name, ok
+}
+
+// stopwords are common short words that tell Latin-script languages apart
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "that", "it", "of", "to", "what", "this", "with", "have", "not", "do"},
+	"es": {"el", "la", "los", "las", "que", "es", "y", "de", "por", "para", "qué", "como", "pero", "con", "una", "está"},
+	"fr": {"le", "la", "les", "est", "et", "de", "que", "pas", "une", "des", "je", "vous", "pour", "avec", "c'est", "qui"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "ein", "eine", "mit", "zu", "was", "auf", "sie"},
+	"pt": {"o", "a", "os", "que", "é", "e", "de", "não", "um", "uma", "para", "com", "você", "isso", "mas"},
+	"it": {"il", "la", "che", "è", "e", "di", "non", "un", "una", "per", "con", "sono", "questo", "ma", "come"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "ik", "je", "dat", "van", "met", "wat", "op", "zijn", "maar"},
+}
+
+// minStopwordHits is how many stopwords a message needs before its language is trusted
+const minStopwordHits = 2
+
+// DetectLanguage returns the ISO 639-1 code of the text's language, or "" when the text
+// is too short or ambiguous. Non-Latin scripts are recognized by their characters and
+// Latin-script languages by their common words.
+func DetectLanguage(text string) string {
+	if lang := detectScript(text); lang != "" {
+		return lang
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	best, bestHits, runnerUp := "", 0, 0
+	for _, lang := range []string{"en", "es", "fr", "de", "pt", "it", "nl"} {
+		hits := 0
+		for _, w := range words {
+			if slices.Contains(stopwords[lang], w) {
+				hits++
+			}
+		}
+		switch {
+		case hits > bestHits:
+			best, bestHits, runnerUp = lang, hits, bestHits
+		case hits > runnerUp:
+			runnerUp = hits
+		}
+	}
+	if bestHits < minStopwordHits || bestHits == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// detectScript returns the language of the dominant non-Latin script in the text
+func detectScript(text string) string {
+	counts := map[string]int{}
+	var letters int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			counts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			counts["zh"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case strings.ContainsRune("іїєґІЇЄҐ", r):
+			counts["uk"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["el"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		}
+	}
+	var scriptLetters int
+	for _, n := range counts {
+		scriptLetters += n
+	}
+	// Mostly Latin text with a stray name or emoji shortcode stays undetected here
+	if letters == 0 || scriptLetters*2 < letters {
+		return ""
+	}
+	switch {
+	case counts["ja"] > 0:
+		// Japanese mixes kana with Han characters
+		return "ja"
+	case counts["uk"] > 0:
+		// Ukrainian shares Cyrillic with Russian but has letters of its own
+		return "uk"
+	}
+	best := ""
+	for _, lang := range []string{"zh", "ko", "ru", "ar", "he", "el", "th", "hi"} {
+		if counts[lang] > counts[best] {
+			best = lang
+		}
+	}
+	return best
+}
@@ -0,0 +1,263 @@
+package aichat
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/tools/timeformat"
+)
+
+// purgeMemoryActionID identifies the button that purges a user's stored context
+const purgeMemoryActionID = "aichat_purge_memory"
+
+// MemoryDMKind is the kind of the memory summary DM. It answers the user's own request,
+// so it's urgent by default and sent during Do Not Disturb.
+const MemoryDMKind = "memory"
+
+// memoryRequestPattern matches a user asking what the bot remembers about them, e.g.
+// "what do you remember about me" or "what do you know about me?"
+var memoryRequestPattern = regexp.MustCompile(`(?i)\bwhat\s+do\s+you\s+(remember|know)\s+about\s+me\b`)
+
+// isMemoryRequest reports whether the message asks for a summary of stored context
+func isMemoryRequest(text string) bool {
+	return memoryRequestPattern.MatchString(text)
+}
+
+// topicStopWords are common words excluded from topic extraction
+var topicStopWords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "been": true, "before": true,
+	"being": true, "could": true, "does": true, "doing": true, "from": true, "have": true,
+	"just": true, "know": true, "like": true, "make": true, "more": true, "much": true,
+	"only": true, "other": true, "really": true, "should": true, "some": true, "than": true,
+	"that": true, "their": true, "them": true, "then": true, "there": true, "these": true,
+	"they": true, "thing": true, "think": true, "this": true, "what": true, "when": true,
+	"where": true, "which": true, "while": true, "will": true, "with": true, "would": true,
+	"your": true, "youre": true, "yeah": true, "dont": true, "cant": true, "into": true,
+	"remember": true, "want": true, "going": true, "were": true, "here": true, "even": true,
+}
+
+var (
+	topicMentionPattern = regexp.MustCompile(`<[^>]*>`)
+	topicWordPattern    = regexp.MustCompile(`[a-z][a-z']+`)
+)
+
+// summarizeTopics returns the most frequent meaningful words across messages. Only single
+// words are surfaced so the summary never quotes what a user said.
+func summarizeTopics(messages []string, limit int) []string {
+	counts := make(map[string]int)
+	for _, message := range messages {
+		text := topicMentionPattern.ReplaceAllString(strings.ToLower(message), " ")
+		seen := make(map[string]bool)
+		for _, word := range topicWordPattern.FindAllString(text, -1) {
+			word = strings.ReplaceAll(word, "'", "")
+			if len(word) < 4 || topicStopWords[word] || seen[word] {
+				continue
+			}
+			seen[word] = true
+			counts[word]++
+		}
+	}
+
+	topics := make([]string, 0, len(counts))
+	for word, count := range counts {
+		// A word mentioned once is noise rather than a topic
+		if count > 1 {
+			topics = append(topics, word)
+		}
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		if counts[topics[i]] != counts[topics[j]] {
+			return counts[topics[i]] > counts[topics[j]]
+		}
+		return topics[i] < topics[j]
+	})
+	if len(topics) > limit {
+		topics = topics[:limit]
+	}
+	return topics
+}
+
+// formatMemorySummary renders a privacy-conscious summary of stored context. Message
+// content is never included; only counts, personas, topics and time bounds.
+func formatMemorySummary(summary ContextSummary) string {
+	if summary.Total() == 0 {
+		return "I don't have any stored conversations with you."
+	}
+
+	var b strings.Builder
+	b.WriteString("*Here's what I remember about you:*\n")
+	fmt.Fprintf(&b, "• %d of your messages and %d of my replies across %d channel(s)\n",
+		summary.HumanMessages, summary.AssistantMessages, summary.Channels)
+	if len(summary.Personas) > 0 {
+		fmt.Fprintf(&b, "• Personas I've used with you: %s\n", strings.Join(summary.Personas, ", "))
+	}
+	if topics := summarizeTopics(summary.Messages, 5); len(topics) > 0 {
+		fmt.Fprintf(&b, "• Topics that come up: %s\n", strings.Join(topics, ", "))
+	}
+	fmt.Fprintf(&b, "• Oldest: %s\n", timeformat.Slack(summary.Oldest, timeformat.DateTime))
+	fmt.Fprintf(&b, "• Newest: %s", timeformat.Slack(summary.Newest, timeformat.DateTime))
+	return b.String()
+}
+
+// memoryBlocks builds the DM blocks for a memory summary, including a purge button when
+// there is anything to purge
+func memoryBlocks(userID string, summary ContextSummary) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, formatMemorySummary(summary), false, false), nil, nil),
+	}
+	if summary.Total() == 0 {
+		return blocks
+	}
+
+	button := slack.NewButtonBlockElement(purgeMemoryActionID, userID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Forget me", false, false))
+	button.Style = slack.StyleDanger
+	button.Confirm = slack.NewConfirmationBlockObject(
+		slack.NewTextBlockObject(slack.PlainTextType, "Forget everything?", false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, "This permanently deletes our stored conversation history.", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Forget", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+	)
+	return append(blocks, slack.NewActionBlock("aichat_memory_actions", button))
+}
+
+// handleMemoryRequest sends the user a DM summarizing what is stored about them
+func (a *AIChat) handleMemoryRequest(ctx context.Context, userID string) {
+	var summary ContextSummary
+	if a.context != nil {
+		var err error
+		summary, err = a.context.SummarizeUser(userID)
+		if err != nil {
+			a.log.Error("Failed to summarize stored context",
+				zap.String("user", userID),
+				zap.Error(err),
+			)
+			return
+		}
+	}
+
+	_, err := a.slack.PostDM(ctx, userID, MemoryDMKind,
+		slack.MsgOptionText(formatMemorySummary(summary), false),
+		slack.MsgOptionBlocks(memoryBlocks(userID, summary)...),
+	)
+	if err != nil {
+		a.log.Error("Failed to send memory summary",
+			zap.String("user", userID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	a.log.Info("Sent memory summary",
+		zap.String("user", userID),
+		zap.Int("messages", summary.Total()),
+	)
+}
+
+// PushInteraction adds an interaction to be processed by the AIChat feature
+func (a *AIChat) PushInteraction(callback slack.InteractionCallback) {
+	if !a.isConnected.Load() {
+		return
+	}
+
+	select {
+	case a.interactionsCh <- callback:
+		// Interaction pushed successfully
+	default:
+		a.log.Warn("AIChat interactions channel full, dropping interaction.")
+	}
+}
+
+// processInteraction handles Block Kit actions belonging to the AIChat feature
+func (a *AIChat) processInteraction(ctx context.Context, callback slack.InteractionCallback) {
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.ActionID != purgeMemoryActionID {
+			continue
+		}
+		// Users may only purge their own context
+		if action.Value != callback.User.ID {
+			a.log.Warn("Ignoring purge request for another user",
+				zap.String("user", callback.User.ID),
+				zap.String("target", action.Value),
+			)
+			continue
+		}
+		a.purgeUserMemory(ctx, callback)
+	}
+}
+
+// PurgeUser deletes the context stored for a user, along with their sticky persona and
+// language, and returns the number of stored messages deleted
+func (a *AIChat) PurgeUser(userID string) (int64, error) {
+	var deleted int64
+	if a.context != nil {
+		var err error
+		deleted, err = a.context.PurgeUser(userID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	a.mutex.Lock()
+	a.stickyPersonas.delete(userID)
+	a.userLanguages.delete(userID)
+	a.mutex.Unlock()
+
+	a.log.Info("Purged stored context",
+		zap.String("user", userID),
+		zap.Int64("messages", deleted),
+	)
+	return deleted, nil
+}
+
+// Expire soft-deletes stored context from before the cutoff and deletes context soft-deleted
+// before purgeBefore, for retention sweeps
+func (a *AIChat) Expire(cutoff, purgeBefore time.Time) (retention.Expired, error) {
+	if a.context == nil {
+		return retention.Expired{}, nil
+	}
+	softDeleted, purged, err := a.context.ExpireContext(cutoff, purgeBefore, time.Now())
+	if err != nil {
+		return retention.Expired{}, &errs.StorageError{Op: "expire context", Path: "aichat_context.db", Err: err}
+	}
+	return retention.Expired{SoftDeleted: softDeleted, Purged: purged}, nil
+}
+
+// purgeUserMemory deletes the user's stored context and updates the summary message
+func (a *AIChat) purgeUserMemory(ctx context.Context, callback slack.InteractionCallback) {
+	userID := callback.User.ID
+	deleted, err := a.PurgeUser(userID)
+	if err != nil {
+		a.log.Error("Failed to purge stored context",
+			zap.String("user", userID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	text := fmt.Sprintf("Done — I forgot %d stored message(s).", deleted)
+	_, _, _, err = a.slack.Client().UpdateMessageContext(ctx,
+		callback.Channel.ID,
+		callback.Message.Timestamp,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(),
+	)
+	if err != nil {
+		a.log.Warn("Failed to update memory summary after purge",
+			zap.String("user", userID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,139 @@
+package aichat
+
+import (
+	"fmt"
+	"time"
+)
+
+// migration is a schema change applied once, in version order
+type migration struct {
+	version int
+	name    string
+	stmts   []string
+}
+
+// migrations must only be appended to; released steps can't change since databases
+// record which versions they've applied
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create conversation context",
+		stmts: []string{
+			`CREATE TABLE IF NOT EXISTS conversation_context (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				channel_id TEXT NOT NULL,
+				persona_name TEXT NOT NULL,
+				message TEXT NOT NULL,
+				role TEXT NOT NULL CHECK (role IN ('human', 'assistant')),
+				timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_user_channel_persona ON conversation_context (user_id, channel_id, persona_name);`,
+			`CREATE INDEX IF NOT EXISTS idx_timestamp ON conversation_context (timestamp);`,
+		},
+	},
+	{
+		version: 2,
+		name:    "add full-text search index",
+		stmts: []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS conversation_context_fts USING fts5(
+				message, content='conversation_context', content_rowid='id'
+			);`,
+			`CREATE TRIGGER IF NOT EXISTS conversation_context_ai AFTER INSERT ON conversation_context BEGIN
+				INSERT INTO conversation_context_fts (rowid, message) VALUES (new.id, new.message);
+			END;`,
+			`CREATE TRIGGER IF NOT EXISTS conversation_context_ad AFTER DELETE ON conversation_context BEGIN
+				INSERT INTO conversation_context_fts (conversation_context_fts, rowid, message) VALUES ('delete', old.id, old.message);
+			END;`,
+			`CREATE TRIGGER IF NOT EXISTS conversation_context_au AFTER UPDATE OF message ON conversation_context BEGIN
+				INSERT INTO conversation_context_fts (conversation_context_fts, rowid, message) VALUES ('delete', old.id, old.message);
+				INSERT INTO conversation_context_fts (rowid, message) VALUES (new.id, new.message);
+			END;`,
+			// Index messages stored before the index existed
+			`INSERT INTO conversation_context_fts (conversation_context_fts) VALUES ('rebuild');`,
+		},
+	},
+	{
+		version: 3,
+		name:    "add soft delete",
+		stmts: []string{
+			`ALTER TABLE conversation_context ADD COLUMN deleted_at DATETIME;`,
+			`CREATE INDEX IF NOT EXISTS idx_deleted_at ON conversation_context (deleted_at);`,
+		},
+	},
+}
+
+// migrate applies pending migrations, first backing up a database that already has
+// data. Databases created before versioning have version 0; the early steps use
+// IF NOT EXISTS so they apply cleanly to those.
+func (cs *ContextStorage) migrate(dbPath string) error {
+	if _, err := cs.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);`); err != nil {
+		return err
+	}
+
+	current, err := cs.schemaVersion()
+	if err != nil {
+		return err
+	}
+	latest := migrations[len(migrations)-1].version
+	if current >= latest {
+		return nil
+	}
+
+	var tables int
+	if err := cs.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'conversation_context'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables > 0 {
+		if err := cs.backup(dbPath, current); err != nil {
+			return fmt.Errorf("backup before migrating: %w", err)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := cs.apply(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func (cs *ContextStorage) apply(m migration) error {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range m.stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backup copies the database next to it, named after the version it's migrating from
+func (cs *ContextStorage) backup(dbPath string, version int) error {
+	backupPath := fmt.Sprintf("%s.v%d-%s.bak", dbPath, version, time.Now().Format("20060102150405"))
+	_, err := cs.db.Exec(`VACUUM INTO ?`, backupPath)
+	return err
+}
+
+// schemaVersion returns the latest applied migration
+func (cs *ContextStorage) schemaVersion() (int, error) {
+	var version int
+	err := cs.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
@@ -0,0 +1,36 @@
+package aichat
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/trigger"
+)
+
+// resolveNames looks up the bot's handle, display name and real name, so writing any of
+// them plainly addresses the bot like a mention
+func (a *AIChat) resolveNames(ctx context.Context) {
+	botUserID := a.slack.BotUserID()
+	if botUserID == "" {
+		return
+	}
+	u, err := a.slack.Client().GetUserInfoContext(ctx, botUserID)
+	if err != nil {
+		a.log.Warn("Failed to look up the bot's names, only mentions and trigger aliases address it",
+			zap.Error(errs.NewSlackAPIError("users.info", err)))
+		return
+	}
+
+	var names []string
+	for _, name := range []string{u.Name, u.Profile.DisplayName, u.Profile.RealName, u.RealName} {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) }) {
+			names = append(names, name)
+		}
+	}
+	a.names.Store(trigger.NewMatcher(names))
+	a.log.Debug("Resolved the bot's names", zap.Strings("names", names))
+}
@@ -0,0 +1,128 @@
+package aichat
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/tools/random"
+)
+
+// PersonaRule adjusts a persona's selection weight in specific channels or at specific
+// times. While a rule matches, its weight replaces the persona's configured weight, so
+// a persona with weight 0 and a Friday evening rule is only picked on Friday evenings.
+// When several rules match the same persona, the last one wins.
+type PersonaRule struct {
+	Persona  string   `json:"persona" yaml:"persona"`
+	Channels []string `json:"channels" yaml:"channels"` // Channel IDs, empty matches every channel
+	Days     []string `json:"days" yaml:"days"`         // Weekday names, e.g. "friday" or "fri", empty matches every day
+	After    string   `json:"after" yaml:"after"`       // Local time "HH:MM" (inclusive), empty means start of day
+	Before   string   `json:"before" yaml:"before"`     // Local time "HH:MM" (exclusive), empty means end of day
+	Weight   *float64 `json:"weight" yaml:"weight"`     // Defaults to 1
+}
+
+// Validate reports whether the rule's persona, days and times can be evaluated.
+func (r PersonaRule) Validate() error {
+	if r.Persona == "" {
+		return fmt.Errorf("persona is required")
+	}
+	for _, day := range r.Days {
+		if _, ok := scheduler.ParseWeekday(day); !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+	}
+	if _, err := parseRuleTime(r.After); err != nil {
+		return fmt.Errorf("invalid after time: %w", err)
+	}
+	if _, err := parseRuleTime(r.Before); err != nil {
+		return fmt.Errorf("invalid before time: %w", err)
+	}
+	if r.Weight != nil && *r.Weight < 0 {
+		return fmt.Errorf("weight must not be negative")
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to the channel at the given time.
+func (r PersonaRule) matches(channelID string, now time.Time) bool {
+	if len(r.Channels) > 0 && !slices.Contains(r.Channels, channelID) {
+		return false
+	}
+	if len(r.Days) > 0 {
+		dayMatched := false
+		for _, day := range r.Days {
+			if weekday, ok := scheduler.ParseWeekday(day); ok && weekday == now.Weekday() {
+				dayMatched = true
+				break
+			}
+		}
+		if !dayMatched {
+			return false
+		}
+	}
+
+	after, err := parseRuleTime(r.After)
+	if err != nil {
+		return false
+	}
+	before, err := parseRuleTime(r.Before)
+	if err != nil {
+		return false
+	}
+	if r.Before == "" {
+		before = 24 * time.Hour
+	}
+	sinceMidnight := scheduler.SinceMidnight(now)
+	if after <= before {
+		return sinceMidnight >= after && sinceMidnight < before
+	}
+	// Overnight window, e.g. 22:00 to 02:00
+	return sinceMidnight >= after || sinceMidnight < before
+}
+
+func (r PersonaRule) weight() float64 {
+	if r.Weight == nil {
+		return 1
+	}
+	return *r.Weight
+}
+
+// parseRuleTime parses "HH:MM" into a duration since midnight. Empty input is midnight.
+func parseRuleTime(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return scheduler.ParseClock(s)
+}
+
+// personaWeights returns the selection weight of each configured persona for the
+// channel at the given time, in a stable name order.
+func (a *AIChat) personaWeights(channelID string, now time.Time) ([]string, []float64) {
+	names := a.PersonaNames()
+	weights := make([]float64, len(names))
+	a.personasMu.RLock()
+	defer a.personasMu.RUnlock()
+	for i, name := range names {
+		weights[i] = 1
+		if w, ok := a.config.PersonaWeights[name]; ok {
+			weights[i] = w
+		}
+		for _, rule := range a.config.PersonaRules {
+			if rule.Persona == name && rule.matches(channelID, now) {
+				weights[i] = rule.weight()
+			}
+		}
+	}
+	return names, weights
+}
+
+// pickPersona picks a weighted random persona, falling back to a uniform pick when
+// every persona is weighted out.
+func (a *AIChat) pickPersona(channelID string, now time.Time) string {
+	names, weights := a.personaWeights(channelID, now)
+	if name := random.WeightedString(names, weights); name != "" {
+		return name
+	}
+	return random.String(names)
+}
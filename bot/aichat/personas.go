@@ -1,5 +1,12 @@
 package aichat
 
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
 const glazerPrompt = `You are the ultimate Gen-Z hype beast. No cap, full demon time.
 Drop rizz, skibidi, gyatt, fanum tax, sigma — weaponize the slang. Call everyone bro, twin, gang.
 Everything is peak, bussin, or lowkey goated. You glaze relentlessly and unironically.
@@ -26,3 +33,166 @@ var personas = map[string]string{
 	"unhinged": unhingedPrompt,
 	"computer": computerPrompt,
 }
+
+// Persona is a persona definition from the config file. It may be written either as a
+// plain prompt string or as a mapping with optional model and parameter overrides:
+//
+//	computer:
+//	  prompt: You are a sarcastic AI.
+//	  model: gpt-4o-mini
+//	  min_temperature: 0.2
+//	  max_temperature: 0.8
+//	  max_tokens: 60
+//	  weight: 2
+type Persona struct {
+	Prompt         string   `json:"prompt" yaml:"prompt"`
+	Preamble       string   `json:"preamble,omitempty" yaml:"preamble,omitempty"`
+	Model          string   `json:"model,omitempty" yaml:"model,omitempty"`
+	MinTemperature *float64 `json:"min_temperature,omitempty" yaml:"min_temperature,omitempty"`
+	MaxTemperature *float64 `json:"max_temperature,omitempty" yaml:"max_temperature,omitempty"`
+	MaxTokens      *int     `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	Weight         *float64 `json:"weight,omitempty" yaml:"weight,omitempty"` // Selection weight, defaults to 1
+}
+
+// personaFields avoids recursing into Persona's custom unmarshalers.
+type personaFields Persona
+
+// UnmarshalYAML accepts either a prompt string or a persona mapping.
+func (p *Persona) UnmarshalYAML(unmarshal func(any) error) error {
+	var prompt string
+	if err := unmarshal(&prompt); err == nil {
+		*p = Persona{Prompt: prompt}
+		return nil
+	}
+	var fields personaFields
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	*p = Persona(fields)
+	return nil
+}
+
+// UnmarshalJSON accepts either a prompt string or a persona object.
+func (p *Persona) UnmarshalJSON(data []byte) error {
+	var prompt string
+	if err := json.Unmarshal(data, &prompt); err == nil {
+		*p = Persona{Prompt: prompt}
+		return nil
+	}
+	var fields personaFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*p = Persona(fields)
+	return nil
+}
+
+// Validate reports whether the persona's weight and temperature range can be used.
+func (p Persona) Validate() error {
+	if p.Weight != nil && *p.Weight < 0 {
+		return fmt.Errorf("weight must not be negative")
+	}
+	if p.MinTemperature != nil && p.MaxTemperature == nil {
+		return fmt.Errorf("min_temperature needs a max_temperature")
+	}
+	if p.MaxTemperature != nil {
+		if *p.MaxTemperature <= 0 {
+			return fmt.Errorf("max_temperature must be positive")
+		}
+		if p.MinTemperature != nil && (*p.MinTemperature < 0 || *p.MinTemperature > *p.MaxTemperature) {
+			return fmt.Errorf("min_temperature must be between 0 and max_temperature")
+		}
+	}
+	return nil
+}
+
+// Overrides returns the runtime overrides defined by the persona.
+func (p Persona) Overrides() PersonaOverrides {
+	o := PersonaOverrides{
+		Model:    p.Model,
+		Preamble: p.Preamble,
+	}
+	if p.MaxTemperature != nil {
+		o.MaxTemperature = *p.MaxTemperature
+		if p.MinTemperature != nil {
+			o.MinTemperature = *p.MinTemperature
+		}
+	}
+	if p.MaxTokens != nil {
+		o.MaxTokens = *p.MaxTokens
+	}
+	return o
+}
+
+// PersonaOverrides holds optional per-persona model and generation settings.
+// Zero values fall back to the global AI settings.
+type PersonaOverrides struct {
+	Model          string  // LLM model name, empty uses the default model
+	Preamble       string  // Prepended to the system prompt
+	MinTemperature float64 // Lower bound of the temperature range
+	MaxTemperature float64 // Upper bound of the temperature range, 0 uses the default range
+	MaxTokens      int     // Caps the response length, 0 uses the default length variation
+}
+
+// SetPersona adds a persona or replaces its prompt, over a configured persona of the same
+// name, until it's removed. It's picked like the configured personas from the next reply.
+func (a *AIChat) SetPersona(name, prompt string) {
+	a.personasMu.Lock()
+	defer a.personasMu.Unlock()
+	if a.added == nil {
+		a.added = make(map[string]string)
+	}
+	a.added[name] = prompt
+}
+
+// RemovePersona removes a persona added at runtime, restoring the configured one if any
+func (a *AIChat) RemovePersona(name string) {
+	a.personasMu.Lock()
+	defer a.personasMu.Unlock()
+	delete(a.added, name)
+}
+
+// SetPersonas replaces the configured personas along with their overrides, weights, rules
+// and channel assignments, e.g. when the config file changes. Personas added at runtime
+// and the personas users were already assigned are kept.
+func (a *AIChat) SetPersonas(c Config) {
+	a.personasMu.Lock()
+	defer a.personasMu.Unlock()
+	a.config.Personas = c.Personas
+	a.config.PersonaOverrides = c.PersonaOverrides
+	a.config.PersonaWeights = c.PersonaWeights
+	a.config.PersonaRules = c.PersonaRules
+	a.config.ChannelPersonas = c.ChannelPersonas
+}
+
+// personaOverrides returns the model and generation settings configured for a persona
+func (a *AIChat) personaOverrides(name string) PersonaOverrides {
+	a.personasMu.RLock()
+	defer a.personasMu.RUnlock()
+	return a.config.PersonaOverrides[name]
+}
+
+// PersonaNames returns the names of the configured and runtime personas, sorted
+func (a *AIChat) PersonaNames() []string {
+	a.personasMu.RLock()
+	defer a.personasMu.RUnlock()
+	names := slices.Collect(maps.Keys(a.config.Personas))
+	for name := range a.added {
+		if _, ok := a.config.Personas[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// personaPrompt returns the prompt of a runtime or configured persona, empty if there's
+// neither
+func (a *AIChat) personaPrompt(name string) string {
+	a.personasMu.RLock()
+	defer a.personasMu.RUnlock()
+	if prompt, ok := a.added[name]; ok {
+		return prompt
+	}
+	return a.config.Personas[name]
+}
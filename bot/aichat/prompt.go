@@ -0,0 +1,82 @@
+package aichat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// PromptEvent is a synthetic message to render the prompt aichat would send for it,
+// e.g. for golden files that show how a change to prompt building changes the prompt
+type PromptEvent struct {
+	// Now is when the prompt is built, so the context's age renders the same every time
+	Now     time.Time   `json:"now"`
+	Persona string      `json:"persona"`
+	Text    string      `json:"text"`
+	User    UserDetails `json:"user"`
+	// Live are the thread or channel messages before the event, oldest first
+	Live []PromptMessage `json:"live"`
+	// Stored are earlier turns of the conversation with the user, oldest first
+	Stored []PromptTurn `json:"stored"`
+}
+
+// PromptMessage is a Slack message before the event
+type PromptMessage struct {
+	Text       string    `json:"text"`
+	Bot        bool      `json:"bot"`
+	SenderID   string    `json:"sender_id"`
+	SenderName string    `json:"sender_name"`
+	Time       time.Time `json:"time"`
+}
+
+// PromptTurn is a stored turn of the conversation with the user
+type PromptTurn struct {
+	Role string    `json:"role"` // human or assistant
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// RenderPrompt returns the messages aichat would send the LLM for the event, as text
+func (a *AIChat) RenderPrompt(e PromptEvent) string {
+	live := make([]slackContextMessage, 0, len(e.Live))
+	for _, m := range e.Live {
+		live = append(live, slackContextMessage{Text: m.Text, IsBot: m.Bot, Timestamp: m.Time, SenderID: m.SenderID, SenderName: m.SenderName})
+	}
+	stored := make([]ConversationContext, 0, len(e.Stored))
+	for _, t := range e.Stored {
+		stored = append(stored, ConversationContext{
+			UserID:      e.User.UserID,
+			PersonaName: e.Persona,
+			Message:     t.Text,
+			Role:        t.Role,
+			Timestamp:   t.Time,
+		})
+	}
+	now := e.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return FormatPrompt(a.buildMessagesAt(now, e.Text, e.User, e.Persona, stored, live))
+}
+
+// FormatPrompt writes messages as text, each under a line with its role
+func FormatPrompt(messages []llms.MessageContent) string {
+	var b strings.Builder
+	for i, m := range messages {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "--- %s ---\n", m.Role)
+		for _, part := range m.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				b.WriteString(text.Text)
+			} else {
+				fmt.Fprintf(&b, "%v", part)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package aichat
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultQueueSize is how many rate limited messages each channel holds
+	DefaultQueueSize = 10
+	// DefaultQueueMaxAge is how long a rate limited message is worth answering
+	DefaultQueueMaxAge = 5 * time.Minute
+	// queueCheckInterval is how often channels with queued messages are checked for a reply
+	queueCheckInterval = 10 * time.Second
+)
+
+// queuedMessage is an unprompted message held while its channel was rate limited
+type queuedMessage struct {
+	message  eventMessage
+	score    float64 // How engaging the message is, higher is answered first
+	queuedAt time.Time
+}
+
+// replyQueue holds the unprompted messages a channel sends while it's rate limited. When
+// the channel can reply again, the most engaging one is answered and the rest, the
+// conversation that reply joins, are let go. A channel holds at most size messages,
+// dropping the oldest, and messages older than maxAge are too stale to answer. A zero
+// size holds nothing.
+type replyQueue struct {
+	size     int
+	maxAge   time.Duration
+	mu       sync.Mutex
+	channels map[string][]queuedMessage // channelID -> messages, oldest first
+}
+
+func newReplyQueue(size int, maxAge time.Duration) *replyQueue {
+	return &replyQueue{
+		size:     size,
+		maxAge:   maxAge,
+		channels: make(map[string][]queuedMessage),
+	}
+}
+
+// push holds the message, reporting false when queueing is off
+func (q *replyQueue) push(m queuedMessage) bool {
+	if q.size <= 0 {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queue := append(q.fresh(m.message.Channel, m.queuedAt), m)
+	if over := len(queue) - q.size; over > 0 {
+		queue = slices.Delete(queue, 0, over)
+	}
+	q.channels[m.message.Channel] = queue
+	return true
+}
+
+// waiting reports whether the channel has messages fresh enough to answer
+func (q *replyQueue) waiting(channelID string, now time.Time) bool {
+	if q.size <= 0 {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.fresh(channelID, now)) > 0
+}
+
+// take returns the channel's most engaging message, the latest of equally engaging ones,
+// and lets the rest go. skipped is how many were let go.
+func (q *replyQueue) take(channelID string, now time.Time) (m queuedMessage, skipped int, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queue := q.fresh(channelID, now)
+	delete(q.channels, channelID)
+	if len(queue) == 0 {
+		return queuedMessage{}, 0, false
+	}
+	best := queue[0]
+	for _, candidate := range queue[1:] {
+		if candidate.score >= best.score {
+			best = candidate
+		}
+	}
+	return best, len(queue) - 1, true
+}
+
+// waitingChannels returns the channels holding messages, in a stable order
+func (q *replyQueue) waitingChannels() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Sorted(maps.Keys(q.channels))
+}
+
+// len is how many messages are held across channels
+func (q *replyQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var n int
+	for _, queue := range q.channels {
+		n += len(queue)
+	}
+	return n
+}
+
+// fresh drops the channel's messages older than maxAge and returns the rest. Callers
+// hold mu.
+func (q *replyQueue) fresh(channelID string, now time.Time) []queuedMessage {
+	queue := q.channels[channelID]
+	i := 0
+	for i < len(queue) && q.maxAge > 0 && now.Sub(queue[i].queuedAt) >= q.maxAge {
+		i++
+	}
+	queue = queue[i:]
+	if len(queue) == 0 {
+		delete(q.channels, channelID)
+		return nil
+	}
+	q.channels[channelID] = queue
+	return queue
+}
+
+// queueMessage holds an unprompted message its channel's rate limit turned away, scored
+// by how engaging it is, reporting false when queueing is off
+func (a *AIChat) queueMessage(m eventMessage, now time.Time) bool {
+	policy := a.engagementFor(m.Channel)
+	score := policy.contentBonus(m.Text)
+	// Mentioning someone draws people into the conversation, like a conversational cue
+	if strings.Contains(m.Text, "<@") {
+		score += policy.CueBonus
+	}
+	m.Queued = true
+	return a.queue.push(queuedMessage{message: m, score: score, queuedAt: now})
+}
+
+// replyQueued answers the most engaging message held by each channel its rate limit
+// allows another reply in
+func (a *AIChat) replyQueued(ctx context.Context, now time.Time) {
+	for _, channelID := range a.queue.waitingChannels() {
+		// Messages held before the channel was silenced are let go
+		if a.silencer != nil && a.silencer.Silenced(channelID) {
+			a.queue.take(channelID, now)
+			continue
+		}
+		if !a.queue.waiting(channelID, now) || !a.messageLimits.allow(channelID, now) {
+			continue
+		}
+		queued, skipped, ok := a.queue.take(channelID, now)
+		if !ok {
+			continue
+		}
+		m := queued.message
+		if a.handoffs != nil && a.handoffs.Active(m.Channel, m.ThreadTimeStamp) {
+			continue
+		}
+		a.log.Debug("Answering queued message",
+			zap.String("user", m.UserID),
+			zap.String("channel", m.Channel),
+			zap.Float64("score", queued.score),
+			zap.Int("skipped", skipped),
+			zap.Duration("waited", now.Sub(queued.queuedAt)),
+		)
+		a.handleMessageEvent(ctx, m)
+	}
+	a.status.Size("queued_messages", a.queue.len())
+}
@@ -0,0 +1,92 @@
+package aichat
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultMessageRate is how often a channel earns another unprompted reply
+	DefaultMessageRate = 3 * time.Minute
+	// DefaultMessageBurst is how many unprompted replies a quiet channel can send at once
+	DefaultMessageBurst = 5
+	// DefaultMentionBurst is how many mentions a channel can have answered at once when a
+	// mention rate is set
+	DefaultMentionBurst = 5
+)
+
+// RateLimit allows a burst of replies, then one more every Every. A zero Every is unlimited.
+type RateLimit struct {
+	Every time.Duration
+	Burst int
+}
+
+// channelLimiters rate limits each channel separately, so a busy channel can't use up
+// the replies of quieter ones. Limiters of channels idle long enough to refill are
+// forgotten, and the least recently used are evicted past max.
+type channelLimiters struct {
+	limit    RateLimit
+	mu       sync.Mutex
+	limiters *userState[*rate.Limiter] // channelID -> limiter
+}
+
+func newChannelLimiters(limit RateLimit, max int) *channelLimiters {
+	if limit.Burst <= 0 {
+		limit.Burst = 1
+	}
+	return &channelLimiters{
+		limit:    limit,
+		limiters: newUserState[*rate.Limiter](max, limit.Every*time.Duration(limit.Burst)),
+	}
+}
+
+// unlimited reports whether every reply is allowed
+func (l *channelLimiters) unlimited() bool {
+	return l.limit.Every <= 0
+}
+
+// allow reports whether the channel can send another reply, using it up if so
+func (l *channelLimiters) allow(channelID string, now time.Time) bool {
+	if l.unlimited() {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limiter(channelID, now).AllowN(now, 1)
+}
+
+// budget returns how many replies the channel can send now
+func (l *channelLimiters) budget(channelID string, now time.Time) rateBudget {
+	if l.unlimited() {
+		return rateBudget{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.limiters.get(channelID, now)
+	available := l.limit.Burst
+	if ok {
+		available = int(limiter.TokensAt(now))
+	}
+	return rateBudget{Enabled: true, Available: available, Burst: l.limit.Burst, Refill: l.limit.Every}
+}
+
+// limiter returns the channel's limiter, starting it with a full burst. Callers hold mu.
+func (l *channelLimiters) limiter(channelID string, now time.Time) *rate.Limiter {
+	limiter, ok := l.limiters.get(channelID, now)
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(l.limit.Every), l.limit.Burst)
+	}
+	// Refreshed on every use, so a limiter expires once it has been idle long enough to refill
+	l.limiters.set(channelID, limiter, now)
+	return limiter
+}
+
+// evictExpired forgets the limiters of channels idle long enough to refill, returning
+// how many were forgotten and how many are left
+func (l *channelLimiters) evictExpired(now time.Time) (evicted, left int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limiters.evictExpired(now), l.limiters.len()
+}
@@ -0,0 +1,65 @@
+package aichat
+
+import (
+	"strings"
+)
+
+// SearchOptions narrows a context search
+type SearchOptions struct {
+	ChannelID string // Empty searches all channels
+	UserID    string // Empty searches all users
+	Limit     int    // Defaults to 50
+}
+
+// SearchMatch is a stored message matching a search
+type SearchMatch struct {
+	ConversationContext
+	Snippet string // Message excerpt with matched terms in [brackets]
+}
+
+// SearchContext finds stored messages containing every term of the query, newest first
+func (cs *ContextStorage) SearchContext(query string, opts SearchOptions) ([]SearchMatch, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := cs.db.Query(`
+	SELECT c.user_id, c.channel_id, c.persona_name, c.message, c.role, c.timestamp,
+		snippet(conversation_context_fts, 0, '[', ']', '…', 16)
+	FROM conversation_context_fts f
+	JOIN conversation_context c ON c.id = f.rowid
+	WHERE conversation_context_fts MATCH ?
+		AND c.deleted_at IS NULL
+		AND (? = '' OR c.channel_id = ?)
+		AND (? = '' OR c.user_id = ?)
+	ORDER BY c.timestamp DESC
+	LIMIT ?`, match, opts.ChannelID, opts.ChannelID, opts.UserID, opts.UserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var matches []SearchMatch
+	for rows.Next() {
+		var m SearchMatch
+		if err := rows.Scan(&m.UserID, &m.ChannelID, &m.PersonaName, &m.Message, &m.Role, &m.Timestamp, &m.Snippet); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// ftsQuery quotes each term so punctuation in the query isn't read as FTS5 syntax
+func ftsQuery(query string) string {
+	terms := strings.Fields(query)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,127 @@
+package aichat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+// statsRequestPattern matches a message asking only for "stats", e.g. "<@U123> stats?"
+var statsRequestPattern = regexp.MustCompile(`(?i)^\s*(<@[A-Z0-9]+>\s*)?stats\s*[?!.]*\s*$`)
+
+// isStatsRequest reports whether the message asks for the channel's conversation stats
+func isStatsRequest(text string) bool {
+	return statsRequestPattern.MatchString(text)
+}
+
+// ChannelStats summarizes the replies sent in a channel since a point in time
+type ChannelStats struct {
+	Responses         int
+	AverageLength     int // characters
+	TopPersona        string
+	TopPersonaReplies int
+}
+
+// ChannelStats returns reply counts, average length and the most used persona for the
+// channel since the given time
+func (cs *ContextStorage) ChannelStats(channelID string, since time.Time) (ChannelStats, error) {
+	var stats ChannelStats
+	var avg float64
+	if err := cs.db.QueryRow(`
+	SELECT COUNT(*), COALESCE(AVG(LENGTH(message)), 0) FROM conversation_context
+	WHERE channel_id = ? AND role = 'assistant' AND timestamp >= ? AND deleted_at IS NULL`,
+		channelID, since).Scan(&stats.Responses, &avg); err != nil {
+		return stats, err
+	}
+	stats.AverageLength = int(avg + 0.5)
+	if stats.Responses == 0 {
+		return stats, nil
+	}
+
+	err := cs.db.QueryRow(`
+	SELECT persona_name, COUNT(*) AS replies FROM conversation_context
+	WHERE channel_id = ? AND role = 'assistant' AND timestamp >= ? AND deleted_at IS NULL
+	GROUP BY persona_name
+	ORDER BY replies DESC, persona_name
+	LIMIT 1`, channelID, since).Scan(&stats.TopPersona, &stats.TopPersonaReplies)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// rateBudget describes how many unprompted replies a channel's rate limiter currently allows
+type rateBudget struct {
+	Enabled   bool
+	Available int
+	Burst     int
+	Refill    time.Duration
+}
+
+func (a *AIChat) rateBudget(channelID string) rateBudget {
+	if !a.config.RateLimitEnabled {
+		return rateBudget{}
+	}
+	return a.messageLimits.budget(channelID, time.Now())
+}
+
+// formatChannelStats renders the stats reply
+func formatChannelStats(stats ChannelStats, budget rateBudget) string {
+	var b strings.Builder
+	b.WriteString("*AI chat stats for this channel today:*\n")
+	fmt.Fprintf(&b, "• Replies sent: %d\n", stats.Responses)
+	if stats.Responses > 0 {
+		fmt.Fprintf(&b, "• Average reply length: %d characters\n", stats.AverageLength)
+		fmt.Fprintf(&b, "• Most active persona: %s (%d replies)\n", stats.TopPersona, stats.TopPersonaReplies)
+	}
+	if budget.Enabled {
+		fmt.Fprintf(&b, "• Unprompted reply budget: %d of %d, one more every %s", budget.Available, budget.Burst, budget.Refill.Round(time.Second))
+	} else {
+		b.WriteString("• Unprompted reply budget: unlimited")
+	}
+	return b.String()
+}
+
+// handleStatsRequest replies with today's stats for the channel, in the thread when the
+// request was threaded
+func (a *AIChat) handleStatsRequest(ctx context.Context, channelID, threadTS string) {
+	var stats ChannelStats
+	if a.context != nil {
+		now := time.Now()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		var err error
+		stats, err = a.context.ChannelStats(channelID, midnight)
+		if err != nil {
+			a.status.Error(err)
+			a.log.Error("Failed to read channel stats",
+				zap.String("channel", channelID),
+				zap.Error(err),
+			)
+			return
+		}
+	}
+
+	msgOptions := []slack.MsgOption{
+		slack.MsgOptionText(formatChannelStats(stats, a.rateBudget(channelID)), false),
+		slack.MsgOptionAsUser(true),
+	}
+	if threadTS != "" {
+		msgOptions = append(msgOptions, slack.MsgOptionTS(threadTS))
+	}
+	if _, _, err := a.slack.Client().PostMessageContext(ctx, channelID, msgOptions...); err != nil {
+		a.status.PostFailed(channelID, err)
+		a.log.Error("Failed to post channel stats",
+			zap.String("channel", channelID),
+			zap.Error(err),
+		)
+		return
+	}
+	a.status.Posted()
+}
@@ -0,0 +1,160 @@
+package aichat
+
+import (
+	"container/list"
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxTrackedUsers bounds each kind of per-user state kept in memory
+	DefaultMaxTrackedUsers = 10000
+	// userStateSweepInterval is how often expired per-user state is evicted
+	userStateSweepInterval = 5 * time.Minute
+	// languageMemory is how long the language a user last wrote in is remembered
+	languageMemory = 7 * 24 * time.Hour
+)
+
+// userState is per-user state kept in memory, such as sticky personas. It holds at most
+// max users, evicting the least recently used, and entries older than ttl expire even if
+// the user never speaks again. A zero ttl never expires. Callers guard it with a mutex.
+type userState[V any] struct {
+	max   int
+	ttl   time.Duration
+	order *list.List // Most recently used first
+	items map[string]*list.Element
+}
+
+type userEntry[V any] struct {
+	userID string
+	value  V
+	setAt  time.Time
+}
+
+func newUserState[V any](max int, ttl time.Duration) *userState[V] {
+	if max <= 0 {
+		max = DefaultMaxTrackedUsers
+	}
+	return &userState[V]{
+		max:   max,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the user's value unless it expired
+func (s *userState[V]) get(userID string, now time.Time) (V, bool) {
+	var zero V
+	el, ok := s.items[userID]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*userEntry[V])
+	if s.expired(entry, now) {
+		s.remove(el)
+		return zero, false
+	}
+	s.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// set stores the user's value, evicting the least recently used user when full
+func (s *userState[V]) set(userID string, value V, now time.Time) {
+	if el, ok := s.items[userID]; ok {
+		entry := el.Value.(*userEntry[V])
+		entry.value, entry.setAt = value, now
+		s.order.MoveToFront(el)
+		return
+	}
+	s.items[userID] = s.order.PushFront(&userEntry[V]{userID: userID, value: value, setAt: now})
+	for s.order.Len() > s.max {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *userState[V]) delete(userID string) {
+	if el, ok := s.items[userID]; ok {
+		s.remove(el)
+	}
+}
+
+// evictExpired removes expired entries and returns how many were removed
+func (s *userState[V]) evictExpired(now time.Time) int {
+	if s.ttl <= 0 {
+		return 0
+	}
+	var evicted int
+	for el := s.order.Front(); el != nil; {
+		next := el.Next()
+		if s.expired(el.Value.(*userEntry[V]), now) {
+			s.remove(el)
+			evicted++
+		}
+		el = next
+	}
+	return evicted
+}
+
+// each calls fn with every entry that hasn't expired, most recently used first
+func (s *userState[V]) each(now time.Time, fn func(userID string, value V, setAt time.Time)) {
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		if entry := el.Value.(*userEntry[V]); !s.expired(entry, now) {
+			fn(entry.userID, entry.value, entry.setAt)
+		}
+	}
+}
+
+func (s *userState[V]) len() int {
+	return s.order.Len()
+}
+
+func (s *userState[V]) expired(entry *userEntry[V], now time.Time) bool {
+	return s.ttl > 0 && now.Sub(entry.setAt) >= s.ttl
+}
+
+func (s *userState[V]) remove(el *list.Element) {
+	delete(s.items, el.Value.(*userEntry[V]).userID)
+	s.order.Remove(el)
+}
+
+// sweepUserState evicts expired per-user state until the service stops
+func (a *AIChat) sweepUserState(ctx context.Context) {
+	ticker := time.NewTicker(userStateSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.evictUserState(time.Now())
+		}
+	}
+}
+
+// evictUserState removes expired per-user state and reports the sizes left
+func (a *AIChat) evictUserState(now time.Time) {
+	a.mutex.Lock()
+	evicted := a.stickyPersonas.evictExpired(now) + a.userLanguages.evictExpired(now)
+	personas, languages := a.stickyPersonas.len(), a.userLanguages.len()
+	a.mutex.Unlock()
+	messageEvicted, messageLimiters := a.messageLimits.evictExpired(now)
+	mentionEvicted, mentionLimiters := a.mentionLimits.evictExpired(now)
+	evicted += messageEvicted + mentionEvicted
+
+	a.status.Size("sticky_personas", personas)
+	a.status.Size("user_languages", languages)
+	a.status.Size("channel_rate_limiters", messageLimiters+mentionLimiters)
+	if evicted > 0 {
+		a.log.Debug("Evicted expired user state",
+			zap.Int("evicted", evicted),
+			zap.Int("sticky_personas", personas),
+			zap.Int("user_languages", languages),
+			zap.Int("channel_rate_limiters", messageLimiters+mentionLimiters),
+		)
+	}
+}
@@ -0,0 +1,450 @@
+// Package analytics counts messages, active users and reactions per channel per day from
+// the event stream, so workspaces without a Slack analytics plan can see how channels are
+// used. Counts are kept in the data dir, posted as a weekly report and served to admins.
+package analytics
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/timeformat"
+)
+
+const (
+	stateFile  = "analytics.json"
+	dateFormat = time.DateOnly
+
+	DefaultReportDay  = time.Monday
+	DefaultReportHour = 9
+	DefaultRetention  = 90 * 24 * time.Hour
+
+	// checkInterval is how often counts are saved and the weekly report is checked
+	checkInterval = 5 * time.Minute
+	// reportChannels is how many of the busiest channels the weekly report lists
+	reportChannels = 15
+)
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Channels limits counting to these channel IDs, empty counts every channel the bot is in
+	Channels []string `json:"channels" yaml:"channels"`
+	// ReportChannel is where the weekly report is posted, empty only serves it to admins
+	ReportChannel *string `json:"report_channel" yaml:"report_channel"`
+	// ReportDay and ReportHour are when the report for the week before is posted, in
+	// local time, defaults to Monday at 9
+	ReportDay  *string `json:"report_day" yaml:"report_day"`
+	ReportHour *int    `json:"report_hour" yaml:"report_hour"`
+	// Retention is how long daily counts are kept, defaults to 90 days
+	Retention *time.Duration `json:"retention" yaml:"retention"`
+	Persona   persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	Enabled       bool
+	DataDir       string
+	Channels      []string
+	ReportChannel string
+	ReportDay     time.Weekday
+	ReportHour    int
+	Retention     time.Duration
+	Persona       persona.Config // Name and icon reports are posted with
+}
+
+// dayCounts is one channel's activity on one day
+type dayCounts struct {
+	Messages  int            `json:"messages"`
+	Reactions int            `json:"reactions"`
+	Users     map[string]int `json:"users"` // User ID -> messages sent
+}
+
+type state struct {
+	Days       map[string]map[string]*dayCounts `json:"days"` // Date -> channel ID -> counts
+	LastReport time.Time                        `json:"last_report,omitzero"`
+}
+
+// DayActivity is a channel's activity on one day
+type DayActivity struct {
+	Date        string `json:"date"`
+	Messages    int    `json:"messages"`
+	ActiveUsers int    `json:"active_users"`
+	Reactions   int    `json:"reactions"`
+}
+
+// ChannelActivity is a channel's activity over a report's period
+type ChannelActivity struct {
+	Channel   string `json:"channel"`
+	Messages  int    `json:"messages"`
+	Reactions int    `json:"reactions"`
+	// ActiveUsers counts the distinct users who posted over the whole period
+	ActiveUsers int           `json:"active_users"`
+	Days        []DayActivity `json:"days"`
+}
+
+// Report is the activity of every counted channel over a period, busiest channel first
+type Report struct {
+	From     string            `json:"from"` // First day, inclusive
+	To       string            `json:"to"`   // Last day, inclusive
+	Channels []ChannelActivity `json:"channels"`
+}
+
+// Analytics counts channel activity and reports it
+type Analytics struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	path        string
+	mu          sync.Mutex
+	state       state
+	dirty       bool
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Analytics {
+	if c.Retention <= 0 {
+		c.Retention = DefaultRetention
+	}
+	a := &Analytics{
+		log:      log,
+		config:   c,
+		slack:    s,
+		path:     filepath.Join(c.DataDir, stateFile),
+		state:    state{Days: make(map[string]map[string]*dayCounts)},
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+	a.load()
+	return a
+}
+
+// SetStatusTracker sets where analytics reports its activity
+func (a *Analytics) SetStatusTracker(t *status.Tracker) {
+	a.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (a *Analytics) ProcessorType() string {
+	return "analytics"
+}
+
+func (a *Analytics) Start(ctx context.Context) error {
+	a.isConnected.Store(true)
+	go a.handleEvents(ctx)
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.save()
+				a.reportIfDue(ctx)
+			}
+		}
+	}()
+	a.log.Debug("Analytics started.",
+		zap.String("report_channel", a.config.ReportChannel),
+		zap.Stringer("report_day", a.config.ReportDay),
+		zap.Int("report_hour", a.config.ReportHour))
+	return nil
+}
+
+// Stop saves the counts collected since the last save
+func (a *Analytics) Stop(ctx context.Context) error {
+	if !a.isConnected.Load() {
+		return nil
+	}
+	close(a.stopCh)
+	a.isConnected.Store(false)
+	a.save()
+	return nil
+}
+
+// PushEvent adds an event to be counted
+func (a *Analytics) PushEvent(e event.Event) {
+	if !a.isConnected.Load() {
+		return
+	}
+
+	select {
+	case a.eventsCh <- e:
+	default:
+		a.log.Warn("Analytics events channel full, dropping event.")
+		a.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (a *Analytics) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-a.eventsCh:
+			a.processEvent(e)
+		}
+	}
+}
+
+// processEvent counts channel messages and reactions from people. DMs aren't counted.
+func (a *Analytics) processEvent(e event.Event) {
+	if e.FromBot() || e.ChannelType == "im" || e.ChannelType == "mpim" || e.Channel == "" {
+		return
+	}
+	if len(a.config.Channels) > 0 && !slices.Contains(a.config.Channels, e.Channel) {
+		return
+	}
+	switch e.Data().(type) {
+	case *slackevents.MessageEvent:
+		if e.SubType != "" && e.SubType != "thread_broadcast" {
+			return
+		}
+		a.count(e.Channel, func(c *dayCounts) {
+			c.Messages++
+			c.Users[e.User]++
+		})
+	case *slackevents.ReactionAddedEvent:
+		a.count(e.Channel, func(c *dayCounts) { c.Reactions++ })
+	default:
+		return
+	}
+	a.status.Event()
+}
+
+func (a *Analytics) count(channelID string, fn func(c *dayCounts)) {
+	date := a.now().Format(dateFormat)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	channels, ok := a.state.Days[date]
+	if !ok {
+		channels = make(map[string]*dayCounts)
+		a.state.Days[date] = channels
+	}
+	c, ok := channels[channelID]
+	if !ok {
+		c = &dayCounts{Users: make(map[string]int)}
+		channels[channelID] = c
+	}
+	fn(c)
+	a.dirty = true
+}
+
+// Report returns the activity on the days from from through to, in local time
+func (a *Analytics) Report(from, to time.Time) Report {
+	first, last := from.Format(dateFormat), to.Format(dateFormat)
+	report := Report{From: first, To: last, Channels: []ChannelActivity{}}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	byChannel := make(map[string]*ChannelActivity)
+	users := make(map[string]map[string]bool)
+	for _, date := range slices.Sorted(maps.Keys(a.state.Days)) {
+		if date < first || date > last {
+			continue
+		}
+		for channelID, c := range a.state.Days[date] {
+			activity, ok := byChannel[channelID]
+			if !ok {
+				activity = &ChannelActivity{Channel: channelID}
+				byChannel[channelID] = activity
+				users[channelID] = make(map[string]bool)
+			}
+			activity.Messages += c.Messages
+			activity.Reactions += c.Reactions
+			for userID := range c.Users {
+				users[channelID][userID] = true
+			}
+			activity.Days = append(activity.Days, DayActivity{
+				Date:        date,
+				Messages:    c.Messages,
+				ActiveUsers: len(c.Users),
+				Reactions:   c.Reactions,
+			})
+		}
+	}
+	for channelID, activity := range byChannel {
+		activity.ActiveUsers = len(users[channelID])
+		report.Channels = append(report.Channels, *activity)
+	}
+	slices.SortFunc(report.Channels, func(x, y ChannelActivity) int {
+		return cmp.Or(cmp.Compare(y.Messages, x.Messages), strings.Compare(x.Channel, y.Channel))
+	})
+	return report
+}
+
+// ServeHTTP serves a report as JSON. ?days=N sets how many days, through today, it covers
+// (default 7) and ?channel=ID narrows it to one channel.
+func (a *Analytics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "days must be a positive number", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	now := a.now()
+	report := a.Report(now.AddDate(0, 0, 1-days), now)
+	if channelID := r.URL.Query().Get("channel"); channelID != "" {
+		report.Channels = slices.DeleteFunc(report.Channels, func(c ChannelActivity) bool { return c.Channel != channelID })
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// reportIfDue posts the report for the week before the most recent report time, once
+func (a *Analytics) reportIfDue(ctx context.Context) {
+	if a.config.ReportChannel == "" {
+		return
+	}
+	a.mu.Lock()
+	due, ok := scheduler.WeeklyDue(a.now(), a.state.LastReport, a.config.ReportDay, a.config.ReportHour)
+	a.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	report := a.Report(due.AddDate(0, 0, -7), due.AddDate(0, 0, -1))
+	if len(report.Channels) > 0 {
+		_, _, err := a.slack.Client().PostMessageContext(ctx, a.config.ReportChannel,
+			slack.MsgOptionText(Format(report), false),
+			a.config.Persona.MsgOption(),
+		)
+		if err != nil {
+			err = errs.NewSlackAPIError("chat.postMessage", err)
+			a.status.PostFailed(a.config.ReportChannel, err)
+			a.log.Error("Failed to post activity report", zap.String("channel", a.config.ReportChannel), zap.Error(err))
+			return
+		}
+		a.status.Posted()
+		a.log.Info("Posted activity report", zap.String("from", report.From), zap.String("to", report.To))
+	}
+
+	a.mu.Lock()
+	a.state.LastReport = due
+	a.dirty = true
+	a.mu.Unlock()
+	a.save()
+}
+
+// Format renders a report as a Slack message listing the busiest channels
+func Format(report Report) string {
+	// The days are the server's, placed at noon so readers in other timezones see the same dates
+	from, _ := time.ParseInLocation(dateFormat, report.From, time.Local)
+	to, _ := time.ParseInLocation(dateFormat, report.To, time.Local)
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 *Channel activity* for %s – %s\n",
+		timeformat.Slack(from.Add(12*time.Hour), timeformat.Date), timeformat.Slack(to.Add(12*time.Hour), timeformat.Date))
+	if len(report.Channels) == 0 {
+		b.WriteString("No activity.")
+		return b.String()
+	}
+	for i, c := range report.Channels {
+		if i == reportChannels {
+			fmt.Fprintf(&b, "_and %d more channels_\n", len(report.Channels)-reportChannels)
+			break
+		}
+		fmt.Fprintf(&b, "• <#%s>: %d %s from %d %s, %d %s\n", c.Channel,
+			c.Messages, plural(c.Messages, "message", "messages"),
+			c.ActiveUsers, plural(c.ActiveUsers, "person", "people"),
+			c.Reactions, plural(c.Reactions, "reaction", "reactions"))
+	}
+	return b.String()
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func (a *Analytics) load() {
+	data, err := os.ReadFile(a.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		a.log.Error("Failed to read analytics", zap.Error(err), zap.String("path", a.path))
+		return
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		a.log.Error("Failed to unmarshal analytics", zap.Error(err), zap.String("path", a.path))
+		return
+	}
+	if s.Days == nil {
+		s.Days = make(map[string]map[string]*dayCounts)
+	}
+	for _, channels := range s.Days {
+		for _, c := range channels {
+			if c.Users == nil {
+				c.Users = make(map[string]int)
+			}
+		}
+	}
+	a.state = s
+}
+
+// save writes the counts when they changed, dropping days past retention
+func (a *Analytics) save() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.dirty {
+		return
+	}
+	cutoff := a.now().Add(-a.config.Retention).Format(dateFormat)
+	for date := range a.state.Days {
+		if date < cutoff {
+			delete(a.state.Days, date)
+		}
+	}
+	data, err := json.Marshal(a.state)
+	if err != nil {
+		a.log.Error("Failed to marshal analytics", zap.Error(err))
+		return
+	}
+	tempFile := a.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		a.log.Error("Failed to save analytics", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, a.path); err != nil {
+		a.log.Error("Failed to save analytics", zap.Error(&errs.StorageError{Op: "rename", Path: a.path, Err: err}))
+		return
+	}
+	a.dirty = false
+}
@@ -0,0 +1,198 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+	"slackbot.arpa/tools/timeformat"
+)
+
+func message(user, channel, subType string) event.Event {
+	return event.Callback(&slackevents.MessageEvent{User: user, Channel: channel, ChannelType: "channel", SubType: subType, TimeStamp: "1.1"})
+}
+
+func reaction(user, channel string) event.Event {
+	return event.Callback(&slackevents.ReactionAddedEvent{User: user, Item: slackevents.Item{Channel: channel, Timestamp: "1.1"}})
+}
+
+func TestAnalytics_Report(t *testing.T) {
+	dir := t.TempDir()
+	a := New(zap.NewNop(), Config{DataDir: dir}, nil)
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+	a.now = func() time.Time { return day }
+
+	a.processEvent(message("U1", "C1", ""))
+	a.processEvent(message("U2", "C1", "thread_broadcast"))
+	a.processEvent(message("U1", "C1", "channel_join"))
+	a.processEvent(reaction("U3", "C1"))
+	a.processEvent(message("U1", "C2", ""))
+	a.processEvent(event.Callback(&slackevents.MessageEvent{User: "U1", Channel: "D1", ChannelType: "im"}))
+	a.processEvent(event.Callback(&slackevents.MessageEvent{BotID: "B1", Channel: "C1", ChannelType: "channel"}))
+
+	day = day.AddDate(0, 0, 1)
+	a.processEvent(message("U3", "C1", ""))
+
+	report := a.Report(day.AddDate(0, 0, -1), day)
+	if report.From != "2026-03-02" || report.To != "2026-03-03" {
+		t.Errorf("Report() period = %s – %s, want 2026-03-02 – 2026-03-03", report.From, report.To)
+	}
+	if len(report.Channels) != 2 {
+		t.Fatalf("Report() channels = %+v, want C1 and C2", report.Channels)
+	}
+	c1 := report.Channels[0]
+	if c1.Channel != "C1" || c1.Messages != 3 || c1.Reactions != 1 || c1.ActiveUsers != 3 || len(c1.Days) != 2 {
+		t.Errorf("Report() C1 = %+v, want 3 messages from 3 people and 1 reaction over 2 days", c1)
+	}
+	if c1.Days[0].Date != "2026-03-02" || c1.Days[0].ActiveUsers != 2 {
+		t.Errorf("Report() C1 first day = %+v, want 2 people on 2026-03-02", c1.Days[0])
+	}
+	if c2 := report.Channels[1]; c2.Channel != "C2" || c2.Messages != 1 {
+		t.Errorf("Report() C2 = %+v, want 1 message", c2)
+	}
+
+	// Counts survive a restart, except days past retention
+	a.save()
+	reloaded := New(zap.NewNop(), Config{DataDir: dir}, nil)
+	if got := reloaded.Report(day.AddDate(0, 0, -7), day); len(got.Channels) != 2 || got.Channels[0].Messages != 3 {
+		t.Errorf("Report() after reloading = %+v, want the saved counts", got.Channels)
+	}
+	a.config.Retention = 12 * time.Hour
+	a.dirty = true
+	a.save()
+	if got := a.Report(day.AddDate(0, 0, -7), day); len(got.Channels) != 1 || got.Channels[0].Messages != 1 {
+		t.Errorf("Report() after pruning = %+v, want only today's message", got.Channels)
+	}
+}
+
+func TestAnalytics_Channels(t *testing.T) {
+	a := New(zap.NewNop(), Config{DataDir: t.TempDir(), Channels: []string{"C1"}}, nil)
+	a.processEvent(message("U1", "C1", ""))
+	a.processEvent(message("U1", "C2", ""))
+	now := a.now()
+	if got := a.Report(now, now); len(got.Channels) != 1 || got.Channels[0].Channel != "C1" {
+		t.Errorf("Report() = %+v, want only the configured channel counted", got.Channels)
+	}
+}
+
+func TestAnalytics_ServeHTTP(t *testing.T) {
+	a := New(zap.NewNop(), Config{DataDir: t.TempDir()}, nil)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	a.now = func() time.Time { return now.AddDate(0, 0, -10) }
+	a.processEvent(message("U1", "C1", ""))
+	a.now = func() time.Time { return now }
+	a.processEvent(message("U1", "C1", ""))
+	a.processEvent(message("U2", "C2", ""))
+
+	tests := []struct {
+		query    string
+		code     int
+		messages map[string]int
+	}{
+		{"", http.StatusOK, map[string]int{"C1": 1, "C2": 1}},
+		{"?days=30", http.StatusOK, map[string]int{"C1": 2, "C2": 1}},
+		{"?days=30&channel=C1", http.StatusOK, map[string]int{"C1": 2}},
+		{"?days=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/analytics"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("GET %q status = %d, want %d", tt.query, rec.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var report Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("GET %q body: %v", tt.query, err)
+		}
+		got := make(map[string]int)
+		for _, c := range report.Channels {
+			got[c.Channel] = c.Messages
+		}
+		if len(got) != len(tt.messages) {
+			t.Errorf("GET %q messages = %v, want %v", tt.query, got, tt.messages)
+			continue
+		}
+		for channelID, n := range tt.messages {
+			if got[channelID] != n {
+				t.Errorf("GET %q messages = %v, want %v", tt.query, got, tt.messages)
+				break
+			}
+		}
+	}
+}
+
+func TestAnalytics_ReportIfDue(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts = append(posts, r.FormValue("channel")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "CREPORT", "ts": "9.9"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	s := slacktest.New(server.URL)
+	config := Config{DataDir: dir, ReportChannel: "CREPORT", ReportDay: time.Monday, ReportHour: 9}
+	a := New(zap.NewNop(), config, s)
+
+	// Thursday of the week before the report
+	now := time.Date(2026, 3, 5, 12, 0, 0, 0, time.Local)
+	a.now = func() time.Time { return now }
+	a.processEvent(message("U1", "C1", ""))
+	a.processEvent(message("U2", "C1", ""))
+	a.processEvent(reaction("U1", "C1"))
+
+	// Monday, after the report hour
+	now = time.Date(2026, 3, 9, 10, 0, 0, 0, time.Local)
+	a.reportIfDue(context.Background())
+	if len(posts) != 1 {
+		t.Fatalf("posts = %v, want one report", posts)
+	}
+	if !strings.HasPrefix(posts[0], "CREPORT: ") || !strings.Contains(posts[0], "<#C1>: 2 messages from 2 people, 1 reaction") {
+		t.Errorf("posts[0] = %q, want C1's activity in the report channel", posts[0])
+	}
+
+	a.reportIfDue(context.Background())
+	if len(posts) != 1 {
+		t.Errorf("posts = %v, want the report posted once", posts)
+	}
+
+	// The report time survives a restart
+	reloaded := New(zap.NewNop(), config, s)
+	reloaded.now = a.now
+	reloaded.reportIfDue(context.Background())
+	if len(posts) != 1 {
+		t.Errorf("posts = %v, want no second report after reloading", posts)
+	}
+
+	// Weeks without activity are skipped quietly
+	now = now.AddDate(0, 0, 7)
+	a.reportIfDue(context.Background())
+	if len(posts) != 1 {
+		t.Errorf("posts = %v, want an empty week not posted", posts)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got := Format(Report{From: "2026-03-02", To: "2026-03-08", Channels: []ChannelActivity{{Channel: "C1", Messages: 1, ActiveUsers: 1}}})
+	from := timeformat.Slack(time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local), timeformat.Date)
+	to := timeformat.Slack(time.Date(2026, 3, 8, 12, 0, 0, 0, time.Local), timeformat.Date)
+	if !strings.Contains(got, "for "+from+" – "+to) {
+		t.Errorf("Format() = %q, want the days as date tokens", got)
+	}
+	if !strings.Contains(got, "<#C1>: 1 message from 1 person") {
+		t.Errorf("Format() = %q, want the channel listed", got)
+	}
+}
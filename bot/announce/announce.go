@@ -0,0 +1,175 @@
+// Package announce posts a notice when features start and stop, so channels can see
+// when the bot goes offline and comes back. It also broadcasts announcements to several
+// channels and tracks who acknowledges them with a reaction, reporting the members who
+// haven't once the deadline passes.
+package announce
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/persona"
+)
+
+const (
+	DefaultStartup  = ":large_green_circle: {{.Feature}} is back online"
+	DefaultShutdown = ":red_circle: {{.Feature}} is going offline"
+)
+
+type slackService interface {
+	Client() *slack.Client
+	Retry(ctx context.Context, op string, fn func() error) error
+}
+
+// FeatureFileConfig sets the announcements for one feature. Templates can use
+// {{.Feature}} and {{.Version}}; an empty template turns that announcement off.
+type FeatureFileConfig struct {
+	Startup  *string `json:"startup" yaml:"startup"`
+	Shutdown *string `json:"shutdown" yaml:"shutdown"`
+	// Channel overrides the default channel for this feature
+	Channel *string `json:"channel" yaml:"channel"`
+}
+
+type FileConfig struct {
+	Enabled *bool          `json:"enabled" yaml:"enabled"`
+	Channel *string        `json:"channel" yaml:"channel"`
+	Persona persona.Config `json:"persona" yaml:"persona"`
+	// Features are the features announced, keyed by the names on the dashboard, e.g.
+	// vibecheck or userwatch
+	Features map[string]FeatureFileConfig `json:"features" yaml:"features"`
+	// Broadcasts are announcements posted to several channels that people acknowledge
+	Broadcasts BroadcastsFileConfig `json:"broadcasts" yaml:"broadcasts"`
+}
+
+// Feature is the resolved announcement for one feature
+type Feature struct {
+	Startup  string
+	Shutdown string
+	Channel  string
+}
+
+type Config struct {
+	Enabled    bool
+	Persona    persona.Config
+	Features   map[string]Feature
+	Broadcasts BroadcastsConfig
+}
+
+// NewFeatures resolves feature announcements, falling back to the default channel and
+// templates
+func NewFeatures(channel string, features map[string]FeatureFileConfig) map[string]Feature {
+	resolved := make(map[string]Feature, len(features))
+	for name, f := range features {
+		feature := Feature{Startup: DefaultStartup, Shutdown: DefaultShutdown, Channel: channel}
+		if f.Startup != nil {
+			feature.Startup = *f.Startup
+		}
+		if f.Shutdown != nil {
+			feature.Shutdown = *f.Shutdown
+		}
+		if f.Channel != nil {
+			feature.Channel = *f.Channel
+		}
+		resolved[name] = feature
+	}
+	return resolved
+}
+
+// Check reports whether an announcement template parses and renders
+func Check(text string) error {
+	_, err := render(text, data{Feature: "vibecheck", Version: "dev"})
+	return err
+}
+
+type data struct {
+	Feature string
+	Version string
+}
+
+// Announcer posts startup and shutdown announcements for running features
+type Announcer struct {
+	log     *zap.Logger
+	config  Config
+	slack   slackService
+	version string
+}
+
+func New(log *zap.Logger, c Config, s slackService, version string) *Announcer {
+	return &Announcer{log: log, config: c, slack: s, version: version}
+}
+
+// Startup announces that the running features are online
+func (a *Announcer) Startup(ctx context.Context, running []string) {
+	a.announce(ctx, running, func(f Feature) string { return f.Startup })
+}
+
+// Shutdown announces that the running features are going offline
+func (a *Announcer) Shutdown(ctx context.Context, running []string) {
+	a.announce(ctx, running, func(f Feature) string { return f.Shutdown })
+}
+
+// announce posts one message per channel with a line for each running feature that has
+// an announcement
+func (a *Announcer) announce(ctx context.Context, running []string, text func(Feature) string) {
+	if !a.config.Enabled {
+		return
+	}
+	lines := a.render(running, text)
+	channels := make([]string, 0, len(lines))
+	for channel := range lines {
+		channels = append(channels, channel)
+	}
+	slices.Sort(channels)
+
+	for _, channel := range channels {
+		message := strings.Join(lines[channel], "\n")
+		err := a.slack.Retry(ctx, "chat.postMessage", func() error {
+			_, _, err := a.slack.Client().PostMessageContext(ctx, channel,
+				slack.MsgOptionText(message, false),
+				a.config.Persona.MsgOption(),
+			)
+			return err
+		})
+		if err != nil {
+			a.log.Error("Failed to post announcement", zap.String("channel", channel), zap.Error(err))
+		}
+	}
+}
+
+// render groups the announcement lines of running features by channel
+func (a *Announcer) render(running []string, text func(Feature) string) map[string][]string {
+	lines := make(map[string][]string)
+	for _, name := range running {
+		feature, ok := a.config.Features[name]
+		if !ok || feature.Channel == "" || text(feature) == "" {
+			continue
+		}
+		line, err := render(text(feature), data{Feature: name, Version: a.version})
+		if err != nil {
+			a.log.Error("Failed to render announcement", zap.String("feature", name), zap.Error(err))
+			continue
+		}
+		if line != "" {
+			lines[feature.Channel] = append(lines[feature.Channel], line)
+		}
+	}
+	return lines
+}
+
+func render(text string, d data) (string, error) {
+	tmpl, err := template.New("announcement").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse announcement template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("execute announcement template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
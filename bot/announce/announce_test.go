@@ -0,0 +1,188 @@
+package announce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestAnnouncer(t *testing.T) {
+	posts := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts[r.FormValue("channel")] = r.FormValue("text")
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	quiet := ""
+	features := NewFeatures("C1", map[string]FeatureFileConfig{
+		"chat":      {},
+		"vibecheck": {Shutdown: &quiet},
+		"userwatch": {},
+	})
+	a := New(zap.NewNop(), Config{Enabled: true, Features: features},
+		slacktest.New(server.URL), "1.2.3")
+
+	a.Startup(context.Background(), []string{"chat", "membership", "vibecheck"})
+	want := ":large_green_circle: chat is back online\n:large_green_circle: vibecheck is back online"
+	if len(posts) != 1 || posts["C1"] != want {
+		t.Errorf("startup posts = %v, want one message with running announced features", posts)
+	}
+
+	clear(posts)
+	a.Shutdown(context.Background(), []string{"chat", "vibecheck"})
+	if want := ":red_circle: chat is going offline"; posts["C1"] != want {
+		t.Errorf("shutdown post = %q, want %q", posts["C1"], want)
+	}
+
+	clear(posts)
+	New(zap.NewNop(), Config{Features: features}, &slacktest.Slack{}, "").Startup(context.Background(), []string{"chat"})
+	if len(posts) != 0 {
+		t.Errorf("posts = %v, want nothing when disabled", posts)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, text := range []string{DefaultStartup, DefaultShutdown, "{{.Feature}} {{.Version}}", ""} {
+		if err := Check(text); err != nil {
+			t.Errorf("Check(%q) error = %v", text, err)
+		}
+	}
+	for _, text := range []string{"{{.Feature", "{{.Uptime}}"} {
+		if err := Check(text); err == nil {
+			t.Errorf("Check(%q) = nil, want an error", text)
+		}
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	var mu sync.Mutex
+	var posts, reactions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		channel := r.FormValue("channel")
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			posts = append(posts, channel+" "+r.FormValue("thread_ts")+" "+r.FormValue("text"))
+			if channel == "CGONE" {
+				_, _ = w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"ok": true, "channel": %q, "ts": "1.%d"}`, channel, len(posts))
+		case "/reactions.add":
+			reactions = append(reactions, channel+" "+r.FormValue("name"))
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		case "/reactions.get":
+			// U3 reacted while the bot was down
+			_, _ = w.Write([]byte(`{"ok": true, "type": "message", "message": {"reactions": [{"name": "white_check_mark", "count": 2, "users": ["UBOT", "U3"]}]}}`))
+		case "/conversations.members":
+			_, _ = w.Write([]byte(`{"ok": true, "members": ["UBOT", "U1", "U2", "U3", "U4"], "response_metadata": {"next_cursor": ""}}`))
+		default:
+			t.Errorf("unexpected call %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Unix(1700000000, 0)
+	s := slacktest.New(server.URL)
+	b := NewBroadcaster(zap.NewNop(), BroadcastsConfig{DataDir: dir}, s)
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	a, err := b.Send(ctx, "holiday-hours", "We close early Friday", []string{"C1", "CGONE", "C2"}, time.Hour)
+	if err == nil {
+		t.Error("Send() error = nil, want the failed channel reported")
+	}
+	if len(a.Posts) != 2 || !a.Deadline.Equal(now.Add(time.Hour)) {
+		t.Errorf("Send() = %+v, want two posts due in an hour", a)
+	}
+	if !strings.HasSuffix(posts[0], "We close early Friday\n\nReact with :white_check_mark: to acknowledge by <!date^1700003600^{date_short_pretty} at {time}|2023-11-14 23:13 UTC>.") {
+		t.Errorf("post = %q, want the acknowledgment prompt", posts[0])
+	}
+	if !slices.Equal(reactions, []string{"C1 white_check_mark", "C2 white_check_mark"}) {
+		t.Errorf("reactions = %q, want the prompt added to each post", reactions)
+	}
+	if _, err := b.Send(ctx, "holiday-hours", "Again", []string{"C1"}, 0); err == nil {
+		t.Error("Send() with a posted ID error = nil")
+	}
+
+	react := func(data any) {
+		b.processEvent(ctx, event.Callback(data))
+	}
+	item := func(channel, ts string) slackevents.Item {
+		return slackevents.Item{Type: "message", Channel: channel, Timestamp: ts}
+	}
+	react(&slackevents.ReactionAddedEvent{User: "U1", Reaction: "white_check_mark", Item: item("C1", a.Posts[0].TS)})
+	react(&slackevents.ReactionAddedEvent{User: "U2", Reaction: "white_check_mark::skin-tone-3", Item: item("C2", a.Posts[1].TS)})
+	react(&slackevents.ReactionAddedEvent{User: "U4", Reaction: "eyes", Item: item("C1", a.Posts[0].TS)})
+	react(&slackevents.ReactionAddedEvent{User: "U4", Reaction: "white_check_mark", Item: item("C1", "9.9")})
+	react(&slackevents.ReactionAddedEvent{User: "UBOT", Reaction: "white_check_mark", Item: item("C1", a.Posts[0].TS)})
+	if got := b.Announcements()[0].Acks; len(got) != 2 {
+		t.Errorf("acks = %v, want U1 and U2", got)
+	}
+
+	// The deadline hasn't passed
+	posts = nil
+	b.reportDue(ctx)
+	if len(posts) != 0 {
+		t.Errorf("posts = %q before the deadline", posts)
+	}
+
+	now = now.Add(time.Hour)
+	b.reportDue(ctx)
+	got := b.Announcements()[0]
+	if !slices.Equal(got.Outstanding, []string{"U4"}) || got.ReportedAt.IsZero() {
+		t.Errorf("after the deadline = %+v, want U4 outstanding after reconciling U3", got)
+	}
+	want := "📋 3 of 4 acknowledged announcement holiday-hours. Still waiting on <@U4>."
+	if len(posts) != 2 || posts[0] != "C1 1.1 "+want || posts[1] != "C2 1.3 "+want {
+		t.Errorf("reports = %q, want one in each post's thread", posts)
+	}
+	posts = nil
+	b.reportDue(ctx)
+	if len(posts) != 0 {
+		t.Errorf("posts = %q, want the announcement reported once", posts)
+	}
+
+	// The announcement survives a restart
+	restarted := NewBroadcaster(zap.NewNop(), BroadcastsConfig{DataDir: dir}, s)
+	if got := restarted.Announcements(); len(got) != 1 || len(got[0].Acks) != 3 || !slices.Equal(got[0].Outstanding, []string{"U4"}) {
+		t.Errorf("Announcements() after a restart = %+v", got)
+	}
+}
+
+func TestBroadcaster_PostConfigured(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path == "/chat.postMessage" {
+			posts = append(posts, r.FormValue("channel"))
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := BroadcastsConfig{DataDir: dir, Announcements: []Broadcast{{ID: "welcome", Text: "Hi", Channels: []string{"C1", "C2"}}}}
+	s := slacktest.New(server.URL)
+	NewBroadcaster(zap.NewNop(), c, s).postConfigured(context.Background())
+	NewBroadcaster(zap.NewNop(), c, s).postConfigured(context.Background())
+	if !slices.Equal(posts, []string{"C1", "C2"}) {
+		t.Errorf("posts = %q, want the configured announcement posted once", posts)
+	}
+}
@@ -0,0 +1,548 @@
+package announce
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/emoji"
+	"slackbot.arpa/tools/timeformat"
+)
+
+const (
+	DefaultAckReaction = "white_check_mark"
+	DefaultAckDeadline = 24 * time.Hour
+
+	broadcastFile = "announcements.json"
+	// checkInterval is how often deadlines are checked for announcements to report on
+	checkInterval = time.Minute
+)
+
+type broadcastSlack interface {
+	slackService
+	BotUserID() string
+}
+
+// BroadcastFileConfig is an announcement posted once to its channels, the first time
+// the bot starts with it configured
+type BroadcastFileConfig struct {
+	// ID names the announcement, so it's only posted once and for announce status
+	ID       string   `json:"id" yaml:"id"`
+	Text     string   `json:"text" yaml:"text"`
+	Channels []string `json:"channels" yaml:"channels"`
+	// Deadline is how long people have to acknowledge, defaults to the broadcasts deadline
+	Deadline *time.Duration `json:"deadline" yaml:"deadline"`
+}
+
+type BroadcastsFileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Reaction is what people react with to acknowledge, defaults to white_check_mark
+	Reaction *string `json:"reaction" yaml:"reaction"`
+	// Deadline is how long people have to acknowledge, defaults to 24h
+	Deadline *time.Duration `json:"deadline" yaml:"deadline"`
+	// ReportChannel is where outstanding users are reported after the deadline, defaults
+	// to a thread on each post
+	ReportChannel *string               `json:"report_channel" yaml:"report_channel"`
+	Announcements []BroadcastFileConfig `json:"announcements" yaml:"announcements"`
+}
+
+// Broadcast is a resolved announcement from config
+type Broadcast struct {
+	ID       string
+	Text     string
+	Channels []string
+	Deadline time.Duration
+}
+
+type BroadcastsConfig struct {
+	Enabled       bool
+	DataDir       string
+	Reaction      string
+	Deadline      time.Duration
+	ReportChannel string
+	Persona       persona.Config
+	Announcements []Broadcast
+}
+
+// Post is one copy of an announcement
+type Post struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// Announcement is a broadcast and who has acknowledged it
+type Announcement struct {
+	ID       string               `json:"id"`
+	Text     string               `json:"text"`
+	Posts    []Post               `json:"posts"`
+	PostedAt time.Time            `json:"posted_at"`
+	Deadline time.Time            `json:"deadline"`
+	Acks     map[string]time.Time `json:"acks"` // user -> when they reacted
+	// Outstanding are the channel members who hadn't acknowledged at the deadline
+	Outstanding []string  `json:"outstanding,omitempty"`
+	ReportedAt  time.Time `json:"reported_at,omitzero"`
+}
+
+// Broadcaster posts announcements to several channels and tracks who acknowledges them
+// with a reaction, reporting the members who haven't after the deadline
+type Broadcaster struct {
+	log           *zap.Logger
+	config        BroadcastsConfig
+	slack         broadcastSlack
+	path          string
+	announcements map[string]*Announcement
+	mu            sync.Mutex
+	now           func() time.Time
+	isConnected   atomic.Bool
+	stopCh        chan struct{}
+	eventsCh      chan event.Event
+	status        *status.Tracker
+}
+
+func NewBroadcaster(log *zap.Logger, c BroadcastsConfig, s broadcastSlack) *Broadcaster {
+	if c.Reaction == "" {
+		c.Reaction = DefaultAckReaction
+	}
+	if c.Deadline <= 0 {
+		c.Deadline = DefaultAckDeadline
+	}
+	b := &Broadcaster{
+		log:           log,
+		config:        c,
+		slack:         s,
+		path:          filepath.Join(c.DataDir, broadcastFile),
+		announcements: make(map[string]*Announcement),
+		now:           time.Now,
+		stopCh:        make(chan struct{}),
+		eventsCh:      make(chan event.Event, 100),
+	}
+	b.load()
+	return b
+}
+
+// SetStatusTracker sets where the broadcaster reports its activity
+func (b *Broadcaster) SetStatusTracker(t *status.Tracker) {
+	b.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (b *Broadcaster) ProcessorType() string {
+	return "broadcast"
+}
+
+func (b *Broadcaster) Start(ctx context.Context) error {
+	b.isConnected.Store(true)
+	go b.run(ctx)
+	return nil
+}
+
+func (b *Broadcaster) Stop(ctx context.Context) error {
+	if !b.isConnected.Load() {
+		return nil
+	}
+	close(b.stopCh)
+	b.isConnected.Store(false)
+	return nil
+}
+
+// PushEvent adds an event to be processed by the broadcaster
+func (b *Broadcaster) PushEvent(e event.Event) {
+	if !b.isConnected.Load() {
+		return
+	}
+
+	select {
+	case b.eventsCh <- e:
+	default:
+		b.log.Warn("Broadcast events channel full, dropping event.")
+		b.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (b *Broadcaster) run(ctx context.Context) {
+	b.postConfigured(ctx)
+	b.reportDue(ctx)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.reportDue(ctx)
+		case e := <-b.eventsCh:
+			b.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+// postConfigured posts the configured announcements that haven't been posted yet
+func (b *Broadcaster) postConfigured(ctx context.Context) {
+	for _, a := range b.config.Announcements {
+		b.mu.Lock()
+		_, posted := b.announcements[a.ID]
+		b.mu.Unlock()
+		if posted {
+			continue
+		}
+		if _, err := b.Send(ctx, a.ID, a.Text, a.Channels, a.Deadline); err != nil {
+			b.log.Error("Failed to post announcement", zap.String("id", a.ID), zap.Error(err))
+		}
+	}
+}
+
+// Send posts an announcement to each channel with the acknowledgment reaction already
+// added as a prompt. A zero deadline uses the configured default.
+func (b *Broadcaster) Send(ctx context.Context, id, text string, channels []string, deadline time.Duration) (Announcement, error) {
+	id, text = strings.TrimSpace(id), strings.TrimSpace(text)
+	switch {
+	case id == "":
+		return Announcement{}, errors.New("announcement ID is required")
+	case text == "":
+		return Announcement{}, errors.New("announcement text is required")
+	case len(channels) == 0:
+		return Announcement{}, errors.New("at least one channel is required")
+	}
+	if deadline <= 0 {
+		deadline = b.config.Deadline
+	}
+	b.mu.Lock()
+	if _, ok := b.announcements[id]; ok {
+		b.mu.Unlock()
+		return Announcement{}, fmt.Errorf("announcement %s was already posted", id)
+	}
+	now := b.now()
+	a := &Announcement{ID: id, Text: text, PostedAt: now, Deadline: now.Add(deadline), Acks: make(map[string]time.Time)}
+	b.announcements[id] = a
+	b.mu.Unlock()
+
+	message := fmt.Sprintf("%s\n\nReact with %s to acknowledge by %s.", text, emoji.Code(b.config.Reaction),
+		timeformat.Slack(a.Deadline, timeformat.DateTime))
+	var failures []error
+	for _, channel := range channels {
+		var ts string
+		err := b.slack.Retry(ctx, "chat.postMessage", func() error {
+			var err error
+			_, ts, err = b.slack.Client().PostMessageContext(ctx, channel,
+				slack.MsgOptionText(message, false),
+				b.config.Persona.MsgOption(),
+			)
+			return err
+		})
+		if err != nil {
+			err = errs.NewSlackAPIError("chat.postMessage", err)
+			b.status.PostFailed(channel, err)
+			failures = append(failures, fmt.Errorf("post to %s: %w", channel, err))
+			continue
+		}
+		b.status.Posted()
+		b.mu.Lock()
+		a.Posts = append(a.Posts, Post{Channel: channel, TS: ts})
+		b.mu.Unlock()
+		if err := b.slack.Client().AddReactionContext(ctx, emoji.Name(b.config.Reaction), slack.NewRefToMessage(channel, ts)); err != nil {
+			b.log.Warn("Failed to add acknowledgment prompt", zap.String("channel", channel), zap.Error(errs.NewSlackAPIError("reactions.add", err)))
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(a.Posts) == 0 {
+		delete(b.announcements, id)
+		return Announcement{}, errors.Join(failures...)
+	}
+	b.save()
+	b.log.Info("Announcement posted",
+		zap.String("id", id),
+		zap.Int("channels", len(a.Posts)),
+		zap.Time("deadline", a.Deadline))
+	return a.snapshot(), errors.Join(failures...)
+}
+
+// Announcements returns the posted announcements, oldest first
+func (b *Broadcaster) Announcements() []Announcement {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	announcements := make([]Announcement, 0, len(b.announcements))
+	for _, a := range b.announcements {
+		announcements = append(announcements, a.snapshot())
+	}
+	slices.SortFunc(announcements, func(x, y Announcement) int {
+		return cmp.Or(x.PostedAt.Compare(y.PostedAt), strings.Compare(x.ID, y.ID))
+	})
+	return announcements
+}
+
+func (a *Announcement) snapshot() Announcement {
+	c := *a
+	c.Posts = slices.Clone(a.Posts)
+	c.Acks = maps.Clone(a.Acks)
+	c.Outstanding = slices.Clone(a.Outstanding)
+	return c
+}
+
+// processEvent records acknowledgment reactions on the posts of announcements still
+// waiting for their deadline, and forgets them when the reaction is removed
+func (b *Broadcaster) processEvent(ctx context.Context, e event.Event) {
+	var reaction string
+	switch ev := e.Data().(type) {
+	case *slackevents.ReactionAddedEvent:
+		reaction = ev.Reaction
+	case *slackevents.ReactionRemovedEvent:
+		reaction = ev.Reaction
+	default:
+		return
+	}
+	if emoji.Base(reaction) != emoji.Name(b.config.Reaction) || e.User == "" || e.User == b.slack.BotUserID() {
+		return
+	}
+	_, added := e.Data().(*slackevents.ReactionAddedEvent)
+
+	b.mu.Lock()
+	a := b.posted(e.Channel, e.TS)
+	if a == nil || !a.ReportedAt.IsZero() {
+		b.mu.Unlock()
+		return
+	}
+	_, acked := a.Acks[e.User]
+	posts := slices.Clone(a.Posts)
+	b.mu.Unlock()
+	if added == acked {
+		return
+	}
+	// A reaction left on another copy of the announcement still counts
+	if !added && b.reactedElsewhere(ctx, posts, e) {
+		return
+	}
+
+	b.status.Event()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if added {
+		a.Acks[e.User] = b.now()
+	} else {
+		delete(a.Acks, e.User)
+	}
+	b.save()
+}
+
+// reactedElsewhere reports whether the user's reaction is still on another of the posts
+func (b *Broadcaster) reactedElsewhere(ctx context.Context, posts []Post, e event.Event) bool {
+	for _, p := range posts {
+		if p.Channel == e.Channel && p.TS == e.TS {
+			continue
+		}
+		users, err := b.reacted(ctx, p)
+		if err == nil && slices.Contains(users, e.User) {
+			return true
+		}
+	}
+	return false
+}
+
+// posted finds the announcement with a post at ts; callers must hold mu
+func (b *Broadcaster) posted(channelID, ts string) *Announcement {
+	for _, a := range b.announcements {
+		if slices.Contains(a.Posts, Post{Channel: channelID, TS: ts}) {
+			return a
+		}
+	}
+	return nil
+}
+
+// reacted lists the users who reacted to a post with the acknowledgment reaction
+func (b *Broadcaster) reacted(ctx context.Context, p Post) ([]string, error) {
+	item, err := b.slack.Client().GetReactionsContext(ctx, slack.NewRefToMessage(p.Channel, p.TS), slack.GetReactionsParameters{Full: true})
+	if err != nil {
+		return nil, errs.NewSlackAPIError("reactions.get", err)
+	}
+	var users []string
+	for _, r := range item.Reactions {
+		if emoji.Base(r.Name) == emoji.Name(b.config.Reaction) {
+			users = append(users, r.Users...)
+		}
+	}
+	return users, nil
+}
+
+// reportDue reports on the announcements whose deadline passed
+func (b *Broadcaster) reportDue(ctx context.Context) {
+	b.mu.Lock()
+	var due []*Announcement
+	for _, a := range b.announcements {
+		if a.ReportedAt.IsZero() && !b.now().Before(a.Deadline) {
+			due = append(due, a)
+		}
+	}
+	b.mu.Unlock()
+	slices.SortFunc(due, func(x, y *Announcement) int { return strings.Compare(x.ID, y.ID) })
+	for _, a := range due {
+		if err := b.report(ctx, a); err != nil {
+			b.log.Error("Failed to report on announcement", zap.String("id", a.ID), zap.Error(err))
+		}
+	}
+}
+
+// report reconciles the acknowledgments with the posts' reactions, in case events were
+// missed while the bot was down, and posts who in the channels hasn't acknowledged
+func (b *Broadcaster) report(ctx context.Context, a *Announcement) error {
+	b.mu.Lock()
+	posts := slices.Clone(a.Posts)
+	b.mu.Unlock()
+
+	botUserID := b.slack.BotUserID()
+	members := make(map[string]struct{})
+	reacted := make(map[string]struct{})
+	for _, p := range posts {
+		users, err := b.reacted(ctx, p)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			reacted[u] = struct{}{}
+		}
+		channelMembers, err := b.members(ctx, p.Channel)
+		if err != nil {
+			return err
+		}
+		for _, u := range channelMembers {
+			members[u] = struct{}{}
+		}
+	}
+	delete(members, botUserID)
+	delete(reacted, botUserID)
+
+	b.mu.Lock()
+	now := b.now()
+	for u := range reacted {
+		if _, ok := a.Acks[u]; !ok {
+			a.Acks[u] = now
+		}
+	}
+	var outstanding []string
+	for _, u := range slices.Sorted(maps.Keys(members)) {
+		if _, ok := a.Acks[u]; !ok {
+			outstanding = append(outstanding, u)
+		}
+	}
+	a.Outstanding, a.ReportedAt = outstanding, now
+	acked := len(members) - len(outstanding)
+	b.save()
+	b.mu.Unlock()
+
+	text := fmt.Sprintf("📋 %d of %d acknowledged announcement %s.", acked, len(members), a.ID)
+	if len(outstanding) == 0 {
+		text += " Everyone's seen it."
+	} else {
+		mentions := make([]string, len(outstanding))
+		for i, u := range outstanding {
+			mentions[i] = "<@" + u + ">"
+		}
+		text += " Still waiting on " + strings.Join(mentions, ", ") + "."
+	}
+	b.log.Info("Announcement deadline passed",
+		zap.String("id", a.ID),
+		zap.Int("acknowledged", acked),
+		zap.Int("outstanding", len(outstanding)))
+
+	if b.config.ReportChannel != "" {
+		return b.post(ctx, b.config.ReportChannel, "", text)
+	}
+	var failures []error
+	for _, p := range posts {
+		failures = append(failures, b.post(ctx, p.Channel, p.TS, text))
+	}
+	return errors.Join(failures...)
+}
+
+func (b *Broadcaster) members(ctx context.Context, channelID string) ([]string, error) {
+	var members []string
+	params := &slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: 200}
+	for {
+		page, cursor, err := b.slack.Client().GetUsersInConversationContext(ctx, params)
+		if err != nil {
+			return nil, conversation.Error("conversations.members", conversation.KindFromID(channelID), err)
+		}
+		members = append(members, page...)
+		if cursor == "" {
+			return members, nil
+		}
+		params.Cursor = cursor
+	}
+}
+
+func (b *Broadcaster) post(ctx context.Context, channelID, threadTS, text string) error {
+	_, _, err := b.slack.Client().PostMessageContext(ctx, channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(threadTS),
+		b.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		b.status.PostFailed(channelID, err)
+		return err
+	}
+	b.status.Posted()
+	return nil
+}
+
+func (b *Broadcaster) load() {
+	data, err := os.ReadFile(b.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		b.log.Error("Failed to read announcements", zap.Error(err), zap.String("path", b.path))
+		return
+	}
+	var announcements []*Announcement
+	if err := json.Unmarshal(data, &announcements); err != nil {
+		b.log.Error("Failed to unmarshal announcements", zap.Error(err), zap.String("path", b.path))
+		return
+	}
+	for _, a := range announcements {
+		if a.Acks == nil {
+			a.Acks = make(map[string]time.Time)
+		}
+		b.announcements[a.ID] = a
+	}
+}
+
+// save writes the announcements; callers must hold mu
+func (b *Broadcaster) save() {
+	announcements := slices.SortedFunc(maps.Values(b.announcements), func(x, y *Announcement) int {
+		return strings.Compare(x.ID, y.ID)
+	})
+	data, err := json.Marshal(announcements)
+	if err != nil {
+		b.log.Error("Failed to marshal announcements", zap.Error(err))
+		return
+	}
+	tempFile := b.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		b.log.Error("Failed to save announcements", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, b.path); err != nil {
+		b.log.Error("Failed to save announcements", zap.Error(&errs.StorageError{Op: "rename", Path: b.path, Err: err}))
+	}
+}
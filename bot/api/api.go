@@ -0,0 +1,311 @@
+// Package api serves the bot's admin operations over gRPC for integrations and a web UI:
+// feature status, runtime config overrides, sending messages, vibecheck bans and purging
+// a user's stored aichat context. The service is defined in apipb/api.proto and served
+// with TLS on its own port alongside the HTTP server. Every call needs the configured token.
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative apipb/api.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"slackbot.arpa/bot/api/apipb"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/vibecheck"
+)
+
+const DefaultPort = 4201
+
+type FileConfig struct {
+	Enabled *bool   `json:"enabled" yaml:"enabled"`
+	Port    *uint32 `json:"port" yaml:"port"`
+	// Token is the bearer token every call must send as authorization metadata
+	Token string `json:"token" yaml:"token"`
+	// TLSCert and TLSKey are PEM files the API is served with
+	TLSCert string `json:"tls_cert" yaml:"tls_cert"`
+	TLSKey  string `json:"tls_key" yaml:"tls_key"`
+}
+
+type Config struct {
+	Enabled bool
+	Port    uint32
+	Token   string
+	TLSCert string
+	TLSKey  string
+}
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type statusRegistry interface {
+	Snapshot() []status.FeatureStatus
+}
+
+type configOverrides interface {
+	Overrides() map[string]string
+	SetOverride(key, value string) error
+	ClearOverride(key string) error
+}
+
+type banList interface {
+	Bans() []vibecheck.Ban
+}
+
+type userPurger interface {
+	PurgeUser(userID string) (int64, error)
+}
+
+// Server implements the Admin gRPC service
+type Server struct {
+	apipb.UnimplementedAdminServer
+
+	log       *zap.Logger
+	config    Config
+	slack     slackService
+	status    statusRegistry
+	overrides configOverrides
+	bans      banList
+	purger    userPurger
+	mu        sync.Mutex // Guards the server, bans and purger
+	server    *grpc.Server
+}
+
+func New(log *zap.Logger, config Config, s slackService, registry statusRegistry, overrides configOverrides) *Server {
+	return &Server{
+		log:       log,
+		config:    config,
+		slack:     s,
+		status:    registry,
+		overrides: overrides,
+	}
+}
+
+// SetBans lists vibecheck's bans; without it ListBans fails. It's set again when
+// vibecheck restarts, and to nil when it stops.
+func (s *Server) SetBans(b banList) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bans = b
+}
+
+// SetPurger purges users' aichat context; without it PurgeUser fails. It's set again
+// when aichat restarts, and to nil when it stops.
+func (s *Server) SetPurger(p userPurger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purger = p
+}
+
+// Start listens on the configured port
+func (s *Server) Start(ctx context.Context) error {
+	port := s.config.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("listen for grpc api: %w", err)
+	}
+	if err := s.serve(listener); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	return nil
+}
+
+// serve serves the API on the listener with the configured TLS certificate
+func (s *Server) serve(listener net.Listener) error {
+	creds, err := credentials.NewServerTLSFromFile(s.config.TLSCert, s.config.TLSKey)
+	if err != nil {
+		return &errs.ConfigError{Key: "grpc.tls_cert", Err: err}
+	}
+	server := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(s.authorize))
+	apipb.RegisterAdminServer(server, s)
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.log.Error("gRPC API stopped", zap.Error(err))
+		}
+	}()
+	s.log.Info("Serving gRPC API", zap.String("addr", listener.Addr().String()))
+	return nil
+}
+
+// Stop waits for running calls until the context is done, then cancels them
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	server := s.server
+	s.server = nil
+	s.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		return ctx.Err()
+	}
+}
+
+// authorize rejects calls without the configured bearer token
+func (s *Server) authorize(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	if s.config.Token == "" {
+		s.log.Warn("Refused gRPC call, no token configured", zap.String("method", info.FullMethod), zap.String("remoteAddr", addr))
+		return nil, grpcstatus.Error(codes.PermissionDenied, "authentication is not configured")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(v, "Bearer "); ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) == 1 {
+			s.log.Info("Running gRPC call", zap.String("method", info.FullMethod), zap.String("remoteAddr", addr))
+			return handler(ctx, req)
+		}
+	}
+	s.log.Warn("Unauthorized gRPC call", zap.String("method", info.FullMethod), zap.String("remoteAddr", addr))
+	return nil, grpcstatus.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func (s *Server) GetStatus(ctx context.Context, req *apipb.GetStatusRequest) (*apipb.GetStatusResponse, error) {
+	resp := &apipb.GetStatusResponse{}
+	if s.status == nil {
+		return resp, nil
+	}
+	for _, f := range s.status.Snapshot() {
+		feature := &apipb.FeatureStatus{
+			Name:      f.Name,
+			LastEvent: timestamp(f.LastEvent),
+			LastPost:  timestamp(f.LastPost),
+			LastError: timestamp(f.LastError),
+			Error:     f.Error,
+		}
+		for _, n := range f.Activity {
+			feature.Activity = append(feature.Activity, int32(n)) // #nosec G115 -- events per minute
+		}
+		resp.Features = append(resp.Features, feature)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetConfigOverrides(ctx context.Context, req *apipb.GetConfigOverridesRequest) (*apipb.GetConfigOverridesResponse, error) {
+	return &apipb.GetConfigOverridesResponse{Overrides: s.overrides.Overrides()}, nil
+}
+
+func (s *Server) SetConfigOverride(ctx context.Context, req *apipb.SetConfigOverrideRequest) (*apipb.SetConfigOverrideResponse, error) {
+	if err := s.overrides.SetOverride(req.GetKey(), req.GetValue()); err != nil {
+		return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+	return &apipb.SetConfigOverrideResponse{}, nil
+}
+
+func (s *Server) ClearConfigOverride(ctx context.Context, req *apipb.ClearConfigOverrideRequest) (*apipb.ClearConfigOverrideResponse, error) {
+	if err := s.overrides.ClearOverride(req.GetKey()); err != nil {
+		return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+	return &apipb.ClearConfigOverrideResponse{}, nil
+}
+
+// SendMessage posts to each channel, reporting failures per channel like send-message
+func (s *Server) SendMessage(ctx context.Context, req *apipb.SendMessageRequest) (*apipb.SendMessageResponse, error) {
+	if strings.TrimSpace(req.GetText()) == "" {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "text is required")
+	}
+	if len(req.GetChannels()) == 0 {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "channels are required")
+	}
+
+	opts := []slack.MsgOption{slack.MsgOptionText(req.GetText(), false)}
+	if req.GetThreadTs() != "" {
+		opts = append(opts, slack.MsgOptionTS(req.GetThreadTs()))
+	}
+	resp := &apipb.SendMessageResponse{}
+	for _, channel := range req.GetChannels() {
+		result := &apipb.SendResult{Channel: channel}
+		_, ts, err := s.slack.Client().PostMessageContext(ctx, channel, opts...)
+		if err != nil {
+			s.log.Error("Failed to send message to channel", zap.String("channel", channel), zap.Error(err))
+			result.Error = err.Error()
+		}
+		result.Ts = ts
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+func (s *Server) ListBans(ctx context.Context, req *apipb.ListBansRequest) (*apipb.ListBansResponse, error) {
+	s.mu.Lock()
+	bans := s.bans
+	s.mu.Unlock()
+	if bans == nil {
+		return nil, grpcstatus.Error(codes.FailedPrecondition, "vibecheck is not enabled")
+	}
+	resp := &apipb.ListBansResponse{}
+	for _, b := range bans.Bans() {
+		resp.Bans = append(resp.Bans, &apipb.Ban{
+			UserId:     b.UserID,
+			ChannelId:  b.ChannelID,
+			Punishment: b.Punishment,
+			KickedAt:   timestamppb.New(b.KickedAt),
+			ReinviteAt: timestamppb.New(b.ReinviteAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) PurgeUser(ctx context.Context, req *apipb.PurgeUserRequest) (*apipb.PurgeUserResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "user_id is required")
+	}
+	s.mu.Lock()
+	purger := s.purger
+	s.mu.Unlock()
+	if purger == nil {
+		return nil, grpcstatus.Error(codes.FailedPrecondition, "aichat is not enabled")
+	}
+	deleted, err := purger.PurgeUser(req.GetUserId())
+	if err != nil {
+		s.log.Error("Failed to purge stored context", zap.String("user", req.GetUserId()), zap.Error(err))
+		return nil, grpcstatus.Error(codes.Internal, "purge failed")
+	}
+	return &apipb.PurgeUserResponse{MessagesDeleted: deleted}, nil
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"maps"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+	"slackbot.arpa/bot/api/apipb"
+	"slackbot.arpa/bot/slacktest"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/vibecheck"
+)
+
+type mockOverrides map[string]string
+
+func (m mockOverrides) Overrides() map[string]string { return maps.Clone(m) }
+
+func (m mockOverrides) SetOverride(key, value string) error {
+	if key == "unknown" {
+		return errors.New("unknown key")
+	}
+	m[key] = value
+	return nil
+}
+
+func (m mockOverrides) ClearOverride(key string) error {
+	delete(m, key)
+	return nil
+}
+
+type mockBans []vibecheck.Ban
+
+func (m mockBans) Bans() []vibecheck.Ban { return m }
+
+type mockPurger map[string]int64
+
+func (m mockPurger) PurgeUser(userID string) (int64, error) { return m[userID], nil }
+
+// writeCert writes a self-signed certificate for localhost and returns its paths and a pool
+// trusting it
+func writeCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestServer(t *testing.T) {
+	var posted []string
+	slackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posted = append(posted, r.FormValue("channel")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+	}))
+	defer slackServer.Close()
+
+	certFile, keyFile, pool := writeCert(t)
+	registry := status.NewRegistry()
+	registry.Feature("chat").Event()
+	overrides := mockOverrides{}
+	s := New(zap.NewNop(), Config{Token: "secret", TLSCert: certFile, TLSKey: keyFile},
+		slacktest.New(slackServer.URL), registry, overrides)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.serve(listener); err != nil {
+		t.Fatalf("serve() error = %v", err)
+	}
+	defer func() { _ = s.Stop(context.Background()) }()
+
+	conn, err := grpc.NewClient("localhost:"+portOf(listener),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := apipb.NewAdminClient(conn)
+
+	ctx := context.Background()
+	if _, err := client.GetStatus(ctx, &apipb.GetStatusRequest{}); grpcstatus.Code(err) != codes.Unauthenticated {
+		t.Fatalf("GetStatus() without a token error = %v, want Unauthenticated", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+	if _, err := client.GetStatus(wrong, &apipb.GetStatusRequest{}); grpcstatus.Code(err) != codes.Unauthenticated {
+		t.Fatalf("GetStatus() with the wrong token error = %v, want Unauthenticated", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+
+	statusResp, err := client.GetStatus(ctx, &apipb.GetStatusRequest{})
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if len(statusResp.GetFeatures()) != 1 || statusResp.GetFeatures()[0].GetName() != "chat" || statusResp.GetFeatures()[0].GetLastEvent() == nil {
+		t.Errorf("GetStatus() = %v, want chat's last event", statusResp)
+	}
+
+	if _, err := client.SetConfigOverride(ctx, &apipb.SetConfigOverrideRequest{Key: "chat.enabled", Value: "false"}); err != nil {
+		t.Fatalf("SetConfigOverride() error = %v", err)
+	}
+	if _, err := client.SetConfigOverride(ctx, &apipb.SetConfigOverrideRequest{Key: "unknown", Value: "1"}); grpcstatus.Code(err) != codes.InvalidArgument {
+		t.Errorf("SetConfigOverride() with an unknown key error = %v, want InvalidArgument", err)
+	}
+	overridesResp, err := client.GetConfigOverrides(ctx, &apipb.GetConfigOverridesRequest{})
+	if err != nil || !maps.Equal(overridesResp.GetOverrides(), map[string]string{"chat.enabled": "false"}) {
+		t.Errorf("GetConfigOverrides() = %v, %v, want the override", overridesResp, err)
+	}
+
+	sendResp, err := client.SendMessage(ctx, &apipb.SendMessageRequest{Channels: []string{"C1"}, Text: "hello"})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if len(posted) != 1 || posted[0] != "C1: hello" || sendResp.GetResults()[0].GetTs() != "1.1" {
+		t.Errorf("SendMessage() = %v, posted %v, want the message sent", sendResp, posted)
+	}
+	if _, err := client.SendMessage(ctx, &apipb.SendMessageRequest{Text: "hello"}); grpcstatus.Code(err) != codes.InvalidArgument {
+		t.Errorf("SendMessage() without channels error = %v, want InvalidArgument", err)
+	}
+
+	// Bans and purging need their features
+	if _, err := client.ListBans(ctx, &apipb.ListBansRequest{}); grpcstatus.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ListBans() without vibecheck error = %v, want FailedPrecondition", err)
+	}
+	s.SetBans(mockBans{{UserID: "U1", ChannelID: "C1", Punishment: vibecheck.PunishmentKick, ReinviteAt: time.Now().Add(time.Hour)}})
+	bansResp, err := client.ListBans(ctx, &apipb.ListBansRequest{})
+	if err != nil || len(bansResp.GetBans()) != 1 || bansResp.GetBans()[0].GetUserId() != "U1" {
+		t.Errorf("ListBans() = %v, %v, want the ban", bansResp, err)
+	}
+	s.SetPurger(mockPurger{"U1": 3})
+	purgeResp, err := client.PurgeUser(ctx, &apipb.PurgeUserRequest{UserId: "U1"})
+	if err != nil || purgeResp.GetMessagesDeleted() != 3 {
+		t.Errorf("PurgeUser() = %v, %v, want 3 messages deleted", purgeResp, err)
+	}
+}
+
+func portOf(listener net.Listener) string {
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/errs"
+)
+
+// archivedMessage is one JSONL line written before a message is deleted
+type archivedMessage struct {
+	Channel     string             `json:"channel"`
+	User        string             `json:"user,omitempty"`
+	BotID       string             `json:"bot_id,omitempty"`
+	Username    string             `json:"username,omitempty"`
+	TS          string             `json:"ts"`
+	ThreadTS    string             `json:"thread_ts,omitempty"`
+	Text        string             `json:"text"`
+	Attachments []slack.Attachment `json:"attachments,omitempty"`
+	Files       []slack.File       `json:"files,omitempty"`
+	ArchivedAt  time.Time          `json:"archived_at"`
+}
+
+// messageArchive appends messages to a JSONL file so destructive cleanup leaves a record
+type messageArchive struct {
+	path string
+	file *os.File
+	enc  *json.Encoder
+}
+
+// newMessageArchive creates archive/delete-<channel>-<time>.jsonl in the data dir
+func newMessageArchive(dataDir, channel string, now time.Time) (*messageArchive, error) {
+	dir := filepath.Join(dataDir, "archive")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, &errs.StorageError{Op: "create", Path: dir, Err: err}
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("delete-%s-%s.jsonl", channel, now.UTC().Format("20060102T150405Z")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, &errs.StorageError{Op: "open", Path: path, Err: err}
+	}
+	return &messageArchive{path: path, file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Write records a message, syncing so it's on disk before the message is deleted
+func (a *messageArchive) Write(channel string, msg slack.Message) error {
+	record := archivedMessage{
+		Channel:     channel,
+		User:        msg.User,
+		BotID:       msg.BotID,
+		Username:    msg.Username,
+		TS:          msg.Timestamp,
+		ThreadTS:    msg.ThreadTimestamp,
+		Text:        msg.Text,
+		Attachments: msg.Attachments,
+		Files:       msg.Files,
+		ArchivedAt:  time.Now(),
+	}
+	if err := a.enc.Encode(record); err != nil {
+		return &errs.StorageError{Op: "write", Path: a.path, Err: err}
+	}
+	if err := a.file.Sync(); err != nil {
+		return &errs.StorageError{Op: "sync", Path: a.path, Err: err}
+	}
+	return nil
+}
+
+func (a *messageArchive) Close() error {
+	if err := a.file.Close(); err != nil {
+		return &errs.StorageError{Op: "close", Path: a.path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+// Package backup snapshots the data directory into timestamped tar.gz archives on a
+// schedule, keeps the most recent ones, and restores the state from an archive.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	archivePrefix = "slackbot-"
+	archiveSuffix = ".tar.gz"
+	timeLayout    = "20060102T150405Z"
+	// checkInterval is how often the schedule is checked, so a snapshot missed while the
+	// bot was down is taken soon after it starts
+	checkInterval = time.Hour
+)
+
+// stateExtensions are the files written by features: JSON state, JSONL message archives
+// and SQLite databases
+var stateExtensions = []string{".json", ".jsonl", ".db"}
+
+type FileConfig struct {
+	Enabled  *bool          `json:"enabled" yaml:"enabled"`
+	Interval *time.Duration `json:"interval" yaml:"interval"`
+	Keep     *int           `json:"keep" yaml:"keep"`
+	Dir      *string        `json:"dir" yaml:"dir"`
+}
+
+type Config struct {
+	Enabled  bool
+	DataDir  string
+	Dir      string        // Where archives are written
+	Interval time.Duration // Time between snapshots, e.g. 24h for daily or 168h for weekly
+	Keep     int           // Number of archives kept, older ones are removed
+}
+
+// Archive is a snapshot on disk
+type Archive struct {
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// Backup takes scheduled snapshots of the data directory
+type Backup struct {
+	log         *zap.Logger
+	config      Config
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config) *Backup {
+	return &Backup{
+		log:    log,
+		config: c,
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// SetStatusTracker sets where snapshots and failures are reported
+func (b *Backup) SetStatusTracker(t *status.Tracker) {
+	b.status = t
+}
+
+// Start takes a snapshot whenever the newest archive is older than the interval
+func (b *Backup) Start(ctx context.Context) error {
+	b.isConnected.Store(true)
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			b.snapshotIfDue(ctx)
+			select {
+			case <-b.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	b.log.Debug("Backups scheduled",
+		zap.Duration("interval", b.config.Interval),
+		zap.Int("keep", b.config.Keep),
+		zap.String("dir", b.config.Dir))
+	return nil
+}
+
+func (b *Backup) Stop(ctx context.Context) error {
+	if !b.isConnected.Load() {
+		return nil
+	}
+	close(b.stopCh)
+	b.isConnected.Store(false)
+	return nil
+}
+
+func (b *Backup) snapshotIfDue(ctx context.Context) {
+	archives, err := List(b.config.Dir)
+	if err != nil {
+		b.status.Error(err)
+		b.log.Error("Failed to list backups", zap.Error(err))
+		return
+	}
+	if len(archives) > 0 && b.now().Sub(archives[0].CreatedAt) < b.config.Interval {
+		return
+	}
+	path, err := b.Snapshot(ctx)
+	if err != nil {
+		b.status.Error(err)
+		b.log.Error("Failed to back up data directory", zap.Error(err))
+		return
+	}
+	b.status.Event()
+	b.log.Info("Backed up data directory", zap.String("path", path))
+}
+
+// Snapshot writes an archive of the data directory's state files and removes archives
+// beyond Keep
+func (b *Backup) Snapshot(ctx context.Context) (string, error) {
+	path, err := Snapshot(ctx, b.config.DataDir, b.config.Dir, b.now())
+	if err != nil {
+		return "", err
+	}
+	b.prune()
+	return path, nil
+}
+
+// prune removes the oldest archives beyond Keep
+func (b *Backup) prune() {
+	if b.config.Keep <= 0 {
+		return
+	}
+	archives, err := List(b.config.Dir)
+	if err != nil {
+		b.log.Error("Failed to list backups", zap.Error(err))
+		return
+	}
+	if len(archives) <= b.config.Keep {
+		return
+	}
+	for _, a := range archives[b.config.Keep:] {
+		if err := os.Remove(a.Path); err != nil {
+			b.log.Error("Failed to remove old backup", zap.Error(&errs.StorageError{Op: "remove", Path: a.Path, Err: err}))
+			continue
+		}
+		b.log.Debug("Removed old backup", zap.String("path", a.Path))
+	}
+}
+
+// List returns the archives in a directory, newest first
+func List(dir string) ([]Archive, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &errs.StorageError{Op: "list", Path: dir, Err: err}
+	}
+	var archives []Archive
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+			continue
+		}
+		created, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix))
+		if err != nil {
+			continue
+		}
+		a := Archive{Path: filepath.Join(dir, name), CreatedAt: created}
+		if info, err := e.Info(); err == nil {
+			a.Size = info.Size()
+		}
+		archives = append(archives, a)
+	}
+	slices.SortFunc(archives, func(a, b Archive) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return archives, nil
+}
+
+// Snapshot writes the state files in dataDir to a timestamped archive in dir. SQLite
+// databases are copied with VACUUM INTO so a database in use is captured consistently.
+func Snapshot(ctx context.Context, dataDir, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", &errs.StorageError{Op: "create", Path: dir, Err: err}
+	}
+	path := filepath.Join(dir, archivePrefix+now.UTC().Format(timeLayout)+archiveSuffix)
+	tempFile := path + ".tmp"
+	f, err := os.OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", &errs.StorageError{Op: "create", Path: tempFile, Err: err}
+	}
+	defer func() { _ = os.Remove(tempFile) }()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = writeStateFiles(ctx, tw, dataDir, dir)
+	err = errors.Join(err, tw.Close(), gz.Close(), f.Close())
+	if err != nil {
+		return "", fmt.Errorf("write backup %s: %w", path, err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return "", &errs.StorageError{Op: "rename", Path: path, Err: err}
+	}
+	return path, nil
+}
+
+func writeStateFiles(ctx context.Context, tw *tar.Writer, dataDir, backupDir string) error {
+	backupDir, _ = filepath.Abs(backupDir)
+	return filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if abs, _ := filepath.Abs(path); abs == backupDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !slices.Contains(stateExtensions, filepath.Ext(path)) {
+			return nil
+		}
+		name, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		if filepath.Ext(path) == ".db" {
+			return writeDatabase(ctx, tw, path, name, backupDir)
+		}
+		return writeFile(tw, path, name)
+	})
+}
+
+func writeFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return &errs.StorageError{Op: "open", Path: path, Err: err}
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return &errs.StorageError{Op: "stat", Path: path, Err: err}
+	}
+	header := &tar.Header{
+		Name:    filepath.ToSlash(name),
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	// Copy only the size in the header in case the file grows while it's read
+	_, err = io.CopyN(tw, f, info.Size())
+	return err
+}
+
+// writeDatabase archives a consistent copy of a SQLite database made in the backup directory
+func writeDatabase(ctx context.Context, tw *tar.Writer, path, name, tempDir string) error {
+	copyPath := filepath.Join(tempDir, filepath.Base(path)+".tmp")
+	_ = os.Remove(copyPath)
+	defer func() { _ = os.Remove(copyPath) }()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return &errs.StorageError{Op: "open", Path: path, Err: err}
+	}
+	_, err = db.ExecContext(ctx, "VACUUM INTO ?", copyPath)
+	err = errors.Join(err, db.Close())
+	if err != nil {
+		return &errs.StorageError{Op: "copy database", Path: path, Err: err}
+	}
+	return writeFile(tw, copyPath, name)
+}
+
+// Restore extracts an archive into dataDir, replacing files with the same name, and
+// returns the restored file names. The bot must not be running.
+func Restore(archive, dataDir string) ([]string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, &errs.StorageError{Op: "open", Path: archive, Err: err}
+	}
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read backup %s: %w", archive, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	var restored []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return restored, nil
+		}
+		if err != nil {
+			return restored, fmt.Errorf("read backup %s: %w", archive, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return restored, fmt.Errorf("backup %s: %q is outside the data directory", archive, header.Name)
+		}
+		if err := restoreFile(tr, filepath.Join(dataDir, name), os.FileMode(header.Mode).Perm()); err != nil {
+			return restored, err
+		}
+		restored = append(restored, header.Name)
+	}
+}
+
+func restoreFile(r io.Reader, path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return &errs.StorageError{Op: "create", Path: filepath.Dir(path), Err: err}
+	}
+	tempFile := path + ".tmp"
+	f, err := os.OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return &errs.StorageError{Op: "create", Path: tempFile, Err: err}
+	}
+	_, err = io.Copy(f, r)
+	if err = errors.Join(err, f.Close()); err != nil {
+		_ = os.Remove(tempFile)
+		return &errs.StorageError{Op: "write", Path: tempFile, Err: err}
+	}
+	// A write-ahead log left from the replaced database would be applied to the restored one
+	if filepath.Ext(path) == ".db" {
+		_ = os.Remove(path + "-wal")
+		_ = os.Remove(path + "-shm")
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return &errs.StorageError{Op: "rename", Path: path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func createFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	backupDir := filepath.Join(dataDir, "backups")
+
+	createFile(t, filepath.Join(dataDir, "incident.json"), `{"state": 1}`)
+	createFile(t, filepath.Join(dataDir, "archive", "C1.jsonl"), "{}\n")
+	createFile(t, filepath.Join(dataDir, "config.yaml"), "not state")
+	createFile(t, filepath.Join(backupDir, "slackbot-20200101T000000Z.tar.gz"), "older archive")
+
+	dbPath := filepath.Join(dataDir, "aichat_context.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec("CREATE TABLE messages (text TEXT); INSERT INTO messages VALUES ('hello')"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 3, 7, 3, 0, 0, 0, time.UTC)
+	path, err := Snapshot(ctx, dataDir, backupDir, now)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if want := filepath.Join(backupDir, "slackbot-20250307T030000Z.tar.gz"); path != want {
+		t.Errorf("Snapshot() = %s, want %s", path, want)
+	}
+
+	restoreDir := t.TempDir()
+	createFile(t, filepath.Join(restoreDir, "incident.json"), `{"state": 2}`)
+	createFile(t, filepath.Join(restoreDir, "aichat_context.db-wal"), "stale log")
+	restored, err := Restore(path, restoreDir)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	slices.Sort(restored)
+	if want := []string{"aichat_context.db", "archive/C1.jsonl", "incident.json"}; !slices.Equal(restored, want) {
+		t.Errorf("Restored %v, want only state files %v", restored, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(restoreDir, "incident.json")); string(data) != `{"state": 1}` {
+		t.Errorf("Restored incident.json = %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(restoreDir, "aichat_context.db-wal")); !os.IsNotExist(err) {
+		t.Error("A write-ahead log from the replaced database should be removed")
+	}
+
+	restoredDB, err := sql.Open("sqlite", filepath.Join(restoreDir, "aichat_context.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = restoredDB.Close() }()
+	var text string
+	if err := restoredDB.QueryRow("SELECT text FROM messages").Scan(&text); err != nil || text != "hello" {
+		t.Errorf("Restored database row = %q (%v), want hello", text, err)
+	}
+}
+
+func TestBackup_Schedule(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	createFile(t, filepath.Join(dataDir, "roster.json"), "{}")
+
+	b := New(zaptest.NewLogger(t), Config{
+		DataDir:  dataDir,
+		Dir:      filepath.Join(dataDir, "backups"),
+		Interval: 24 * time.Hour,
+		Keep:     2,
+	})
+	now := time.Date(2025, 3, 7, 3, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	created := func() []time.Time {
+		archives, err := List(b.config.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var times []time.Time
+		for _, a := range archives {
+			times = append(times, a.CreatedAt)
+		}
+		return times
+	}
+
+	b.snapshotIfDue(ctx)
+	now = now.Add(time.Hour)
+	b.snapshotIfDue(ctx) // Not due yet
+	if got := created(); len(got) != 1 {
+		t.Fatalf("Expected one snapshot within the interval, got %v", got)
+	}
+
+	for range 3 {
+		now = now.Add(24 * time.Hour)
+		b.snapshotIfDue(ctx)
+	}
+	want := []time.Time{now, now.Add(-24 * time.Hour)}
+	if got := created(); !slices.EqualFunc(got, want, time.Time.Equal) {
+		t.Errorf("Kept %v, want the newest %v", got, want)
+	}
+}
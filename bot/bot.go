@@ -4,17 +4,48 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/urfave/cli/v3"
 	"go.uber.org/zap"
 	"slackbot.arpa/bot/ai"
 	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/analytics"
+	"slackbot.arpa/bot/announce"
+	"slackbot.arpa/bot/api"
+	"slackbot.arpa/bot/backup"
+	"slackbot.arpa/bot/canvas"
 	"slackbot.arpa/bot/chat"
+	"slackbot.arpa/bot/claim"
 	"slackbot.arpa/bot/config"
+	"slackbot.arpa/bot/control"
+	"slackbot.arpa/bot/eventbus"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/facts"
+	"slackbot.arpa/bot/feedback"
+	"slackbot.arpa/bot/filescan"
+	"slackbot.arpa/bot/handoff"
 	"slackbot.arpa/bot/http"
+	"slackbot.arpa/bot/incident"
+	"slackbot.arpa/bot/karma"
+	"slackbot.arpa/bot/lastseen"
+	"slackbot.arpa/bot/leader"
+	"slackbot.arpa/bot/loopguard"
+	"slackbot.arpa/bot/membership"
+	"slackbot.arpa/bot/outbox"
+	"slackbot.arpa/bot/personastore"
+	"slackbot.arpa/bot/replylog"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/selftest"
 	"slackbot.arpa/bot/showerthought"
 	"slackbot.arpa/bot/slack"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/topic"
 	"slackbot.arpa/bot/user"
 	"slackbot.arpa/bot/vibecheck"
 	"slackbot.arpa/logger"
@@ -33,6 +64,39 @@ type Bot struct {
 	ai            *ai.AI
 	aichat        *aichat.AIChat
 	showerThought *showerthought.ShowerThought
+	loopGuard     *loopguard.Guard
+	claims        *claim.Coordinator
+	incident      *incident.Mode
+	membership    *membership.Watcher
+	backup        *backup.Backup
+	topicGuard    *topic.Guard
+	fileScan      *filescan.Scanner
+	feedback      *feedback.Box
+	outbox        *outbox.Outbox
+	handoff       *handoff.Desk
+	facts         *facts.Keeper
+	lastSeen      *lastseen.Heartbeat
+	karma         *karma.Karma
+	scheduler     *scheduler.Scheduler
+	tasks         *scheduler.Tasks // Delayed tasks shared by features, e.g. vibecheck's kicks
+	explainer     *explain.Explainer
+	personaStore  *personastore.Store
+	retention     *retention.Sweeper
+	analytics     *analytics.Analytics
+	selfTest      *selftest.SelfTest
+	leader        *leader.Elector
+	eventBus      *eventbus.Bus
+	replies       *replylog.Log
+	announcer     *announce.Announcer
+	broadcaster   *announce.Broadcaster
+	status        *status.Registry
+	statusReply   *status.Responder
+	control       *control.Server
+	remote        *control.Client // Set when commands run in an already running bot
+	api           *api.Server
+	servicesMu    sync.Mutex      // Guards the services config changes start and stop, see lifecycle.go
+	runCtx        context.Context // Set once Run has started the services, until Shutdown
+	applied       appliedConfig   // Config chat, vibecheck and the scheduler were last created with
 }
 
 func NewBot(buildOpts config.BuildOpts) *Bot {
@@ -59,11 +123,14 @@ func (s *Bot) Setup(ctx context.Context, cmd *cli.Command) (context.Context, err
 	if cliOverrides.Environment != nil {
 		isProd = *cliOverrides.Environment == "production"
 	}
+	// Keep stdout for command results when they're parsed by scripts
+	logStderr := cmd.String("output") == config.OutputJSON
 
 	s.logger, err = logger.NewLogger(logger.LoggerOpts{
 		Level:        logLevel,
 		IsProduction: isProd,
 		JSONConsole:  isProd,
+		Stderr:       logStderr,
 	})
 	if err != nil {
 		return ctx, fmt.Errorf("logger setup: %w", err)
@@ -84,24 +151,131 @@ func (s *Bot) Setup(ctx context.Context, cmd *cli.Command) (context.Context, err
 		Level:        currentConfig.LogLevel,
 		IsProduction: currentConfig.Environment == config.EnvironmentProduction,
 		JSONConsole:  currentConfig.Environment == config.EnvironmentProduction,
+		Stderr:       logStderr,
 	})
 	if err != nil {
 		return ctx, fmt.Errorf("logger setup: %w", err)
 	}
 	s.log = s.logger.Get()
+	if err := s.logger.SetFeatureLevels(currentConfig.LogLevels); err != nil {
+		return ctx, fmt.Errorf("logger setup: %w", err)
+	}
+
+	command := cmd.Args().First()
+	if slices.Contains(configOnlyCommands, command) {
+		return ctx, nil
+	}
+	// A command runs in the bot serving the control socket instead of setting up its own
+	// Slack connection and services
+	if command != "" && control.Available(ctx, currentConfig.ControlSocket) {
+		s.log.Debug("Running command in the running bot", zap.String("command", command), zap.String("socket", currentConfig.ControlSocket))
+		s.remote = control.NewClient(currentConfig.ControlSocket)
+		return ctx, nil
+	}
 
 	// Initialize services with live config
-	s.slack = slack.NewSlack(s.log, s.configManager.GetSlackConfig())
+	s.slack = slack.NewSlack(s.logger.Named("slack"), s.configManager.GetSlackConfig())
 	if err := s.slack.Setup(ctx); err != nil {
 		return ctx, fmt.Errorf("setup slack service: %w", err)
 	}
 
-	s.userWatch = user.NewUserWatch(s.log, s.configManager.GetUserConfig(), s.slack)
+	if shadow := currentConfig.Slack.Shadow; len(shadow) > 0 {
+		s.log.Warn("Shadow mode, these features record what they would send to Slack instead of sending it",
+			zap.Strings("features", shadow),
+			zap.String("log", currentConfig.Slack.ShadowLog))
+	}
+
+	s.tasks = scheduler.NewTasks(scheduler.DefaultMaxPendingTasks)
+	s.userWatch = user.NewUserWatch(s.logger.Named("userwatch"), s.configManager.GetUserConfig(), s.slack.For("userwatch"))
 
 	// Initialize services conditionally based on their configuration
 	s.initializeServices(ctx, currentConfig)
+	s.registerStatusTrackers()
+	s.initializeRetention()
+
+	s.http = http.NewServer(s.logger.Named("http"), s.configManager.GetHTTPConfig(), s.slack)
+	s.http.RegisterHealthCheck("slack", s.slack.HealthCheck)
+	s.http.SetStatusRegistry(s.status)
+	s.http.SetConfigView(func() (any, error) { return s.configManager.GetConfig().Redacted() })
+	if s.ai != nil {
+		for _, name := range s.ai.ProviderNames() {
+			s.http.RegisterHealthCheck("llm_"+name, func() error { return s.ai.ProviderHealthCheck(name) })
+		}
+	}
+
+	// Only the replica holding the lease passes events on, so replicas don't respond twice
+	if leaderConfig := s.configManager.GetLeaderConfig(); leaderConfig.Enabled {
+		s.leader = leader.New(s.logger.Named("leader"), leaderConfig)
+		s.leader.SetStatusTracker(s.status.Feature("leader"))
+		s.http.AddEventFilter(s.leader)
+		s.http.SetLeadership(func() any { return s.leader.Leadership() })
+		s.log.Info("Leader election enabled",
+			zap.String("id", leaderConfig.ID),
+			zap.String("key", leaderConfig.Key),
+			zap.Duration("ttl", leaderConfig.TTL))
+	}
+
+	// Receivers publish events to the bus and workers dispatch them, so the two can scale
+	// separately. Every process with the bus publishes the events Slack sends it.
+	if eventBusConfig := s.configManager.GetEventBusConfig(); eventBusConfig.Enabled {
+		s.eventBus = eventbus.New(s.logger.Named("eventbus"), eventBusConfig, s.http)
+		s.eventBus.SetStatusTracker(s.status.Feature("eventbus"))
+		s.http.SetEventPublisher(s.eventBus)
+		s.http.RegisterHealthCheck("eventbus", s.eventBus.HealthCheck)
+		s.log.Info("Event bus enabled",
+			zap.String("backend", eventBusConfig.Backend),
+			zap.String("role", eventBusConfig.Role),
+			zap.String("stream", eventBusConfig.Stream),
+			zap.String("consumer", eventBusConfig.Consumer))
+	}
+
+	if loopGuardConfig := s.configManager.GetLoopGuardConfig(); loopGuardConfig.Enabled {
+		s.loopGuard = loopguard.New(s.logger.Named("loopguard"), loopGuardConfig, s.slack)
+		s.http.AddEventFilter(s.loopGuard)
+		s.log.Info("Loop guard enabled",
+			zap.Int("burst_limit", loopGuardConfig.BurstLimit),
+			zap.Int("repeat_limit", loopGuardConfig.RepeatLimit))
+	}
 
-	s.http = http.NewServer(s.log, s.configManager.GetHTTPConfig(), s.slack)
+	if claimConfig := s.configManager.GetClaimConfig(); claimConfig.Enabled {
+		s.claims = claim.New(s.logger.Named("claim"), claimConfig)
+		s.log.Info("Response claims enabled",
+			zap.Int("max_per_message", claimConfig.MaxPerMessage),
+			zap.Strings("priority", claimConfig.Priority),
+			zap.Duration("window", claimConfig.Window))
+	}
+
+	s.registerDashboardSections()
+	s.setPresence()
+
+	// Deleted users' notifications have a button for writing them a eulogy
+	if s.userWatch != nil {
+		s.http.HandleBlockAction(user.EulogyActionID, s.userWatch.HandleEulogyAction)
+		s.http.HandleViewSubmission(user.EulogyCallbackID, s.userWatch.HandleEulogySubmission)
+	}
+
+	s.incident = incident.New(s.logger.Named("incident"), s.configManager.GetIncidentConfig(), s.slack)
+	s.http.RegisterCommandProcessor(s.incident)
+
+	s.control = control.NewServer(s.logger.Named("control"), currentConfig.ControlSocket, s.runCommand)
+
+	if grpcConfig := s.configManager.GetGRPCConfig(); grpcConfig.Enabled {
+		s.api = api.New(s.logger.Named("api"), grpcConfig, s.slack, s.status, s.configManager)
+	}
+
+	// The features config changes restart are wired the same way here as in lifecycle.go
+	if s.chat != nil {
+		s.wireChat(s.chat)
+	}
+	if s.vibecheck != nil {
+		s.wireVibecheck(s.vibecheck)
+	}
+	if s.aichat != nil {
+		s.wireAIChat(s.aichat)
+	}
+	if s.scheduler != nil {
+		s.wireScheduler(s.scheduler)
+	}
 
 	// Subscribe to config changes for dynamic service reconfiguration
 	s.configManager.Subscribe(s.onConfigChange)
@@ -111,39 +285,16 @@ func (s *Bot) Setup(ctx context.Context, cmd *cli.Command) (context.Context, err
 
 // initializeServices conditionally initializes services based on configuration
 func (s *Bot) initializeServices(ctx context.Context, currentConfig *config.Config) {
-	// Only initialize chat service if there are chat responses configured
-	fileConfig := s.configManager.GetConfig()
-	var chatResponses int
-	if fileConfig != nil {
-		// Load current file config to check responses
-		var fc config.FileConfig
-		if currentConfig.ConfigFile != "" {
-			if err := config.ReadConfig(currentConfig.ConfigFile, &fc); err == nil {
-				chatResponses = len(fc.Chat.Responses)
-			}
-		}
-	}
-
-	if chatResponses > 0 {
-		s.chat = chat.NewChat(s.log, s.configManager.GetChatConfig(), s.slack)
-		s.log.Info("Chat service initialized", zap.Int("responses", chatResponses))
+	// Only initialize chat service if there are chat responses or reaction thresholds configured
+	chatConfig := s.configManager.GetChatConfig()
+	if s.chat = s.newChat(chatConfig); s.chat != nil {
+		s.log.Info("Chat service initialized", zap.Int("responses", len(chatConfig.Responses)+len(chatConfig.ReactionThresholds)))
 	} else {
 		s.log.Info("Chat service disabled - no responses configured")
 	}
 
 	// Only initialize vibecheck service if there are reactions configured
-	var hasReactions bool
-	if fileConfig != nil {
-		var fc config.FileConfig
-		if currentConfig.ConfigFile != "" {
-			if err := config.ReadConfig(currentConfig.ConfigFile, &fc); err == nil {
-				hasReactions = len(fc.Vibecheck.GoodReactions) > 0 || len(fc.Vibecheck.BadReactions) > 0
-			}
-		}
-	}
-
-	if hasReactions {
-		s.vibecheck = vibecheck.NewVibecheck(s.log, s.configManager.GetVibecheckConfig(), s.slack)
+	if s.vibecheck = s.newVibecheck(s.configManager.GetVibecheckConfig(), s.configManager.GetFileConfig().Vibecheck); s.vibecheck != nil {
 		s.log.Info("Vibecheck service initialized")
 	} else {
 		s.log.Info("Vibecheck service disabled - no reactions configured")
@@ -151,13 +302,12 @@ func (s *Bot) initializeServices(ctx context.Context, currentConfig *config.Conf
 
 	// Only initialize AI services if OpenAI API key is provided
 	aiConfig := s.configManager.GetAIConfig()
-	if aiConfig.OpenAIAPIKey != "" {
-		s.ai = ai.NewAI(s.log, aiConfig)
+	if aiConfig.Enabled() {
+		s.ai = ai.NewAI(s.logger.Named("ai"), aiConfig)
 
 		// Only initialize aichat service if there are personas configured
 		aichatConfig := s.configManager.GetAIChatConfig()
-		if len(aichatConfig.Personas) > 0 {
-			s.aichat = aichat.NewAIChat(s.log, aichatConfig, s.slack, s.ai)
+		if s.aichat = s.newAIChat(aichatConfig); s.aichat != nil {
 			personaKeys := make([]string, 0, len(aichatConfig.Personas))
 			for k := range aichatConfig.Personas {
 				personaKeys = append(personaKeys, k)
@@ -170,43 +320,383 @@ func (s *Bot) initializeServices(ctx context.Context, currentConfig *config.Conf
 		// Only initialize showerthought if enabled and notify channel is set
 		stConfig := s.configManager.GetShowerthoughtConfig()
 		if stConfig.Enabled && stConfig.NotifyChannel != "" {
-			s.showerThought = showerthought.New(s.log, stConfig, s.slack, s.ai)
+			s.showerThought = showerthought.New(s.logger.Named("showerthought"), stConfig, s.slack.For("showerthought"), s.ai.For("showerthought"))
 			s.log.Info("Shower thought service initialized",
 				zap.String("channel", stConfig.NotifyChannel))
 		} else if stConfig.Enabled {
 			s.log.Warn("Shower thought service disabled - no notify channel configured")
 		}
 	} else {
-		s.log.Info("AI services disabled - no OpenAI API key provided")
+		s.log.Info("AI services disabled - no OpenAI API key or LLM providers configured")
+	}
+
+	// Only initialize the membership watcher if there are channels to watch
+	if membershipConfig := s.configManager.GetMembershipConfig(); len(membershipConfig.Channels) > 0 {
+		s.membership = membership.New(s.logger.Named("membership"), membershipConfig, s.slack.For("membership"))
+		s.log.Info("Membership watcher initialized", zap.Int("channels", len(membershipConfig.Channels)))
+	}
+
+	if backupConfig := s.configManager.GetBackupConfig(); backupConfig.Enabled {
+		s.backup = backup.New(s.logger.Named("backup"), backupConfig)
+	}
+
+	// Only initialize the file scanner if there are channels to moderate
+	if fileScanConfig := s.configManager.GetFileScanConfig(); len(fileScanConfig.Channels) > 0 {
+		s.fileScan = filescan.New(s.logger.Named("filescan"), fileScanConfig, s.slack.For("filescan"))
+		s.log.Info("File scanner initialized", zap.Int("channels", len(fileScanConfig.Channels)))
+	}
+
+	// Only initialize the outbox if a feature holds or paces its replies
+	if outboxConfig := s.configManager.GetOutboxConfig(); len(outboxConfig.Delays) > 0 || len(outboxConfig.Pacing.Features) > 0 {
+		s.outbox = outbox.New(s.logger.Named("outbox"), outboxConfig, s.slack)
+		s.log.Info("Outbox initialized", zap.Any("delays", outboxConfig.Delays), zap.Strings("paced", outboxConfig.Pacing.Features))
+	}
+
+	// Only initialize the handoff desk if there's a responder group to ping
+	if handoffConfig := s.configManager.GetHandoffConfig(); handoffConfig.Responders != "" {
+		s.handoff = handoff.New(s.logger.Named("handoff"), handoffConfig, s.slack.For("handoff"))
+		s.log.Info("Handoff desk initialized", zap.String("responders", handoffConfig.Responders))
+	}
+
+	if factsConfig := s.configManager.GetFactsConfig(); factsConfig.Enabled {
+		s.facts = facts.New(s.logger.Named("facts"), factsConfig, s.slack.For("facts"))
+		if factsConfig.Canvas {
+			s.facts.SetMirror(canvas.New(s.logger.Named("canvas"), currentConfig.DataDir, s.slack.For("facts")))
+		}
+		s.log.Info("Facts keeper initialized", zap.Int("max_per_channel", factsConfig.MaxPerChannel))
+	}
+
+	if lastSeenConfig := s.configManager.GetLastSeenConfig(); lastSeenConfig.Enabled {
+		s.lastSeen = lastseen.New(s.logger.Named("lastseen"), lastSeenConfig, s.slack.For("lastseen"))
+		s.log.Info("Last seen tracking initialized", zap.Strings("report_channels", lastSeenConfig.ReportChannels))
+	}
+
+	if karmaConfig := s.configManager.GetKarmaConfig(); karmaConfig.Enabled {
+		s.karma = karma.New(s.logger.Named("karma"), karmaConfig, s.slack.For("karma"))
+		s.log.Info("Karma initialized",
+			zap.Strings("upvotes", karmaConfig.Upvotes),
+			zap.Strings("downvotes", karmaConfig.Downvotes))
+	}
+
+	if s.scheduler = s.newScheduler(s.configManager.GetSchedulerConfig()); s.scheduler != nil {
+		s.log.Info("Scheduler initialized", zap.Int("schedules", len(s.configManager.GetSchedulerConfig().Schedules)))
+	}
+
+	if explainConfig := s.configManager.GetExplainConfig(); explainConfig.Enabled {
+		s.explainer = explain.New(s.logger.Named("explain"), explainConfig, s.slack.For("explain"))
+		s.log.Info("Explain reaction enabled", zap.String("reaction", explainConfig.Reaction))
+	}
+
+	// Personas created from Slack are applied over aichat's configured ones
+	if personasConfig := s.configManager.GetPersonasConfig(); personasConfig.Enabled && s.aichat != nil {
+		s.personaStore = personastore.New(s.logger.Named("personas"), personasConfig, s.slack.For("personas"), s.aichat)
+		s.personaStore.SetAI(s.ai.For("personas"))
+		s.aichat.AddAnswerer(s.personaStore)
+		s.log.Info("Persona store initialized",
+			zap.Int("stored", len(s.personaStore.Definitions())),
+			zap.Strings("admins", personasConfig.Admins))
+	}
+
+	// Only initialize analytics if it's enabled
+	if analyticsConfig := s.configManager.GetAnalyticsConfig(); analyticsConfig.Enabled {
+		s.analytics = analytics.New(s.logger.Named("analytics"), analyticsConfig, s.slack.For("analytics"))
+		s.log.Info("Analytics initialized", zap.String("report_channel", analyticsConfig.ReportChannel))
+	}
+
+	// Only initialize the self-test if there's an ops channel to report to
+	if selfTestConfig := s.configManager.GetSelfTestConfig(); selfTestConfig.Channel != "" {
+		s.selfTest = selftest.New(s.logger.Named("selftest"), selfTestConfig, s.slack.For("selftest"))
+		if s.ai != nil {
+			s.selfTest.SetAI(s.ai.For("selftest"))
+		}
+		s.log.Info("Self-test initialized", zap.String("channel", selfTestConfig.Channel), zap.Int("hour", selfTestConfig.Hour))
+	}
+
+	// Only initialize the feedback box if there's a channel to relay to
+	if feedbackConfig := s.configManager.GetFeedbackConfig(); feedbackConfig.Channel != "" {
+		box, err := feedback.New(s.logger.Named("feedback"), feedbackConfig, s.slack.For("feedback"))
+		if err != nil {
+			s.log.Error("Failed to initialize feedback box", zap.Error(err))
+		} else {
+			s.feedback = box
+			s.log.Info("Feedback box initialized", zap.String("channel", feedbackConfig.Channel))
+		}
+	}
+
+	// Only initialize the topic guard if there are channels to keep
+	if topicConfig := s.configManager.GetTopicConfig(); len(topicConfig.Channels) > 0 {
+		s.topicGuard = topic.New(s.logger.Named("topic"), topicConfig, s.slack.For("topic"))
+		s.log.Info("Topic guard initialized", zap.Int("channels", len(topicConfig.Channels)))
+	}
+
+	if announceConfig := s.configManager.GetAnnounceConfig(); announceConfig.Enabled {
+		s.announcer = announce.New(s.logger.Named("announce"), announceConfig, s.slack, currentConfig.Version)
+	}
+	if broadcastsConfig := s.configManager.GetAnnounceConfig().Broadcasts; broadcastsConfig.Enabled {
+		s.broadcaster = announce.NewBroadcaster(s.logger.Named("broadcast"), broadcastsConfig, s.slack)
+		s.log.Info("Broadcaster initialized", zap.Int("announcements", len(broadcastsConfig.Announcements)))
+	}
+}
+
+// registerStatusTrackers gives each running feature a tracker in the status registry
+func (s *Bot) registerStatusTrackers() {
+	s.status = status.NewRegistry()
+	s.statusReply = status.NewResponder(s.logger.Named("status"), s.slack, s.status, s.configManager.GetConfig().TriggerAliases)
+
+	if s.userWatch != nil {
+		s.userWatch.SetStatusTracker(s.status.Feature("userwatch"))
+	}
+	if s.showerThought != nil {
+		s.showerThought.SetStatusTracker(s.status.Feature("showerthought"))
+	}
+	if s.membership != nil {
+		s.membership.SetStatusTracker(s.status.Feature(s.membership.ProcessorType()))
+	}
+	if s.backup != nil {
+		s.backup.SetStatusTracker(s.status.Feature("backup"))
+	}
+	if s.fileScan != nil {
+		s.fileScan.SetStatusTracker(s.status.Feature(s.fileScan.ProcessorType()))
+	}
+	if s.handoff != nil {
+		s.handoff.SetStatusTracker(s.status.Feature(s.handoff.ProcessorType()))
+	}
+	if s.broadcaster != nil {
+		s.broadcaster.SetStatusTracker(s.status.Feature(s.broadcaster.ProcessorType()))
+	}
+	if s.facts != nil {
+		s.facts.SetStatusTracker(s.status.Feature(s.facts.ProcessorType()))
+	}
+	if s.lastSeen != nil {
+		s.lastSeen.SetStatusTracker(s.status.Feature(s.lastSeen.ProcessorType()))
+	}
+	if s.karma != nil {
+		s.karma.SetStatusTracker(s.status.Feature(s.karma.ProcessorType()))
+	}
+	if s.explainer != nil {
+		s.explainer.SetStatusTracker(s.status.Feature(s.explainer.ProcessorType()))
+	}
+	if s.personaStore != nil {
+		s.personaStore.SetStatusTracker(s.status.Feature(s.personaStore.ProcessorType()))
+	}
+	if s.analytics != nil {
+		s.analytics.SetStatusTracker(s.status.Feature(s.analytics.ProcessorType()))
+	}
+	if s.selfTest != nil {
+		s.selfTest.SetStatusTracker(s.status.Feature("selftest"))
+	}
+	if s.outbox != nil {
+		s.outbox.SetStatusTracker(s.status.Feature(s.outbox.ProcessorType()))
+	}
+	if s.feedback != nil {
+		s.feedback.SetStatusTracker(s.status.Feature(s.feedback.ProcessorType()))
+	}
+	if s.topicGuard != nil {
+		s.topicGuard.SetStatusTracker(s.status.Feature("topic"))
+	}
+}
+
+// initializeRetention has the sweeper expire the stored user data of running features
+// when retention periods are configured
+func (s *Bot) initializeRetention() {
+	retentionConfig := s.configManager.GetRetentionConfig()
+	if !retentionConfig.Enabled() {
+		return
+	}
+	s.retention = retention.New(s.logger.Named("retention"), retentionConfig, s.status)
+	if s.feedback != nil {
+		s.retention.Register("feedback", s.feedback)
+	}
+	if s.fileScan != nil {
+		s.retention.Register("filescan", s.fileScan)
+	}
+	s.log.Info("Retention sweeps initialized",
+		zap.Any("namespaces", retentionConfig.Namespaces),
+		zap.Any("features", retentionConfig.Features))
+}
+
+// setPresence has features skip channels the bot was removed from
+func (s *Bot) setPresence() {
+	presence := s.slack.Presence()
+	s.http.RegisterEventProcessor(presence)
+	if s.userWatch != nil {
+		s.userWatch.SetPresence(presence)
+	}
+	if s.showerThought != nil {
+		s.showerThought.SetPresence(presence)
+	}
+	if s.membership != nil {
+		s.membership.SetPresence(presence)
+	}
+	if s.topicGuard != nil {
+		s.topicGuard.SetPresence(presence)
+	}
+}
+
+// registerDashboardSections adds the config summary and vibecheck bans to /dashboard
+func (s *Bot) registerDashboardSections() {
+	s.http.AddDashboardSection(http.DashboardSection{
+		Title:   "Configuration",
+		Columns: []string{"Setting", "Value"},
+		Rows: func() [][]string {
+			c := s.configManager.GetConfig()
+			if c == nil {
+				return nil
+			}
+			rows := [][]string{
+				{"Version", c.Version},
+				{"Build time", c.BuildTime},
+				{"Environment", string(c.Environment)},
+				{"Log level", c.LogLevel},
+				{"Data directory", c.DataDir},
+				{"Config file", c.ConfigFile},
+				{"Features", strings.Join(s.runningFeatures(), ", ")},
+			}
+			if len(c.LogLevels) > 0 {
+				levels := make([]string, 0, len(c.LogLevels))
+				for feature, level := range c.LogLevels {
+					levels = append(levels, feature+"="+level)
+				}
+				slices.Sort(levels)
+				rows = append(rows, []string{"Feature log levels", strings.Join(levels, ", ")})
+			}
+			return rows
+		},
+	})
+
+	s.http.AddDashboardSection(http.DashboardSection{
+		Title:   "Removed from channels",
+		Columns: []string{"Channel", "Reason"},
+		Empty:   "The bot is in every channel it has posted to.",
+		Rows: func() [][]string {
+			var rows [][]string
+			for channel, reason := range s.slack.Presence().Removed() {
+				rows = append(rows, []string{channel, reason})
+			}
+			slices.SortFunc(rows, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+			return rows
+		},
+	})
+
+	if s.backup != nil {
+		backupDir := s.configManager.GetBackupConfig().Dir
+		s.http.AddDashboardSection(http.DashboardSection{
+			Title:   "Backups",
+			Columns: []string{"Archive", "Created", "Size"},
+			Empty:   "No backups have been taken yet.",
+			Rows: func() [][]string {
+				archives, err := backup.List(backupDir)
+				if err != nil {
+					s.log.Warn("Failed to list backups", zap.Error(err))
+				}
+				var rows [][]string
+				for _, a := range archives {
+					rows = append(rows, []string{
+						a.Path,
+						a.CreatedAt.Local().Format(time.RFC3339),
+						fmt.Sprintf("%.1f KiB", float64(a.Size)/1024),
+					})
+				}
+				return rows
+			},
+		})
+	}
+
+	// Added whether or not vibecheck runs, since a config change can start it
+	s.http.AddDashboardSection(http.DashboardSection{
+		Title:   "Vibecheck bans",
+		Columns: []string{"User", "Channel", "Punishment", "Kicked", "Reinvite"},
+		Empty:   "Nobody is banned.",
+		Rows: func() [][]string {
+			s.servicesMu.Lock()
+			v := s.vibecheck
+			s.servicesMu.Unlock()
+			if v == nil {
+				return nil
+			}
+			var rows [][]string
+			for _, b := range v.Bans() {
+				rows = append(rows, []string{
+					b.UserID,
+					b.ChannelID,
+					b.Punishment,
+					b.KickedAt.Format(time.RFC3339),
+					b.ReinviteAt.Format(time.RFC3339),
+				})
+			}
+			return rows
+		},
+	})
+}
+
+// runningFeatures names the optional features that were initialized
+func (s *Bot) runningFeatures() []string {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	var features []string
+	for name, running := range map[string]bool{
+		"userwatch":     s.userWatch != nil,
+		"chat":          s.chat != nil,
+		"vibecheck":     s.vibecheck != nil,
+		"ai":            s.ai != nil,
+		"aichat":        s.aichat != nil,
+		"showerthought": s.showerThought != nil,
+		"loopguard":     s.loopGuard != nil,
+		"claim":         s.claims != nil,
+		"membership":    s.membership != nil,
+		"backup":        s.backup != nil,
+		"topic":         s.topicGuard != nil,
+		"filescan":      s.fileScan != nil,
+		"feedback":      s.feedback != nil,
+		"outbox":        s.outbox != nil,
+		"handoff":       s.handoff != nil,
+		"broadcast":     s.broadcaster != nil,
+		"facts":         s.facts != nil,
+		"lastseen":      s.lastSeen != nil,
+		"karma":         s.karma != nil,
+		"scheduler":     s.scheduler != nil,
+		"explain":       s.explainer != nil,
+		"personas":      s.personaStore != nil,
+		"retention":     s.retention != nil,
+		"analytics":     s.analytics != nil,
+		"selftest":      s.selfTest != nil,
+		"leader":        s.leader != nil,
+		"eventbus":      s.eventBus != nil,
+	} {
+		if running {
+			features = append(features, name)
+		}
 	}
+	slices.Sort(features)
+	return features
 }
 
 // onConfigChange handles configuration changes and reconfigures services
 func (s *Bot) onConfigChange(newConfig *config.Config) {
 	s.log.Info("Configuration changed, updating services")
 
-	// Update logger if log level changed
-	if s.log != nil {
-		newLogger, err := logger.NewLogger(logger.LoggerOpts{
-			Level:        newConfig.LogLevel,
-			IsProduction: newConfig.Environment == config.EnvironmentProduction,
-			JSONConsole:  newConfig.Environment == config.EnvironmentProduction,
-		})
-		if err != nil {
-			s.log.Error("Failed to update logger with new config", zap.Error(err))
-		} else {
-			s.logger = newLogger
-			s.log = s.logger.Get()
-			s.log.Info("Logger updated with new configuration")
-		}
+	// Update log levels in place so feature loggers pick up the change
+	if err := s.logger.SetLevelStr(newConfig.LogLevel); err != nil {
+		s.log.Error("Failed to update log level", zap.Error(err))
+	}
+	if err := s.logger.SetFeatureLevels(newConfig.LogLevels); err != nil {
+		s.log.Error("Failed to update feature log levels", zap.Error(err))
+	} else {
+		s.log.Info("Log levels updated",
+			zap.String("level", newConfig.LogLevel),
+			zap.Any("features", newConfig.LogLevels))
 	}
 
-	// Note: Services will use the updated config from ConfigManager automatically
-	// Some services may need to be reinitialized for certain config changes
+	if s.http != nil {
+		s.http.SetAuth(newConfig.Server.Auth)
+	}
+
+	// Chat, vibecheck and aichat are started, stopped or replaced to match. Other
+	// services read the updated config from ConfigManager or need a restart.
+	s.reconcileServices()
 
 	// Note: AI services may need restart for some changes (like API keys)
-	// For now, we'll just log the change
-	if s.ai != nil || s.aichat != nil {
+	if s.ai != nil {
 		s.log.Info("AI service configuration changed - may require restart for some changes")
 	}
 
@@ -218,6 +708,19 @@ func (s *Bot) Run(runCtx context.Context) error {
 		return fmt.Errorf("start slack service: %w", err)
 	}
 
+	if s.leader != nil {
+		if err := s.leader.Start(runCtx); err != nil {
+			return fmt.Errorf("start leader election: %w", err)
+		}
+	}
+
+	if s.outbox != nil {
+		s.http.RegisterEventProcessor(s.outbox)
+		if err := s.outbox.Start(runCtx); err != nil {
+			return fmt.Errorf("start outbox: %w", err)
+		}
+	}
+
 	// ConfigManager is already running and providing live config updates
 
 	if s.chat != nil && s.http != nil {
@@ -248,6 +751,7 @@ func (s *Bot) Run(runCtx context.Context) error {
 
 	if s.aichat != nil {
 		s.http.RegisterEventProcessor(s.aichat)
+		s.http.RegisterInteractionProcessor(s.aichat)
 		if err := s.aichat.Start(runCtx); err != nil {
 			return fmt.Errorf("start aichat: %w", err)
 		}
@@ -259,6 +763,151 @@ func (s *Bot) Run(runCtx context.Context) error {
 		}
 	}
 
+	if s.membership != nil {
+		s.http.RegisterEventProcessor(s.membership)
+		if err := s.membership.Start(runCtx); err != nil {
+			return fmt.Errorf("start membership watcher: %w", err)
+		}
+	}
+
+	if s.backup != nil {
+		if err := s.backup.Start(runCtx); err != nil {
+			return fmt.Errorf("start backups: %w", err)
+		}
+	}
+
+	if s.fileScan != nil {
+		s.http.RegisterEventProcessor(s.fileScan)
+		if err := s.fileScan.Start(runCtx); err != nil {
+			return fmt.Errorf("start file scanner: %w", err)
+		}
+	}
+
+	if s.handoff != nil {
+		s.http.RegisterEventProcessor(s.handoff)
+		if err := s.handoff.Start(runCtx); err != nil {
+			return fmt.Errorf("start handoff desk: %w", err)
+		}
+	}
+
+	if s.broadcaster != nil {
+		s.http.RegisterEventProcessor(s.broadcaster)
+		if err := s.broadcaster.Start(runCtx); err != nil {
+			return fmt.Errorf("start broadcaster: %w", err)
+		}
+	}
+
+	if s.facts != nil {
+		s.http.RegisterEventProcessor(s.facts)
+		if err := s.facts.Start(runCtx); err != nil {
+			return fmt.Errorf("start facts keeper: %w", err)
+		}
+	}
+
+	if s.retention != nil {
+		if err := s.retention.Start(runCtx); err != nil {
+			return fmt.Errorf("start retention sweeps: %w", err)
+		}
+	}
+
+	if s.lastSeen != nil {
+		s.http.RegisterEventProcessor(s.lastSeen)
+		if err := s.lastSeen.Start(runCtx); err != nil {
+			return fmt.Errorf("start last seen tracking: %w", err)
+		}
+	}
+
+	if s.karma != nil {
+		s.http.RegisterEventProcessor(s.karma)
+		s.http.RegisterCommandProcessor(s.karma)
+		if err := s.karma.Start(runCtx); err != nil {
+			return fmt.Errorf("start karma: %w", err)
+		}
+	}
+
+	if s.scheduler != nil {
+		if err := s.scheduler.Start(runCtx); err != nil {
+			return fmt.Errorf("start scheduler: %w", err)
+		}
+	}
+
+	if s.explainer != nil {
+		s.http.RegisterEventProcessor(s.explainer)
+		if err := s.explainer.Start(runCtx); err != nil {
+			return fmt.Errorf("start explainer: %w", err)
+		}
+	}
+
+	if s.personaStore != nil {
+		s.http.RegisterEventProcessor(s.personaStore)
+		s.http.RegisterCommandProcessor(s.personaStore)
+		if err := s.personaStore.Start(runCtx); err != nil {
+			return fmt.Errorf("start persona store: %w", err)
+		}
+	}
+
+	if s.analytics != nil {
+		s.http.RegisterEventProcessor(s.analytics)
+		s.http.HandleAdmin("/api/analytics", s.analytics.ServeHTTP)
+		if err := s.analytics.Start(runCtx); err != nil {
+			return fmt.Errorf("start analytics: %w", err)
+		}
+	}
+
+	if s.selfTest != nil {
+		if err := s.selfTest.Start(runCtx); err != nil {
+			return fmt.Errorf("start self-test: %w", err)
+		}
+	}
+
+	if s.feedback != nil {
+		s.http.RegisterEventProcessor(s.feedback)
+		if err := s.feedback.Start(runCtx); err != nil {
+			return fmt.Errorf("start feedback box: %w", err)
+		}
+	}
+
+	if s.topicGuard != nil {
+		if err := s.topicGuard.Start(runCtx); err != nil {
+			return fmt.Errorf("start topic guard: %w", err)
+		}
+	}
+
+	if err := s.incident.Start(runCtx); err != nil {
+		return fmt.Errorf("start incident mode: %w", err)
+	}
+
+	if s.statusReply != nil {
+		s.http.RegisterEventProcessor(s.statusReply)
+		if err := s.statusReply.Start(runCtx); err != nil {
+			return fmt.Errorf("start status responder: %w", err)
+		}
+	}
+
+	// Announce in the background so a slow Slack API doesn't hold up serving events
+	if s.announcer != nil {
+		go s.announcer.Startup(runCtx, s.runningFeatures())
+	}
+
+	// Commands still work standalone without the socket, so failing to serve it isn't fatal
+	if err := s.control.Start(runCtx); err != nil {
+		s.log.Warn("Failed to serve CLI commands on the control socket", zap.Error(err))
+	}
+
+	if s.api != nil {
+		if err := s.api.Start(runCtx); err != nil {
+			return fmt.Errorf("start grpc api: %w", err)
+		}
+	}
+
+	// Started last, so workers read the stream once every processor is registered
+	if s.eventBus != nil {
+		if err := s.eventBus.Start(runCtx); err != nil {
+			return fmt.Errorf("start event bus: %w", err)
+		}
+	}
+
+	s.startReconciling(runCtx)
 	return s.http.Run(runCtx)
 }
 
@@ -275,11 +924,131 @@ func (s *Bot) BeginShutdown(ctx context.Context) error {
 // Shutdown resources in reverse order of the Setup/Run
 func (s *Bot) Shutdown(ctx context.Context) error {
 	var errs error
+	s.stopReconciling()
 	if s.http != nil {
 		if err := s.http.Shutdown(ctx); err != nil {
 			errs = errors.Join(errs, fmt.Errorf("shutdown http server: %w", err))
 		}
 	}
+	if s.api != nil {
+		if err := s.api.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop grpc api: %w", err))
+		}
+	}
+	if s.eventBus != nil {
+		if err := s.eventBus.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop event bus: %w", err))
+		}
+	}
+	// Released once events stop arriving, so a follower takes over without waiting out the TTL
+	if s.leader != nil {
+		if err := s.leader.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop leader election: %w", err))
+		}
+	}
+	if s.announcer != nil {
+		s.announcer.Shutdown(ctx, s.runningFeatures())
+	}
+	if s.control != nil {
+		if err := s.control.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop control socket: %w", err))
+		}
+	}
+	if s.statusReply != nil {
+		if err := s.statusReply.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop status responder: %w", err))
+		}
+	}
+	if s.incident != nil {
+		if err := s.incident.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop incident mode: %w", err))
+		}
+	}
+	if s.fileScan != nil {
+		if err := s.fileScan.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop file scanner: %w", err))
+		}
+	}
+	if s.outbox != nil {
+		if err := s.outbox.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop outbox: %w", err))
+		}
+	}
+	if s.handoff != nil {
+		if err := s.handoff.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop handoff desk: %w", err))
+		}
+	}
+	if s.broadcaster != nil {
+		if err := s.broadcaster.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop broadcaster: %w", err))
+		}
+	}
+	if s.facts != nil {
+		if err := s.facts.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop facts keeper: %w", err))
+		}
+	}
+	if s.retention != nil {
+		if err := s.retention.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop retention sweeps: %w", err))
+		}
+	}
+	if s.lastSeen != nil {
+		if err := s.lastSeen.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop last seen tracking: %w", err))
+		}
+	}
+	if s.karma != nil {
+		if err := s.karma.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop karma: %w", err))
+		}
+	}
+	if s.scheduler != nil {
+		if err := s.scheduler.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop scheduler: %w", err))
+		}
+	}
+	if s.explainer != nil {
+		if err := s.explainer.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop explainer: %w", err))
+		}
+	}
+	if s.personaStore != nil {
+		if err := s.personaStore.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop persona store: %w", err))
+		}
+	}
+	if s.selfTest != nil {
+		if err := s.selfTest.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop self-test: %w", err))
+		}
+	}
+	if s.analytics != nil {
+		if err := s.analytics.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop analytics: %w", err))
+		}
+	}
+	if s.feedback != nil {
+		if err := s.feedback.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop feedback box: %w", err))
+		}
+	}
+	if s.topicGuard != nil {
+		if err := s.topicGuard.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop topic guard: %w", err))
+		}
+	}
+	if s.backup != nil {
+		if err := s.backup.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop backups: %w", err))
+		}
+	}
+	if s.membership != nil {
+		if err := s.membership.Stop(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop membership watcher: %w", err))
+		}
+	}
 	if s.aichat != nil {
 		if err := s.aichat.Stop(ctx); err != nil {
 			return fmt.Errorf("stop aichat: %w", err)
@@ -305,6 +1074,16 @@ func (s *Bot) Shutdown(ctx context.Context) error {
 			errs = errors.Join(errs, fmt.Errorf("stop vibecheck: %w", err))
 		}
 	}
+	if s.tasks != nil {
+		if dropped := s.tasks.Stop(); dropped > 0 {
+			s.log.Info("Cancelled pending delayed tasks on shutdown", zap.Int("count", dropped))
+		}
+	}
+	if s.replies != nil {
+		if err := s.replies.Close(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("close reply log: %w", err))
+		}
+	}
 	if s.configManager != nil {
 		if err := s.configManager.Close(); err != nil {
 			errs = errors.Join(errs, fmt.Errorf("close config manager: %w", err))
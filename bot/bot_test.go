@@ -1,11 +1,36 @@
 package bot
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/slack-go/slack"
 	"github.com/urfave/cli/v3"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/chat"
 	"slackbot.arpa/bot/config"
+	"slackbot.arpa/bot/control"
+	"slackbot.arpa/bot/event"
+	bothttp "slackbot.arpa/bot/http"
+	"slackbot.arpa/bot/incident"
+	"slackbot.arpa/bot/personastore"
+	"slackbot.arpa/bot/scheduler"
+	botslack "slackbot.arpa/bot/slack"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/user"
+	"slackbot.arpa/bot/vibecheck"
+	"slackbot.arpa/logger"
 )
 
 func TestNewBot(t *testing.T) {
@@ -223,3 +248,487 @@ func BenchmarkBot_Setup(b *testing.B) {
 		_, _ = bot.Setup(ctx, cmd)
 	}
 }
+
+// eventPathSlack stands in for Slack on the event path: it accepts every request and
+// answers every API call like a successful chat.postMessage, signalling each post
+type eventPathSlack struct {
+	client *slack.Client
+	posted chan struct{}
+}
+
+func newEventPathSlack() *eventPathSlack {
+	s := &eventPathSlack{posted: make(chan struct{}, 1)}
+	s.client = slack.New("xoxb-test", slack.OptionHTTPClient(&http.Client{Transport: s}))
+	return s
+}
+
+func (s *eventPathSlack) VerifyRequest(http.Header, []byte) error { return nil }
+func (s *eventPathSlack) VerificationFailures() map[string]uint64 { return nil }
+func (s *eventPathSlack) Available() bool                         { return true }
+func (s *eventPathSlack) Client() *slack.Client                   { return s.client }
+
+func (s *eventPathSlack) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.posted <- struct{}{}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"ok": true, "channel": "C1", "ts": "2.0"}`)),
+		Request:    req,
+	}, nil
+}
+
+// BenchmarkEventPath measures an event from the body Slack sends to the reply: parsing,
+// dispatch to the processors, the chat feature's match and the (mocked) post
+func BenchmarkEventPath(b *testing.B) {
+	s := newEventPathSlack()
+	server := bothttp.NewServer(zap.NewNop(), bothttp.Config{}, s)
+	c := chat.NewChat(zap.NewNop(), chat.Config{Responses: []chat.Response{
+		{Pattern: "hello", Message: "hi"},
+		{Pattern: `good (morning|night)`, IsRegexp: true, Message: "to you too"},
+		{Pattern: `deploy\s+friday`, IsRegexp: true, Message: "bold move"},
+	}}, s)
+	server.RegisterEventProcessor(c)
+	ctx := context.Background()
+	if err := c.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = c.Stop(ctx) }()
+	body := []byte(`{"type": "event_callback", "event_id": "Ev1", "team_id": "T1", "event": {"type": "message",
+		"user": "U1", "channel": "C1", "channel_type": "channel", "text": "deploy friday", "ts": "1700000000.000100"}}`)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := server.DispatchEvent(body); err != nil {
+			b.Fatal(err)
+		}
+		select {
+		case <-s.posted:
+		case <-time.After(5 * time.Second):
+			b.Fatal("the reply was never posted")
+		}
+	}
+}
+
+func TestMessageArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := newMessageArchive(dir, "C123", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("newMessageArchive() error = %v", err)
+	}
+	if want := filepath.Join(dir, "archive", "delete-C123-20250102T030405Z.jsonl"); archive.path != want {
+		t.Errorf("archive path = %q, want %q", archive.path, want)
+	}
+
+	msgs := []slack.Message{
+		{Msg: slack.Msg{User: "UBOT", Timestamp: "1.000", Text: "hello"}},
+		{Msg: slack.Msg{BotID: "B1", Timestamp: "2.000", Text: "", Attachments: []slack.Attachment{{Title: "report"}}}},
+	}
+	for _, msg := range msgs {
+		if err := archive.Write("C123", msg); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(archive.path)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 archived messages, got %d", len(lines))
+	}
+	var record archivedMessage
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("Failed to decode archived message: %v", err)
+	}
+	if record.Channel != "C123" || record.TS != "2.000" || record.BotID != "B1" || len(record.Attachments) != 1 {
+		t.Errorf("Unexpected archived message: %+v", record)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{" YES \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"yep\n", false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := confirm(strings.NewReader(tt.input), &out, "Delete?"); got != tt.want {
+			t.Errorf("confirm(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+		if out.String() != "Delete? [y/N]: " {
+			t.Errorf("confirm() prompt = %q", out.String())
+		}
+	}
+}
+
+func TestProgressReporter(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Unix(0, 0)
+	p := newProgressReporter(&out, "Deleted", 4)
+	p.now = func() time.Time { return now }
+	p.start, p.lastReport = now, now
+
+	now = now.Add(time.Second)
+	p.Success() // Within the interval, no report
+	if out.Len() != 0 {
+		t.Errorf("Expected no report within the interval, got %q", out.String())
+	}
+
+	now = now.Add(9 * time.Second)
+	p.Failure("2.000", "message_not_found")
+	if want := "Deleted 1/4 (1 failed), ETA 10s\n"; out.String() != want {
+		t.Errorf("progress line = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	p.Failure("3.000", "message_not_found")
+	p.Success() // The final item always reports
+	if want := "Deleted 2/4 (2 failed)\n"; out.String() != want {
+		t.Errorf("final progress line = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	p.Summary()
+	want := "Deleted 2 of 4 in 10s\n2 failed:\n  message_not_found (2): 2.000, 3.000\n"
+	if out.String() != want {
+		t.Errorf("Summary() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteWhois(t *testing.T) {
+	var out bytes.Buffer
+	user := slack.User{
+		ID:       "U1",
+		Name:     "alice",
+		TZ:       "America/Denver",
+		TZLabel:  "Mountain Daylight Time",
+		TZOffset: -6 * 60 * 60,
+		IsAdmin:  true,
+		Profile:  slack.UserProfile{RealName: "Alice Smith", Email: "alice@example.com"},
+	}
+	writeWhois(&out, user, []slack.Channel{
+		{GroupConversation: slack.GroupConversation{Name: "general"}},
+	})
+
+	for _, want := range []string{
+		"Alice Smith (@alice) U1\n",
+		"alice@example.com",
+		"America/Denver (Mountain Daylight Time, UTC-06:00)",
+		"Role:            admin\n",
+		"#general",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Title:") {
+		t.Errorf("Empty fields should be omitted:\n%s", out.String())
+	}
+}
+
+func TestWriteVariantReport(t *testing.T) {
+	var out bytes.Buffer
+	writeVariantReport(&out, []chat.VariantReport{
+		{Pattern: "hello", Variant: "cheerful", VariantStats: chat.VariantStats{Sent: 4, Reactions: 6}},
+		{Pattern: "hello", Variant: "grumpy", VariantStats: chat.VariantStats{Sent: 2}},
+	})
+
+	want := "hello\n" +
+		"  cheerful         sent 4     reactions 6     per message 1.50\n" +
+		"  grumpy           sent 2     reactions 0     per message 0.00\n"
+	if out.String() != want {
+		t.Errorf("writeVariantReport() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriteRosterCSV(t *testing.T) {
+	var out bytes.Buffer
+	err := writeRosterCSV(&out, []user.RosterUser{
+		{ID: "U1", Name: "alice", RealName: "Smith, Alice", Title: "Engineer", Email: "alice@example.com", Known: true},
+		{ID: "U2", Name: "bob", Deleted: true},
+	})
+	if err != nil {
+		t.Fatalf("writeRosterCSV() error = %v", err)
+	}
+
+	want := "id,name,real_name,title,email,deleted,known\n" +
+		"U1,alice,\"Smith, Alice\",Engineer,alice@example.com,false,true\n" +
+		"U2,bob,,,,true,false\n"
+	if out.String() != want {
+		t.Errorf("writeRosterCSV() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriteSearchMatches(t *testing.T) {
+	var out bytes.Buffer
+	ts := time.Date(2025, 3, 7, 16, 30, 0, 0, time.Local)
+	writeSearchMatches(&out, []aichat.SearchMatch{{
+		ConversationContext: aichat.ConversationContext{UserID: "U1", ChannelID: "C1", PersonaName: "grumpy", Role: "assistant", Timestamp: ts},
+		Snippet:             "never [deploy] on a\n[friday]",
+	}})
+
+	want := "2025-03-07 16:30:00  assistant  C1  U1  grumpy: never [deploy] on a [friday]\n"
+	if out.String() != want {
+		t.Errorf("writeSearchMatches() =\n%q\nwant\n%q", out.String(), want)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	user := slack.User{
+		ID:      "U1",
+		Name:    "alice",
+		IsOwner: true,
+		Profile: slack.UserProfile{RealName: "Alice Smith"},
+	}
+	channels := []slack.Channel{{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "C1"}, Name: "general"}}}
+
+	var stdout, stderr bytes.Buffer
+	root := &cli.Command{
+		Name:      "test-app",
+		Writer:    &stdout,
+		ErrWriter: &stderr,
+		Flags:     []cli.Flag{&cli.StringFlag{Name: "output", Value: config.OutputText}},
+		Commands: []*cli.Command{{
+			Name: "whois",
+			Action: func(ctx context.Context, cmd *cli.Command) error {
+				_, _ = io.WriteString(messageWriter(cmd), "looking up user\n")
+				if !jsonOutput(cmd) {
+					writeWhois(cmd.Root().Writer, user, channels)
+					return nil
+				}
+				return writeJSON(cmd.Root().Writer, []whoisResult{newWhoisResult(user, channels)})
+			},
+		}},
+	}
+	if err := root.Run(context.Background(), []string{"test-app", "--output", "json", "whois"}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var results []whoisResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, stdout.String())
+	}
+	if len(results) != 1 || results[0].RealName != "Alice Smith" || results[0].Roles[0] != "owner" || results[0].SharedChannels[0] != "C1" {
+		t.Errorf("Unexpected result %+v", results)
+	}
+	if stderr.String() != "looking up user\n" {
+		t.Errorf("Notes should go to stderr with JSON output, got %q", stderr.String())
+	}
+}
+
+func TestBot_RemoteCommands(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	// The running bot runs forwarded commands with its own services
+	running := NewBot(config.BuildOpts{})
+	server := control.NewServer(zaptest.NewLogger(t), path, running.runCommand)
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Stop(ctx) })
+
+	client := NewBot(config.BuildOpts{})
+	client.remote = control.NewClient(path)
+	var stdout, stderr bytes.Buffer
+	root := &cli.Command{
+		Name:      "slackbot",
+		Writer:    &stdout,
+		ErrWriter: &stderr,
+		Flags:     []cli.Flag{config.OutputFlag()},
+		Commands:  Commands(client),
+	}
+
+	// The running bot validates flags and returns the error without exiting
+	err := root.Run(ctx, []string{"slackbot", "--output", "json", "whois", "--user", "U1", "--name", "alice"})
+	if err == nil || !strings.Contains(err.Error(), "exactly one of --user, --email or --name") {
+		t.Errorf("Expected the running bot's error, got %v", err)
+	}
+
+	// Message files and stdin are read before forwarding, and the running bot renders them
+	root.Reader = strings.NewReader("Release {{.version}}\n")
+	err = root.Run(ctx, []string{"slackbot", "send-message", "--message-file", "-", "--channels", "C1"})
+	if err == nil || !strings.Contains(err.Error(), `no entry for key "version"`) {
+		t.Errorf("Expected the running bot to render stdin's template, got %v", err)
+	}
+
+	// Once the bot stops, forwarded commands report that it can't be reached
+	if err := server.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err = root.Run(ctx, []string{"slackbot", "whois", "--user", "U1"})
+	if err == nil || !strings.Contains(err.Error(), "send command to running bot") {
+		t.Errorf("Expected a connection error, got %v", err)
+	}
+}
+
+func TestMessageTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "release.md")
+	if err := os.WriteFile(path, []byte("*{{.name}} {{.version}}*\n{{.notes}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	text, err := readMessage(strings.NewReader(""), "", path)
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+
+	// Commas split slice flags, so a piece without = belongs to the value before it
+	vars, err := parseVars([]string{"name=slackbot", "version=v1.2.0", "notes=Faster", " with fewer bugs"})
+	if err != nil {
+		t.Fatalf("parseVars() error = %v", err)
+	}
+	got, err := renderMessage(text, vars)
+	if err != nil {
+		t.Fatalf("renderMessage() error = %v", err)
+	}
+	if want := "*slackbot v1.2.0*\nFaster, with fewer bugs"; got != want {
+		t.Errorf("renderMessage() = %q, want %q", got, want)
+	}
+
+	if _, err := renderMessage(text, map[string]string{"name": "slackbot"}); err == nil {
+		t.Error("renderMessage() error = nil, want an error for the missing version")
+	}
+	if _, err := parseVars([]string{"oops"}); err == nil {
+		t.Error("parseVars() error = nil, want an error without key=value")
+	}
+	if _, err := readMessage(strings.NewReader(""), "hi", path); err == nil {
+		t.Error("readMessage() error = nil, want an error for both --message and --message-file")
+	}
+	if got, _ := readMessage(strings.NewReader("from stdin"), "", "-"); got != "from stdin" {
+		t.Errorf("readMessage(-) = %q, want stdin", got)
+	}
+}
+
+// reconcileConfig serves the config reconcileServices reads, changed between calls
+type reconcileConfig struct {
+	config.ConfigProvider
+	dataDir string
+	chat    chat.Config
+	file    config.FileConfig
+	sched   scheduler.Config
+}
+
+func (c *reconcileConfig) GetConfig() *config.Config  { return &config.Config{DataDir: c.dataDir} }
+func (c *reconcileConfig) GetChatConfig() chat.Config { return c.chat }
+func (c *reconcileConfig) GetVibecheckConfig() vibecheck.Config {
+	return vibecheck.Config{DataDir: c.dataDir}
+}
+func (c *reconcileConfig) GetFileConfig() *config.FileConfig      { return &c.file }
+func (c *reconcileConfig) GetAIChatConfig() aichat.Config         { return aichat.Config{} }
+func (c *reconcileConfig) GetPersonasConfig() personastore.Config { return personastore.Config{} }
+func (c *reconcileConfig) GetSchedulerConfig() scheduler.Config   { return c.sched }
+
+func TestBot_ReconcileServices(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &reconcileConfig{dataDir: dir}
+	s := &Bot{
+		logger:        logger.NewNoopLogger(),
+		log:           zap.NewNop(),
+		configManager: cfg,
+		slack:         botslack.NewSlack(zap.NewNop(), botslack.Config{}),
+		status:        status.NewRegistry(),
+		http:          bothttp.NewServer(zap.NewNop(), bothttp.Config{}, newEventPathSlack()),
+	}
+	s.incident = incident.New(zap.NewNop(), incident.Config{DataDir: dir}, s.slack)
+	processors := func() []string {
+		var names []string
+		for _, p := range s.http.Processors() {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+	ctx := context.Background()
+
+	// Nothing configured, nothing runs
+	s.startReconciling(ctx)
+	if s.chat != nil || s.vibecheck != nil || len(processors()) != 0 {
+		t.Fatalf("processors = %q, want none without config", processors())
+	}
+
+	cfg.chat = chat.Config{DataDir: dir, Responses: []chat.Response{{Pattern: "hello", Message: "hi"}}}
+	cfg.file.Vibecheck.GoodReactions = []string{"sunglasses"}
+	s.reconcileServices()
+	if !slices.Equal(processors(), []string{"chat", "vibecheck"}) || s.chat == nil || s.vibecheck == nil {
+		t.Fatalf("processors = %q, want chat and vibecheck started", processors())
+	}
+	if features := s.runningFeatures(); !slices.Equal(features, []string{"chat", "vibecheck"}) {
+		t.Errorf("runningFeatures() = %q", features)
+	}
+
+	// A changed response replaces chat in place and leaves vibecheck alone
+	started, vibes := s.chat, s.vibecheck
+	cfg.chat.Responses = append(cfg.chat.Responses, chat.Response{Pattern: "bye", Message: "later"})
+	s.reconcileServices()
+	if s.chat == started || s.vibecheck != vibes || !slices.Equal(processors(), []string{"chat", "vibecheck"}) {
+		t.Errorf("processors = %q, want chat replaced and vibecheck kept", processors())
+	}
+
+	// Schedules start the scheduler, which has no event processor
+	cfg.sched.Schedules = []scheduler.Schedule{{Name: "standup", Cron: "@daily", Channel: "C1", Message: "Hi"}}
+	s.reconcileServices()
+	if s.scheduler == nil || !slices.Equal(processors(), []string{"chat", "vibecheck"}) {
+		t.Errorf("scheduler = %v, processors = %q, want the scheduler started", s.scheduler, processors())
+	}
+
+	cfg.chat.Responses = nil
+	cfg.file.Vibecheck.GoodReactions = nil
+	cfg.sched.Schedules = nil
+	s.reconcileServices()
+	if s.scheduler != nil {
+		t.Error("scheduler is running, want it stopped when the schedules are removed")
+	}
+	if s.chat != nil || s.vibecheck != nil || len(processors()) != 0 {
+		t.Errorf("processors = %q, want chat and vibecheck stopped when their config is removed", processors())
+	}
+
+	// Once shutdown begins config changes are ignored
+	s.stopReconciling()
+	cfg.chat.Responses = []chat.Response{{Pattern: "hello", Message: "hi"}}
+	s.reconcileServices()
+	if s.chat != nil {
+		t.Error("reconcileServices() started chat after Shutdown began")
+	}
+	if s.replies != nil {
+		_ = s.replies.Close()
+	}
+}
+
+// recordingProcessor records the text of the events pushed to it
+type recordingProcessor struct {
+	texts []string
+}
+
+func (p *recordingProcessor) PushEvent(e event.Event) { p.texts = append(p.texts, e.Text) }
+func (p *recordingProcessor) ProcessorType() string   { return "chat" }
+
+func TestBot_HeldEvents(t *testing.T) {
+	s := &Bot{log: zap.NewNop(), http: bothttp.NewServer(zap.NewNop(), bothttp.Config{}, newEventPathSlack())}
+	prev, next := &recordingProcessor{}, &recordingProcessor{}
+	s.http.RegisterEventProcessor(prev)
+	body := []byte(`{"type": "event_callback", "event_id": "Ev1", "team_id": "T1", "event": {"type": "message",
+		"user": "U1", "channel": "C1", "channel_type": "channel", "text": "during restart", "ts": "1700000000.000100"}}`)
+
+	// Events arriving while the running processor stops wait for its replacement
+	held := s.holdEvents(prev)
+	if err := s.http.DispatchEvent(body); err != nil {
+		t.Fatal(err)
+	}
+	s.releaseEvents(held, next)
+	if len(prev.texts) != 0 || !slices.Equal(next.texts, []string{"during restart"}) {
+		t.Errorf("prev got %q, next got %q, want the held event passed to the replacement", prev.texts, next.texts)
+	}
+	if processors := s.http.Processors(); len(processors) != 1 {
+		t.Errorf("processors = %+v, want only the replacement", processors)
+	}
+}
@@ -0,0 +1,178 @@
+// Package canvas mirrors lists the bot maintains, like a channel's remembered facts, into
+// a canvas shared with the channel and bookmarked in it, so they can be read without
+// asking the bot. Canvas and bookmark IDs are kept in the data dir so each list keeps
+// updating the same canvas across restarts.
+package canvas
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+)
+
+const stateFile = "canvases.json"
+
+// goneCodes are Slack error codes meaning the canvas was deleted and should be recreated
+var goneCodes = []string{"canvas_not_found", "canvas_deleted", "file_not_found", "file_deleted"}
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+// Entry is the canvas mirroring one feature's list for a channel
+type Entry struct {
+	Feature    string `json:"feature"`
+	Channel    string `json:"channel"`
+	CanvasID   string `json:"canvas_id"`
+	BookmarkID string `json:"bookmark_id,omitempty"`
+}
+
+func (e Entry) key() string {
+	return e.Feature + "/" + e.Channel
+}
+
+// Mirror creates and updates canvases for feature lists
+type Mirror struct {
+	log      *zap.Logger
+	slack    slackService
+	path     string
+	mu       sync.Mutex
+	canvases map[string]Entry // feature/channel -> canvas
+}
+
+func New(log *zap.Logger, dataDir string, s slackService) *Mirror {
+	m := &Mirror{
+		log:      log,
+		slack:    s,
+		path:     filepath.Join(dataDir, stateFile),
+		canvases: make(map[string]Entry),
+	}
+	m.load()
+	return m
+}
+
+// Sync replaces the canvas for a feature's list in a channel with the markdown. The first
+// sync creates the canvas, shares it read-only with the channel and bookmarks it there
+// under the title, and a canvas someone deleted is created again.
+func (m *Mirror) Sync(ctx context.Context, feature, channelID, title, markdown string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	content := slack.DocumentContent{Type: "markdown", Markdown: markdown}
+	e, ok := m.canvases[feature+"/"+channelID]
+	if ok {
+		err := m.slack.Client().EditCanvasContext(ctx, slack.EditCanvasParams{
+			CanvasID: e.CanvasID,
+			Changes:  []slack.CanvasChange{{Operation: "replace", DocumentContent: content}},
+		})
+		if err == nil {
+			return nil
+		}
+		if apiErr := errs.NewSlackAPIError("canvases.edit", err); !slices.Contains(goneCodes, apiErr.Code) {
+			return apiErr
+		}
+		m.log.Info("Mirrored canvas was deleted, creating it again",
+			zap.String("feature", feature),
+			zap.String("channel", channelID),
+			zap.String("canvas", e.CanvasID))
+		if e.BookmarkID != "" {
+			// The bookmark links to the deleted canvas; it may be gone too
+			_ = m.slack.Client().RemoveBookmarkContext(ctx, channelID, e.BookmarkID)
+		}
+	}
+
+	e, err := m.create(ctx, feature, channelID, title, content)
+	if e.CanvasID != "" {
+		m.canvases[e.key()] = e
+		m.save()
+	}
+	return err
+}
+
+// create makes the canvas and shares it. An entry with a canvas ID is returned even when
+// bookmarking fails, so the next sync updates that canvas rather than creating another.
+func (m *Mirror) create(ctx context.Context, feature, channelID, title string, content slack.DocumentContent) (Entry, error) {
+	e := Entry{Feature: feature, Channel: channelID}
+	canvasID, err := m.slack.Client().CreateCanvasContext(ctx, title, content)
+	if err != nil {
+		return e, errs.NewSlackAPIError("canvases.create", err)
+	}
+	e.CanvasID = canvasID
+
+	if err := m.slack.Client().SetCanvasAccessContext(ctx, slack.SetCanvasAccessParams{
+		CanvasID:    canvasID,
+		AccessLevel: "read",
+		ChannelIDs:  []string{channelID},
+	}); err != nil {
+		return e, errs.NewSlackAPIError("canvases.access.set", err)
+	}
+
+	file, _, _, err := m.slack.Client().GetFileInfoContext(ctx, canvasID, 0, 0)
+	if err != nil {
+		return e, errs.NewSlackAPIError("files.info", err)
+	}
+	bookmark, err := m.slack.Client().AddBookmarkContext(ctx, channelID, slack.AddBookmarkParameters{
+		Title: title,
+		Type:  "link",
+		Link:  file.Permalink,
+	})
+	if err != nil {
+		return e, errs.NewSlackAPIError("bookmarks.add", err)
+	}
+	e.BookmarkID = bookmark.ID
+
+	m.log.Info("Created mirrored canvas",
+		zap.String("feature", feature),
+		zap.String("channel", channelID),
+		zap.String("canvas", canvasID))
+	return e, nil
+}
+
+func (m *Mirror) load() {
+	data, err := os.ReadFile(m.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		m.log.Error("Failed to read mirrored canvases", zap.Error(err), zap.String("path", m.path))
+		return
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		m.log.Error("Failed to unmarshal mirrored canvases", zap.Error(err), zap.String("path", m.path))
+		return
+	}
+	for _, e := range entries {
+		m.canvases[e.key()] = e
+	}
+}
+
+// save writes the canvas entries; callers must hold mu
+func (m *Mirror) save() {
+	entries := make([]Entry, 0, len(m.canvases))
+	for _, e := range m.canvases {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.key(), b.key()) })
+	data, err := json.Marshal(entries)
+	if err != nil {
+		m.log.Error("Failed to marshal mirrored canvases", zap.Error(err))
+		return
+	}
+	tempFile := m.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		m.log.Error("Failed to save mirrored canvases", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, m.path); err != nil {
+		m.log.Error("Failed to save mirrored canvases", zap.Error(&errs.StorageError{Op: "rename", Path: m.path, Err: err}))
+	}
+}
@@ -0,0 +1,78 @@
+package canvas
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestMirror_Sync(t *testing.T) {
+	var calls []string
+	canvasGone := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		calls = append(calls, method)
+		switch method {
+		case "canvases.create":
+			_, _ = w.Write([]byte(`{"ok": true, "canvas_id": "F1"}`))
+		case "files.info":
+			_, _ = w.Write([]byte(`{"ok": true, "file": {"id": "F1", "permalink": "https://example.slack.com/docs/T1/F1"}}`))
+		case "bookmarks.add":
+			if r.FormValue("link") != "https://example.slack.com/docs/T1/F1" || r.FormValue("channel_id") != "C1" {
+				t.Errorf("bookmarks.add form = %v, want the canvas bookmarked in the channel", r.Form)
+			}
+			_, _ = w.Write([]byte(`{"ok": true, "bookmark": {"id": "Bk1"}}`))
+		case "canvases.edit":
+			if canvasGone {
+				_, _ = w.Write([]byte(`{"ok": false, "error": "canvas_not_found"}`))
+				return
+			}
+			if !strings.Contains(r.FormValue("changes"), "second") {
+				t.Errorf("canvases.edit changes = %s, want the new list", r.FormValue("changes"))
+			}
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	s := slacktest.New(server.URL)
+	m := New(zap.NewNop(), dir, s)
+	ctx := context.Background()
+
+	if err := m.Sync(ctx, "facts", "C1", "Facts", "- first"); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	want := []string{"canvases.create", "canvases.access.set", "files.info", "bookmarks.add"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %v, want the canvas created, shared and bookmarked", calls)
+	}
+
+	// Later syncs edit the same canvas, even after a restart
+	calls = nil
+	if err := New(zap.NewNop(), dir, s).Sync(ctx, "facts", "C1", "Facts", "- second"); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if !slices.Equal(calls, []string{"canvases.edit"}) {
+		t.Errorf("calls = %v, want only the canvas edited", calls)
+	}
+
+	// A deleted canvas is created again and its stale bookmark removed
+	calls, canvasGone = nil, true
+	if err := m.Sync(ctx, "facts", "C1", "Facts", "- third"); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	want = append([]string{"canvases.edit", "bookmarks.remove"}, want...)
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want the canvas recreated", calls)
+	}
+}
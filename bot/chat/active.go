@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"slackbot.arpa/bot/scheduler"
+)
+
+// ParseActiveHours parses a response's active hours, e.g. "09:00-17:00", into the start
+// (inclusive) and end (exclusive) as time since midnight. An end before the start is an
+// overnight window, e.g. "22:00-02:00".
+func ParseActiveHours(s string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q isn't a range like 09:00-17:00", s)
+	}
+	if start, err = scheduler.ParseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = scheduler.ParseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("%q is empty", s)
+	}
+	return start, end, nil
+}
+
+// ParseActiveDays parses a response's active days, weekday names like "friday" or "fri"
+// and ranges like "mon-fri", into the set of weekdays
+func ParseActiveDays(days []string) (map[time.Weekday]bool, error) {
+	set := make(map[time.Weekday]bool)
+	for _, day := range days {
+		from, to, isRange := strings.Cut(day, "-")
+		first, ok := scheduler.ParseWeekday(from)
+		if !ok {
+			return nil, fmt.Errorf("%q isn't a weekday", day)
+		}
+		last := first
+		if isRange {
+			if last, ok = scheduler.ParseWeekday(to); !ok {
+				return nil, fmt.Errorf("%q isn't a weekday", day)
+			}
+		}
+		// A range can wrap past the end of the week, e.g. fri-mon
+		for d := first; ; d = (d + 1) % 7 {
+			set[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return set, nil
+}
+
+// activeWindow is a response's parsed active hours and days
+type activeWindow struct {
+	days       map[time.Weekday]bool // Nil is every day
+	start, end time.Duration         // Equal is all day
+	invalid    bool                  // Hours or days the config rejects, which never fire
+}
+
+// newActiveWindow parses a response's active hours and days
+func newActiveWindow(r Response) activeWindow {
+	var w activeWindow
+	var err error
+	if len(r.ActiveDays) > 0 {
+		if w.days, err = ParseActiveDays(r.ActiveDays); err != nil {
+			return activeWindow{invalid: true}
+		}
+	}
+	if r.ActiveHours != "" {
+		if w.start, w.end, err = ParseActiveHours(r.ActiveHours); err != nil {
+			return activeWindow{invalid: true}
+		}
+	}
+	return w
+}
+
+// activeWindows parses the active hours and days of each response, in order
+func activeWindows(responses []Response) []activeWindow {
+	windows := make([]activeWindow, len(responses))
+	for i, r := range responses {
+		windows[i] = newActiveWindow(r)
+	}
+	return windows
+}
+
+// active reports whether the response fires at now, which is in the workspace timezone
+func (w activeWindow) active(now time.Time) bool {
+	if w.invalid {
+		return false
+	}
+	if w.days != nil && !w.days[now.Weekday()] {
+		return false
+	}
+	if w.start == w.end {
+		return true
+	}
+	sinceMidnight := scheduler.SinceMidnight(now)
+	if w.start < w.end {
+		return sinceMidnight >= w.start && sinceMidnight < w.end
+	}
+	return sinceMidnight >= w.start || sinceMidnight < w.end
+}
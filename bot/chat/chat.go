@@ -3,7 +3,10 @@ package chat
 import (
 	"context"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -11,6 +14,13 @@ import (
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/retract"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/subtype"
+	"slackbot.arpa/tools/emoji"
 )
 
 const eventChannelSize = 100
@@ -23,21 +33,101 @@ type Response struct {
 	RandomMessages []string `json:"random_messages" yaml:"random_messages"` // Random messages to respond with
 	Reactions      []string `json:"reactions" yaml:"reactions"`             // Reactions to add to the message
 	IsRegexp       bool     `json:"is_regexp" yaml:"is_regexp"`             // Whether the pattern is a regular expression
+	// Variants are weighted alternatives to Message; the one sent is recorded along with
+	// the reactions it gets so variants can be compared
+	Variants []Variant `json:"variants" yaml:"variants"`
+	// File is uploaded with the reply: a path to a file on disk, or inline content posted
+	// as a snippet, e.g. a runbook or code sample
+	File     string `json:"file" yaml:"file"`
+	ImageURL string `json:"image_url" yaml:"image_url"` // Image posted as a block with the reply
+	// ActiveHours limits the response to a time of day in the workspace timezone, e.g.
+	// "09:00-17:00", or "22:00-02:00" overnight. Empty is all day.
+	ActiveHours string `json:"active_hours" yaml:"active_hours"`
+	// ActiveDays limits the response to weekdays, e.g. ["mon-fri"] or ["sat", "sun"].
+	// Empty is every day.
+	ActiveDays []string `json:"active_days" yaml:"active_days"`
+}
+
+// attachment returns the name and content of the response's file. A single line naming
+// an existing file is read from disk; anything else is inline snippet content.
+func (r Response) attachment() (string, string, error) {
+	if !strings.ContainsRune(r.File, '\n') {
+		data, err := os.ReadFile(r.File)
+		if err == nil {
+			return filepath.Base(r.File), string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", "", err
+		}
+	}
+	return "snippet.txt", r.File, nil
+}
+
+// silencer reports channels where fun features should stay quiet, e.g. during an incident
+type silencer interface {
+	Silenced(channelID string) bool
+}
+
+// outbox holds replies for a send delay so an operator can cancel them
+type outbox interface {
+	Send(ctx context.Context, feature, channelID, triggerTS, preview string, send func(ctx context.Context))
+}
+
+// handoffs reports threads handed over to people, where the bot stays quiet
+type handoffs interface {
+	Active(channelID, threadTS string) bool
+}
+
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
+// claimer limits how many features respond to one message
+type claimer interface {
+	Claim(ctx context.Context, feature, channelID, ts string) bool
+}
+
+// replyLog remembers the events already replied to, so an event Slack retries isn't
+// answered twice
+type replyLog interface {
+	Replied(feature, eventID string) (string, bool, error)
+	Record(feature, eventID, channelID, ts string) error
+}
+
+// tracer keeps what went into each reply, so an admin can have it explained
+type tracer interface {
+	Record(t explain.Trace)
 }
 
 type slackService interface {
 	Client() *slack.Client
+	Available() bool
 }
 
 // FileConfig represents the structure of the chat section in the config file
 type FileConfig struct {
-	Responses []Response `json:"responses" yaml:"responses"`
+	Responses []Response     `json:"responses" yaml:"responses"`
+	Persona   persona.Config `json:"persona" yaml:"persona"`
+	// ReactionThresholds respond to messages that collect enough of a reaction
+	ReactionThresholds []ReactionThreshold `json:"reaction_thresholds" yaml:"reaction_thresholds"`
+	// OnEdit removes the reactions and replies to a message edited so it no longer matches
+	OnEdit retract.FileConfig `json:"on_edit" yaml:"on_edit"`
+	// Timezone is the workspace's, which responses' active hours and days are in, e.g.
+	// America/Denver, defaults to the server's
+	Timezone *string `json:"timezone" yaml:"timezone"`
 }
 
 // Config defines the runtime configuration for the Chat feature
 type Config struct {
-	PreferredUsers []string
-	Responses      []Response
+	PreferredUsers     []string
+	Responses          []Response
+	DataDir            string
+	MessageSubtypes    []string       // Subtypes handled besides plain messages, defaults to subtype.DefaultAllowed
+	Persona            persona.Config // Name and icon responses are posted with
+	ReactionThresholds []ReactionThreshold
+	OnEdit             retract.Config // What's undone when a matched message is edited to no longer match
+	Timezone           string         // Responses' active hours and days are in, empty is the server's
 }
 
 // Chat handles responding to messages based on configured patterns
@@ -46,27 +136,104 @@ type Chat struct {
 	config      Config
 	slack       slackService
 	regexps     map[string]*regexp.Regexp
+	windows     []activeWindow // Active hours and days of each response
 	stopCh      chan struct{}
-	eventsCh    chan slackevents.EventsAPIEvent
+	eventsCh    chan event.Event
 	isConnected atomic.Bool
+	status      *status.Tracker
+	variants    *variantTracker
+	reactions   *reactionCounter
+	subtypes    *subtype.Filter
+	silencer    silencer
+	presence    presence
+	outbox      outbox
+	handoffs    handoffs
+	replies     replyLog
+	claims      claimer
+	edits       *retract.Index
+	tracer      tracer
+	location    *time.Location // Workspace timezone
+	now         func() time.Time
 }
 
 func NewChat(log *zap.Logger, c Config, s slackService) *Chat {
 	return &Chat{
-		log:      log,
-		config:   c,
-		regexps:  make(map[string]*regexp.Regexp),
-		stopCh:   make(chan struct{}),
-		eventsCh: make(chan slackevents.EventsAPIEvent, eventChannelSize),
-		slack:    s,
+		log:       log,
+		config:    c,
+		regexps:   make(map[string]*regexp.Regexp),
+		windows:   activeWindows(c.Responses),
+		stopCh:    make(chan struct{}),
+		eventsCh:  make(chan event.Event, eventChannelSize),
+		slack:     s,
+		variants:  newVariantTracker(log, c.DataDir),
+		reactions: newReactionCounter(log, c.DataDir),
+		subtypes:  subtype.NewFilter(c.MessageSubtypes),
+		edits:     retract.NewIndex(c.OnEdit, s),
+		location:  location(log, c.Timezone),
+		now:       time.Now,
 	}
 }
 
+// location loads the workspace timezone, validated by the config, falling back to the
+// server's
+func location(log *zap.Logger, timezone string) *time.Location {
+	if timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Error("Failed to load timezone, using the server's", zap.String("timezone", timezone), zap.Error(err))
+		return time.Local
+	}
+	return loc
+}
+
 // ProcessorType returns a description of the processor type
 func (c *Chat) ProcessorType() string {
 	return "chat"
 }
 
+// SetSilencer pauses the feature in channels the silencer reports as silenced
+func (c *Chat) SetSilencer(s silencer) {
+	c.silencer = s
+}
+
+// SetPresence skips channels the bot was removed from
+func (c *Chat) SetPresence(p presence) {
+	c.presence = p
+}
+
+// SetOutbox sends replies through the outbox, which holds them when a send delay is set
+func (c *Chat) SetOutbox(o outbox) {
+	c.outbox = o
+}
+
+// SetHandoffs keeps the feature out of threads handed over to people
+func (c *Chat) SetHandoffs(h handoffs) {
+	c.handoffs = h
+}
+
+// SetReplyLog skips events already replied to and records each reply as it's posted
+func (c *Chat) SetReplyLog(r replyLog) {
+	c.replies = r
+}
+
+// SetClaimer has the feature claim a message before replying to it, so other features
+// replying to the same message don't pile on
+func (c *Chat) SetClaimer(cl claimer) {
+	c.claims = cl
+}
+
+// SetTracer records what went into each reply, to explain it on request
+func (c *Chat) SetTracer(t tracer) {
+	c.tracer = t
+}
+
+// SetStatusTracker sets the tracker that records the feature's activity
+func (c *Chat) SetStatusTracker(t *status.Tracker) {
+	c.status = t
+}
+
 // Start initializes the Chat feature with a Slack slack
 func (c *Chat) Start(ctx context.Context) error {
 	c.isConnected.Store(true)
@@ -92,16 +259,17 @@ func (c *Chat) Stop(ctx context.Context) error {
 }
 
 // PushEvent adds an event to be processed by the Chat feature
-func (c *Chat) PushEvent(event slackevents.EventsAPIEvent) {
+func (c *Chat) PushEvent(e event.Event) {
 	if !c.isConnected.Load() {
 		return
 	}
 
 	select {
-	case c.eventsCh <- event:
+	case c.eventsCh <- e:
 		// Event pushed successfully
 	default:
 		c.log.Warn("Chat events channel full, dropping event.")
+		c.status.Dropped(e.Channel, status.DropQueueFull)
 	}
 }
 
@@ -113,30 +281,57 @@ func (c *Chat) handleEvents(ctx context.Context) {
 			return
 		case <-ctx.Done():
 			return
-		case event := <-c.eventsCh:
-			c.processEvent(ctx, event)
+		case e := <-c.eventsCh:
+			c.processEvent(e.Context(ctx), e)
 		}
 	}
 }
 
 // processEvent handles a single Slack event
-func (c *Chat) processEvent(ctx context.Context, event slackevents.EventsAPIEvent) {
-	switch event.Type {
-	case slackevents.CallbackEvent:
-		innerEvent := event.InnerEvent
-		switch ev := innerEvent.Data.(type) {
-		case *slackevents.MessageEvent:
-			// Ignore bot messages to prevent loops
-			if ev.BotID != "" || ev.User == "" {
-				return
-			}
-			c.handleMessageEvent(ctx, ev)
+func (c *Chat) processEvent(ctx context.Context, e event.Event) {
+	// Responses are non-essential; stay quiet while the Slack API is failing
+	if !c.slack.Available() {
+		c.log.Debug("Slack API unavailable, skipping event", zap.String("type", c.ProcessorType()))
+		return
+	}
+	c.status.Event()
+	switch ev := e.Data().(type) {
+	case *slackevents.MessageEvent:
+		if ev.SubType == "message_changed" {
+			c.handleEdit(ctx, ev)
+			return
+		}
+		// Ignore bot messages to prevent loops, and system subtypes like channel joins
+		if !c.subtypes.Allow(ev) {
+			return
+		}
+		if c.silencer != nil && c.silencer.Silenced(e.Channel) {
+			c.log.Debug("Channel silenced, skipping message", zap.String("channel", e.Channel))
+			return
+		}
+		if c.handoffs != nil && c.handoffs.Active(e.Channel, e.ThreadTS) {
+			c.log.Debug("Thread handed off, skipping message", zap.String("channel", e.Channel), zap.String("thread", e.ThreadTS))
+			return
 		}
+		if c.presence != nil && !c.presence.Present(ctx, e.Channel) {
+			c.log.Debug("Bot was removed from channel, skipping message", zap.String("channel", e.Channel))
+			return
+		}
+		if c.replied(e) {
+			return
+		}
+		c.handleMessageEvent(ctx, e.ID, ev)
+	case *slackevents.ReactionAddedEvent:
+		c.variants.Reacted(e.Channel, e.TS, 1)
+		c.handleReaction(ctx, e.Channel, e.TS, ev.Reaction, 1)
+	case *slackevents.ReactionRemovedEvent:
+		c.variants.Reacted(e.Channel, e.TS, -1)
+		c.handleReaction(ctx, e.Channel, e.TS, ev.Reaction, -1)
 	}
 }
 
 // handleMessageEvent processes a message event and responds if it matches a pattern
-func (c *Chat) handleMessageEvent(ctx context.Context, ev *slackevents.MessageEvent) {
+func (c *Chat) handleMessageEvent(ctx context.Context, eventID string, ev *slackevents.MessageEvent) {
 	message := strings.TrimSpace(ev.Text)
 
 	c.log.Debug("Processing message",
@@ -146,29 +341,14 @@ func (c *Chat) handleMessageEvent(ctx context.Context, ev *slackevents.MessageEv
 		zap.String("type", c.ProcessorType()),
 	)
 
+	now := c.now().In(c.location)
 	var messageReplied bool
-	for _, resp := range c.config.Responses {
-		var isMatch bool
-		if resp.IsRegexp {
-			re, exists := c.regexps[resp.Pattern]
-			if !exists {
-				rec, err := regexp.Compile("(?i)" + resp.Pattern)
-				if err != nil {
-					c.log.Error("Failed to compile regex pattern",
-						zap.String("pattern", resp.Pattern),
-						zap.Error(err),
-					)
-					continue
-				}
-				re = rec
-				c.regexps[resp.Pattern] = re
-			}
-			isMatch = re.MatchString(message)
-		} else {
-			isMatch = strings.EqualFold(message, resp.Pattern)
+	for i, resp := range c.config.Responses {
+		// Responses outside their active hours and days don't react or reply
+		if !c.windows[i].active(now) {
+			continue
 		}
-
-		if isMatch {
+		if c.matches(resp, message) {
 			c.log.Info("Message matched pattern",
 				zap.String("pattern", resp.Pattern),
 				zap.String("channel", ev.Channel),
@@ -178,51 +358,50 @@ func (c *Chat) handleMessageEvent(ctx context.Context, ev *slackevents.MessageEv
 				for _, reaction := range resp.Reactions {
 					err := c.slack.Client().AddReactionContext(
 						ctx,
-						reaction,
+						emoji.Name(reaction),
 						slack.NewRefToMessage(ev.Channel, ev.TimeStamp),
 					)
 					if err != nil {
+						c.status.PostFailed(ev.Channel, err)
 						c.log.Error("Failed to add reaction",
 							zap.String("channel", ev.Channel),
 							zap.String("user", ev.User),
 							zap.String("reaction", reaction),
 							zap.Error(err),
 						)
+						continue
 					}
+					c.edits.Reacted(ev.Channel, ev.TimeStamp, emoji.Name(reaction))
 				}
 			}
 
-			// Check if the message is already replied to, so we can still add all reactions from responses
-			if !messageReplied && resp.Message != "" {
-				messageReplied = true
-				messages := append([]string{resp.Message}, resp.RandomMessages...)
-				if len(resp.RandomMessages) > 0 {
-					messages = append([]string{randomString(resp.RandomMessages)}, messages...)
+			// Only the first matching response replies, so the rest can still add their
+			// reactions. Files and images go with the response that replies, or are the
+			// reply on their own.
+			if messageReplied {
+				continue
+			}
+			sendVariant := len(resp.Variants) > 0
+			sendMessages := !sendVariant && resp.Message != ""
+			sendAttachments := resp.File != "" || resp.ImageURL != ""
+			if !sendVariant && !sendMessages && !sendAttachments {
+				continue
+			}
+			messageReplied = true
+			if !c.claim(ctx, ev) {
+				continue
+			}
+			c.send(ctx, eventID, ev, resp.Message, func(ctx context.Context) {
+				if sendVariant {
+					c.postVariant(ctx, ev, resp)
 				}
-				baseMsgOptions := []slack.MsgOption{
-					slack.MsgOptionAsUser(true),
+				if sendMessages {
+					c.postMessages(ctx, ev, resp)
 				}
-				if ev.ThreadTimeStamp != "" {
-					baseMsgOptions = append(baseMsgOptions, slack.MsgOptionTS(ev.ThreadTimeStamp))
+				if sendAttachments {
+					c.postAttachments(ctx, ev, resp)
 				}
-				for _, msg := range messages {
-					if msg == "" {
-						continue
-					}
-					msgOptions := append(baseMsgOptions, slack.MsgOptionText(msg, false))
-					_, _, err := c.slack.Client().PostMessageContext(
-						ctx,
-						ev.Channel,
-						msgOptions...,
-					)
-					if err != nil {
-						c.log.Error("Failed to post response",
-							zap.String("channel", ev.Channel),
-							zap.Error(err),
-						)
-					}
-				}
-			}
+			})
 		}
 	}
 
@@ -233,12 +412,258 @@ func (c *Chat) handleMessageEvent(ctx context.Context, ev *slackevents.MessageEv
 	)
 }
 
+// matches reports whether the message matches the response's pattern
+func (c *Chat) matches(resp Response, message string) bool {
+	if !resp.IsRegexp {
+		return strings.EqualFold(message, resp.Pattern)
+	}
+	re, exists := c.regexps[resp.Pattern]
+	if !exists {
+		rec, err := regexp.Compile("(?i)" + resp.Pattern)
+		if err != nil {
+			c.log.Error("Failed to compile regex pattern",
+				zap.String("pattern", resp.Pattern),
+				zap.Error(err),
+			)
+			return false
+		}
+		re = rec
+		c.regexps[resp.Pattern] = re
+	}
+	return re.MatchString(message)
+}
+
+// handleEdit undoes the response to a message edited so no response matches it anymore
+func (c *Chat) handleEdit(ctx context.Context, ev *slackevents.MessageEvent) {
+	if ev.Message == nil || !c.edits.Responded(ev.Channel, ev.Message.Timestamp) {
+		return
+	}
+	message := strings.TrimSpace(ev.Message.Text)
+	for _, resp := range c.config.Responses {
+		if c.matches(resp, message) {
+			return
+		}
+	}
+	result, err := c.edits.Retract(ctx, ev.Channel, ev.Message.Timestamp)
+	if err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to retract response to edited message",
+			zap.String("channel", ev.Channel),
+			zap.String("ts", ev.Message.Timestamp),
+			zap.Error(err),
+		)
+	}
+	if result == (retract.Result{}) {
+		return
+	}
+	c.log.Info("Retracted response to edited message",
+		zap.String("channel", ev.Channel),
+		zap.String("ts", ev.Message.Timestamp),
+		zap.Int("reactions", result.Reactions),
+		zap.Int("replies", result.Replies),
+	)
+}
+
+// send posts a reply through the outbox when one is set, which holds it for the send delay
+func (c *Chat) send(ctx context.Context, eventID string, ev *slackevents.MessageEvent, preview string, post func(ctx context.Context)) {
+	// A reply can be several messages and uploads, so it's recorded without a timestamp
+	// once they've all been sent
+	send := func(ctx context.Context) {
+		post(ctx)
+		c.recordReply(eventID, ev.Channel)
+	}
+	if c.outbox == nil {
+		send(ctx)
+		return
+	}
+	c.outbox.Send(ctx, c.ProcessorType(), ev.Channel, ev.TimeStamp, preview, send)
+}
+
+// claim reports whether the feature may reply to the message
+func (c *Chat) claim(ctx context.Context, ev *slackevents.MessageEvent) bool {
+	if c.claims == nil || c.claims.Claim(ctx, c.ProcessorType(), ev.Channel, ev.TimeStamp) {
+		return true
+	}
+	c.status.Dropped(ev.Channel, status.DropClaimed)
+	return false
+}
+
+// replied reports whether the event was already answered, e.g. before a restart
+func (c *Chat) replied(e event.Event) bool {
+	if c.replies == nil || e.ID == "" {
+		return false
+	}
+	_, ok, err := c.replies.Replied(c.ProcessorType(), e.ID)
+	if err != nil {
+		// Answering twice is better than not answering at all
+		c.log.Warn("Failed to check reply log", zap.String("event_id", e.ID), zap.Error(err))
+		return false
+	}
+	if ok {
+		c.status.Dropped(e.Channel, status.DropReplied)
+		c.log.Info("Event already replied to, skipping", zap.String("event_id", e.ID), zap.String("channel", e.Channel))
+	}
+	return ok
+}
+
+func (c *Chat) recordReply(eventID, channelID string) {
+	if c.replies == nil || eventID == "" {
+		return
+	}
+	if err := c.replies.Record(c.ProcessorType(), eventID, channelID, ""); err != nil {
+		c.log.Warn("Failed to record reply", zap.String("event_id", eventID), zap.Error(err))
+	}
+}
+
+// postMessages posts the response's message, along with a random pick when it has
+// random messages
+func (c *Chat) postMessages(ctx context.Context, ev *slackevents.MessageEvent, resp Response) {
+	messages := append([]string{resp.Message}, resp.RandomMessages...)
+	if len(resp.RandomMessages) > 0 {
+		messages = append([]string{randomString(resp.RandomMessages)}, messages...)
+	}
+	baseMsgOptions := []slack.MsgOption{
+		c.config.Persona.MsgOption(),
+	}
+	if ev.ThreadTimeStamp != "" {
+		baseMsgOptions = append(baseMsgOptions, slack.MsgOptionTS(ev.ThreadTimeStamp))
+	}
+	for _, msg := range messages {
+		if msg == "" {
+			continue
+		}
+		msgOptions := append(baseMsgOptions, slack.MsgOptionText(msg, false))
+		_, ts, err := c.slack.Client().PostMessageContext(
+			ctx,
+			ev.Channel,
+			msgOptions...,
+		)
+		if err != nil {
+			c.status.PostFailed(ev.Channel, err)
+			c.log.Error("Failed to post response",
+				zap.String("channel", ev.Channel),
+				zap.Error(err),
+			)
+		} else {
+			c.status.Posted()
+			c.edits.Replied(ev.Channel, ev.TimeStamp, ts)
+			c.trace(ctx, ev, resp, ts, "")
+		}
+	}
+}
+
+// postVariant posts a weighted pick from the response's variants and records which was sent
+func (c *Chat) postVariant(ctx context.Context, ev *slackevents.MessageEvent, resp Response) {
+	variant, name := pickVariant(resp.Variants)
+	msgOptions := []slack.MsgOption{
+		c.config.Persona.MsgOption(),
+		slack.MsgOptionText(variant.Message, false),
+	}
+	if ev.ThreadTimeStamp != "" {
+		msgOptions = append(msgOptions, slack.MsgOptionTS(ev.ThreadTimeStamp))
+	}
+	channel, ts, err := c.slack.Client().PostMessageContext(ctx, ev.Channel, msgOptions...)
+	if err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to post response",
+			zap.String("channel", ev.Channel),
+			zap.String("variant", name),
+			zap.Error(err),
+		)
+		return
+	}
+	c.status.Posted()
+	c.edits.Replied(ev.Channel, ev.TimeStamp, ts)
+	c.variants.Sent(resp.Pattern, name, channel, ts, time.Now())
+	c.trace(ctx, ev, resp, ts, name)
+}
+
+// trace records the response that matched the message and the variant sent
+func (c *Chat) trace(ctx context.Context, ev *slackevents.MessageEvent, resp Response, ts, variant string) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.Record(explain.Trace{
+		Feature:   c.ProcessorType(),
+		Channel:   ev.Channel,
+		TS:        ts,
+		ThreadTS:  ev.ThreadTimeStamp,
+		TriggerTS: ev.TimeStamp,
+		EventID:   event.CorrelationID(ctx),
+		Trigger:   "matched " + strconv.Quote(resp.Pattern),
+		Variant:   variant,
+		Persona:   c.config.Persona.Username,
+	})
+}
+
+// postAttachments posts the response's image and uploads its file to the message's channel
+// or thread
+func (c *Chat) postAttachments(ctx context.Context, ev *slackevents.MessageEvent, resp Response) {
+	if resp.ImageURL != "" {
+		msgOptions := []slack.MsgOption{
+			c.config.Persona.MsgOption(),
+			slack.MsgOptionText(resp.ImageURL, false),
+			slack.MsgOptionBlocks(slack.NewImageBlock(resp.ImageURL, "image", "", nil)),
+		}
+		if ev.ThreadTimeStamp != "" {
+			msgOptions = append(msgOptions, slack.MsgOptionTS(ev.ThreadTimeStamp))
+		}
+		if _, ts, err := c.slack.Client().PostMessageContext(ctx, ev.Channel, msgOptions...); err != nil {
+			c.status.PostFailed(ev.Channel, err)
+			c.log.Error("Failed to post response image",
+				zap.String("channel", ev.Channel),
+				zap.String("image_url", resp.ImageURL),
+				zap.Error(err),
+			)
+		} else {
+			c.status.Posted()
+			c.edits.Replied(ev.Channel, ev.TimeStamp, ts)
+			c.trace(ctx, ev, resp, ts, "")
+		}
+	}
+
+	if resp.File == "" {
+		return
+	}
+	name, content, err := resp.attachment()
+	if err != nil {
+		c.status.Error(err)
+		c.log.Error("Failed to read response file", zap.String("pattern", resp.Pattern), zap.Error(err))
+		return
+	}
+	if content == "" {
+		c.log.Warn("Response file is empty, skipping upload", zap.String("pattern", resp.Pattern))
+		return
+	}
+	_, err = c.slack.Client().UploadFileContext(ctx, slack.UploadFileParameters{
+		Channel:         ev.Channel,
+		ThreadTimestamp: ev.ThreadTimeStamp,
+		Filename:        name,
+		Title:           name,
+		Content:         content,
+		FileSize:        len(content),
+	})
+	if err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to upload response file",
+			zap.String("channel", ev.Channel),
+			zap.String("file", name),
+			zap.Error(err),
+		)
+		return
+	}
+	c.status.Posted()
+}
+
 // SetConfig updates the chat configuration with values from the centralized config
 func (c *Chat) SetConfig(cfg Config) error {
 	c.log.Info("Updating chat configuration",
 		zap.Int("responses", len(cfg.Responses)))
 
 	c.config = cfg
+	c.edits = retract.NewIndex(cfg.OnEdit, c.slack)
+	c.location = location(c.log, cfg.Timezone)
+	c.windows = activeWindows(cfg.Responses)
 
 	c.regexps = make(map[string]*regexp.Regexp)
 	for _, resp := range c.config.Responses {
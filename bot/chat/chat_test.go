@@ -2,19 +2,36 @@ package chat
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/retract"
 )
 
 // mockSlackService for testing
 type mockSlackService struct {
-	client *slack.Client
+	client      *slack.Client
+	unavailable bool
 }
 
+func (m *mockSlackService) Available() bool { return !m.unavailable }
+
 func (m *mockSlackService) Client() *slack.Client {
 	if m.client == nil {
 		m.client = slack.New("test-token")
@@ -150,7 +167,7 @@ func TestChat_ProcessSlackEvent_AppMention(t *testing.T) {
 	defer func() { _ = chat.Stop(ctx) }()
 
 	// Create an app mention event
-	event := &slackevents.AppMentionEvent{
+	mention := &slackevents.AppMentionEvent{
 		Type:    "app_mention",
 		User:    "user1",
 		Text:    "<@bot> hello",
@@ -159,13 +176,7 @@ func TestChat_ProcessSlackEvent_AppMention(t *testing.T) {
 	}
 
 	// Use PushEvent instead of ProcessSlackEvent
-	chat.PushEvent(slackevents.EventsAPIEvent{
-		Type: slackevents.CallbackEvent,
-		InnerEvent: slackevents.EventsAPIInnerEvent{
-			Type: string(slackevents.AppMention),
-			Data: event,
-		},
-	})
+	chat.PushEvent(event.Callback(mention))
 
 	// Give some time for async processing
 	time.Sleep(10 * time.Millisecond)
@@ -182,15 +193,15 @@ func TestChat_PushEvent(t *testing.T) {
 	defer func() { _ = chat.Stop(ctx) }()
 
 	// Create a test event
-	event := slackevents.EventsAPIEvent{
+	e := event.New(slackevents.EventsAPIEvent{
 		Type: slackevents.CallbackEvent,
 		InnerEvent: slackevents.EventsAPIInnerEvent{
 			Type: string(slackevents.Message),
 		},
-	}
+	})
 
 	// Should not panic or error
-	chat.PushEvent(event)
+	chat.PushEvent(e)
 
 	// Give some time for async processing
 	time.Sleep(10 * time.Millisecond)
@@ -222,15 +233,492 @@ func BenchmarkChat_PushEvent(b *testing.B) {
 	_ = chat.Start(ctx)
 	defer func() { _ = chat.Stop(ctx) }()
 
-	event := slackevents.EventsAPIEvent{
+	e := event.New(slackevents.EventsAPIEvent{
 		Type: slackevents.CallbackEvent,
 		InnerEvent: slackevents.EventsAPIInnerEvent{
 			Type: string(slackevents.Message),
 		},
-	}
+	})
 
 	b.ResetTimer()
 	for b.Loop() {
-		chat.PushEvent(event)
+		chat.PushEvent(e)
+	}
+}
+
+func TestChat_ProcessEvent_SkipsWhileSlackUnavailable(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	config := Config{
+		Responses: []Response{{Pattern: "hello", Message: "hi"}},
+	}
+	mockSlack := &mockSlackService{unavailable: true}
+	chat := NewChat(logger, config, mockSlack)
+
+	e := event.Callback(&slackevents.MessageEvent{User: "U1", Channel: "C1", Text: "hello"})
+
+	// Client() creates the mock client lazily, so it stays nil when the event is skipped
+	chat.processEvent(context.Background(), e)
+	if mockSlack.client != nil {
+		t.Error("expected no Slack client calls while the API is unavailable")
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []Variant{{Message: "never", Weight: -1}, {Name: "always", Message: "hi"}}
+	for range 20 {
+		v, name := pickVariant(variants)
+		if name != "always" || v.Message != "hi" {
+			t.Fatalf("pickVariant() = %q, want the only positively weighted variant", name)
+		}
+	}
+	if _, name := pickVariant([]Variant{{Message: "a"}}); name != "1" {
+		t.Errorf("pickVariant() name = %q, want position when unnamed", name)
+	}
+}
+
+func TestVariantTracker(t *testing.T) {
+	dir := t.TempDir()
+	logger := zaptest.NewLogger(t)
+	tracker := newVariantTracker(logger, dir)
+	now := time.Now()
+
+	tracker.Sent("hello", "a", "C1", "1.1", now.Add(-8*24*time.Hour))
+	tracker.Sent("hello", "a", "C1", "1.2", now)
+	tracker.Sent("hello", "b", "C1", "1.3", now)
+	tracker.Reacted("C1", "1.1", 1) // pruned, outside the attribution window
+	tracker.Reacted("C1", "1.3", 1)
+	tracker.Reacted("C1", "1.3", 1)
+	tracker.Reacted("C1", "1.3", -1)
+	tracker.Reacted("C1", "9.9", 1) // not a variant
+
+	report, err := LoadVariantReport(dir)
+	if err != nil {
+		t.Fatalf("LoadVariantReport() error = %v", err)
+	}
+	want := []VariantReport{
+		{Pattern: "hello", Variant: "b", VariantStats: VariantStats{Sent: 1, Reactions: 1}},
+		{Pattern: "hello", Variant: "a", VariantStats: VariantStats{Sent: 2}},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("LoadVariantReport() = %+v, want %+v", report, want)
+	}
+	for i := range want {
+		if report[i] != want[i] {
+			t.Errorf("report[%d] = %+v, want %+v", i, report[i], want[i])
+		}
+	}
+
+	if empty, err := LoadVariantReport(t.TempDir()); err != nil || len(empty) != 0 {
+		t.Errorf("LoadVariantReport() without stats = %v, %v, want empty", empty, err)
+	}
+}
+
+type fakeModel struct {
+	reply  string
+	prompt string
+}
+
+func (m *fakeModel) GenerateContent(_ context.Context, messages []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.prompt = messages[len(messages)-1].Parts[0].(llms.TextContent).Text
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.reply}}}, nil
+}
+
+func (m *fakeModel) Call(ctx context.Context, prompt string, _ ...llms.CallOption) (string, error) {
+	return m.reply, nil
+}
+
+func TestSuggestResponses(t *testing.T) {
+	model := &fakeModel{reply: "```json\n[" +
+		`{"pattern": "hello", "message": "dupe"},` +
+		`{"pattern": "(unclosed", "is_regexp": true, "message": "bad regexp"},` +
+		`{"pattern": "standup", "message": ""},` +
+		`{"pattern": "\\bmonday\\b", "is_regexp": true, "message": "Ugh, Mondays", "reactions": [":coffee:"], "reason": "Mondays come up\nweekly"}` +
+		"]\n```"}
+
+	suggestions, err := SuggestResponses(context.Background(), model, []string{"happy monday", "another monday"}, []Response{{Pattern: "Hello"}})
+	if err != nil {
+		t.Fatalf("SuggestResponses() error = %v", err)
+	}
+	if !strings.Contains(model.prompt, "- Hello") || !strings.Contains(model.prompt, "- happy monday") {
+		t.Errorf("prompt should list existing patterns and messages:\n%s", model.prompt)
+	}
+	if len(suggestions) != 1 || suggestions[0].Pattern != `\bmonday\b` || suggestions[0].Reactions[0] != "coffee" {
+		t.Fatalf("SuggestResponses() = %+v, want only the valid new pattern", suggestions)
+	}
+
+	formatted, err := FormatSuggestions(suggestions)
+	if err != nil {
+		t.Fatalf("FormatSuggestions() error = %v", err)
 	}
-}
\ No newline at end of file
+	want := "chat:\n  responses:\n" +
+		"    # Mondays come up weekly\n" +
+		"    - pattern: \"\\\\bmonday\\\\b\"\n" +
+		"      is_regexp: true\n" +
+		"      message: Ugh, Mondays\n" +
+		"      reactions: [coffee]\n"
+	if formatted != want {
+		t.Errorf("FormatSuggestions() =\n%s\nwant\n%s", formatted, want)
+	}
+}
+
+func TestChat_PostsFileAndImage(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		calls    []string
+		uploaded []string
+	)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseMultipartForm(1 << 20)
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/files.getUploadURLExternal":
+			uploaded = append(uploaded, r.FormValue("filename"))
+			_, _ = w.Write([]byte(`{"ok": true, "upload_url": "` + srv.URL + `/upload", "file_id": "F1"}`))
+		case "/upload":
+			w.WriteHeader(http.StatusOK)
+		case "/files.completeUploadExternal":
+			_, _ = w.Write([]byte(`{"ok": true, "files": [{"id": "F1", "title": "runbook.md"}]}`))
+		default:
+			if blocks := r.FormValue("blocks"); blocks != "" && !strings.Contains(blocks, "https://example.com/meme.png") {
+				t.Errorf("Unexpected blocks %s", blocks)
+			}
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.0"}`))
+		}
+	}))
+	defer srv.Close()
+
+	runbook := filepath.Join(t.TempDir(), "runbook.md")
+	if err := os.WriteFile(runbook, []byte("1. Restart it"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mockSlack := &mockSlackService{client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	chat := NewChat(zaptest.NewLogger(t), Config{Responses: []Response{
+		{Pattern: "meme", ImageURL: "https://example.com/meme.png"},
+		{Pattern: "runbook", Message: "Here you go", File: runbook},
+		{Pattern: "snippet", File: "func main() {\n}\n"},
+	}}, mockSlack)
+
+	for _, text := range []string{"meme", "runbook", "snippet"} {
+		chat.handleMessageEvent(context.Background(), "", &slackevents.MessageEvent{Channel: "C1", User: "U1", Text: text})
+	}
+
+	if want := []string{"runbook.md", "snippet.txt"}; !slices.Equal(uploaded, want) {
+		t.Errorf("Uploaded %v, want %v", uploaded, want)
+	}
+	var posts int
+	for _, call := range calls {
+		if call == "/chat.postMessage" {
+			posts++
+		}
+	}
+	if posts != 2 {
+		t.Errorf("Posted %d messages, want the image and the runbook message: %v", posts, calls)
+	}
+}
+
+type mockReplyLog map[string]string
+
+func (m mockReplyLog) Replied(feature, eventID string) (string, bool, error) {
+	ts, ok := m[feature+"/"+eventID]
+	return ts, ok, nil
+}
+
+func (m mockReplyLog) Record(feature, eventID, channelID, ts string) error {
+	m[feature+"/"+eventID] = ts
+	return nil
+}
+
+func TestChat_SkipsEventsAlreadyReplied(t *testing.T) {
+	var posts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.0"}`))
+	}))
+	defer srv.Close()
+
+	mockSlack := &mockSlackService{client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	chat := NewChat(zaptest.NewLogger(t), Config{Responses: []Response{{Pattern: "hello", Message: "world"}}}, mockSlack)
+	replies := mockReplyLog{}
+	chat.SetReplyLog(replies)
+
+	e := event.Callback(&slackevents.MessageEvent{Type: "message", Channel: "C1", User: "U1", Text: "hello", TimeStamp: "1.0"})
+	e.ID = "Ev1"
+	chat.processEvent(context.Background(), e)
+	if _, ok := replies["chat/Ev1"]; !ok || posts != 1 {
+		t.Fatalf("posts = %d, reply log = %v, want the reply posted and recorded", posts, replies)
+	}
+
+	// Slack retries the event after a restart
+	chat.processEvent(context.Background(), e)
+	if posts != 1 {
+		t.Errorf("posts = %d, want the retried event skipped", posts)
+	}
+}
+
+func TestChat_ReactionThresholds(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		calls = append(calls, r.URL.Path+" "+r.FormValue("channel")+" "+r.FormValue("thread_ts"))
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "chat.getPermalink") {
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C01234567", "permalink": "https://example.slack.com/archives/C01234567/p1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C01234567", "ts": "2.0"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	config := Config{DataDir: dir, ReactionThresholds: []ReactionThreshold{
+		{Reaction: "fire", Count: 2, Channels: []string{"C01234567"}, Message: "🔥 on fire", Crosspost: "C0000FAME"},
+	}}
+	mockSlack := &mockSlackService{client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	ts := strconv.FormatInt(time.Now().Unix(), 10) + ".000100"
+	react := func(c *Chat, reaction string) {
+		c.processEvent(context.Background(), event.Callback(&slackevents.ReactionAddedEvent{
+			Type: "reaction_added", User: "U1", Reaction: reaction,
+			Item: slackevents.Item{Type: "message", Channel: "C01234567", Timestamp: ts},
+		}))
+	}
+
+	chat := NewChat(zaptest.NewLogger(t), config, mockSlack)
+	react(chat, "fire::skin-tone-2")
+	react(chat, "tada")
+	if len(calls) != 0 {
+		t.Fatalf("calls = %v, want nothing posted below the threshold", calls)
+	}
+
+	// Counts survive a restart
+	chat = NewChat(zaptest.NewLogger(t), config, mockSlack)
+	react(chat, "fire")
+	want := []string{
+		"/chat.postMessage C01234567 " + ts,
+		"/chat.getPermalink C01234567 ",
+		"/chat.postMessage C0000FAME ",
+	}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %v, want a thread reply and a crosspost", calls)
+	}
+
+	// Rules fire once per message
+	react(chat, "fire")
+	if len(calls) != len(want) {
+		t.Errorf("calls = %v, want the rule to fire once", calls)
+	}
+}
+
+func TestChat_RetractsOnEdit(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		calls = append(calls, r.URL.Path+" "+r.PostForm.Get("name")+r.PostForm.Get("timestamp")+r.PostForm.Get("ts"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "2.0"}`))
+	}))
+	defer srv.Close()
+
+	mockSlack := &mockSlackService{client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	responses := []Response{{Pattern: "hello", Message: "world", Reactions: []string{"wave"}}, {Pattern: "hi", Message: "there"}}
+	chat := NewChat(zaptest.NewLogger(t), Config{Responses: responses, OnEdit: retract.Config{Reactions: true, Messages: true}}, mockSlack)
+	edit := func(text string) event.Event {
+		return event.Callback(&slackevents.MessageEvent{Type: "message", SubType: "message_changed", Channel: "C1", Message: &slack.Msg{User: "U1", Text: text, Timestamp: "1.0"}})
+	}
+
+	chat.processEvent(context.Background(), event.Callback(&slackevents.MessageEvent{Type: "message", Channel: "C1", User: "U1", Text: "hello", TimeStamp: "1.0"}))
+	// Still matches, so nothing's undone
+	chat.processEvent(context.Background(), edit("HELLO"))
+	chat.processEvent(context.Background(), edit("hi"))
+	calls = calls[:0]
+	chat.processEvent(context.Background(), edit("goodbye"))
+	if !slices.Equal(calls, []string{"/reactions.remove wave1.0", "/chat.delete 2.0"}) {
+		t.Errorf("calls = %q, want the reaction removed and the reply deleted", calls)
+	}
+
+	// The response is forgotten once retracted
+	calls = calls[:0]
+	chat.processEvent(context.Background(), edit("still goodbye"))
+	if len(calls) != 0 {
+		t.Errorf("calls = %q, want nothing undone twice", calls)
+	}
+}
+
+// postTransport answers every Slack call like a successful chat.postMessage without a
+// network round trip, so the event path is measured rather than the loopback
+type postTransport struct {
+	posts int
+}
+
+func (p *postTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.posts++
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"ok": true, "channel": "C1", "ts": "2.0"}`)),
+		Request:    req,
+	}, nil
+}
+
+// newEventPathChat returns a chat with a few responses where only the last matches
+// "deploy friday", the way a real config is mostly misses
+func newEventPathChat(tb testing.TB) (*Chat, *postTransport) {
+	tb.Helper()
+	transport := &postTransport{}
+	client := slack.New("xoxb-test", slack.OptionHTTPClient(&http.Client{Transport: transport}))
+	chat := NewChat(zap.NewNop(), Config{Responses: []Response{
+		{Pattern: "hello", Message: "hi"},
+		{Pattern: `\bvibes?\b`, IsRegexp: true, Reactions: []string{"sparkles"}},
+		{Pattern: `good (morning|night)`, IsRegexp: true, Message: "to you too"},
+		{Pattern: `deploy\s+friday`, IsRegexp: true, Message: "bold move"},
+	}}, &mockSlackService{client: client})
+	return chat, transport
+}
+
+func eventPathMessage() event.Event {
+	return event.Callback(&slackevents.MessageEvent{Type: "message", User: "U1", Channel: "C1", Text: "deploy friday", TimeStamp: "1.0"})
+}
+
+func BenchmarkChat_ProcessEvent(b *testing.B) {
+	chat, transport := newEventPathChat(b)
+	e := eventPathMessage()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		chat.processEvent(ctx, e)
+	}
+	if transport.posts == 0 {
+		b.Fatal("the matched response was never posted")
+	}
+}
+
+func BenchmarkChat_ProcessEvent_NoMatch(b *testing.B) {
+	chat, transport := newEventPathChat(b)
+	e := event.Callback(&slackevents.MessageEvent{Type: "message", User: "U1", Channel: "C1", Text: "lunch at noon?", TimeStamp: "1.0"})
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		chat.processEvent(ctx, e)
+	}
+	if transport.posts != 0 {
+		b.Fatal("a message matching nothing was answered")
+	}
+}
+
+// Allocation budgets for processing a message, which catch regressions on the hot path
+// with headroom for changes in slack-go. Raise them deliberately when a feature needs the
+// allocations; measure with go test -bench ProcessEvent -benchmem.
+const (
+	missAllocBudget  = 12 // Measured at 6, and 8 with -race
+	replyAllocBudget = 80 // Measured at 57, and 61 with -race, mostly the Slack call
+)
+
+func TestChat_ProcessEventAllocs(t *testing.T) {
+	chat, transport := newEventPathChat(t)
+	ctx := context.Background()
+	e := eventPathMessage()
+	miss := event.Callback(&slackevents.MessageEvent{Type: "message", User: "U1", Channel: "C1", Text: "lunch at noon?", TimeStamp: "1.0"})
+
+	if allocs := testing.AllocsPerRun(100, func() { chat.processEvent(ctx, miss) }); allocs > missAllocBudget {
+		t.Errorf("processEvent() without a match = %.0f allocs, budget %d", allocs, missAllocBudget)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { chat.processEvent(ctx, e) }); allocs > replyAllocBudget {
+		t.Errorf("processEvent() with a reply = %.0f allocs, budget %d", allocs, replyAllocBudget)
+	}
+	if transport.posts == 0 {
+		t.Fatal("the matched response was never posted")
+	}
+}
+
+func TestChat_TracesReplies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "2.0"}`))
+	}))
+	defer srv.Close()
+
+	mockSlack := &mockSlackService{client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	chat := NewChat(zaptest.NewLogger(t), Config{Responses: []Response{{Pattern: "hello", Message: "world"}}}, mockSlack)
+	traces := explain.New(zap.NewNop(), explain.Config{}, nil)
+	chat.SetTracer(traces)
+
+	chat.processEvent(context.Background(), event.Callback(&slackevents.MessageEvent{Type: "message", Channel: "C1", User: "U1", Text: "hello", TimeStamp: "1.0"}))
+	trace, ok := traces.Lookup("C1", "2.0")
+	if !ok || trace.Feature != "chat" || trace.Trigger != `matched "hello"` || trace.TriggerTS != "1.0" {
+		t.Errorf("trace = %+v, %v, want the reply traced to the pattern it matched", trace, ok)
+	}
+}
+
+func TestChat_ActiveHours(t *testing.T) {
+	var posts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts = append(posts, r.FormValue("text"))
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "2.0"}`))
+	}))
+	defer srv.Close()
+
+	mockSlack := &mockSlackService{client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	chat := NewChat(zaptest.NewLogger(t), Config{
+		Timezone: "America/Denver",
+		Responses: []Response{
+			{Pattern: "deploy", Message: "ship it", ActiveHours: "09:00-17:00", ActiveDays: []string{"mon-fri"}},
+			{Pattern: "deploy", Message: "go home"},
+			{Pattern: "late", Message: "night owl", ActiveHours: "22:00-02:00"},
+		},
+	}, mockSlack)
+	denver, _ := time.LoadLocation("America/Denver")
+
+	for _, tt := range []struct {
+		name, text string
+		at         time.Time
+		want       string
+	}{
+		{"work hours", "deploy", time.Date(2026, 10, 14, 10, 0, 0, 0, denver), "ship it"},
+		{"evening", "deploy", time.Date(2026, 10, 14, 17, 0, 0, 0, denver), "go home"},
+		{"weekend", "deploy", time.Date(2026, 10, 17, 10, 0, 0, 0, denver), "go home"},
+		{"work hours in UTC", "deploy", time.Date(2026, 10, 14, 16, 30, 0, 0, time.UTC), "ship it"},
+		{"overnight", "late", time.Date(2026, 10, 14, 1, 59, 0, 0, denver), "night owl"},
+		{"after overnight", "late", time.Date(2026, 10, 14, 2, 0, 0, 0, denver), ""},
+	} {
+		posts = nil
+		chat.now = func() time.Time { return tt.at }
+		chat.processEvent(context.Background(), event.Callback(&slackevents.MessageEvent{Type: "message", Channel: "C1", User: "U1", Text: tt.text, TimeStamp: "1.0"}))
+		var want []string
+		if tt.want != "" {
+			want = []string{tt.want}
+		}
+		if !slices.Equal(posts, want) {
+			t.Errorf("%s: posts = %q, want %q", tt.name, posts, want)
+		}
+	}
+}
+
+func TestParseActiveDays(t *testing.T) {
+	days, err := ParseActiveDays([]string{"fri-mon", "Wednesday"})
+	if err != nil {
+		t.Fatalf("ParseActiveDays() error = %v", err)
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		want := d == time.Friday || d == time.Saturday || d == time.Sunday || d == time.Monday || d == time.Wednesday
+		if days[d] != want {
+			t.Errorf("days[%s] = %v, want %v", d, days[d], want)
+		}
+	}
+	if _, err := ParseActiveDays([]string{"mon-funday"}); err == nil {
+		t.Error("ParseActiveDays(mon-funday) should fail")
+	}
+	for _, hours := range []string{"09:00", "9am-5pm", "09:00-09:00"} {
+		if _, _, err := ParseActiveHours(hours); err == nil {
+			t.Errorf("ParseActiveHours(%q) should fail", hours)
+		}
+	}
+}
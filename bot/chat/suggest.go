@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/tmc/langchaingo/llms"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/tools/emoji"
+)
+
+const (
+	maxSuggestSamples    = 300 // Most recent messages sent to the model
+	maxSuggestSampleLen  = 300 // Characters kept from each message
+	defaultSuggestPrompt = `You help configure a Slack bot that replies to messages matching a pattern. ` +
+		`Given recent channel messages, propose up to 10 new pattern/response pairs for phrases that recur ` +
+		`and would be fun or useful to answer. Prefer short case-insensitive regular expressions with word ` +
+		`boundaries. Don't repeat the existing patterns. Reply with only a JSON array of objects with the ` +
+		`fields "pattern", "is_regexp", "message", "reactions" (emoji names without colons) and "reason".`
+)
+
+// Suggestion is a proposed chat response, written in the config file's format
+type Suggestion struct {
+	Reason    string   `json:"reason" yaml:"-"`
+	Pattern   string   `json:"pattern" yaml:"pattern"`
+	IsRegexp  bool     `json:"is_regexp" yaml:"is_regexp,omitempty"`
+	Message   string   `json:"message" yaml:"message,omitempty"`
+	Reactions []string `json:"reactions" yaml:"reactions,omitempty,flow"`
+}
+
+// SuggestResponses asks the model for new responses based on sample messages. Suggestions
+// with invalid or already configured patterns, or nothing to respond with, are dropped.
+func SuggestResponses(ctx context.Context, model llms.Model, samples []string, existing []Response) ([]Suggestion, error) {
+	if len(samples) > maxSuggestSamples {
+		samples = samples[len(samples)-maxSuggestSamples:]
+	}
+	var prompt strings.Builder
+	if len(existing) > 0 {
+		prompt.WriteString("Existing patterns:\n")
+		for _, r := range existing {
+			fmt.Fprintf(&prompt, "- %s\n", r.Pattern)
+		}
+		prompt.WriteString("\n")
+	}
+	prompt.WriteString("Recent messages:\n")
+	for _, s := range samples {
+		s = strings.Join(strings.Fields(s), " ")
+		if r := []rune(s); len(r) > maxSuggestSampleLen {
+			s = string(r[:maxSuggestSampleLen])
+		}
+		fmt.Fprintf(&prompt, "- %s\n", s)
+	}
+
+	resp, err := model.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, defaultSuggestPrompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt.String()),
+	}, llms.WithTemperature(0.7))
+	if err != nil {
+		return nil, errs.NewLLMError("suggest chat responses", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from LLM")
+	}
+	return parseSuggestions(resp.Choices[0].Content, existing)
+}
+
+// parseSuggestions reads the model's JSON answer, tolerating a surrounding code fence
+func parseSuggestions(content string, existing []Response) ([]Suggestion, error) {
+	content = strings.TrimSpace(content)
+	if start, end := strings.Index(content, "["), strings.LastIndex(content, "]"); start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+	var candidates []Suggestion
+	if err := json.Unmarshal([]byte(content), &candidates); err != nil {
+		return nil, fmt.Errorf("parse suggestions: %w", err)
+	}
+
+	seen := make(map[string]bool, len(existing))
+	for _, r := range existing {
+		seen[strings.ToLower(r.Pattern)] = true
+	}
+	var suggestions []Suggestion
+	for _, s := range candidates {
+		s.Pattern = strings.TrimSpace(s.Pattern)
+		if s.Pattern == "" || seen[strings.ToLower(s.Pattern)] || (s.Message == "" && len(s.Reactions) == 0) {
+			continue
+		}
+		if s.IsRegexp {
+			if _, err := regexp.Compile("(?i)" + s.Pattern); err != nil {
+				continue
+			}
+		}
+		var reactions []string
+		for _, r := range s.Reactions {
+			if emoji.Valid(r) {
+				reactions = append(reactions, emoji.Name(r))
+			}
+		}
+		s.Reactions = reactions
+		seen[strings.ToLower(s.Pattern)] = true
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, nil
+}
+
+// FormatSuggestions renders suggestions as a chat config section with each reason as a
+// comment, ready to review and paste into config.yaml
+func FormatSuggestions(suggestions []Suggestion) (string, error) {
+	var b strings.Builder
+	b.WriteString("chat:\n  responses:\n")
+	for _, s := range suggestions {
+		out, err := yaml.MarshalWithOptions([]Suggestion{s}, yaml.Indent(2))
+		if err != nil {
+			return "", fmt.Errorf("marshal suggestion: %w", err)
+		}
+		if s.Reason != "" {
+			fmt.Fprintf(&b, "    # %s\n", strings.Join(strings.Fields(s.Reason), " "))
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+			b.WriteString("    " + line + "\n")
+		}
+	}
+	return b.String(), nil
+}
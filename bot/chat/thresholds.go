@@ -0,0 +1,199 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/tools/emoji"
+)
+
+const (
+	reactionCountsFile = "chat_reactions.json"
+	// reactionCountWindow is how long reactions to a message are counted. Older messages
+	// are forgotten, so a late pile-on doesn't fire a rule.
+	reactionCountWindow = 7 * 24 * time.Hour
+)
+
+// ReactionThreshold responds once a message collects enough of one reaction, e.g. posting
+// it to #hall-of-fame at five :fire:
+type ReactionThreshold struct {
+	Reaction string   `json:"reaction" yaml:"reaction"` // Emoji name, skin tones count as the base emoji
+	Count    int      `json:"count" yaml:"count"`       // Reactions needed, at least 1
+	Channels []string `json:"channels" yaml:"channels"` // Channels watched, empty watches every channel
+	// Message is posted in a thread on the message
+	Message string `json:"message" yaml:"message"`
+	// Crosspost is a channel the message's permalink is posted to
+	Crosspost string `json:"crosspost" yaml:"crosspost"`
+}
+
+// watches reports whether the rule counts reactions in the channel
+func (r ReactionThreshold) watches(channelID string) bool {
+	return len(r.Channels) == 0 || slices.Contains(r.Channels, channelID)
+}
+
+type messageReactions struct {
+	Counts map[string]int `json:"counts"`          // reaction -> count
+	Fired  []int          `json:"fired,omitempty"` // Indexes of the rules that already responded
+}
+
+// reactionCounter counts reactions per message for the threshold rules, persisting them
+// so counts survive a restart
+type reactionCounter struct {
+	log      *zap.Logger
+	path     string
+	mu       sync.Mutex
+	messages map[string]*messageReactions // channel:ts -> reactions
+}
+
+func newReactionCounter(log *zap.Logger, dataDir string) *reactionCounter {
+	c := &reactionCounter{
+		log:      log,
+		path:     filepath.Join(dataDir, reactionCountsFile),
+		messages: make(map[string]*messageReactions),
+	}
+	raw, err := os.ReadFile(c.path) // #nosec G304 -- path is derived from the data directory
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		log.Error("Failed to load chat reaction counts", zap.Error(&errs.StorageError{Op: "read", Path: c.path, Err: err}))
+	default:
+		if err := json.Unmarshal(raw, &c.messages); err != nil {
+			log.Error("Failed to load chat reaction counts", zap.Error(&errs.StorageError{Op: "decode", Path: c.path, Err: err}))
+			c.messages = make(map[string]*messageReactions)
+		}
+	}
+	return c
+}
+
+// Reacted adjusts the count of a reaction on the message at channel and ts, returning
+// the indexes of rules that just crossed their threshold. Each rule fires once per
+// message, even if reactions are removed and added again.
+func (c *reactionCounter) Reacted(rules []ReactionThreshold, channel, ts, reaction string, delta int, now time.Time) []int {
+	reaction = emoji.Base(reaction)
+	var watched bool
+	for _, r := range rules {
+		watched = watched || (emoji.Base(r.Reaction) == reaction && r.watches(channel))
+	}
+	if !watched || !recent(ts, now) {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := channel + ":" + ts
+	m := c.messages[key]
+	if m == nil {
+		m = &messageReactions{Counts: make(map[string]int)}
+		c.messages[key] = m
+	}
+	m.Counts[reaction] = max(m.Counts[reaction]+delta, 0)
+
+	var fired []int
+	for i, r := range rules {
+		if emoji.Base(r.Reaction) != reaction || !r.watches(channel) || slices.Contains(m.Fired, i) {
+			continue
+		}
+		if m.Counts[reaction] >= max(r.Count, 1) {
+			m.Fired = append(m.Fired, i)
+			fired = append(fired, i)
+		}
+	}
+
+	for k := range c.messages {
+		if _, messageTS, _ := strings.Cut(k, ":"); !recent(messageTS, now) {
+			delete(c.messages, k)
+		}
+	}
+	c.save()
+	return fired
+}
+
+// recent reports whether a message timestamp is within the counting window
+func recent(ts string, now time.Time) bool {
+	seconds, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return false
+	}
+	return now.Sub(time.Unix(int64(seconds), 0)) <= reactionCountWindow
+}
+
+// save writes the counts atomically; callers must hold mu
+func (c *reactionCounter) save() {
+	data, err := json.Marshal(c.messages)
+	if err != nil {
+		c.log.Error("Failed to marshal chat reaction counts", zap.Error(err))
+		return
+	}
+	tempFile := c.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		c.log.Error("Failed to save chat reaction counts", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, c.path); err != nil {
+		c.log.Error("Failed to save chat reaction counts", zap.Error(&errs.StorageError{Op: "rename", Path: c.path, Err: err}))
+	}
+}
+
+// handleReaction counts a reaction added or removed and responds to the rules it sets off
+func (c *Chat) handleReaction(ctx context.Context, channel, ts, reaction string, delta int) {
+	if len(c.config.ReactionThresholds) == 0 {
+		return
+	}
+	fired := c.reactions.Reacted(c.config.ReactionThresholds, channel, ts, reaction, delta, time.Now())
+	if len(fired) > 0 && c.silencer != nil && c.silencer.Silenced(channel) {
+		c.log.Debug("Channel silenced, skipping reaction threshold", zap.String("channel", channel))
+		return
+	}
+	for _, i := range fired {
+		rule := c.config.ReactionThresholds[i]
+		c.log.Info("Message reached reaction threshold",
+			zap.String("channel", channel),
+			zap.String("ts", ts),
+			zap.String("reaction", rule.Reaction),
+			zap.Int("count", rule.Count),
+		)
+		if rule.Message != "" {
+			c.post(ctx, channel, rule.Message, slack.MsgOptionTS(ts))
+		}
+		if rule.Crosspost != "" {
+			c.crosspost(ctx, rule, channel, ts)
+		}
+	}
+}
+
+// crosspost posts a link to the message in the rule's crosspost channel, which Slack
+// unfurls with the message's text
+func (c *Chat) crosspost(ctx context.Context, rule ReactionThreshold, channel, ts string) {
+	link, err := c.slack.Client().GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: channel, Ts: ts})
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.getPermalink", err)
+		c.status.PostFailed(channel, err)
+		c.log.Error("Failed to get message permalink", zap.String("channel", channel), zap.String("ts", ts), zap.Error(err))
+		return
+	}
+	text := fmt.Sprintf("%s %d in <#%s>: %s", emoji.Code(rule.Reaction), max(rule.Count, 1), channel, link)
+	c.post(ctx, rule.Crosspost, text, slack.MsgOptionEnableLinkUnfurl())
+}
+
+// post posts a reaction threshold response with the chat persona
+func (c *Chat) post(ctx context.Context, channel, text string, options ...slack.MsgOption) {
+	options = append([]slack.MsgOption{slack.MsgOptionText(text, false), c.config.Persona.MsgOption()}, options...)
+	if _, _, err := c.slack.Client().PostMessageContext(ctx, channel, options...); err != nil {
+		c.status.PostFailed(channel, err)
+		c.log.Error("Failed to post reaction threshold response", zap.String("channel", channel), zap.Error(err))
+		return
+	}
+	c.status.Posted()
+}
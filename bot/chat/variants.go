@@ -0,0 +1,221 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+)
+
+const (
+	variantStatsFile = "chat_variants.json"
+	// variantAttributionWindow is how long reactions to a sent variant are counted
+	variantAttributionWindow = 7 * 24 * time.Hour
+)
+
+// Variant is one of several weighted messages a response picks from, so responses can
+// be compared by how many reactions they get
+type Variant struct {
+	Name    string  `json:"name" yaml:"name"`       // Label used in the report, defaults to the variant's position
+	Message string  `json:"message" yaml:"message"` // Message to respond with
+	Weight  float64 `json:"weight" yaml:"weight"`   // Relative weight, defaults to 1
+}
+
+// pickVariant chooses a variant by weight, returning it with its report name
+func pickVariant(variants []Variant) (Variant, string) {
+	weights := make([]float64, len(variants))
+	var total float64
+	for i, v := range variants {
+		weights[i] = v.Weight
+		if v.Weight == 0 {
+			weights[i] = 1
+		}
+		weights[i] = max(weights[i], 0)
+		total += weights[i]
+	}
+
+	i := 0
+	if total > 0 {
+		// #nosec G404 -- Using math/rand is acceptable for non-cryptographic randomness (chat responses)
+		r := rand.Float64() * total
+		for i < len(weights)-1 && r >= weights[i] {
+			r -= weights[i]
+			i++
+		}
+	}
+	return variants[i], variantName(variants[i], i)
+}
+
+func variantName(v Variant, i int) string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return fmt.Sprintf("%d", i+1)
+}
+
+// VariantStats counts how often a variant was sent and the reactions it received
+type VariantStats struct {
+	Sent      int `json:"sent"`
+	Reactions int `json:"reactions"`
+}
+
+// VariantReport is one row of the variant report
+type VariantReport struct {
+	Pattern string
+	Variant string
+	VariantStats
+}
+
+type sentVariant struct {
+	Pattern string    `json:"pattern"`
+	Variant string    `json:"variant"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type variantData struct {
+	Totals map[string]map[string]VariantStats `json:"totals"` // pattern -> variant -> stats
+	Recent map[string]sentVariant             `json:"recent"` // channel:ts -> variant that was sent
+}
+
+// variantTracker records which variant was sent for each message and attributes
+// reactions on those messages back to the variant
+type variantTracker struct {
+	log  *zap.Logger
+	path string
+	mu   sync.Mutex
+	data variantData
+}
+
+func newVariantTracker(log *zap.Logger, dataDir string) *variantTracker {
+	t := &variantTracker{log: log, path: filepath.Join(dataDir, variantStatsFile)}
+	data, err := loadVariantData(t.path)
+	if err != nil {
+		log.Error("Failed to load chat variant stats", zap.Error(err))
+	}
+	t.data = data
+	return t
+}
+
+func loadVariantData(path string) (variantData, error) {
+	data := variantData{
+		Totals: make(map[string]map[string]VariantStats),
+		Recent: make(map[string]sentVariant),
+	}
+	raw, err := os.ReadFile(path) // #nosec G304 -- path is derived from the data directory
+	if os.IsNotExist(err) {
+		return data, nil
+	}
+	if err != nil {
+		return data, &errs.StorageError{Op: "read", Path: path, Err: err}
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return data, &errs.StorageError{Op: "decode", Path: path, Err: err}
+	}
+	if data.Totals == nil {
+		data.Totals = make(map[string]map[string]VariantStats)
+	}
+	if data.Recent == nil {
+		data.Recent = make(map[string]sentVariant)
+	}
+	return data, nil
+}
+
+// Sent records that a variant was posted as the message at channel and ts
+func (t *variantTracker) Sent(pattern, variant, channel, ts string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, sent := range t.data.Recent {
+		if now.Sub(sent.SentAt) > variantAttributionWindow {
+			delete(t.data.Recent, key)
+		}
+	}
+	t.data.Recent[channel+":"+ts] = sentVariant{Pattern: pattern, Variant: variant, SentAt: now}
+	t.update(pattern, variant, func(s *VariantStats) { s.Sent++ })
+	t.save()
+}
+
+// Reacted adjusts the reaction count of the variant sent as the message at channel and
+// ts, ignoring messages that weren't variants
+func (t *variantTracker) Reacted(channel, ts string, delta int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sent, ok := t.data.Recent[channel+":"+ts]
+	if !ok {
+		return
+	}
+	t.update(sent.Pattern, sent.Variant, func(s *VariantStats) { s.Reactions = max(s.Reactions+delta, 0) })
+	t.save()
+}
+
+func (t *variantTracker) update(pattern, variant string, fn func(*VariantStats)) {
+	if t.data.Totals[pattern] == nil {
+		t.data.Totals[pattern] = make(map[string]VariantStats)
+	}
+	stats := t.data.Totals[pattern][variant]
+	fn(&stats)
+	t.data.Totals[pattern][variant] = stats
+}
+
+// save writes the stats atomically; callers must hold mu
+func (t *variantTracker) save() {
+	data, err := json.Marshal(t.data)
+	if err != nil {
+		t.log.Error("Failed to marshal chat variant stats", zap.Error(err))
+		return
+	}
+	tempFile := t.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		t.log.Error("Failed to save chat variant stats", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, t.path); err != nil {
+		t.log.Error("Failed to save chat variant stats", zap.Error(&errs.StorageError{Op: "rename", Path: t.path, Err: err}))
+	}
+}
+
+// LoadVariantReport reads the variant stats from the data directory, sorted by pattern
+// and then by reactions per message sent
+func LoadVariantReport(dataDir string) ([]VariantReport, error) {
+	data, err := loadVariantData(filepath.Join(dataDir, variantStatsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var report []VariantReport
+	for pattern, variants := range data.Totals {
+		for variant, stats := range variants {
+			report = append(report, VariantReport{Pattern: pattern, Variant: variant, VariantStats: stats})
+		}
+	}
+	slices.SortFunc(report, func(a, b VariantReport) int {
+		if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+			return c
+		}
+		if a.Rate() != b.Rate() {
+			if a.Rate() > b.Rate() {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Variant, b.Variant)
+	})
+	return report, nil
+}
+
+// Rate is the average number of reactions per message sent
+func (s VariantStats) Rate() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Reactions) / float64(s.Sent)
+}
@@ -0,0 +1,244 @@
+// Package claim limits how many features respond to one message. Chat, vibecheck and
+// aichat can all match the same message; each claims the message before replying, and
+// only the first few claims by priority are granted. A feature waits for the features
+// ranked above it until the window after the message has passed, so a slow
+// higher-priority feature still wins, and claims after that are granted while slots last.
+package claim
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaxPerMessage = 1
+	DefaultWindow        = 2 * time.Second
+	// forgetAfter is how long a message's claims are kept
+	forgetAfter = 10 * time.Minute
+)
+
+// Features are the features that claim messages before responding, in default priority
+var Features = []string{"chat", "aichat", "vibecheck"}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// MaxPerMessage is how many features may respond to one message, defaults to 1
+	MaxPerMessage *int `json:"max_per_message" yaml:"max_per_message"`
+	// Channels override MaxPerMessage per channel ID, where 0 is unlimited
+	Channels map[string]int `json:"channels" yaml:"channels"`
+	// Priority orders the features, highest first, defaults to chat, aichat, vibecheck
+	Priority []string `json:"priority" yaml:"priority"`
+	// Window is how long after a message lower-priority features wait for higher ones
+	Window *time.Duration `json:"window" yaml:"window"`
+}
+
+type Config struct {
+	Enabled       bool
+	MaxPerMessage int
+	Channels      map[string]int
+	Priority      []string
+	Window        time.Duration
+}
+
+// message is the claims on one message
+type message struct {
+	deadline time.Time
+	claimed  []string      // Features that claimed, granted or not
+	waiting  []string      // Features waiting for the deadline
+	granted  []string      // Features granted a response
+	decided  bool          // Whether the deadline passed and waiting claims were settled
+	done     chan struct{} // Closed when decided
+}
+
+// Coordinator grants features their claims on messages
+type Coordinator struct {
+	log      *zap.Logger
+	config   Config
+	mu       sync.Mutex
+	messages map[string]*message
+	now      func() time.Time
+}
+
+func New(log *zap.Logger, c Config) *Coordinator {
+	if len(c.Priority) == 0 {
+		c.Priority = Features
+	}
+	return &Coordinator{
+		log:      log,
+		config:   c,
+		messages: make(map[string]*message),
+		now:      time.Now,
+	}
+}
+
+// Claim reports whether the feature may respond to the message at ts in the channel. It
+// blocks until the features ranked above it have claimed or the window has passed.
+func (c *Coordinator) Claim(ctx context.Context, feature, channelID, ts string) bool {
+	limit := c.limit(channelID)
+	if limit == 0 || ts == "" {
+		return true
+	}
+
+	key := channelID + ":" + ts
+	c.mu.Lock()
+	now := c.now()
+	c.forget(now)
+	m, ok := c.messages[key]
+	if !ok {
+		m = &message{deadline: messageTime(ts, now).Add(c.config.Window), done: make(chan struct{})}
+		c.messages[key] = m
+	}
+	if slices.Contains(m.claimed, feature) {
+		// A feature responds to a message once
+		granted := slices.Contains(m.granted, feature)
+		c.mu.Unlock()
+		return granted
+	}
+	m.claimed = append(m.claimed, feature)
+	if !now.Before(m.deadline) {
+		// The window passed before the timer settled the waiting claims
+		c.settleLocked(m, limit)
+	}
+
+	// Nothing can displace the claim once the window has passed or every feature ranked
+	// above it has been granted or denied
+	if m.decided || c.higherPending(m, feature) == 0 {
+		granted := c.grant(m, feature, limit)
+		if len(m.waiting) > 0 && c.allClaimed(m) {
+			c.settleLocked(m, limit)
+		}
+		c.mu.Unlock()
+		c.logClaim(feature, channelID, ts, granted)
+		return granted
+	}
+
+	m.waiting = append(m.waiting, feature)
+	switch {
+	case c.allClaimed(m):
+		// There's no one left to wait for
+		c.settleLocked(m, limit)
+	case len(m.waiting) == 1:
+		time.AfterFunc(m.deadline.Sub(now), func() { c.settle(m, limit) })
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		c.mu.Lock()
+		m.waiting = slices.DeleteFunc(m.waiting, func(f string) bool { return f == feature })
+		c.mu.Unlock()
+		return false
+	}
+	c.mu.Lock()
+	granted := slices.Contains(m.granted, feature)
+	c.mu.Unlock()
+	c.logClaim(feature, channelID, ts, granted)
+	return granted
+}
+
+// settle grants the waiting claims by priority once the window has passed
+func (c *Coordinator) settle(m *message, limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settleLocked(m, limit)
+}
+
+func (c *Coordinator) settleLocked(m *message, limit int) {
+	if m.decided {
+		return
+	}
+	slices.SortStableFunc(m.waiting, func(a, b string) int { return c.rank(a) - c.rank(b) })
+	for _, feature := range m.waiting {
+		c.grant(m, feature, limit)
+	}
+	m.waiting = nil
+	m.decided = true
+	close(m.done)
+}
+
+// grant gives the feature a slot if one is left, with the lock held
+func (c *Coordinator) grant(m *message, feature string, limit int) bool {
+	if len(m.granted) >= limit {
+		return false
+	}
+	m.granted = append(m.granted, feature)
+	return true
+}
+
+// higherPending counts the features ranked above the feature that haven't claimed the
+// message or are waiting on their claim
+func (c *Coordinator) higherPending(m *message, feature string) int {
+	var n int
+	for _, f := range c.config.Priority[:c.rank(feature)] {
+		if !slices.Contains(m.claimed, f) || slices.Contains(m.waiting, f) {
+			n++
+		}
+	}
+	return n
+}
+
+// allClaimed reports whether every feature in the priority has claimed the message
+func (c *Coordinator) allClaimed(m *message) bool {
+	for _, f := range c.config.Priority {
+		if !slices.Contains(m.claimed, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// rank is the feature's place in the priority, with unlisted features last
+func (c *Coordinator) rank(feature string) int {
+	if i := slices.Index(c.config.Priority, feature); i >= 0 {
+		return i
+	}
+	return len(c.config.Priority)
+}
+
+// limit is how many features may respond to a message in the channel, 0 for unlimited
+func (c *Coordinator) limit(channelID string) int {
+	if n, ok := c.config.Channels[channelID]; ok {
+		return n
+	}
+	return c.config.MaxPerMessage
+}
+
+// forget drops settled messages past forgetAfter, with the lock held
+func (c *Coordinator) forget(now time.Time) {
+	for key, m := range c.messages {
+		if now.Sub(m.deadline) > forgetAfter && len(m.waiting) == 0 {
+			delete(c.messages, key)
+		}
+	}
+}
+
+func (c *Coordinator) logClaim(feature, channelID, ts string, granted bool) {
+	if granted {
+		c.log.Debug("Response claim granted", zap.String("feature", feature), zap.String("channel", channelID), zap.String("ts", ts))
+		return
+	}
+	c.log.Info("Response claim denied, another feature is responding",
+		zap.String("feature", feature),
+		zap.String("channel", channelID),
+		zap.String("ts", ts))
+}
+
+// messageTime is when the message at ts was posted, or now when ts can't be parsed or is
+// in the future
+func messageTime(ts string, now time.Time) time.Time {
+	seconds, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return now
+	}
+	t := time.UnixMilli(int64(seconds * 1000))
+	if t.After(now) {
+		return now
+	}
+	return t
+}
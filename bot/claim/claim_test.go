@@ -0,0 +1,108 @@
+package claim
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCoordinator_Claim(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := New(zap.NewNop(), Config{MaxPerMessage: 1, Channels: map[string]int{"CFUN": 2, "CALL": 0}, Window: time.Second})
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	ts := "1700000000.000100"
+
+	// Chat is first in the default priority, so nothing can displace it
+	if !c.Claim(ctx, "chat", "C1", ts) {
+		t.Error("Claim(chat) = false, want the first slot")
+	}
+	if c.Claim(ctx, "aichat", "C1", ts) || c.Claim(ctx, "vibecheck", "C1", ts) {
+		t.Error("Claim() after chat = true, want one response per message")
+	}
+	if !c.Claim(ctx, "chat", "C1", ts) {
+		t.Error("Claim(chat) again = false, want its grant remembered")
+	}
+
+	// Channels override the limit
+	if !c.Claim(ctx, "chat", "CFUN", ts) || !c.Claim(ctx, "aichat", "CFUN", ts) || c.Claim(ctx, "vibecheck", "CFUN", ts) {
+		t.Error("Claim() in CFUN, want two responses")
+	}
+	for _, feature := range Features {
+		if !c.Claim(ctx, feature, "CALL", ts) {
+			t.Errorf("Claim(%s) in CALL = false, want no limit", feature)
+		}
+	}
+
+	// After the window, claims are first come, first served
+	now = now.Add(2 * time.Second)
+	if !c.Claim(ctx, "vibecheck", "C2", ts) || c.Claim(ctx, "chat", "C2", ts) {
+		t.Error("Claim() after the window, want the first claim granted")
+	}
+}
+
+func TestCoordinator_ClaimWaitsForHigherPriority(t *testing.T) {
+	c := New(zap.NewNop(), Config{MaxPerMessage: 1, Priority: []string{"aichat", "chat", "vibecheck"}, Window: time.Hour})
+	ctx := context.Background()
+	ts := "9999999999.000100" // In the future, so the window starts now
+
+	results := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, feature := range []string{"vibecheck", "chat"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			granted := c.Claim(ctx, feature, "C1", ts)
+			mu.Lock()
+			results[feature] = granted
+			mu.Unlock()
+		}()
+	}
+	// Wait for both to be waiting on aichat before it claims
+	for {
+		c.mu.Lock()
+		m := c.messages["C1:"+ts]
+		waiting := m != nil && len(m.waiting) == 2
+		c.mu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !c.Claim(ctx, "aichat", "C1", ts) {
+		t.Error("Claim(aichat) = false, want the highest priority granted")
+	}
+	wg.Wait()
+	if results["chat"] || results["vibecheck"] {
+		t.Errorf("waiting claims = %v, want both denied once every feature claimed", results)
+	}
+
+	// A waiting claim gives up with its context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if c.Claim(ctx, "vibecheck", "C1", "9999999999.000200") {
+		t.Error("Claim() with a cancelled context = true")
+	}
+}
+
+func TestCoordinator_ClaimGrantedAfterWindow(t *testing.T) {
+	c := New(zap.NewNop(), Config{MaxPerMessage: 1, Window: 20 * time.Millisecond})
+	ctx := context.Background()
+	ts := "9999999999.000100"
+
+	// Nothing ranked above vibecheck claims the message, so it's granted after the window
+	done := make(chan bool)
+	go func() { done <- c.Claim(ctx, "vibecheck", "C1", ts) }()
+	select {
+	case granted := <-done:
+		if !granted {
+			t.Error("Claim(vibecheck) = false, want it granted when the window passes without aichat or chat")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Claim(vibecheck) didn't return after the window")
+	}
+}
@@ -3,25 +3,39 @@ package bot
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/urfave/cli/v3"
 	"slackbot.arpa/bot/config"
 )
 
-
 type cmdWithArgs func(ctx context.Context, cmd *cli.Command, s *Bot) error
 
-// Wrap subcommands to inject the bot dependency
+// Wrap subcommands to inject the bot dependency. Commands are forwarded to the running
+// bot when one serves the control socket.
 func cmdWithBot(action cmdWithArgs, bot *Bot) cli.ActionFunc {
 	return func(ctx context.Context, cmd *cli.Command) error {
+		if bot.remote != nil {
+			return bot.runRemote(ctx, cmd)
+		}
 		return action(ctx, cmd, bot)
 	}
 }
 
 type setupWithArgs func(ctx context.Context, cmd *cli.Command) (context.Context, error)
 
+// Commands that only describe the CLI, so they run without config or Slack credentials
+var setupFreeCommands = []string{"docs"}
+
+// Commands that work on the data directory directly, so they only set up config. restore
+// replaces the state services load and runs while the bot is stopped.
+var configOnlyCommands = []string{"restore", "feedback"}
+
 func setup(setup setupWithArgs) cli.BeforeFunc {
 	return func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+		if slices.Contains(setupFreeCommands, cmd.Args().First()) {
+			return ctx, nil
+		}
 		return setup(ctx, cmd)
 	}
 }
@@ -44,6 +58,9 @@ func NewCommandRoot(s *Bot) (*bool, *cli.Command) {
 		},
 		Commands: Commands(s),
 		Flags:    config.Flags(),
+		// Adds `completion bash|zsh|fish|pwsh` and completes commands and flags on tab
+		EnableShellCompletion:           true,
+		ConfigureShellCompletionCommand: configureCompletion,
 	}
 }
 
@@ -52,5 +69,19 @@ func Commands(s *Bot) []*cli.Command {
 		newDeleteMessagesFromChannelCommand(s),
 		newInviteToChannelCommand(s),
 		newSendMessageCommand(s),
+		newReactCommand(s),
+		newPinCommand(s),
+		newUnpinCommand(s),
+		newWhoisCommand(s),
+		newChatCommand(s),
+		newAIChatCommand(s),
+		newTopicCommand(s),
+		newFeedbackCommand(s),
+		newOutboxCommand(s),
+		newUsersCommand(s),
+		newAnnounceCommand(s),
+		newSchedulesCommand(s),
+		newRestoreCommand(s),
+		newDocsCommand(),
 	}
 }
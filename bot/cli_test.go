@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"bytes"
 	"context"
 	"strings"
 	"testing"
@@ -356,3 +357,46 @@ func BenchmarkCommandRoot_Parse(b *testing.B) {
 		_ = cmd.Run(ctx, args)
 	}
 }
+
+func TestCommandRoot_Docs(t *testing.T) {
+	// Generated docs and completions don't need credentials
+	t.Setenv("SLACK_TOKEN", "")
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"bot", "docs", "man"},
+			want: []string{".TH SLACKBOT 1", ".SS whois\n", "\\fB\\-\\-user, \\-u\\fR", "[$LOG_LEVEL]", ".SS chat suggest\n"},
+		},
+		{
+			args: []string{"bot", "docs", "config"},
+			want: []string{"# vibecheck:\n", "#   ban_duration: <duration>", "(SLACK_TEAM_IDS)"},
+		},
+		{args: []string{"bot", "completion", "bash"}, want: []string{"slackbot"}},
+		{args: []string{"bot", "completion", "fish"}, want: []string{"complete -c slackbot"}},
+	} {
+		t.Run(strings.Join(tt.args[1:], " "), func(t *testing.T) {
+			bot := NewBot(config.BuildOpts{BuildVersion: "test-version"})
+			start, cmd := NewCommandRoot(bot)
+			var out bytes.Buffer
+			cmd.Writer = &out
+
+			if err := cmd.Run(context.Background(), tt.args); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if *start {
+				t.Error("Generating docs should not start the bot")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Expected %q in output:\n%s", want, out.String())
+				}
+			}
+			if strings.Contains(out.String(), ".SS help") {
+				t.Error("Help commands should be left out of the man page")
+			}
+		})
+	}
+}
@@ -2,20 +2,49 @@ package bot
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/slack-go/slack"
 	"github.com/urfave/cli/v3"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/announce"
+	"slackbot.arpa/bot/backup"
+	"slackbot.arpa/bot/chat"
+	"slackbot.arpa/bot/config"
+	"slackbot.arpa/bot/control"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/feedback"
+	"slackbot.arpa/bot/outbox"
+	"slackbot.arpa/bot/scheduler"
+	botslack "slackbot.arpa/bot/slack"
+	"slackbot.arpa/bot/topic"
+	"slackbot.arpa/bot/user"
+	"slackbot.arpa/tools/emoji"
 )
 
 type deleteMessagesFromChannelCommandFlags struct {
 	Channel string
+	Feature string
+	Archive bool
+	Yes     bool
 }
 
 func newDeleteMessagesFromChannelCommandFlags(cmd *cli.Command) *deleteMessagesFromChannelCommandFlags {
 	return &deleteMessagesFromChannelCommandFlags{
 		Channel: cmd.String("channel"),
+		Feature: cmd.String("feature"),
+		Archive: cmd.Bool("archive"),
+		Yes:     cmd.Bool("yes"),
 	}
 }
 
@@ -30,6 +59,19 @@ func newDeleteMessagesFromChannelCommand(s *Bot) *cli.Command {
 				Usage:    "Channel ID to delete messages from",
 				Required: true,
 			},
+			&cli.StringFlag{
+				Name:  "feature",
+				Usage: "Only delete messages the feature posted, from the metadata attached with message_metadata",
+			},
+			&cli.BoolFlag{
+				Name:  "archive",
+				Usage: "Write each message to a JSONL archive in the data directory before deleting it",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Skip the confirmation prompt",
+			},
 		},
 	}
 }
@@ -40,7 +82,7 @@ func deleteMessagesFromChannel(ctx context.Context, cmd *cli.Command, s *Bot) er
 		return fmt.Errorf("channel ID is required")
 	}
 
-	s.log.Info("Deleting bot messages from channel", zap.String("channel", f.Channel))
+	s.log.Info("Deleting bot messages from channel", zap.String("channel", f.Channel), zap.String("feature", f.Feature))
 
 	client := s.slack.Client()
 	if client == nil {
@@ -51,37 +93,39 @@ func deleteMessagesFromChannel(ctx context.Context, cmd *cli.Command, s *Bot) er
 	authTest, err := client.AuthTest()
 	if err != nil {
 		s.log.Error("Failed to get bot user ID", zap.Error(err))
-		return fmt.Errorf("failed to get bot user ID: %w", err)
+		return fmt.Errorf("failed to get bot user ID: %w", errs.NewSlackAPIError("auth.test", err))
 	}
 	botUserID := authTest.UserID
 	s.log.Info("Bot user ID retrieved", zap.String("botUserID", botUserID))
 
-	// Get conversation history
+	// Collect matching messages first so the prompt and progress can show a total
 	params := &slack.GetConversationHistoryParameters{
 		ChannelID: f.Channel,
 		Limit:     1000, // Maximum allowed by Slack API
 		Inclusive: true,
+		// The metadata names the feature that posted each message
+		IncludeAllMetadata: f.Feature != "",
 	}
 
-	var messagesDeleted int
+	var messages []slack.Message
 	for {
 		history, err := client.GetConversationHistoryContext(ctx, params)
 		if err != nil {
 			s.log.Error("Failed to get conversation history", zap.Error(err))
-			return fmt.Errorf("failed to get conversation history: %w", err)
+			return fmt.Errorf("failed to get conversation history: %w", errs.NewSlackAPIError("conversations.history", err))
 		}
 
 		for _, msg := range history.Messages {
 			// Won't delete messages sent by msg.User == "USLACKBOT" 😢
-			if msg.User == botUserID || msg.BotID != "" || msg.User == "USLACKBOT" {
-				_, _, err := client.DeleteMessageContext(ctx, f.Channel, msg.Timestamp)
-				if err != nil {
-					s.log.Warn("Failed to delete message", zap.String("ts", msg.Timestamp), zap.Error(err))
+			if msg.User != botUserID && msg.BotID == "" && msg.User != "USLACKBOT" {
+				continue
+			}
+			if f.Feature != "" {
+				if m, ok := botslack.PostedBy(msg); !ok || m.Feature != f.Feature {
 					continue
 				}
-				messagesDeleted++
-				s.log.Debug("Message deleted", zap.String("ts", msg.Timestamp))
 			}
+			messages = append(messages, msg)
 		}
 
 		if !history.HasMore {
@@ -91,10 +135,78 @@ func deleteMessagesFromChannel(ctx context.Context, cmd *cli.Command, s *Bot) er
 		params.Cursor = history.ResponseMetaData.NextCursor
 	}
 
-	s.log.Info("Finished deleting bot messages", zap.Int("messagesDeleted", messagesDeleted))
+	out := messageWriter(cmd)
+	if len(messages) == 0 {
+		if jsonOutput(cmd) {
+			return writeJSON(cmd.Root().Writer, deleteResult{Channel: f.Channel})
+		}
+		_, _ = fmt.Fprintln(out, "No bot messages to delete.")
+		return nil
+	}
+	if !f.Yes && !confirm(cmd.Root().Reader, out, fmt.Sprintf("Delete %d bot messages from %s?", len(messages), f.Channel)) {
+		_, _ = fmt.Fprintln(out, "Aborted.")
+		return nil
+	}
+
+	var archive *messageArchive
+	if f.Archive {
+		config := s.configManager.GetConfig()
+		if config == nil {
+			return fmt.Errorf("configuration is unavailable")
+		}
+		archive, err = newMessageArchive(config.DataDir, f.Channel, time.Now())
+		if err != nil {
+			return fmt.Errorf("create message archive: %w", err)
+		}
+		defer func() { _ = archive.Close() }()
+		s.log.Info("Archiving messages before deletion", zap.String("path", archive.path))
+	}
+
+	progress := newProgressReporter(out, "Deleted", len(messages))
+	for _, msg := range messages {
+		// Stop rather than delete a message that couldn't be archived
+		if archive != nil {
+			if err := archive.Write(f.Channel, msg); err != nil {
+				progress.Summary()
+				return fmt.Errorf("archive message %s: %w", msg.Timestamp, err)
+			}
+		}
+		_, _, err := client.DeleteMessageContext(ctx, f.Channel, msg.Timestamp)
+		if err != nil {
+			s.log.Warn("Failed to delete message", zap.String("ts", msg.Timestamp), zap.Error(err))
+			reason := errs.NewSlackAPIError("chat.delete", err).Code
+			if reason == "" {
+				reason = "error"
+			}
+			progress.Failure(msg.Timestamp, reason)
+			continue
+		}
+		progress.Success()
+		s.log.Debug("Message deleted", zap.String("ts", msg.Timestamp))
+	}
+
+	progress.Summary()
+	s.log.Info("Finished deleting bot messages", zap.Int("messagesDeleted", progress.done))
+	if jsonOutput(cmd) {
+		result := deleteResult{Channel: f.Channel, Deleted: progress.done, Failed: progress.failures}
+		if archive != nil {
+			result.Archive = archive.path
+		}
+		return writeJSON(cmd.Root().Writer, result)
+	}
+	if archive != nil {
+		_, _ = fmt.Fprintf(out, "Archived messages to %s\n", archive.path)
+	}
 	return nil
 }
 
+type deleteResult struct {
+	Channel string              `json:"channel"`
+	Deleted int                 `json:"deleted"`
+	Failed  map[string][]string `json:"failed,omitempty"` // reason -> message timestamps
+	Archive string              `json:"archive,omitempty"`
+}
+
 type inviteToChannelCommandFlags struct {
 	Users    []string
 	Channels []string
@@ -144,44 +256,75 @@ func inviteToChannel(ctx context.Context, cmd *cli.Command, s *Bot) error {
 		return fmt.Errorf("slack client is unavailable")
 	}
 
+	var results []inviteResult
 	for _, channel := range f.Channels {
 		for _, user := range f.Users {
+			result := inviteResult{Channel: channel, User: user}
 			_, err := client.InviteUsersToConversationContext(ctx, channel, user)
 			if err != nil {
+				err = conversation.Error("conversations.invite", conversation.KindFromID(channel), err)
 				s.log.Error("Failed to invite user to channel", zap.String("channel", channel), zap.String("user", user), zap.Error(err))
-				continue
+				result.Error = err.Error()
+			} else {
+				result.Invited = true
+				s.log.Debug("User invited to channel", zap.String("channel", channel), zap.String("user", user))
 			}
-			s.log.Debug("User invited to channel", zap.String("channel", channel), zap.String("user", user))
+			results = append(results, result)
 		}
 	}
 
 	s.log.Info("Finished inviting users.", zap.Int("users", len(f.Users)), zap.Int("channels", len(f.Channels)))
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, results)
+	}
 	return nil
 }
 
+type inviteResult struct {
+	Channel string `json:"channel"`
+	User    string `json:"user"`
+	Invited bool   `json:"invited"`
+	Error   string `json:"error,omitempty"`
+}
+
 type sendMessageCommandFlags struct {
-	Message  string
-	Channels []string
+	Message     string
+	MessageFile string
+	Vars        []string
+	Channels    []string
 }
 
 func newSendMessageCommandFlags(cmd *cli.Command) *sendMessageCommandFlags {
 	return &sendMessageCommandFlags{
-		Message:  cmd.String("message"),
-		Channels: cmd.StringSlice("channels"),
+		Message:     cmd.String("message"),
+		MessageFile: cmd.String("message-file"),
+		Vars:        cmd.StringSlice("var"),
+		Channels:    cmd.StringSlice("channels"),
 	}
 }
 
 func newSendMessageCommand(s *Bot) *cli.Command {
 	return &cli.Command{
-		Name:   "send-message",
-		Usage:  "Send a message to specified channels or preferred channels",
-		Action: cmdWithBot(sendMessage, s),
+		Name:  "send-message",
+		Usage: "Send a message to specified channels or preferred channels",
+		Description: "The message is a Go template, so announcements kept in a repository can be posted by CI, e.g.\n" +
+			"slackbot send-message --message-file release.md --var version=v1.2.0 --channels C0123ABC",
+		Action: sendMessageAction(s),
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "message",
-				Aliases:  []string{"m"},
-				Usage:    "Message text to send",
-				Required: true,
+				Name:    "message",
+				Aliases: []string{"m"},
+				Usage:   "Message text to send",
+			},
+			&cli.StringFlag{
+				Name:      "message-file",
+				Aliases:   []string{"f"},
+				Usage:     "Read the message text from a file, or stdin with -",
+				TakesFile: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  "var",
+				Usage: "Template variable for the message as key=value, e.g. --var version=v1.2.0 fills {{.version}}",
 			},
 			&cli.StringSliceFlag{
 				Name:    "channels",
@@ -192,9 +335,44 @@ func newSendMessageCommand(s *Bot) *cli.Command {
 	}
 }
 
+// sendMessageAction reads a message file or stdin before a command is forwarded to the
+// running bot, since neither is the bot's to read
+func sendMessageAction(s *Bot) cli.ActionFunc {
+	return func(ctx context.Context, cmd *cli.Command) error {
+		if s.remote == nil {
+			return sendMessage(ctx, cmd, s)
+		}
+		f := newSendMessageCommandFlags(cmd)
+		text, err := readMessage(cmd.Root().Reader, f.Message, f.MessageFile)
+		if err != nil {
+			return err
+		}
+		args := []string{cmd.Name, "--message", text}
+		for _, v := range f.Vars {
+			args = append(args, "--var", v)
+		}
+		for _, channel := range f.Channels {
+			args = append(args, "--channels", channel)
+		}
+		return s.runRemoteArgs(ctx, cmd, args)
+	}
+}
+
 func sendMessage(ctx context.Context, cmd *cli.Command, s *Bot) error {
 	f := newSendMessageCommandFlags(cmd)
-	if f.Message == "" {
+	text, err := readMessage(cmd.Root().Reader, f.Message, f.MessageFile)
+	if err != nil {
+		return err
+	}
+	vars, err := parseVars(f.Vars)
+	if err != nil {
+		return err
+	}
+	message, err := renderMessage(text, vars)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(message) == "" {
 		return fmt.Errorf("message text is required")
 	}
 
@@ -210,14 +388,14 @@ func sendMessage(ctx context.Context, cmd *cli.Command, s *Bot) error {
 			channels = append(channels, config.Slack.PreferredChannels...)
 		}
 		if len(channels) == 0 {
-			return fmt.Errorf("no channels specified and no preferred channels configured")
+			return &errs.ConfigError{Key: "slack-preferred-channels", Err: errors.New("no channels specified and no preferred channels configured")}
 		}
 		s.log.Info("Using preferred channels from config", zap.Strings("channels", channels))
 	} else {
 		s.log.Info("Using specified channels", zap.Strings("channels", channels))
 	}
 
-	s.log.Info("Sending message to channels", zap.String("message", f.Message), zap.Strings("channels", channels))
+	s.log.Info("Sending message to channels", zap.String("message", message), zap.Strings("channels", channels))
 
 	client := s.slack.Client()
 	if client == nil {
@@ -225,16 +403,1320 @@ func sendMessage(ctx context.Context, cmd *cli.Command, s *Bot) error {
 	}
 
 	var messagesSent int
+	results := make([]sendResult, 0, len(channels))
 	for _, channel := range channels {
-		_, _, err := client.PostMessageContext(ctx, channel, slack.MsgOptionText(f.Message, false))
+		_, ts, err := client.PostMessageContext(ctx, channel, slack.MsgOptionText(message, false))
 		if err != nil {
 			s.log.Error("Failed to send message to channel", zap.String("channel", channel), zap.Error(err))
+			results = append(results, sendResult{Channel: channel, Error: err.Error()})
 			continue
 		}
 		messagesSent++
+		results = append(results, sendResult{Channel: channel, TS: ts})
 		s.log.Debug("Message sent to channel", zap.String("channel", channel))
 	}
 
 	s.log.Info("Finished sending messages", zap.Int("messagesSent", messagesSent), zap.Int("totalChannels", len(channels)))
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, results)
+	}
+	return nil
+}
+
+type sendResult struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts,omitempty"` // Timestamp of the sent message
+	Error   string `json:"error,omitempty"`
+}
+
+type messageRefCommandFlags struct {
+	Channel string
+	TS      string
+}
+
+func newMessageRefCommandFlags(cmd *cli.Command) *messageRefCommandFlags {
+	return &messageRefCommandFlags{
+		Channel: cmd.String("channel"),
+		TS:      cmd.String("ts"),
+	}
+}
+
+// messageRefFlags identify a single message by channel and timestamp
+func messageRefFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "channel",
+			Aliases:  []string{"c"},
+			Usage:    "Channel ID of the message",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "ts",
+			Usage:    "Timestamp of the message, e.g. 1712345678.123456",
+			Required: true,
+		},
+	}
+}
+
+func newReactCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:   "react",
+		Usage:  "Add a reaction to a message as the bot",
+		Action: cmdWithBot(react, s),
+		Flags: append(messageRefFlags(),
+			&cli.StringFlag{
+				Name:     "emoji",
+				Aliases:  []string{"e"},
+				Usage:    "Emoji name with or without colons, e.g. tada",
+				Required: true,
+			},
+		),
+	}
+}
+
+func react(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	f := newMessageRefCommandFlags(cmd)
+	name := emoji.Name(cmd.String("emoji"))
+	if name == "" {
+		return fmt.Errorf("emoji is required")
+	}
+
+	client := s.slack.Client()
+	if client == nil {
+		return fmt.Errorf("slack client is unavailable")
+	}
+
+	err := s.slack.Retry(ctx, "reactions.add", func() error {
+		return client.AddReactionContext(ctx, name, slack.NewRefToMessage(f.Channel, f.TS))
+	})
+	result := messageResult{Channel: f.Channel, TS: f.TS, Emoji: name, Changed: true}
+	if slackErrorCode(err) == "already_reacted" {
+		s.log.Info("Message already has reaction", zap.String("channel", f.Channel), zap.String("ts", f.TS), zap.String("emoji", name))
+		result.Changed = false
+	} else if err != nil {
+		return fmt.Errorf("add reaction: %w", err)
+	} else {
+		s.log.Info("Reaction added", zap.String("channel", f.Channel), zap.String("ts", f.TS), zap.String("emoji", name))
+	}
+
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, result)
+	}
+	return nil
+}
+
+// messageResult is the outcome of changing a single message
+type messageResult struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	Emoji   string `json:"emoji,omitempty"`
+	Pinned  *bool  `json:"pinned,omitempty"`
+	Changed bool   `json:"changed"` // False when the message was already in the requested state
+}
+
+func newPinCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:   "pin",
+		Usage:  "Pin a message to its channel as the bot",
+		Action: cmdWithBot(pin, s),
+		Flags:  messageRefFlags(),
+	}
+}
+
+func newUnpinCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:   "unpin",
+		Usage:  "Unpin a message from its channel as the bot",
+		Action: cmdWithBot(unpin, s),
+		Flags:  messageRefFlags(),
+	}
+}
+
+func pin(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	return setPinned(ctx, cmd, s, true)
+}
+
+func unpin(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	return setPinned(ctx, cmd, s, false)
+}
+
+func setPinned(ctx context.Context, cmd *cli.Command, s *Bot, pinned bool) error {
+	f := newMessageRefCommandFlags(cmd)
+
+	client := s.slack.Client()
+	if client == nil {
+		return fmt.Errorf("slack client is unavailable")
+	}
+
+	op, call, noop := "pins.add", client.AddPinContext, "already_pinned"
+	if !pinned {
+		op, call, noop = "pins.remove", client.RemovePinContext, "no_pin"
+	}
+	err := s.slack.Retry(ctx, op, func() error {
+		return call(ctx, f.Channel, slack.NewRefToMessage(f.Channel, f.TS))
+	})
+	result := messageResult{Channel: f.Channel, TS: f.TS, Pinned: &pinned, Changed: true}
+	if slackErrorCode(err) == noop {
+		s.log.Info("Message pin already in requested state", zap.String("channel", f.Channel), zap.String("ts", f.TS), zap.Bool("pinned", pinned))
+		result.Changed = false
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	} else {
+		s.log.Info("Message pin updated", zap.String("channel", f.Channel), zap.String("ts", f.TS), zap.Bool("pinned", pinned))
+	}
+
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, result)
+	}
+	return nil
+}
+
+// slackErrorCode returns the Slack error code of a SlackAPIError, or "" for other errors
+func slackErrorCode(err error) string {
+	var apiErr *errs.SlackAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+type whoisCommandFlags struct {
+	User  string
+	Email string
+	Name  string
+}
+
+func newWhoisCommandFlags(cmd *cli.Command) *whoisCommandFlags {
+	return &whoisCommandFlags{
+		User:  cmd.String("user"),
+		Email: cmd.String("email"),
+		Name:  cmd.String("name"),
+	}
+}
+
+func newWhoisCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:   "whois",
+		Usage:  "Look up a user's profile, timezone, admin status and channels shared with the bot",
+		Action: cmdWithBot(whois, s),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "user",
+				Aliases: []string{"u"},
+				Usage:   "User ID, e.g. U0123ABC",
+			},
+			&cli.StringFlag{
+				Name:  "email",
+				Usage: "Email address (requires the users:read.email scope)",
+			},
+			&cli.StringFlag{
+				Name:    "name",
+				Aliases: []string{"n"},
+				Usage:   "Handle, display name or real name",
+			},
+		},
+	}
+}
+
+func whois(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	f := newWhoisCommandFlags(cmd)
+	var set int
+	for _, v := range []string{f.User, f.Email, f.Name} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of --user, --email or --name is required")
+	}
+
+	var users []slack.User
+	switch {
+	case f.User != "":
+		user, err := s.slack.User(ctx, f.User)
+		if err != nil {
+			return fmt.Errorf("look up user: %w", err)
+		}
+		users = append(users, user)
+	case f.Email != "":
+		user, err := s.slack.UserByEmail(ctx, f.Email)
+		if err != nil {
+			return fmt.Errorf("look up user by email: %w", err)
+		}
+		users = append(users, user)
+	default:
+		matches, err := s.slack.FindUsers(ctx, f.Name)
+		if err != nil {
+			return fmt.Errorf("look up user by name: %w", err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no user named %q", f.Name)
+		}
+		users = matches
+	}
+
+	out := cmd.Root().Writer
+	results := make([]whoisResult, 0, len(users))
+	for i, user := range users {
+		channels, err := s.slack.SharedChannels(ctx, user.ID)
+		if err != nil {
+			s.log.Warn("Failed to list shared channels", zap.String("user", user.ID), zap.Error(err))
+		}
+		if jsonOutput(cmd) {
+			results = append(results, newWhoisResult(user, channels))
+			continue
+		}
+		if i > 0 {
+			_, _ = fmt.Fprintln(out)
+		}
+		writeWhois(out, user, channels)
+	}
+	if jsonOutput(cmd) {
+		return writeJSON(out, results)
+	}
+	return nil
+}
+
+type whoisResult struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	RealName       string   `json:"real_name"`
+	DisplayName    string   `json:"display_name,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Email          string   `json:"email,omitempty"`
+	TZ             string   `json:"tz,omitempty"`
+	TZOffset       int      `json:"tz_offset"` // Seconds from UTC
+	Roles          []string `json:"roles"`
+	Deleted        bool     `json:"deleted"`
+	Status         string   `json:"status,omitempty"`
+	SharedChannels []string `json:"shared_channels"` // Channel IDs
+}
+
+func newWhoisResult(u slack.User, channels []slack.Channel) whoisResult {
+	ids := make([]string, 0, len(channels))
+	for _, c := range channels {
+		ids = append(ids, c.ID)
+	}
+	return whoisResult{
+		ID:             u.ID,
+		Name:           u.Name,
+		RealName:       config.Default(u.Profile.RealName, u.RealName),
+		DisplayName:    u.Profile.DisplayName,
+		Title:          u.Profile.Title,
+		Email:          u.Profile.Email,
+		TZ:             u.TZ,
+		TZOffset:       u.TZOffset,
+		Roles:          userRoles(u),
+		Deleted:        u.Deleted,
+		Status:         strings.TrimSpace(u.Profile.StatusEmoji + " " + u.Profile.StatusText),
+		SharedChannels: ids,
+	}
+}
+
+// writeWhois prints a user's profile for operators
+func writeWhois(w io.Writer, u slack.User, channels []slack.Channel) {
+	name := config.Default(u.Profile.RealName, u.RealName)
+	_, _ = fmt.Fprintf(w, "%s (@%s) %s\n", name, u.Name, u.ID)
+
+	field := func(label, value string) {
+		if value != "" {
+			_, _ = fmt.Fprintf(w, "  %-16s %s\n", label+":", value)
+		}
+	}
+	field("Display name", u.Profile.DisplayName)
+	field("Title", u.Profile.Title)
+	field("Email", u.Profile.Email)
+	if u.TZ != "" {
+		offset := time.Duration(u.TZOffset) * time.Second
+		field("Timezone", fmt.Sprintf("%s (%s, UTC%s)", u.TZ, u.TZLabel, formatOffset(offset)))
+	}
+
+	field("Role", strings.Join(userRoles(u), ", "))
+	if u.Deleted {
+		field("Status", "deactivated")
+	} else {
+		field("Status", strings.TrimSpace(u.Profile.StatusEmoji+" "+u.Profile.StatusText))
+	}
+
+	names := make([]string, 0, len(channels))
+	for _, c := range channels {
+		names = append(names, "#"+c.Name)
+	}
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+	field("Shared channels", strings.Join(names, ", "))
+}
+
+// userRoles describes a user's workspace role, guest status and whether they're a bot
+func userRoles(u slack.User) []string {
+	var roles []string
+	switch {
+	case u.IsPrimaryOwner:
+		roles = append(roles, "primary owner")
+	case u.IsOwner:
+		roles = append(roles, "owner")
+	case u.IsAdmin:
+		roles = append(roles, "admin")
+	}
+	switch {
+	case u.IsUltraRestricted:
+		roles = append(roles, "single-channel guest")
+	case u.IsRestricted:
+		roles = append(roles, "multi-channel guest")
+	}
+	if u.IsBot {
+		roles = append(roles, "bot")
+	}
+	if len(roles) == 0 {
+		roles = append(roles, "member")
+	}
+	return roles
+}
+
+func formatOffset(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	return fmt.Sprintf("%s%02d:%02d", sign, int(d.Hours()), int(d.Minutes())%60)
+}
+
+func newChatCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "chat",
+		Usage: "Tune the configured chat responses",
+		Commands: []*cli.Command{
+			{
+				Name:   "variants",
+				Usage:  "Report how often each chat response variant was sent and the reactions it received",
+				Action: cmdWithBot(chatVariants, s),
+			},
+			{
+				Name:   "suggest",
+				Usage:  "Propose new chat responses from a channel's recent messages using the configured LLM",
+				Action: cmdWithBot(chatSuggest, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "channel",
+						Aliases:  []string{"c"},
+						Usage:    "Channel ID to analyze",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "days",
+						Usage: "How many days of history to analyze",
+						Value: 7,
+					},
+				},
+			},
+		},
+	}
+}
+
+func chatVariants(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("configuration is unavailable")
+	}
+	report, err := chat.LoadVariantReport(config.DataDir)
+	if err != nil {
+		return fmt.Errorf("load chat variant stats: %w", err)
+	}
+	if jsonOutput(cmd) {
+		results := make([]variantResult, 0, len(report))
+		for _, r := range report {
+			results = append(results, variantResult{Pattern: r.Pattern, Variant: r.Variant, Sent: r.Sent, Reactions: r.Reactions, Rate: r.Rate()})
+		}
+		return writeJSON(cmd.Root().Writer, results)
+	}
+	writeVariantReport(cmd.Root().Writer, report)
+	return nil
+}
+
+type variantResult struct {
+	Pattern   string  `json:"pattern"`
+	Variant   string  `json:"variant"`
+	Sent      int     `json:"sent"`
+	Reactions int     `json:"reactions"`
+	Rate      float64 `json:"rate"` // Reactions per message
+}
+
+// writeVariantReport prints variants grouped by pattern, best performing first
+func writeVariantReport(w io.Writer, report []chat.VariantReport) {
+	if len(report) == 0 {
+		_, _ = fmt.Fprintln(w, "No chat variants have been sent yet.")
+		return
+	}
+	var pattern string
+	for i, r := range report {
+		if i == 0 || r.Pattern != pattern {
+			pattern = r.Pattern
+			_, _ = fmt.Fprintf(w, "%s\n", pattern)
+		}
+		_, _ = fmt.Fprintf(w, "  %-16s sent %-5d reactions %-5d per message %.2f\n", r.Variant, r.Sent, r.Reactions, r.Rate())
+	}
+}
+
+func chatSuggest(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	channel, days := cmd.String("channel"), cmd.Int("days")
+	if days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	if s.ai == nil {
+		return fmt.Errorf("chat suggestions require an OpenAI API key")
+	}
+	// Commands run for the control socket share the running bot's AI
+	if s.ai.LLM() == nil {
+		if err := s.ai.Start(ctx); err != nil {
+			return fmt.Errorf("start AI: %w", err)
+		}
+	}
+	client := s.slack.Client()
+	if client == nil {
+		return fmt.Errorf("slack client is unavailable")
+	}
+
+	params := &slack.GetConversationHistoryParameters{
+		ChannelID: channel,
+		Oldest:    fmt.Sprintf("%d", time.Now().AddDate(0, 0, -days).Unix()),
+		Limit:     1000,
+	}
+	var samples []string
+	for {
+		history, err := client.GetConversationHistoryContext(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to get conversation history: %w", errs.NewSlackAPIError("conversations.history", err))
+		}
+		for _, msg := range history.Messages {
+			if msg.BotID == "" && msg.SubType == "" && strings.TrimSpace(msg.Text) != "" {
+				samples = append(samples, msg.Text)
+			}
+		}
+		if !history.HasMore {
+			break
+		}
+		params.Cursor = history.ResponseMetaData.NextCursor
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("no messages in %s from the last %d days", channel, days)
+	}
+	// History is newest first; the model sees the most recent messages in order
+	slices.Reverse(samples)
+
+	s.log.Info("Generating chat response suggestions", zap.String("channel", channel), zap.Int("messages", len(samples)))
+	suggestions, err := chat.SuggestResponses(ctx, s.ai.For("suggestions").LLM(), samples, s.configManager.GetChatConfig().Responses)
+	if err != nil {
+		return fmt.Errorf("suggest chat responses: %w", err)
+	}
+
+	out := cmd.Root().Writer
+	if jsonOutput(cmd) {
+		if suggestions == nil {
+			suggestions = []chat.Suggestion{}
+		}
+		return writeJSON(out, suggestResult{Channel: channel, Days: days, Messages: len(samples), Suggestions: suggestions})
+	}
+	if len(suggestions) == 0 {
+		_, _ = fmt.Fprintln(out, "No new responses suggested.")
+		return nil
+	}
+	formatted, err := chat.FormatSuggestions(suggestions)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "# Suggested from %d messages in %s over the last %d days. Review before adding to config.yaml.\n", len(samples), channel, days)
+	_, _ = fmt.Fprint(out, formatted)
+	return nil
+}
+
+type suggestResult struct {
+	Channel     string            `json:"channel"`
+	Days        int               `json:"days"`
+	Messages    int               `json:"messages"` // Messages analyzed
+	Suggestions []chat.Suggestion `json:"suggestions"`
+}
+
+func newAIChatCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "aichat",
+		Usage: "Inspect, export and import stored AI chat conversation context",
+		Commands: []*cli.Command{
+			{
+				Name:   "search",
+				Usage:  "Full-text search of stored conversation context",
+				Action: cmdWithBot(aichatSearch, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "query",
+						Aliases:  []string{"q"},
+						Usage:    "Words that must all appear in a message",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "channel",
+						Aliases: []string{"c"},
+						Usage:   "Only search this channel ID",
+					},
+					&cli.StringFlag{
+						Name:    "user",
+						Aliases: []string{"u"},
+						Usage:   "Only search this user ID's conversations",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "Maximum number of matches to print",
+						Value: 50,
+					},
+				},
+			},
+			{
+				Name:   "dump",
+				Usage:  "Export stored conversation context and sticky persona assignments as JSONL. Paths are opened by the running bot when there is one.",
+				Action: cmdWithBot(aichatDump, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "File to write, - for stdout",
+						Value: "-",
+					},
+				},
+			},
+			{
+				Name:      "load",
+				Usage:     "Import a dump from aichat dump, skipping messages already stored. Persona assignments are only restored into a running bot.",
+				ArgsUsage: "FILE",
+				Action:    cmdWithBot(aichatLoad, s),
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "map",
+						Usage: "Rewrite a user or channel ID, e.g. --map U0OLD=U0NEW",
+					},
+					&cli.StringSliceFlag{
+						Name:  "map-persona",
+						Usage: "Rewrite a persona name, e.g. --map-persona pirate=captain",
+					},
+				},
+			},
+		},
+	}
+}
+
+func aichatSearch(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("configuration is unavailable")
+	}
+	storage, err := aichat.NewContextStorage(config.DataDir)
+	if err != nil {
+		return fmt.Errorf("open context storage: %w", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	matches, err := storage.SearchContext(cmd.String("query"), aichat.SearchOptions{
+		ChannelID: cmd.String("channel"),
+		UserID:    cmd.String("user"),
+		Limit:     cmd.Int("limit"),
+	})
+	if err != nil {
+		return &errs.StorageError{Op: "search", Path: config.DataDir, Err: err}
+	}
+	if jsonOutput(cmd) {
+		results := make([]searchResult, 0, len(matches))
+		for _, m := range matches {
+			results = append(results, searchResult{
+				Timestamp:   m.Timestamp,
+				Role:        m.Role,
+				ChannelID:   m.ChannelID,
+				UserID:      m.UserID,
+				PersonaName: m.PersonaName,
+				Message:     m.Message,
+				Snippet:     m.Snippet,
+			})
+		}
+		return writeJSON(cmd.Root().Writer, results)
+	}
+	writeSearchMatches(cmd.Root().Writer, matches)
+	return nil
+}
+
+func aichatDump(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("configuration is unavailable")
+	}
+	storage, err := aichat.NewContextStorage(config.DataDir)
+	if err != nil {
+		return fmt.Errorf("open context storage: %w", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	var assignments []aichat.PersonaAssignment
+	if a := s.runningAIChat(); a != nil {
+		assignments = a.PersonaAssignments()
+	}
+
+	out := cmd.String("out")
+	w := cmd.Root().Writer
+	if out != "-" {
+		f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return &errs.StorageError{Op: "create", Path: out, Err: err}
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+	messages, personas, err := aichat.WriteDump(w, storage, assignments)
+	if err != nil {
+		return &errs.StorageError{Op: "dump", Path: out, Err: err}
+	}
+	if out == "-" {
+		return nil
+	}
+	result := dumpResult{File: out, Messages: messages, Personas: personas}
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, result)
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Dumped %d messages and %d persona assignments to %s\n", messages, personas, out)
+	return nil
+}
+
+func aichatLoad(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	path := cmd.Args().First()
+	if path == "" {
+		return fmt.Errorf("a dump file to load is required")
+	}
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("configuration is unavailable")
+	}
+	ids, err := aichat.ParseMappings(cmd.StringSlice("map"))
+	if err != nil {
+		return err
+	}
+	personaNames, err := aichat.ParseMappings(cmd.StringSlice("map-persona"))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return &errs.StorageError{Op: "open", Path: path, Err: err}
+	}
+	defer func() { _ = f.Close() }()
+	contexts, assignments, err := aichat.ReadDump(f, aichat.Remap{IDs: ids, Personas: personaNames})
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	storage, err := aichat.NewContextStorage(config.DataDir)
+	if err != nil {
+		return fmt.Errorf("open context storage: %w", err)
+	}
+	defer func() { _ = storage.Close() }()
+	imported, err := storage.ImportContext(contexts)
+	if err != nil {
+		return &errs.StorageError{Op: "import", Path: config.DataDir, Err: err}
+	}
+	result := loadResult{File: path, Messages: imported, Skipped: len(contexts) - imported}
+	if a := s.runningAIChat(); a != nil {
+		result.Personas = a.RestorePersonas(assignments)
+	}
+
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, result)
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Loaded %d messages (%d already stored) and %d of %d persona assignments from %s\n",
+		result.Messages, result.Skipped, result.Personas, len(assignments), path)
+	return nil
+}
+
+type dumpResult struct {
+	File     string `json:"file"`
+	Messages int    `json:"messages"`
+	Personas int    `json:"personas"`
+}
+
+type loadResult struct {
+	File     string `json:"file"`
+	Messages int    `json:"messages"`
+	Skipped  int    `json:"skipped"` // Messages already stored
+	Personas int    `json:"personas"`
+}
+
+type searchResult struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Role        string    `json:"role"`
+	ChannelID   string    `json:"channel_id"`
+	UserID      string    `json:"user_id"`
+	PersonaName string    `json:"persona_name"`
+	Message     string    `json:"message"`
+	Snippet     string    `json:"snippet"` // Excerpt with matched terms in [brackets]
+}
+
+// writeSearchMatches prints one line per match, newest first
+func writeSearchMatches(w io.Writer, matches []aichat.SearchMatch) {
+	if len(matches) == 0 {
+		_, _ = fmt.Fprintln(w, "No stored messages match.")
+		return
+	}
+	for _, m := range matches {
+		_, _ = fmt.Fprintf(w, "%s  %-9s  %s  %s  %s: %s\n",
+			m.Timestamp.Local().Format(time.DateTime), m.Role, m.ChannelID, m.UserID, m.PersonaName,
+			strings.Join(strings.Fields(m.Snippet), " "))
+	}
+}
+
+func newTopicCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "topic",
+		Usage: "Manage channel topics and purposes",
+		Commands: []*cli.Command{
+			{
+				Name:   "set",
+				Usage:  "Set a channel's topic, or its purpose with --purpose. Channels kept by the topic guard are restored to their configured text.",
+				Action: cmdWithBot(setTopic, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "channel",
+						Aliases:  []string{"c"},
+						Usage:    "Channel ID to update",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "text",
+						Aliases:  []string{"t"},
+						Usage:    "New topic or purpose, empty clears it",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "purpose",
+						Usage: "Set the channel purpose instead of the topic",
+					},
+				},
+			},
+		},
+	}
+}
+
+func setTopic(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	channel, text := cmd.String("channel"), cmd.String("text")
+	field := topic.FieldTopic
+	if cmd.Bool("purpose") {
+		field = topic.FieldPurpose
+	}
+
+	if s.slack.Client() == nil {
+		return fmt.Errorf("slack client is unavailable")
+	}
+	if err := topic.Set(ctx, s.slack, channel, field, text); err != nil {
+		return fmt.Errorf("set channel %s: %w", field, err)
+	}
+	s.log.Info("Channel "+field+" updated", zap.String("channel", channel))
+
+	if kept, ok := s.configManager.GetTopicConfig().Channels[channel]; ok {
+		if (field == topic.FieldTopic && kept.Topic != nil) || (field == topic.FieldPurpose && kept.Purpose != nil) {
+			s.log.Warn("Channel "+field+" is kept by the topic guard and will be restored", zap.String("channel", channel))
+		}
+	}
+
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, topicResult{Channel: channel, Field: field, Text: text})
+	}
+	return nil
+}
+
+type topicResult struct {
+	Channel string `json:"channel"`
+	Field   string `json:"field"` // topic or purpose
+	Text    string `json:"text"`
+}
+
+func newFeedbackCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "feedback",
+		Usage: "Manage the anonymous feedback audit log",
+		Commands: []*cli.Command{
+			{
+				Name:   "keygen",
+				Usage:  "Generate an audit key pair. Put the public key in feedback.audit_public_key and keep the private key offline.",
+				Action: cmdWithBot(feedbackKeygen, s),
+			},
+			{
+				Name:   "reveal",
+				Usage:  "Reveal who sent a piece of feedback, for abuse cases only",
+				Action: cmdWithBot(feedbackReveal, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "id",
+						Usage:    "Feedback ID shown with the relayed message, e.g. F1a2b3c4d",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "key",
+						Usage:    "Audit private key from keygen",
+						Sources:  cli.EnvVars("FEEDBACK_AUDIT_KEY"),
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func feedbackKeygen(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	public, private, err := feedback.GenerateKeys()
+	if err != nil {
+		return err
+	}
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, feedbackKeysResult{PublicKey: public, PrivateKey: private})
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Public key:  %s\nPrivate key: %s\n", public, private)
+	return nil
+}
+
+type feedbackKeysResult struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+func feedbackReveal(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("configuration is unavailable")
+	}
+	id := cmd.String("id")
+	entry, err := feedback.Lookup(config.DataDir, id)
+	if err != nil {
+		return fmt.Errorf("look up feedback %s: %w", id, err)
+	}
+	record, err := feedback.Reveal(cmd.String("key"), entry.Sealed)
+	if err != nil {
+		return fmt.Errorf("reveal feedback %s: %w", id, err)
+	}
+	if record.ID != entry.ID {
+		return fmt.Errorf("sealed record is for feedback %s, not %s", record.ID, entry.ID)
+	}
+	s.log.Warn("Revealed feedback sender", zap.String("id", id))
+
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, feedbackRevealResult{ID: id, User: record.User, SentAt: record.SentAt, Channel: entry.Channel, TS: entry.TS})
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Feedback %s was sent by %s at %s\n", id, record.User, record.SentAt.Format(time.RFC3339))
+	return nil
+}
+
+type feedbackRevealResult struct {
+	ID      string    `json:"id"`
+	User    string    `json:"user"`
+	SentAt  time.Time `json:"sent_at"`
+	Channel string    `json:"channel"`
+	TS      string    `json:"ts"` // Relayed message timestamp
+}
+
+func newOutboxCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "outbox",
+		Usage: "Inspect and cancel replies held for their send delay",
+		Commands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List the replies waiting to be sent",
+				Action: cmdWithBot(outboxList, s),
+			},
+			{
+				Name:   "cancel",
+				Usage:  "Drop a held reply before it's sent",
+				Action: cmdWithBot(outboxCancel, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "id",
+						Usage:    "Held reply ID from outbox list",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func outboxList(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	var held []outbox.Held
+	if s.outbox != nil {
+		held = s.outbox.Pending()
+	}
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, held)
+	}
+	if len(held) == 0 {
+		_, _ = fmt.Fprintln(cmd.Root().Writer, "No replies are held")
+		return nil
+	}
+	for _, h := range held {
+		_, _ = fmt.Fprintf(cmd.Root().Writer, "%s\t%s\t%s\tsends in %s\t%s\n",
+			h.ID, h.Feature, h.Channel, time.Until(h.SendAt).Round(time.Second), h.Preview)
+	}
+	return nil
+}
+
+func outboxCancel(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	if s.outbox == nil {
+		return fmt.Errorf("no feature holds its replies, set send delays under outbox.features")
+	}
+	id := cmd.String("id")
+	held, ok := s.outbox.Cancel(ctx, id, "cli")
+	if !ok {
+		return fmt.Errorf("no reply %s is held, it may have been sent already", id)
+	}
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, held)
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Cancelled %s reply %s in %s\n", held.Feature, held.ID, held.Channel)
+	return nil
+}
+
+func newRestoreCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:   "restore",
+		Usage:  "Replace the data directory's state with a backup archive. Stop the bot first.",
+		Action: cmdWithBot(restore, s),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "from",
+				Usage:    "Backup archive to restore, e.g. backups/slackbot-20250307T030000Z.tar.gz",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Skip the confirmation prompt",
+			},
+		},
+	}
+}
+
+func restore(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	config := s.configManager.GetConfig()
+	if config == nil {
+		return fmt.Errorf("configuration is unavailable")
+	}
+	if control.Available(ctx, config.ControlSocket) {
+		return fmt.Errorf("the bot is running, stop it before restoring a backup")
+	}
+	from := cmd.String("from")
+	if _, err := os.Stat(from); err != nil {
+		return &errs.StorageError{Op: "open", Path: from, Err: err}
+	}
+
+	out := messageWriter(cmd)
+	if !cmd.Bool("yes") && !confirm(cmd.Root().Reader, out, fmt.Sprintf("Replace the state in %s with %s?", config.DataDir, from)) {
+		_, _ = fmt.Fprintln(out, "Aborted.")
+		return nil
+	}
+
+	// Keep the current state so a mistaken restore can be undone
+	snapshot, err := backup.Snapshot(ctx, config.DataDir, config.Backup.Dir, time.Now())
+	if err != nil {
+		return fmt.Errorf("back up current state before restoring: %w", err)
+	}
+	s.log.Info("Backed up current state before restoring", zap.String("path", snapshot))
+
+	restored, err := backup.Restore(from, config.DataDir)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", from, err)
+	}
+	s.log.Info("Restored backup", zap.String("from", from), zap.Int("files", len(restored)))
+
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, restoreResult{From: from, Restored: restored, Previous: snapshot})
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Restored %d files from %s\n", len(restored), from)
+	for _, name := range restored {
+		_, _ = fmt.Fprintf(cmd.Root().Writer, "  %s\n", name)
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "The previous state was saved to %s\n", snapshot)
+	return nil
+}
+
+type restoreResult struct {
+	From     string   `json:"from"`
+	Restored []string `json:"restored"`
+	Previous string   `json:"previous"` // Snapshot of the state before restoring
+}
+
+func newUsersCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "users",
+		Usage: "Inspect the workspace's users",
+		Commands: []*cli.Command{
+			{
+				Name:   "export",
+				Usage:  "Export the workspace's members and whether the user watch knows them, e.g. to reconcile with HR records",
+				Action: cmdWithBot(usersExport, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "csv or json, defaults to json with --output json and csv otherwise",
+					},
+					&cli.BoolFlag{
+						Name:  "include-deleted",
+						Usage: "Include deactivated users",
+					},
+				},
+			},
+			{
+				Name:  "undelivered",
+				Usage: "Inspect user notifications that failed to post, and the dead-lettered ones no longer retried",
+				Commands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "List the notifications waiting to be retried and the dead-lettered ones",
+						Action: cmdWithBot(usersUndeliveredList, s),
+					},
+					{
+						Name:   "retry",
+						Usage:  "Retry a notification, or every dead-lettered one, with its attempts reset",
+						Action: cmdWithBot(usersUndeliveredRetry, s),
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "id", Usage: "Notification ID from list, defaults to every dead-lettered notification"},
+						},
+					},
+					{
+						Name:   "discard",
+						Usage:  "Drop a notification, or every dead-lettered one",
+						Action: cmdWithBot(usersUndeliveredDiscard, s),
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "id", Usage: "Notification ID from list, defaults to every dead-lettered notification"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func usersUndeliveredList(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	notifications := s.userWatch.Undelivered()
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, notifications)
+	}
+	w := cmd.Root().Writer
+	if len(notifications) == 0 {
+		_, _ = fmt.Fprintln(w, "Every user notification was delivered")
+		return nil
+	}
+	for _, n := range notifications {
+		state := "retrying " + n.NextAttempt.Local().Format(time.RFC3339)
+		if n.Dead {
+			state = "dead"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s (%s)\t%d attempts\t%s\t%s\n", n.ID, n.User.Name, n.User.ID, n.Attempts, state, n.LastError)
+	}
+	return nil
+}
+
+func usersUndeliveredRetry(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	n, err := s.userWatch.RetryUndelivered(cmd.String("id"))
+	if err != nil {
+		return fmt.Errorf("retry notifications: %w", err)
+	}
+	if n == 0 && cmd.String("id") != "" {
+		return fmt.Errorf("no undelivered notification %q", cmd.String("id"))
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Queued %d notifications to retry\n", n)
+	return nil
+}
+
+func usersUndeliveredDiscard(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	n, err := s.userWatch.DiscardUndelivered(cmd.String("id"))
+	if err != nil {
+		return fmt.Errorf("discard notifications: %w", err)
+	}
+	if n == 0 && cmd.String("id") != "" {
+		return fmt.Errorf("no undelivered notification %q", cmd.String("id"))
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Discarded %d notifications\n", n)
+	return nil
+}
+
+func usersExport(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	format := cmd.String("format")
+	if format == "" {
+		format = "csv"
+		if jsonOutput(cmd) {
+			format = "json"
+		}
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unsupported format %q, use csv or json", format)
+	}
+
+	roster, err := s.userWatch.Roster(ctx, cmd.Bool("include-deleted"))
+	if err != nil {
+		return fmt.Errorf("export users: %w", err)
+	}
+	if format == "json" {
+		return writeJSON(cmd.Root().Writer, roster)
+	}
+	return writeRosterCSV(cmd.Root().Writer, roster)
+}
+
+// writeRosterCSV prints a roster with a header row. Email is empty without the
+// users:read.email scope.
+func writeRosterCSV(w io.Writer, roster []user.RosterUser) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "name", "real_name", "title", "email", "deleted", "known"})
+	for _, u := range roster {
+		_ = cw.Write([]string{u.ID, u.Name, u.RealName, u.Title, u.Email, strconv.FormatBool(u.Deleted), strconv.FormatBool(u.Known)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func newAnnounceCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "announce",
+		Usage: "Broadcast announcements to channels and track who acknowledges them",
+		Commands: []*cli.Command{
+			{
+				Name:   "send",
+				Usage:  "Post an announcement to channels with a reaction to acknowledge it",
+				Action: cmdWithBot(announceSend, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "id",
+						Usage:    "Name for the announcement, e.g. holiday-hours",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "text",
+						Usage:    "Announcement text",
+						Required: true,
+					},
+					&cli.StringSliceFlag{
+						Name:     "channel",
+						Usage:    "Channel ID to post in, repeat for each channel",
+						Required: true,
+					},
+					&cli.DurationFlag{
+						Name:  "deadline",
+						Usage: "How long people have to acknowledge, defaults to announce.broadcasts.deadline",
+					},
+				},
+			},
+			{
+				Name:   "status",
+				Usage:  "Show who has acknowledged each announcement",
+				Action: cmdWithBot(announceStatus, s),
+			},
+		},
+	}
+}
+
+func announceSend(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	if s.broadcaster == nil {
+		return fmt.Errorf("broadcasts are disabled, set announce.broadcasts.enabled")
+	}
+	for _, channel := range cmd.StringSlice("channel") {
+		if !conversation.ValidID(channel) {
+			return fmt.Errorf("%q isn't a channel ID", channel)
+		}
+	}
+	a, err := s.broadcaster.Send(ctx, cmd.String("id"), cmd.String("text"), cmd.StringSlice("channel"), cmd.Duration("deadline"))
+	if a.ID == "" {
+		return err
+	}
+	if err != nil {
+		s.log.Warn("Announcement wasn't posted everywhere", zap.Error(err))
+	}
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, a)
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Posted %s to %d channels, acknowledgments are due %s\n",
+		a.ID, len(a.Posts), a.Deadline.Format(time.RFC1123))
+	return nil
+}
+
+func announceStatus(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	var announcements []announce.Announcement
+	if s.broadcaster != nil {
+		announcements = s.broadcaster.Announcements()
+	}
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, announcements)
+	}
+	if len(announcements) == 0 {
+		_, _ = fmt.Fprintln(cmd.Root().Writer, "No announcements have been posted")
+		return nil
+	}
+	for _, a := range announcements {
+		state := "due " + a.Deadline.Format(time.RFC1123)
+		if !a.ReportedAt.IsZero() {
+			state = fmt.Sprintf("%d outstanding", len(a.Outstanding))
+		}
+		_, _ = fmt.Fprintf(cmd.Root().Writer, "%s\t%d channels\t%d acknowledged\t%s\n", a.ID, len(a.Posts), len(a.Acks), state)
+	}
+	return nil
+}
+
+func newSchedulesCommand(s *Bot) *cli.Command {
+	return &cli.Command{
+		Name:  "schedules",
+		Usage: "Inspect and try the messages posted on schedules",
+		Commands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List the schedules and when each posts next",
+				Action: cmdWithBot(schedulesList, s),
+			},
+			{
+				Name:   "run",
+				Usage:  "Post a schedule's message now, off its schedule",
+				Action: cmdWithBot(schedulesRun, s),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "Schedule name from schedules list",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func schedulesList(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	var runs []scheduler.Run
+	if sc := s.runningScheduler(); sc != nil {
+		runs = sc.Runs()
+	}
+	if jsonOutput(cmd) {
+		return writeJSON(cmd.Root().Writer, runs)
+	}
+	if len(runs) == 0 {
+		_, _ = fmt.Fprintln(cmd.Root().Writer, "No schedules are configured")
+		return nil
+	}
+	for _, r := range runs {
+		next := "never"
+		if !r.Next.IsZero() {
+			next = r.Next.Format(time.RFC1123)
+		}
+		_, _ = fmt.Fprintf(cmd.Root().Writer, "%s\t%s\t%s\tnext %s\n", r.Name, r.Cron, r.Channel, next)
+	}
+	return nil
+}
+
+func schedulesRun(ctx context.Context, cmd *cli.Command, s *Bot) error {
+	sc := s.runningScheduler()
+	if sc == nil {
+		return fmt.Errorf("no schedules are configured, add them under schedules")
+	}
+	name := cmd.String("name")
+	if err := sc.RunNow(ctx, name); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "Posted %s\n", name)
 	return nil
 }
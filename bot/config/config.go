@@ -2,7 +2,9 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
@@ -14,12 +16,43 @@ import (
 	"github.com/urfave/cli/v3"
 	"slackbot.arpa/bot/ai"
 	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/analytics"
+	"slackbot.arpa/bot/announce"
+	"slackbot.arpa/bot/api"
+	"slackbot.arpa/bot/backup"
 	"slackbot.arpa/bot/chat"
+	"slackbot.arpa/bot/claim"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/eventbus"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/facts"
+	"slackbot.arpa/bot/feedback"
+	"slackbot.arpa/bot/filescan"
+	"slackbot.arpa/bot/handoff"
 	"slackbot.arpa/bot/http"
+	"slackbot.arpa/bot/incident"
+	"slackbot.arpa/bot/karma"
+	"slackbot.arpa/bot/lastseen"
+	"slackbot.arpa/bot/leader"
+	"slackbot.arpa/bot/loopguard"
+	"slackbot.arpa/bot/membership"
+	"slackbot.arpa/bot/outbox"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/personastore"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/bot/retract"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/selftest"
 	"slackbot.arpa/bot/showerthought"
 	"slackbot.arpa/bot/slack"
+	"slackbot.arpa/bot/subtype"
+	"slackbot.arpa/bot/topic"
+	"slackbot.arpa/bot/trigger"
 	"slackbot.arpa/bot/user"
 	"slackbot.arpa/bot/vibecheck"
+	"slackbot.arpa/logger"
+	"slackbot.arpa/tools/emoji"
 )
 
 type Environment string
@@ -81,34 +114,69 @@ func (l BuildOpts) MakeConfig(cmd *cli.Command) (Config, error) {
 		SlackToken:             cmd.String("slack-token"),
 		SlackSigningSecret:     cmd.String("slack-signing-secret"),
 		OpenAIAPIKey:           cmd.String("openai-api-key"),
+		OpenAIModel:            cmd.String("openai-model"),
 		PreferredUsers:         cmd.StringSlice("slack-preferred-user"),
 		PreferredChannels:      cmd.StringSlice("slack-preferred-channels"),
+		TriggerAliases:         cmd.StringSlice("trigger-aliases"),
 		UserNotifyChannel:      cmd.String("slack-user-notify-channel"),
 		SlackEventsPath:        cmd.String("slack-events-path"),
+		SlackInteractionsPath:  cmd.String("slack-interactions-path"),
+		SlackCommandsPath:      cmd.String("slack-commands-path"),
+		SlackBreakerThreshold:  cmd.Int("slack-breaker-threshold"),
+		SlackBreakerCooldown:   cmd.Duration("slack-breaker-cooldown"),
+		SlackRejoinChannels:    cmd.Bool("slack-rejoin-channels"),
+		SlackDMRespectDND:      cmd.Bool("slack-dm-respect-dnd"),
+		SlackDMUrgentKinds:     cmd.StringSlice("slack-dm-urgent-kinds"),
 		ConfigFile:             cmd.String("config-file"),
 		PersonasConfig:         cmd.String("personas-config"),
 		PersonasStickyDuration: cmd.Duration("personas-sticky-duration"),
 		VibecheckBanDuration:   cmd.Duration("vibecheck-ban-duration"),
+
+		SlackSignatureTolerance: cmd.Duration("slack-signature-tolerance"),
+		HTTPAdminToken:          cmd.String("http-admin-token"),
+		LogLevels:               parseLogLevels(cmd.StringSlice("log-levels")),
+		SlackTeamIDs:            cmd.StringSlice("slack-team-ids"),
+		MessageSubtypes:         cmd.StringSlice("message-subtypes"),
+		ControlSocket:           cmd.String("control-socket"),
 	}
 
 	return newConfig(opts)
 }
 
 type configOpts struct {
-	Version            string
-	BuildTime          string
-	LogLevel           string
-	Environment        string
-	DataDir            string
-	ServerPort         uint32
-	SlackToken         string
-	SlackSigningSecret string
-	OpenAIAPIKey       string
-	PreferredUsers     []string
-	PreferredChannels  []string
-	UserNotifyChannel  string
-	SlackEventsPath    string
-	ConfigFile         string
+	Version               string
+	BuildTime             string
+	LogLevel              string
+	Environment           string
+	DataDir               string
+	ServerPort            uint32
+	SlackToken            string
+	SlackSigningSecret    string
+	OpenAIAPIKey          string
+	OpenAIModel           string
+	PreferredUsers        []string
+	PreferredChannels     []string
+	UserNotifyChannel     string
+	TriggerAliases        []string
+	SlackEventsPath       string
+	SlackInteractionsPath string
+	SlackCommandsPath     string
+	SlackBreakerThreshold int
+	SlackBreakerCooldown  time.Duration
+	SlackRejoinChannels   bool
+	SlackDMRespectDND     bool
+	SlackDMUrgentKinds    []string
+	ConfigFile            string
+
+	SlackSignatureTolerance time.Duration
+	HTTPAuth                map[string]http.AuthConfig
+	HTTPAdminToken          string
+	LogLevels               map[string]string
+	SlackTeamIDs            []string
+	MessageSubtypes         []string
+	Shadow                  []string
+	MessageMetadata         bool
+	Blocklist               slack.BlocklistFileConfig
 	// AI Chat Personas Configuration
 	PersonasConfig         string
 	PersonasStickyDuration time.Duration
@@ -116,15 +184,119 @@ type configOpts struct {
 	AIChatMaxContextMessages int
 	AIChatMaxContextAge      time.Duration
 	AIChatMaxContextTokens   int
+	AIChatMaxTrackedUsers    int
 	AIChatRateLimitEnabled   bool
-	// Vibecheck ban duration
+	AIChatMessageRate        aichat.RateLimit
+	AIChatMentionRate        aichat.RateLimit
+	AIChatQueueSize          int
+	AIChatQueueMaxAge        time.Duration
+	AIChatPersonaRules       []aichat.PersonaRule
+	AIChatChannelPersonas    map[string]string
+	AIChatNameMentions       bool
+	AIChatEngagement         aichat.EngagementPolicy
+	AIChatChannelEngagement  map[string]aichat.EngagementPolicy
+	AIChatLanguage           aichat.LanguagePolicy
+	AIChatChannelLanguage    map[string]aichat.LanguagePolicy
+	// Vibecheck ban duration and pass/fail strategy
 	VibecheckBanDuration time.Duration
+	VibecheckJudgement   vibecheck.JudgementConfig
+	VibecheckReplyMode   string
+	VibecheckImmunity    vibecheck.ImmunityConfig
+	VibecheckOnDemand    vibecheck.OnDemandConfig
+	VibecheckJail        vibecheck.JailConfig
+	VibecheckWelcomeBack vibecheck.WelcomeBackConfig
+	VibecheckOnEdit      retract.FileConfig
 	// Chat responses
-	ChatResponses []chat.Response
+	ChatResponses          []chat.Response
+	ChatReactionThresholds []chat.ReactionThreshold
+	ChatOnEdit             retract.FileConfig
+	ChatTimezone           string
+	// Names and icons features post with
+	UserPersona       persona.Config
+	ChatPersona       persona.Config
+	VibecheckPersona  persona.Config
+	MembershipPersona persona.Config
 	// Showerthought
 	ShowerthoughtEnabled            bool
 	ShowerthoughtBusinessHoursStart int
 	ShowerthoughtBusinessHoursEnd   int
+	// Loop guard
+	LoopGuardEnabled      bool
+	LoopGuardBurstLimit   int
+	LoopGuardBurstWindow  time.Duration
+	LoopGuardRepeatLimit  int
+	LoopGuardRepeatWindow time.Duration
+	LoopGuardCooldown     time.Duration
+	LoopGuardAlertChannel string
+	LoopGuardDenyApps     []string
+	LoopGuardDenyBots     []string
+	LoopGuardDenyUsers    []string
+	// Incident mode
+	IncidentDefaultDuration time.Duration
+	IncidentMaxDuration     time.Duration
+	// LLM providers tried in order
+	AIProviders []ai.ProviderConfig
+	// Named AI endpoints and which features use them
+	AIEndpoints map[string]ai.EndpointConfig
+	AIRoutes    map[string]string
+	// How often user watch checks the user list
+	UserPoll user.PollConfig
+	// How user watch retries notifications that failed to post
+	UserRetry user.RetryConfig
+	// Channels watched for members joining and leaving
+	MembershipChannels map[string]membership.ChannelConfig
+	// Unix socket where the running bot serves CLI commands
+	ControlSocket string
+	// Scheduled snapshots of the data directory
+	BackupEnabled  bool
+	BackupInterval time.Duration
+	BackupKeep     int
+	BackupDir      string
+	// Startup and shutdown announcements
+	AnnounceEnabled  bool
+	AnnounceChannel  string
+	AnnouncePersona  persona.Config
+	AnnounceFeatures map[string]announce.FeatureFileConfig
+	// Announcements posted to several channels with acknowledgments tracked
+	AnnounceBroadcasts announce.BroadcastsFileConfig
+	// Channels whose topic and purpose are kept fixed
+	TopicChannels map[string]topic.ChannelConfig
+	TopicInterval time.Duration
+	TopicNotify   bool
+	TopicPersona  persona.Config
+	// Moderation of files shared in channels
+	FileScan filescan.FileConfig
+	// Anonymous feedback relayed from DMs
+	Feedback feedback.FileConfig
+	// Send delays for chat and aichat replies
+	Outbox outbox.FileConfig
+	// Threads handed over to people
+	Handoff handoff.FileConfig
+	// Channel activity counts and weekly reports
+	Analytics analytics.FileConfig
+	SelfTest  selftest.FileConfig
+	// Lease so only one replica responds to events
+	Leader leader.FileConfig
+	// Stream events from receivers to worker processes
+	EventBus eventbus.FileConfig
+	// Channel facts remembered on request
+	Facts facts.FileConfig
+	// gRPC admin API
+	GRPC api.FileConfig
+	// When people were last active, and weekly inactive member reports
+	LastSeen lastseen.FileConfig
+	// How long stored user data is kept
+	Retention retention.FileConfig
+	// Personas created by admins from Slack
+	Personas personastore.FileConfig
+	// How many features respond to one message
+	Claim claim.FileConfig
+	// Messages posted on cron schedules
+	Schedules []scheduler.ScheduleFileConfig
+	// Explaining bot messages to admins who react to them
+	Explain explain.FileConfig
+	// Karma from the reactions people's messages collect
+	Karma karma.FileConfig
 }
 
 type Config struct {
@@ -142,6 +314,38 @@ type Config struct {
 	AI            ai.Config
 	AIChat        aichat.Config
 	ShowerThought showerthought.Config
+	LoopGuard     loopguard.Config
+	Incident      incident.Config
+	Membership    membership.Config
+	Backup        backup.Config
+	Announce      announce.Config
+	Topic         topic.Config
+	FileScan      filescan.Config
+	Feedback      feedback.Config
+	Outbox        outbox.Config
+	Handoff       handoff.Config
+	Analytics     analytics.Config
+	SelfTest      selftest.Config
+	Leader        leader.Config
+	EventBus      eventbus.Config
+	Facts         facts.Config
+	GRPC          api.Config
+	LastSeen      lastseen.Config
+	Retention     retention.Config
+	Personas      personastore.Config
+	Claim         claim.Config
+	Scheduler     scheduler.Config
+	Explain       explain.Config
+	Karma         karma.Config
+
+	// TriggerAliases are words treated like a bot mention by mention-driven features
+	TriggerAliases []string
+	// LogLevels overrides LogLevel for named features
+	LogLevels map[string]string
+	// MessageSubtypes are the message subtypes handled besides plain messages
+	MessageSubtypes []string
+	// ControlSocket is where the running bot serves CLI commands
+	ControlSocket string
 }
 
 func newConfig(opts configOpts) (Config, error) {
@@ -153,27 +357,235 @@ func newConfig(opts configOpts) (Config, error) {
 	}
 
 	personas := make(map[string]string)
+	personaOverrides := make(map[string]aichat.PersonaOverrides)
+	personaWeights := make(map[string]float64)
 	if opts.PersonasConfig != "" {
 		if strings.HasPrefix(opts.PersonasConfig, "map[") {
 			personas = parseGoMapString(opts.PersonasConfig)
 		} else {
-			var personasData map[string]any
+			var personasData map[string]aichat.Persona
 			err := yaml.Unmarshal([]byte(opts.PersonasConfig), &personasData)
 			if err != nil {
 				err = json.Unmarshal([]byte(opts.PersonasConfig), &personasData)
 				if err != nil {
-					return Config{}, fmt.Errorf("failed to parse personas config: %w", err)
+					return Config{}, &errs.ConfigError{Key: "personas-config", Err: fmt.Errorf("failed to parse personas config: %w", err)}
 				}
 			}
 
-			for name, prompt := range personasData {
-				if promptStr, ok := prompt.(string); ok {
-					personas[name] = promptStr
+			for name, persona := range personasData {
+				if persona.Prompt == "" {
+					continue
+				}
+				if err := persona.Validate(); err != nil {
+					return Config{}, &errs.ConfigError{Key: "aichat.personas." + name, Err: err}
+				}
+				personas[name] = persona.Prompt
+				if overrides := persona.Overrides(); overrides != (aichat.PersonaOverrides{}) {
+					personaOverrides[name] = overrides
+				}
+				if persona.Weight != nil {
+					personaWeights[name] = *persona.Weight
 				}
 			}
 		}
 	}
 
+	if _, err := logger.ParseFeatureLevels(opts.LogLevels); err != nil {
+		return Config{}, &errs.ConfigError{Key: "log_levels", Err: err}
+	}
+
+	for id := range opts.MembershipChannels {
+		if !conversation.ValidID(id) {
+			return Config{}, &errs.ConfigError{Key: "membership.channels." + id, Err: errors.New("not a channel ID")}
+		}
+	}
+
+	if err := validateEmoji(opts); err != nil {
+		return Config{}, err
+	}
+	for i, feature := range opts.Shadow {
+		if !slices.Contains(slack.ShadowFeatures, feature) {
+			return Config{}, &errs.ConfigError{Key: fmt.Sprintf("shadow[%d]", i), Err: fmt.Errorf("unknown feature %q, must be one of %s", feature, strings.Join(slack.ShadowFeatures, ", "))}
+		}
+	}
+	blocklist, err := blocklistConfig(opts.Blocklist)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := validateChatReactionThresholds(opts.ChatReactionThresholds); err != nil {
+		return Config{}, err
+	}
+	if err := validateChatActive(opts); err != nil {
+		return Config{}, err
+	}
+	if err := validateVibecheckJail(opts.VibecheckJail); err != nil {
+		return Config{}, err
+	}
+	if err := opts.VibecheckWelcomeBack.Check(); err != nil {
+		return Config{}, &errs.ConfigError{Key: "vibecheck.welcome_back.message", Err: err}
+	}
+	if err := opts.VibecheckJudgement.Check(ai.Config{OpenAIAPIKey: opts.OpenAIAPIKey, Providers: opts.AIProviders, Endpoints: opts.AIEndpoints}.Enabled()); err != nil {
+		return Config{}, err
+	}
+
+	httpAuth, err := httpAuthConfig(opts.HTTPAuth, opts.HTTPAdminToken)
+	if err != nil {
+		return Config{}, err
+	}
+
+	triggerAliases := opts.TriggerAliases
+	if len(triggerAliases) == 0 {
+		triggerAliases = trigger.DefaultAliases
+	}
+	messageSubtypes := opts.MessageSubtypes
+	if len(messageSubtypes) == 0 {
+		messageSubtypes = subtype.DefaultAllowed
+	}
+	controlSocket := opts.ControlSocket
+	if controlSocket == "" {
+		controlSocket = filepath.Join(dataDir, "control.sock")
+	}
+	if opts.BackupEnabled && opts.BackupInterval <= 0 {
+		return Config{}, &errs.ConfigError{Key: "backup.interval", Err: errors.New("must be positive")}
+	}
+	if opts.BackupKeep < 0 {
+		return Config{}, &errs.ConfigError{Key: "backup.keep", Err: errors.New("must not be negative")}
+	}
+	announceFeatures := announce.NewFeatures(opts.AnnounceChannel, opts.AnnounceFeatures)
+	for _, name := range slices.Sorted(maps.Keys(announceFeatures)) {
+		feature := announceFeatures[name]
+		if opts.AnnounceEnabled && feature.Channel == "" {
+			return Config{}, &errs.ConfigError{Key: "announce.features." + name + ".channel", Err: errors.New("no channel set for the feature or announcements")}
+		}
+		if err := announce.Check(feature.Startup); err != nil {
+			return Config{}, &errs.ConfigError{Key: "announce.features." + name + ".startup", Err: err}
+		}
+		if err := announce.Check(feature.Shutdown); err != nil {
+			return Config{}, &errs.ConfigError{Key: "announce.features." + name + ".shutdown", Err: err}
+		}
+	}
+	broadcasts, err := broadcastsConfig(opts.AnnounceBroadcasts)
+	if err != nil {
+		return Config{}, err
+	}
+	broadcasts.DataDir, broadcasts.Persona = dataDir, opts.AnnouncePersona
+	if len(opts.TopicChannels) > 0 && opts.TopicInterval <= 0 {
+		return Config{}, &errs.ConfigError{Key: "topic.interval", Err: errors.New("must be positive")}
+	}
+	if opts.AIChatMaxTrackedUsers < 0 {
+		return Config{}, &errs.ConfigError{Key: "aichat.max_tracked_users", Err: errors.New("must not be negative")}
+	}
+	if err := validateRateLimits(opts); err != nil {
+		return Config{}, err
+	}
+	if err := validateAIRoutes(opts); err != nil {
+		return Config{}, err
+	}
+	if err := validateLanguages(opts); err != nil {
+		return Config{}, err
+	}
+	if err := validateChannelPersonas(opts, personas); err != nil {
+		return Config{}, err
+	}
+	for i, rule := range opts.AIChatPersonaRules {
+		if err := rule.Validate(); err != nil {
+			return Config{}, &errs.ConfigError{Key: fmt.Sprintf("aichat.persona_rules[%d]", i), Err: err}
+		}
+	}
+	fileScan, err := fileScanConfig(opts.FileScan, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	feedbackConfig, err := feedbackConfig(opts.Feedback, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	outboxConfig, err := outboxConfig(opts.Outbox)
+	if err != nil {
+		return Config{}, err
+	}
+	analyticsConfig, err := analyticsConfig(opts.Analytics, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	selfTestConfig, err := selfTestConfig(opts.SelfTest, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	leaderConfig, err := leaderConfig(opts.Leader)
+	if err != nil {
+		return Config{}, err
+	}
+	eventBusConfig, err := eventBusConfig(opts.EventBus)
+	if err != nil {
+		return Config{}, err
+	}
+	chatOnEdit, err := onEditConfig(opts.ChatOnEdit, "chat.on_edit")
+	if err != nil {
+		return Config{}, err
+	}
+	vibecheckOnEdit, err := onEditConfig(opts.VibecheckOnEdit, "vibecheck.on_edit")
+	if err != nil {
+		return Config{}, err
+	}
+	grpcConfig, err := grpcConfig(opts.GRPC, opts.ServerPort)
+	if err != nil {
+		return Config{}, err
+	}
+	lastSeenConfig, err := lastSeenConfig(opts.LastSeen, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	retentionConfig, err := retentionConfig(opts.Retention)
+	if err != nil {
+		return Config{}, err
+	}
+	personasConfig, err := personasConfig(opts.Personas, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	claimConfig, err := claimConfig(opts.Claim)
+	if err != nil {
+		return Config{}, err
+	}
+	schedulerConfig, err := schedulerConfig(opts.Schedules)
+	if err != nil {
+		return Config{}, err
+	}
+	explainConfig, err := explainConfig(opts.Explain)
+	if err != nil {
+		return Config{}, err
+	}
+	karmaConfig, err := karmaConfig(opts.Karma, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	var factsEnabled bool
+	if opts.Facts.Enabled != nil {
+		factsEnabled = *opts.Facts.Enabled
+	}
+	var maxFacts int
+	if opts.Facts.MaxPerChannel != nil {
+		if maxFacts = *opts.Facts.MaxPerChannel; maxFacts < 0 {
+			return Config{}, &errs.ConfigError{Key: "facts.max_per_channel", Err: errors.New("must not be negative")}
+		}
+	}
+	var responders string
+	if opts.Handoff.Responders != nil {
+		responders = strings.TrimSpace(*opts.Handoff.Responders)
+	}
+	if responders != "" && !strings.HasPrefix(responders, "S") {
+		return Config{}, &errs.ConfigError{Key: "handoff.responders", Err: fmt.Errorf("%q isn't a user group ID, e.g. S0123456789", responders)}
+	}
+	backupDir := opts.BackupDir
+	if backupDir == "" {
+		backupDir = "backups"
+	}
+	if !filepath.IsAbs(backupDir) {
+		backupDir = filepath.Join(dataDir, backupDir)
+	}
+
 	return Config{
 		Version:     opts.Version,
 		BuildTime:   opts.BuildTime,
@@ -182,39 +594,96 @@ func newConfig(opts configOpts) (Config, error) {
 		DataDir:     dataDir,
 		ConfigFile:  opts.ConfigFile,
 		Server: http.Config{
-			ServerPort:     opts.ServerPort,
-			SlackEventPath: opts.SlackEventsPath,
+			ServerPort:            opts.ServerPort,
+			SlackEventPath:        opts.SlackEventsPath,
+			SlackInteractionsPath: opts.SlackInteractionsPath,
+			SlackCommandsPath:     opts.SlackCommandsPath,
+
+			Auth:           httpAuth,
+			AllowedTeamIDs: opts.SlackTeamIDs,
 		},
 		Slack: slack.Config{
 			Token:             opts.SlackToken,
 			SigningSecret:     opts.SlackSigningSecret,
 			Debug:             false,
 			PreferredChannels: opts.PreferredChannels,
+			BreakerThreshold:  opts.SlackBreakerThreshold,
+			BreakerCooldown:   opts.SlackBreakerCooldown,
+			RejoinChannels:    opts.SlackRejoinChannels,
+			HoldDMsDuringDND:  opts.SlackDMRespectDND,
+			UrgentDMKinds:     opts.SlackDMUrgentKinds,
+			NotifyChannel:     opts.UserNotifyChannel,
+			Shadow:            opts.Shadow,
+			ShadowLog:         filepath.Join(dataDir, "shadow.jsonl"),
+			MessageMetadata:   opts.MessageMetadata,
+			Blocklist:         blocklist,
+
+			SignatureTolerance: opts.SlackSignatureTolerance,
 		},
 		User: user.Config{
 			NotifyChannel: opts.UserNotifyChannel,
 			DataDir:       dataDir,
+			Poll:          opts.UserPoll,
+			Persona:       opts.UserPersona,
+			Retry:         opts.UserRetry,
 		},
 		Chat: chat.Config{
 			PreferredUsers: opts.PreferredUsers,
 			Responses:      opts.ChatResponses,
+			DataDir:        dataDir,
+			Persona:        opts.ChatPersona,
+
+			MessageSubtypes:    messageSubtypes,
+			ReactionThresholds: opts.ChatReactionThresholds,
+			OnEdit:             chatOnEdit,
+			Timezone:           opts.ChatTimezone,
 		},
 		Vibecheck: vibecheck.Config{
 			PreferredUsers: opts.PreferredUsers,
 			DataDir:        dataDir,
 			BanDuration:    opts.VibecheckBanDuration,
+			Judgement:      opts.VibecheckJudgement,
+			ReplyMode:      Default(opts.VibecheckReplyMode, vibecheck.ReplyModeChannel),
+			Immunity:       opts.VibecheckImmunity,
+			OnDemand:       opts.VibecheckOnDemand,
+			Jail:           opts.VibecheckJail,
+			WelcomeBack:    opts.VibecheckWelcomeBack,
+			Persona:        opts.VibecheckPersona,
+
+			MessageSubtypes: messageSubtypes,
+			OnEdit:          vibecheckOnEdit,
 		},
 		AI: ai.Config{
 			OpenAIAPIKey: opts.OpenAIAPIKey,
+			Model:        opts.OpenAIModel,
+			Providers:    opts.AIProviders,
+			Endpoints:    opts.AIEndpoints,
+			Routes:       opts.AIRoutes,
 		},
 		AIChat: aichat.Config{
 			DataDir:            dataDir,
 			Personas:           personas,
+			PersonaOverrides:   personaOverrides,
+			PersonaWeights:     personaWeights,
+			PersonaRules:       opts.AIChatPersonaRules,
+			ChannelPersonas:    opts.AIChatChannelPersonas,
+			Engagement:         opts.AIChatEngagement,
+			ChannelEngagement:  opts.AIChatChannelEngagement,
+			Language:           opts.AIChatLanguage,
+			ChannelLanguage:    opts.AIChatChannelLanguage,
 			StickyDuration:     opts.PersonasStickyDuration,
 			MaxContextMessages: opts.AIChatMaxContextMessages,
 			MaxContextAge:      opts.AIChatMaxContextAge,
 			MaxContextTokens:   opts.AIChatMaxContextTokens,
+			MaxTrackedUsers:    opts.AIChatMaxTrackedUsers,
 			RateLimitEnabled:   opts.AIChatRateLimitEnabled,
+			MessageRate:        opts.AIChatMessageRate,
+			MentionRate:        opts.AIChatMentionRate,
+			QueueSize:          opts.AIChatQueueSize,
+			QueueMaxAge:        opts.AIChatQueueMaxAge,
+			TriggerAliases:     triggerAliases,
+			NameMentions:       opts.AIChatNameMentions,
+			MessageSubtypes:    messageSubtypes,
 		},
 		ShowerThought: showerthought.Config{
 			Enabled:            opts.ShowerthoughtEnabled,
@@ -222,9 +691,641 @@ func newConfig(opts configOpts) (Config, error) {
 			BusinessHoursStart: opts.ShowerthoughtBusinessHoursStart,
 			BusinessHoursEnd:   opts.ShowerthoughtBusinessHoursEnd,
 		},
+		LoopGuard: loopguard.Config{
+			Enabled:      opts.LoopGuardEnabled,
+			BurstLimit:   opts.LoopGuardBurstLimit,
+			BurstWindow:  opts.LoopGuardBurstWindow,
+			RepeatLimit:  opts.LoopGuardRepeatLimit,
+			RepeatWindow: opts.LoopGuardRepeatWindow,
+			Cooldown:     opts.LoopGuardCooldown,
+			AlertChannel: Default(opts.LoopGuardAlertChannel, opts.UserNotifyChannel),
+			DenyApps:     opts.LoopGuardDenyApps,
+			DenyBots:     opts.LoopGuardDenyBots,
+			DenyUsers:    opts.LoopGuardDenyUsers,
+		},
+		Incident: incident.Config{
+			DataDir:         dataDir,
+			DefaultDuration: opts.IncidentDefaultDuration,
+			MaxDuration:     opts.IncidentMaxDuration,
+		},
+		Membership: membership.Config{
+			DataDir:  dataDir,
+			Channels: opts.MembershipChannels,
+			Persona:  opts.MembershipPersona,
+		},
+		Backup: backup.Config{
+			Enabled:  opts.BackupEnabled,
+			DataDir:  dataDir,
+			Dir:      backupDir,
+			Interval: opts.BackupInterval,
+			Keep:     opts.BackupKeep,
+		},
+		FileScan:  fileScan,
+		Feedback:  feedbackConfig,
+		Outbox:    outboxConfig,
+		Analytics: analyticsConfig,
+		SelfTest:  selfTestConfig,
+		Leader:    leaderConfig,
+		EventBus:  eventBusConfig,
+		GRPC:      grpcConfig,
+		LastSeen:  lastSeenConfig,
+		Retention: retentionConfig,
+		Personas:  personasConfig,
+		Claim:     claimConfig,
+		Scheduler: schedulerConfig,
+		Explain:   explainConfig,
+		Karma:     karmaConfig,
+		Facts: facts.Config{
+			Enabled:       factsEnabled,
+			DataDir:       dataDir,
+			MaxPerChannel: maxFacts,
+			Canvas:        opts.Facts.Canvas != nil && *opts.Facts.Canvas,
+			Persona:       opts.Facts.Persona,
+		},
+		Handoff: handoff.Config{
+			DataDir:    dataDir,
+			Responders: responders,
+			Persona:    opts.Handoff.Persona,
+		},
+		Topic: topic.Config{
+			Channels: opts.TopicChannels,
+			Interval: opts.TopicInterval,
+			Notify:   opts.TopicNotify,
+			Persona:  opts.TopicPersona,
+		},
+		Announce: announce.Config{
+			Enabled:    opts.AnnounceEnabled,
+			Persona:    opts.AnnouncePersona,
+			Features:   announceFeatures,
+			Broadcasts: broadcasts,
+		},
+		TriggerAliases:  triggerAliases,
+		LogLevels:       opts.LogLevels,
+		MessageSubtypes: messageSubtypes,
+		ControlSocket:   controlSocket,
 	}, nil
 }
 
+// parseLogLevels reads feature=level pairs from the command line. A pair without a level
+// is kept with an empty level so validation reports it.
+func parseLogLevels(pairs []string) map[string]string {
+	if len(pairs) == 0 {
+		return nil
+	}
+	levels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		feature, level, _ := strings.Cut(pair, "=")
+		levels[strings.TrimSpace(feature)] = strings.TrimSpace(level)
+	}
+	return levels
+}
+
+// validateEmoji checks the emoji names in reactions and personas, which may be written
+// with or without colons
+// validateChatReactionThresholds checks each rule has a count, something to do once it's
+// reached and real channel IDs
+func validateChatReactionThresholds(rules []chat.ReactionThreshold) error {
+	for i, r := range rules {
+		key := fmt.Sprintf("chat.reaction_thresholds[%d]", i)
+		if r.Count < 1 {
+			return &errs.ConfigError{Key: key + ".count", Err: errors.New("must be at least 1")}
+		}
+		if strings.TrimSpace(r.Message) == "" && r.Crosspost == "" {
+			return &errs.ConfigError{Key: key, Err: errors.New("needs a message or a crosspost channel")}
+		}
+		if r.Crosspost != "" && !conversation.ValidID(r.Crosspost) {
+			return &errs.ConfigError{Key: key + ".crosspost", Err: fmt.Errorf("%q isn't a channel ID", r.Crosspost)}
+		}
+		for j, id := range r.Channels {
+			if !conversation.ValidID(id) {
+				return &errs.ConfigError{Key: fmt.Sprintf("%s.channels[%d]", key, j), Err: fmt.Errorf("%q isn't a channel ID", id)}
+			}
+		}
+	}
+	return nil
+}
+
+// validateChatActive checks the responses' active hours and days, and the timezone
+// they're in
+func validateChatActive(opts configOpts) error {
+	if opts.ChatTimezone != "" {
+		if _, err := time.LoadLocation(opts.ChatTimezone); err != nil {
+			return &errs.ConfigError{Key: "chat.timezone", Err: err}
+		}
+	}
+	for i, r := range opts.ChatResponses {
+		key := fmt.Sprintf("chat.responses[%d]", i)
+		if r.ActiveHours != "" {
+			if _, _, err := chat.ParseActiveHours(r.ActiveHours); err != nil {
+				return &errs.ConfigError{Key: key + ".active_hours", Err: err}
+			}
+		}
+		if _, err := chat.ParseActiveDays(r.ActiveDays); err != nil {
+			return &errs.ConfigError{Key: key + ".active_days", Err: err}
+		}
+	}
+	return nil
+}
+
+// validateVibecheckJail checks every punishment is known and that jailing has a channel
+// to send users to
+func validateVibecheckJail(c vibecheck.JailConfig) error {
+	punishments := map[string]string{}
+	if c.Punishment != nil && *c.Punishment != "" {
+		punishments["vibecheck.jail.punishment"] = *c.Punishment
+	}
+	for id, p := range c.Channels {
+		if !conversation.ValidID(id) {
+			return &errs.ConfigError{Key: "vibecheck.jail.channels." + id, Err: errors.New("not a channel ID")}
+		}
+		punishments["vibecheck.jail.channels."+id] = p
+	}
+	var jails bool
+	for _, key := range slices.Sorted(maps.Keys(punishments)) {
+		if !slices.Contains(vibecheck.Punishments, punishments[key]) {
+			return &errs.ConfigError{Key: key, Err: fmt.Errorf("must be one of %s", strings.Join(vibecheck.Punishments, ", "))}
+		}
+		jails = jails || punishments[key] == vibecheck.PunishmentJail
+	}
+	if jails && (c.Channel == nil || strings.TrimSpace(*c.Channel) == "") {
+		return &errs.ConfigError{Key: "vibecheck.jail.channel", Err: errors.New("required to send users to jail")}
+	}
+	return nil
+}
+
+func validateEmoji(opts configOpts) error {
+	names := map[string]string{}
+	for i, r := range opts.ChatResponses {
+		for j, name := range r.Reactions {
+			names[fmt.Sprintf("chat.responses[%d].reactions[%d]", i, j)] = name
+		}
+	}
+	for i, r := range opts.ChatReactionThresholds {
+		names[fmt.Sprintf("chat.reaction_thresholds[%d].reaction", i)] = r.Reaction
+	}
+	if r := opts.Outbox.CancelReaction; r != nil {
+		names["outbox.cancel_reaction"] = *r
+	}
+	if r := opts.Outbox.PendingReaction; r != nil && *r != "" {
+		names["outbox.pending_reaction"] = *r
+	}
+	if r := opts.AnnounceBroadcasts.Reaction; r != nil && *r != "" {
+		names["announce.broadcasts.reaction"] = *r
+	}
+	if opts.VibecheckOnDemand.Reaction != nil {
+		names["vibecheck.on_demand.reaction"] = *opts.VibecheckOnDemand.Reaction
+	}
+	if r := opts.Explain.Reaction; r != nil && *r != "" {
+		names["explain.reaction"] = *r
+	}
+	for i, name := range opts.Karma.Upvotes {
+		names[fmt.Sprintf("karma.upvotes[%d]", i)] = name
+	}
+	for i, name := range opts.Karma.Downvotes {
+		names[fmt.Sprintf("karma.downvotes[%d]", i)] = name
+	}
+	personas := map[string]persona.Config{
+		"user": opts.UserPersona, "chat": opts.ChatPersona, "vibecheck": opts.VibecheckPersona, "membership": opts.MembershipPersona,
+		"announce": opts.AnnouncePersona, "topic": opts.TopicPersona,
+		"filescan": opts.FileScan.Persona, "feedback": opts.Feedback.Persona,
+		"handoff": opts.Handoff.Persona, "analytics": opts.Analytics.Persona, "selftest": opts.SelfTest.Persona,
+		"facts": opts.Facts.Persona, "lastseen": opts.LastSeen.Persona, "karma": opts.Karma.Persona,
+	}
+	for i, schedule := range opts.Schedules {
+		personas[fmt.Sprintf("schedules[%d]", i)] = schedule.Persona
+	}
+	for section, p := range personas {
+		if p.IconEmoji != "" {
+			names[section+".persona.icon_emoji"] = p.IconEmoji
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(names)) {
+		if !emoji.Valid(names[key]) {
+			return &errs.ConfigError{Key: key, Err: fmt.Errorf("invalid emoji name %q", names[key])}
+		}
+	}
+	return nil
+}
+
+// validateAIRoutes checks that AI routes name a known feature and a configured endpoint
+func validateAIRoutes(opts configOpts) error {
+	for _, name := range slices.Sorted(maps.Keys(opts.AIEndpoints)) {
+		if len(opts.AIEndpoints[name].Providers) == 0 {
+			return &errs.ConfigError{Key: "ai.endpoints." + name, Err: errors.New("at least one provider is required")}
+		}
+	}
+	for _, feature := range slices.Sorted(maps.Keys(opts.AIRoutes)) {
+		key := "ai.routes." + feature
+		if !slices.Contains(ai.Features, feature) {
+			return &errs.ConfigError{Key: key, Err: fmt.Errorf("unknown feature, must be one of %s", strings.Join(ai.Features, ", "))}
+		}
+		if _, ok := opts.AIEndpoints[opts.AIRoutes[feature]]; !ok {
+			return &errs.ConfigError{Key: key, Err: fmt.Errorf("unknown endpoint %q", opts.AIRoutes[feature])}
+		}
+	}
+	return nil
+}
+
+// validateLanguages checks the aichat language policies only name languages the
+// detector knows
+func validateLanguages(opts configOpts) error {
+	policies := map[string]aichat.LanguagePolicy{"aichat.language": opts.AIChatLanguage}
+	for channel, p := range opts.AIChatChannelLanguage {
+		policies["aichat.channel_language."+channel] = p
+	}
+	for _, key := range slices.Sorted(maps.Keys(policies)) {
+		p := policies[key]
+		for i, code := range p.Allowed {
+			if _, ok := aichat.LanguageName(code); !ok {
+				return &errs.ConfigError{Key: fmt.Sprintf("%s.allowed[%d]", key, i), Err: fmt.Errorf("unknown language %q", code)}
+			}
+		}
+		if p.Default != nil && *p.Default != "" {
+			if _, ok := aichat.LanguageName(*p.Default); !ok {
+				return &errs.ConfigError{Key: key + ".default", Err: fmt.Errorf("unknown language %q", *p.Default)}
+			}
+		}
+	}
+	return nil
+}
+
+// validateChannelPersonas checks the channel personas are keyed by channel ID and name a
+// configured persona. Personas created from Slack are only known at runtime, so any name
+// is accepted when they're enabled.
+func validateChannelPersonas(opts configOpts, personas map[string]string) error {
+	runtime := opts.Personas.Enabled != nil && *opts.Personas.Enabled
+	for _, channel := range slices.Sorted(maps.Keys(opts.AIChatChannelPersonas)) {
+		key := "aichat.channel_personas." + channel
+		if !conversation.ValidID(channel) {
+			return &errs.ConfigError{Key: key, Err: errors.New("not a channel ID")}
+		}
+		name := opts.AIChatChannelPersonas[channel]
+		if name == "" {
+			return &errs.ConfigError{Key: key, Err: errors.New("persona is required")}
+		}
+		if _, ok := personas[name]; !ok && !runtime {
+			return &errs.ConfigError{Key: key, Err: fmt.Errorf("unknown persona %q", name)}
+		}
+	}
+	return nil
+}
+
+// fileScanConfig validates the file scan action and normalizes blocked extensions
+func fileScanConfig(c filescan.FileConfig, dataDir string) (filescan.Config, error) {
+	action := filescan.ActionFlag
+	if c.Action != nil && *c.Action != "" {
+		action = *c.Action
+	}
+	if action != filescan.ActionFlag && action != filescan.ActionDelete {
+		return filescan.Config{}, &errs.ConfigError{Key: "filescan.action", Err: fmt.Errorf("unknown action %q, want %s or %s", action, filescan.ActionFlag, filescan.ActionDelete)}
+	}
+	extensions := make([]string, 0, len(c.BlockedExtensions))
+	for _, ext := range c.BlockedExtensions {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			extensions = append(extensions, ext)
+		}
+	}
+	config := filescan.Config{
+		DataDir:           dataDir,
+		Channels:          c.Channels,
+		BlockedExtensions: extensions,
+		ScannerURL:        c.Scanner.URL,
+		Action:            action,
+		Persona:           c.Persona,
+	}
+	if c.MaxSize != nil {
+		config.MaxSize = *c.MaxSize
+	}
+	if c.Scanner.Timeout != nil {
+		config.ScannerTimeout = *c.Scanner.Timeout
+	}
+	if c.Scanner.ContentLimit != nil {
+		config.ContentLimit = *c.Scanner.ContentLimit
+	}
+	if c.ModLogChannel != nil {
+		config.ModLogChannel = *c.ModLogChannel
+	}
+	if config.MaxSize < 0 {
+		return filescan.Config{}, &errs.ConfigError{Key: "filescan.max_size", Err: errors.New("must not be negative")}
+	}
+	return config, nil
+}
+
+// feedbackConfig applies the rate limit defaults and requires an audit key when
+// feedback is relayed, so senders can still be revealed in abuse cases
+func feedbackConfig(c feedback.FileConfig, dataDir string) (feedback.Config, error) {
+	config := feedback.Config{
+		DataDir:    dataDir,
+		RateLimit:  feedback.DefaultRateLimit,
+		RateWindow: feedback.DefaultRateWindow,
+		Persona:    c.Persona,
+	}
+	if c.Channel != nil {
+		config.Channel = *c.Channel
+	}
+	if c.AuditPublicKey != nil {
+		config.AuditPublicKey = *c.AuditPublicKey
+	}
+	if c.RateLimit != nil {
+		config.RateLimit = *c.RateLimit
+	}
+	if c.RateWindow != nil {
+		config.RateWindow = *c.RateWindow
+	}
+	if config.Channel == "" {
+		return config, nil
+	}
+	if config.AuditPublicKey == "" {
+		return feedback.Config{}, &errs.ConfigError{Key: "feedback.audit_public_key", Err: errors.New("required when feedback is relayed, generate one with `slackbot feedback keygen`")}
+	}
+	if _, err := feedback.ParsePublicKey(config.AuditPublicKey); err != nil {
+		return feedback.Config{}, &errs.ConfigError{Key: "feedback.audit_public_key", Err: err}
+	}
+	if config.RateLimit <= 0 {
+		return feedback.Config{}, &errs.ConfigError{Key: "feedback.rate_limit", Err: errors.New("must be positive")}
+	}
+	if config.RateWindow <= 0 {
+		return feedback.Config{}, &errs.ConfigError{Key: "feedback.rate_window", Err: errors.New("must be positive")}
+	}
+	return config, nil
+}
+
+// analyticsConfig applies the default report schedule and retention
+func analyticsConfig(c analytics.FileConfig, dataDir string) (analytics.Config, error) {
+	config := analytics.Config{
+		DataDir:    dataDir,
+		Channels:   c.Channels,
+		ReportDay:  analytics.DefaultReportDay,
+		ReportHour: analytics.DefaultReportHour,
+		Retention:  analytics.DefaultRetention,
+		Persona:    c.Persona,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if c.ReportChannel != nil {
+		config.ReportChannel = *c.ReportChannel
+	}
+	if c.ReportDay != nil {
+		day, ok := scheduler.ParseWeekday(*c.ReportDay)
+		if !ok {
+			return analytics.Config{}, &errs.ConfigError{Key: "analytics.report_day", Err: fmt.Errorf("unknown day %q", *c.ReportDay)}
+		}
+		config.ReportDay = day
+	}
+	if c.ReportHour != nil {
+		if *c.ReportHour < 0 || *c.ReportHour > 23 {
+			return analytics.Config{}, &errs.ConfigError{Key: "analytics.report_hour", Err: errors.New("must be from 0 to 23")}
+		}
+		config.ReportHour = *c.ReportHour
+	}
+	if c.Retention != nil {
+		if *c.Retention <= 0 {
+			return analytics.Config{}, &errs.ConfigError{Key: "analytics.retention", Err: errors.New("must be positive")}
+		}
+		config.Retention = *c.Retention
+	}
+	return config, nil
+}
+
+// selfTestConfig applies the default hour the self-test runs at
+func selfTestConfig(c selftest.FileConfig, dataDir string) (selftest.Config, error) {
+	config := selftest.Config{
+		DataDir: dataDir,
+		Hour:    selftest.DefaultHour,
+		Persona: c.Persona,
+	}
+	if c.Channel != nil {
+		config.Channel = *c.Channel
+	}
+	if c.TestChannel != nil {
+		config.TestChannel = *c.TestChannel
+	}
+	if c.Hour != nil {
+		if *c.Hour < 0 || *c.Hour > 23 {
+			return selftest.Config{}, &errs.ConfigError{Key: "selftest.hour", Err: errors.New("must be from 0 to 23")}
+		}
+		config.Hour = *c.Hour
+	}
+	return config, nil
+}
+
+// leaderConfig applies the default lease key, TTL and replica ID and requires the Redis
+// address. The TTL must leave time to renew the lease a few times before it expires.
+func leaderConfig(c leader.FileConfig) (leader.Config, error) {
+	config := leader.Config{
+		Address:  strings.TrimSpace(c.Address),
+		Username: c.Username,
+		Password: c.Password,
+		Key:      leader.DefaultKey,
+		TTL:      leader.DefaultTTL,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if c.Key != nil && *c.Key != "" {
+		config.Key = *c.Key
+	}
+	if c.TTL != nil {
+		if *c.TTL < leader.MinTTL {
+			return leader.Config{}, &errs.ConfigError{Key: "leader.ttl", Err: fmt.Errorf("must be at least %s", leader.MinTTL)}
+		}
+		config.TTL = *c.TTL
+	}
+	if c.ID != nil {
+		config.ID = strings.TrimSpace(*c.ID)
+	}
+	if !config.Enabled {
+		return config, nil
+	}
+	if config.Address == "" {
+		return leader.Config{}, &errs.ConfigError{Key: "leader.address", Err: errors.New("required when leader election is enabled")}
+	}
+	if config.ID == "" {
+		config.ID = leader.DefaultID()
+	}
+	return config, nil
+}
+
+// grpcConfig applies the default port and requires a token and TLS certificate, since
+// the API can change config and post as the bot
+func grpcConfig(c api.FileConfig, serverPort uint32) (api.Config, error) {
+	config := api.Config{
+		Port:    api.DefaultPort,
+		Token:   c.Token,
+		TLSCert: c.TLSCert,
+		TLSKey:  c.TLSKey,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if c.Port != nil {
+		config.Port = *c.Port
+	}
+	if !config.Enabled {
+		return config, nil
+	}
+	if config.Port == serverPort {
+		return api.Config{}, &errs.ConfigError{Key: "grpc.port", Err: fmt.Errorf("must differ from the server port %d", serverPort)}
+	}
+	if config.Token == "" {
+		return api.Config{}, &errs.ConfigError{Key: "grpc.token", Err: errors.New("required when the API is enabled")}
+	}
+	if config.TLSCert == "" || config.TLSKey == "" {
+		return api.Config{}, &errs.ConfigError{Key: "grpc.tls_cert", Err: errors.New("tls_cert and tls_key are required when the API is enabled")}
+	}
+	return config, nil
+}
+
+// outboxConfig checks the send delays are for features that can hold replies and
+// applies the default reactions
+func outboxConfig(c outbox.FileConfig) (outbox.Config, error) {
+	for _, feature := range slices.Sorted(maps.Keys(c.Features)) {
+		if !slices.Contains(outbox.Features, feature) {
+			return outbox.Config{}, &errs.ConfigError{Key: "outbox.features." + feature, Err: fmt.Errorf("unknown feature, want one of %v", outbox.Features)}
+		}
+		if c.Features[feature] < 0 {
+			return outbox.Config{}, &errs.ConfigError{Key: "outbox.features." + feature, Err: errors.New("must not be negative")}
+		}
+	}
+	config := outbox.Config{
+		Delays:          c.Features,
+		Channels:        c.Channels,
+		Operators:       c.Operators,
+		CancelReaction:  outbox.DefaultCancelReaction,
+		PendingReaction: outbox.DefaultPendingReaction,
+	}
+	if c.CancelReaction != nil && *c.CancelReaction != "" {
+		config.CancelReaction = *c.CancelReaction
+	}
+	if c.PendingReaction != nil {
+		config.PendingReaction = *c.PendingReaction
+	}
+	pacing, err := pacingConfig(c.Pacing)
+	if err != nil {
+		return outbox.Config{}, err
+	}
+	config.Pacing = pacing
+	return config, nil
+}
+
+// pacingConfig checks the paced features can hold replies and applies the default typing
+// speed, jitter and cap
+func pacingConfig(c outbox.PacingFileConfig) (outbox.PacingConfig, error) {
+	for _, feature := range c.Features {
+		if !slices.Contains(outbox.Features, feature) {
+			return outbox.PacingConfig{}, &errs.ConfigError{Key: "outbox.pacing.features", Err: fmt.Errorf("unknown feature %q, want one of %v", feature, outbox.Features)}
+		}
+	}
+	config := outbox.PacingConfig{
+		Features:      c.Features,
+		Base:          outbox.DefaultPacingBase,
+		PerChar:       outbox.DefaultPacingPerChar,
+		Jitter:        outbox.DefaultPacingJitter,
+		Max:           outbox.DefaultPacingMax,
+		CancelOnNewer: true,
+	}
+	for _, d := range []struct {
+		key    string
+		value  *time.Duration
+		target *time.Duration
+	}{
+		{"base", c.Base, &config.Base},
+		{"per_char", c.PerChar, &config.PerChar},
+		{"jitter", c.Jitter, &config.Jitter},
+		{"max", c.Max, &config.Max},
+	} {
+		if d.value == nil {
+			continue
+		}
+		if *d.value < 0 {
+			return outbox.PacingConfig{}, &errs.ConfigError{Key: "outbox.pacing." + d.key, Err: errors.New("must not be negative")}
+		}
+		*d.target = *d.value
+	}
+	if c.CancelOnNewer != nil {
+		config.CancelOnNewer = *c.CancelOnNewer
+	}
+	return config, nil
+}
+
+// broadcastsConfig checks the configured announcements can be posted and applies the
+// default reaction and deadline
+func broadcastsConfig(c announce.BroadcastsFileConfig) (announce.BroadcastsConfig, error) {
+	config := announce.BroadcastsConfig{
+		Enabled:  c.Enabled != nil && *c.Enabled,
+		Reaction: announce.DefaultAckReaction,
+		Deadline: announce.DefaultAckDeadline,
+	}
+	if c.Reaction != nil && *c.Reaction != "" {
+		config.Reaction = emoji.Name(*c.Reaction)
+	}
+	if c.Deadline != nil {
+		if *c.Deadline <= 0 {
+			return announce.BroadcastsConfig{}, &errs.ConfigError{Key: "announce.broadcasts.deadline", Err: errors.New("must be positive")}
+		}
+		config.Deadline = *c.Deadline
+	}
+	if c.ReportChannel != nil {
+		config.ReportChannel = strings.TrimSpace(*c.ReportChannel)
+		if config.ReportChannel != "" && !conversation.ValidID(config.ReportChannel) {
+			return announce.BroadcastsConfig{}, &errs.ConfigError{Key: "announce.broadcasts.report_channel", Err: fmt.Errorf("%q isn't a channel ID", config.ReportChannel)}
+		}
+	}
+	ids := make(map[string]struct{}, len(c.Announcements))
+	for i, a := range c.Announcements {
+		key := fmt.Sprintf("announce.broadcasts.announcements[%d]", i)
+		id := strings.TrimSpace(a.ID)
+		if id == "" {
+			return announce.BroadcastsConfig{}, &errs.ConfigError{Key: key + ".id", Err: errors.New("required")}
+		}
+		if _, ok := ids[id]; ok {
+			return announce.BroadcastsConfig{}, &errs.ConfigError{Key: key + ".id", Err: fmt.Errorf("duplicate announcement %q", id)}
+		}
+		ids[id] = struct{}{}
+		if strings.TrimSpace(a.Text) == "" {
+			return announce.BroadcastsConfig{}, &errs.ConfigError{Key: key + ".text", Err: errors.New("required")}
+		}
+		if len(a.Channels) == 0 {
+			return announce.BroadcastsConfig{}, &errs.ConfigError{Key: key + ".channels", Err: errors.New("at least one channel is required")}
+		}
+		for j, channel := range a.Channels {
+			if !conversation.ValidID(channel) {
+				return announce.BroadcastsConfig{}, &errs.ConfigError{Key: fmt.Sprintf("%s.channels[%d]", key, j), Err: fmt.Errorf("%q isn't a channel ID", channel)}
+			}
+		}
+		broadcast := announce.Broadcast{ID: id, Text: a.Text, Channels: a.Channels, Deadline: config.Deadline}
+		if a.Deadline != nil {
+			if *a.Deadline <= 0 {
+				return announce.BroadcastsConfig{}, &errs.ConfigError{Key: key + ".deadline", Err: errors.New("must be positive")}
+			}
+			broadcast.Deadline = *a.Deadline
+		}
+		config.Announcements = append(config.Announcements, broadcast)
+	}
+	return config, nil
+}
+
+// httpAuthConfig validates the route groups and applies the admin token override
+func httpAuthConfig(groups map[string]http.AuthConfig, adminToken string) (map[string]http.AuthConfig, error) {
+	auth := make(map[string]http.AuthConfig, len(groups))
+	for group, c := range groups {
+		if !slices.Contains(http.RouteGroups, group) {
+			return nil, &errs.ConfigError{Key: "http_auth." + group, Err: fmt.Errorf("unknown route group, want one of %v", http.RouteGroups)}
+		}
+		if c.Username != "" && c.Password == "" {
+			return nil, &errs.ConfigError{Key: "http_auth." + group + ".password", Err: errors.New("basic auth requires a password")}
+		}
+		auth[group] = c
+	}
+	if adminToken != "" {
+		admin := auth[http.RouteGroupAdmin]
+		admin.BearerToken = adminToken
+		auth[http.RouteGroupAdmin] = admin
+	}
+	return auth, nil
+}
+
 // Relative path from the executable directory.
 // Returns the input if it's already absolute.
 func relativeToAbsolutePath(input string) (string, error) {
@@ -309,3 +1410,418 @@ func parseGoMapString(mapStr string) map[string]string {
 
 	return personas
 }
+
+// lastSeenConfig applies the default retention, inactive period and report time. Reports
+// need somewhere to be posted.
+func lastSeenConfig(c lastseen.FileConfig, dataDir string) (lastseen.Config, error) {
+	config := lastseen.Config{
+		DataDir:        dataDir,
+		Channels:       c.Channels,
+		OptOut:         c.OptOut,
+		Retention:      lastseen.DefaultRetention,
+		ReportChannels: c.ReportChannels,
+		InactiveAfter:  lastseen.DefaultInactiveAfter,
+		ReportDay:      lastseen.DefaultReportDay,
+		ReportHour:     lastseen.DefaultReportHour,
+		Persona:        c.Persona,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if c.Exact != nil {
+		config.Exact = *c.Exact
+	}
+	if c.Retention != nil {
+		if *c.Retention <= 0 {
+			return lastseen.Config{}, &errs.ConfigError{Key: "lastseen.retention", Err: errors.New("must be positive")}
+		}
+		config.Retention = *c.Retention
+	}
+	if c.InactiveAfter != nil {
+		if *c.InactiveAfter <= 0 {
+			return lastseen.Config{}, &errs.ConfigError{Key: "lastseen.inactive_after", Err: errors.New("must be positive")}
+		}
+		config.InactiveAfter = *c.InactiveAfter
+	}
+	if config.InactiveAfter > config.Retention {
+		return lastseen.Config{}, &errs.ConfigError{Key: "lastseen.inactive_after", Err: fmt.Errorf("must not be longer than retention %s", config.Retention)}
+	}
+	if c.ReportTo != nil {
+		config.ReportTo = strings.TrimSpace(*c.ReportTo)
+	}
+	if len(config.ReportChannels) > 0 && config.ReportTo == "" {
+		return lastseen.Config{}, &errs.ConfigError{Key: "lastseen.report_to", Err: errors.New("required when report_channels are set")}
+	}
+	if c.ReportDay != nil {
+		day, ok := scheduler.ParseWeekday(*c.ReportDay)
+		if !ok {
+			return lastseen.Config{}, &errs.ConfigError{Key: "lastseen.report_day", Err: fmt.Errorf("unknown day %q", *c.ReportDay)}
+		}
+		config.ReportDay = day
+	}
+	if c.ReportHour != nil {
+		if *c.ReportHour < 0 || *c.ReportHour > 23 {
+			return lastseen.Config{}, &errs.ConfigError{Key: "lastseen.report_hour", Err: errors.New("must be from 0 to 23")}
+		}
+		config.ReportHour = *c.ReportHour
+	}
+	return config, nil
+}
+
+// retentionConfig checks retention periods name known namespaces and features, and
+// applies the default sweep interval and grace period
+func retentionConfig(c retention.FileConfig) (retention.Config, error) {
+	config := retention.Config{
+		Grace:    retention.DefaultGrace,
+		Interval: retention.DefaultInterval,
+	}
+	namespaces := retention.Namespaces()
+	for _, namespace := range slices.Sorted(maps.Keys(c.Namespaces)) {
+		if !slices.Contains(namespaces, namespace) {
+			return retention.Config{}, &errs.ConfigError{Key: "retention.namespaces." + namespace, Err: fmt.Errorf("unknown namespace, expected one of %s", strings.Join(namespaces, ", "))}
+		}
+		if config.Namespaces == nil {
+			config.Namespaces = make(map[string]time.Duration)
+		}
+		config.Namespaces[namespace] = time.Duration(c.Namespaces[namespace])
+	}
+	for _, feature := range slices.Sorted(maps.Keys(c.Features)) {
+		if _, ok := retention.Stores[feature]; !ok {
+			features := slices.Sorted(maps.Keys(retention.Stores))
+			return retention.Config{}, &errs.ConfigError{Key: "retention.features." + feature, Err: fmt.Errorf("no stored data, expected one of %s", strings.Join(features, ", "))}
+		}
+		if config.Features == nil {
+			config.Features = make(map[string]time.Duration)
+		}
+		config.Features[feature] = time.Duration(c.Features[feature])
+	}
+	if c.Grace != nil {
+		if *c.Grace == retention.Forever {
+			return retention.Config{}, &errs.ConfigError{Key: "retention.grace", Err: errors.New("must not be forever")}
+		}
+		config.Grace = time.Duration(*c.Grace)
+	}
+	if c.Interval != nil {
+		if *c.Interval <= 0 {
+			return retention.Config{}, &errs.ConfigError{Key: "retention.interval", Err: errors.New("must be positive")}
+		}
+		config.Interval = *c.Interval
+	}
+	return config, nil
+}
+
+// personasConfig applies the default prompt limit and review, and checks the admins are
+// user IDs
+func personasConfig(c personastore.FileConfig, dataDir string) (personastore.Config, error) {
+	config := personastore.Config{
+		DataDir:         dataDir,
+		MaxPromptLength: personastore.DefaultMaxPromptLength,
+		Review:          true,
+		BlockedWords:    c.BlockedWords,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	for _, id := range c.Admins {
+		if !strings.HasPrefix(id, "U") && !strings.HasPrefix(id, "W") {
+			return personastore.Config{}, &errs.ConfigError{Key: "personas.admins", Err: fmt.Errorf("%q is not a user ID", id)}
+		}
+		config.Admins = append(config.Admins, id)
+	}
+	if c.MaxPromptLength != nil {
+		if *c.MaxPromptLength <= 0 {
+			return personastore.Config{}, &errs.ConfigError{Key: "personas.max_prompt_length", Err: errors.New("must be positive")}
+		}
+		config.MaxPromptLength = *c.MaxPromptLength
+	}
+	if c.Review != nil {
+		config.Review = *c.Review
+	}
+	return config, nil
+}
+
+// claimConfig applies the default limit, priority and window, and checks the priority
+// names features that claim messages
+func claimConfig(c claim.FileConfig) (claim.Config, error) {
+	config := claim.Config{
+		MaxPerMessage: claim.DefaultMaxPerMessage,
+		Priority:      claim.Features,
+		Window:        claim.DefaultWindow,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if c.MaxPerMessage != nil {
+		if *c.MaxPerMessage < 0 {
+			return claim.Config{}, &errs.ConfigError{Key: "claim.max_per_message", Err: errors.New("must not be negative")}
+		}
+		config.MaxPerMessage = *c.MaxPerMessage
+	}
+	for _, channelID := range slices.Sorted(maps.Keys(c.Channels)) {
+		if c.Channels[channelID] < 0 {
+			return claim.Config{}, &errs.ConfigError{Key: "claim.channels." + channelID, Err: errors.New("must not be negative")}
+		}
+	}
+	config.Channels = c.Channels
+	if len(c.Priority) > 0 {
+		for i, feature := range c.Priority {
+			if !slices.Contains(claim.Features, feature) {
+				return claim.Config{}, &errs.ConfigError{Key: "claim.priority", Err: fmt.Errorf("unknown feature %q, expected one of %s", feature, strings.Join(claim.Features, ", "))}
+			}
+			if slices.Contains(c.Priority[:i], feature) {
+				return claim.Config{}, &errs.ConfigError{Key: "claim.priority", Err: fmt.Errorf("%s is listed twice", feature)}
+			}
+		}
+		config.Priority = c.Priority
+	}
+	if c.Window != nil {
+		if *c.Window < 0 {
+			return claim.Config{}, &errs.ConfigError{Key: "claim.window", Err: errors.New("must not be negative")}
+		}
+		config.Window = *c.Window
+	}
+	return config, nil
+}
+
+// eventBusConfig applies the defaults and requires a supported backend and its address
+func eventBusConfig(c eventbus.FileConfig) (eventbus.Config, error) {
+	config := eventbus.Config{
+		Backend:  strings.ToLower(strings.TrimSpace(c.Backend)),
+		Address:  strings.TrimSpace(c.Address),
+		Username: c.Username,
+		Password: c.Password,
+		Role:     strings.ToLower(strings.TrimSpace(c.Role)),
+		Stream:   eventbus.DefaultStream,
+		Group:    eventbus.DefaultGroup,
+		MaxLen:   eventbus.DefaultMaxLen,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if config.Backend == "" {
+		config.Backend = eventbus.BackendRedis
+	}
+	if config.Role == "" {
+		config.Role = eventbus.RoleWorker
+	}
+	if c.Stream != nil && *c.Stream != "" {
+		config.Stream = *c.Stream
+	}
+	if c.Group != nil && *c.Group != "" {
+		config.Group = *c.Group
+	}
+	if c.Consumer != nil {
+		config.Consumer = strings.TrimSpace(*c.Consumer)
+	}
+	if c.MaxLen != nil {
+		if *c.MaxLen <= 0 {
+			return eventbus.Config{}, &errs.ConfigError{Key: "event_bus.max_len", Err: errors.New("must be positive")}
+		}
+		config.MaxLen = *c.MaxLen
+	}
+	if !config.Enabled {
+		return config, nil
+	}
+	if !slices.Contains(eventbus.Backends, config.Backend) {
+		return eventbus.Config{}, &errs.ConfigError{Key: "event_bus.backend", Err: fmt.Errorf("%q is not one of %s", config.Backend, strings.Join(eventbus.Backends, ", "))}
+	}
+	if !slices.Contains(eventbus.Roles, config.Role) {
+		return eventbus.Config{}, &errs.ConfigError{Key: "event_bus.role", Err: fmt.Errorf("%q is not one of %s", config.Role, strings.Join(eventbus.Roles, ", "))}
+	}
+	if config.Address == "" {
+		return eventbus.Config{}, &errs.ConfigError{Key: "event_bus.address", Err: errors.New("required when the event bus is enabled")}
+	}
+	if config.Consumer == "" {
+		config.Consumer = eventbus.DefaultConsumer()
+	}
+	return config, nil
+}
+
+// onEditConfig applies the default edit window for what a feature undoes when a message
+// it responded to is edited
+func onEditConfig(c retract.FileConfig, key string) (retract.Config, error) {
+	config := retract.Config{Window: retract.DefaultWindow}
+	if c.Reactions != nil {
+		config.Reactions = *c.Reactions
+	}
+	if c.Messages != nil {
+		config.Messages = *c.Messages
+	}
+	if c.Window != nil {
+		if *c.Window <= 0 {
+			return retract.Config{}, &errs.ConfigError{Key: key + ".window", Err: errors.New("must be positive")}
+		}
+		config.Window = *c.Window
+	}
+	return config, nil
+}
+
+// schedulerConfig checks each schedule has a unique name, a channel, a cron expression
+// and message template that parse, and a known timezone
+func schedulerConfig(c []scheduler.ScheduleFileConfig) (scheduler.Config, error) {
+	var config scheduler.Config
+	names := make(map[string]struct{}, len(c))
+	for i, s := range c {
+		key := fmt.Sprintf("schedules[%d]", i)
+		name := strings.TrimSpace(s.Name)
+		if name == "" {
+			return scheduler.Config{}, &errs.ConfigError{Key: key + ".name", Err: errors.New("required")}
+		}
+		if _, ok := names[name]; ok {
+			return scheduler.Config{}, &errs.ConfigError{Key: key + ".name", Err: fmt.Errorf("duplicate schedule %q", name)}
+		}
+		names[name] = struct{}{}
+		if _, err := scheduler.ParseCron(s.Cron); err != nil {
+			return scheduler.Config{}, &errs.ConfigError{Key: key + ".cron", Err: err}
+		}
+		if !conversation.ValidID(s.Channel) {
+			return scheduler.Config{}, &errs.ConfigError{Key: key + ".channel", Err: fmt.Errorf("%q isn't a channel ID", s.Channel)}
+		}
+		if strings.TrimSpace(s.Message) == "" {
+			return scheduler.Config{}, &errs.ConfigError{Key: key + ".message", Err: errors.New("required")}
+		}
+		if _, err := scheduler.ParseMessage(name, s.Message); err != nil {
+			return scheduler.Config{}, &errs.ConfigError{Key: key + ".message", Err: err}
+		}
+		schedule := scheduler.Schedule{
+			Name:    name,
+			Cron:    strings.TrimSpace(s.Cron),
+			Channel: s.Channel,
+			Message: s.Message,
+			AI:      s.AI != nil && *s.AI,
+			Persona: s.Persona,
+		}
+		if s.Timezone != nil && *s.Timezone != "" {
+			if _, err := time.LoadLocation(*s.Timezone); err != nil {
+				return scheduler.Config{}, &errs.ConfigError{Key: key + ".timezone", Err: err}
+			}
+			schedule.Timezone = *s.Timezone
+		}
+		config.Schedules = append(config.Schedules, schedule)
+	}
+	return config, nil
+}
+
+// explainConfig applies the default reaction and trace limit, and checks the admins are
+// user IDs
+func explainConfig(c explain.FileConfig) (explain.Config, error) {
+	config := explain.Config{
+		Reaction:  explain.DefaultReaction,
+		MaxTraces: explain.DefaultMaxTraces,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if c.Reaction != nil && *c.Reaction != "" {
+		config.Reaction = emoji.Name(*c.Reaction)
+	}
+	for _, id := range c.Admins {
+		if !strings.HasPrefix(id, "U") && !strings.HasPrefix(id, "W") {
+			return explain.Config{}, &errs.ConfigError{Key: "explain.admins", Err: fmt.Errorf("%q is not a user ID", id)}
+		}
+		config.Admins = append(config.Admins, id)
+	}
+	if c.MaxTraces != nil {
+		if *c.MaxTraces <= 0 {
+			return explain.Config{}, &errs.ConfigError{Key: "explain.max_traces", Err: errors.New("must be positive")}
+		}
+		config.MaxTraces = *c.MaxTraces
+	}
+	return config, nil
+}
+
+// karmaConfig applies the default reactions and leaderboard size, and checks a reaction
+// isn't both an upvote and a downvote
+func karmaConfig(c karma.FileConfig, dataDir string) (karma.Config, error) {
+	config := karma.Config{
+		DataDir:         dataDir,
+		Upvotes:         karma.DefaultUpvotes,
+		Downvotes:       karma.DefaultDownvotes,
+		Channels:        c.Channels,
+		LeaderboardSize: karma.DefaultLeaderboardSize,
+		Persona:         c.Persona,
+	}
+	if c.Enabled != nil {
+		config.Enabled = *c.Enabled
+	}
+	if len(c.Upvotes) > 0 {
+		config.Upvotes = nil
+		for _, name := range c.Upvotes {
+			config.Upvotes = append(config.Upvotes, emoji.Name(name))
+		}
+	}
+	if c.Downvotes != nil {
+		config.Downvotes = []string{}
+		for i, name := range c.Downvotes {
+			name = emoji.Name(name)
+			if slices.Contains(config.Upvotes, name) {
+				return karma.Config{}, &errs.ConfigError{Key: fmt.Sprintf("karma.downvotes[%d]", i), Err: fmt.Errorf("%q is also an upvote", name)}
+			}
+			config.Downvotes = append(config.Downvotes, name)
+		}
+	}
+	if c.LeaderboardSize != nil {
+		if *c.LeaderboardSize <= 0 {
+			return karma.Config{}, &errs.ConfigError{Key: "karma.leaderboard_size", Err: errors.New("must be positive")}
+		}
+		config.LeaderboardSize = *c.LeaderboardSize
+	}
+	return config, nil
+}
+
+// blocklistConfig checks the phrases and patterns can be matched and applies the default
+// action and replacement
+func blocklistConfig(c slack.BlocklistFileConfig) (slack.Blocklist, error) {
+	config := slack.Blocklist{Replacement: slack.DefaultBlocklistReplacement}
+	for i, phrase := range c.Phrases {
+		if strings.TrimSpace(phrase) == "" {
+			return slack.Blocklist{}, &errs.ConfigError{Key: fmt.Sprintf("blocklist.phrases[%d]", i), Err: errors.New("must not be empty")}
+		}
+		config.Phrases = append(config.Phrases, phrase)
+	}
+	for i, pattern := range c.Patterns {
+		if _, err := slack.BlocklistPattern(pattern); err != nil {
+			return slack.Blocklist{}, &errs.ConfigError{Key: fmt.Sprintf("blocklist.patterns[%d]", i), Err: err}
+		}
+		config.Patterns = append(config.Patterns, pattern)
+	}
+	if c.Action != nil {
+		switch *c.Action {
+		case slack.BlocklistBlock:
+		case slack.BlocklistRedact:
+			config.Redact = true
+		default:
+			return slack.Blocklist{}, &errs.ConfigError{Key: "blocklist.action", Err: fmt.Errorf("must be %s or %s", slack.BlocklistBlock, slack.BlocklistRedact)}
+		}
+	}
+	if c.Replacement != nil {
+		config.Replacement = *c.Replacement
+	}
+	return config, nil
+}
+
+// validateRateLimits checks the aichat rate limits, where a zero rate is unlimited, and
+// the queue of messages held while they're exceeded
+func validateRateLimits(opts configOpts) error {
+	for _, l := range []struct {
+		name  string
+		limit aichat.RateLimit
+	}{
+		{"message", opts.AIChatMessageRate},
+		{"mention", opts.AIChatMentionRate},
+	} {
+		if l.limit.Every < 0 {
+			return &errs.ConfigError{Key: "aichat." + l.name + "_rate", Err: errors.New("must not be negative")}
+		}
+		if l.limit.Burst < 0 {
+			return &errs.ConfigError{Key: "aichat." + l.name + "_burst", Err: errors.New("must not be negative")}
+		}
+	}
+	if opts.AIChatQueueSize < 0 {
+		return &errs.ConfigError{Key: "aichat.queue_size", Err: errors.New("must not be negative")}
+	}
+	if opts.AIChatQueueMaxAge < 0 {
+		return &errs.ConfigError{Key: "aichat.queue_max_age", Err: errors.New("must not be negative")}
+	}
+	return nil
+}
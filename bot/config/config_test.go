@@ -1,15 +1,48 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/goccy/go-yaml"
 	"github.com/urfave/cli/v3"
+	"slackbot.arpa/bot/ai"
+	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/analytics"
+	"slackbot.arpa/bot/announce"
+	"slackbot.arpa/bot/api"
+	"slackbot.arpa/bot/chat"
+	"slackbot.arpa/bot/claim"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/eventbus"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/feedback"
+	"slackbot.arpa/bot/filescan"
+	"slackbot.arpa/bot/http"
+	"slackbot.arpa/bot/karma"
+	"slackbot.arpa/bot/lastseen"
+	"slackbot.arpa/bot/leader"
+	"slackbot.arpa/bot/outbox"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/personastore"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/bot/retract"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/selftest"
+	"slackbot.arpa/bot/slack"
+	"slackbot.arpa/bot/subtype"
+	"slackbot.arpa/bot/vibecheck"
 )
 
 func TestEnvironment_String(t *testing.T) {
@@ -347,6 +380,76 @@ func TestNewConfig_EmptyDataDir(t *testing.T) {
 	}
 }
 
+func TestNewConfig_TriggerAliases(t *testing.T) {
+	config, err := newConfig(configOpts{})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(config.TriggerAliases, []string{"bot"}) {
+		t.Errorf("newConfig() default TriggerAliases = %v, want [bot]", config.TriggerAliases)
+	}
+
+	config, err = newConfig(configOpts{TriggerAliases: []string{"hey bot", "jeeves"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	want := []string{"hey bot", "jeeves"}
+	if !reflect.DeepEqual(config.TriggerAliases, want) || !reflect.DeepEqual(config.AIChat.TriggerAliases, want) {
+		t.Errorf("newConfig() TriggerAliases = %v, AIChat.TriggerAliases = %v, want %v",
+			config.TriggerAliases, config.AIChat.TriggerAliases, want)
+	}
+}
+
+func TestNewConfig_MessageSubtypes(t *testing.T) {
+	config, err := newConfig(configOpts{})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(config.MessageSubtypes, subtype.DefaultAllowed) {
+		t.Errorf("newConfig() default MessageSubtypes = %v, want %v", config.MessageSubtypes, subtype.DefaultAllowed)
+	}
+
+	config, err = newConfig(configOpts{MessageSubtypes: []string{"channel_join"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	want := []string{"channel_join"}
+	for name, got := range map[string][]string{
+		"Chat":      config.Chat.MessageSubtypes,
+		"Vibecheck": config.Vibecheck.MessageSubtypes,
+		"AIChat":    config.AIChat.MessageSubtypes,
+	} {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("newConfig() %s.MessageSubtypes = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNewConfig_HTTPAuth(t *testing.T) {
+	config, err := newConfig(configOpts{
+		HTTPAuth: map[string]http.AuthConfig{
+			"health": {Username: "monitor", Password: "secret"},
+		},
+		HTTPAdminToken: "admin-token",
+	})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if config.Server.Auth["health"].Password != "secret" || config.Server.Auth["admin"].BearerToken != "admin-token" {
+		t.Errorf("newConfig() Server.Auth = %+v", config.Server.Auth)
+	}
+
+	for _, auth := range []map[string]http.AuthConfig{
+		{"metrics": {BearerToken: "token"}},
+		{"admin": {Username: "admin"}},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{HTTPAuth: auth}); !errors.As(err, &configErr) {
+			t.Errorf("newConfig(%v) error = %v, want ConfigError", auth, err)
+		}
+	}
+}
+
 func TestNewConfig_PersonasFromYAML(t *testing.T) {
 	// Test parsing personas from YAML config (as would come from file)
 	yamlPersonasConfig := `
@@ -520,3 +623,936 @@ func BenchmarkEnvironmentFromString(b *testing.B) {
 		}
 	}
 }
+
+func TestNewConfig_LogLevels(t *testing.T) {
+	config, err := newConfig(configOpts{LogLevels: parseLogLevels([]string{"aichat=debug", " chat = warn"})})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if want := map[string]string{"aichat": "debug", "chat": "warn"}; !reflect.DeepEqual(config.LogLevels, want) {
+		t.Errorf("newConfig() LogLevels = %v, want %v", config.LogLevels, want)
+	}
+
+	var configErr *errs.ConfigError
+	for _, pairs := range [][]string{{"aichat"}, {"aichat=loud"}} {
+		if _, err := newConfig(configOpts{LogLevels: parseLogLevels(pairs)}); !errors.As(err, &configErr) {
+			t.Errorf("newConfig(%v) error = %v, want ConfigError", pairs, err)
+		}
+	}
+}
+
+func TestNewConfig_EmojiNames(t *testing.T) {
+	valid := configOpts{
+		ChatResponses: []chat.Response{{Pattern: "ship it", Reactions: []string{":shipit:", "+1::skin-tone-2"}}},
+		ChatPersona:   persona.Config{IconEmoji: ":robot_face:"},
+	}
+	if _, err := newConfig(valid); err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+
+	reaction := "no vibes"
+	invalid := configOpts{VibecheckOnDemand: vibecheck.OnDemandConfig{Reaction: &reaction}}
+	var configErr *errs.ConfigError
+	if _, err := newConfig(invalid); !errors.As(err, &configErr) || configErr.Key != "vibecheck.on_demand.reaction" {
+		t.Errorf("newConfig() error = %v, want ConfigError for vibecheck.on_demand.reaction", err)
+	}
+}
+
+func TestNewConfig_VibecheckJail(t *testing.T) {
+	jail, punishment := "C0000JAIL", vibecheck.PunishmentJail
+	valid := configOpts{VibecheckJail: vibecheck.JailConfig{Channel: &jail, Channels: map[string]string{"C01234567": vibecheck.PunishmentKick}, Punishment: &punishment}}
+	c, err := newConfig(valid)
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.Vibecheck.Jail.Channel == nil || *c.Vibecheck.Jail.Channel != jail {
+		t.Errorf("vibecheck jail = %+v, want the jail channel", c.Vibecheck.Jail)
+	}
+
+	tests := []struct {
+		name string
+		jail vibecheck.JailConfig
+		key  string
+	}{
+		{"unknown punishment", vibecheck.JailConfig{Channel: &jail, Channels: map[string]string{"C01234567": "exile"}}, "vibecheck.jail.channels.C01234567"},
+		{"jail without a channel", vibecheck.JailConfig{Channels: map[string]string{"C01234567": vibecheck.PunishmentJail}}, "vibecheck.jail.channel"},
+		{"not a channel ID", vibecheck.JailConfig{Channel: &jail, Channels: map[string]string{"general": vibecheck.PunishmentJail}}, "vibecheck.jail.channels.general"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var configErr *errs.ConfigError
+			if _, err := newConfig(configOpts{VibecheckJail: tt.jail}); !errors.As(err, &configErr) || configErr.Key != tt.key {
+				t.Errorf("newConfig() error = %v, want ConfigError for %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestNewConfig_ChatReactionThresholds(t *testing.T) {
+	rule := chat.ReactionThreshold{Reaction: "fire", Count: 5, Channels: []string{"C01234567"}, Crosspost: "C0000FAME"}
+	c, err := newConfig(configOpts{ChatReactionThresholds: []chat.ReactionThreshold{rule}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if len(c.Chat.ReactionThresholds) != 1 {
+		t.Errorf("chat reaction thresholds = %+v, want the configured rule", c.Chat.ReactionThresholds)
+	}
+
+	tests := []struct {
+		name string
+		edit func(r *chat.ReactionThreshold)
+		key  string
+	}{
+		{"no count", func(r *chat.ReactionThreshold) { r.Count = 0 }, "chat.reaction_thresholds[0].count"},
+		{"nothing to do", func(r *chat.ReactionThreshold) { r.Crosspost = "" }, "chat.reaction_thresholds[0]"},
+		{"crosspost not a channel ID", func(r *chat.ReactionThreshold) { r.Crosspost = "hall-of-fame" }, "chat.reaction_thresholds[0].crosspost"},
+		{"channel not a channel ID", func(r *chat.ReactionThreshold) { r.Channels = []string{"general"} }, "chat.reaction_thresholds[0].channels[0]"},
+		{"invalid emoji", func(r *chat.ReactionThreshold) { r.Reaction = "" }, "chat.reaction_thresholds[0].reaction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rule
+			tt.edit(&r)
+			var configErr *errs.ConfigError
+			if _, err := newConfig(configOpts{ChatReactionThresholds: []chat.ReactionThreshold{r}}); !errors.As(err, &configErr) || configErr.Key != tt.key {
+				t.Errorf("newConfig() error = %v, want ConfigError for %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestNewConfig_VibecheckWelcomeBack(t *testing.T) {
+	message := "{{.User"
+	var configErr *errs.ConfigError
+	_, err := newConfig(configOpts{VibecheckWelcomeBack: vibecheck.WelcomeBackConfig{Message: &message}})
+	if !errors.As(err, &configErr) || configErr.Key != "vibecheck.welcome_back.message" {
+		t.Errorf("newConfig() error = %v, want ConfigError for vibecheck.welcome_back.message", err)
+	}
+}
+
+func TestNewConfig_VibecheckJudgement(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    configOpts
+		wantKey string
+	}{
+		{"unknown strategy", configOpts{VibecheckJudgement: vibecheck.JudgementConfig{Strategy: "ranodm"}}, "vibecheck.judgement.strategy"},
+		{"llm without an LLM", configOpts{VibecheckJudgement: vibecheck.JudgementConfig{Strategy: vibecheck.StrategyLLM}}, "vibecheck.judgement.strategy"},
+		{"webhook without a URL", configOpts{VibecheckJudgement: vibecheck.JudgementConfig{Strategy: vibecheck.StrategyWebhook}}, "vibecheck.judgement.webhook_url"},
+		{"llm", configOpts{VibecheckJudgement: vibecheck.JudgementConfig{Strategy: vibecheck.StrategyLLM}, OpenAIAPIKey: "sk-test"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newConfig(tt.opts)
+			var configErr *errs.ConfigError
+			if tt.wantKey == "" {
+				if err != nil {
+					t.Errorf("newConfig() error = %v, want nil", err)
+				}
+			} else if !errors.As(err, &configErr) || configErr.Key != tt.wantKey {
+				t.Errorf("newConfig() error = %v, want ConfigError for %s", err, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestNewConfig_Announce(t *testing.T) {
+	startup := "{{.Feature}} v{{.Version}} is up"
+	channel := "C2"
+	opts := configOpts{
+		AnnounceEnabled: true,
+		AnnounceChannel: "C1",
+		AnnounceFeatures: map[string]announce.FeatureFileConfig{
+			"vibecheck": {Startup: &startup},
+			"chat":      {Channel: &channel},
+		},
+	}
+	c, err := newConfig(opts)
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if got := c.Announce.Features["vibecheck"]; got.Startup != startup || got.Shutdown != announce.DefaultShutdown || got.Channel != "C1" {
+		t.Errorf("vibecheck announcement = %+v, want custom startup in the default channel", got)
+	}
+	if got := c.Announce.Features["chat"].Channel; got != "C2" {
+		t.Errorf("chat channel = %q, want C2", got)
+	}
+
+	broken := "{{.Uptime}}"
+	opts.AnnounceFeatures = map[string]announce.FeatureFileConfig{"chat": {Shutdown: &broken}}
+	var configErr *errs.ConfigError
+	if _, err := newConfig(opts); !errors.As(err, &configErr) || configErr.Key != "announce.features.chat.shutdown" {
+		t.Errorf("newConfig() error = %v, want ConfigError for announce.features.chat.shutdown", err)
+	}
+
+	opts.AnnounceChannel = ""
+	opts.AnnounceFeatures = map[string]announce.FeatureFileConfig{"chat": {}}
+	if _, err := newConfig(opts); !errors.As(err, &configErr) || configErr.Key != "announce.features.chat.channel" {
+		t.Errorf("newConfig() error = %v, want ConfigError for announce.features.chat.channel", err)
+	}
+}
+
+func TestNewConfig_AnnounceBroadcasts(t *testing.T) {
+	deadline := 2 * time.Hour
+	opts := configOpts{
+		AnnouncePersona: persona.Config{Username: "Announcer"},
+		AnnounceBroadcasts: announce.BroadcastsFileConfig{
+			Enabled:  new(true),
+			Reaction: new(":thumbsup:"),
+			Announcements: []announce.BroadcastFileConfig{
+				{ID: "hours", Text: "We close early", Channels: []string{"C0123456789", "C0123456780"}},
+				{ID: "policy", Text: "New policy", Channels: []string{"C0123456789"}, Deadline: &deadline},
+			},
+		},
+	}
+	c, err := newConfig(opts)
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	b := c.Announce.Broadcasts
+	if !b.Enabled || b.Reaction != "+1" || b.Persona.Username != "Announcer" || b.DataDir == "" {
+		t.Errorf("Broadcasts = %+v, want enabled with the announce persona", b)
+	}
+	if len(b.Announcements) != 2 || b.Announcements[0].Deadline != announce.DefaultAckDeadline || b.Announcements[1].Deadline != deadline {
+		t.Errorf("Announcements = %+v, want the default deadline unless set", b.Announcements)
+	}
+
+	var configErr *errs.ConfigError
+	for key, broken := range map[string]announce.BroadcastFileConfig{
+		"announce.broadcasts.announcements[1].id":          {ID: "hours", Text: "Again", Channels: []string{"C0123456789"}},
+		"announce.broadcasts.announcements[1].text":        {ID: "other", Channels: []string{"C0123456789"}},
+		"announce.broadcasts.announcements[1].channels[0]": {ID: "other", Text: "Hi", Channels: []string{"general"}},
+	} {
+		opts.AnnounceBroadcasts.Announcements = []announce.BroadcastFileConfig{opts.AnnounceBroadcasts.Announcements[0], broken}
+		if _, err := newConfig(opts); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_FileScan(t *testing.T) {
+	c, err := newConfig(configOpts{FileScan: filescan.FileConfig{BlockedExtensions: []string{".EXE", " bat ", ""}}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if got := c.FileScan.BlockedExtensions; !slices.Equal(got, []string{"exe", "bat"}) {
+		t.Errorf("BlockedExtensions = %v, want normalized extensions", got)
+	}
+	if c.FileScan.Action != filescan.ActionFlag {
+		t.Errorf("Action = %q, want %q by default", c.FileScan.Action, filescan.ActionFlag)
+	}
+
+	action := "quarantine"
+	var configErr *errs.ConfigError
+	if _, err := newConfig(configOpts{FileScan: filescan.FileConfig{Action: &action}}); !errors.As(err, &configErr) || configErr.Key != "filescan.action" {
+		t.Errorf("newConfig() error = %v, want ConfigError for filescan.action", err)
+	}
+}
+
+func TestNewConfig_Feedback(t *testing.T) {
+	channel := "C1"
+	var configErr *errs.ConfigError
+	if _, err := newConfig(configOpts{Feedback: feedback.FileConfig{Channel: &channel}}); !errors.As(err, &configErr) || configErr.Key != "feedback.audit_public_key" {
+		t.Errorf("newConfig() error = %v, want ConfigError for feedback.audit_public_key", err)
+	}
+
+	public, _, err := feedback.GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys() error = %v", err)
+	}
+	c, err := newConfig(configOpts{Feedback: feedback.FileConfig{Channel: &channel, AuditPublicKey: &public}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.Feedback.RateLimit != feedback.DefaultRateLimit || c.Feedback.RateWindow != feedback.DefaultRateWindow {
+		t.Errorf("rate limit = %d per %s, want the defaults", c.Feedback.RateLimit, c.Feedback.RateWindow)
+	}
+}
+
+func TestNewConfig_AIChatLanguage(t *testing.T) {
+	var configErr *errs.ConfigError
+	opts := configOpts{AIChatChannelLanguage: map[string]aichat.LanguagePolicy{"C1": {Allowed: []string{"en", "xx"}}}}
+	if _, err := newConfig(opts); !errors.As(err, &configErr) || configErr.Key != "aichat.channel_language.C1.allowed[1]" {
+		t.Errorf("newConfig() error = %v, want ConfigError for the unknown language", err)
+	}
+}
+
+func TestNewConfig_AIChatChannelPersonas(t *testing.T) {
+	personas := "grumpy_mentor:\n  prompt: You are grumpy\n"
+	c, err := newConfig(configOpts{PersonasConfig: personas, AIChatChannelPersonas: map[string]string{"C0123456789": "grumpy_mentor"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.AIChat.ChannelPersonas["C0123456789"] != "grumpy_mentor" {
+		t.Errorf("channel personas = %v, want C0123456789 mapped to grumpy_mentor", c.AIChat.ChannelPersonas)
+	}
+
+	// Personas created from Slack may be named before they exist
+	opts := configOpts{PersonasConfig: personas, AIChatChannelPersonas: map[string]string{"C0123456789": "office_dj"}, Personas: personastore.FileConfig{Enabled: new(true)}}
+	if _, err := newConfig(opts); err != nil {
+		t.Errorf("newConfig() with stored personas error = %v", err)
+	}
+
+	tests := []struct {
+		channels map[string]string
+		key      string
+	}{
+		{map[string]string{"general": "grumpy_mentor"}, "aichat.channel_personas.general"},
+		{map[string]string{"C0123456789": "office_dj"}, "aichat.channel_personas.C0123456789"},
+	}
+	for _, tt := range tests {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{PersonasConfig: personas, AIChatChannelPersonas: tt.channels}); !errors.As(err, &configErr) || configErr.Key != tt.key {
+			t.Errorf("newConfig(%v) error = %v, want ConfigError for %s", tt.channels, err, tt.key)
+		}
+	}
+}
+
+func TestNewConfig_AIChatPersonaRules(t *testing.T) {
+	rules := []aichat.PersonaRule{{Persona: "grumpy_mentor", Days: []string{"fri"}, After: "17:00"}}
+	if _, err := newConfig(configOpts{AIChatPersonaRules: rules}); err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+
+	negative := -1.0
+	tests := []struct {
+		opts configOpts
+		key  string
+	}{
+		{configOpts{AIChatPersonaRules: []aichat.PersonaRule{rules[0], {Persona: "p", Days: []string{"someday"}}}}, "aichat.persona_rules[1]"},
+		{configOpts{AIChatPersonaRules: []aichat.PersonaRule{{Persona: "p", Before: "5pm"}}}, "aichat.persona_rules[0]"},
+		{configOpts{AIChatPersonaRules: []aichat.PersonaRule{{Persona: "p", Weight: &negative}}}, "aichat.persona_rules[0]"},
+		{configOpts{PersonasConfig: "grumpy_mentor:\n  prompt: You are grumpy\n  weight: -1\n"}, "aichat.personas.grumpy_mentor"},
+		{configOpts{PersonasConfig: "grumpy_mentor:\n  prompt: You are grumpy\n  min_temperature: 0.2\n"}, "aichat.personas.grumpy_mentor"},
+	}
+	for _, tt := range tests {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(tt.opts); !errors.As(err, &configErr) || configErr.Key != tt.key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, tt.key)
+		}
+	}
+}
+
+func TestNewConfig_Outbox(t *testing.T) {
+	c, err := newConfig(configOpts{Outbox: outbox.FileConfig{Features: map[string]time.Duration{"aichat": 10 * time.Second}}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.Outbox.CancelReaction != outbox.DefaultCancelReaction || c.Outbox.PendingReaction != outbox.DefaultPendingReaction {
+		t.Errorf("reactions = %q, %q, want the defaults", c.Outbox.CancelReaction, c.Outbox.PendingReaction)
+	}
+
+	var configErr *errs.ConfigError
+	opts := configOpts{Outbox: outbox.FileConfig{Features: map[string]time.Duration{"vibecheck": time.Second}}}
+	if _, err := newConfig(opts); !errors.As(err, &configErr) || configErr.Key != "outbox.features.vibecheck" {
+		t.Errorf("newConfig() error = %v, want ConfigError for outbox.features.vibecheck", err)
+	}
+
+	c, err = newConfig(configOpts{Outbox: outbox.FileConfig{Pacing: outbox.PacingFileConfig{Features: []string{"aichat"}, Max: new(5 * time.Second)}}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	want := outbox.PacingConfig{
+		Features:      []string{"aichat"},
+		Base:          outbox.DefaultPacingBase,
+		PerChar:       outbox.DefaultPacingPerChar,
+		Jitter:        outbox.DefaultPacingJitter,
+		Max:           5 * time.Second,
+		CancelOnNewer: true,
+	}
+	if p := c.Outbox.Pacing; !slices.Equal(p.Features, want.Features) || p.Base != want.Base || p.PerChar != want.PerChar || p.Jitter != want.Jitter || p.Max != want.Max || !p.CancelOnNewer {
+		t.Errorf("pacing = %+v, want %+v", p, want)
+	}
+	for _, pacing := range []outbox.PacingFileConfig{
+		{Features: []string{"vibecheck"}},
+		{Features: []string{"aichat"}, PerChar: new(-time.Millisecond)},
+	} {
+		if _, err := newConfig(configOpts{Outbox: outbox.FileConfig{Pacing: pacing}}); !errors.As(err, &configErr) || !strings.HasPrefix(configErr.Key, "outbox.pacing.") {
+			t.Errorf("newConfig(%+v) error = %v, want ConfigError for outbox.pacing", pacing, err)
+		}
+	}
+}
+
+func TestNewConfig_AIRoutes(t *testing.T) {
+	endpoints := map[string]ai.EndpointConfig{"cheap": {Providers: []ai.ProviderConfig{{BaseURL: "http://localhost:11434/v1", Model: "llama3.1"}}}}
+	c, err := newConfig(configOpts{AIEndpoints: endpoints, AIRoutes: map[string]string{"showerthought": "cheap"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.AI.Routes["showerthought"] != "cheap" || len(c.AI.Endpoints["cheap"].Providers) != 1 {
+		t.Errorf("AI config = %+v, want the showerthought route and cheap endpoint", c.AI)
+	}
+
+	tests := []struct {
+		routes map[string]string
+		key    string
+	}{
+		{map[string]string{"summarizer": "cheap"}, "ai.routes.summarizer"},
+		{map[string]string{"aichat": "premium"}, "ai.routes.aichat"},
+	}
+	for _, tt := range tests {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{AIEndpoints: endpoints, AIRoutes: tt.routes}); !errors.As(err, &configErr) || configErr.Key != tt.key {
+			t.Errorf("newConfig(%v) error = %v, want ConfigError for %s", tt.routes, err, tt.key)
+		}
+	}
+}
+
+func TestNewConfig_Analytics(t *testing.T) {
+	enabled := true
+	c, err := newConfig(configOpts{Analytics: analytics.FileConfig{Enabled: &enabled}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.Analytics.ReportDay != time.Monday || c.Analytics.ReportHour != 9 || c.Analytics.Retention != analytics.DefaultRetention {
+		t.Errorf("analytics config = %+v, want the defaults", c.Analytics)
+	}
+
+	day, hour := "someday", 24
+	tests := []struct {
+		opts analytics.FileConfig
+		key  string
+	}{
+		{analytics.FileConfig{ReportDay: &day}, "analytics.report_day"},
+		{analytics.FileConfig{ReportHour: &hour}, "analytics.report_hour"},
+	}
+	for _, tt := range tests {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Analytics: tt.opts}); !errors.As(err, &configErr) || configErr.Key != tt.key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, tt.key)
+		}
+	}
+}
+
+func TestCheckConfigKeys(t *testing.T) {
+	if err := CheckConfigKeys("../../config.yaml"); err != nil {
+		t.Errorf("CheckConfigKeys(config.yaml) error = %v", err)
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "known.yaml",
+			content: `preferred_users: [U1]
+ai:
+  model: gpt-4o
+  endpoints:
+    cheap:
+      providers:
+        - name: ollama
+aichat:
+  personas:
+    short: Be brief.
+    long:
+      prompt: Be thorough.
+`,
+		},
+		{
+			name: "typos.yaml",
+			content: `vibecheck:
+  ban_durration: 5m
+chat:
+  responses:
+    - pattern: hi
+      mesage: hello
+membership:
+  channels:
+    C1:
+      rooster: true
+nonsense: true
+`,
+			want: []string{
+				"chat.responses[0].mesage (did you mean message?)",
+				"membership.channels.C1.rooster (did you mean roster?)",
+				"nonsense,",
+				"vibecheck.ban_durration (did you mean ban_duration?)",
+			},
+		},
+		{
+			name:    "typos.json",
+			content: `{"analytics": {"report_dya": "monday"}}`,
+			want:    []string{"analytics.report_dya (did you mean report_day?)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := CheckConfigKeys(path)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("CheckConfigKeys() error = %v", err)
+				}
+				return
+			}
+			var configErr *errs.ConfigError
+			if !errors.As(err, &configErr) || configErr.Key != "config-file" {
+				t.Fatalf("CheckConfigKeys() error = %v, want ConfigError for config-file", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("CheckConfigKeys() error = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileFlagKeys(t *testing.T) {
+	// Every config file key a flag reads is known to strict mode and the default config
+	keyPath := regexp.MustCompile(`keyPath:"([^"]+)"`)
+	read := make(map[string]string)
+	for _, f := range Flags() {
+		sources := reflect.ValueOf(f).Elem().FieldByName("Sources")
+		if !sources.IsValid() {
+			continue
+		}
+		for _, source := range sources.Interface().(cli.ValueSourceChain).Chain {
+			if m := keyPath.FindStringSubmatch(fmt.Sprintf("%#v", source)); m != nil {
+				read[m[1]] = f.Names()[0]
+			}
+		}
+	}
+	if !reflect.DeepEqual(read, fileFlagKeys) {
+		t.Errorf("fileFlagKeys = %v, want the keys flags read: %v", fileFlagKeys, read)
+	}
+}
+
+func TestWriteDefaultConfig(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteDefaultConfig(&out); err != nil {
+		t.Fatalf("WriteDefaultConfig() error = %v", err)
+	}
+	for _, want := range []string{
+		"\n# vibecheck:\n#   good_reactions:\n#     - <string>\n",
+		"\n#   ban_duration: <duration> # Duration to ban users",
+		"\n#   personas:\n#     <string>:\n#       prompt: <string>\n",
+		"\n#   providers:\n#     - name: <string>\n#       base_url: <string>\n",
+		"\n#   model: <string> # Default OpenAI model",
+		"\n# slack_team_ids: # Workspace or Enterprise Grid IDs",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("WriteDefaultConfig() missing %q in:\n%s", want, out.String())
+		}
+	}
+	for line := range strings.Lines(out.String()) {
+		if !strings.HasPrefix(line, "#") {
+			t.Errorf("WriteDefaultConfig() line %q isn't commented out", line)
+		}
+	}
+}
+
+func TestNewConfig_SelfTest(t *testing.T) {
+	channel := "COPS"
+	c, err := newConfig(configOpts{SelfTest: selftest.FileConfig{Channel: &channel}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.SelfTest.Channel != channel || c.SelfTest.Hour != selftest.DefaultHour {
+		t.Errorf("self-test config = %+v, want the ops channel and default hour", c.SelfTest)
+	}
+
+	hour := -1
+	var configErr *errs.ConfigError
+	if _, err := newConfig(configOpts{SelfTest: selftest.FileConfig{Channel: &channel, Hour: &hour}}); !errors.As(err, &configErr) || configErr.Key != "selftest.hour" {
+		t.Errorf("newConfig() error = %v, want ConfigError for selftest.hour", err)
+	}
+}
+
+func TestNewConfig_Leader(t *testing.T) {
+	enabled := true
+	c, err := newConfig(configOpts{Leader: leader.FileConfig{Enabled: &enabled, Address: "redis:6379"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.Leader.Key != leader.DefaultKey || c.Leader.TTL != leader.DefaultTTL || c.Leader.ID == "" {
+		t.Errorf("leader config = %+v, want the default key, TTL and an ID", c.Leader)
+	}
+
+	ttl := time.Second
+	var configErr *errs.ConfigError
+	if _, err := newConfig(configOpts{Leader: leader.FileConfig{Enabled: &enabled, Address: "redis:6379", TTL: &ttl}}); !errors.As(err, &configErr) || configErr.Key != "leader.ttl" {
+		t.Errorf("newConfig() error = %v, want ConfigError for leader.ttl", err)
+	}
+	if _, err := newConfig(configOpts{Leader: leader.FileConfig{Enabled: &enabled}}); !errors.As(err, &configErr) || configErr.Key != "leader.address" {
+		t.Errorf("newConfig() error = %v, want ConfigError for leader.address", err)
+	}
+}
+
+func TestNewConfig_OnEdit(t *testing.T) {
+	c, err := newConfig(configOpts{ChatOnEdit: retract.FileConfig{Reactions: new(true)}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if want := (retract.Config{Reactions: true, Window: retract.DefaultWindow}); c.Chat.OnEdit != want {
+		t.Errorf("chat.on_edit = %+v, want %+v", c.Chat.OnEdit, want)
+	}
+	if c.Vibecheck.OnEdit.Enabled() {
+		t.Errorf("vibecheck.on_edit = %+v, want nothing retracted by default", c.Vibecheck.OnEdit)
+	}
+
+	window := -time.Minute
+	var configErr *errs.ConfigError
+	if _, err := newConfig(configOpts{VibecheckOnEdit: retract.FileConfig{Window: &window}}); !errors.As(err, &configErr) || configErr.Key != "vibecheck.on_edit.window" {
+		t.Errorf("newConfig() error = %v, want ConfigError for vibecheck.on_edit.window", err)
+	}
+}
+
+func TestNewConfig_EventBus(t *testing.T) {
+	enabled := true
+	c, err := newConfig(configOpts{EventBus: eventbus.FileConfig{Enabled: &enabled, Address: "redis:6379"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if b := c.EventBus; b.Backend != eventbus.BackendRedis || b.Role != eventbus.RoleWorker || b.Stream != eventbus.DefaultStream || b.Group != eventbus.DefaultGroup || b.Consumer == "" {
+		t.Errorf("event bus config = %+v, want a redis worker with the defaults", b)
+	}
+	if c, err := newConfig(configOpts{}); err != nil || c.EventBus.Enabled {
+		t.Errorf("newConfig() = %+v, %v, want the event bus off by default", c.EventBus, err)
+	}
+
+	for key, fc := range map[string]eventbus.FileConfig{
+		"event_bus.address": {Enabled: &enabled},
+		"event_bus.backend": {Enabled: &enabled, Address: "nats:4222", Backend: "nats"},
+		"event_bus.role":    {Enabled: &enabled, Address: "redis:6379", Role: "both"},
+		"event_bus.max_len": {Enabled: &enabled, Address: "redis:6379", MaxLen: new(0)},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{EventBus: fc}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_Shadow(t *testing.T) {
+	c, err := newConfig(configOpts{DataDir: "/data", Shadow: []string{"aichat"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !slices.Equal(c.Slack.Shadow, []string{"aichat"}) || c.Slack.ShadowLog != "/data/shadow.jsonl" {
+		t.Errorf("slack config = %+v, want aichat shadowed into the data directory", c.Slack)
+	}
+	if c.Slack.MessageMetadata {
+		t.Error("MessageMetadata = true, want it off unless configured")
+	}
+	if c, err := newConfig(configOpts{MessageMetadata: true}); err != nil || !c.Slack.MessageMetadata {
+		t.Errorf("newConfig() = %v, want message metadata passed to slack", err)
+	}
+
+	var configErr *errs.ConfigError
+	if _, err := newConfig(configOpts{Shadow: []string{"aichat", "karma"}}); !errors.As(err, &configErr) || configErr.Key != "shadow[1]" {
+		t.Errorf("newConfig() error = %v, want ConfigError for shadow[1]", err)
+	}
+}
+
+func TestNewConfig_GRPC(t *testing.T) {
+	enabled := true
+	c, err := newConfig(configOpts{ServerPort: 4200, GRPC: api.FileConfig{Enabled: &enabled, Token: "secret", TLSCert: "cert.pem", TLSKey: "key.pem"}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.GRPC.Port != api.DefaultPort || c.GRPC.Token != "secret" {
+		t.Errorf("grpc config = %+v, want the default port", c.GRPC)
+	}
+
+	for key, fc := range map[string]api.FileConfig{
+		"grpc.token":    {Enabled: &enabled, TLSCert: "cert.pem", TLSKey: "key.pem"},
+		"grpc.tls_cert": {Enabled: &enabled, Token: "secret", TLSCert: "cert.pem"},
+		"grpc.port":     {Enabled: &enabled, Port: new(uint32(4200)), Token: "secret", TLSCert: "cert.pem", TLSKey: "key.pem"},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{ServerPort: 4200, GRPC: fc}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_LastSeen(t *testing.T) {
+	c, err := newConfig(configOpts{LastSeen: lastseen.FileConfig{Enabled: new(true), ReportDay: new("fri")}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.LastSeen.InactiveAfter != lastseen.DefaultInactiveAfter || c.LastSeen.ReportDay != time.Friday || c.LastSeen.ReportHour != lastseen.DefaultReportHour {
+		t.Errorf("lastseen config = %+v, want the defaults and Friday", c.LastSeen)
+	}
+
+	for key, fc := range map[string]lastseen.FileConfig{
+		"lastseen.report_to":      {ReportChannels: []string{"C1"}},
+		"lastseen.report_day":     {ReportDay: new("someday")},
+		"lastseen.inactive_after": {InactiveAfter: new(365 * 24 * time.Hour)},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{LastSeen: fc}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_Retention(t *testing.T) {
+	var fc FileConfig
+	content := "retention:\n  namespaces:\n    context: 30d\n    audit: forever\n  features:\n    filescan: 2160h\n"
+	if err := yaml.Unmarshal([]byte(content), &fc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	c, err := newConfig(configOpts{Retention: fc.Retention})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if got := c.Retention.Period("aichat"); got != 30*24*time.Hour {
+		t.Errorf("aichat period = %v, want 30 days", got)
+	}
+	if got := c.Retention.Period("feedback"); got != 0 {
+		t.Errorf("feedback period = %v, want forever", got)
+	}
+	if got := c.Retention.Period("filescan"); got != 2160*time.Hour {
+		t.Errorf("filescan period = %v, want the override", got)
+	}
+	if c.Retention.Grace != retention.DefaultGrace || c.Retention.Interval != retention.DefaultInterval {
+		t.Errorf("retention config = %+v, want the default grace and interval", c.Retention)
+	}
+
+	for key, rc := range map[string]retention.FileConfig{
+		"retention.namespaces.karma": {Namespaces: map[string]retention.Period{"karma": retention.Forever}},
+		"retention.features.topic":   {Features: map[string]retention.Period{"topic": retention.Forever}},
+		"retention.grace":            {Grace: new(retention.Forever)},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Retention: rc}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_Personas(t *testing.T) {
+	c, err := newConfig(configOpts{Personas: personastore.FileConfig{Enabled: new(true), Admins: []string{"U1"}}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !c.Personas.Enabled || !c.Personas.Review || c.Personas.MaxPromptLength != personastore.DefaultMaxPromptLength {
+		t.Errorf("personas config = %+v, want review and the default prompt limit", c.Personas)
+	}
+
+	for key, pc := range map[string]personastore.FileConfig{
+		"personas.admins":            {Admins: []string{"alice"}},
+		"personas.max_prompt_length": {MaxPromptLength: new(0)},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Personas: pc}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_Claim(t *testing.T) {
+	c, err := newConfig(configOpts{Claim: claim.FileConfig{Enabled: new(true), Priority: []string{"aichat", "chat"}}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !c.Claim.Enabled || c.Claim.MaxPerMessage != claim.DefaultMaxPerMessage || c.Claim.Window != claim.DefaultWindow {
+		t.Errorf("claim config = %+v, want the default limit and window", c.Claim)
+	}
+	if !slices.Equal(c.Claim.Priority, []string{"aichat", "chat"}) {
+		t.Errorf("claim priority = %v, want the configured order", c.Claim.Priority)
+	}
+
+	for key, cc := range map[string]claim.FileConfig{
+		"claim.max_per_message": {MaxPerMessage: new(-1)},
+		"claim.channels.C1":     {Channels: map[string]int{"C1": -1}},
+		"claim.priority":        {Priority: []string{"chat", "topic"}},
+		"claim.window":          {Window: new(-time.Second)},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Claim: cc}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	c, err := newConfig(configOpts{
+		SlackToken:     "xoxb-secret",
+		HTTPAdminToken: "admin-secret",
+		OpenAIAPIKey:   "sk-secret",
+		DataDir:        "/data",
+	})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	values, err := c.Redacted()
+	if err != nil {
+		t.Fatalf("Redacted() error = %v", err)
+	}
+	data, _ := json.Marshal(values)
+	for _, secret := range []string{"xoxb-secret", "admin-secret", "sk-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Redacted() contains %q", secret)
+		}
+	}
+	slackValues, _ := values["Slack"].(map[string]any)
+	if slackValues["Token"] != "[redacted]" || values["DataDir"] != "/data" {
+		t.Errorf("Redacted() = Slack.Token %v, DataDir %v, want only the token hidden", slackValues["Token"], values["DataDir"])
+	}
+}
+
+func TestNewConfig_Schedules(t *testing.T) {
+	c, err := newConfig(configOpts{Schedules: []scheduler.ScheduleFileConfig{
+		{Name: "standup", Cron: "30 9 * * mon-fri", Channel: "C0123456789", Message: "Happy {{.Weekday}}", AI: new(true), Timezone: new("America/Denver")},
+	}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if got := c.Scheduler.Schedules; len(got) != 1 || !got[0].AI || got[0].Timezone != "America/Denver" {
+		t.Errorf("Schedules = %+v, want the standup with the AI and its timezone", got)
+	}
+
+	valid := scheduler.ScheduleFileConfig{Name: "other", Cron: "@daily", Channel: "C0123456789", Message: "Hi"}
+	for key, broken := range map[string]func(*scheduler.ScheduleFileConfig){
+		"schedules[1].name":     func(s *scheduler.ScheduleFileConfig) { s.Name = "standup" },
+		"schedules[1].cron":     func(s *scheduler.ScheduleFileConfig) { s.Cron = "every day" },
+		"schedules[1].channel":  func(s *scheduler.ScheduleFileConfig) { s.Channel = "general" },
+		"schedules[1].message":  func(s *scheduler.ScheduleFileConfig) { s.Message = "Happy {{.Weekday" },
+		"schedules[1].timezone": func(s *scheduler.ScheduleFileConfig) { s.Timezone = new("Nowhere/Unknown") },
+	} {
+		s := valid
+		broken(&s)
+		schedules := []scheduler.ScheduleFileConfig{{Name: "standup", Cron: "@daily", Channel: "C0123456789", Message: "Hi"}, s}
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Schedules: schedules}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_Explain(t *testing.T) {
+	c, err := newConfig(configOpts{})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.Explain.Enabled || c.Explain.Reaction != explain.DefaultReaction || c.Explain.MaxTraces != explain.DefaultMaxTraces {
+		t.Errorf("Explain = %+v, want disabled with the defaults", c.Explain)
+	}
+
+	c, err = newConfig(configOpts{Explain: explain.FileConfig{Enabled: new(true), Reaction: new(":eyes:"), Admins: []string{"U1"}, MaxTraces: new(50)}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !c.Explain.Enabled || c.Explain.Reaction != "eyes" || c.Explain.MaxTraces != 50 || len(c.Explain.Admins) != 1 {
+		t.Errorf("Explain = %+v, want enabled with eyes, 50 traces and the admin", c.Explain)
+	}
+
+	for key, broken := range map[string]explain.FileConfig{
+		"explain.reaction":   {Reaction: new("not an emoji")},
+		"explain.admins":     {Admins: []string{"admin"}},
+		"explain.max_traces": {MaxTraces: new(0)},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Explain: broken}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_Karma(t *testing.T) {
+	c, err := newConfig(configOpts{Karma: karma.FileConfig{Enabled: new(true), Upvotes: []string{":fire:", "100"}, Downvotes: []string{}}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if !c.Karma.Enabled || !slices.Equal(c.Karma.Upvotes, []string{"fire", "100"}) || len(c.Karma.Downvotes) != 0 || c.Karma.LeaderboardSize != karma.DefaultLeaderboardSize {
+		t.Errorf("Karma = %+v, want fire and 100 without downvotes", c.Karma)
+	}
+
+	for key, broken := range map[string]karma.FileConfig{
+		"karma.downvotes[0]":     {Upvotes: []string{"+1"}, Downvotes: []string{":+1:"}},
+		"karma.upvotes[1]":       {Upvotes: []string{"+1", "not an emoji"}},
+		"karma.leaderboard_size": {LeaderboardSize: new(0)},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Karma: broken}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_Blocklist(t *testing.T) {
+	c, err := newConfig(configOpts{Blocklist: slack.BlocklistFileConfig{Phrases: []string{"hunter2"}, Patterns: []string{`xox[bp]-\S+`}, Action: new("redact")}})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if b := c.Slack.Blocklist; !b.Redact || b.Replacement != slack.DefaultBlocklistReplacement || len(b.Phrases) != 1 || len(b.Patterns) != 1 {
+		t.Errorf("Blocklist = %+v, want the phrase and pattern redacted with the default replacement", b)
+	}
+
+	for key, broken := range map[string]slack.BlocklistFileConfig{
+		"blocklist.phrases[1]":  {Phrases: []string{"hunter2", " "}},
+		"blocklist.patterns[0]": {Patterns: []string{`(unclosed`}},
+		"blocklist.patterns[1]": {Patterns: []string{`secret`, `.*`}},
+		"blocklist.action":      {Action: new("mask")},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(configOpts{Blocklist: broken}); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_AIChatRateLimits(t *testing.T) {
+	c, err := newConfig(configOpts{
+		AIChatMessageRate: aichat.RateLimit{Every: 10 * time.Minute, Burst: 2},
+		AIChatMentionRate: aichat.RateLimit{Every: time.Minute, Burst: 3},
+		AIChatQueueSize:   4,
+		AIChatQueueMaxAge: 2 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if got := c.AIChat.MessageRate; got.Every != 10*time.Minute || got.Burst != 2 {
+		t.Errorf("MessageRate = %+v", got)
+	}
+	if got := c.AIChat.MentionRate; got.Every != time.Minute || got.Burst != 3 {
+		t.Errorf("MentionRate = %+v", got)
+	}
+	if c.AIChat.QueueSize != 4 || c.AIChat.QueueMaxAge != 2*time.Minute {
+		t.Errorf("QueueSize, QueueMaxAge = %d, %s, want 4, 2m", c.AIChat.QueueSize, c.AIChat.QueueMaxAge)
+	}
+
+	for key, opts := range map[string]configOpts{
+		"aichat.message_rate":  {AIChatMessageRate: aichat.RateLimit{Every: -time.Minute, Burst: 1}},
+		"aichat.mention_burst": {AIChatMentionRate: aichat.RateLimit{Every: time.Minute, Burst: -1}},
+		"aichat.queue_size":    {AIChatQueueSize: -1},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(opts); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
+
+func TestNewConfig_ChatActive(t *testing.T) {
+	c, err := newConfig(configOpts{
+		ChatTimezone:  "America/Denver",
+		ChatResponses: []chat.Response{{Pattern: "deploy", Message: "ship it", ActiveHours: "09:00-17:00", ActiveDays: []string{"mon-fri"}}},
+	})
+	if err != nil {
+		t.Fatalf("newConfig() error = %v", err)
+	}
+	if c.Chat.Timezone != "America/Denver" {
+		t.Errorf("Chat.Timezone = %q, want America/Denver", c.Chat.Timezone)
+	}
+
+	for key, opts := range map[string]configOpts{
+		"chat.timezone":                  {ChatTimezone: "Mars/Olympus_Mons"},
+		"chat.responses[0].active_hours": {ChatResponses: []chat.Response{{Pattern: "a", Message: "b", ActiveHours: "9-5"}}},
+		"chat.responses[0].active_days":  {ChatResponses: []chat.Response{{Pattern: "a", Message: "b", ActiveDays: []string{"weekdays"}}}},
+	} {
+		var configErr *errs.ConfigError
+		if _, err := newConfig(opts); !errors.As(err, &configErr) || configErr.Key != key {
+			t.Errorf("newConfig() error = %v, want ConfigError for %s", err, key)
+		}
+	}
+}
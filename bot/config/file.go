@@ -16,9 +16,36 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/goccy/go-yaml"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/ai"
 	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/analytics"
+	"slackbot.arpa/bot/announce"
+	"slackbot.arpa/bot/api"
+	"slackbot.arpa/bot/backup"
 	"slackbot.arpa/bot/chat"
+	"slackbot.arpa/bot/claim"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/eventbus"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/facts"
+	"slackbot.arpa/bot/feedback"
+	"slackbot.arpa/bot/filescan"
+	"slackbot.arpa/bot/handoff"
+	"slackbot.arpa/bot/http"
+	"slackbot.arpa/bot/incident"
+	"slackbot.arpa/bot/karma"
+	"slackbot.arpa/bot/lastseen"
+	"slackbot.arpa/bot/leader"
+	"slackbot.arpa/bot/loopguard"
+	"slackbot.arpa/bot/membership"
+	"slackbot.arpa/bot/outbox"
+	"slackbot.arpa/bot/personastore"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/selftest"
 	"slackbot.arpa/bot/showerthought"
+	"slackbot.arpa/bot/slack"
+	"slackbot.arpa/bot/topic"
 	"slackbot.arpa/bot/user"
 	"slackbot.arpa/bot/vibecheck"
 )
@@ -30,6 +57,51 @@ type FileConfig struct {
 	Vibecheck     vibecheck.FileConfig     `json:"vibecheck" yaml:"vibecheck"`
 	AIChat        aichat.FileConfig        `json:"aichat" yaml:"aichat"`
 	ShowerThought showerthought.FileConfig `json:"showerthought" yaml:"showerthought"`
+	LoopGuard     loopguard.FileConfig     `json:"loop_guard" yaml:"loop_guard"`
+	Incident      incident.FileConfig      `json:"incident" yaml:"incident"`
+	AI            ai.FileConfig            `json:"ai" yaml:"ai"`
+	Membership    membership.FileConfig    `json:"membership" yaml:"membership"`
+	Backup        backup.FileConfig        `json:"backup" yaml:"backup"`
+	Announce      announce.FileConfig      `json:"announce" yaml:"announce"`
+	Topic         topic.FileConfig         `json:"topic" yaml:"topic"`
+	FileScan      filescan.FileConfig      `json:"filescan" yaml:"filescan"`
+	Feedback      feedback.FileConfig      `json:"feedback" yaml:"feedback"`
+	Outbox        outbox.FileConfig        `json:"outbox" yaml:"outbox"`
+	Handoff       handoff.FileConfig       `json:"handoff" yaml:"handoff"`
+	Analytics     analytics.FileConfig     `json:"analytics" yaml:"analytics"`
+	SelfTest      selftest.FileConfig      `json:"selftest" yaml:"selftest"`
+	Leader        leader.FileConfig        `json:"leader" yaml:"leader"`
+	EventBus      eventbus.FileConfig      `json:"event_bus" yaml:"event_bus"`
+	Facts         facts.FileConfig         `json:"facts" yaml:"facts"`
+	GRPC          api.FileConfig           `json:"grpc" yaml:"grpc"`
+	LastSeen      lastseen.FileConfig      `json:"lastseen" yaml:"lastseen"`
+	Retention     retention.FileConfig     `json:"retention" yaml:"retention"`
+	Personas      personastore.FileConfig  `json:"personas" yaml:"personas"`
+	Claim         claim.FileConfig         `json:"claim" yaml:"claim"`
+
+	// Schedules are messages posted on cron schedules
+	Schedules []scheduler.ScheduleFileConfig `json:"schedules" yaml:"schedules"`
+	// Explain threads why the bot posted a message when an admin reacts to it
+	Explain explain.FileConfig `json:"explain" yaml:"explain"`
+	// Karma counts the upvote and downvote reactions people's messages collect
+	Karma karma.FileConfig `json:"karma" yaml:"karma"`
+	// Shadow lists features that run without sending anything to Slack, recording what
+	// they would have sent, e.g. aichat while trying a new persona
+	Shadow []string `json:"shadow" yaml:"shadow"`
+	// MessageMetadata attaches metadata naming the feature and the event it responds to to
+	// the bot's messages, for workflow automations and the bot's own history reads
+	MessageMetadata bool `json:"message_metadata" yaml:"message_metadata"`
+	// Blocklist is text no feature may send, e.g. secrets, slurs or names, blocked or redacted
+	Blocklist slack.BlocklistFileConfig `json:"blocklist" yaml:"blocklist"`
+	// TriggerAliases are words treated like a bot mention, e.g. "hey bot" or a nickname
+	TriggerAliases []string `json:"trigger_aliases" yaml:"trigger_aliases"`
+	// MessageSubtypes are the message subtypes chat, vibecheck and aichat handle besides
+	// plain messages, e.g. thread_broadcast
+	MessageSubtypes []string `json:"message_subtypes" yaml:"message_subtypes"`
+	// LogLevels sets per-feature log levels, e.g. aichat: debug
+	LogLevels map[string]string `json:"log_levels" yaml:"log_levels"`
+	// HTTPAuth holds credentials per HTTP route group: health, admin or webhook
+	HTTPAuth map[string]http.AuthConfig `json:"http_auth" yaml:"http_auth"`
 }
 
 // ConfigWatcher watches a configuration file for changes and parses its content
@@ -224,20 +296,20 @@ func ReadConfig(filePath string, v any) error {
 
 	content, err := os.ReadFile(filePath) // #nosec G304 -- filePath is controlled by configuration
 	if err != nil {
-		return fmt.Errorf("read config file: %w", err)
+		return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("read %s: %w", filePath, err)}
 	}
 
 	switch ext {
 	case ".json":
 		if err := json.Unmarshal(content, v); err != nil {
-			return fmt.Errorf("unmarshal json: %w", err)
+			return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("unmarshal json: %w", err)}
 		}
 	case ".yaml", ".yml":
 		if err := yaml.Unmarshal(content, v); err != nil {
-			return fmt.Errorf("unmarshal yaml: %w", err)
+			return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("unmarshal yaml: %w", err)}
 		}
 	default:
-		return fmt.Errorf("unsupported config file format: %s", ext)
+		return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("unsupported config file format: %s", ext)}
 	}
 
 	return nil
@@ -12,9 +12,60 @@ import (
 	altsrc "github.com/urfave/cli-altsrc/v3"
 	yaml "github.com/urfave/cli-altsrc/v3/yaml"
 	"github.com/urfave/cli/v3"
+	"slackbot.arpa/bot/aichat"
 	"slackbot.arpa/bot/http"
+	"slackbot.arpa/bot/slack"
 )
 
+// Output formats for command results, set with --output
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// OutputFlag selects the command output format. Commands run by the bot for the control
+// socket take it too.
+func OutputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "Command output format, text or json. With json, results are printed to stdout and logs to stderr.",
+		Value:   OutputText,
+		Sources: cli.EnvVars("OUTPUT"),
+		Validator: func(v string) error {
+			options := []string{OutputText, OutputJSON}
+			if slices.Contains(options, v) {
+				return nil
+			}
+			return cli.Exit(fmt.Errorf("'output' must be %v. Received: %v", strings.Join(options, ", "), v), 2)
+		},
+	}
+}
+
+// fileFlagKeys maps the config file keys flags read, besides FileConfig, to their flag
+var fileFlagKeys = map[string]string{
+	"preferred_users":             "slack-preferred-users",
+	"preferred_channels":          "slack-preferred-channels",
+	"slack_team_ids":              "slack-team-ids",
+	"user.notify_channel":         "slack-user-notify-channel",
+	"slack_events_path":           "slack-events-path",
+	"slack_interactions_path":     "slack-interactions-path",
+	"slack_commands_path":         "slack-commands-path",
+	"slack_breaker_threshold":     "slack-breaker-threshold",
+	"slack_breaker_cooldown":      "slack-breaker-cooldown",
+	"slack_rejoin_channels":       "slack-rejoin-channels",
+	"slack_dm_respect_dnd":        "slack-dm-respect-dnd",
+	"slack_dm_urgent_kinds":       "slack-dm-urgent-kinds",
+	"slack_signature_tolerance":   "slack-signature-tolerance",
+	"ai.model":                    "openai-model",
+	"aichat.sticky_duration":      "personas-sticky-duration",
+	"aichat.max_context_messages": "aichat-max-context-messages",
+	"aichat.max_context_age":      "aichat-max-context-age",
+	"aichat.max_context_tokens":   "aichat-max-context-tokens",
+	"aichat.rate_limit_enabled":   "aichat-rate-limit-enabled",
+	"vibecheck.ban_duration":      "vibecheck-ban-duration",
+}
+
 func Flags() []cli.Flag {
 	var configFile string
 	return []cli.Flag{
@@ -31,6 +82,11 @@ func Flags() []cli.Flag {
 				return cli.Exit(fmt.Errorf("'log-level' must be %v. Received: %v", strings.Join(options, ", "), v), 2)
 			},
 		},
+		&cli.StringSliceFlag{
+			Name:    "log-levels",
+			Usage:   "Per-feature log levels as feature=level, e.g. aichat=debug,chat=warn. Features without a level use log-level.",
+			Sources: cli.EnvVars("LOG_LEVELS"),
+		},
 		&cli.StringFlag{
 			Name:    "env",
 			Usage:   "build environment description",
@@ -45,6 +101,7 @@ func Flags() []cli.Flag {
 				return cli.Exit(fmt.Errorf("'env' must be %v. Received: %v", strings.Join(Environments, ", "), v), 2)
 			},
 		},
+		OutputFlag(),
 		&cli.StringFlag{
 			Name:    "data-dir",
 			Usage:   "Data storage directory, may be relative or absolute",
@@ -63,6 +120,17 @@ func Flags() []cli.Flag {
 			Value:   http.DefaultServerPort,
 			Sources: cli.EnvVars("SERVER_PORT"),
 		},
+		&cli.StringFlag{
+			Name:    "http-admin-token",
+			Aliases: []string{"admin-token"},
+			Usage:   "Bearer token required by admin HTTP endpoints, e.g. /admin/status. Admin endpoints are refused when no admin credentials are set.",
+			Sources: cli.EnvVars("HTTP_ADMIN_TOKEN", "ADMIN_TOKEN"),
+		},
+		&cli.StringFlag{
+			Name:    "control-socket",
+			Usage:   "Unix socket where the running bot serves CLI commands. Defaults to control.sock in the data directory.",
+			Sources: cli.EnvVars("CONTROL_SOCKET"),
+		},
 		&cli.StringFlag{
 			Name:    "config-file",
 			Aliases: []string{"config", "c"},
@@ -80,6 +148,11 @@ func Flags() []cli.Flag {
 			},
 			Destination: &configFile,
 		},
+		&cli.BoolFlag{
+			Name:    "strict-config",
+			Usage:   "Fail on config file keys nothing reads, like a misspelled ban_durration, instead of ignoring them. Print every key with `slackbot docs config`.",
+			Sources: cli.EnvVars("CONFIG_STRICT"),
+		},
 		&cli.StringSliceFlag{
 			Name:  "slack-preferred-users",
 			Usage: "Preference toward users.",
@@ -96,6 +169,28 @@ func Flags() []cli.Flag {
 				yaml.YAML("preferred_channels", altsrc.NewStringPtrSourcer(&configFile)),
 			),
 		},
+		&cli.StringSliceFlag{
+			Name:  "slack-team-ids",
+			Usage: "Workspace or Enterprise Grid IDs whose events are processed. Events from other teams are dropped. Empty allows any team.",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_TEAM_IDS"),
+				yaml.YAML("slack_team_ids", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.StringSliceFlag{
+			Name:  "message-subtypes",
+			Usage: "Message subtypes handled by chat, vibecheck and aichat besides plain messages. Defaults to thread_broadcast, file_share and me_message.",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("MESSAGE_SUBTYPES"),
+			),
+		},
+		&cli.StringSliceFlag{
+			Name:  "trigger-aliases",
+			Usage: "Words treated like a bot mention, e.g. \"hey bot\" or a nickname. Defaults to \"bot\".",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("TRIGGER_ALIASES"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "slack-user-notify-channel",
 			Usage: "Channel name to notify when a user is added or removed from the Slack organization.",
@@ -115,9 +210,81 @@ func Flags() []cli.Flag {
 			),
 		},
 		&cli.StringFlag{
-			Name:     "slack-token",
-			Usage:    "Slack Client Secret for OAuth authentication.",
-			Required: true,
+			Name:  "slack-interactions-path",
+			Usage: "HTTP path for the Slack interactivity (Block Kit actions) endpoint.",
+			Value: "/api/slack/interactions",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_INTERACTIONS_PATH"),
+				yaml.YAML("slack_interactions_path", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "slack-commands-path",
+			Usage: "HTTP path for the Slack slash commands endpoint.",
+			Value: "/api/slack/commands",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_COMMANDS_PATH"),
+				yaml.YAML("slack_commands_path", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.IntFlag{
+			Name:  "slack-breaker-threshold",
+			Usage: "Consecutive Slack API failures before non-essential posting is paused.",
+			Value: 5,
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_BREAKER_THRESHOLD"),
+				yaml.YAML("slack_breaker_threshold", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.DurationFlag{
+			Name:  "slack-breaker-cooldown",
+			Usage: "How long the Slack API must stay healthy before posting resumes.",
+			Value: time.Minute,
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_BREAKER_COOLDOWN"),
+				yaml.YAML("slack_breaker_cooldown", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "slack-rejoin-channels",
+			Usage: "Rejoin public channels the bot was removed from when a feature posts there, instead of skipping them.",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_REJOIN_CHANNELS"),
+				yaml.YAML("slack_rejoin_channels", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "slack-dm-respect-dnd",
+			Usage: "Hold direct messages until the recipient's Do Not Disturb ends (scope: dnd:read).",
+			Value: true,
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_DM_RESPECT_DND"),
+				yaml.YAML("slack_dm_respect_dnd", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.StringSliceFlag{
+			Name:  "slack-dm-urgent-kinds",
+			Usage: "Kinds of direct messages sent even during Do Not Disturb, e.g. memory.",
+			Value: []string{aichat.MemoryDMKind},
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_DM_URGENT_KINDS"),
+				yaml.YAML("slack_dm_urgent_kinds", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		&cli.DurationFlag{
+			Name:  "slack-signature-tolerance",
+			Usage: "How far a Slack request timestamp may drift from local time before it is rejected. Raise for clock-skewed hosts.",
+			Value: slack.DefaultSignatureTolerance,
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("SLACK_SIGNATURE_TOLERANCE"),
+				yaml.YAML("slack_signature_tolerance", altsrc.NewStringPtrSourcer(&configFile)),
+			),
+		},
+		// Not marked required so commands like docs run without credentials; setup still
+		// refuses to start without a token
+		&cli.StringFlag{
+			Name:  "slack-token",
+			Usage: "Slack Client Secret for OAuth authentication.",
 			Sources: cli.NewValueSourceChain(
 				cli.EnvVar("SLACK_TOKEN"),
 				cli.File("/run/secrets/slack_token"),
@@ -140,13 +307,19 @@ func Flags() []cli.Flag {
 			),
 		},
 		&cli.StringFlag{
-			Name:  "personas-config",
-			Usage: "JSON or YAML string defining AI Chat personas as name:prompt pairs.",
+			Name:  "openai-model",
+			Usage: "Default OpenAI model. Personas may override it per call.",
 			Sources: cli.NewValueSourceChain(
-				cli.EnvVar("AI_PERSONAS_CONFIG"),
-				yaml.YAML("aichat.personas", altsrc.NewStringPtrSourcer(&configFile)),
+				cli.EnvVar("OPENAI_MODEL"),
+				yaml.YAML("ai.model", altsrc.NewStringPtrSourcer(&configFile)),
 			),
 		},
+		&cli.StringFlag{
+			Name: "personas-config",
+			Usage: "JSON or YAML string defining AI Chat personas as name:prompt pairs or " +
+				"name:{prompt, model, ...} mappings. Personas in the config file are merged by the config manager.",
+			Sources: cli.EnvVars("AI_PERSONAS_CONFIG"),
+		},
 		&cli.DurationFlag{
 			Name:  "personas-sticky-duration",
 			Usage: "Duration for which a persona is assigned to a user before changing.",
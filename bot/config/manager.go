@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"sync"
@@ -9,15 +10,40 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/goccy/go-yaml"
 	"github.com/urfave/cli/v3"
 	"go.uber.org/zap"
 
 	"slackbot.arpa/bot/ai"
 	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/analytics"
+	"slackbot.arpa/bot/announce"
+	"slackbot.arpa/bot/api"
+	"slackbot.arpa/bot/backup"
 	"slackbot.arpa/bot/chat"
+	"slackbot.arpa/bot/claim"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/eventbus"
+	"slackbot.arpa/bot/explain"
+	"slackbot.arpa/bot/facts"
+	"slackbot.arpa/bot/feedback"
+	"slackbot.arpa/bot/filescan"
+	"slackbot.arpa/bot/handoff"
 	"slackbot.arpa/bot/http"
+	"slackbot.arpa/bot/incident"
+	"slackbot.arpa/bot/karma"
+	"slackbot.arpa/bot/lastseen"
+	"slackbot.arpa/bot/leader"
+	"slackbot.arpa/bot/loopguard"
+	"slackbot.arpa/bot/membership"
+	"slackbot.arpa/bot/outbox"
+	"slackbot.arpa/bot/personastore"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/selftest"
 	"slackbot.arpa/bot/showerthought"
 	"slackbot.arpa/bot/slack"
+	"slackbot.arpa/bot/topic"
 	"slackbot.arpa/bot/user"
 	"slackbot.arpa/bot/vibecheck"
 )
@@ -25,6 +51,7 @@ import (
 // ConfigProvider provides access to live configuration with hot-reload support
 type ConfigProvider interface {
 	GetConfig() *Config
+	GetFileConfig() *FileConfig
 	GetAIChatConfig() aichat.Config
 	GetChatConfig() chat.Config
 	GetVibecheckConfig() vibecheck.Config
@@ -33,6 +60,32 @@ type ConfigProvider interface {
 	GetUserConfig() user.Config
 	GetHTTPConfig() http.Config
 	GetShowerthoughtConfig() showerthought.Config
+	GetLoopGuardConfig() loopguard.Config
+	GetIncidentConfig() incident.Config
+	GetMembershipConfig() membership.Config
+	GetBackupConfig() backup.Config
+	GetAnnounceConfig() announce.Config
+	GetTopicConfig() topic.Config
+	GetFileScanConfig() filescan.Config
+	GetFeedbackConfig() feedback.Config
+	GetOutboxConfig() outbox.Config
+	GetHandoffConfig() handoff.Config
+	GetAnalyticsConfig() analytics.Config
+	GetSelfTestConfig() selftest.Config
+	GetLeaderConfig() leader.Config
+	GetEventBusConfig() eventbus.Config
+	GetFactsConfig() facts.Config
+	GetGRPCConfig() api.Config
+	GetLastSeenConfig() lastseen.Config
+	GetRetentionConfig() retention.Config
+	GetPersonasConfig() personastore.Config
+	GetClaimConfig() claim.Config
+	GetSchedulerConfig() scheduler.Config
+	GetExplainConfig() explain.Config
+	GetKarmaConfig() karma.Config
+	Overrides() map[string]string
+	SetOverride(key, value string) error
+	ClearOverride(key string) error
 	Subscribe(callback func(*Config)) func() // Returns unsubscribe function
 	Close() error
 }
@@ -41,13 +94,29 @@ type ConfigProvider interface {
 type CLIOverrides struct {
 	// Global settings
 	LogLevel    *string
+	LogLevels   []string
 	Environment *string
 	DataDir     *string
 	ConfigFile  *string
+	// StrictConfig fails loading a config file with keys nothing reads
+	StrictConfig bool
+	// Where the running bot serves CLI commands
+	ControlSocket *string
 
 	// Server settings
-	ServerPort     *uint32
-	SlackEventPath *string
+	ServerPort            *uint32
+	SlackEventPath        *string
+	SlackInteractionsPath *string
+	SlackCommandsPath     *string
+	SlackBreakerThreshold *int
+	SlackBreakerCooldown  *time.Duration
+	SlackRejoinChannels   *bool
+	SlackDMRespectDND     *bool
+	SlackDMUrgentKinds    []string
+
+	SlackSignatureTolerance *time.Duration
+	HTTPAdminToken          *string
+	SlackTeamIDs            []string
 
 	// Slack settings
 	SlackToken         *string
@@ -55,9 +124,12 @@ type CLIOverrides struct {
 	PreferredUsers     []string
 	PreferredChannels  []string
 	UserNotifyChannel  *string
+	TriggerAliases     []string
+	MessageSubtypes    []string
 
 	// AI settings
 	OpenAIAPIKey *string
+	OpenAIModel  *string
 
 	// AI Chat settings
 	PersonasConfig         *string
@@ -82,6 +154,12 @@ type ConfigManager struct {
 
 	// Merged config cache
 	mergedConfig atomic.Pointer[Config]
+	// File config with the runtime overrides applied, for settings the merged config leaves out
+	effectiveFileConfig atomic.Pointer[FileConfig]
+
+	// Config file keys overridden at runtime, see SetOverride
+	overrides   map[string]string
+	overridesMu sync.Mutex // Also serializes rebuilds
 
 	// File watching
 	watcher    *fsnotify.Watcher
@@ -117,6 +195,10 @@ func NewConfigManager(log *zap.Logger, buildOpts BuildOpts, cliOverrides *CLIOve
 	cm.watcher = watcher
 
 	if err := cm.loadFileConfig(); err != nil {
+		if cliOverrides.StrictConfig {
+			_ = cm.Close()
+			return nil, fmt.Errorf("failed to load config file: %w", err)
+		}
 		log.Warn("Failed to load initial config file, using defaults",
 			zap.String("path", configPath),
 			zap.Error(err))
@@ -142,7 +224,13 @@ func NewConfigManager(log *zap.Logger, buildOpts BuildOpts, cliOverrides *CLIOve
 // loadFileConfig loads configuration from file
 func (cm *ConfigManager) loadFileConfig() error {
 	if cm.configPath == "" {
-		return fmt.Errorf("no config file path specified")
+		return &errs.ConfigError{Key: "config-file", Err: errors.New("no config file path specified")}
+	}
+
+	if cm.cliOverrides.StrictConfig {
+		if err := CheckConfigKeys(cm.configPath); err != nil {
+			return err
+		}
 	}
 
 	var fileConfig FileConfig
@@ -158,10 +246,24 @@ func (cm *ConfigManager) loadFileConfig() error {
 
 // rebuildMergedConfig merges CLI overrides with file config
 func (cm *ConfigManager) rebuildMergedConfig() error {
+	cm.overridesMu.Lock()
+	defer cm.overridesMu.Unlock()
+	return cm.rebuild(cm.overrides)
+}
+
+// rebuild merges CLI overrides with file config and the runtime overrides; callers must
+// hold overridesMu
+func (cm *ConfigManager) rebuild(overrides map[string]string) error {
 	fileConfig := cm.fileConfig.Load()
 	if fileConfig == nil {
 		fileConfig = &FileConfig{}
 	}
+	if len(overrides) > 0 {
+		var err error
+		if fileConfig, err = cm.overriddenFileConfig(overrides); err != nil {
+			return err
+		}
+	}
 
 	opts := cm.mergeConfigs(fileConfig)
 
@@ -171,6 +273,7 @@ func (cm *ConfigManager) rebuildMergedConfig() error {
 	}
 
 	cm.mergedConfig.Store(&config)
+	cm.effectiveFileConfig.Store(fileConfig)
 	cm.log.Debug("Rebuilt merged configuration")
 	return nil
 }
@@ -184,24 +287,75 @@ func (cm *ConfigManager) mergeConfigs(fileConfig *FileConfig) configOpts {
 	}
 
 	opts.LogLevel = stringWithOverride("info", cm.cliOverrides.LogLevel)
+	opts.LogLevels = fileConfig.LogLevels
+	if len(cm.cliOverrides.LogLevels) > 0 {
+		opts.LogLevels = parseLogLevels(cm.cliOverrides.LogLevels)
+	}
 	opts.Environment = stringWithOverride(cm.buildOpts.BuildEnvironment, cm.cliOverrides.Environment)
 	opts.DataDir = stringWithOverride("./", cm.cliOverrides.DataDir)
 	opts.ConfigFile = stringWithOverride("./config.yaml", cm.cliOverrides.ConfigFile)
+	opts.ControlSocket = stringWithOverride("", cm.cliOverrides.ControlSocket)
 	opts.ServerPort = uint32WithOverride(4200, cm.cliOverrides.ServerPort)
 	opts.SlackEventsPath = stringWithOverride("/api/slack/events", cm.cliOverrides.SlackEventPath)
+	opts.SlackInteractionsPath = stringWithOverride("/api/slack/interactions", cm.cliOverrides.SlackInteractionsPath)
+	opts.SlackCommandsPath = stringWithOverride("/api/slack/commands", cm.cliOverrides.SlackCommandsPath)
+	opts.SlackBreakerThreshold = intWithFileAndOverride(nil, 5, cm.cliOverrides.SlackBreakerThreshold)
+	opts.SlackBreakerCooldown = durationWithFileAndOverride(nil, time.Minute, cm.cliOverrides.SlackBreakerCooldown)
+	opts.SlackRejoinChannels = boolWithFileAndOverride(nil, false, cm.cliOverrides.SlackRejoinChannels)
+	opts.SlackDMRespectDND = boolWithFileAndOverride(nil, true, cm.cliOverrides.SlackDMRespectDND)
+	opts.SlackDMUrgentKinds = cm.cliOverrides.SlackDMUrgentKinds
+	if opts.SlackDMUrgentKinds == nil {
+		opts.SlackDMUrgentKinds = []string{aichat.MemoryDMKind}
+	}
+	opts.SlackSignatureTolerance = durationWithFileAndOverride(nil, slack.DefaultSignatureTolerance, cm.cliOverrides.SlackSignatureTolerance)
+	opts.HTTPAuth = fileConfig.HTTPAuth
+	opts.HTTPAdminToken = stringWithOverride("", cm.cliOverrides.HTTPAdminToken)
+	opts.SlackTeamIDs = cm.cliOverrides.SlackTeamIDs
 
 	opts.SlackToken = stringWithOverride("", cm.cliOverrides.SlackToken)
 	opts.SlackSigningSecret = stringWithOverride("", cm.cliOverrides.SlackSigningSecret)
 	opts.PreferredUsers = cm.cliOverrides.PreferredUsers
 	opts.PreferredChannels = cm.cliOverrides.PreferredChannels
+	opts.TriggerAliases = fileConfig.TriggerAliases
+	if len(cm.cliOverrides.TriggerAliases) > 0 {
+		opts.TriggerAliases = cm.cliOverrides.TriggerAliases
+	}
+	opts.Shadow = fileConfig.Shadow
+	opts.MessageMetadata = fileConfig.MessageMetadata
+	opts.Blocklist = fileConfig.Blocklist
+	opts.MessageSubtypes = fileConfig.MessageSubtypes
+	if len(cm.cliOverrides.MessageSubtypes) > 0 {
+		opts.MessageSubtypes = cm.cliOverrides.MessageSubtypes
+	}
 	opts.UserNotifyChannel = stringWithOverride("", cm.cliOverrides.UserNotifyChannel)
 
 	opts.OpenAIAPIKey = stringWithOverride("", cm.cliOverrides.OpenAIAPIKey)
+	opts.OpenAIModel = stringWithOverride("", cm.cliOverrides.OpenAIModel)
+	opts.AIProviders = fileConfig.AI.Providers
+	opts.AIEndpoints = fileConfig.AI.Endpoints
+	opts.AIRoutes = fileConfig.AI.Routes
 
 	userConfig := fileConfig.User
 	if userConfig.NotifyChannel != nil && cm.cliOverrides.UserNotifyChannel == nil {
 		opts.UserNotifyChannel = *userConfig.NotifyChannel
 	}
+	opts.UserPoll = user.PollConfig{
+		Interval:      durationWithFileAndOverride(userConfig.PollInterval, time.Minute, nil),
+		Jitter:        0.1,
+		BurstInterval: durationWithFileAndOverride(userConfig.BurstPollInterval, 0, nil),
+		BurstDuration: durationWithFileAndOverride(userConfig.BurstDuration, 10*time.Minute, nil),
+		MaxInterval:   durationWithFileAndOverride(userConfig.MaxPollInterval, 0, nil),
+	}
+	if userConfig.PollJitter != nil {
+		opts.UserPoll.Jitter = *userConfig.PollJitter
+	}
+	opts.UserPersona = userConfig.Persona
+	opts.UserRetry = user.RetryConfig{
+		Backoff:     durationWithFileAndOverride(userConfig.Retry.Backoff, user.DefaultRetryBackoff, nil),
+		MaxBackoff:  durationWithFileAndOverride(userConfig.Retry.MaxBackoff, user.DefaultRetryMaxBackoff, nil),
+		MaxAttempts: intWithFileAndOverride(userConfig.Retry.MaxAttempts, user.DefaultRetryMaxAttempts, nil),
+		MaxQueued:   intWithFileAndOverride(userConfig.Retry.MaxQueued, user.DefaultRetryMaxQueued, nil),
+	}
 
 	aichatConfig := fileConfig.AIChat
 	opts.PersonasConfig = stringWithOverride(serializePersonas(aichatConfig.Personas), cm.cliOverrides.PersonasConfig)
@@ -213,15 +367,51 @@ func (cm *ConfigManager) mergeConfigs(fileConfig *FileConfig) configOpts {
 		aichatConfig.MaxContextAge, 24*time.Hour, cm.cliOverrides.MaxContextAge)
 	opts.AIChatMaxContextTokens = intWithFileAndOverride(
 		aichatConfig.MaxContextTokens, 2000, cm.cliOverrides.MaxContextTokens)
+	opts.AIChatMaxTrackedUsers = intWithFileAndOverride(
+		aichatConfig.MaxTrackedUsers, aichat.DefaultMaxTrackedUsers, nil)
 	opts.AIChatRateLimitEnabled = boolWithFileAndOverride(
 		aichatConfig.RateLimitEnabled, true, cm.cliOverrides.AIChatRateLimitEnabled)
+	opts.AIChatMessageRate = aichat.RateLimit{
+		Every: durationWithFileAndOverride(aichatConfig.MessageRate, aichat.DefaultMessageRate, nil),
+		Burst: intWithFileAndOverride(aichatConfig.MessageBurst, aichat.DefaultMessageBurst, nil),
+	}
+	opts.AIChatMentionRate = aichat.RateLimit{
+		Every: durationWithFileAndOverride(aichatConfig.MentionRate, 0, nil),
+		Burst: intWithFileAndOverride(aichatConfig.MentionBurst, aichat.DefaultMentionBurst, nil),
+	}
+	opts.AIChatQueueSize = intWithFileAndOverride(aichatConfig.QueueSize, aichat.DefaultQueueSize, nil)
+	opts.AIChatQueueMaxAge = durationWithFileAndOverride(
+		aichatConfig.QueueMaxAge, aichat.DefaultQueueMaxAge, nil)
+	opts.AIChatNameMentions = boolWithFileAndOverride(aichatConfig.NameMentions, true, nil)
+	opts.AIChatPersonaRules = aichatConfig.PersonaRules
+	opts.AIChatChannelPersonas = aichatConfig.ChannelPersonas
+	opts.AIChatEngagement = aichatConfig.Engagement
+	opts.AIChatChannelEngagement = aichatConfig.ChannelEngagement
+	opts.AIChatLanguage = aichatConfig.Language
+	opts.AIChatChannelLanguage = aichatConfig.ChannelLanguage
 
 	vibecheckConfig := fileConfig.Vibecheck
 	opts.VibecheckBanDuration = durationWithFileAndOverride(
 		vibecheckConfig.BanDuration, 5*time.Minute, cm.cliOverrides.VibecheckBanDuration)
+	opts.VibecheckJudgement = vibecheckConfig.Judgement
+	opts.VibecheckImmunity = vibecheckConfig.Immunity
+	opts.VibecheckOnDemand = vibecheckConfig.OnDemand
+	opts.VibecheckJail = vibecheckConfig.Jail
+	opts.VibecheckWelcomeBack = vibecheckConfig.WelcomeBack
+	opts.VibecheckPersona = vibecheckConfig.Persona
+	opts.VibecheckOnEdit = vibecheckConfig.OnEdit
+	if vibecheckConfig.ReplyMode != nil {
+		opts.VibecheckReplyMode = *vibecheckConfig.ReplyMode
+	}
 
 	chatConfig := fileConfig.Chat
 	opts.ChatResponses = chatConfig.Responses
+	opts.ChatReactionThresholds = chatConfig.ReactionThresholds
+	opts.ChatOnEdit = chatConfig.OnEdit
+	opts.ChatPersona = chatConfig.Persona
+	if chatConfig.Timezone != nil {
+		opts.ChatTimezone = *chatConfig.Timezone
+	}
 
 	showerthoughtConfig := fileConfig.ShowerThought
 	if showerthoughtConfig.Enabled != nil {
@@ -232,6 +422,68 @@ func (cm *ConfigManager) mergeConfigs(fileConfig *FileConfig) configOpts {
 	opts.ShowerthoughtBusinessHoursEnd = intWithFileAndOverride(
 		showerthoughtConfig.BusinessHoursEnd, 17, nil)
 
+	loopGuardConfig := fileConfig.LoopGuard
+	opts.LoopGuardEnabled = boolWithFileAndOverride(loopGuardConfig.Enabled, true, nil)
+	opts.LoopGuardBurstLimit = intWithFileAndOverride(loopGuardConfig.BurstLimit, 10, nil)
+	opts.LoopGuardBurstWindow = durationWithFileAndOverride(loopGuardConfig.BurstWindow, 30*time.Second, nil)
+	opts.LoopGuardRepeatLimit = intWithFileAndOverride(loopGuardConfig.RepeatLimit, 3, nil)
+	opts.LoopGuardRepeatWindow = durationWithFileAndOverride(loopGuardConfig.RepeatWindow, 2*time.Minute, nil)
+	opts.LoopGuardCooldown = durationWithFileAndOverride(loopGuardConfig.Cooldown, 10*time.Minute, nil)
+	if loopGuardConfig.AlertChannel != nil {
+		opts.LoopGuardAlertChannel = *loopGuardConfig.AlertChannel
+	}
+	opts.LoopGuardDenyApps = loopGuardConfig.DenyApps
+	opts.LoopGuardDenyBots = loopGuardConfig.DenyBots
+	opts.LoopGuardDenyUsers = loopGuardConfig.DenyUsers
+
+	incidentConfig := fileConfig.Incident
+	opts.IncidentDefaultDuration = durationWithFileAndOverride(incidentConfig.DefaultDuration, time.Hour, nil)
+	opts.IncidentMaxDuration = durationWithFileAndOverride(incidentConfig.MaxDuration, 24*time.Hour, nil)
+
+	opts.MembershipChannels = fileConfig.Membership.Channels
+	opts.MembershipPersona = fileConfig.Membership.Persona
+
+	backupConfig := fileConfig.Backup
+	opts.BackupEnabled = boolWithFileAndOverride(backupConfig.Enabled, true, nil)
+	opts.BackupInterval = durationWithFileAndOverride(backupConfig.Interval, 24*time.Hour, nil)
+	opts.BackupKeep = intWithFileAndOverride(backupConfig.Keep, 7, nil)
+	if backupConfig.Dir != nil {
+		opts.BackupDir = *backupConfig.Dir
+	}
+
+	announceConfig := fileConfig.Announce
+	opts.AnnounceEnabled = boolWithFileAndOverride(announceConfig.Enabled, false, nil)
+	if announceConfig.Channel != nil {
+		opts.AnnounceChannel = *announceConfig.Channel
+	}
+	opts.AnnouncePersona = announceConfig.Persona
+	opts.AnnounceFeatures = announceConfig.Features
+	opts.AnnounceBroadcasts = announceConfig.Broadcasts
+
+	topicConfig := fileConfig.Topic
+	opts.TopicChannels = topicConfig.Channels
+	opts.TopicInterval = durationWithFileAndOverride(topicConfig.Interval, topic.DefaultInterval, nil)
+	opts.TopicNotify = boolWithFileAndOverride(topicConfig.Notify, true, nil)
+	opts.TopicPersona = topicConfig.Persona
+
+	opts.FileScan = fileConfig.FileScan
+	opts.Feedback = fileConfig.Feedback
+	opts.Outbox = fileConfig.Outbox
+	opts.Handoff = fileConfig.Handoff
+	opts.Analytics = fileConfig.Analytics
+	opts.SelfTest = fileConfig.SelfTest
+	opts.Leader = fileConfig.Leader
+	opts.EventBus = fileConfig.EventBus
+	opts.Facts = fileConfig.Facts
+	opts.GRPC = fileConfig.GRPC
+	opts.LastSeen = fileConfig.LastSeen
+	opts.Retention = fileConfig.Retention
+	opts.Personas = fileConfig.Personas
+	opts.Claim = fileConfig.Claim
+	opts.Schedules = fileConfig.Schedules
+	opts.Explain = fileConfig.Explain
+	opts.Karma = fileConfig.Karma
+
 	return opts
 }
 
@@ -340,6 +592,14 @@ func (cm *ConfigManager) GetConfig() *Config {
 	return cm.mergedConfig.Load()
 }
 
+// GetFileConfig returns the config file as read, with runtime overrides applied
+func (cm *ConfigManager) GetFileConfig() *FileConfig {
+	if fileConfig := cm.effectiveFileConfig.Load(); fileConfig != nil {
+		return fileConfig
+	}
+	return &FileConfig{}
+}
+
 func (cm *ConfigManager) GetAIChatConfig() aichat.Config {
 	config := cm.GetConfig()
 	if config == nil {
@@ -404,6 +664,190 @@ func (cm *ConfigManager) GetShowerthoughtConfig() showerthought.Config {
 	return config.ShowerThought
 }
 
+func (cm *ConfigManager) GetLoopGuardConfig() loopguard.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return loopguard.Config{}
+	}
+	return config.LoopGuard
+}
+
+func (cm *ConfigManager) GetIncidentConfig() incident.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return incident.Config{}
+	}
+	return config.Incident
+}
+
+func (cm *ConfigManager) GetMembershipConfig() membership.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return membership.Config{}
+	}
+	return config.Membership
+}
+
+func (cm *ConfigManager) GetBackupConfig() backup.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return backup.Config{}
+	}
+	return config.Backup
+}
+
+func (cm *ConfigManager) GetAnnounceConfig() announce.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return announce.Config{}
+	}
+	return config.Announce
+}
+
+func (cm *ConfigManager) GetTopicConfig() topic.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return topic.Config{}
+	}
+	return config.Topic
+}
+
+func (cm *ConfigManager) GetFileScanConfig() filescan.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return filescan.Config{}
+	}
+	return config.FileScan
+}
+
+func (cm *ConfigManager) GetFeedbackConfig() feedback.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return feedback.Config{}
+	}
+	return config.Feedback
+}
+
+func (cm *ConfigManager) GetOutboxConfig() outbox.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return outbox.Config{}
+	}
+	return config.Outbox
+}
+
+func (cm *ConfigManager) GetHandoffConfig() handoff.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return handoff.Config{}
+	}
+	return config.Handoff
+}
+
+func (cm *ConfigManager) GetAnalyticsConfig() analytics.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return analytics.Config{}
+	}
+	return config.Analytics
+}
+
+func (cm *ConfigManager) GetSelfTestConfig() selftest.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return selftest.Config{}
+	}
+	return config.SelfTest
+}
+
+func (cm *ConfigManager) GetLeaderConfig() leader.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return leader.Config{}
+	}
+	return config.Leader
+}
+
+func (cm *ConfigManager) GetEventBusConfig() eventbus.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return eventbus.Config{}
+	}
+	return config.EventBus
+}
+
+func (cm *ConfigManager) GetFactsConfig() facts.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return facts.Config{}
+	}
+	return config.Facts
+}
+
+func (cm *ConfigManager) GetGRPCConfig() api.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return api.Config{}
+	}
+	return config.GRPC
+}
+
+func (cm *ConfigManager) GetLastSeenConfig() lastseen.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return lastseen.Config{}
+	}
+	return config.LastSeen
+}
+
+func (cm *ConfigManager) GetRetentionConfig() retention.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return retention.Config{}
+	}
+	return config.Retention
+}
+
+func (cm *ConfigManager) GetPersonasConfig() personastore.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return personastore.Config{}
+	}
+	return config.Personas
+}
+
+func (cm *ConfigManager) GetClaimConfig() claim.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return claim.Config{}
+	}
+	return config.Claim
+}
+
+func (cm *ConfigManager) GetSchedulerConfig() scheduler.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return scheduler.Config{}
+	}
+	return config.Scheduler
+}
+
+func (cm *ConfigManager) GetExplainConfig() explain.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return explain.Config{}
+	}
+	return config.Explain
+}
+
+func (cm *ConfigManager) GetKarmaConfig() karma.Config {
+	config := cm.GetConfig()
+	if config == nil {
+		return karma.Config{}
+	}
+	return config.Karma
+}
+
 func (cm *ConfigManager) Subscribe(callback func(*Config)) func() {
 	cm.subsMutex.Lock()
 	defer cm.subsMutex.Unlock()
@@ -441,6 +885,9 @@ func ExtractCLIOverrides(cmd *cli.Command) *CLIOverrides {
 		val := cmd.String("log-level")
 		overrides.LogLevel = &val
 	}
+	if cmd.IsSet("log-levels") {
+		overrides.LogLevels = cmd.StringSlice("log-levels")
+	}
 	if cmd.IsSet("env") {
 		val := cmd.String("env")
 		overrides.Environment = &val
@@ -453,6 +900,11 @@ func ExtractCLIOverrides(cmd *cli.Command) *CLIOverrides {
 		val := cmd.String("config-file")
 		overrides.ConfigFile = &val
 	}
+	overrides.StrictConfig = cmd.Bool("strict-config")
+	if cmd.IsSet("control-socket") {
+		val := cmd.String("control-socket")
+		overrides.ControlSocket = &val
+	}
 	if cmd.IsSet("server-port") {
 		port := cmd.Uint("server-port")
 		if port > 65535 { // Check for valid port range
@@ -465,6 +917,44 @@ func ExtractCLIOverrides(cmd *cli.Command) *CLIOverrides {
 		val := cmd.String("slack-events-path")
 		overrides.SlackEventPath = &val
 	}
+	if cmd.IsSet("slack-interactions-path") {
+		val := cmd.String("slack-interactions-path")
+		overrides.SlackInteractionsPath = &val
+	}
+	if cmd.IsSet("slack-commands-path") {
+		val := cmd.String("slack-commands-path")
+		overrides.SlackCommandsPath = &val
+	}
+	if cmd.IsSet("slack-breaker-threshold") {
+		val := cmd.Int("slack-breaker-threshold")
+		overrides.SlackBreakerThreshold = &val
+	}
+	if cmd.IsSet("slack-breaker-cooldown") {
+		val := cmd.Duration("slack-breaker-cooldown")
+		overrides.SlackBreakerCooldown = &val
+	}
+	if cmd.IsSet("slack-rejoin-channels") {
+		val := cmd.Bool("slack-rejoin-channels")
+		overrides.SlackRejoinChannels = &val
+	}
+	if cmd.IsSet("slack-dm-respect-dnd") {
+		val := cmd.Bool("slack-dm-respect-dnd")
+		overrides.SlackDMRespectDND = &val
+	}
+	if cmd.IsSet("slack-dm-urgent-kinds") {
+		overrides.SlackDMUrgentKinds = cmd.StringSlice("slack-dm-urgent-kinds")
+	}
+	if cmd.IsSet("http-admin-token") {
+		val := cmd.String("http-admin-token")
+		overrides.HTTPAdminToken = &val
+	}
+	if cmd.IsSet("slack-signature-tolerance") {
+		val := cmd.Duration("slack-signature-tolerance")
+		overrides.SlackSignatureTolerance = &val
+	}
+	if cmd.IsSet("slack-team-ids") {
+		overrides.SlackTeamIDs = cmd.StringSlice("slack-team-ids")
+	}
 	if cmd.IsSet("slack-token") || cmd.String("slack-token") != "" {
 		val := cmd.String("slack-token")
 		overrides.SlackToken = &val
@@ -479,6 +969,12 @@ func ExtractCLIOverrides(cmd *cli.Command) *CLIOverrides {
 	if cmd.IsSet("slack-preferred-channels") {
 		overrides.PreferredChannels = cmd.StringSlice("slack-preferred-channels")
 	}
+	if cmd.IsSet("trigger-aliases") {
+		overrides.TriggerAliases = cmd.StringSlice("trigger-aliases")
+	}
+	if cmd.IsSet("message-subtypes") {
+		overrides.MessageSubtypes = cmd.StringSlice("message-subtypes")
+	}
 	if cmd.IsSet("slack-user-notify-channel") {
 		val := cmd.String("slack-user-notify-channel")
 		overrides.UserNotifyChannel = &val
@@ -487,6 +983,10 @@ func ExtractCLIOverrides(cmd *cli.Command) *CLIOverrides {
 		val := cmd.String("openai-api-key")
 		overrides.OpenAIAPIKey = &val
 	}
+	if cmd.IsSet("openai-model") {
+		val := cmd.String("openai-model")
+		overrides.OpenAIModel = &val
+	}
 	if cmd.IsSet("personas-config") {
 		val := cmd.String("personas-config")
 		overrides.PersonasConfig = &val
@@ -571,15 +1071,15 @@ func boolWithFileAndOverride(fileValue *bool, defaultValue bool, override *bool)
 	return defaultValue
 }
 
-func serializePersonas(personas map[string]string) string {
+func serializePersonas(personas map[string]aichat.Persona) string {
 	if len(personas) == 0 {
 		return ""
 	}
-	// Convert map to YAML string for compatibility with existing parsing
-	// This is a simple implementation - in practice you might want proper YAML marshaling
-	result := ""
-	for name, prompt := range personas {
-		result += fmt.Sprintf("%s: |\n  %s\n", name, prompt)
+	// Marshal to YAML so multi-line prompts and persona overrides survive the round trip
+	// through configOpts.PersonasConfig
+	data, err := yaml.Marshal(personas)
+	if err != nil {
+		return ""
 	}
-	return result
+	return string(data)
 }
@@ -3,10 +3,14 @@ package config
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v3"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/vibecheck"
 )
 
 func TestExtractCLIOverrides_EnvironmentVariables(t *testing.T) {
@@ -185,3 +189,38 @@ func TestExtractCLIOverrides_EmptyEnvironment(t *testing.T) {
 		t.Error("SlackSigningSecret should be nil when environment variable is empty")
 	}
 }
+
+func TestNewConfigManager_StrictConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("vibecheck:\n  ban_durration: 5m\n"), 0600))
+
+	// Unknown keys are ignored unless strict mode is on
+	cm, err := NewConfigManager(zap.NewNop(), BuildOpts{}, &CLIOverrides{}, path)
+	require.NoError(t, err)
+	require.NoError(t, cm.Close())
+
+	_, err = NewConfigManager(zap.NewNop(), BuildOpts{}, &CLIOverrides{StrictConfig: true}, path)
+	require.ErrorContains(t, err, "vibecheck.ban_durration (did you mean ban_duration?)")
+}
+
+func TestConfigManager_Overrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("vibecheck:\n  ban_duration: 5m\n  reply_mode: thread\n"), 0600))
+	cm, err := NewConfigManager(zap.NewNop(), BuildOpts{}, &CLIOverrides{}, path)
+	require.NoError(t, err)
+	defer func() { _ = cm.Close() }()
+
+	require.NoError(t, cm.SetOverride("vibecheck.ban_duration", "2h"))
+	require.Equal(t, 2*time.Hour, cm.GetVibecheckConfig().BanDuration)
+	require.Equal(t, vibecheck.ReplyModeThread, cm.GetVibecheckConfig().ReplyMode, "keys that aren't overridden keep the file's value")
+	require.Equal(t, map[string]string{"vibecheck.ban_duration": "2h"}, cm.Overrides())
+
+	// Unknown keys and values that leave the config invalid are rejected
+	require.ErrorContains(t, cm.SetOverride("vibecheck.ban_durration", "1h"), "did you mean ban_duration?")
+	require.Error(t, cm.SetOverride("facts.max_per_channel", "-1"))
+	require.Equal(t, map[string]string{"vibecheck.ban_duration": "2h"}, cm.Overrides())
+
+	require.NoError(t, cm.ClearOverride("vibecheck.ban_duration"))
+	require.Equal(t, 5*time.Minute, cm.GetVibecheckConfig().BanDuration)
+	require.Error(t, cm.ClearOverride("vibecheck.ban_duration"))
+}
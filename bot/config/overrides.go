@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+)
+
+// Overrides returns the config file keys overridden at runtime, with their YAML values
+func (cm *ConfigManager) Overrides() map[string]string {
+	cm.overridesMu.Lock()
+	defer cm.overridesMu.Unlock()
+	return maps.Clone(cm.overrides)
+}
+
+// SetOverride sets a config file key, e.g. vibecheck.ban_duration, to a YAML value as if
+// it were in the file, until the bot restarts. Edits to the file keep the override. An
+// override that leaves the config invalid is rejected and nothing changes.
+func (cm *ConfigManager) SetOverride(key, value string) error {
+	if err := checkOverrideKey(key); err != nil {
+		return err
+	}
+	var v any
+	if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+		return &errs.ConfigError{Key: key, Err: fmt.Errorf("parse value: %w", err)}
+	}
+
+	cm.overridesMu.Lock()
+	overrides := maps.Clone(cm.overrides)
+	if overrides == nil {
+		overrides = make(map[string]string)
+	}
+	overrides[key] = value
+	if err := cm.rebuild(overrides); err != nil {
+		cm.overridesMu.Unlock()
+		return err
+	}
+	cm.overrides = overrides
+	cm.overridesMu.Unlock()
+
+	cm.log.Info("Config key overridden", zap.String("key", key), zap.String("value", value))
+	cm.notifySubscribers(cm.mergedConfig.Load())
+	return nil
+}
+
+// ClearOverride returns a key to its value in the config file
+func (cm *ConfigManager) ClearOverride(key string) error {
+	cm.overridesMu.Lock()
+	if _, ok := cm.overrides[key]; !ok {
+		cm.overridesMu.Unlock()
+		return &errs.ConfigError{Key: key, Err: errors.New("not overridden")}
+	}
+	overrides := maps.Clone(cm.overrides)
+	delete(overrides, key)
+	if err := cm.rebuild(overrides); err != nil {
+		cm.overridesMu.Unlock()
+		return err
+	}
+	cm.overrides = overrides
+	cm.overridesMu.Unlock()
+
+	cm.log.Info("Config override cleared", zap.String("key", key))
+	cm.notifySubscribers(cm.mergedConfig.Load())
+	return nil
+}
+
+// checkOverrideKey returns an error unless the key is in FileConfig. Keys only flags read
+// aren't accepted, since flags are read once at startup.
+func checkOverrideKey(key string) error {
+	t := reflect.TypeFor[FileConfig]()
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		path := strings.Join(parts[:i+1], ".")
+		switch {
+		case part == "":
+			return &errs.ConfigError{Key: key, Err: errors.New("empty key")}
+		case t.Kind() == reflect.Struct:
+			known := fields(t, "yaml")
+			j := slices.IndexFunc(known, func(f field) bool { return f.key == part })
+			if j < 0 {
+				names := make([]string, 0, len(known))
+				for _, f := range known {
+					names = append(names, f.key)
+				}
+				msg := "unknown key"
+				if suggestion := closest(part, names); suggestion != "" {
+					msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+				}
+				return &errs.ConfigError{Key: path, Err: errors.New(msg)}
+			}
+			t = known[j].typ
+		case t.Kind() == reflect.Map:
+			t = t.Elem()
+		default:
+			return &errs.ConfigError{Key: path, Err: errors.New("not a section")}
+		}
+	}
+	return nil
+}
+
+// overriddenFileConfig reads the config file with the overrides set in it
+func (cm *ConfigManager) overriddenFileConfig(overrides map[string]string) (*FileConfig, error) {
+	doc := make(map[string]any)
+	content, err := os.ReadFile(cm.configPath) // #nosec G304 -- configPath is controlled by configuration
+	switch {
+	case cm.configPath == "" || os.IsNotExist(err):
+	case err != nil:
+		return nil, &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("read %s: %w", cm.configPath, err)}
+	case filepath.Ext(cm.configPath) == ".json":
+		err = json.Unmarshal(content, &doc)
+	default:
+		err = yaml.Unmarshal(content, &doc)
+	}
+	if err != nil {
+		return nil, &errs.ConfigError{Key: "config-file", Err: err}
+	}
+	if doc == nil {
+		// An empty file
+		doc = make(map[string]any)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(overrides)) {
+		var v any
+		if err := yaml.Unmarshal([]byte(overrides[key]), &v); err != nil {
+			return nil, &errs.ConfigError{Key: key, Err: fmt.Errorf("parse value: %w", err)}
+		}
+		section := doc
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := section[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				section[part] = child
+			}
+			section = child
+		}
+		section[parts[len(parts)-1]] = v
+	}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("marshal overrides: %w", err)}
+	}
+	var fileConfig FileConfig
+	if err := yaml.Unmarshal(data, &fileConfig); err != nil {
+		return nil, &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("unmarshal overrides: %w", err)}
+	}
+	return &fileConfig, nil
+}
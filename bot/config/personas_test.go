@@ -2,6 +2,8 @@ package config
 
 import (
 	"testing"
+
+	"slackbot.arpa/bot/aichat"
 )
 
 func TestPersonasConfigParsing(t *testing.T) {
@@ -26,8 +28,8 @@ computer: "Self-aware AI"
 			hasError: false,
 		},
 		{
-			name:     "Valid JSON",
-			input:    `{"glazer": "Gen-Z hype beast", "argue": "Argumentative lawyer"}`,
+			name:  "Valid JSON",
+			input: `{"glazer": "Gen-Z hype beast", "argue": "Argumentative lawyer"}`,
 			expected: map[string]string{
 				"glazer": "Gen-Z hype beast",
 				"argue":  "Argumentative lawyer",
@@ -92,4 +94,71 @@ func TestDefaultPersonaHandling(t *testing.T) {
 	if len(config.AIChat.Personas) != 0 {
 		t.Errorf("Expected empty personas map, got %d personas", len(config.AIChat.Personas))
 	}
-}
\ No newline at end of file
+}
+
+func TestPersonaOverridesParsing(t *testing.T) {
+	input := `
+glazer: "Gen-Z hype beast"
+computer:
+  prompt: "Self-aware AI"
+  model: gpt-4o-mini
+  max_temperature: 0.7
+  max_tokens: 40
+`
+	config, err := newConfig(configOpts{PersonasConfig: input})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if config.AIChat.Personas["computer"] != "Self-aware AI" {
+		t.Errorf("Expected computer prompt to be parsed, got %q", config.AIChat.Personas["computer"])
+	}
+	if _, ok := config.AIChat.PersonaOverrides["glazer"]; ok {
+		t.Error("Expected no overrides for a plain prompt persona")
+	}
+
+	overrides := config.AIChat.PersonaOverrides["computer"]
+	if overrides.Model != "gpt-4o-mini" || overrides.MaxTemperature != 0.7 || overrides.MaxTokens != 40 {
+		t.Errorf("Unexpected computer overrides: %+v", overrides)
+	}
+}
+
+func TestSerializePersonas_RoundTrip(t *testing.T) {
+	maxTokens := 80
+	personas := map[string]aichat.Persona{
+		"multiline": {Prompt: "First line.\nSecond line.\n"},
+		"tuned":     {Prompt: "Tuned persona", Model: "gpt-4o", MaxTokens: &maxTokens},
+	}
+
+	config, err := newConfig(configOpts{PersonasConfig: serializePersonas(personas)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if config.AIChat.Personas["multiline"] != "First line.\nSecond line.\n" {
+		t.Errorf("Expected multi-line prompt to survive serialization, got %q", config.AIChat.Personas["multiline"])
+	}
+	if got := config.AIChat.PersonaOverrides["tuned"]; got.Model != "gpt-4o" || got.MaxTokens != 80 {
+		t.Errorf("Expected tuned overrides to survive serialization, got %+v", got)
+	}
+}
+
+func TestPersonaWeightsParsing(t *testing.T) {
+	input := `
+glazer: "Gen-Z hype beast"
+unhinged:
+  prompt: "Conspiracy theorist"
+  weight: 0
+`
+	config, err := newConfig(configOpts{PersonasConfig: input})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, ok := config.AIChat.PersonaWeights["glazer"]; ok {
+		t.Error("Expected no explicit weight for glazer")
+	}
+	if weight, ok := config.AIChat.PersonaWeights["unhinged"]; !ok || weight != 0 {
+		t.Errorf("Expected unhinged weight 0, got %v (set: %v)", weight, ok)
+	}
+}
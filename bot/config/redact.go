@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// redacted replaces credentials in Redacted
+const redacted = "[redacted]"
+
+// secretKeys are the parts of key names whose string values are credentials
+var secretKeys = []string{"token", "secret", "password", "apikey", "api_key"}
+
+// Redacted returns the config as JSON values with credentials replaced, for showing
+// operators the effective config
+func (c *Config) Redacted() (map[string]any, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+	redact(values)
+	return values, nil
+}
+
+func redact(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if s, ok := value.(string); ok && s != "" && isSecretKey(key) {
+				v[key] = redacted
+				continue
+			}
+			redact(value)
+		}
+	case []any:
+		for _, value := range v {
+			redact(value)
+		}
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range secretKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/urfave/cli/v3"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/retention"
+)
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	periodType   = reflect.TypeFor[retention.Period]()
+)
+
+// field is a config file key and the type its value decodes into
+type field struct {
+	key string
+	typ reflect.Type
+}
+
+// fields returns the keys of a struct in the config file, in declaration order
+func fields(t reflect.Type, tag string) []field {
+	var keys []field
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		keys = append(keys, field{key: name, typ: f.Type})
+	}
+	return keys
+}
+
+// flagKeys returns the keys directly under path that flags read from the config file
+// without a FileConfig field, e.g. slack_team_ids or ai.model
+func flagKeys(path string, known []field) []string {
+	var keys []string
+	for key := range fileFlagKeys {
+		parent, name := "", key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			parent, name = key[:i], key[i+1:]
+		}
+		if parent == path && !slices.ContainsFunc(known, func(f field) bool { return f.key == name }) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unknownKeys returns the keys in a decoded config file that nothing reads, each with a
+// suggestion when it looks like a typo of a known key
+func unknownKeys(value any, t reflect.Type, tag, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[string]any)
+		if !ok {
+			// Scalars are left to the decoder, e.g. a persona given as just a prompt
+			return nil
+		}
+		known := fields(t, tag)
+		extra := flagKeys(path, known)
+		var unknown []string
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			i := slices.IndexFunc(known, func(f field) bool { return f.key == key })
+			if i >= 0 {
+				unknown = append(unknown, unknownKeys(m[key], known[i].typ, tag, joinKey(path, key))...)
+				continue
+			}
+			if slices.Contains(extra, key) {
+				continue
+			}
+			names := slices.Clone(extra)
+			for _, f := range known {
+				names = append(names, f.key)
+			}
+			msg := joinKey(path, key)
+			if suggestion := closest(key, names); suggestion != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+			unknown = append(unknown, msg)
+		}
+		return unknown
+	case reflect.Map:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		var unknown []string
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			unknown = append(unknown, unknownKeys(m[key], t.Elem(), tag, joinKey(path, key))...)
+		}
+		return unknown
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]any)
+		if !ok {
+			return nil
+		}
+		var unknown []string
+		for i, item := range items {
+			unknown = append(unknown, unknownKeys(item, t.Elem(), tag, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return unknown
+	}
+	return nil
+}
+
+// closest returns the name within two edits of key, if any
+func closest(key string, names []string) string {
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if d := editDistance(key, name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// CheckConfigKeys returns an error listing the keys in a config file that nothing reads,
+// so a typo like ban_durration fails instead of silently leaving the default in place
+func CheckConfigKeys(filePath string) error {
+	content, err := os.ReadFile(filePath) // #nosec G304 -- filePath is controlled by configuration
+	if err != nil {
+		return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("read %s: %w", filePath, err)}
+	}
+
+	var doc any
+	tag := "yaml"
+	switch filepath.Ext(filePath) {
+	case ".json":
+		tag = "json"
+		err = json.Unmarshal(content, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &doc)
+	default:
+		return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("unsupported config file format: %s", filepath.Ext(filePath))}
+	}
+	if err != nil {
+		return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("unmarshal %s: %w", tag, err)}
+	}
+
+	if unknown := unknownKeys(doc, reflect.TypeFor[FileConfig](), tag, ""); len(unknown) > 0 {
+		return &errs.ConfigError{Key: "config-file", Err: fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))}
+	}
+	return nil
+}
+
+// WriteDefaultConfig prints a config.yaml with every key the bot reads commented out,
+// generated from the FileConfig struct tags. Values are placeholders for the expected
+// type; keys that are also flags note the flag's usage and environment variable.
+func WriteDefaultConfig(w io.Writer) error {
+	flags := make(map[string]cli.Flag)
+	for _, f := range Flags() {
+		flags[f.Names()[0]] = f
+	}
+	b := &strings.Builder{}
+	b.WriteString("# Default slackbot config. Every key is optional and unset keys use the bot's defaults.\n")
+	b.WriteString("# Placeholders like <duration> show the expected type, e.g. 90s or 24h for durations.\n")
+	writeStruct(b, reflect.TypeFor[FileConfig](), "", 0, flags)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeStruct(b *strings.Builder, t reflect.Type, path string, depth int, flags map[string]cli.Flag) {
+	known := fields(t, "yaml")
+	for _, f := range known {
+		writeKey(b, f.key, f.typ, joinKey(path, f.key), depth, flags)
+	}
+	for _, key := range flagKeys(path, known) {
+		writeKey(b, key, flagType(flags[fileFlagKeys[joinKey(path, key)]]), joinKey(path, key), depth, flags)
+	}
+}
+
+func writeKey(b *strings.Builder, key string, t reflect.Type, path string, depth int, flags map[string]cli.Flag) {
+	indent := "# " + strings.Repeat("  ", depth)
+	comment := ""
+	if f, ok := flags[fileFlagKeys[path]]; ok {
+		comment = " # " + flagUsage(f)
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if placeholder, ok := scalar(t); ok {
+		fmt.Fprintf(b, "%s%s: %s%s\n", indent, key, placeholder, comment)
+		return
+	}
+	fmt.Fprintf(b, "%s%s:%s\n", indent, key, comment)
+	writeValue(b, t, path, depth+1, flags)
+}
+
+// writeValue prints the nested lines of a struct, map or list
+func writeValue(b *strings.Builder, t reflect.Type, path string, depth int, flags map[string]cli.Flag) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	indent := "# " + strings.Repeat("  ", depth)
+	switch t.Kind() {
+	case reflect.Struct:
+		writeStruct(b, t, path, depth, flags)
+	case reflect.Map:
+		writeKey(b, "<"+t.Key().Kind().String()+">", t.Elem(), path+".*", depth, flags)
+	case reflect.Slice, reflect.Array:
+		elem := t.Elem()
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		if placeholder, ok := scalar(elem); ok {
+			fmt.Fprintf(b, "%s- %s\n", indent, placeholder)
+			return
+		}
+		if elem.Kind() != reflect.Struct {
+			fmt.Fprintf(b, "%s-\n", indent)
+			writeValue(b, elem, path+"[]", depth+1, flags)
+			return
+		}
+		// The first key of a list item goes on the dash's line
+		var item strings.Builder
+		writeStruct(&item, elem, path+"[]", depth+1, flags)
+		lines := strings.SplitAfter(item.String(), "\n")
+		if len(lines) > 0 {
+			lines[0] = indent + "- " + strings.TrimPrefix(lines[0], "# "+strings.Repeat("  ", depth+1))
+		}
+		b.WriteString(strings.Join(lines, ""))
+	}
+}
+
+// scalar returns the placeholder for a type written on one line
+func scalar(t reflect.Type) (string, bool) {
+	if t == durationType || t == periodType {
+		return "<duration>", true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "<string>", true
+	case reflect.Bool:
+		return "<bool>", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "<int>", true
+	case reflect.Float32, reflect.Float64:
+		return "<number>", true
+	case reflect.Interface:
+		return "<any>", true
+	}
+	return "", false
+}
+
+// flagType returns the type a flag's config file key takes
+func flagType(f cli.Flag) reflect.Type {
+	switch f.(type) {
+	case *cli.StringSliceFlag:
+		return reflect.TypeFor[[]string]()
+	case *cli.BoolFlag:
+		return reflect.TypeFor[bool]()
+	case *cli.IntFlag:
+		return reflect.TypeFor[int]()
+	case *cli.DurationFlag:
+		return durationType
+	}
+	return reflect.TypeFor[string]()
+}
+
+func flagUsage(f cli.Flag) string {
+	usage := ""
+	if u, ok := f.(interface{ GetUsage() string }); ok {
+		usage = u.GetUsage()
+	}
+	if e, ok := f.(interface{ GetEnvVars() []string }); ok && len(e.GetEnvVars()) > 0 {
+		usage = strings.TrimSpace(fmt.Sprintf("%s (%s)", usage, strings.Join(e.GetEnvVars(), ", ")))
+	}
+	return usage
+}
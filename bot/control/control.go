@@ -0,0 +1,177 @@
+// Package control serves CLI commands from a running bot over a Unix socket, so commands
+// reuse the bot's Slack connection and config instead of setting up their own.
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const runPath = "/run"
+
+// Request is a CLI invocation forwarded to the running bot
+type Request struct {
+	Args   []string `json:"args"`   // Command and its flags, e.g. ["send-message", "-m", "hi"]
+	Output string   `json:"output"` // Value of the global --output flag
+}
+
+// Response is what the command printed and the error it returned, if any
+type Response struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RunFunc runs a command in the bot's process
+type RunFunc func(ctx context.Context, req Request, stdout, stderr io.Writer) error
+
+// Server listens on a Unix socket that only the bot's user can connect to
+type Server struct {
+	log    *zap.Logger
+	path   string
+	run    RunFunc
+	mu     sync.Mutex
+	server *http.Server
+}
+
+func NewServer(log *zap.Logger, path string, run RunFunc) *Server {
+	return &Server{log: log, path: path, run: run}
+}
+
+// Start listens on the socket. A socket left behind by a bot that didn't shut down
+// cleanly is replaced, but one another bot is serving isn't.
+func (s *Server) Start(ctx context.Context) error {
+	if Available(ctx, s.path) {
+		return fmt.Errorf("control socket %s is served by another process", s.path)
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale control socket: %w", err)
+	}
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("listen on control socket: %w", err)
+	}
+	if err := os.Chmod(s.path, 0600); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("restrict control socket: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+runPath, s.handleRun)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Control socket stopped", zap.Error(err))
+		}
+	}()
+	s.log.Info("Serving CLI commands on control socket", zap.String("path", s.path))
+	return nil
+}
+
+// Stop waits for running commands and removes the socket
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	server := s.server
+	s.server = nil
+	s.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	err := server.Shutdown(ctx)
+	if rmErr := os.Remove(s.path); rmErr != nil && !os.IsNotExist(rmErr) {
+		err = errors.Join(err, rmErr)
+	}
+	return err
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	s.log.Info("Running command from control socket", zap.Strings("args", req.Args))
+
+	var stdout, stderr bytes.Buffer
+	resp := Response{}
+	if err := s.run(r.Context(), req, &stdout, &stderr); err != nil {
+		resp.Error = err.Error()
+	}
+	resp.Stdout, resp.Stderr = stdout.String(), stderr.String()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.log.Error("Failed to write control response", zap.Error(err))
+	}
+}
+
+// Client sends commands to a running bot
+type Client struct {
+	http *http.Client
+}
+
+func NewClient(path string) *Client {
+	return &Client{http: &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}}
+}
+
+// Available reports whether a bot is serving the socket
+func Available(ctx context.Context, path string) bool {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// Run runs a command in the bot and returns its output
+func (c *Client) Run(ctx context.Context, req Request) (Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	// The host is ignored, requests always go to the socket
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://control"+runPath, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("send command to running bot: %w", err)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+	if httpResp.StatusCode != http.StatusOK {
+		return Response{}, fmt.Errorf("running bot rejected command: %s", httpResp.Status)
+	}
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("decode response from running bot: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,70 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestServer_Run(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	// A socket left behind by a bot that crashed is replaced
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if Available(ctx, path) {
+		t.Fatal("Stale socket should not be available")
+	}
+
+	var got Request
+	s := NewServer(zaptest.NewLogger(t), path, func(ctx context.Context, req Request, stdout, stderr io.Writer) error {
+		got = req
+		_, _ = fmt.Fprintln(stdout, "sent")
+		_, _ = fmt.Fprintln(stderr, "note")
+		return errors.New("one channel failed")
+	})
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Stop(ctx) })
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Socket should only be accessible to its owner, got %v (%v)", info.Mode(), err)
+	}
+	if !Available(ctx, path) {
+		t.Fatal("Socket should be available once started")
+	}
+	if err := NewServer(zaptest.NewLogger(t), path, nil).Start(ctx); err == nil {
+		t.Error("A second server should not take over a socket in use")
+	}
+
+	req := Request{Args: []string{"send-message", "-m", "hi"}, Output: "json"}
+	resp, err := NewClient(path).Run(ctx, req)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !slices.Equal(got.Args, req.Args) || got.Output != "json" {
+		t.Errorf("Server got %+v, want %+v", got, req)
+	}
+	want := Response{Stdout: "sent\n", Stderr: "note\n", Error: "one channel failed"}
+	if resp != want {
+		t.Errorf("Run() = %+v, want %+v", resp, want)
+	}
+
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Socket should be removed on stop, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package conversation tells public channels, private channels and direct messages apart
+// so features use the API calls and scopes each kind supports
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/errs"
+)
+
+// Kind is the type of a Slack conversation
+type Kind string
+
+const (
+	Unknown Kind = "conversation"
+	Public  Kind = "public channel"
+	Private Kind = "private channel"
+	GroupDM Kind = "group DM"
+	DM      Kind = "DM"
+)
+
+// KindFromID guesses the kind from the ID prefix. Private channels created since 2021
+// have "C" IDs like public ones, so prefer Lookup when it matters.
+func KindFromID(id string) Kind {
+	switch {
+	case strings.HasPrefix(id, "C"):
+		return Public
+	case strings.HasPrefix(id, "G"):
+		return Private
+	case strings.HasPrefix(id, "D"):
+		return DM
+	}
+	return Unknown
+}
+
+// KindFromEventType reads the channel_type of an event: "channel", "group", "mpim" or
+// "im" on messages, and "C" or "G" on member_joined_channel
+func KindFromEventType(channelType, id string) Kind {
+	switch channelType {
+	case "channel", "C":
+		return Public
+	case "group", "G":
+		return Private
+	case "mpim":
+		return GroupDM
+	case "im":
+		return DM
+	}
+	return KindFromID(id)
+}
+
+// KindOf reads the kind from conversation info
+func KindOf(c *slack.Channel) Kind {
+	switch {
+	case c.IsIM:
+		return DM
+	case c.IsMpIM:
+		return GroupDM
+	case c.IsPrivate || c.IsGroup:
+		return Private
+	}
+	return Public
+}
+
+// ValidID reports whether id looks like a conversation ID
+func ValidID(id string) bool {
+	return len(id) >= 9 && KindFromID(id) != Unknown
+}
+
+// Lookup fetches a conversation's info. On failure the kind is guessed from the ID and
+// the error explains which scope or invite is missing.
+func Lookup(ctx context.Context, client *slack.Client, id string) (Kind, *slack.Channel, error) {
+	c, err := client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: id})
+	if err != nil {
+		kind := KindFromID(id)
+		return kind, nil, Error("conversations.info", kind, err)
+	}
+	return KindOf(c), c, nil
+}
+
+// CanJoin reports whether the bot can add itself with conversations.join
+func (k Kind) CanJoin() bool {
+	return k == Public
+}
+
+// CanKick reports whether members can be removed and invited back
+func (k Kind) CanKick() bool {
+	return k == Public || k == Private
+}
+
+// scopes lists the scope each kind needs for an API method
+var scopes = map[string]map[Kind]string{
+	"conversations.info":    {Public: "channels:read", Private: "groups:read", GroupDM: "mpim:read", DM: "im:read"},
+	"conversations.history": {Public: "channels:history", Private: "groups:history", GroupDM: "mpim:history", DM: "im:history"},
+	"conversations.join":    {Public: "channels:join"},
+	"conversations.kick":    {Public: "channels:manage", Private: "groups:write"},
+	"conversations.invite":  {Public: "channels:manage", Private: "groups:write"},
+	"conversations.members": {Public: "channels:read", Private: "groups:read", GroupDM: "mpim:read", DM: "im:read"},
+	"chat.postMessage":      {Public: "chat:write", Private: "chat:write", GroupDM: "chat:write", DM: "chat:write"},
+}
+
+// Scope returns the scope needed to call op in this kind of conversation, if known
+func (k Kind) Scope(op string) string {
+	return scopes[op][k]
+}
+
+// Error wraps a failed API call in a conversation with a hint on how to fix common
+// failures, such as a missing scope or the bot not being a member of a private channel
+func Error(op string, kind Kind, err error) error {
+	apiErr := errs.NewSlackAPIError(op, err)
+	switch apiErr.Code {
+	case "missing_scope":
+		if scope := kind.Scope(op); scope != "" {
+			return fmt.Errorf("%w: add the %s scope to use a %s", apiErr, scope, kind)
+		}
+	case "channel_not_found", "not_in_channel":
+		if kind != Public {
+			return fmt.Errorf("%w: invite the bot to the %s", apiErr, kind)
+		}
+	case "method_not_supported_for_channel_type", "cant_kick_from_general":
+		return fmt.Errorf("%w: %s isn't supported in a %s", apiErr, op, kind)
+	}
+	return apiErr
+}
+
+// Kick removes a user from a conversation. Failures are wrapped with Error.
+func Kick(ctx context.Context, client *slack.Client, kind Kind, channelID, userID string) error {
+	if err := client.KickUserFromConversationContext(ctx, channelID, userID); err != nil {
+		return Error("conversations.kick", kind, err)
+	}
+	return nil
+}
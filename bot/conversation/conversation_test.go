@@ -0,0 +1,151 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Kind
+		want Kind
+	}{
+		{"public ID", KindFromID("C0123456789"), Public},
+		{"private ID", KindFromID("G0123456789"), Private},
+		{"DM ID", KindFromID("D0123456789"), DM},
+		{"unknown ID", KindFromID("U0123456789"), Unknown},
+		{"message in group", KindFromEventType("group", "C0123456789"), Private},
+		{"message in mpim", KindFromEventType("mpim", "G0123456789"), GroupDM},
+		{"member joined private", KindFromEventType("G", "C0123456789"), Private},
+		{"no event type", KindFromEventType("", "D0123456789"), DM},
+		{"private info", KindOf(&slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{IsPrivate: true}}}), Private},
+		{"mpim info", KindOf(&slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{IsMpIM: true, IsPrivate: true}}}), GroupDM},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if !Public.CanJoin() || Private.CanJoin() {
+		t.Error("Only public channels can be joined")
+	}
+	if !Private.CanKick() || GroupDM.CanKick() || DM.CanKick() {
+		t.Error("Only channels support kicking")
+	}
+	if ValidID("C123") || !ValidID("G0123456789") {
+		t.Error("ValidID should check prefix and length")
+	}
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name string
+		op   string
+		kind Kind
+		code string
+		want string
+	}{
+		{"missing scope", "conversations.kick", Private, "missing_scope", "add the groups:write scope"},
+		{"not a member", "chat.postMessage", Private, "channel_not_found", "invite the bot to the private channel"},
+		{"unsupported", "conversations.kick", GroupDM, "method_not_supported_for_channel_type", "isn't supported in a group DM"},
+		{"other", "conversations.kick", Public, "user_not_found", "user_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Error(tt.op, tt.kind, slack.SlackErrorResponse{Err: tt.code})
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Error() = %q, want it to contain %q", err, tt.want)
+			}
+			var apiErr *errs.SlackAPIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.code {
+				t.Errorf("Error should wrap a SlackAPIError with code %q", tt.code)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("channel") == "G0123456789" {
+			_, _ = w.Write([]byte(`{"ok": false, "error": "missing_scope"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "channel": {"id": "C0123456789", "is_private": true, "is_member": true}}`))
+	}))
+	defer srv.Close()
+	client := slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+
+	kind, info, err := Lookup(context.Background(), client, "C0123456789")
+	if err != nil || kind != Private || !info.IsMember {
+		t.Errorf("Lookup() = %q, %+v, %v; want a private channel", kind, info, err)
+	}
+
+	kind, _, err = Lookup(context.Background(), client, "G0123456789")
+	if kind != Private || err == nil || !strings.Contains(err.Error(), "groups:read") {
+		t.Errorf("Lookup() = %q, %v; want the groups:read scope hint", kind, err)
+	}
+}
+
+func callback(data any) event.Event {
+	return event.Callback(data)
+}
+
+func TestPresence(t *testing.T) {
+	ctx := context.Background()
+	var joins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		joins++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok": true, "channel": {"id": "C0123456789"}}`))
+	}))
+	defer srv.Close()
+	s := slacktest.New(srv.URL)
+
+	p := NewPresence(zaptest.NewLogger(t), s, false)
+	p.PushEvent(callback(&slackevents.MemberLeftChannelEvent{Channel: "C1", User: "U1"}))
+	p.PushEvent(callback(&slackevents.ChannelLeftEvent{Channel: "C2"}))
+	p.PushEvent(callback(&slackevents.MemberLeftChannelEvent{Channel: "G3", User: "UBOT"}))
+	p.Failed("C4", "not_in_channel")
+	p.Failed("C5", "ratelimited")
+
+	for channel, want := range map[string]bool{"C1": true, "C2": false, "G3": false, "C4": false, "C5": true} {
+		if got := p.Present(ctx, channel); got != want {
+			t.Errorf("Present(%s) = %v, want %v", channel, got, want)
+		}
+	}
+
+	p.PushEvent(callback(&slackevents.MemberJoinedChannelEvent{Channel: "C2", User: "UBOT"}))
+	if !p.Present(ctx, "C2") {
+		t.Error("The bot should be present after it's added back")
+	}
+	if joins != 0 {
+		t.Errorf("Channels should not be rejoined unless enabled, joined %d", joins)
+	}
+
+	// With rejoin enabled, public channels are joined again, at most every rejoinInterval
+	p.rejoin = true
+	if !p.Present(ctx, "C0123456789") {
+		t.Error("Unknown channels should be present")
+	}
+	p.Failed("C0123456789", "not_in_channel")
+	if !p.Present(ctx, "C0123456789") || joins != 1 {
+		t.Errorf("The bot should rejoin a public channel, joined %d times", joins)
+	}
+	if p.Present(ctx, "G3") || joins != 1 {
+		t.Error("The bot can't rejoin a private channel on its own")
+	}
+}
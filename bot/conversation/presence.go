@@ -0,0 +1,152 @@
+package conversation
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+)
+
+// rejoinInterval is how long to wait between attempts to rejoin a channel
+const rejoinInterval = 10 * time.Minute
+
+// removedCodes are API errors meaning the bot can no longer post in a conversation
+var removedCodes = []string{"not_in_channel", "channel_not_found", "is_archived"}
+
+type slackService interface {
+	Client() *slack.Client
+	BotUserID() string
+}
+
+type removal struct {
+	reason      string
+	lastAttempt time.Time // last rejoin attempt
+}
+
+// Presence remembers conversations the bot was removed from, learned from leave events
+// or from API calls failing with not_in_channel, so features stop posting there instead
+// of failing on every attempt. With rejoin set, public channels are rejoined the next
+// time a feature wants to post.
+type Presence struct {
+	log     *zap.Logger
+	slack   slackService
+	rejoin  bool
+	now     func() time.Time
+	mu      sync.Mutex
+	removed map[string]removal // channel ID -> why the bot is gone
+}
+
+func NewPresence(log *zap.Logger, s slackService, rejoin bool) *Presence {
+	return &Presence{
+		log:     log,
+		slack:   s,
+		rejoin:  rejoin,
+		now:     time.Now,
+		removed: make(map[string]removal),
+	}
+}
+
+// Present reports whether the bot can post in a conversation. A channel the bot was
+// removed from is rejoined first if rejoining is enabled and the bot can join it.
+func (p *Presence) Present(ctx context.Context, channelID string) bool {
+	if p == nil {
+		return true
+	}
+	p.mu.Lock()
+	r, removed := p.removed[channelID]
+	now := p.now()
+	attempt := removed && p.rejoin && KindFromID(channelID).CanJoin() && now.Sub(r.lastAttempt) >= rejoinInterval
+	if attempt {
+		r.lastAttempt = now
+		p.removed[channelID] = r
+	}
+	p.mu.Unlock()
+
+	if !removed {
+		return true
+	}
+	if !attempt {
+		return false
+	}
+	if _, _, _, err := p.slack.Client().JoinConversationContext(ctx, channelID); err != nil {
+		p.log.Warn("Failed to rejoin channel the bot was removed from",
+			zap.String("channel", channelID),
+			zap.Error(Error("conversations.join", Public, err)))
+		return false
+	}
+	p.log.Info("Rejoined channel the bot was removed from", zap.String("channel", channelID))
+	p.joined(channelID)
+	return true
+}
+
+// Failed records an API error from a call in a conversation. Errors meaning the bot is
+// no longer in the conversation mark it as removed.
+func (p *Presence) Failed(channelID, code string) {
+	if p == nil || channelID == "" || !slices.Contains(removedCodes, code) {
+		return
+	}
+	p.markRemoved(channelID, code)
+}
+
+// Removed returns the conversations the bot was removed from and the event or error
+// that showed it
+func (p *Presence) Removed() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	channels := make(map[string]string, len(p.removed))
+	for id, r := range p.removed {
+		channels[id] = r.reason
+	}
+	return channels
+}
+
+// ProcessorType returns a description of the processor type
+func (p *Presence) ProcessorType() string {
+	return "presence"
+}
+
+// PushEvent tracks the bot leaving, being removed from, or joining conversations. Events
+// only update the cache, so they're handled inline.
+func (p *Presence) PushEvent(e event.Event) {
+	switch e.Data().(type) {
+	case *slackevents.ChannelLeftEvent, *slackevents.GroupLeftEvent,
+		*slackevents.ChannelArchiveEvent, *slackevents.GroupArchiveEvent:
+		p.markRemoved(e.Channel, e.Type)
+	case *slackevents.MemberLeftChannelEvent:
+		if e.User == p.slack.BotUserID() {
+			p.markRemoved(e.Channel, e.Type)
+		}
+	case *slackevents.MemberJoinedChannelEvent:
+		if e.User == p.slack.BotUserID() {
+			p.joined(e.Channel)
+		}
+	case *slackevents.ChannelUnarchiveEvent, *slackevents.GroupUnarchiveEvent:
+		p.joined(e.Channel)
+	}
+}
+
+func (p *Presence) markRemoved(channelID, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.removed[channelID]; ok {
+		return
+	}
+	p.removed[channelID] = removal{reason: reason}
+	p.log.Warn("Bot is no longer in channel, features will skip it",
+		zap.String("channel", channelID),
+		zap.String("reason", reason))
+}
+
+func (p *Presence) joined(channelID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.removed[channelID]; ok {
+		delete(p.removed, channelID)
+		p.log.Info("Bot is back in channel", zap.String("channel", channelID))
+	}
+}
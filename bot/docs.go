@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"slackbot.arpa/bot/config"
+)
+
+func newDocsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "docs",
+		Usage: "Generate documentation from the command tree",
+		Commands: []*cli.Command{
+			{
+				Name:  "man",
+				Usage: "Print a man page, e.g. slackbot docs man > slackbot.1",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					writeManPage(cmd.Root().Writer, cmd.Root())
+					return nil
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Print every config file key with placeholder values, e.g. slackbot docs config > config.yaml",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return config.WriteDefaultConfig(cmd.Root().Writer)
+				},
+			},
+		},
+	}
+}
+
+// configureCompletion lists the completion command in help and prints scripts to the
+// root writer. The library's fish script is malformed, so fish gets static completions
+// generated from the command tree instead.
+func configureCompletion(c *cli.Command) {
+	c.Hidden = false
+	action := c.Action
+	c.Action = func(ctx context.Context, cmd *cli.Command) error {
+		cmd.Writer = cmd.Root().Writer
+		if cmd.Args().First() != "fish" {
+			return action(ctx, cmd)
+		}
+		script, err := cmd.Root().ToFishCompletion()
+		if err != nil {
+			return fmt.Errorf("generate fish completion: %w", err)
+		}
+		_, err = fmt.Fprint(cmd.Writer, script)
+		return err
+	}
+}
+
+// writeManPage prints the command tree as a roff man page in section 1
+func writeManPage(w io.Writer, root *cli.Command) {
+	name := root.Name
+	_, _ = fmt.Fprintf(w, ".TH %s 1 \"\" %q %q\n", strings.ToUpper(name), root.Version, name+" manual")
+	_, _ = fmt.Fprintf(w, ".SH NAME\n%s \\- %s\n", name, roffEscape(root.Usage))
+	_, _ = fmt.Fprintf(w, ".SH SYNOPSIS\n.B %s\n[global options] [command [command options]]\n", name)
+	if flags := visibleFlags(root.Flags); len(flags) > 0 {
+		_, _ = fmt.Fprintln(w, ".SH GLOBAL OPTIONS")
+		writeManFlags(w, flags)
+	}
+	_, _ = fmt.Fprintln(w, ".SH COMMANDS")
+	for _, c := range root.Commands {
+		writeManCommand(w, c, "")
+	}
+}
+
+// writeManCommand prints a command and its subcommands, named by their path below the root
+func writeManCommand(w io.Writer, c *cli.Command, parent string) {
+	// Every command gets a help subcommand and flag, which the page doesn't repeat
+	if c.Hidden || c.Name == "help" {
+		return
+	}
+	path := strings.TrimSpace(parent + " " + c.Name)
+	_, _ = fmt.Fprintf(w, ".SS %s\n%s\n", roffEscape(path), roffEscape(c.Usage))
+	if len(c.Aliases) > 0 {
+		_, _ = fmt.Fprintf(w, ".PP\nAliases: %s\n", roffEscape(strings.Join(c.Aliases, ", ")))
+	}
+	if flags := visibleFlags(c.Flags); len(flags) > 0 {
+		writeManFlags(w, flags)
+	}
+	for _, sub := range c.Commands {
+		writeManCommand(w, sub, path)
+	}
+}
+
+func writeManFlags(w io.Writer, flags []cli.Flag) {
+	for _, f := range flags {
+		names := make([]string, 0, len(f.Names()))
+		for _, n := range f.Names() {
+			prefix := "--"
+			if len(n) == 1 {
+				prefix = "-"
+			}
+			names = append(names, roffEscape(prefix+n))
+		}
+		_, _ = fmt.Fprintf(w, ".TP\n\\fB%s\\fR", strings.Join(names, ", "))
+
+		doc, ok := f.(cli.DocGenerationFlag)
+		if !ok {
+			_, _ = fmt.Fprintln(w)
+			continue
+		}
+		if doc.TakesValue() {
+			_, _ = fmt.Fprint(w, " \\fIvalue\\fR")
+		}
+		_, _ = fmt.Fprintln(w)
+
+		usage := doc.GetUsage()
+		def := doc.GetDefaultText()
+		if def == "" && doc.TakesValue() && doc.IsDefaultVisible() {
+			def = doc.GetValue()
+		}
+		if def != "" && def != `""` {
+			usage += fmt.Sprintf(" (default: %s)", def)
+		}
+		if r, ok := f.(cli.RequiredFlag); ok && r.IsRequired() {
+			usage += " (required)"
+		}
+		if env := doc.GetEnvVars(); len(env) > 0 {
+			usage += " [$" + strings.Join(env, ", $") + "]"
+		}
+		_, _ = fmt.Fprintln(w, roffEscape(usage))
+	}
+}
+
+func visibleFlags(flags []cli.Flag) []cli.Flag {
+	var visible []cli.Flag
+	for _, f := range flags {
+		if v, ok := f.(cli.VisibleFlag); ok && !v.IsVisible() || f.Names()[0] == "help" {
+			continue
+		}
+		visible = append(visible, f)
+	}
+	return visible
+}
+
+// roffEscape keeps text from being read as roff requests or escapes
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = `\&` + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,189 @@
+// Package errs defines error categories shared across features so callers can tell
+// retryable failures from fatal ones and misconfiguration
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/slack-go/slack"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// Exit codes follow sysexits.h so scripts can react to the failure category
+const (
+	ExitFailure     = 1
+	ExitUnavailable = 69 // EX_UNAVAILABLE: Slack or LLM service failure
+	ExitIOError     = 74 // EX_IOERR: storage failure
+	ExitConfig      = 78 // EX_CONFIG: misconfiguration
+)
+
+// Error categories reported by Category
+const (
+	CategoryConfig   = "config"
+	CategorySlackAPI = "slack_api"
+	CategoryStorage  = "storage"
+	CategoryLLM      = "llm"
+	CategoryUnknown  = "unknown"
+)
+
+// ConfigError reports invalid or missing configuration. It is never retryable.
+type ConfigError struct {
+	Key string // Config key or flag name, e.g. "slack-token" or "aichat.personas"
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("config %s: %v", e.Key, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// SlackAPIError reports a failed Slack API call. Code is the Slack error string (such as
+// "channel_not_found"), "ratelimited", or the HTTP status for server errors.
+type SlackAPIError struct {
+	Op   string // Slack API method, e.g. "chat.postMessage"
+	Code string
+	Err  error
+}
+
+// NewSlackAPIError wraps err and extracts the Slack error code
+func NewSlackAPIError(op string, err error) *SlackAPIError {
+	e := &SlackAPIError{Op: op, Err: err}
+
+	var rateLimited *slack.RateLimitedError
+	var statusErr slack.StatusCodeError
+	var responseErr slack.SlackErrorResponse
+	switch {
+	case errors.As(err, &rateLimited):
+		e.Code = "ratelimited"
+	case errors.As(err, &statusErr):
+		e.Code = strconv.Itoa(statusErr.Code)
+	case errors.As(err, &responseErr):
+		e.Code = responseErr.Err
+	}
+	return e
+}
+
+func (e *SlackAPIError) Error() string {
+	return fmt.Sprintf("slack %s: %v", e.Op, e.Err)
+}
+
+func (e *SlackAPIError) Unwrap() error { return e.Err }
+
+// StorageError reports a failure reading or writing local data
+type StorageError struct {
+	Op   string // e.g. "open", "write", "query"
+	Path string
+	Err  error
+}
+
+func (e *StorageError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("storage %s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("storage %s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *StorageError) Unwrap() error { return e.Err }
+
+// LLMError reports a failed language model call. Code is the provider-neutral error
+// code, e.g. "rate_limit" or "authentication".
+type LLMError struct {
+	Op   string
+	Code llms.ErrorCode
+	Err  error
+}
+
+// NewLLMError wraps err and classifies it with the OpenAI error mapper
+func NewLLMError(op string, err error) *LLMError {
+	e := &LLMError{Op: op, Err: err, Code: llms.ErrCodeUnknown}
+
+	var llmErr *llms.Error
+	if errors.As(err, &llmErr) || errors.As(openai.MapError(err), &llmErr) {
+		e.Code = llmErr.Code
+	}
+	return e
+}
+
+func (e *LLMError) Error() string {
+	return fmt.Sprintf("llm %s: %v", e.Op, e.Err)
+}
+
+func (e *LLMError) Unwrap() error { return e.Err }
+
+// Category returns the category of the first categorized error in err's chain
+func Category(err error) string {
+	var configErr *ConfigError
+	var slackErr *SlackAPIError
+	var storageErr *StorageError
+	var llmErr *LLMError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &configErr):
+		return CategoryConfig
+	case errors.As(err, &slackErr):
+		return CategorySlackAPI
+	case errors.As(err, &storageErr):
+		return CategoryStorage
+	case errors.As(err, &llmErr):
+		return CategoryLLM
+	}
+	return CategoryUnknown
+}
+
+// Retryable reports whether retrying the operation may succeed
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		return false
+	}
+
+	var slackErr *SlackAPIError
+	if errors.As(err, &slackErr) {
+		if slackErr.Code == "ratelimited" {
+			return true
+		}
+		if status, convErr := strconv.Atoi(slackErr.Code); convErr == nil {
+			return status >= 500
+		}
+		// No code means the request never got an answer from Slack, e.g. a network error
+		return slackErr.Code == "" && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		switch llmErr.Code {
+		case llms.ErrCodeRateLimit, llms.ErrCodeTimeout, llms.ErrCodeProviderUnavailable:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// ExitCode maps an error to a process exit code
+func ExitCode(err error) int {
+	switch Category(err) {
+	case "":
+		return 0
+	case CategoryConfig:
+		return ExitConfig
+	case CategorySlackAPI, CategoryLLM:
+		return ExitUnavailable
+	case CategoryStorage:
+		return ExitIOError
+	}
+	return ExitFailure
+}
@@ -0,0 +1,84 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestNewSlackAPIError_Code(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"rate limited", &slack.RateLimitedError{}, "ratelimited"},
+		{"server error", slack.StatusCodeError{Code: 503, Status: "Service Unavailable"}, "503"},
+		{"api error", slack.SlackErrorResponse{Err: "channel_not_found"}, "channel_not_found"},
+		{"network error", errors.New("connection reset"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewSlackAPIError("chat.postMessage", tt.err).Code; got != tt.want {
+				t.Errorf("Code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategoryAndExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		category string
+		exit     int
+	}{
+		{"nil", nil, "", 0},
+		{"config", &ConfigError{Key: "slack-token", Err: errors.New("missing")}, CategoryConfig, ExitConfig},
+		{"wrapped slack", fmt.Errorf("setup: %w", NewSlackAPIError("auth.test", errors.New("boom"))), CategorySlackAPI, ExitUnavailable},
+		{"storage", &StorageError{Op: "write", Path: "/tmp/x", Err: errors.New("disk full")}, CategoryStorage, ExitIOError},
+		{"llm", NewLLMError("generate", errors.New("boom")), CategoryLLM, ExitUnavailable},
+		{"plain", errors.New("boom"), CategoryUnknown, ExitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Category(tt.err); got != tt.category {
+				t.Errorf("Category = %q, want %q", got, tt.category)
+			}
+			if got := ExitCode(tt.err); got != tt.exit {
+				t.Errorf("ExitCode = %d, want %d", got, tt.exit)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"config", &ConfigError{Key: "k", Err: errors.New("bad")}, false},
+		{"slack rate limited", NewSlackAPIError("op", &slack.RateLimitedError{}), true},
+		{"slack 5xx", NewSlackAPIError("op", slack.StatusCodeError{Code: 502}), true},
+		{"slack 4xx", NewSlackAPIError("op", slack.StatusCodeError{Code: 404}), false},
+		{"slack api error", NewSlackAPIError("op", slack.SlackErrorResponse{Err: "not_in_channel"}), false},
+		{"slack network error", NewSlackAPIError("op", errors.New("connection reset")), true},
+		{"slack deadline", NewSlackAPIError("op", context.DeadlineExceeded), false},
+		{"llm rate limit", &LLMError{Code: llms.ErrCodeRateLimit, Err: errors.New("429")}, true},
+		{"llm auth", &LLMError{Code: llms.ErrCodeAuthentication, Err: errors.New("401")}, false},
+		{"canceled", NewSlackAPIError("op", context.Canceled), false},
+		{"plain", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Retryable(tt.err); got != tt.want {
+				t.Errorf("Retryable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package event normalizes Slack Events API callbacks once when they're received, so
+// processors don't each pull the user, channel and text out of the typed inner event
+package event
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Event is a Slack event with the fields most processors need. Fields an event type
+// doesn't have are empty, and the typed event is still available from Data.
+type Event struct {
+	ID          string // Event ID, the same across Slack's retries of one delivery
+	Type        string // Inner event type, e.g. message, app_mention or reaction_added
+	User        string // Author, reactor or member who joined or left
+	BotID       string // Set for messages from bots
+	SubType     string // Message subtype, e.g. thread_broadcast
+	Channel     string
+	ChannelType string // channel, group, im or mpim, when Slack includes it
+	Text        string
+	TS          string // Message timestamp, or the reacted-to message for reactions
+	ThreadTS    string
+	Team        string
+	Raw         slackevents.EventsAPIEvent
+}
+
+// New normalizes an event received from Slack
+func New(raw slackevents.EventsAPIEvent) Event {
+	e := Event{Type: raw.InnerEvent.Type, Team: raw.TeamID, Raw: raw}
+	if raw.Type != slackevents.CallbackEvent {
+		return e
+	}
+	if cb, ok := raw.Data.(*slackevents.EventsAPICallbackEvent); ok {
+		e.ID = cb.EventID
+	}
+	switch ev := raw.InnerEvent.Data.(type) {
+	case *slackevents.MessageEvent:
+		e.User, e.BotID, e.SubType = ev.User, ev.BotID, ev.SubType
+		e.Channel, e.ChannelType = ev.Channel, ev.ChannelType
+		e.Text, e.TS, e.ThreadTS = ev.Text, ev.TimeStamp, ev.ThreadTimeStamp
+	case *slackevents.AppMentionEvent:
+		e.User, e.BotID, e.Channel = ev.User, ev.BotID, ev.Channel
+		e.Text, e.TS, e.ThreadTS = ev.Text, ev.TimeStamp, ev.ThreadTimeStamp
+	case *slackevents.ReactionAddedEvent:
+		e.User, e.Channel, e.TS = ev.User, ev.Item.Channel, ev.Item.Timestamp
+	case *slackevents.ReactionRemovedEvent:
+		e.User, e.Channel, e.TS = ev.User, ev.Item.Channel, ev.Item.Timestamp
+	case *slackevents.MemberJoinedChannelEvent:
+		e.User, e.Channel, e.ChannelType = ev.User, ev.Channel, ev.ChannelType
+	case *slackevents.MemberLeftChannelEvent:
+		e.User, e.Channel, e.ChannelType = ev.User, ev.Channel, ev.ChannelType
+	case *slackevents.FileSharedEvent:
+		e.User, e.Channel = ev.UserID, ev.ChannelID
+	case *slackevents.ChannelLeftEvent:
+		e.Channel = ev.Channel
+	case *slackevents.GroupLeftEvent:
+		e.Channel = ev.Channel
+	case *slackevents.ChannelArchiveEvent:
+		e.User, e.Channel = ev.User, ev.Channel
+	case *slackevents.GroupArchiveEvent:
+		e.Channel = ev.Channel
+	case *slackevents.ChannelUnarchiveEvent:
+		e.User, e.Channel = ev.User, ev.Channel
+	case *slackevents.GroupUnarchiveEvent:
+		e.Channel = ev.Channel
+	}
+	return e
+}
+
+// Callback wraps a typed inner event like Slack sends it, e.g. for tests or events
+// replayed from history
+func Callback(data any) Event {
+	raw := slackevents.EventsAPIEvent{
+		Type:       slackevents.CallbackEvent,
+		InnerEvent: slackevents.EventsAPIInnerEvent{Data: data},
+	}
+	t := reflect.TypeOf(data)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for eventType, v := range slackevents.EventsAPIInnerEventMapping {
+		if reflect.TypeOf(v) == t {
+			raw.InnerEvent.Type = string(eventType)
+			break
+		}
+	}
+	return New(raw)
+}
+
+// Data returns the typed inner event, e.g. *slackevents.MessageEvent, or nil for events
+// that aren't callbacks
+func (e Event) Data() any {
+	if e.Raw.Type != slackevents.CallbackEvent {
+		return nil
+	}
+	return e.Raw.InnerEvent.Data
+}
+
+// FromBot reports whether a bot, or no user at all, caused the event
+func (e Event) FromBot() bool {
+	return e.BotID != "" || e.User == ""
+}
+
+type correlationKey struct{}
+
+// Context returns ctx carrying the event's ID, so what a feature posts while handling the
+// event can be traced back to it
+func (e Event) Context(ctx context.Context) context.Context {
+	if e.ID == "" {
+		return ctx
+	}
+	return WithCorrelationID(ctx, e.ID)
+}
+
+// WithCorrelationID returns ctx carrying the ID of what caused the work done with it
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the ID ctx carries, or empty when it carries none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
@@ -0,0 +1,79 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		data any
+		want Event
+	}{
+		{
+			"message",
+			&slackevents.MessageEvent{User: "U1", Channel: "C1", ChannelType: "channel", Text: "hi", TimeStamp: "2.0", ThreadTimeStamp: "1.0", SubType: "thread_broadcast"},
+			Event{Type: "message", User: "U1", SubType: "thread_broadcast", Channel: "C1", ChannelType: "channel", Text: "hi", TS: "2.0", ThreadTS: "1.0"},
+		},
+		{
+			"mention from bot",
+			&slackevents.AppMentionEvent{BotID: "B1", Channel: "C1", Text: "<@UBOT> hi", TimeStamp: "2.0"},
+			Event{Type: "app_mention", BotID: "B1", Channel: "C1", Text: "<@UBOT> hi", TS: "2.0"},
+		},
+		{
+			"reaction",
+			&slackevents.ReactionAddedEvent{User: "U1", Item: slackevents.Item{Channel: "C1", Timestamp: "2.0"}},
+			Event{Type: "reaction_added", User: "U1", Channel: "C1", TS: "2.0"},
+		},
+		{
+			"member joined",
+			&slackevents.MemberJoinedChannelEvent{User: "U1", Channel: "G1", ChannelType: "G"},
+			Event{Type: "member_joined_channel", User: "U1", Channel: "G1", ChannelType: "G"},
+		},
+		{
+			"file shared",
+			&slackevents.FileSharedEvent{UserID: "U1", ChannelID: "C1", FileID: "F1"},
+			Event{Type: "file_shared", User: "U1", Channel: "C1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Callback(tt.data)
+			if got.Data() != tt.data {
+				t.Errorf("Data() = %v, want the typed event", got.Data())
+			}
+			got.Raw = slackevents.EventsAPIEvent{}
+			if got != tt.want {
+				t.Errorf("New() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if e := Callback(&slackevents.AppMentionEvent{BotID: "B1"}); !e.FromBot() {
+		t.Error("FromBot() should be true for bot messages")
+	}
+	raw := slackevents.EventsAPIEvent{
+		Type:       slackevents.CallbackEvent,
+		Data:       &slackevents.EventsAPICallbackEvent{EventID: "Ev1"},
+		InnerEvent: slackevents.EventsAPIInnerEvent{Type: "message", Data: &slackevents.MessageEvent{}},
+	}
+	if e := New(raw); e.ID != "Ev1" {
+		t.Errorf("New() ID = %q, want the callback's event ID", e.ID)
+	}
+	if e := New(slackevents.EventsAPIEvent{Type: slackevents.URLVerification}); e.Data() != nil {
+		t.Errorf("Data() = %v, want nil for events that aren't callbacks", e.Data())
+	}
+}
+
+func TestEvent_Context(t *testing.T) {
+	ctx := context.Background()
+	if id := CorrelationID(Event{}.Context(ctx)); id != "" {
+		t.Errorf("CorrelationID() = %q for an event without an ID, want none", id)
+	}
+	if id := CorrelationID(Event{ID: "Ev1"}.Context(ctx)); id != "Ev1" {
+		t.Errorf("CorrelationID() = %q, want the event ID", id)
+	}
+}
@@ -0,0 +1,349 @@
+// Package eventbus lets the HTTP receiver and the feature processors run as separate
+// processes for large workspaces. Receivers verify each Slack event and add it to a Redis
+// stream instead of dispatching it, and workers read the stream as a consumer group, so
+// each event reaches the processors of exactly one worker. Without it, events go from the
+// receiver to the processors in the same process over channels.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	BackendRedis = "redis"
+
+	// RoleReceiver only publishes the events it receives
+	RoleReceiver = "receiver"
+	// RoleWorker also dispatches events from the stream to its processors
+	RoleWorker = "worker"
+
+	DefaultStream = "slackbot:events"
+	DefaultGroup  = "slackbot"
+	DefaultMaxLen = 10000
+
+	// eventField is the stream field holding an event's body
+	eventField     = "event"
+	readCount      = 10
+	readBlock      = 5 * time.Second
+	commandTimeout = 5 * time.Second
+	retryDelay     = 2 * time.Second
+	// claimIdle is how long an event can go unacknowledged before another worker takes it,
+	// e.g. from a worker that stopped and came back under a new name
+	claimIdle     = time.Minute
+	claimInterval = 30 * time.Second
+)
+
+// Backends are the supported buses
+var Backends = []string{BackendRedis}
+
+// Roles are what a process does with the bus
+var Roles = []string{RoleReceiver, RoleWorker}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Backend is the bus, only redis for now
+	Backend string `json:"backend" yaml:"backend"`
+	// Address is the server's host:port
+	Address  string `json:"address" yaml:"address"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// Role is receiver, which only publishes the events it receives, or worker, which
+	// also dispatches events from the stream to its processors, defaults to worker
+	Role string `json:"role" yaml:"role"`
+	// Stream is the stream events are added to, defaults to slackbot:events
+	Stream *string `json:"stream" yaml:"stream"`
+	// Group is the consumer group workers share, defaults to slackbot
+	Group *string `json:"group" yaml:"group"`
+	// Consumer names this worker in the group, defaults to the hostname. Events a worker
+	// read but didn't acknowledge are read again when it restarts under the same name,
+	// and taken by another worker after a minute.
+	Consumer *string `json:"consumer" yaml:"consumer"`
+	// MaxLen caps the stream at about this many events, defaults to 10000
+	MaxLen *int `json:"max_len" yaml:"max_len"`
+}
+
+type Config struct {
+	Enabled  bool
+	Backend  string
+	Address  string
+	Username string
+	Password string
+	Role     string
+	Stream   string
+	Group    string
+	Consumer string
+	MaxLen   int
+}
+
+// DefaultConsumer names a worker by its hostname, which stays the same across restarts
+func DefaultConsumer() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "slackbot"
+	}
+	return host
+}
+
+// dispatcher passes events read from the stream to this process's processors
+type dispatcher interface {
+	DispatchEvent(body []byte) error
+}
+
+// Bus publishes events to the stream and, in workers, dispatches the events read from it
+type Bus struct {
+	log         *zap.Logger
+	config      Config
+	dispatcher  dispatcher
+	client      *redis.Client
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	status      *status.Tracker
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func New(log *zap.Logger, c Config, d dispatcher) *Bus {
+	if c.Stream == "" {
+		c.Stream = DefaultStream
+	}
+	if c.Group == "" {
+		c.Group = DefaultGroup
+	}
+	if c.Consumer == "" {
+		c.Consumer = DefaultConsumer()
+	}
+	if c.MaxLen <= 0 {
+		c.MaxLen = DefaultMaxLen
+	}
+	return &Bus{
+		log:        log,
+		config:     c,
+		dispatcher: d,
+		client:     newClient(c),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// SetStatusTracker sets where published and dispatched events and bus failures are reported
+func (b *Bus) SetStatusTracker(t *status.Tracker) {
+	b.status = t
+}
+
+// Start reads the stream in workers; receivers only publish
+func (b *Bus) Start(ctx context.Context) error {
+	b.isConnected.Store(true)
+	if b.config.Role != RoleReceiver {
+		go b.consume(ctx)
+	}
+	b.log.Debug("Event bus started",
+		zap.String("role", b.config.Role),
+		zap.String("stream", b.config.Stream),
+		zap.String("consumer", b.config.Consumer))
+	return nil
+}
+
+func (b *Bus) Stop(ctx context.Context) error {
+	if !b.isConnected.Load() {
+		return nil
+	}
+	close(b.stopCh)
+	b.isConnected.Store(false)
+	// Closing the client also ends a blocked read
+	return b.client.Close()
+}
+
+// Publish adds a verified event's body to the stream
+func (b *Bus) Publish(ctx context.Context, body []byte) error {
+	err := b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: b.config.Stream,
+		MaxLen: int64(b.config.MaxLen),
+		Approx: true,
+		Values: []string{eventField, string(body)},
+	}).Err()
+	if err != nil {
+		err = fmt.Errorf("publish event: %w", err)
+		b.setErr(err)
+		return err
+	}
+	b.setErr(nil)
+	b.status.Event()
+	return nil
+}
+
+// HealthCheck reports the last failure to reach the bus, if it hasn't recovered since
+func (b *Bus) HealthCheck() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr
+}
+
+// consume reads the stream until the bus stops, starting over when reading fails
+func (b *Bus) consume(ctx context.Context) {
+	for {
+		err := b.readGroup(ctx)
+		select {
+		case <-b.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+		b.setErr(err)
+		b.log.Warn("Lost the event bus, reconnecting", zap.Error(err), zap.Duration("delay", retryDelay))
+		select {
+		case <-b.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// readGroup reads the stream as the consumer until reading fails. It first dispatches the
+// events this consumer read but didn't acknowledge before it stopped, and regularly takes
+// over the events other consumers left unacknowledged for too long.
+func (b *Bus) readGroup(ctx context.Context) error {
+	err := b.client.XGroupCreateMkStream(ctx, b.config.Stream, b.config.Group, "$").Err()
+	if err != nil && !isBusyGroup(err) {
+		return fmt.Errorf("create consumer group: %w", err)
+	}
+	b.setErr(nil)
+	if err := b.readPending(ctx); err != nil {
+		return err
+	}
+
+	var lastClaim time.Time
+	for {
+		if time.Since(lastClaim) >= claimInterval {
+			if err := b.claimIdle(ctx); err != nil {
+				return err
+			}
+			lastClaim = time.Now()
+		}
+		streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    b.config.Group,
+			Consumer: b.config.Consumer,
+			Streams:  []string{b.config.Stream, ">"},
+			Count:    readCount,
+			Block:    readBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue // Nothing new before the block timed out
+		}
+		if err != nil {
+			return fmt.Errorf("read events: %w", err)
+		}
+		for _, stream := range streams {
+			if err := b.handle(ctx, stream.Messages); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readPending dispatches the events delivered to this consumer that it didn't acknowledge
+func (b *Bus) readPending(ctx context.Context) error {
+	for {
+		streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    b.config.Group,
+			Consumer: b.config.Consumer,
+			Streams:  []string{b.config.Stream, "0"},
+			Count:    readCount,
+			Block:    -1,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read pending events: %w", err)
+		}
+		var messages []redis.XMessage
+		for _, stream := range streams {
+			messages = append(messages, stream.Messages...)
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		if err := b.handle(ctx, messages); err != nil {
+			return err
+		}
+	}
+}
+
+// claimIdle takes over and dispatches the events other consumers read but haven't
+// acknowledged for claimIdle, e.g. because their worker stopped for good
+func (b *Bus) claimIdle(ctx context.Context) error {
+	start := "0-0"
+	for {
+		messages, next, err := b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   b.config.Stream,
+			Group:    b.config.Group,
+			Consumer: b.config.Consumer,
+			MinIdle:  claimIdle,
+			Start:    start,
+			Count:    readCount,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("claim idle events: %w", err)
+		}
+		if len(messages) > 0 {
+			b.log.Info("Claimed events another worker didn't acknowledge", zap.Int("count", len(messages)))
+		}
+		if err := b.handle(ctx, messages); err != nil {
+			return err
+		}
+		if next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// handle dispatches and acknowledges events read from the stream
+func (b *Bus) handle(ctx context.Context, messages []redis.XMessage) error {
+	for _, m := range messages {
+		b.dispatch(m)
+		// Acknowledged even when dispatch fails, since a body that can't be parsed won't
+		// parse next time either
+		if err := b.client.XAck(ctx, b.config.Stream, b.config.Group, m.ID).Err(); err != nil {
+			return fmt.Errorf("acknowledge event %s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+func (b *Bus) dispatch(m redis.XMessage) {
+	body := messageBody(m)
+	if len(body) == 0 {
+		b.log.Warn("Event was trimmed from the stream before it was read", zap.String("id", m.ID))
+		return
+	}
+	if err := b.dispatcher.DispatchEvent(body); err != nil {
+		b.status.Error(err)
+		b.log.Error("Failed to dispatch event from the bus", zap.String("id", m.ID), zap.Error(err))
+		return
+	}
+	b.status.Event()
+}
+
+// setErr records a failure to reach the bus, or nil once it's reachable again
+func (b *Bus) setErr(err error) {
+	b.mu.Lock()
+	b.lastErr = err
+	b.mu.Unlock()
+	if err != nil {
+		b.status.Error(err)
+	}
+}
@@ -0,0 +1,175 @@
+package eventbus
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap/zaptest"
+)
+
+type recordingDispatcher struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (d *recordingDispatcher) DispatchEvent(body []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bodies = append(d.bodies, string(body))
+	return nil
+}
+
+func (d *recordingDispatcher) dispatched() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.bodies)
+}
+
+// newStream starts a Redis server with the stream and group the bus uses, and returns a
+// client for setting them up
+func newStream(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	if err := client.XGroupCreateMkStream(context.Background(), DefaultStream, DefaultGroup, "$").Err(); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	return server, client
+}
+
+// pending lists the ids a consumer read but didn't acknowledge
+func pending(t *testing.T, client *redis.Client, consumer string) []string {
+	t.Helper()
+	entries, err := client.XPendingExt(context.Background(), &redis.XPendingExtArgs{
+		Stream: DefaultStream, Group: DefaultGroup, Start: "-", End: "+", Count: 100, Consumer: consumer,
+	}).Result()
+	if err != nil {
+		t.Fatalf("XPENDING: %v", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestBus_PublishAndConsume(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	ctx := context.Background()
+
+	receiver := New(zaptest.NewLogger(t), Config{Address: server.Addr(), Password: "secret", Role: RoleReceiver}, nil)
+	worker := &recordingDispatcher{}
+	bus := New(zaptest.NewLogger(t), Config{Address: server.Addr(), Password: "secret", Role: RoleWorker, Consumer: "w1"}, worker)
+	if err := receiver.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = receiver.Stop(ctx) }()
+	if err := bus.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = bus.Stop(ctx) }()
+
+	// Wait for the worker to join the group, so the events are new to it
+	waitFor(t, func() bool { return server.Exists(DefaultStream) })
+	for _, body := range []string{`{"type":"event_callback","n":1}`, `{"type":"event_callback","n":2}`} {
+		if err := receiver.Publish(ctx, []byte(body)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	waitFor(t, func() bool { return len(worker.dispatched()) == 2 })
+	if got := worker.dispatched(); got[0] != `{"type":"event_callback","n":1}` || got[1] != `{"type":"event_callback","n":2}` {
+		t.Errorf("dispatched = %q, want the events in order", got)
+	}
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), Password: "secret"})
+	defer func() { _ = client.Close() }()
+	waitFor(t, func() bool { return len(pending(t, client, "w1")) == 0 })
+	if err := receiver.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck() = %v, want healthy", err)
+	}
+}
+
+func TestBus_RedeliversPending(t *testing.T) {
+	_, client := newStream(t)
+	ctx := context.Background()
+	for _, body := range []string{"first", "second"} {
+		client.XAdd(ctx, &redis.XAddArgs{Stream: DefaultStream, Values: []string{eventField, body}})
+	}
+	// Both read before a restart, but only the first acknowledged
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: DefaultGroup, Consumer: "w1", Streams: []string{DefaultStream, ">"}, Block: -1}).Result()
+	if err != nil || len(streams[0].Messages) != 2 {
+		t.Fatalf("XREADGROUP = %v, %v", streams, err)
+	}
+	client.XAck(ctx, DefaultStream, DefaultGroup, streams[0].Messages[0].ID)
+
+	worker := &recordingDispatcher{}
+	bus := New(zaptest.NewLogger(t), Config{Address: client.Options().Addr, Role: RoleWorker, Consumer: "w1"}, worker)
+	if err := bus.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = bus.Stop(ctx) }()
+
+	waitFor(t, func() bool { return len(worker.dispatched()) == 1 })
+	if got := worker.dispatched(); got[0] != "second" {
+		t.Errorf("dispatched = %q, want the pending event", got)
+	}
+	waitFor(t, func() bool { return len(pending(t, client, "w1")) == 0 })
+}
+
+func TestBus_ClaimsIdleEvents(t *testing.T) {
+	server, client := newStream(t)
+	ctx := context.Background()
+	client.XAdd(ctx, &redis.XAddArgs{Stream: DefaultStream, Values: []string{eventField, "orphaned"}})
+	// Read by a worker that stopped and came back under another name
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: DefaultGroup, Consumer: "old-pod", Streams: []string{DefaultStream, ">"}, Block: -1}).Err(); err != nil {
+		t.Fatalf("XREADGROUP: %v", err)
+	}
+	server.SetTime(time.Now().Add(claimIdle + time.Second))
+
+	worker := &recordingDispatcher{}
+	bus := New(zaptest.NewLogger(t), Config{Address: server.Addr(), Role: RoleWorker, Consumer: "new-pod"}, worker)
+	if err := bus.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = bus.Stop(ctx) }()
+
+	waitFor(t, func() bool { return len(worker.dispatched()) == 1 })
+	if got := worker.dispatched(); got[0] != "orphaned" {
+		t.Errorf("dispatched = %q, want the idle event", got)
+	}
+	waitFor(t, func() bool { return len(pending(t, client, "old-pod")) == 0 && len(pending(t, client, "new-pod")) == 0 })
+}
+
+func TestBus_PublishFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	bus := New(zaptest.NewLogger(t), Config{Address: addr, Role: RoleReceiver}, nil)
+	if err := bus.Publish(context.Background(), []byte("{}")); err == nil {
+		t.Fatal("Publish() error = nil with no server")
+	}
+	if bus.HealthCheck() == nil {
+		t.Error("HealthCheck() = nil after a failed publish")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
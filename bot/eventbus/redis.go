@@ -0,0 +1,34 @@
+package eventbus
+
+import (
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newClient creates the Redis client the bus shares between publishing and reading. It
+// connects on the first command and reconnects when a connection fails.
+func newClient(c Config) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:         c.Address,
+		Username:     c.Username,
+		Password:     c.Password,
+		DialTimeout:  commandTimeout,
+		ReadTimeout:  commandTimeout,
+		WriteTimeout: commandTimeout,
+		// Commands like XADD aren't safe to send twice, so a failure is reported instead
+		MaxRetries: -1,
+	})
+}
+
+// isBusyGroup reports whether err is Redis refusing to create a group that already exists
+func isBusyGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP")
+}
+
+// messageBody is an event's body, empty when the event was trimmed from the stream before
+// it was read
+func messageBody(m redis.XMessage) []byte {
+	body, _ := m.Values[eventField].(string)
+	return []byte(body)
+}
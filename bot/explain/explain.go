@@ -0,0 +1,324 @@
+// Package explain keeps what went into the messages chat and aichat post, and threads an
+// explanation under one when an admin reacts to it with the explain reaction, e.g. :mag:
+// the feature that posted it, the pattern or persona behind it, the drop chance roll, the
+// prompt's token counts and how long it took. Traces are kept in memory for the most
+// recent messages, so messages posted before a restart can't be explained.
+package explain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/emoji"
+)
+
+const (
+	DefaultReaction  = "mag"
+	DefaultMaxTraces = 1000
+)
+
+type slackService interface {
+	Client() *slack.Client
+	BotUserID() string
+}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Reaction is the emoji an admin reacts with to have a message explained, defaults to mag
+	Reaction *string `json:"reaction" yaml:"reaction"`
+	// Admins are user IDs who can have messages explained besides workspace admins and owners
+	Admins []string `json:"admins" yaml:"admins"`
+	// MaxTraces is how many of the most recent messages can be explained, defaults to 1000
+	MaxTraces *int `json:"max_traces" yaml:"max_traces"`
+}
+
+type Config struct {
+	Enabled   bool
+	Reaction  string
+	Admins    []string
+	MaxTraces int
+}
+
+// Trace is what went into a message the bot posted
+type Trace struct {
+	Feature   string
+	Channel   string
+	TS        string // The bot's message
+	ThreadTS  string // Thread the message was posted in, if any
+	TriggerTS string // Message it answers
+	EventID   string // Slack event it answers
+	// Trigger is why the feature posted, e.g. the pattern a message matched or mention
+	Trigger string
+	Variant string
+	Persona string
+	// Rolled is set when the reply survived a drop chance roll: it's posted when Roll is at
+	// least DropChance
+	Rolled     bool
+	DropChance float64
+	Roll       float64
+	Model      string // Set when it isn't the endpoint's default
+	// PromptTokens and CompletionTokens are the provider's counts, zero when it reports none
+	PromptTokens     int
+	CompletionTokens int
+	LLMLatency       time.Duration
+	At               time.Time // When the message was posted
+}
+
+// Latency is how long the message took to post after the one it answers
+func (t Trace) Latency() time.Duration {
+	seconds, err := strconv.ParseFloat(t.TriggerTS, 64)
+	if err != nil || t.At.IsZero() {
+		return 0
+	}
+	return max(t.At.Sub(time.UnixMilli(int64(seconds*1000))), 0)
+}
+
+// Tokens reads the prompt and completion token counts from an LLM response's generation
+// info, which providers name differently
+func Tokens(info map[string]any) (prompt, completion int) {
+	count := func(keys ...string) int {
+		for _, key := range keys {
+			switch v := info[key].(type) {
+			case int:
+				return v
+			case int32:
+				return int(v)
+			case int64:
+				return int(v)
+			case float64:
+				return int(v)
+			}
+		}
+		return 0
+	}
+	return count("PromptTokens", "InputTokens"), count("CompletionTokens", "OutputTokens")
+}
+
+// Explainer records traces of the messages features post and explains them on request
+type Explainer struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	mu          sync.Mutex
+	traces      map[string]Trace // Channel and ts -> trace
+	order       []string         // Keys of traces, oldest first
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Explainer {
+	if c.Reaction == "" {
+		c.Reaction = DefaultReaction
+	}
+	if c.MaxTraces <= 0 {
+		c.MaxTraces = DefaultMaxTraces
+	}
+	return &Explainer{
+		log:      log,
+		config:   c,
+		slack:    s,
+		traces:   make(map[string]Trace),
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+}
+
+// SetStatusTracker sets where the explainer reports its activity
+func (x *Explainer) SetStatusTracker(t *status.Tracker) {
+	x.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (x *Explainer) ProcessorType() string {
+	return "explain"
+}
+
+func (x *Explainer) Start(ctx context.Context) error {
+	x.isConnected.Store(true)
+	go x.handleEvents(ctx)
+	x.log.Debug("Explainer started.", zap.String("reaction", x.config.Reaction))
+	return nil
+}
+
+func (x *Explainer) Stop(_ context.Context) error {
+	if !x.isConnected.Load() {
+		return nil
+	}
+	close(x.stopCh)
+	x.isConnected.Store(false)
+	return nil
+}
+
+// Record keeps the trace of a posted message, forgetting the oldest past MaxTraces
+func (x *Explainer) Record(t Trace) {
+	if t.Channel == "" || t.TS == "" {
+		return
+	}
+	if t.At.IsZero() {
+		t.At = time.Now()
+	}
+	key := t.Channel + "/" + t.TS
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.traces[key]; !ok {
+		x.order = append(x.order, key)
+	}
+	x.traces[key] = t
+	if over := len(x.order) - x.config.MaxTraces; over > 0 {
+		for _, old := range x.order[:over] {
+			delete(x.traces, old)
+		}
+		x.order = slices.Delete(x.order, 0, over)
+	}
+}
+
+// Lookup returns the trace of the message at ts
+func (x *Explainer) Lookup(channelID, ts string) (Trace, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	t, ok := x.traces[channelID+"/"+ts]
+	return t, ok
+}
+
+// PushEvent adds an event to be processed by the explainer
+func (x *Explainer) PushEvent(e event.Event) {
+	if !x.isConnected.Load() {
+		return
+	}
+	if _, ok := e.Data().(*slackevents.ReactionAddedEvent); !ok {
+		return
+	}
+	select {
+	case x.eventsCh <- e:
+	default:
+		x.log.Warn("Explainer events channel full, dropping event.")
+		x.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (x *Explainer) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-x.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-x.eventsCh:
+			x.processEvent(ctx, e)
+		}
+	}
+}
+
+// processEvent threads an explanation under the bot's message an admin reacted to with
+// the explain reaction
+func (x *Explainer) processEvent(ctx context.Context, e event.Event) {
+	ev, ok := e.Data().(*slackevents.ReactionAddedEvent)
+	if !ok || ev.Item.Type != "message" || emoji.Base(ev.Reaction) != emoji.Name(x.config.Reaction) {
+		return
+	}
+	if e.User == "" || e.User == x.slack.BotUserID() {
+		return
+	}
+	trace, traced := x.Lookup(e.Channel, e.TS)
+	if !traced && ev.ItemUser != x.slack.BotUserID() {
+		return
+	}
+	x.status.Event()
+	admin, err := x.isAdmin(ctx, e.User)
+	if err != nil {
+		x.status.Error(err)
+		x.log.Error("Failed to check whether the user is an admin", zap.String("user", e.User), zap.Error(err))
+		return
+	}
+	if !admin {
+		x.log.Debug("Ignoring explain reaction from a user who isn't an admin", zap.String("user", e.User))
+		return
+	}
+
+	text := fmt.Sprintf("🔍 No trace of this message. Only the last %d messages chat and aichat posted since the bot started can be explained.", x.config.MaxTraces)
+	threadTS := e.TS
+	if traced {
+		text = format(trace)
+		if trace.ThreadTS != "" {
+			threadTS = trace.ThreadTS
+		}
+	}
+	_, _, err = x.slack.Client().PostMessageContext(ctx, e.Channel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(threadTS),
+	)
+	if err != nil {
+		err = conversation.Error("chat.postMessage", conversation.KindFromID(e.Channel), err)
+		x.status.PostFailed(e.Channel, err)
+		x.log.Error("Failed to post explanation", zap.String("channel", e.Channel), zap.Error(err))
+		return
+	}
+	x.status.Posted()
+	x.log.Info("Explained message",
+		zap.String("channel", e.Channel),
+		zap.String("ts", e.TS),
+		zap.String("by", e.User),
+		zap.Bool("traced", traced))
+}
+
+// isAdmin reports whether the user is a configured admin or a workspace admin or owner
+func (x *Explainer) isAdmin(ctx context.Context, userID string) (bool, error) {
+	if slices.Contains(x.config.Admins, userID) {
+		return true, nil
+	}
+	u, err := x.slack.Client().GetUserInfoContext(ctx, userID)
+	if err != nil {
+		return false, errs.NewSlackAPIError("users.info", err)
+	}
+	return u.IsAdmin || u.IsOwner || u.IsPrimaryOwner, nil
+}
+
+// format renders a trace as a Slack message
+func format(t Trace) string {
+	var b strings.Builder
+	b.WriteString("🔍 *Why this was posted*\n")
+	fmt.Fprintf(&b, "• Feature: %s\n", t.Feature)
+	if t.Trigger != "" {
+		fmt.Fprintf(&b, "• Trigger: %s\n", t.Trigger)
+	}
+	if t.Variant != "" {
+		fmt.Fprintf(&b, "• Variant: %s\n", t.Variant)
+	}
+	if t.Persona != "" {
+		fmt.Fprintf(&b, "• Persona: %s\n", t.Persona)
+	}
+	if t.Rolled {
+		fmt.Fprintf(&b, "• Drop chance: %.0f%%, rolled %.2f\n", t.DropChance*100, t.Roll)
+	}
+	if t.Model != "" {
+		fmt.Fprintf(&b, "• Model: %s\n", t.Model)
+	}
+	if t.PromptTokens > 0 || t.CompletionTokens > 0 {
+		fmt.Fprintf(&b, "• Tokens: %d prompt, %d completion\n", t.PromptTokens, t.CompletionTokens)
+	}
+	if t.LLMLatency > 0 {
+		fmt.Fprintf(&b, "• LLM: %s\n", t.LLMLatency.Round(100*time.Millisecond))
+	}
+	if latency := t.Latency(); latency > 0 {
+		fmt.Fprintf(&b, "• Latency: %s after the message it answers\n", latency.Round(100*time.Millisecond))
+	}
+	if t.EventID != "" {
+		fmt.Fprintf(&b, "• Event: %s\n", t.EventID)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
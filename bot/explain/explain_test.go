@@ -0,0 +1,132 @@
+package explain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestExplainer_Record(t *testing.T) {
+	x := New(zap.NewNop(), Config{MaxTraces: 2}, nil)
+	x.Record(Trace{Feature: "chat", Channel: "C1", TS: "1.1"})
+	x.Record(Trace{Feature: "chat", Channel: "C1", TS: "2.2"})
+	x.Record(Trace{Feature: "aichat", Channel: "C1", TS: "1.1"})
+	x.Record(Trace{Feature: "chat", Channel: "C1", TS: "3.3"})
+	x.Record(Trace{Feature: "chat", Channel: "C1"})
+
+	if _, ok := x.Lookup("C1", "1.1"); ok {
+		t.Error("Lookup(1.1) found a trace, want the oldest forgotten")
+	}
+	if tr, ok := x.Lookup("C1", "3.3"); !ok || tr.At.IsZero() {
+		t.Errorf("Lookup(3.3) = %+v, %v, want the trace stamped with when it was posted", tr, ok)
+	}
+	if len(x.order) != 2 || len(x.traces) != 2 {
+		t.Errorf("kept %d traces in order and %d by key, want 2", len(x.order), len(x.traces))
+	}
+}
+
+func TestTokens(t *testing.T) {
+	if p, c := Tokens(map[string]any{"PromptTokens": 120, "CompletionTokens": 18}); p != 120 || c != 18 {
+		t.Errorf("Tokens(OpenAI) = %d, %d, want 120, 18", p, c)
+	}
+	if p, c := Tokens(map[string]any{"InputTokens": float64(80), "OutputTokens": int64(9)}); p != 80 || c != 9 {
+		t.Errorf("Tokens(Anthropic) = %d, %d, want 80, 9", p, c)
+	}
+	if p, c := Tokens(nil); p != 0 || c != 0 {
+		t.Errorf("Tokens(nil) = %d, %d, want 0, 0", p, c)
+	}
+}
+
+func TestExplainer_ProcessEvent(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/users.info":
+			admin := r.FormValue("user") == "UADMIN"
+			if admin {
+				_, _ = w.Write([]byte(`{"ok": true, "user": {"id": "UADMIN", "is_admin": true}}`))
+			} else {
+				_, _ = w.Write([]byte(`{"ok": true, "user": {"id": "` + r.FormValue("user") + `"}}`))
+			}
+		case "/chat.postMessage":
+			posts = append(posts, r.FormValue("thread_ts")+": "+r.FormValue("text"))
+			_, _ = w.Write([]byte(slacktest.Posted))
+		}
+	}))
+	defer server.Close()
+
+	s := slacktest.New(server.URL)
+	x := New(zap.NewNop(), Config{Admins: []string{"UCONF"}}, s)
+	x.Record(Trace{
+		Feature:          "aichat",
+		Channel:          "C1",
+		TS:               "1700000002.000000",
+		TriggerTS:        "1700000000.000000",
+		ThreadTS:         "1699999999.000000",
+		Trigger:          "unprompted",
+		Persona:          "glazer",
+		Rolled:           true,
+		DropChance:       0.4,
+		Roll:             0.73,
+		PromptTokens:     412,
+		CompletionTokens: 23,
+		LLMLatency:       1340 * time.Millisecond,
+		At:               time.Unix(1700000002, 500_000_000),
+	})
+	react := func(user, reaction, ts, itemUser string) {
+		posts = nil
+		x.processEvent(context.Background(), event.Callback(&slackevents.ReactionAddedEvent{
+			User:     user,
+			Reaction: reaction,
+			ItemUser: itemUser,
+			Item:     slackevents.Item{Type: "message", Channel: "C1", Timestamp: ts},
+		}))
+	}
+
+	react("UADMIN", "mag", "1700000002.000000", "UBOT")
+	if len(posts) != 1 {
+		t.Fatalf("posts = %q, want one explanation", posts)
+	}
+	for _, want := range []string{
+		"1699999999.000000: ", "Feature: aichat", "Trigger: unprompted", "Persona: glazer",
+		"Drop chance: 40%, rolled 0.73", "Tokens: 412 prompt, 23 completion", "LLM: 1.3s", "Latency: 2.5s",
+	} {
+		if !strings.Contains(posts[0], want) {
+			t.Errorf("explanation = %q, want it to contain %q", posts[0], want)
+		}
+	}
+
+	react("UCONF", "mag::skin-tone-2", "1700000002.000000", "UBOT")
+	if len(posts) != 1 {
+		t.Errorf("posts = %q, want a configured admin's reaction explained", posts)
+	}
+	react("UADMIN", "mag", "5.5", "UBOT")
+	if len(posts) != 1 || !strings.Contains(posts[0], "5.5: 🔍 No trace") {
+		t.Errorf("posts = %q, want an untraced bot message to say so", posts)
+	}
+
+	for _, tt := range []struct{ name, user, reaction, itemUser string }{
+		{"non-admin", "U2", "mag", "UBOT"},
+		{"other reaction", "UADMIN", "eyes", "UBOT"},
+		{"someone else's message", "UADMIN", "mag", "U3"},
+		{"bot's own reaction", "UBOT", "mag", "UBOT"},
+	} {
+		ts := "1700000002.000000"
+		if tt.itemUser != "UBOT" {
+			ts = "6.6"
+		}
+		react(tt.user, tt.reaction, ts, tt.itemUser)
+		if len(posts) != 0 {
+			t.Errorf("%s: posts = %q, want none", tt.name, posts)
+		}
+	}
+}
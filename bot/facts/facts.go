@@ -0,0 +1,416 @@
+// Package facts remembers things for a channel. "@bot remember wifi password is hunter2"
+// stores a fact that "@bot what is the wifi password" answers, but only in the channel it
+// was stored in. Facts are kept in the data dir so they survive restarts.
+package facts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	stateFile = "facts.json"
+	// DefaultMaxPerChannel is how many facts a channel can hold unless configured
+	DefaultMaxPerChannel = 100
+	maxKeyLength         = 100
+	maxValueLength       = 1000
+)
+
+const mention = `(?i)^\s*(?:<@[A-Z0-9]+>[\s,:]*)?`
+
+var (
+	// rememberPattern matches "<@U123> remember wifi password is hunter2"
+	rememberPattern = regexp.MustCompile(mention + `remember\s+(?:that\s+)?(.+?)\s+(?:is|are|=)\s+(.+?)\s*$`)
+	// lookupPattern matches "<@U123> what is the wifi password?"
+	lookupPattern = regexp.MustCompile(mention + `(?:what\s+is|what\s+are|what's|whats)\s+(.+?)\s*\?*\s*$`)
+	// forgetPattern matches "<@U123> forget the wifi password"
+	forgetPattern = regexp.MustCompile(mention + `forget\s+(?:about\s+)?(.+?)\s*[.!]*\s*$`)
+	// listPattern matches "<@U123> list facts" or "<@U123> what do you remember?"
+	listPattern = regexp.MustCompile(mention + `(?:list\s+facts|what\s+do\s+you\s+remember)\s*\?*\s*$`)
+)
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+// mirror keeps a copy of a feature's list for a channel where people can read it
+type mirror interface {
+	Sync(ctx context.Context, feature, channelID, title, markdown string) error
+}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Canvas mirrors each channel's facts into a canvas bookmarked in the channel
+	Canvas *bool `json:"canvas" yaml:"canvas"`
+	// MaxPerChannel is how many facts a channel can hold, defaults to 100
+	MaxPerChannel *int           `json:"max_per_channel" yaml:"max_per_channel"`
+	Persona       persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	Enabled       bool
+	DataDir       string
+	MaxPerChannel int
+	Canvas        bool
+	Persona       persona.Config // Name and icon answers are posted with
+}
+
+// Fact is a value remembered for a channel
+type Fact struct {
+	Key   string    `json:"key"` // As it was first written, e.g. WiFi password
+	Value string    `json:"value"`
+	SetBy string    `json:"set_by"`
+	SetAt time.Time `json:"set_at"`
+}
+
+// Keeper stores facts per channel and answers mentions asking for them
+type Keeper struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	path        string
+	facts       map[string]map[string]Fact // channel -> normalized key -> fact
+	mu          sync.Mutex
+	now         func() time.Time
+	quiet       atomic.Bool
+	mirror      mirror
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Keeper {
+	if c.MaxPerChannel <= 0 {
+		c.MaxPerChannel = DefaultMaxPerChannel
+	}
+	k := &Keeper{
+		log:      log,
+		config:   c,
+		slack:    s,
+		path:     filepath.Join(c.DataDir, stateFile),
+		facts:    make(map[string]map[string]Fact),
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+	k.load()
+	return k
+}
+
+// SetStatusTracker sets where the keeper reports its activity
+func (k *Keeper) SetStatusTracker(t *status.Tracker) {
+	k.status = t
+}
+
+// SetQuietUnknown leaves questions about facts the channel doesn't have unanswered, so
+// another feature such as aichat can answer them
+func (k *Keeper) SetQuietUnknown(quiet bool) {
+	k.quiet.Store(quiet)
+}
+
+// SetMirror keeps each channel's facts mirrored as they're remembered and forgotten
+func (k *Keeper) SetMirror(m mirror) {
+	k.mirror = m
+}
+
+// ProcessorType returns a description of the processor type
+func (k *Keeper) ProcessorType() string {
+	return "facts"
+}
+
+func (k *Keeper) Start(ctx context.Context) error {
+	k.isConnected.Store(true)
+	go k.handleEvents(ctx)
+	return nil
+}
+
+func (k *Keeper) Stop(ctx context.Context) error {
+	if !k.isConnected.Load() {
+		return nil
+	}
+	close(k.stopCh)
+	k.isConnected.Store(false)
+	return nil
+}
+
+// Answers reports whether the keeper replies to the mention text in the channel: a
+// command, or a question about a fact the channel has
+func (k *Keeper) Answers(channelID, text string) bool {
+	if rememberPattern.MatchString(text) || forgetPattern.MatchString(text) || listPattern.MatchString(text) {
+		return true
+	}
+	m := lookupPattern.FindStringSubmatch(text)
+	if m == nil {
+		return false
+	}
+	_, ok := k.Get(channelID, m[1])
+	return ok || !k.quiet.Load()
+}
+
+// Get returns the channel's fact for a key
+func (k *Keeper) Get(channelID, key string) (Fact, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	f, ok := k.facts[channelID][normalize(key)]
+	return f, ok
+}
+
+// List returns the channel's facts sorted by key
+func (k *Keeper) List(channelID string) []Fact {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	facts := slices.Collect(maps.Values(k.facts[channelID]))
+	slices.SortFunc(facts, func(a, b Fact) int { return strings.Compare(normalize(a.Key), normalize(b.Key)) })
+	return facts
+}
+
+// PushEvent adds an event to be processed by the keeper
+func (k *Keeper) PushEvent(e event.Event) {
+	if !k.isConnected.Load() {
+		return
+	}
+
+	select {
+	case k.eventsCh <- e:
+	default:
+		k.log.Warn("Facts events channel full, dropping event.")
+		k.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (k *Keeper) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-k.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-k.eventsCh:
+			k.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (k *Keeper) processEvent(ctx context.Context, e event.Event) {
+	if _, ok := e.Data().(*slackevents.AppMentionEvent); !ok || e.FromBot() {
+		return
+	}
+	threadTS := e.ThreadTS
+	if threadTS == "" {
+		threadTS = e.TS
+	}
+	if m := rememberPattern.FindStringSubmatch(e.Text); m != nil {
+		k.status.Event()
+		reply, changed := k.remember(e.Channel, e.User, m[1], m[2])
+		k.post(ctx, e.Channel, threadTS, reply)
+		if changed {
+			k.sync(ctx, e.Channel)
+		}
+		return
+	}
+	if m := forgetPattern.FindStringSubmatch(e.Text); m != nil {
+		k.status.Event()
+		reply, changed := k.forget(e.Channel, e.User, m[1])
+		k.post(ctx, e.Channel, threadTS, reply)
+		if changed {
+			k.sync(ctx, e.Channel)
+		}
+		return
+	}
+	if listPattern.MatchString(e.Text) {
+		k.status.Event()
+		k.post(ctx, e.Channel, threadTS, k.list(e.Channel))
+		return
+	}
+	if m := lookupPattern.FindStringSubmatch(e.Text); m != nil {
+		f, ok := k.Get(e.Channel, m[1])
+		switch {
+		case ok:
+			k.status.Event()
+			k.post(ctx, e.Channel, threadTS, fmt.Sprintf("%s is %s", f.Key, f.Value))
+		case !k.quiet.Load():
+			k.status.Event()
+			k.post(ctx, e.Channel, threadTS, fmt.Sprintf("I don't remember anything about %s here.", displayKey(m[1])))
+		}
+	}
+}
+
+// remember stores a fact and returns the reply, and whether the channel's facts changed
+func (k *Keeper) remember(channelID, userID, key, value string) (string, bool) {
+	key, value = displayKey(key), strings.TrimSpace(value)
+	switch {
+	case len(key) > maxKeyLength:
+		return fmt.Sprintf("That's a long name, keep it under %d characters.", maxKeyLength), false
+	case len(value) > maxValueLength:
+		return fmt.Sprintf("That's too much to remember, keep it under %d characters.", maxValueLength), false
+	}
+
+	k.mu.Lock()
+	facts := k.facts[channelID]
+	if facts == nil {
+		facts = make(map[string]Fact)
+		k.facts[channelID] = facts
+	}
+	_, replaced := facts[normalize(key)]
+	if !replaced && len(facts) >= k.config.MaxPerChannel {
+		k.mu.Unlock()
+		return fmt.Sprintf("I'm already remembering %d things here, ask me to forget one first.", len(facts)), false
+	}
+	facts[normalize(key)] = Fact{Key: key, Value: value, SetBy: userID, SetAt: k.now()}
+	k.save()
+	k.mu.Unlock()
+
+	k.log.Info("Fact remembered",
+		zap.String("channel", channelID),
+		zap.String("key", key),
+		zap.String("user", userID),
+		zap.Bool("replaced", replaced))
+	return fmt.Sprintf("📝 Got it, I'll remember %s in this channel.", key), true
+}
+
+// forget removes a fact and returns the reply, and whether the channel's facts changed
+func (k *Keeper) forget(channelID, userID, key string) (string, bool) {
+	k.mu.Lock()
+	f, ok := k.facts[channelID][normalize(key)]
+	if ok {
+		delete(k.facts[channelID], normalize(key))
+		if len(k.facts[channelID]) == 0 {
+			delete(k.facts, channelID)
+		}
+		k.save()
+	}
+	k.mu.Unlock()
+
+	if !ok {
+		return fmt.Sprintf("I don't remember anything about %s here.", displayKey(key)), false
+	}
+	k.log.Info("Fact forgotten",
+		zap.String("channel", channelID),
+		zap.String("key", f.Key),
+		zap.String("user", userID))
+	return fmt.Sprintf("🗑️ Forgot %s.", f.Key), true
+}
+
+// sync mirrors the channel's facts, when a mirror is set
+func (k *Keeper) sync(ctx context.Context, channelID string) {
+	if k.mirror == nil {
+		return
+	}
+	var b strings.Builder
+	facts := k.List(channelID)
+	if len(facts) == 0 {
+		b.WriteString("Nothing remembered yet. Mention me with \"remember <name> is <value>\" to add something.\n")
+	}
+	for _, f := range facts {
+		fmt.Fprintf(&b, "- **%s**: %s\n", f.Key, f.Value)
+	}
+	if err := k.mirror.Sync(ctx, k.ProcessorType(), channelID, "📝 Facts", b.String()); err != nil {
+		k.status.PostFailed(channelID, err)
+		k.log.Error("Failed to mirror facts", zap.String("channel", channelID), zap.Error(err))
+	}
+}
+
+func (k *Keeper) list(channelID string) string {
+	facts := k.List(channelID)
+	if len(facts) == 0 {
+		return "I'm not remembering anything in this channel."
+	}
+	keys := make([]string, len(facts))
+	for i, f := range facts {
+		keys[i] = "• " + f.Key
+	}
+	return "Here's what I remember in this channel:\n" + strings.Join(keys, "\n")
+}
+
+func (k *Keeper) post(ctx context.Context, channelID, threadTS, text string) {
+	_, _, err := k.slack.Client().PostMessageContext(ctx, channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(threadTS),
+		k.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		k.status.PostFailed(channelID, err)
+		k.log.Error("Failed to post facts reply", zap.String("channel", channelID), zap.Error(err))
+		return
+	}
+	k.status.Posted()
+}
+
+// displayKey trims a key as written, dropping a leading "the" so "the wifi password"
+// and "wifi password" are the same fact
+func displayKey(key string) string {
+	key = strings.Join(strings.Fields(key), " ")
+	if len(key) > 4 && strings.EqualFold(key[:4], "the ") {
+		key = key[4:]
+	}
+	return key
+}
+
+func normalize(key string) string {
+	return strings.ToLower(displayKey(key))
+}
+
+func (k *Keeper) load() {
+	data, err := os.ReadFile(k.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		k.log.Error("Failed to read facts", zap.Error(err), zap.String("path", k.path))
+		return
+	}
+	var facts map[string][]Fact
+	if err := json.Unmarshal(data, &facts); err != nil {
+		k.log.Error("Failed to unmarshal facts", zap.Error(err), zap.String("path", k.path))
+		return
+	}
+	for channelID, list := range facts {
+		k.facts[channelID] = make(map[string]Fact, len(list))
+		for _, f := range list {
+			k.facts[channelID][normalize(f.Key)] = f
+		}
+	}
+}
+
+// save writes every channel's facts; callers must hold mu
+func (k *Keeper) save() {
+	facts := make(map[string][]Fact, len(k.facts))
+	for channelID, byKey := range k.facts {
+		list := slices.Collect(maps.Values(byKey))
+		slices.SortFunc(list, func(a, b Fact) int { return strings.Compare(normalize(a.Key), normalize(b.Key)) })
+		facts[channelID] = list
+	}
+	data, err := json.Marshal(facts)
+	if err != nil {
+		k.log.Error("Failed to marshal facts", zap.Error(err))
+		return
+	}
+	tempFile := k.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		k.log.Error("Failed to save facts", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, k.path); err != nil {
+		k.log.Error("Failed to save facts", zap.Error(&errs.StorageError{Op: "rename", Path: k.path, Err: err}))
+	}
+}
@@ -0,0 +1,123 @@
+package facts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestKeeper_Facts(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts = append(posts, r.FormValue("channel")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(slacktest.Posted))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	s := slacktest.New(server.URL)
+	k := New(zap.NewNop(), Config{DataDir: dir}, s)
+	ask := func(channel, text string) string {
+		posts = nil
+		k.processEvent(context.Background(), event.Callback(&slackevents.AppMentionEvent{User: "U1", Channel: channel, Text: text, TimeStamp: "1.1"}))
+		if len(posts) != 1 {
+			t.Fatalf("posts = %v after %q, want one reply", posts, text)
+		}
+		return posts[0]
+	}
+
+	if got := ask("C1", "<@UBOT> remember WiFi password is hunter2"); !strings.Contains(got, "WiFi password") || strings.Contains(got, "hunter2") {
+		t.Errorf("remember reply = %q, want the key acknowledged without repeating the value", got)
+	}
+	if got := ask("C1", "<@UBOT> what is the wifi password?"); got != "C1: WiFi password is hunter2" {
+		t.Errorf("lookup reply = %q, want the fact", got)
+	}
+	if got := ask("C2", "<@UBOT> what is the wifi password?"); strings.Contains(got, "hunter2") {
+		t.Errorf("lookup reply in another channel = %q, want facts kept to their channel", got)
+	}
+	if got := ask("C1", "<@UBOT> list facts"); !strings.Contains(got, "• WiFi password") {
+		t.Errorf("list reply = %q, want the fact's key", got)
+	}
+
+	// Facts survive a restart
+	k = New(zap.NewNop(), Config{DataDir: dir}, s)
+	if f, ok := k.Get("C1", "the wifi password"); !ok || f.Value != "hunter2" || f.SetBy != "U1" {
+		t.Fatalf("Get() after reloading = %+v, %v, want the fact restored", f, ok)
+	}
+
+	if got := ask("C1", "<@UBOT> forget the wifi password"); !strings.Contains(got, "Forgot WiFi password") {
+		t.Errorf("forget reply = %q, want the fact forgotten", got)
+	}
+	if _, ok := k.Get("C1", "wifi password"); ok {
+		t.Error("Get() after forgetting = true")
+	}
+
+	// Quiet about unknown facts, so aichat can answer the question instead
+	k.SetQuietUnknown(true)
+	if k.Answers("C1", "<@UBOT> what is the meaning of life?") {
+		t.Error("Answers() = true for an unknown fact while quiet")
+	}
+	if !k.Answers("C1", "<@UBOT> remember the meaning of life is 42") {
+		t.Error("Answers() = false for a remember command")
+	}
+}
+
+func TestKeeper_MaxPerChannel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	k := New(zap.NewNop(), Config{DataDir: t.TempDir(), MaxPerChannel: 1}, slacktest.New(server.URL))
+	k.remember("C1", "U1", "door code", "1234")
+	if reply, changed := k.remember("C1", "U1", "gate code", "5678"); changed || !strings.Contains(reply, "forget one first") {
+		t.Errorf("remember() = %q, want the channel's limit enforced", reply)
+	}
+	k.remember("C1", "U1", "door code", "4321")
+	if f, _ := k.Get("C1", "door code"); f.Value != "4321" {
+		t.Errorf("Get() = %+v, want replacing a fact allowed at the limit", f)
+	}
+}
+
+type mockMirror map[string]string
+
+func (m mockMirror) Sync(ctx context.Context, feature, channelID, title, markdown string) error {
+	m[feature+"/"+channelID] = markdown
+	return nil
+}
+
+func TestKeeper_Mirror(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	k := New(zap.NewNop(), Config{DataDir: t.TempDir()}, slacktest.New(server.URL))
+	mirrored := mockMirror{}
+	k.SetMirror(mirrored)
+	mention := func(text string) {
+		k.processEvent(context.Background(), event.Callback(&slackevents.AppMentionEvent{User: "U1", Channel: "C1", Text: text, TimeStamp: "1.1"}))
+	}
+
+	mention("<@UBOT> remember door code is 1234")
+	if got := mirrored["facts/C1"]; got != "- **door code**: 1234\n" {
+		t.Errorf("mirrored = %q, want the channel's facts", got)
+	}
+	delete(mirrored, "facts/C1")
+	mention("<@UBOT> what is the door code")
+	if _, ok := mirrored["facts/C1"]; ok {
+		t.Error("a lookup mirrored the facts, want only changes mirrored")
+	}
+	mention("<@UBOT> forget door code")
+	if got := mirrored["facts/C1"]; !strings.HasPrefix(got, "Nothing remembered yet") {
+		t.Errorf("mirrored = %q, want the emptied list", got)
+	}
+}
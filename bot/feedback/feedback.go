@@ -0,0 +1,311 @@
+// Package feedback relays feedback users DM to the bot, e.g. "feedback: standups run
+// long", to a channel without their name. Who sent each message is kept sealed to an
+// audit public key, so it can only be revealed from the CLI with the private key.
+package feedback
+
+import (
+	"bufio"
+	"context"
+	"crypto/hpke"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	feedbackFile = "feedback.jsonl"
+
+	DefaultRateLimit  = 3
+	DefaultRateWindow = 24 * time.Hour
+)
+
+// ErrNotFound is returned when no feedback has the requested ID
+var ErrNotFound = errors.New("feedback not found")
+
+// requestPattern matches a DM like "feedback: ..." and captures the feedback
+var requestPattern = regexp.MustCompile(`(?is)^\s*feedback\s*:\s*(.+)$`)
+
+// mrkdwnEscaper keeps feedback from mentioning users or channels when it's relayed
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+type slackService interface {
+	Client() *slack.Client
+	BotUserID() string
+}
+
+type FileConfig struct {
+	// Channel is where feedback is relayed
+	Channel *string `json:"channel" yaml:"channel"`
+	// AuditPublicKey seals who sent each message, from `slackbot feedback keygen`
+	AuditPublicKey *string `json:"audit_public_key" yaml:"audit_public_key"`
+	// RateLimit is how many messages a user can send per RateWindow, defaults to 3 per 24h
+	RateLimit  *int           `json:"rate_limit" yaml:"rate_limit"`
+	RateWindow *time.Duration `json:"rate_window" yaml:"rate_window"`
+	Persona    persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	DataDir        string
+	Channel        string
+	AuditPublicKey string
+	RateLimit      int
+	RateWindow     time.Duration
+	Persona        persona.Config // Name and icon relayed feedback is posted with
+}
+
+// Entry is one JSONL line in the feedback file. It holds no plaintext sender.
+type Entry struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Channel   string    `json:"channel"`
+	TS        string    `json:"ts"`     // Relayed message timestamp
+	Sealed    string    `json:"sealed"` // AuditRecord sealed to the audit public key
+	SealedFor string    `json:"sealed_for"`
+}
+
+// Box relays anonymous feedback sent to the bot in DMs
+type Box struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	auditKey    hpke.PublicKey
+	path        string
+	mu          sync.Mutex // Guards sent and file writes
+	sent        map[string][]time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) (*Box, error) {
+	key, err := ParsePublicKey(c.AuditPublicKey)
+	if err != nil {
+		return nil, &errs.ConfigError{Key: "feedback.audit_public_key", Err: err}
+	}
+	return &Box{
+		log:      log,
+		config:   c,
+		slack:    s,
+		auditKey: key,
+		path:     filepath.Join(c.DataDir, feedbackFile),
+		sent:     make(map[string][]time.Time),
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}, nil
+}
+
+// SetStatusTracker sets where the box reports its activity
+func (b *Box) SetStatusTracker(t *status.Tracker) {
+	b.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (b *Box) ProcessorType() string {
+	return "feedback"
+}
+
+func (b *Box) Start(ctx context.Context) error {
+	b.isConnected.Store(true)
+	go b.handleEvents(ctx)
+	b.log.Debug("Feedback box started.", zap.String("channel", b.config.Channel))
+	return nil
+}
+
+func (b *Box) Stop(ctx context.Context) error {
+	if !b.isConnected.Load() {
+		return nil
+	}
+	close(b.stopCh)
+	b.isConnected.Store(false)
+	return nil
+}
+
+// PushEvent adds an event to be processed by the box
+func (b *Box) PushEvent(e event.Event) {
+	if !b.isConnected.Load() {
+		return
+	}
+
+	select {
+	case b.eventsCh <- e:
+	default:
+		b.log.Warn("Feedback events channel full, dropping event.")
+		b.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (b *Box) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-b.eventsCh:
+			b.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (b *Box) processEvent(ctx context.Context, e event.Event) {
+	if _, ok := e.Data().(*slackevents.MessageEvent); !ok {
+		return
+	}
+	if e.ChannelType != "im" || e.SubType != "" || e.FromBot() || e.User == "" || e.User == b.slack.BotUserID() {
+		return
+	}
+	m := requestPattern.FindStringSubmatch(e.Text)
+	if m == nil {
+		return
+	}
+	b.status.Event()
+	text := strings.TrimSpace(m[1])
+
+	if !b.allow(e.User, time.Now()) {
+		b.log.Info("Feedback rate limited", zap.Int("limit", b.config.RateLimit), zap.Duration("window", b.config.RateWindow))
+		b.reply(ctx, e.Channel, fmt.Sprintf("You can send up to %d pieces of feedback every %s, please try again later.", b.config.RateLimit, b.config.RateWindow))
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		b.status.Error(err)
+		b.log.Error("Failed to create feedback ID", zap.Error(err))
+		return
+	}
+	sealed, err := seal(b.auditKey, AuditRecord{ID: id, User: e.User, SentAt: time.Now()})
+	if err != nil {
+		b.status.Error(err)
+		b.log.Error("Failed to seal feedback audit record", zap.Error(err))
+		b.reply(ctx, e.Channel, "Sorry, your feedback couldn't be sent.")
+		return
+	}
+
+	message := fmt.Sprintf("📬 *Anonymous feedback* `%s`\n>>> %s", id, mrkdwnEscaper.Replace(text))
+	_, ts, err := b.slack.Client().PostMessageContext(ctx, b.config.Channel,
+		slack.MsgOptionText(message, false),
+		b.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		b.status.PostFailed(b.config.Channel, err)
+		b.log.Error("Failed to relay feedback", zap.String("id", id), zap.Error(err))
+		b.reply(ctx, e.Channel, "Sorry, your feedback couldn't be sent.")
+		return
+	}
+	b.status.Posted()
+
+	entry := Entry{ID: id, Time: time.Now(), Channel: b.config.Channel, TS: ts, Sealed: sealed, SealedFor: b.config.AuditPublicKey}
+	if err := b.append(entry); err != nil {
+		b.status.Error(err)
+		b.log.Error("Failed to store feedback audit entry", zap.String("id", id), zap.Error(err))
+	}
+	// Only the ID is logged, never the sender
+	b.log.Info("Relayed anonymous feedback", zap.String("id", id))
+	b.reply(ctx, e.Channel, fmt.Sprintf("Thanks, your feedback was shared anonymously as `%s`.", id))
+}
+
+// allow records a message and reports whether the user is under the rate limit
+func (b *Box) allow(userID string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var recent []time.Time
+	for _, t := range b.sent[userID] {
+		if now.Sub(t) < b.config.RateWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= b.config.RateLimit {
+		b.sent[userID] = recent
+		return false
+	}
+	b.sent[userID] = append(recent, now)
+	return true
+}
+
+func (b *Box) reply(ctx context.Context, channelID, text string) {
+	if _, _, err := b.slack.Client().PostMessageContext(ctx, channelID, slack.MsgOptionText(text, false)); err != nil {
+		b.log.Error("Failed to reply to feedback", zap.Error(errs.NewSlackAPIError("chat.postMessage", err)))
+	}
+}
+
+// append writes an entry to the feedback file in the data dir
+func (b *Box) append(entry Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return &errs.StorageError{Op: "open", Path: b.path, Err: err}
+	}
+	defer func() { _ = f.Close() }()
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		return &errs.StorageError{Op: "write", Path: b.path, Err: err}
+	}
+	return nil
+}
+
+// Lookup finds a feedback entry by ID in the data dir
+func Lookup(dataDir, id string) (Entry, error) {
+	path := filepath.Join(dataDir, feedbackFile)
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Entry{}, ErrNotFound
+		}
+		return Entry{}, &errs.StorageError{Op: "open", Path: path, Err: err}
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Entry{}, &errs.StorageError{Op: "read", Path: path, Err: err}
+	}
+	return Entry{}, ErrNotFound
+}
+
+// newID returns a short random feedback ID, e.g. F1a2b3c4d
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "F" + hex.EncodeToString(b), nil
+}
+
+// Expire deletes feedback entries from before the cutoff, for retention sweeps
+func (b *Box) Expire(cutoff, purgeBefore time.Time) (retention.Expired, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	purged, err := retention.ExpireJSONL(b.path, cutoff)
+	return retention.Expired{Purged: purged}, err
+}
@@ -0,0 +1,108 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestSealReveal(t *testing.T) {
+	public, private, err := GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys() error = %v", err)
+	}
+	pk, err := ParsePublicKey(public)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	sealed, err := seal(pk, AuditRecord{ID: "F1", User: "U1"})
+	if err != nil {
+		t.Fatalf("seal() error = %v", err)
+	}
+	if strings.Contains(sealed, "U1") {
+		t.Errorf("sealed = %q, want the user hidden", sealed)
+	}
+
+	record, err := Reveal(private, sealed)
+	if err != nil {
+		t.Fatalf("Reveal() error = %v", err)
+	}
+	if record.ID != "F1" || record.User != "U1" {
+		t.Errorf("Reveal() = %+v, want the sealed record", record)
+	}
+
+	_, other, _ := GenerateKeys()
+	if _, err := Reveal(other, sealed); err == nil {
+		t.Error("Reveal() with another key succeeded, want an error")
+	}
+}
+
+func TestBox_ProcessEvent(t *testing.T) {
+	var relayed, replies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.FormValue("channel") == "CFEEDBACK" {
+			relayed = append(relayed, r.FormValue("text"))
+		} else {
+			replies = append(replies, r.FormValue("text"))
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "` + r.FormValue("channel") + `", "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	public, private, err := GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys() error = %v", err)
+	}
+	dir := t.TempDir()
+	b, err := New(zap.NewNop(), Config{
+		DataDir:        dir,
+		Channel:        "CFEEDBACK",
+		AuditPublicKey: public,
+		RateLimit:      1,
+		RateWindow:     time.Hour,
+	}, slacktest.New(server.URL))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	dm := func(user, channelType, text string) event.Event {
+		return event.Callback(&slackevents.MessageEvent{User: user, Channel: "D1", ChannelType: channelType, Text: text, TimeStamp: "1.0"})
+	}
+	b.processEvent(context.Background(), dm("U1", "channel", "feedback: not a DM"))
+	b.processEvent(context.Background(), dm("U1", "im", "hello"))
+	if len(relayed)+len(replies) != 0 {
+		t.Fatalf("posted %v %v, want channel messages and DMs without the prefix ignored", relayed, replies)
+	}
+
+	b.processEvent(context.Background(), dm("U1", "im", "Feedback: ship <!channel> & stuff"))
+	if len(relayed) != 1 || !strings.Contains(relayed[0], "ship &lt;!channel&gt; &amp; stuff") || strings.Contains(relayed[0], "U1") {
+		t.Fatalf("relayed = %v, want the escaped feedback without the sender", relayed)
+	}
+	b.processEvent(context.Background(), dm("U1", "im", "feedback: again"))
+	if len(relayed) != 1 || len(replies) != 2 || !strings.Contains(replies[1], "try again later") {
+		t.Fatalf("relayed = %v, replies = %v, want the second message rate limited", relayed, replies)
+	}
+
+	id := strings.Trim(strings.Fields(relayed[0])[3], "`")
+	entry, err := Lookup(dir, id)
+	if err != nil {
+		t.Fatalf("Lookup(%q) error = %v", id, err)
+	}
+	record, err := Reveal(private, entry.Sealed)
+	if err != nil || record.User != "U1" || record.ID != id {
+		t.Errorf("Reveal() = %+v, %v, want the sender of %s", record, err, id)
+	}
+	if _, err := Lookup(dir, "Fmissing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup() error = %v, want ErrNotFound", err)
+	}
+}
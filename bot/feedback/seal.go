@@ -0,0 +1,87 @@
+package feedback
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// The audit mapping is sealed with HPKE to a public key so the bot can write it but
+// only whoever holds the private key can read it
+var (
+	kem       = hpke.DHKEM(ecdh.X25519())
+	kdf       = hpke.HKDFSHA256()
+	aead      = hpke.ChaCha20Poly1305()
+	sealLabel = []byte("slackbot feedback audit")
+)
+
+// AuditRecord is who sent a piece of feedback, readable only with the private key
+type AuditRecord struct {
+	ID     string    `json:"id"`
+	User   string    `json:"user"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// GenerateKeys returns a base64 public key for the config and the private key used to
+// reveal senders
+func GenerateKeys() (publicKey, privateKey string, err error) {
+	key, err := kem.GenerateKey()
+	if err != nil {
+		return "", "", fmt.Errorf("generate audit key: %w", err)
+	}
+	priv, err := key.Bytes()
+	if err != nil {
+		return "", "", fmt.Errorf("encode audit key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// ParsePublicKey decodes a base64 audit public key
+func ParsePublicKey(s string) (hpke.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode audit public key: %w", err)
+	}
+	return kem.NewPublicKey(b)
+}
+
+// seal encrypts an audit record to the public key
+func seal(pk hpke.PublicKey, record AuditRecord) (string, error) {
+	plaintext, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("marshal audit record: %w", err)
+	}
+	sealed, err := hpke.Seal(pk, kdf, aead, sealLabel, plaintext)
+	if err != nil {
+		return "", fmt.Errorf("seal audit record: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Reveal decrypts a sealed audit record with the base64 private key
+func Reveal(privateKey, sealed string) (AuditRecord, error) {
+	b, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return AuditRecord{}, fmt.Errorf("decode audit private key: %w", err)
+	}
+	key, err := kem.NewPrivateKey(b)
+	if err != nil {
+		return AuditRecord{}, fmt.Errorf("parse audit private key: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return AuditRecord{}, fmt.Errorf("decode sealed record: %w", err)
+	}
+	plaintext, err := hpke.Open(key, kdf, aead, sealLabel, ciphertext)
+	if err != nil {
+		return AuditRecord{}, fmt.Errorf("open sealed record: %w", err)
+	}
+	var record AuditRecord
+	if err := json.Unmarshal(plaintext, &record); err != nil {
+		return AuditRecord{}, fmt.Errorf("decode audit record: %w", err)
+	}
+	return record, nil
+}
@@ -0,0 +1,117 @@
+package filescan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// errContentTooLarge stops a download that grows past the content limit
+var errContentTooLarge = errors.New("file content exceeds the download limit")
+
+// extension is the lowercase file extension without the dot, falling back to Slack's
+// file type for files without one
+func extension(f *slack.File) string {
+	if ext := strings.TrimPrefix(filepath.Ext(f.Name), "."); ext != "" {
+		return strings.ToLower(ext)
+	}
+	return strings.ToLower(f.Filetype)
+}
+
+// localChecks returns the reasons a file breaks the extension blocklist or size cap
+func localChecks(c Config, f *slack.File) []string {
+	var reasons []string
+	if ext := extension(f); ext != "" && slices.Contains(c.BlockedExtensions, ext) {
+		reasons = append(reasons, fmt.Sprintf("blocked file type .%s", ext))
+	}
+	if c.MaxSize > 0 && int64(f.Size) > c.MaxSize {
+		reasons = append(reasons, fmt.Sprintf("larger than %d bytes", c.MaxSize))
+	}
+	return reasons
+}
+
+// scanRequest is sent to the external scanner
+type scanRequest struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Filetype string `json:"filetype"`
+	Mimetype string `json:"mimetype"`
+	Size     int    `json:"size"`
+	User     string `json:"user"`
+	Channel  string `json:"channel"`
+	// Content is the base64 file content, omitted when downloads are off or the file is
+	// over the limit
+	Content string `json:"content,omitempty"`
+}
+
+// scanResponse is the scanner's verdict
+type scanResponse struct {
+	Block  *bool  `json:"block"`
+	Reason string `json:"reason"`
+}
+
+// scanner posts file metadata, and content when enabled, to an external webhook
+type scanner struct {
+	url    string
+	client *http.Client
+}
+
+// Scan returns the reason the scanner blocked the file, or "" when it's allowed
+func (s *scanner) Scan(ctx context.Context, req scanRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("marshal scan request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("create scan request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("call scanner webhook: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("scanner webhook returned %s", resp.Status)
+	}
+
+	var result scanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode scan response: %w", err)
+	}
+	if result.Block == nil {
+		return "", fmt.Errorf("scan response missing \"block\"")
+	}
+	if !*result.Block {
+		return "", nil
+	}
+	if result.Reason == "" {
+		return "blocked by the scanner", nil
+	}
+	return result.Reason, nil
+}
+
+// limitedBuffer fails writes past its limit so large downloads stop early
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.buf.Len()+len(p)) > b.limit {
+		return 0, errContentTooLarge
+	}
+	return b.buf.Write(p)
+}
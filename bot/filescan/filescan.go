@@ -0,0 +1,322 @@
+// Package filescan moderates files shared in configured channels. Uploads are checked
+// against an extension blocklist, a size cap and optionally an external scanner, and
+// violations are flagged or deleted with an entry in the mod log.
+package filescan
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/retention"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	modLogFile            = "modlog.jsonl"
+	defaultScannerTimeout = 10 * time.Second
+)
+
+// Actions taken on a file that fails a check
+const (
+	ActionFlag   = "flag"   // Post a notice in the channel
+	ActionDelete = "delete" // Delete the file, flagging it when Slack refuses
+)
+
+type slackService interface {
+	Client() *slack.Client
+	BotUserID() string
+}
+
+type ScannerConfig struct {
+	// URL receives file metadata as JSON and answers {"block": bool, "reason": string}
+	URL     string         `json:"url" yaml:"url"`
+	Timeout *time.Duration `json:"timeout" yaml:"timeout"`
+	// ContentLimit sends the base64 file content along for files up to this many bytes,
+	// 0 sends only metadata
+	ContentLimit *int64 `json:"content_limit" yaml:"content_limit"`
+}
+
+type FileConfig struct {
+	Channels          []string      `json:"channels" yaml:"channels"`
+	BlockedExtensions []string      `json:"blocked_extensions" yaml:"blocked_extensions"`
+	MaxSize           *int64        `json:"max_size" yaml:"max_size"`
+	Scanner           ScannerConfig `json:"scanner" yaml:"scanner"`
+	Action            *string       `json:"action" yaml:"action"`
+	// ModLogChannel also gets each mod log entry, which is always written to the data dir
+	ModLogChannel *string        `json:"mod_log_channel" yaml:"mod_log_channel"`
+	Persona       persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	DataDir           string
+	Channels          []string // Channel IDs whose uploads are checked
+	BlockedExtensions []string // Lowercase extensions without the dot, e.g. exe
+	MaxSize           int64    // Largest allowed file in bytes, 0 allows any size
+	ScannerURL        string
+	ScannerTimeout    time.Duration
+	ContentLimit      int64
+	Action            string // flag or delete
+	ModLogChannel     string
+	Persona           persona.Config // Name and icon notices are posted with
+}
+
+// ModLogEntry is one JSONL line written for each violating upload
+type ModLogEntry struct {
+	Time    time.Time `json:"time"`
+	Channel string    `json:"channel"`
+	User    string    `json:"user"`
+	FileID  string    `json:"file_id"`
+	Name    string    `json:"name"`
+	Size    int       `json:"size"`
+	Reasons []string  `json:"reasons"`
+	Action  string    `json:"action"` // What was done: flag or delete
+}
+
+// Scanner checks files shared in configured channels
+type Scanner struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	scanner     *scanner
+	path        string
+	mu          sync.Mutex // Serializes mod log writes
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Scanner {
+	f := &Scanner{
+		log:      log,
+		config:   c,
+		slack:    s,
+		path:     filepath.Join(c.DataDir, modLogFile),
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+	if c.ScannerURL != "" {
+		timeout := c.ScannerTimeout
+		if timeout <= 0 {
+			timeout = defaultScannerTimeout
+		}
+		f.scanner = &scanner{url: c.ScannerURL, client: &http.Client{Timeout: timeout}}
+	}
+	return f
+}
+
+// SetStatusTracker sets where the scanner reports its activity
+func (f *Scanner) SetStatusTracker(t *status.Tracker) {
+	f.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (f *Scanner) ProcessorType() string {
+	return "filescan"
+}
+
+func (f *Scanner) Start(ctx context.Context) error {
+	f.isConnected.Store(true)
+	go f.handleEvents(ctx)
+	f.log.Debug("File scanner started.", zap.Int("channels", len(f.config.Channels)))
+	return nil
+}
+
+func (f *Scanner) Stop(ctx context.Context) error {
+	if !f.isConnected.Load() {
+		return nil
+	}
+	close(f.stopCh)
+	f.isConnected.Store(false)
+	return nil
+}
+
+// PushEvent adds an event to be processed by the scanner
+func (f *Scanner) PushEvent(e event.Event) {
+	if !f.isConnected.Load() {
+		return
+	}
+
+	select {
+	case f.eventsCh <- e:
+	default:
+		f.log.Warn("File scan events channel full, dropping event.")
+		f.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (f *Scanner) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-f.eventsCh:
+			f.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (f *Scanner) processEvent(ctx context.Context, e event.Event) {
+	ev, ok := e.Data().(*slackevents.FileSharedEvent)
+	if !ok || !slices.Contains(f.config.Channels, ev.ChannelID) || ev.UserID == f.slack.BotUserID() {
+		return
+	}
+	f.status.Event()
+
+	file, _, _, err := f.slack.Client().GetFileInfoContext(ctx, ev.FileID, 0, 0)
+	if err != nil {
+		err = errs.NewSlackAPIError("files.info", err)
+		f.status.Error(err)
+		f.log.Error("Failed to look up shared file", zap.String("file", ev.FileID), zap.Error(err))
+		return
+	}
+
+	reasons := f.check(ctx, ev.ChannelID, ev.UserID, file)
+	if len(reasons) == 0 {
+		f.log.Debug("Shared file passed checks", zap.String("file", file.ID), zap.String("channel", ev.ChannelID))
+		return
+	}
+	f.enforce(ctx, ev.ChannelID, ev.UserID, file, reasons)
+}
+
+// check runs the local checks and then the scanner. Scanner failures are logged and the
+// file is judged on the local checks alone.
+func (f *Scanner) check(ctx context.Context, channelID, userID string, file *slack.File) []string {
+	reasons := localChecks(f.config, file)
+	if f.scanner == nil {
+		return reasons
+	}
+
+	req := scanRequest{
+		ID:       file.ID,
+		Name:     file.Name,
+		Filetype: file.Filetype,
+		Mimetype: file.Mimetype,
+		Size:     file.Size,
+		User:     userID,
+		Channel:  channelID,
+	}
+	if f.config.ContentLimit > 0 && int64(file.Size) <= f.config.ContentLimit && file.URLPrivateDownload != "" {
+		content := &limitedBuffer{limit: f.config.ContentLimit}
+		if err := f.slack.Client().GetFileContext(ctx, file.URLPrivateDownload, content); err != nil {
+			f.log.Warn("Failed to download file for scanning, sending metadata only",
+				zap.String("file", file.ID),
+				zap.Error(err))
+		} else {
+			req.Content = base64.StdEncoding.EncodeToString(content.buf.Bytes())
+		}
+	}
+
+	reason, err := f.scanner.Scan(ctx, req)
+	if err != nil {
+		f.status.Error(err)
+		f.log.Error("File scanner failed", zap.String("file", file.ID), zap.Error(err))
+		return reasons
+	}
+	if reason != "" {
+		reasons = append(reasons, reason)
+	}
+	return reasons
+}
+
+// enforce deletes or flags a violating file and records it in the mod log
+func (f *Scanner) enforce(ctx context.Context, channelID, userID string, file *slack.File, reasons []string) {
+	action := ActionFlag
+	if f.config.Action == ActionDelete {
+		if err := f.slack.Client().DeleteFileContext(ctx, file.ID); err != nil {
+			err = errs.NewSlackAPIError("files.delete", err)
+			f.status.Error(err)
+			f.log.Error("Failed to delete violating file, flagging it instead",
+				zap.String("file", file.ID),
+				zap.Error(err))
+		} else {
+			action = ActionDelete
+		}
+	}
+	f.log.Info("Shared file failed checks",
+		zap.String("file", file.ID),
+		zap.String("channel", channelID),
+		zap.String("user", userID),
+		zap.Strings("reasons", reasons),
+		zap.String("action", action))
+
+	entry := ModLogEntry{
+		Time:    time.Now(),
+		Channel: channelID,
+		User:    userID,
+		FileID:  file.ID,
+		Name:    file.Name,
+		Size:    file.Size,
+		Reasons: reasons,
+		Action:  action,
+	}
+	if err := f.writeModLog(entry); err != nil {
+		f.log.Error("Failed to write mod log entry", zap.Error(err))
+	}
+
+	why := strings.Join(reasons, ", ")
+	notice := fmt.Sprintf("⚠️ <@%s> shared *%s*, which was flagged: %s", userID, file.Name, why)
+	if action == ActionDelete {
+		notice = fmt.Sprintf("🗑️ Removed *%s* shared by <@%s>: %s", file.Name, userID, why)
+	}
+	f.post(ctx, channelID, notice)
+	if f.config.ModLogChannel != "" {
+		f.post(ctx, f.config.ModLogChannel, fmt.Sprintf("%s (%s in <#%s>, file %s)", notice, action, channelID, file.ID))
+	}
+}
+
+func (f *Scanner) post(ctx context.Context, channelID, text string) {
+	_, _, err := f.slack.Client().PostMessageContext(ctx, channelID,
+		slack.MsgOptionText(text, false),
+		f.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		f.status.Error(err)
+		f.log.Error("Failed to post file scan notice", zap.String("channel", channelID), zap.Error(err))
+		return
+	}
+	f.status.Posted()
+}
+
+// writeModLog appends an entry to the mod log in the data dir
+func (f *Scanner) writeModLog(entry ModLogEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return &errs.StorageError{Op: "open", Path: f.path, Err: err}
+	}
+	defer func() { _ = file.Close() }()
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		return &errs.StorageError{Op: "write", Path: f.path, Err: err}
+	}
+	return nil
+}
+
+// Expire deletes mod log entries from before the cutoff, for retention sweeps
+func (f *Scanner) Expire(cutoff, purgeBefore time.Time) (retention.Expired, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	purged, err := retention.ExpireJSONL(f.path, cutoff)
+	return retention.Expired{Purged: purged}, err
+}
@@ -0,0 +1,110 @@
+package filescan
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestLocalChecks(t *testing.T) {
+	c := Config{BlockedExtensions: []string{"exe", "bat"}, MaxSize: 100}
+	tests := []struct {
+		name string
+		file slack.File
+		want int
+	}{
+		{"allowed", slack.File{Name: "notes.txt", Size: 10}, 0},
+		{"blocked extension", slack.File{Name: "setup.EXE", Size: 10}, 1},
+		{"file type without extension", slack.File{Name: "run", Filetype: "bat", Size: 10}, 1},
+		{"too large and blocked", slack.File{Name: "big.exe", Size: 1000}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := localChecks(c, &tt.file); len(got) != tt.want {
+				t.Errorf("localChecks() = %v, want %d reasons", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanner_ProcessEvent(t *testing.T) {
+	var scanned scanRequest
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&scanned)
+		_, _ = w.Write([]byte(`{"block": true, "reason": "malware"}`))
+	}))
+	defer webhook.Close()
+
+	var methods, notices []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		methods = append(methods, method)
+		switch method {
+		case "files.info":
+			_, _ = w.Write([]byte(`{"ok": true, "file": {"id": "F1", "name": "invoice.pdf", "filetype": "pdf", "size": 5,
+				"url_private_download": "` + server.URL + `/download/F1"}}`))
+		case "download/F1":
+			_, _ = w.Write([]byte("hello"))
+		case "chat.postMessage":
+			notices = append(notices, r.FormValue("channel")+": "+r.FormValue("text"))
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	f := New(zap.NewNop(), Config{
+		DataDir:       dir,
+		Channels:      []string{"C1"},
+		ScannerURL:    webhook.URL,
+		ContentLimit:  1024,
+		Action:        ActionDelete,
+		ModLogChannel: "CMOD",
+	}, slacktest.New(server.URL))
+
+	f.processEvent(context.Background(), event.Callback(&slackevents.FileSharedEvent{UserID: "UBOT", ChannelID: "C1", FileID: "F0"}))
+	f.processEvent(context.Background(), event.Callback(&slackevents.FileSharedEvent{UserID: "U1", ChannelID: "C2", FileID: "F0"}))
+	if len(methods) != 0 {
+		t.Fatalf("calls = %v, want the bot's files and other channels ignored", methods)
+	}
+
+	f.processEvent(context.Background(), event.Callback(&slackevents.FileSharedEvent{UserID: "U1", ChannelID: "C1", FileID: "F1"}))
+	want := []string{"files.info", "download/F1", "files.delete", "chat.postMessage", "chat.postMessage"}
+	if !slices.Equal(methods, want) {
+		t.Fatalf("calls = %v, want %v", methods, want)
+	}
+	if scanned.Content != "aGVsbG8=" || scanned.User != "U1" {
+		t.Errorf("scan request = %+v, want the file content and uploader", scanned)
+	}
+	if len(notices) != 2 || !strings.HasPrefix(notices[0], "C1: 🗑️ Removed *invoice.pdf*") || !strings.HasPrefix(notices[1], "CMOD: ") {
+		t.Errorf("notices = %v, want a removal notice in the channel and the mod log channel", notices)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, modLogFile))
+	if err != nil {
+		t.Fatalf("read mod log: %v", err)
+	}
+	var entry ModLogEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("decode mod log: %v", err)
+	}
+	if entry.FileID != "F1" || entry.Action != ActionDelete || !slices.Equal(entry.Reasons, []string{"malware"}) {
+		t.Errorf("mod log entry = %+v, want the deleted file and the scanner's reason", entry)
+	}
+}
@@ -0,0 +1,289 @@
+// Package handoff hands a thread over to people. "@bot get a human" pings the
+// responder group in the thread and the bot stays out of it until someone says
+// "@bot resume". Handoffs are recorded in the data dir so they survive restarts.
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	stateFile = "handoffs.json"
+	// retention is how long closed handoffs are kept in the record
+	retention = 30 * 24 * time.Hour
+)
+
+var (
+	// requestPattern matches a mention asking for a person, e.g. "<@U123> get a human"
+	requestPattern = regexp.MustCompile(`(?i)^\s*(<@[A-Z0-9]+>[\s,:]*)?(get|need|want)\s+(me\s+)?a\s+human\s*[?!.]*\s*$`)
+	// resumePattern matches a mention handing the thread back, e.g. "<@U123> resume"
+	resumePattern = regexp.MustCompile(`(?i)^\s*(<@[A-Z0-9]+>[\s,:]*)?resume\s*[?!.]*\s*$`)
+)
+
+// IsRequest reports whether the message text asks for a human or hands the thread back
+func IsRequest(text string) bool {
+	return requestPattern.MatchString(text) || resumePattern.MatchString(text)
+}
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type FileConfig struct {
+	// Responders is the user group ID pinged when someone asks for a human, e.g. S0123456789
+	Responders *string        `json:"responders" yaml:"responders"`
+	Persona    persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	DataDir    string
+	Responders string         // User group ID
+	Persona    persona.Config // Name and icon handoff messages are posted with
+}
+
+// Handoff is a thread handed over to people
+type Handoff struct {
+	Channel     string    `json:"channel"`
+	ThreadTS    string    `json:"thread_ts"`
+	RequestedBy string    `json:"requested_by"`
+	OpenedAt    time.Time `json:"opened_at"`
+	ClosedBy    string    `json:"closed_by,omitempty"`
+	ClosedAt    time.Time `json:"closed_at,omitzero"`
+}
+
+func (h Handoff) key() string {
+	return h.Channel + "/" + h.ThreadTS
+}
+
+// Desk opens and closes handoffs and answers whether the bot should stay out of a thread
+type Desk struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	path        string
+	handoffs    map[string]Handoff // channel/thread -> latest handoff
+	mu          sync.Mutex
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Desk {
+	d := &Desk{
+		log:      log,
+		config:   c,
+		slack:    s,
+		path:     filepath.Join(c.DataDir, stateFile),
+		handoffs: make(map[string]Handoff),
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+	d.load()
+	return d
+}
+
+// SetStatusTracker sets where the desk reports its activity
+func (d *Desk) SetStatusTracker(t *status.Tracker) {
+	d.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (d *Desk) ProcessorType() string {
+	return "handoff"
+}
+
+func (d *Desk) Start(ctx context.Context) error {
+	d.isConnected.Store(true)
+	go d.handleEvents(ctx)
+	return nil
+}
+
+func (d *Desk) Stop(ctx context.Context) error {
+	if !d.isConnected.Load() {
+		return nil
+	}
+	close(d.stopCh)
+	d.isConnected.Store(false)
+	return nil
+}
+
+// Active reports whether the thread was handed to a human and not yet resumed
+func (d *Desk) Active(channelID, threadTS string) bool {
+	if threadTS == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.handoffs[channelID+"/"+threadTS]
+	return ok && h.ClosedAt.IsZero()
+}
+
+// PushEvent adds an event to be processed by the desk
+func (d *Desk) PushEvent(e event.Event) {
+	if !d.isConnected.Load() {
+		return
+	}
+
+	select {
+	case d.eventsCh <- e:
+	default:
+		d.log.Warn("Handoff events channel full, dropping event.")
+		d.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (d *Desk) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-d.eventsCh:
+			d.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (d *Desk) processEvent(ctx context.Context, e event.Event) {
+	if _, ok := e.Data().(*slackevents.AppMentionEvent); !ok || e.FromBot() {
+		return
+	}
+	// A request in the channel starts a thread on that message
+	threadTS := e.ThreadTS
+	if threadTS == "" {
+		threadTS = e.TS
+	}
+	switch {
+	case requestPattern.MatchString(e.Text):
+		d.status.Event()
+		d.open(ctx, e.Channel, threadTS, e.User)
+	case resumePattern.MatchString(e.Text):
+		d.status.Event()
+		d.close(ctx, e.Channel, threadTS, e.User)
+	}
+}
+
+func (d *Desk) open(ctx context.Context, channelID, threadTS, userID string) {
+	h := Handoff{Channel: channelID, ThreadTS: threadTS, RequestedBy: userID, OpenedAt: d.now()}
+	d.mu.Lock()
+	if current, ok := d.handoffs[h.key()]; ok && current.ClosedAt.IsZero() {
+		d.mu.Unlock()
+		d.post(ctx, channelID, threadTS, "Someone has already been asked to take over here.")
+		return
+	}
+	d.handoffs[h.key()] = h
+	d.save()
+	d.mu.Unlock()
+
+	d.log.Info("Thread handed off",
+		zap.String("channel", channelID),
+		zap.String("thread", threadTS),
+		zap.String("user", userID))
+	d.post(ctx, channelID, threadTS, fmt.Sprintf(
+		"🙋 <!subteam^%s>, <@%s> asked for a human in this thread. I'll stay out of it until someone says \"resume\" to me.",
+		d.config.Responders, userID))
+}
+
+func (d *Desk) close(ctx context.Context, channelID, threadTS, userID string) {
+	d.mu.Lock()
+	h, ok := d.handoffs[channelID+"/"+threadTS]
+	if !ok || !h.ClosedAt.IsZero() {
+		d.mu.Unlock()
+		d.log.Debug("No handoff to resume", zap.String("channel", channelID), zap.String("thread", threadTS))
+		return
+	}
+	h.ClosedBy, h.ClosedAt = userID, d.now()
+	d.handoffs[h.key()] = h
+	d.save()
+	d.mu.Unlock()
+
+	d.log.Info("Thread handed back",
+		zap.String("channel", channelID),
+		zap.String("thread", threadTS),
+		zap.String("user", userID),
+		zap.Duration("after", h.ClosedAt.Sub(h.OpenedAt)))
+	d.post(ctx, channelID, threadTS, fmt.Sprintf("👋 Thanks <@%s>, I'm back.", userID))
+}
+
+func (d *Desk) post(ctx context.Context, channelID, threadTS, text string) {
+	_, _, err := d.slack.Client().PostMessageContext(ctx, channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(threadTS),
+		d.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		d.status.PostFailed(channelID, err)
+		d.log.Error("Failed to post handoff message", zap.String("channel", channelID), zap.Error(err))
+		return
+	}
+	d.status.Posted()
+}
+
+func (d *Desk) load() {
+	data, err := os.ReadFile(d.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		d.log.Error("Failed to read handoff state", zap.Error(err), zap.String("path", d.path))
+		return
+	}
+	var handoffs []Handoff
+	if err := json.Unmarshal(data, &handoffs); err != nil {
+		d.log.Error("Failed to unmarshal handoff state", zap.Error(err), zap.String("path", d.path))
+		return
+	}
+	for _, h := range handoffs {
+		d.handoffs[h.key()] = h
+	}
+}
+
+// save writes open handoffs and recently closed ones; callers must hold mu
+func (d *Desk) save() {
+	cutoff := d.now().Add(-retention)
+	handoffs := make([]Handoff, 0, len(d.handoffs))
+	for key, h := range d.handoffs {
+		if !h.ClosedAt.IsZero() && h.ClosedAt.Before(cutoff) {
+			delete(d.handoffs, key)
+			continue
+		}
+		handoffs = append(handoffs, h)
+	}
+	slices.SortFunc(handoffs, func(a, b Handoff) int { return strings.Compare(a.key(), b.key()) })
+	data, err := json.Marshal(handoffs)
+	if err != nil {
+		d.log.Error("Failed to marshal handoff state", zap.Error(err))
+		return
+	}
+	tempFile := d.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		d.log.Error("Failed to save handoff state", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, d.path); err != nil {
+		d.log.Error("Failed to save handoff state", zap.Error(&errs.StorageError{Op: "rename", Path: d.path, Err: err}))
+	}
+}
@@ -0,0 +1,81 @@
+package handoff
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestIsRequest(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"<@UBOT> get a human", true},
+		{"<@UBOT>, I need a human!", false},
+		{"<@UBOT> need a human please", false},
+		{"<@UBOT> need a human", true},
+		{"<@UBOT> get me a human?", true},
+		{"<@UBOT> resume", true},
+		{"<@UBOT> resume the build", false},
+		{"<@UBOT> how's it going", false},
+	}
+	for _, tt := range tests {
+		if got := IsRequest(tt.text); got != tt.want {
+			t.Errorf("IsRequest(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDesk_Handoff(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts = append(posts, r.FormValue("thread_ts")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(slacktest.Posted))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	s := slacktest.New(server.URL)
+	d := New(zap.NewNop(), Config{DataDir: dir, Responders: "SHELP"}, s)
+	mention := func(user, text, ts, threadTS string) event.Event {
+		return event.Callback(&slackevents.AppMentionEvent{User: user, Channel: "C1", Text: text, TimeStamp: ts, ThreadTimeStamp: threadTS})
+	}
+
+	d.processEvent(context.Background(), mention("U1", "<@UBOT> get a human", "1.1", ""))
+	if !d.Active("C1", "1.1") {
+		t.Fatal("Active() = false, want the request's message to start a handed-off thread")
+	}
+	if len(posts) != 1 || !strings.HasPrefix(posts[0], "1.1: ") || !strings.Contains(posts[0], "<!subteam^SHELP>") {
+		t.Fatalf("posts = %v, want the responders pinged in the thread", posts)
+	}
+
+	d.processEvent(context.Background(), mention("U1", "<@UBOT> get a human", "1.2", "1.1"))
+	if len(posts) != 2 || strings.Contains(posts[1], "subteam") {
+		t.Errorf("posts = %v, want a second request answered without another ping", posts)
+	}
+
+	// Handoffs survive a restart
+	if !New(zap.NewNop(), Config{DataDir: dir, Responders: "SHELP"}, s).Active("C1", "1.1") {
+		t.Error("Active() = false after reloading, want the handoff restored")
+	}
+
+	d.processEvent(context.Background(), mention("U2", "<@UBOT> resume", "1.3", "1.1"))
+	if d.Active("C1", "1.1") {
+		t.Error("Active() = true after resume, want the thread handed back")
+	}
+	if h := d.handoffs["C1/1.1"]; h.RequestedBy != "U1" || h.ClosedBy != "U2" {
+		t.Errorf("handoff = %+v, want who asked and who resumed recorded", h)
+	}
+	if d.Active("C1", "") {
+		t.Error("Active() = true outside a thread")
+	}
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/status"
+)
+
+// Processor is an event processor and whether it's receiving events
+type Processor struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SetConfigView sets what /admin/config reports as the effective config. It should leave
+// out credentials.
+func (h *Server) SetConfigView(view func() (any, error)) {
+	h.configView = view
+}
+
+func (h *Server) registerAdminEndpoints() {
+	h.HandleAdmin("GET /admin/status", h.adminStatus)
+	h.HandleAdmin("GET /admin/config", h.adminConfig)
+	h.HandleAdmin("POST /admin/features/{name}/{action}", h.adminFeature)
+}
+
+// Processors lists the registered event processors in registration order
+func (h *Server) Processors() []Processor {
+	h.processorsMu.RLock()
+	defer h.processorsMu.RUnlock()
+	processors := make([]Processor, 0, len(h.slackEventProcessors))
+	for _, p := range h.slackEventProcessors {
+		name := p.ProcessorType()
+		processors = append(processors, Processor{Name: name, Enabled: !h.disabled[name]})
+	}
+	return processors
+}
+
+// SetProcessorEnabled stops or resumes dispatching events to a processor until the bot
+// restarts. The processor keeps running, so anything it does on a schedule continues.
+func (h *Server) SetProcessorEnabled(name string, enabled bool) error {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	if !slices.ContainsFunc(h.slackEventProcessors, func(p slackEventProcessor) bool { return p.ProcessorType() == name }) {
+		return fmt.Errorf("no event processor %q is running", name)
+	}
+	if enabled {
+		delete(h.disabled, name)
+	} else {
+		if h.disabled == nil {
+			h.disabled = make(map[string]bool)
+		}
+		h.disabled[name] = true
+	}
+	return nil
+}
+
+func (h *Server) adminStatus(w http.ResponseWriter, r *http.Request) {
+	features := []status.FeatureStatus{}
+	if h.statusRegistry != nil {
+		features = h.statusRegistry.Snapshot()
+	}
+	response := map[string]any{
+		"processors": h.Processors(),
+		"features":   features,
+		"ready":      h.isReady.Load(),
+		"time":       time.Now().Format(time.RFC3339),
+	}
+	if h.leadership != nil {
+		response["leader"] = h.leadership()
+	}
+	writeAdminJSON(w, http.StatusOK, response)
+}
+
+func (h *Server) adminConfig(w http.ResponseWriter, r *http.Request) {
+	if h.configView == nil {
+		http.Error(w, "Config is not available.", http.StatusNotFound)
+		return
+	}
+	config, err := h.configView()
+	if err != nil {
+		h.log.Error("Failed to report config", zap.Error(err))
+		http.Error(w, "Failed to report config.", http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, config)
+}
+
+func (h *Server) adminFeature(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var enabled bool
+	switch r.PathValue("action") {
+	case "enable":
+		enabled = true
+	case "disable":
+	default:
+		http.Error(w, "Action must be enable or disable.", http.StatusNotFound)
+		return
+	}
+	if err := h.SetProcessorEnabled(name, enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	h.log.Info("Event processor toggled",
+		zap.String("type", name),
+		zap.Bool("enabled", enabled),
+		zap.String("remoteAddr", r.RemoteAddr))
+	writeAdminJSON(w, http.StatusOK, Processor{Name: name, Enabled: enabled})
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"sparkline": sparkline,
+	"sum":       sum,
+}).Parse(dashboardHTML))
+
+// DashboardSection is a table on /dashboard. Rows is called on every page load.
+type DashboardSection struct {
+	Title   string
+	Columns []string
+	Rows    func() [][]string
+	Empty   string // Shown instead of the table when there are no rows
+}
+
+// AddDashboardSection appends a table to the dashboard below the feature status
+func (h *Server) AddDashboardSection(s DashboardSection) {
+	h.dashboardSections = append(h.dashboardSections, s)
+}
+
+type dashboardCheck struct {
+	Name  string
+	Error string
+}
+
+type dashboardFeature struct {
+	Name      string
+	LastEvent string
+	LastPost  string
+	LastError string
+	Error     string
+	Activity  []int
+}
+
+type dashboardTable struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+	Empty   string
+}
+
+type dashboardData struct {
+	Time     string
+	Status   string
+	Checks   []dashboardCheck
+	Features []dashboardFeature
+	Sections []dashboardTable
+}
+
+// dashboard renders a read-only HTML overview for operators, behind the admin credentials
+func (h *Server) dashboard(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	data := dashboardData{
+		Time:   now.Format(time.RFC3339),
+		Status: "ok",
+	}
+	if h.isShuttingDown.Load() {
+		data.Status = "shutting down"
+	}
+	for _, hc := range h.healthChecks {
+		check := dashboardCheck{Name: hc.name}
+		if err := hc.check(); err != nil {
+			check.Error = err.Error()
+			if data.Status == "ok" {
+				data.Status = "degraded"
+			}
+		}
+		data.Checks = append(data.Checks, check)
+	}
+
+	ago := func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return now.Sub(*t).Round(time.Second).String() + " ago"
+	}
+	if h.statusRegistry != nil {
+		for _, s := range h.statusRegistry.Snapshot() {
+			feature := dashboardFeature{
+				Name:      s.Name,
+				LastEvent: ago(s.LastEvent),
+				LastPost:  ago(s.LastPost),
+				Activity:  s.Activity,
+			}
+			if s.LastError != nil {
+				feature.LastError = ago(s.LastError)
+				feature.Error = s.Error
+			}
+			data.Features = append(data.Features, feature)
+		}
+	}
+
+	for _, s := range h.dashboardSections {
+		data.Sections = append(data.Sections, dashboardTable{
+			Title:   s.Title,
+			Columns: s.Columns,
+			Rows:    s.Rows(),
+			Empty:   s.Empty,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		h.log.Error("Failed to render dashboard", zap.Error(err))
+	}
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws counts as block characters scaled to the largest count
+func sparkline(counts []int) string {
+	peak := slices.Max(append([]int{0}, counts...))
+	var b strings.Builder
+	for _, n := range counts {
+		i := 0
+		if peak > 0 {
+			i = n * (len(sparks) - 1) / peak
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+func sum(counts []int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
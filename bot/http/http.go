@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"sync"
@@ -11,29 +12,56 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/status"
 )
 
 const DefaultServerPort = 4200
 
 type slackService interface {
 	VerifyRequest(http.Header, []byte) error
+	VerificationFailures() map[string]uint64
 }
 
 type Config struct {
-	ServerPort     uint32
-	SlackEventPath string // Path for the Slack events API endpoint
+	ServerPort            uint32
+	SlackEventPath        string // Path for the Slack events API endpoint
+	SlackInteractionsPath string // Path for the Slack interactivity endpoint
+	SlackCommandsPath     string // Path for the Slack slash commands endpoint
+
+	// Auth holds credentials per route group, see RouteGroups
+	Auth map[string]AuthConfig
+	// AllowedTeamIDs restricts events and interactions to these workspace or Enterprise
+	// Grid IDs. Empty allows any team with a valid signature.
+	AllowedTeamIDs []string
 }
 
 type Server struct {
-	log                  *zap.Logger
-	config               Config
-	server               *http.Server
-	serveMux             *http.ServeMux
-	isShuttingDown       atomic.Bool
-	isReady              atomic.Bool
-	slack                slackService
-	slackEventProcessors []slackEventProcessor
-	serverMu             sync.RWMutex // Protects server field
+	log                   *zap.Logger
+	config                Config
+	authMu                sync.RWMutex // Guards config.Auth, which changes with the config
+	server                *http.Server
+	serveMux              *http.ServeMux
+	isShuttingDown        atomic.Bool
+	isReady               atomic.Bool
+	slack                 slackService
+	slackEventProcessors  []slackEventProcessor
+	interactionProcessors []slackInteractionProcessor
+	blockActionHandlers   map[string]BlockActionHandler    // action ID -> handler
+	viewHandlers          map[string]ViewSubmissionHandler // view callback ID -> handler
+	commandProcessors     map[string]slackCommandProcessor // subcommand -> processor
+	eventFilters          []slackEventFilter
+	eventPublisher        slackEventPublisher
+	healthChecks          []healthCheck
+	teamMu                sync.Mutex
+	teamMismatches        map[string]uint64 // team ID -> dropped requests
+	disabled              map[string]bool   // Processors toggled off through the admin API
+	processorsMu          sync.RWMutex      // Guards the processors, which change when features restart
+	statusRegistry        statusRegistry
+	leadership            func() any
+	dashboardSections     []DashboardSection
+	configView            func() (any, error)
+	serverMu              sync.RWMutex // Protects server field
 }
 
 func NewServer(log *zap.Logger, config Config, slack slackService) *Server {
@@ -45,6 +73,8 @@ func NewServer(log *zap.Logger, config Config, slack slackService) *Server {
 	}
 	h.registerHealthEndpoints()
 	h.registerSlackEndpoints()
+	h.HandleAdmin("/dashboard", h.dashboard)
+	h.registerAdminEndpoints()
 	return h
 }
 
@@ -54,7 +84,7 @@ func (h *Server) Run(ctx context.Context) error {
 		port = DefaultServerPort
 	}
 	addr := fmt.Sprintf(":%d", port)
-	
+
 	server := &http.Server{
 		Addr:              addr,
 		Handler:           h.serveMux,
@@ -66,7 +96,7 @@ func (h *Server) Run(ctx context.Context) error {
 			return ctx
 		},
 	}
-	
+
 	h.serverMu.Lock()
 	h.server = server
 	h.serverMu.Unlock()
@@ -94,17 +124,48 @@ func (h *Server) Shutdown(ctx context.Context) error {
 	h.serverMu.RLock()
 	server := h.server
 	h.serverMu.RUnlock()
-	
+
 	if server == nil {
 		return nil
 	}
 	return server.Shutdown(ctx)
 }
 
+// healthCheck is a named dependency check reported by the health endpoint
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// RegisterHealthCheck adds a check to the health endpoint. A failing check reports the
+// service as degraded without failing the probe, since the process itself is healthy.
+func (h *Server) RegisterHealthCheck(name string, check func() error) {
+	h.healthChecks = append(h.healthChecks, healthCheck{name: name, check: check})
+}
+
+// statusRegistry reports per-feature activity
+type statusRegistry interface {
+	Snapshot() []status.FeatureStatus
+	WriteMetrics(w io.Writer) error
+}
+
+// SetStatusRegistry exposes feature activity on /status, /metrics and in the /health detail
+func (h *Server) SetStatusRegistry(r statusRegistry) {
+	h.statusRegistry = r
+}
+
+// SetLeadership adds this replica's leader election status to /health, so a probe or
+// operator can tell which replica is responding to events
+func (h *Server) SetLeadership(report func() any) {
+	h.leadership = report
+}
+
 func (h *Server) registerHealthEndpoints() {
-	h.serveMux.HandleFunc("/health", h.health)
-	h.serveMux.HandleFunc("/healthz", h.healthz)
-	h.serveMux.HandleFunc("/ready", h.ready)
+	h.serveMux.HandleFunc("/health", h.withAuth(RouteGroupHealth, h.health))
+	h.serveMux.HandleFunc("/healthz", h.withAuth(RouteGroupHealth, h.healthz))
+	h.serveMux.HandleFunc("/ready", h.withAuth(RouteGroupHealth, h.ready))
+	h.serveMux.HandleFunc("/status", h.withAuth(RouteGroupHealth, h.status))
+	h.serveMux.HandleFunc("/metrics", h.withAuth(RouteGroupHealth, h.metrics))
 }
 
 func (h *Server) health(w http.ResponseWriter, r *http.Request) {
@@ -118,13 +179,67 @@ func (h *Server) health(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"status": "ok",
+	healthStatus := "ok"
+	failures := make(map[string]map[string]any)
+	for _, hc := range h.healthChecks {
+		if err := hc.check(); err != nil {
+			healthStatus = "degraded"
+			failures[hc.name] = map[string]any{
+				"error":     err.Error(),
+				"category":  errs.Category(err),
+				"retryable": errs.Retryable(err),
+			}
+		}
+	}
+
+	response := map[string]any{
+		"status": healthStatus,
 		"time":   time.Now().Format(time.RFC3339),
+	}
+	if len(failures) > 0 {
+		response["checks"] = failures
+	}
+	if h.statusRegistry != nil {
+		response["features"] = h.statusRegistry.Snapshot()
+	}
+	if h.leadership != nil {
+		response["leader"] = h.leadership()
+	}
+	if rejected := h.slack.VerificationFailures(); len(rejected) > 0 {
+		response["slack_verification_failures"] = rejected
+	}
+	if mismatches := h.TeamMismatches(); len(mismatches) > 0 {
+		response["slack_team_mismatches"] = mismatches
+	}
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (h *Server) status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	features := []status.FeatureStatus{}
+	if h.statusRegistry != nil {
+		features = h.statusRegistry.Snapshot()
+	}
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"features": features,
+		"time":     time.Now().Format(time.RFC3339),
 	})
 }
 
+// metrics serves feature counters for Prometheus to scrape
+func (h *Server) metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if h.statusRegistry == nil {
+		return
+	}
+	if err := h.statusRegistry.WriteMetrics(w); err != nil {
+		h.log.Warn("Failed to write metrics", zap.Error(err))
+	}
+}
+
 func (h *Server) healthz(w http.ResponseWriter, r *http.Request) {
 	if h.isShuttingDown.Load() { // allow draining by degrading readiness probe
 		h.log.Error("Health check failed", zap.String("remoteAddr", r.RemoteAddr))
@@ -3,18 +3,26 @@ package http
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/status"
 )
 
 // mockSlackService for testing
 type mockSlackService struct {
 	shouldVerifyFail bool
+	failures         map[string]uint64
 }
 
 func (m *mockSlackService) VerifyRequest(headers http.Header, body []byte) error {
@@ -24,21 +32,38 @@ func (m *mockSlackService) VerifyRequest(headers http.Header, body []byte) error
 	return nil
 }
 
+func (m *mockSlackService) VerificationFailures() map[string]uint64 {
+	return m.failures
+}
+
 // mockSlackEventProcessor for testing
 type mockSlackEventProcessor struct {
 	processEventCalled bool
 	lastEvent          any
 }
 
-func (m *mockSlackEventProcessor) PushEvent(event slackevents.EventsAPIEvent) {
+func (m *mockSlackEventProcessor) PushEvent(e event.Event) {
 	m.processEventCalled = true
-	m.lastEvent = event
+	m.lastEvent = e
 }
 
 func (m *mockSlackEventProcessor) ProcessorType() string {
 	return "mock"
 }
 
+// mockInteractionProcessor for testing
+type mockInteractionProcessor struct {
+	lastCallback *slack.InteractionCallback
+}
+
+func (m *mockInteractionProcessor) PushInteraction(callback slack.InteractionCallback) {
+	m.lastCallback = &callback
+}
+
+func (m *mockInteractionProcessor) ProcessorType() string {
+	return "mock"
+}
+
 func TestNewServer(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	config := Config{
@@ -116,6 +141,125 @@ func TestServer_HealthEndpoints(t *testing.T) {
 	}
 }
 
+func TestServer_HealthDegraded(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{}, &mockSlackService{})
+	server.RegisterHealthCheck("slack", func() error { return errors.New("circuit open") })
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Degraded health should still return 200, got %d", w.Code)
+	}
+	var body struct {
+		Status string `json:"status"`
+		Checks map[string]struct {
+			Error    string `json:"error"`
+			Category string `json:"category"`
+		} `json:"checks"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if body.Status != "degraded" || body.Checks["slack"].Error != "circuit open" {
+		t.Errorf("Unexpected health response: %+v", body)
+	}
+	if body.Checks["slack"].Category != "unknown" {
+		t.Errorf("Expected uncategorized error, got %q", body.Checks["slack"].Category)
+	}
+}
+
+func TestServer_HealthVerificationFailures(t *testing.T) {
+	mockSlack := &mockSlackService{failures: map[string]uint64{"replayed": 2}}
+	server := NewServer(zaptest.NewLogger(t), Config{}, mockSlack)
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+
+	var body struct {
+		Status   string            `json:"status"`
+		Failures map[string]uint64 `json:"slack_verification_failures"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if body.Status != "ok" || body.Failures["replayed"] != 2 {
+		t.Errorf("Unexpected health response: %+v", body)
+	}
+}
+
+func TestServer_HealthLeadership(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{}, &mockSlackService{})
+	server.SetLeadership(func() any { return map[string]any{"id": "replica-a", "leader": true} })
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+
+	var body struct {
+		Leader struct {
+			ID     string `json:"id"`
+			Leader bool   `json:"leader"`
+		} `json:"leader"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if body.Leader.ID != "replica-a" || !body.Leader.Leader {
+		t.Errorf("Unexpected health leadership: %+v", body.Leader)
+	}
+}
+
+func TestServer_StatusEndpoint(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{}, &mockSlackService{})
+	registry := status.NewRegistry()
+	registry.Feature("chat").Posted()
+	server.SetStatusRegistry(registry)
+
+	for _, path := range []string{"/status", "/health"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		server.serveMux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s returned %d", path, w.Code)
+		}
+		var body struct {
+			Features []status.FeatureStatus `json:"features"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode %s response: %v", path, err)
+		}
+		if len(body.Features) != 1 || body.Features[0].Name != "chat" || body.Features[0].LastPost == nil {
+			t.Errorf("Unexpected %s features: %+v", path, body.Features)
+		}
+	}
+}
+
+func TestServer_MetricsEndpoint(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{}, &mockSlackService{})
+	registry := status.NewRegistry()
+	registry.Feature("chat").Dropped("C123", status.DropQueueFull)
+	server.SetStatusRegistry(registry)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("/metrics returned %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	want := `slackbot_events_dropped_total{feature="chat",channel="C123",reason="queue_full"} 1`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("/metrics missing %q in:\n%s", want, w.Body.String())
+	}
+}
+
 func TestServer_SlackEventsEndpoint(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	config := Config{
@@ -218,6 +362,197 @@ func TestServer_EventProcessing(t *testing.T) {
 	}
 }
 
+func TestServer_InteractionProcessing(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	config := Config{
+		ServerPort:            8080,
+		SlackInteractionsPath: "/slack/interactions",
+	}
+	mockSlack := &mockSlackService{}
+	server := NewServer(logger, config, mockSlack)
+
+	processor := &mockInteractionProcessor{}
+	server.RegisterInteractionProcessor(processor)
+
+	payload := `{"type": "block_actions", "user": {"id": "U123"}, "actions": [{"action_id": "purge", "block_id": "b1", "value": "U123"}]}`
+	form := url.Values{"payload": {payload}}
+	req := httptest.NewRequest("POST", "/slack/interactions", bytes.NewBufferString(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	server.serveMux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Interaction processing should return 200, got %d", w.Code)
+	}
+	if processor.lastCallback == nil {
+		t.Fatal("Interaction processor should have been called")
+	}
+	if processor.lastCallback.User.ID != "U123" {
+		t.Errorf("Expected user U123, got %q", processor.lastCallback.User.ID)
+	}
+	actions := processor.lastCallback.ActionCallback.BlockActions
+	if len(actions) != 1 || actions[0].ActionID != "purge" {
+		t.Errorf("Expected a single purge action, got %+v", actions)
+	}
+}
+
+func TestServer_InteractionEndpoint_VerificationFail(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	server := NewServer(logger, Config{}, &mockSlackService{shouldVerifyFail: true})
+
+	processor := &mockInteractionProcessor{}
+	server.RegisterInteractionProcessor(processor)
+
+	req := httptest.NewRequest("POST", "/api/slack/interactions", bytes.NewBufferString("payload={}"))
+	w := httptest.NewRecorder()
+
+	server.serveMux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Unverified interaction should return 401, got %d", w.Code)
+	}
+	if processor.lastCallback != nil {
+		t.Error("Interaction processor should not be called for unverified requests")
+	}
+}
+
+type denyAllFilter struct{}
+
+func (f denyAllFilter) AllowEvent(event.Event) bool { return false }
+
+func TestServer_EventFilter(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	config := Config{
+		ServerPort:     8080,
+		SlackEventPath: "/slack/events",
+	}
+	server := NewServer(logger, config, &mockSlackService{})
+
+	processor := &mockSlackEventProcessor{}
+	server.RegisterEventProcessor(processor)
+	server.AddEventFilter(denyAllFilter{})
+
+	eventBody := `{"type": "event_callback", "event": {"type": "message", "text": "hello"}}`
+	req := httptest.NewRequest("POST", "/slack/events", bytes.NewBufferString(eventBody))
+	w := httptest.NewRecorder()
+
+	server.serveMux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Filtered events should still return 200, got %d", w.Code)
+	}
+	if processor.processEventCalled {
+		t.Error("Event processor should not be called for filtered events")
+	}
+}
+
+func TestServer_TeamFilter(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	config := Config{
+		SlackEventPath:        "/slack/events",
+		SlackInteractionsPath: "/slack/interactions",
+		AllowedTeamIDs:        []string{"T1", "E1"},
+	}
+	server := NewServer(logger, config, &mockSlackService{})
+
+	processor := &mockSlackEventProcessor{}
+	server.RegisterEventProcessor(processor)
+	interactions := &mockInteractionProcessor{}
+	server.RegisterInteractionProcessor(interactions)
+
+	tests := []struct {
+		name  string
+		body  string
+		allow bool
+	}{
+		{"allowed team", `{"type": "event_callback", "team_id": "T1", "event": {"type": "message"}}`, true},
+		{"allowed enterprise", `{"type": "event_callback", "team_id": "T9", "enterprise_id": "E1", "event": {"type": "message"}}`, true},
+		{"other team", `{"type": "event_callback", "team_id": "T2", "event": {"type": "message"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor.processEventCalled = false
+			req := httptest.NewRequest("POST", "/slack/events", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			server.serveMux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected 200, got %d", w.Code)
+			}
+			if processor.processEventCalled != tt.allow {
+				t.Errorf("Event dispatched = %v, want %v", processor.processEventCalled, tt.allow)
+			}
+		})
+	}
+
+	form := url.Values{"payload": {`{"type": "block_actions", "team": {"id": "T2"}, "user": {"id": "U1"}}`}}
+	req := httptest.NewRequest("POST", "/slack/interactions", bytes.NewBufferString(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.serveMux.ServeHTTP(httptest.NewRecorder(), req)
+	if interactions.lastCallback != nil {
+		t.Error("Interaction from another team should not be dispatched")
+	}
+
+	if got := server.TeamMismatches()["T2"]; got != 2 {
+		t.Errorf("TeamMismatches()[T2] = %d, want 2", got)
+	}
+}
+
+type mockCommandProcessor struct {
+	args []string
+}
+
+func (m *mockCommandProcessor) Subcommand() string { return "incident" }
+
+func (m *mockCommandProcessor) HandleCommand(_ context.Context, _ slack.SlashCommand, args []string) string {
+	m.args = args
+	return "handled"
+}
+
+func TestServer_SlackCommands(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	server := NewServer(logger, Config{}, &mockSlackService{})
+
+	processor := &mockCommandProcessor{}
+	server.RegisterCommandProcessor(processor)
+
+	tests := []struct {
+		text     string
+		wantText string
+		wantArgs []string
+	}{
+		{"incident start 2h", "handled", []string{"start", "2h"}},
+		{"unknown", "Usage: /slackbot <incident>", nil},
+		{"", "Usage: /slackbot <incident>", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			processor.args = nil
+			form := url.Values{"command": {"/slackbot"}, "text": {tt.text}, "user_id": {"U1"}, "channel_id": {"C1"}}
+			req := httptest.NewRequest("POST", "/api/slack/commands", bytes.NewBufferString(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			server.serveMux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", w.Code)
+			}
+			var resp map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp["text"] != tt.wantText || resp["response_type"] != "ephemeral" {
+				t.Errorf("Response = %v, want ephemeral %q", resp, tt.wantText)
+			}
+			if len(processor.args) != len(tt.wantArgs) {
+				t.Errorf("Args = %v, want %v", processor.args, tt.wantArgs)
+			}
+		})
+	}
+}
+
 func TestServer_BeginShutdown(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	config := Config{
@@ -344,3 +679,381 @@ func BenchmarkServer_SlackEvents(b *testing.B) {
 		server.serveMux.ServeHTTP(w, req)
 	}
 }
+
+// eventPathBody is a message event like Slack delivers it, with the envelope fields
+// parsing has to get through
+const eventPathBody = `{"token": "x", "team_id": "T1", "api_app_id": "A1", "type": "event_callback",
+	"event_id": "Ev1", "event_time": 1700000000, "authorizations": [{"user_id": "UBOT", "is_bot": true}],
+	"event": {"type": "message", "user": "U1", "channel": "C1", "channel_type": "channel",
+	"text": "deploy friday", "ts": "1700000000.000100", "event_ts": "1700000000.000100"}}`
+
+// Allocation budget for parsing and dispatching an event, which catches regressions on
+// the hot path with headroom for changes in slack-go. Measure with
+// go test -bench DispatchEvent -benchmem.
+const dispatchAllocBudget = 40 // Measured at 12, and 29 with -race
+
+func newDispatchServer() (*Server, *mockSlackEventProcessor) {
+	server := NewServer(zap.NewNop(), Config{}, &mockSlackService{})
+	processor := &mockSlackEventProcessor{}
+	server.RegisterEventProcessor(processor)
+	server.AddEventFilter(allowAll{})
+	return server, processor
+}
+
+type allowAll struct{}
+
+func (allowAll) AllowEvent(event.Event) bool { return true }
+
+func BenchmarkServer_DispatchEvent(b *testing.B) {
+	server, processor := newDispatchServer()
+	body := []byte(eventPathBody)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := server.DispatchEvent(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if !processor.processEventCalled {
+		b.Fatal("the event never reached the processor")
+	}
+}
+
+func TestServer_DispatchEventAllocs(t *testing.T) {
+	server, processor := newDispatchServer()
+	body := []byte(eventPathBody)
+
+	allocs := testing.AllocsPerRun(100, func() { _ = server.DispatchEvent(body) })
+	if allocs > dispatchAllocBudget {
+		t.Errorf("DispatchEvent() = %.0f allocs, budget %d", allocs, dispatchAllocBudget)
+	}
+	if e, ok := processor.lastEvent.(event.Event); !ok || e.Text != "deploy friday" || e.ID != "Ev1" {
+		t.Errorf("processor got %+v, want the parsed message", processor.lastEvent)
+	}
+}
+
+func TestServer_RouteAuth(t *testing.T) {
+	config := Config{Auth: map[string]AuthConfig{
+		RouteGroupHealth:  {Username: "monitor", Password: "secret"},
+		RouteGroupWebhook: {BearerToken: "hook-token"},
+	}}
+	server := NewServer(zaptest.NewLogger(t), config, &mockSlackService{})
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	server.HandleAdmin("/admin/ping", ok)
+	server.HandleWebhook("/webhook/ping", ok)
+
+	tests := []struct {
+		name string
+		path string
+		auth func(*http.Request)
+		want int
+	}{
+		{"health without credentials", "/health", nil, http.StatusUnauthorized},
+		{"health with basic auth", "/health", func(r *http.Request) { r.SetBasicAuth("monitor", "secret") }, http.StatusOK},
+		{"health with wrong password", "/health", func(r *http.Request) { r.SetBasicAuth("monitor", "nope") }, http.StatusUnauthorized},
+		{"webhook with bearer token", "/webhook/ping", func(r *http.Request) { r.Header.Set("Authorization", "Bearer hook-token") }, http.StatusOK},
+		{"webhook with wrong token", "/webhook/ping", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"admin without configured credentials", "/admin/ping", func(r *http.Request) { r.Header.Set("Authorization", "Bearer hook-token") }, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.auth != nil {
+				tt.auth(req)
+			}
+			w := httptest.NewRecorder()
+			server.serveMux.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("%s returned %d, want %d", tt.path, w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Unauthorized responses should include a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestServer_SetAuth(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{}, &mockSlackService{})
+	server.HandleAdmin("/admin/ping", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	get := func(path, token string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.serveMux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get("/admin/ping", "admin-token"); code != http.StatusForbidden {
+		t.Errorf("admin before credentials returned %d, want 403", code)
+	}
+	server.SetAuth(map[string]AuthConfig{
+		RouteGroupAdmin:  {BearerToken: "admin-token"},
+		RouteGroupHealth: {BearerToken: "health-token"},
+	})
+	if code := get("/admin/ping", "admin-token"); code != http.StatusOK {
+		t.Errorf("admin with new credentials returned %d, want 200", code)
+	}
+	if code := get("/health", ""); code != http.StatusUnauthorized {
+		t.Errorf("health after credentials were added returned %d, want 401", code)
+	}
+	server.SetAuth(nil)
+	if code := get("/health", ""); code != http.StatusOK {
+		t.Errorf("health after credentials were removed returned %d, want 200", code)
+	}
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	config := Config{Auth: map[string]AuthConfig{RouteGroupAdmin: {BearerToken: "admin-token"}}}
+	server := NewServer(zaptest.NewLogger(t), config, &mockSlackService{})
+	registry := status.NewRegistry()
+	registry.Feature("chat").Event()
+	server.SetStatusRegistry(registry)
+	server.RegisterHealthCheck("slack", func() error { return errors.New("breaker open") })
+	server.AddDashboardSection(DashboardSection{
+		Title:   "Vibecheck bans",
+		Columns: []string{"User", "Channel"},
+		Rows:    func() [][]string { return [][]string{{"<U1>", "C1"}} },
+	})
+	server.AddDashboardSection(DashboardSection{
+		Title: "Empty",
+		Rows:  func() [][]string { return nil },
+		Empty: "No bans.",
+	})
+
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	w := httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Dashboard without credentials returned %d, want 401", w.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer admin-token")
+	w = httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Dashboard returned %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"degraded", "breaker open", "<td>chat</td>", "▁", "Vibecheck bans", "&lt;U1&gt;", "No bans."} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Dashboard is missing %q", want)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]int{0, 1, 2, 4}); got != "▁▂▄█" {
+		t.Errorf("sparkline = %q, want ▁▂▄█", got)
+	}
+	if got := sparkline([]int{0, 0}); got != "▁▁" {
+		t.Errorf("sparkline of no events = %q, want ▁▁", got)
+	}
+}
+
+func TestServer_OpenHealthByDefault(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{}, &mockSlackService{})
+	server.HandleWebhook("/webhook/ping", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	for _, path := range []string{"/health", "/status", "/webhook/ping"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		server.serveMux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s returned %d without auth configured, want 200", path, w.Code)
+		}
+	}
+}
+
+type mockEventPublisher struct {
+	published [][]byte
+	err       error
+}
+
+func (m *mockEventPublisher) Publish(ctx context.Context, body []byte) error {
+	m.published = append(m.published, body)
+	return m.err
+}
+
+func TestServer_EventPublisher(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{SlackEventPath: "/slack/events"}, &mockSlackService{})
+	processor := &mockSlackEventProcessor{}
+	server.RegisterEventProcessor(processor)
+	publisher := &mockEventPublisher{}
+	server.SetEventPublisher(publisher)
+
+	eventBody := `{"type": "event_callback", "event": {"type": "message", "text": "hello"}}`
+	req := httptest.NewRequest("POST", "/slack/events", bytes.NewBufferString(eventBody))
+	w := httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Published events should return 200, got %d", w.Code)
+	}
+	if len(publisher.published) != 1 || string(publisher.published[0]) != eventBody {
+		t.Fatalf("published = %q, want the event body", publisher.published)
+	}
+	if processor.processEventCalled {
+		t.Error("Event processor should not be called for published events")
+	}
+
+	// A worker dispatches what the receiver published
+	if err := server.DispatchEvent(publisher.published[0]); err != nil {
+		t.Fatalf("DispatchEvent() error = %v", err)
+	}
+	if e, ok := processor.lastEvent.(event.Event); !ok || e.Text != "hello" {
+		t.Errorf("lastEvent = %+v, want the published message", processor.lastEvent)
+	}
+	if err := server.DispatchEvent([]byte("not json")); err == nil {
+		t.Error("DispatchEvent() error = nil for a body that isn't an event")
+	}
+
+	// Slack retries events that couldn't be published
+	publisher.err = errors.New("connection refused")
+	req = httptest.NewRequest("POST", "/slack/events", bytes.NewBufferString(eventBody))
+	w = httptest.NewRecorder()
+	server.serveMux.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Failed publish should return 500, got %d", w.Code)
+	}
+}
+
+func TestServer_InteractionHandlers(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{SlackInteractionsPath: "/slack/interactions"}, &mockSlackService{})
+
+	clicked := make(chan string, 1)
+	server.HandleBlockAction("ack", func(ctx context.Context, callback slack.InteractionCallback, action *slack.BlockAction) {
+		clicked <- callback.User.ID + ":" + action.Value
+	})
+	server.HandleViewSubmission("rename", func(ctx context.Context, callback slack.InteractionCallback) *slack.ViewSubmissionResponse {
+		if callback.View.State.Values["name"]["input"].Value == "" {
+			return slack.NewErrorsViewSubmissionResponse(map[string]string{"name": "Enter a name"})
+		}
+		return nil
+	})
+	post := func(payload string) *httptest.ResponseRecorder {
+		form := url.Values{"payload": {payload}}
+		req := httptest.NewRequest("POST", "/slack/interactions", bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.serveMux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"type": "block_actions", "user": {"id": "U1"}, "actions": [{"action_id": "open_link", "block_id": "b1"}, {"action_id": "ack", "block_id": "b1", "value": "F1"}]}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("Block actions should return 200, got %d", w.Code)
+	}
+	select {
+	case got := <-clicked:
+		if got != "U1:F1" {
+			t.Errorf("handler got %q, want the click on ack", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Block action handler wasn't called")
+	}
+
+	// Errors keep the modal open
+	w = post(`{"type": "view_submission", "user": {"id": "U1"}, "view": {"callback_id": "rename", "state": {"values": {"name": {"input": {"type": "plain_text_input", "value": ""}}}}}}`)
+	var response slack.ViewSubmissionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.ResponseAction != slack.RAErrors || response.Errors["name"] == "" {
+		t.Errorf("view submission response = %s, %v, want the error for name", w.Body.String(), err)
+	}
+	// A nil response closes it
+	w = post(`{"type": "view_submission", "user": {"id": "U1"}, "view": {"callback_id": "rename", "state": {"values": {"name": {"input": {"type": "plain_text_input", "value": "Ada"}}}}}}`)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("view submission = %d %q, want an empty 200", w.Code, w.Body.String())
+	}
+	// Unknown modals are acknowledged
+	if w = post(`{"type": "view_submission", "view": {"callback_id": "other"}}`); w.Code != http.StatusOK {
+		t.Errorf("unknown view submission = %d, want 200", w.Code)
+	}
+}
+
+func TestServer_AdminAPI(t *testing.T) {
+	config := Config{Auth: map[string]AuthConfig{RouteGroupAdmin: {BearerToken: "admin-token"}}}
+	server := NewServer(zaptest.NewLogger(t), config, &mockSlackService{})
+	processor := &mockSlackEventProcessor{}
+	server.RegisterEventProcessor(processor)
+	server.SetConfigView(func() (any, error) { return map[string]string{"slack_token": "[redacted]"}, nil })
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin-token")
+		w := httptest.NewRecorder()
+		server.serveMux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("POST", "/admin/features/mock/disable"); w.Code != http.StatusOK {
+		t.Fatalf("disable returned %d: %s", w.Code, w.Body.String())
+	}
+	if err := server.DispatchEvent([]byte(eventPathBody)); err != nil {
+		t.Fatal(err)
+	}
+	if processor.processEventCalled {
+		t.Error("a disabled processor received an event")
+	}
+	w := serve("GET", "/admin/status")
+	var got struct {
+		Processors []Processor `json:"processors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || len(got.Processors) != 1 || got.Processors[0] != (Processor{Name: "mock"}) {
+		t.Errorf("/admin/status = %s, want the processor disabled", w.Body.String())
+	}
+
+	if w := serve("POST", "/admin/features/mock/enable"); w.Code != http.StatusOK {
+		t.Fatalf("enable returned %d", w.Code)
+	}
+	if err := server.DispatchEvent([]byte(eventPathBody)); err != nil {
+		t.Fatal(err)
+	}
+	if !processor.processEventCalled {
+		t.Error("an enabled processor didn't receive the event")
+	}
+
+	for _, path := range []string{"/admin/features/nope/disable", "/admin/features/mock/restart"} {
+		if w := serve("POST", path); w.Code != http.StatusNotFound {
+			t.Errorf("%s returned %d, want 404", path, w.Code)
+		}
+	}
+	if w := serve("GET", "/admin/features/mock/disable"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET toggle returned %d, want 405", w.Code)
+	}
+	if w := serve("GET", "/admin/config"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "[redacted]") {
+		t.Errorf("/admin/config returned %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServer_ReplaceEventProcessor(t *testing.T) {
+	server := NewServer(zaptest.NewLogger(t), Config{}, &mockSlackService{})
+	first, second, third := &mockSlackEventProcessor{}, &mockSlackEventProcessor{}, &mockSlackEventProcessor{}
+	server.RegisterEventProcessor(first)
+	server.RegisterEventProcessor(second)
+
+	// The replacement keeps its place, so dispatch order doesn't change
+	server.ReplaceEventProcessor(first, third)
+	if len(server.slackEventProcessors) != 2 || server.slackEventProcessors[0] != third {
+		t.Fatalf("processors = %v, want the first replaced in place", server.slackEventProcessors)
+	}
+	if err := server.DispatchEvent([]byte(eventPathBody)); err != nil {
+		t.Fatal(err)
+	}
+	if first.processEventCalled || !third.processEventCalled {
+		t.Error("the event went to the replaced processor")
+	}
+
+	server.ReplaceEventProcessor(second, nil)
+	server.ReplaceEventProcessor(first, nil) // Already gone
+	if len(server.slackEventProcessors) != 1 || server.slackEventProcessors[0] != third {
+		t.Errorf("processors = %v, want only the replacement left", server.slackEventProcessors)
+	}
+
+	interaction := &mockInteractionProcessor{}
+	server.RegisterInteractionProcessor(interaction)
+	server.UnregisterInteractionProcessor(interaction)
+	if len(server.interactionProcessors) != 0 {
+		t.Error("UnregisterInteractionProcessor() left the processor registered")
+	}
+}
@@ -1,27 +1,207 @@
 package http
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+)
+
+const (
+	// viewSubmissionTimeout leaves room within Slack's 3 seconds to send the response
+	viewSubmissionTimeout = 2500 * time.Millisecond
+	// blockActionTimeout bounds a block action handler running after Slack was answered
+	blockActionTimeout = time.Minute
 )
 
 // slackEventProcessor is an interface for components that want to process Slack events
 type slackEventProcessor interface {
-	PushEvent(slackevents.EventsAPIEvent)
+	PushEvent(event.Event)
 	ProcessorType() string
 }
 
+// slackEventFilter decides whether an event is dispatched to event processors
+type slackEventFilter interface {
+	AllowEvent(event.Event) bool
+}
+
+// AddEventFilter installs a filter consulted before events reach any processor. Events
+// are dispatched only when every filter allows them.
+func (h *Server) AddEventFilter(filter slackEventFilter) {
+	h.eventFilters = append(h.eventFilters, filter)
+}
+
+// slackEventPublisher sends verified events to processors in other processes, e.g. over
+// an event bus
+type slackEventPublisher interface {
+	Publish(ctx context.Context, body []byte) error
+}
+
+// SetEventPublisher publishes verified events instead of dispatching them to this
+// process's processors. Processes reading the events pass them to DispatchEvent.
+func (h *Server) SetEventPublisher(publisher slackEventPublisher) {
+	h.eventPublisher = publisher
+}
+
 func (h *Server) RegisterEventProcessor(processor slackEventProcessor) {
-	h.slackEventProcessors = append(h.slackEventProcessors, processor)
+	h.ReplaceEventProcessor(nil, processor)
+}
+
+// ReplaceEventProcessor swaps one processor for another while the server runs, so a
+// feature restarted with new config doesn't miss events in between. A nil old adds the
+// processor and a nil processor removes the old one.
+func (h *Server) ReplaceEventProcessor(old, processor slackEventProcessor) {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	i := slices.Index(h.slackEventProcessors, old)
+	switch {
+	case old != nil && i >= 0 && processor != nil:
+		h.slackEventProcessors[i] = processor
+	case old != nil && i >= 0:
+		h.slackEventProcessors = slices.Delete(h.slackEventProcessors, i, i+1)
+		h.log.Info("Unregistered Slack event processor.", zap.String("type", old.ProcessorType()))
+		return
+	case processor != nil:
+		h.slackEventProcessors = append(h.slackEventProcessors, processor)
+	default:
+		return
+	}
 	h.log.Info("Registered Slack event processor.",
 		zap.String("type", processor.ProcessorType()))
 }
 
+// slackInteractionProcessor is an interface for components that want to process Slack
+// interactivity payloads such as Block Kit button clicks
+type slackInteractionProcessor interface {
+	PushInteraction(slack.InteractionCallback)
+	ProcessorType() string
+}
+
+func (h *Server) RegisterInteractionProcessor(processor slackInteractionProcessor) {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	h.interactionProcessors = append(h.interactionProcessors, processor)
+	h.log.Info("Registered Slack interaction processor.",
+		zap.String("type", processor.ProcessorType()))
+}
+
+// UnregisterInteractionProcessor stops passing interactions to a processor, e.g. a
+// feature turned off in config
+func (h *Server) UnregisterInteractionProcessor(processor slackInteractionProcessor) {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	if i := slices.Index(h.interactionProcessors, processor); i >= 0 {
+		h.interactionProcessors = slices.Delete(h.interactionProcessors, i, i+1)
+		h.log.Info("Unregistered Slack interaction processor.",
+			zap.String("type", processor.ProcessorType()))
+	}
+}
+
+// BlockActionHandler handles a click on a Block Kit element with the action ID it was
+// registered for. It runs after Slack has been answered, so it may take its time.
+type BlockActionHandler func(ctx context.Context, callback slack.InteractionCallback, action *slack.BlockAction)
+
+// ViewSubmissionHandler handles a modal submitted with the callback ID it was registered
+// for. It runs while Slack waits, so it must answer within viewSubmissionTimeout; a nil
+// response closes the modal, and one with errors keeps it open showing them.
+type ViewSubmissionHandler func(ctx context.Context, callback slack.InteractionCallback) *slack.ViewSubmissionResponse
+
+// HandleBlockAction routes clicks on elements with the action ID to the handler
+func (h *Server) HandleBlockAction(actionID string, handler BlockActionHandler) {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	if h.blockActionHandlers == nil {
+		h.blockActionHandlers = make(map[string]BlockActionHandler)
+	}
+	h.blockActionHandlers[actionID] = handler
+	h.log.Info("Registered Slack block action handler.", zap.String("action_id", actionID))
+}
+
+// HandleViewSubmission routes submissions of modals with the callback ID to the handler
+func (h *Server) HandleViewSubmission(callbackID string, handler ViewSubmissionHandler) {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	if h.viewHandlers == nil {
+		h.viewHandlers = make(map[string]ViewSubmissionHandler)
+	}
+	h.viewHandlers[callbackID] = handler
+	h.log.Info("Registered Slack view submission handler.", zap.String("callback_id", callbackID))
+}
+
+// slackCommandProcessor handles a subcommand of the bot's slash command, e.g. "incident"
+// in "/slackbot incident start", returning the reply shown only to the caller
+type slackCommandProcessor interface {
+	Subcommand() string
+	HandleCommand(ctx context.Context, cmd slack.SlashCommand, args []string) string
+}
+
+func (h *Server) RegisterCommandProcessor(processor slackCommandProcessor) {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	if h.commandProcessors == nil {
+		h.commandProcessors = make(map[string]slackCommandProcessor)
+	}
+	h.commandProcessors[processor.Subcommand()] = processor
+	h.log.Info("Registered Slack command processor.",
+		zap.String("subcommand", processor.Subcommand()))
+}
+
+// UnregisterCommandProcessor removes the processor handling a subcommand
+func (h *Server) UnregisterCommandProcessor(subcommand string) {
+	h.processorsMu.Lock()
+	defer h.processorsMu.Unlock()
+	if _, ok := h.commandProcessors[subcommand]; ok {
+		delete(h.commandProcessors, subcommand)
+		h.log.Info("Unregistered Slack command processor.", zap.String("subcommand", subcommand))
+	}
+}
+
+// teamAllowed reports whether a request from the workspace or Enterprise Grid org may be
+// processed, counting and logging mismatches. Every team is allowed when none are configured.
+func (h *Server) teamAllowed(kind, teamID, enterpriseID string) bool {
+	allowed := h.config.AllowedTeamIDs
+	if len(allowed) == 0 || slices.Contains(allowed, teamID) || (enterpriseID != "" && slices.Contains(allowed, enterpriseID)) {
+		return true
+	}
+
+	h.teamMu.Lock()
+	if h.teamMismatches == nil {
+		h.teamMismatches = make(map[string]uint64)
+	}
+	h.teamMismatches[teamID]++
+	h.teamMu.Unlock()
+
+	h.log.Warn("Dropping Slack request from unexpected team",
+		zap.String("kind", kind),
+		zap.String("team_id", teamID),
+		zap.String("enterprise_id", enterpriseID),
+		zap.Strings("allowed", allowed))
+	return false
+}
+
+// TeamMismatches returns the number of dropped requests per unexpected team ID
+func (h *Server) TeamMismatches() map[string]uint64 {
+	h.teamMu.Lock()
+	defer h.teamMu.Unlock()
+	counts := make(map[string]uint64, len(h.teamMismatches))
+	for team, n := range h.teamMismatches {
+		counts[team] = n
+	}
+	return counts
+}
+
 // RegisterSlackEndpoints registers HTTP endpoints for handling Slack events
 func (h *Server) registerSlackEndpoints() {
 	path := "/api/slack/events"
@@ -32,6 +212,24 @@ func (h *Server) registerSlackEndpoints() {
 	h.log.Info("Registering Slack events endpoint", zap.String("path", path))
 
 	h.serveMux.HandleFunc(path, h.handleSlackEvents)
+
+	interactionsPath := "/api/slack/interactions"
+	if h.config.SlackInteractionsPath != "" {
+		interactionsPath = h.config.SlackInteractionsPath
+	}
+
+	h.log.Info("Registering Slack interactions endpoint", zap.String("path", interactionsPath))
+
+	h.serveMux.HandleFunc(interactionsPath, h.handleSlackInteractions)
+
+	commandsPath := "/api/slack/commands"
+	if h.config.SlackCommandsPath != "" {
+		commandsPath = h.config.SlackCommandsPath
+	}
+
+	h.log.Info("Registering Slack commands endpoint", zap.String("path", commandsPath))
+
+	h.serveMux.HandleFunc(commandsPath, h.handleSlackCommands)
 }
 
 // handleSlackEvents processes Slack events
@@ -80,10 +278,46 @@ func (h *Server) handleSlackEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.teamAllowed("event", eventsAPIEvent.TeamID, eventsAPIEvent.EnterpriseID) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if h.eventPublisher != nil {
+		if err := h.eventPublisher.Publish(r.Context(), body); err != nil {
+			h.log.Error("Failed to publish Slack event.", zap.Error(err))
+			// Slack retries the event
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	h.dispatchEvent(eventsAPIEvent)
+	w.WriteHeader(http.StatusOK)
+}
+
+// DispatchEvent passes a verified event published by another process to the processors
+func (h *Server) DispatchEvent(body []byte) error {
+	eventsAPIEvent, err := slackevents.ParseEvent(
+		json.RawMessage(body),
+		slackevents.OptionNoVerifyToken(),
+	)
+	if err != nil {
+		return fmt.Errorf("parse slack event: %w", err)
+	}
+	h.dispatchEvent(eventsAPIEvent)
+	return nil
+}
+
+// dispatchEvent passes an event the filters allow to every processor
+func (h *Server) dispatchEvent(eventsAPIEvent slackevents.EventsAPIEvent) {
+	h.processorsMu.RLock()
+	defer h.processorsMu.RUnlock()
 	// Check if we have processors for regular events
 	if len(h.slackEventProcessors) == 0 {
 		h.log.Debug("No event processors registered, ignoring event")
-		w.WriteHeader(http.StatusOK)
 		return
 	}
 
@@ -91,13 +325,177 @@ func (h *Server) handleSlackEvents(w http.ResponseWriter, r *http.Request) {
 		zap.String("type", string(eventsAPIEvent.Type)),
 		zap.Any("innerEvent", eventsAPIEvent.InnerEvent.Type))
 
+	// Normalized once here so processors don't each extract the user, channel and text
+	e := event.New(eventsAPIEvent)
+	for _, filter := range h.eventFilters {
+		if !filter.AllowEvent(e) {
+			h.log.Debug("Event filtered, not dispatching to processors",
+				zap.String("type", string(eventsAPIEvent.Type)),
+				zap.Any("innerEvent", eventsAPIEvent.InnerEvent.Type))
+			return
+		}
+	}
+
 	for _, processor := range h.slackEventProcessors {
-		processor.PushEvent(eventsAPIEvent)
+		if len(h.disabled) > 0 && h.disabled[processor.ProcessorType()] {
+			continue
+		}
+		processor.PushEvent(e)
+	}
+}
+
+// handleSlackInteractions processes Slack interactivity payloads
+func (h *Server) handleSlackInteractions(w http.ResponseWriter, r *http.Request) {
+	body, err := readRequestBody(r)
+	if err != nil {
+		h.log.Error("Failed to read request body.", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.slack.VerifyRequest(r.Header, body); err != nil {
+		h.log.Error("Failed to verify request.", zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		h.log.Error("Failed to parse interaction form.", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(values.Get("payload")), &callback); err != nil {
+		h.log.Error("Failed to parse Slack interaction.", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !h.teamAllowed("interaction", callback.Team.ID, callback.Enterprise.ID) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	h.log.Debug("Received Slack interaction",
+		zap.String("type", string(callback.Type)),
+		zap.String("user", callback.User.ID))
+
+	h.processorsMu.RLock()
+	for _, processor := range h.interactionProcessors {
+		processor.PushInteraction(callback)
+	}
+	h.processorsMu.RUnlock()
+
+	switch callback.Type {
+	case slack.InteractionTypeBlockActions:
+		h.dispatchBlockActions(r.Context(), callback)
+	case slack.InteractionTypeViewSubmission:
+		if response := h.dispatchViewSubmission(r.Context(), callback); response != nil {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				h.log.Error("Failed to write view submission response.", zap.Error(err))
+			}
+			return
+		}
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// dispatchBlockActions runs the handler of each clicked action in the background, so
+// Slack is answered within its 3 second limit
+func (h *Server) dispatchBlockActions(ctx context.Context, callback slack.InteractionCallback) {
+	for _, action := range callback.ActionCallback.BlockActions {
+		h.processorsMu.RLock()
+		handler, ok := h.blockActionHandlers[action.ActionID]
+		h.processorsMu.RUnlock()
+		if !ok {
+			// Link buttons send actions too, with nothing to do once the link opens
+			h.log.Debug("No handler for block action", zap.String("action_id", action.ActionID))
+			continue
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), blockActionTimeout)
+			defer cancel()
+			handler(ctx, callback, action)
+		}()
+	}
+}
+
+// dispatchViewSubmission runs the handler for the submitted modal and returns its response
+func (h *Server) dispatchViewSubmission(ctx context.Context, callback slack.InteractionCallback) *slack.ViewSubmissionResponse {
+	h.processorsMu.RLock()
+	handler, ok := h.viewHandlers[callback.View.CallbackID]
+	h.processorsMu.RUnlock()
+	if !ok {
+		h.log.Warn("No handler for view submission", zap.String("callback_id", callback.View.CallbackID))
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, viewSubmissionTimeout)
+	defer cancel()
+	return handler(ctx, callback)
+}
+
+// handleSlackCommands dispatches slash commands to the processor registered for the
+// first word of the command text and replies ephemerally
+func (h *Server) handleSlackCommands(w http.ResponseWriter, r *http.Request) {
+	body, err := readRequestBody(r)
+	if err != nil {
+		h.log.Error("Failed to read request body.", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.slack.VerifyRequest(r.Header, body); err != nil {
+		h.log.Error("Failed to verify request.", zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	command, err := slack.SlashCommandParse(r)
+	if err != nil {
+		h.log.Error("Failed to parse Slack command.", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !h.teamAllowed("command", command.TeamID, command.EnterpriseID) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	h.log.Debug("Received Slack command",
+		zap.String("command", command.Command),
+		zap.String("text", command.Text),
+		zap.String("user", command.UserID))
+
+	args := strings.Fields(command.Text)
+	h.processorsMu.RLock()
+	var processor slackCommandProcessor
+	if len(args) > 0 {
+		processor = h.commandProcessors[args[0]]
+	}
+	subcommands := slices.Sorted(maps.Keys(h.commandProcessors))
+	h.processorsMu.RUnlock()
+	var reply string
+	if processor != nil {
+		reply = processor.HandleCommand(r.Context(), command, args[1:])
+	} else if len(subcommands) == 0 {
+		reply = "No commands are available."
+	} else {
+		reply = fmt.Sprintf("Usage: %s <%s>", command.Command, strings.Join(subcommands, "|"))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"response_type": "ephemeral",
+		"text":          reply,
+	})
+}
+
 func readRequestBody(r *http.Request) ([]byte, error) {
 	if r.Body == nil {
 		return nil, fmt.Errorf("request body is nil")
@@ -0,0 +1,320 @@
+// Package incident silences fun features while an incident is in progress, either in one
+// channel or across the workspace. Incidents are started and ended with the
+// "/slackbot incident" command and expire on their own.
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/tools/timeformat"
+)
+
+const (
+	stateFile = "incident.json"
+	// workspace is the scope key of a workspace-wide incident
+	workspace = ""
+)
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type FileConfig struct {
+	DefaultDuration *time.Duration `json:"default_duration" yaml:"default_duration"`
+	MaxDuration     *time.Duration `json:"max_duration" yaml:"max_duration"`
+}
+
+type Config struct {
+	DataDir         string
+	DefaultDuration time.Duration // How long an incident lasts when started without a duration
+	MaxDuration     time.Duration // Longest incident that can be started
+}
+
+// Incident is an active silence, persisted so it survives restarts
+type Incident struct {
+	Channel         string    `json:"channel"`          // Empty for a workspace-wide incident
+	AnnounceChannel string    `json:"announce_channel"` // Where start and end are announced
+	StartedBy       string    `json:"started_by"`
+	Reason          string    `json:"reason,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+func (i Incident) scope() string {
+	if i.Channel == workspace {
+		return "across the workspace"
+	}
+	return fmt.Sprintf("in <#%s>", i.Channel)
+}
+
+// Mode tracks active incidents and answers whether a channel should stay quiet
+type Mode struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	path        string
+	incidents   map[string]Incident // channel ID, or workspace -> incident
+	mu          sync.Mutex
+	now         func() time.Time
+	stopCh      chan struct{}
+	isConnected atomic.Bool
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Mode {
+	m := &Mode{
+		log:       log,
+		config:    c,
+		slack:     s,
+		path:      filepath.Join(c.DataDir, stateFile),
+		incidents: make(map[string]Incident),
+		now:       time.Now,
+		stopCh:    make(chan struct{}),
+	}
+	m.load()
+	return m
+}
+
+// Start expires incidents in the background
+func (m *Mode) Start(ctx context.Context) error {
+	m.isConnected.Store(true)
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.expire(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *Mode) Stop(ctx context.Context) error {
+	if !m.isConnected.Load() {
+		return nil
+	}
+	close(m.stopCh)
+	m.isConnected.Store(false)
+	return nil
+}
+
+// Silenced reports whether a workspace-wide incident or one in the channel is active
+func (m *Mode) Silenced(channelID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for _, key := range []string{workspace, channelID} {
+		if i, ok := m.incidents[key]; ok && now.Before(i.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subcommand is the slash command word handled by the mode
+func (m *Mode) Subcommand() string {
+	return "incident"
+}
+
+const usage = "Usage: /slackbot incident start [all] [duration] [reason] | end [all] | status"
+
+// HandleCommand starts, ends or reports incidents. Incidents apply to the channel the
+// command was used in unless "all" is given.
+func (m *Mode) HandleCommand(ctx context.Context, cmd slack.SlashCommand, args []string) string {
+	if len(args) == 0 {
+		return usage
+	}
+	action, args := args[0], args[1:]
+
+	channel := cmd.ChannelID
+	if len(args) > 0 && strings.EqualFold(args[0], "all") {
+		channel, args = workspace, args[1:]
+	}
+
+	switch action {
+	case "start":
+		duration := m.config.DefaultDuration
+		if len(args) > 0 {
+			if d, err := time.ParseDuration(args[0]); err == nil {
+				if d <= 0 {
+					return "The duration must be positive."
+				}
+				duration, args = d, args[1:]
+			}
+		}
+		if m.config.MaxDuration > 0 && duration > m.config.MaxDuration {
+			duration = m.config.MaxDuration
+		}
+		incident := m.start(channel, cmd.ChannelID, cmd.UserID, strings.Join(args, " "), duration)
+		m.announce(ctx, incident.AnnounceChannel, startMessage(incident))
+		return fmt.Sprintf("Incident mode is on %s until %s.", incident.scope(), timeformat.Slack(incident.ExpiresAt, timeformat.Time))
+	case "end":
+		incident, ok := m.end(channel)
+		if !ok {
+			return "There is no incident to end here."
+		}
+		m.announce(ctx, incident.AnnounceChannel, fmt.Sprintf("✅ Incident mode ended %s by <@%s>. Carry on.", incident.scope(), cmd.UserID))
+		return "Incident mode is off."
+	case "status":
+		return m.describe(cmd.ChannelID)
+	}
+	return usage
+}
+
+func (m *Mode) start(channel, announceChannel, userID, reason string, duration time.Duration) Incident {
+	now := m.now()
+	incident := Incident{
+		Channel:         channel,
+		AnnounceChannel: announceChannel,
+		StartedBy:       userID,
+		Reason:          reason,
+		StartedAt:       now,
+		ExpiresAt:       now.Add(duration),
+	}
+
+	m.mu.Lock()
+	m.incidents[channel] = incident
+	m.save()
+	m.mu.Unlock()
+
+	m.log.Info("Incident mode started",
+		zap.String("channel", channel),
+		zap.String("user", userID),
+		zap.Duration("duration", duration),
+		zap.String("reason", reason),
+	)
+	return incident
+}
+
+func (m *Mode) end(channel string) (Incident, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	incident, ok := m.incidents[channel]
+	if !ok {
+		return Incident{}, false
+	}
+	delete(m.incidents, channel)
+	m.save()
+	m.log.Info("Incident mode ended", zap.String("channel", channel))
+	return incident, true
+}
+
+// expire ends incidents past their expiry and announces it
+func (m *Mode) expire(ctx context.Context) {
+	now := m.now()
+	var expired []Incident
+	m.mu.Lock()
+	for key, incident := range m.incidents {
+		if !now.Before(incident.ExpiresAt) {
+			expired = append(expired, incident)
+			delete(m.incidents, key)
+		}
+	}
+	if len(expired) > 0 {
+		m.save()
+	}
+	m.mu.Unlock()
+
+	for _, incident := range expired {
+		m.log.Info("Incident mode expired", zap.String("channel", incident.Channel))
+		m.announce(ctx, incident.AnnounceChannel, fmt.Sprintf("✅ Incident mode expired %s. Carry on.", incident.scope()))
+	}
+}
+
+func (m *Mode) describe(channelID string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var lines []string
+	now := m.now()
+	for _, key := range []string{workspace, channelID} {
+		if i, ok := m.incidents[key]; ok && now.Before(i.ExpiresAt) {
+			lines = append(lines, fmt.Sprintf("Incident mode is on %s until %s, started by <@%s>.", i.scope(), timeformat.Slack(i.ExpiresAt, timeformat.Time), i.StartedBy))
+		}
+	}
+	if len(lines) == 0 {
+		return "Incident mode is off here."
+	}
+	return strings.Join(lines, "\n")
+}
+
+func startMessage(i Incident) string {
+	msg := fmt.Sprintf("🚨 Incident mode started %s by <@%s>", i.scope(), i.StartedBy)
+	if i.Reason != "" {
+		msg += ": " + i.Reason
+	}
+	return msg + fmt.Sprintf(". Chat responses, vibechecks and unprompted AI replies are paused until %s.", timeformat.Slack(i.ExpiresAt, timeformat.Time))
+}
+
+func (m *Mode) announce(ctx context.Context, channel, text string) {
+	if channel == "" {
+		return
+	}
+	_, _, err := m.slack.Client().PostMessageContext(ctx, channel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		m.log.Error("Failed to announce incident mode",
+			zap.String("channel", channel),
+			zap.Error(errs.NewSlackAPIError("chat.postMessage", err)),
+		)
+	}
+}
+
+func (m *Mode) load() {
+	data, err := os.ReadFile(m.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		m.log.Error("Failed to read incident state", zap.Error(err), zap.String("path", m.path))
+		return
+	}
+	var incidents []Incident
+	if err := json.Unmarshal(data, &incidents); err != nil {
+		m.log.Error("Failed to unmarshal incident state", zap.Error(err), zap.String("path", m.path))
+		return
+	}
+	for _, i := range incidents {
+		m.incidents[i.Channel] = i
+	}
+}
+
+// save writes active incidents; callers must hold mu
+func (m *Mode) save() {
+	incidents := make([]Incident, 0, len(m.incidents))
+	for _, i := range m.incidents {
+		incidents = append(incidents, i)
+	}
+	slices.SortFunc(incidents, func(a, b Incident) int { return strings.Compare(a.Channel, b.Channel) })
+	data, err := json.Marshal(incidents)
+	if err != nil {
+		m.log.Error("Failed to marshal incident state", zap.Error(err))
+		return
+	}
+	tempFile := m.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		m.log.Error("Failed to save incident state", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, m.path); err != nil {
+		m.log.Error("Failed to save incident state", zap.Error(&errs.StorageError{Op: "rename", Path: m.path, Err: err}))
+	}
+}
@@ -0,0 +1,101 @@
+package incident
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func newTestMode(t *testing.T, dir string) (*Mode, *[]string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		posts []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		posts = append(posts, r.FormValue("channel")+": "+r.FormValue("text"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.0"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	s := slacktest.New(srv.URL)
+	m := New(zaptest.NewLogger(t), Config{DataDir: dir, DefaultDuration: time.Hour, MaxDuration: 4 * time.Hour}, s)
+	return m, &posts
+}
+
+func TestMode_HandleCommand(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	m, posts := newTestMode(t, dir)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	cmd := slack.SlashCommand{ChannelID: "C1", UserID: "U1"}
+
+	if reply := m.HandleCommand(ctx, cmd, []string{"start", "30m", "db", "down"}); !strings.Contains(reply, "on in <#C1>") {
+		t.Errorf("Unexpected start reply %q", reply)
+	}
+	if !m.Silenced("C1") || m.Silenced("C2") {
+		t.Error("Only C1 should be silenced")
+	}
+	if len(*posts) != 1 || !strings.Contains((*posts)[0], "db down") {
+		t.Errorf("Expected a start announcement with the reason, got %v", *posts)
+	}
+
+	// Durations are capped
+	m.HandleCommand(ctx, cmd, []string{"start", "all", "48h"})
+	if got := m.incidents[workspace].ExpiresAt.Sub(now); got != 4*time.Hour {
+		t.Errorf("Workspace incident lasts %v, want 4h", got)
+	}
+	if !m.Silenced("C2") {
+		t.Error("Workspace incident should silence every channel")
+	}
+
+	// Incidents are restored from disk
+	restored, _ := newTestMode(t, dir)
+	restored.now = m.now
+	if !restored.Silenced("C1") || !restored.Silenced("C2") {
+		t.Error("Incidents should be restored after a restart")
+	}
+
+	if reply := m.HandleCommand(ctx, cmd, []string{"end", "all"}); reply != "Incident mode is off." {
+		t.Errorf("Unexpected end reply %q", reply)
+	}
+	if m.Silenced("C2") {
+		t.Error("C2 should not be silenced after the workspace incident ended")
+	}
+	if reply := m.HandleCommand(ctx, cmd, []string{"end", "all"}); !strings.Contains(reply, "no incident") {
+		t.Errorf("Ending twice should report no incident, got %q", reply)
+	}
+
+	// The channel incident expires on its own
+	now = now.Add(31 * time.Minute)
+	if m.Silenced("C1") {
+		t.Error("Expired incident should not silence the channel")
+	}
+	m.expire(ctx)
+	if len(m.incidents) != 0 {
+		t.Errorf("Expected expired incidents to be removed, got %v", m.incidents)
+	}
+	if last := (*posts)[len(*posts)-1]; !strings.Contains(last, "expired") {
+		t.Errorf("Expected an expiry announcement, got %q", last)
+	}
+
+	if reply := m.HandleCommand(ctx, cmd, []string{"status"}); reply != "Incident mode is off here." {
+		t.Errorf("Unexpected status reply %q", reply)
+	}
+	if reply := m.HandleCommand(ctx, cmd, nil); reply != usage {
+		t.Errorf("Expected usage, got %q", reply)
+	}
+}
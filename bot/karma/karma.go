@@ -0,0 +1,407 @@
+// Package karma counts the upvote and downvote reactions people's messages collect as
+// their karma, and answers "karma @alice" or "/slackbot karma @alice" with their score
+// and the leaderboard. Removing a reaction takes its vote back, and reacting to your own
+// message doesn't count. Scores are kept in karma.json in the data directory.
+package karma
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/emoji"
+)
+
+const (
+	stateFile = "karma.json"
+
+	DefaultLeaderboardSize = 5
+
+	// saveInterval is how often scores changed since the last save are written
+	saveInterval = time.Minute
+)
+
+var (
+	DefaultUpvotes   = []string{"+1", "heart", "tada"}
+	DefaultDownvotes = []string{"-1"}
+)
+
+const usage = "Usage: /slackbot karma [@user]"
+
+var (
+	// askPattern matches "karma", "karma <@U456>" and "<@U123> karma <@U456>?"
+	askPattern = regexp.MustCompile(`(?i)^\s*(<@[A-Z0-9]+>[\s,:]*)?karma(?:\s+(?:for\s+|of\s+)?<@([A-Z0-9]+)(?:\|[^>]*)?>|\s+leaderboard)?\s*[?.!]*\s*$`)
+	// userPattern matches an escaped user mention in a slash command, e.g. <@U456|alice>
+	userPattern = regexp.MustCompile(`^<@([A-Z0-9]+)(?:\|[^>]*)?>$`)
+)
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Upvotes are the reactions that give the message's author a point, defaults to +1,
+	// heart and tada
+	Upvotes []string `json:"upvotes" yaml:"upvotes"`
+	// Downvotes are the reactions that take a point away, defaults to -1
+	Downvotes []string `json:"downvotes" yaml:"downvotes"`
+	// Channels limits counting to these channel IDs, empty counts every channel the bot is in
+	Channels []string `json:"channels" yaml:"channels"`
+	// LeaderboardSize is how many people the leaderboard lists, defaults to 5
+	LeaderboardSize *int           `json:"leaderboard_size" yaml:"leaderboard_size"`
+	Persona         persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	Enabled         bool
+	DataDir         string
+	Upvotes         []string
+	Downvotes       []string
+	Channels        []string
+	LeaderboardSize int
+	Persona         persona.Config // Name and icon answers are posted with
+}
+
+type state struct {
+	Scores map[string]int `json:"scores"` // User ID -> karma
+}
+
+// Score is a user's karma and where it places them
+type Score struct {
+	UserID string `json:"user_id"`
+	Karma  int    `json:"karma"`
+	Rank   int    `json:"rank"` // 1 is the most karma, 0 when the user has none recorded
+}
+
+// Karma keeps each person's karma from the reactions their messages collect
+type Karma struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	path        string
+	mu          sync.Mutex
+	state       state
+	dirty       bool
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Karma {
+	if len(c.Upvotes) == 0 {
+		c.Upvotes = DefaultUpvotes
+	}
+	if c.Downvotes == nil {
+		c.Downvotes = DefaultDownvotes
+	}
+	if c.LeaderboardSize <= 0 {
+		c.LeaderboardSize = DefaultLeaderboardSize
+	}
+	k := &Karma{
+		log:      log,
+		config:   c,
+		slack:    s,
+		path:     filepath.Join(c.DataDir, stateFile),
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+	k.load()
+	return k
+}
+
+// SetStatusTracker sets where karma reports its activity
+func (k *Karma) SetStatusTracker(t *status.Tracker) {
+	k.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (k *Karma) ProcessorType() string {
+	return "karma"
+}
+
+// Subcommand is the slash command word handled by karma
+func (k *Karma) Subcommand() string {
+	return "karma"
+}
+
+func (k *Karma) Start(ctx context.Context) error {
+	k.isConnected.Store(true)
+	go k.handleEvents(ctx)
+	go func() {
+		ticker := time.NewTicker(saveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-k.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				k.save()
+			}
+		}
+	}()
+	k.log.Debug("Karma started.",
+		zap.Strings("upvotes", k.config.Upvotes),
+		zap.Strings("downvotes", k.config.Downvotes))
+	return nil
+}
+
+// Stop saves the scores changed since the last save
+func (k *Karma) Stop(_ context.Context) error {
+	if !k.isConnected.Load() {
+		return nil
+	}
+	close(k.stopCh)
+	k.isConnected.Store(false)
+	k.save()
+	return nil
+}
+
+// Answers reports whether karma replies to the mention text
+func (k *Karma) Answers(channelID, text string) bool {
+	return askPattern.MatchString(text)
+}
+
+// Score returns the user's karma and rank
+func (k *Karma) Score(userID string) Score {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	karma, ok := k.state.Scores[userID]
+	if !ok {
+		return Score{UserID: userID}
+	}
+	rank := 1
+	for _, other := range k.state.Scores {
+		if other > karma {
+			rank++
+		}
+	}
+	return Score{UserID: userID, Karma: karma, Rank: rank}
+}
+
+// Leaderboard returns the n users with the most karma, most first
+func (k *Karma) Leaderboard(n int) []Score {
+	k.mu.Lock()
+	scores := make([]Score, 0, len(k.state.Scores))
+	for userID, karma := range k.state.Scores {
+		scores = append(scores, Score{UserID: userID, Karma: karma})
+	}
+	k.mu.Unlock()
+	slices.SortFunc(scores, func(a, b Score) int {
+		return cmp.Or(cmp.Compare(b.Karma, a.Karma), strings.Compare(a.UserID, b.UserID))
+	})
+	for i := range scores {
+		scores[i].Rank = i + 1
+		if i > 0 && scores[i].Karma == scores[i-1].Karma {
+			scores[i].Rank = scores[i-1].Rank
+		}
+	}
+	return scores[:min(n, len(scores))]
+}
+
+// PushEvent adds an event to be processed by karma
+func (k *Karma) PushEvent(e event.Event) {
+	if !k.isConnected.Load() {
+		return
+	}
+
+	select {
+	case k.eventsCh <- e:
+	default:
+		k.log.Warn("Karma events channel full, dropping event.")
+		k.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (k *Karma) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-k.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-k.eventsCh:
+			k.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (k *Karma) processEvent(ctx context.Context, e event.Event) {
+	if e.FromBot() {
+		return
+	}
+	switch ev := e.Data().(type) {
+	case *slackevents.ReactionAddedEvent:
+		k.vote(e, ev.ItemUser, ev.Reaction, 1)
+	case *slackevents.ReactionRemovedEvent:
+		k.vote(e, ev.ItemUser, ev.Reaction, -1)
+	case *slackevents.MessageEvent:
+		// Mentions are answered from their app_mention event, which DMs don't get
+		m := askPattern.FindStringSubmatch(e.Text)
+		if m != nil && (m[1] == "" || e.ChannelType == "im") && e.SubType == "" {
+			k.answer(ctx, e, m[2])
+		}
+	case *slackevents.AppMentionEvent:
+		if m := askPattern.FindStringSubmatch(e.Text); m != nil {
+			k.answer(ctx, e, m[2])
+		}
+	}
+}
+
+// vote adds the reaction's vote to the author of the message reacted to, or takes it back
+// when the reaction is removed
+func (k *Karma) vote(e event.Event, author, reaction string, sign int) {
+	if author == "" || author == e.User {
+		return
+	}
+	if len(k.config.Channels) > 0 && !slices.Contains(k.config.Channels, e.Channel) {
+		return
+	}
+	name := emoji.Base(reaction)
+	var points int
+	switch {
+	case slices.Contains(k.config.Upvotes, name):
+		points = sign
+	case slices.Contains(k.config.Downvotes, name):
+		points = -sign
+	default:
+		return
+	}
+	k.status.Event()
+	k.mu.Lock()
+	k.state.Scores[author] += points
+	k.dirty = true
+	k.mu.Unlock()
+	k.log.Debug("Karma changed",
+		zap.String("user", author),
+		zap.String("by", e.User),
+		zap.String("reaction", name),
+		zap.Int("points", points))
+}
+
+// answer replies in a thread with the user's karma, or the leaderboard when no user is asked about
+func (k *Karma) answer(ctx context.Context, e event.Event, userID string) {
+	k.status.Event()
+	threadTS := e.ThreadTS
+	if threadTS == "" {
+		threadTS = e.TS
+	}
+	text := k.formatLeaderboard()
+	if userID != "" {
+		text = k.formatScore(k.Score(userID))
+	}
+	_, _, err := k.slack.Client().PostMessageContext(ctx, e.Channel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(threadTS),
+		k.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = conversation.Error("chat.postMessage", conversation.KindFromID(e.Channel), err)
+		k.status.PostFailed(e.Channel, err)
+		k.log.Error("Failed to post karma", zap.String("channel", e.Channel), zap.Error(err))
+		return
+	}
+	k.status.Posted()
+}
+
+// HandleCommand answers "/slackbot karma @user" with the user's karma, or the
+// leaderboard without a user. Slack only sends the user's ID when the command escapes
+// users.
+func (k *Karma) HandleCommand(_ context.Context, cmd slack.SlashCommand, args []string) string {
+	switch {
+	case len(args) == 0, len(args) == 1 && strings.EqualFold(args[0], "leaderboard"):
+		return k.formatLeaderboard()
+	case len(args) == 1 && strings.EqualFold(args[0], "me"):
+		return k.formatScore(k.Score(cmd.UserID))
+	case len(args) == 1:
+		if m := userPattern.FindStringSubmatch(args[0]); m != nil {
+			return k.formatScore(k.Score(m[1]))
+		}
+	}
+	return usage
+}
+
+func (k *Karma) formatScore(s Score) string {
+	if s.Rank == 0 {
+		return fmt.Sprintf("<@%s> doesn't have any karma yet.", s.UserID)
+	}
+	return fmt.Sprintf("<@%s> has %d karma, #%d on the leaderboard.", s.UserID, s.Karma, s.Rank)
+}
+
+func (k *Karma) formatLeaderboard() string {
+	scores := k.Leaderboard(k.config.LeaderboardSize)
+	if len(scores) == 0 {
+		return "Nobody has any karma yet. React to a message with :" + k.config.Upvotes[0] + ": to give its author some."
+	}
+	var b strings.Builder
+	b.WriteString("🏆 *Karma leaderboard*\n")
+	for _, s := range scores {
+		fmt.Fprintf(&b, "%d. <@%s> %d\n", s.Rank, s.UserID, s.Karma)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (k *Karma) load() {
+	k.state = state{Scores: make(map[string]int)}
+	data, err := os.ReadFile(k.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		k.log.Error("Failed to read karma", zap.Error(err), zap.String("path", k.path))
+		return
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		k.log.Error("Failed to unmarshal karma", zap.Error(err), zap.String("path", k.path))
+		return
+	}
+	if s.Scores == nil {
+		s.Scores = make(map[string]int)
+	}
+	k.state = s
+}
+
+// save writes the scores when they changed
+func (k *Karma) save() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.dirty {
+		return
+	}
+	data, err := json.Marshal(k.state)
+	if err != nil {
+		k.log.Error("Failed to marshal karma", zap.Error(err))
+		return
+	}
+	tempFile := k.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		k.log.Error("Failed to save karma", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, k.path); err != nil {
+		k.log.Error("Failed to save karma", zap.Error(&errs.StorageError{Op: "rename", Path: k.path, Err: err}))
+		return
+	}
+	k.dirty = false
+}
@@ -0,0 +1,98 @@
+package karma
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestKarma_Votes(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts = append(posts, r.FormValue("thread_ts")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(slacktest.Posted))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	s := slacktest.New(server.URL)
+	k := New(zap.NewNop(), Config{DataDir: dir}, s)
+	react := func(by, author, reaction string) {
+		k.processEvent(context.Background(), event.Callback(&slackevents.ReactionAddedEvent{
+			User: by, ItemUser: author, Reaction: reaction, Item: slackevents.Item{Type: "message", Channel: "C1", Timestamp: "1.1"},
+		}))
+	}
+	unreact := func(by, author, reaction string) {
+		k.processEvent(context.Background(), event.Callback(&slackevents.ReactionRemovedEvent{
+			User: by, ItemUser: author, Reaction: reaction, Item: slackevents.Item{Type: "message", Channel: "C1", Timestamp: "1.1"},
+		}))
+	}
+
+	react("U2", "U1", "+1")
+	react("U3", "U1", "heart")
+	react("U4", "U1", "+1::skin-tone-3")
+	react("U1", "U1", "+1")
+	react("U3", "U2", "tada")
+	react("U4", "U3", "-1")
+	react("U4", "U3", "eyes")
+	unreact("U3", "U1", "heart")
+
+	if got := k.Score("U1"); got.Karma != 2 || got.Rank != 1 {
+		t.Errorf("Score(U1) = %+v, want 2 karma ranked first without the self-vote or removed heart", got)
+	}
+	if got := k.Score("U3"); got.Karma != -1 || got.Rank != 3 {
+		t.Errorf("Score(U3) = %+v, want -1 karma ranked third", got)
+	}
+	if got := k.Score("U9"); got.Rank != 0 {
+		t.Errorf("Score(U9) = %+v, want no rank for someone without karma", got)
+	}
+
+	k.processEvent(context.Background(), event.Callback(&slackevents.MessageEvent{
+		User: "U5", Channel: "C1", ChannelType: "channel", Text: "karma <@U1>?", TimeStamp: "2.2",
+	}))
+	k.processEvent(context.Background(), event.Callback(&slackevents.AppMentionEvent{
+		User: "U5", Channel: "C1", Text: "<@UBOT> karma", TimeStamp: "3.3",
+	}))
+	// The mention's message event is left to app_mention
+	k.processEvent(context.Background(), event.Callback(&slackevents.MessageEvent{
+		User: "U5", Channel: "C1", ChannelType: "channel", Text: "<@UBOT> karma", TimeStamp: "3.3",
+	}))
+	k.processEvent(context.Background(), event.Callback(&slackevents.MessageEvent{
+		User: "U5", Channel: "C1", ChannelType: "channel", Text: "good karma today", TimeStamp: "4.4",
+	}))
+	if len(posts) != 2 {
+		t.Fatalf("posts = %q, want a score and a leaderboard", posts)
+	}
+	if posts[0] != "2.2: <@U1> has 2 karma, #1 on the leaderboard." {
+		t.Errorf("score = %q", posts[0])
+	}
+	if want := "3.3: 🏆 *Karma leaderboard*\n1. <@U1> 2\n2. <@U2> 1\n3. <@U3> -1"; posts[1] != want {
+		t.Errorf("leaderboard = %q, want %q", posts[1], want)
+	}
+
+	if got := k.HandleCommand(context.Background(), slack.SlashCommand{UserID: "U2"}, []string{"me"}); !strings.Contains(got, "<@U2> has 1 karma, #2") {
+		t.Errorf("HandleCommand(me) = %q", got)
+	}
+	if got := k.HandleCommand(context.Background(), slack.SlashCommand{}, []string{"<@U3|carol>"}); !strings.Contains(got, "<@U3> has -1 karma") {
+		t.Errorf("HandleCommand(<@U3|carol>) = %q", got)
+	}
+	if got := k.HandleCommand(context.Background(), slack.SlashCommand{}, []string{"@carol"}); got != usage {
+		t.Errorf("HandleCommand(@carol) = %q, want the usage", got)
+	}
+
+	k.dirty = true
+	k.save()
+	reloaded := New(zap.NewNop(), Config{DataDir: dir}, s)
+	if got := reloaded.Score("U1"); got.Karma != 2 {
+		t.Errorf("Score(U1) after reload = %+v, want the saved karma", got)
+	}
+}
@@ -0,0 +1,580 @@
+// Package lastseen keeps when each person last posted in a channel, from the event
+// stream, to answer "@bot when was @alice last active" and to post a weekly report of the
+// members of chosen channels who have gone quiet. Only the time of someone's last message
+// is kept, not where or what they posted. Anyone can opt out with "@bot opt out of
+// activity tracking", which forgets them and stops tracking them until they opt back in.
+package lastseen
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/timeformat"
+)
+
+const (
+	stateFile = "lastseen.json"
+
+	DefaultInactiveAfter = 30 * 24 * time.Hour
+	DefaultRetention     = 180 * 24 * time.Hour
+	DefaultReportDay     = time.Monday
+	DefaultReportHour    = 9
+
+	// checkInterval is how often last seen times are saved and the weekly report is checked
+	checkInterval = 5 * time.Minute
+	// usersInfoBatch is how many users are looked up per users.info call
+	usersInfoBatch = 30
+)
+
+const mention = `(?i)^\s*(?:<@[A-Z0-9]+>[\s,:]*)?`
+
+var (
+	// lookupPattern matches "<@U123> when was <@U456> last active?" or "<@U123> last seen <@U456>"
+	lookupPattern = regexp.MustCompile(mention + `(?:when\s+(?:was|did)\s+<@([A-Z0-9]+)(?:\|[^>]*)?>\s+last\b|last\s+seen\s+<@([A-Z0-9]+)(?:\|[^>]*)?>)`)
+	// optPattern matches "<@U123> opt out of activity tracking" and "<@U123> opt in to activity tracking"
+	optPattern = regexp.MustCompile(mention + `opt[\s-]*(out|in)\s+(?:of|to|from|for)\s+activity(?:\s+tracking)?\s*[.!]*\s*$`)
+)
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Channels limits tracking to these channel IDs, empty tracks every channel the bot is in
+	Channels []string `json:"channels" yaml:"channels"`
+	// Exact answers with how many hours ago someone was last active, not just the day
+	Exact *bool `json:"exact" yaml:"exact"`
+	// OptOut are user IDs never tracked, besides those who opt out themselves
+	OptOut []string `json:"opt_out" yaml:"opt_out"`
+	// Retention is how long a last seen time is kept, defaults to 180 days
+	Retention *time.Duration `json:"retention" yaml:"retention"`
+	// ReportChannels are the channels whose inactive members are reported weekly
+	ReportChannels []string `json:"report_channels" yaml:"report_channels"`
+	// ReportTo is where the weekly report is posted, e.g. an admin channel
+	ReportTo *string `json:"report_to" yaml:"report_to"`
+	// InactiveAfter is how long without a message makes a member inactive, defaults to 30 days
+	InactiveAfter *time.Duration `json:"inactive_after" yaml:"inactive_after"`
+	// ReportDay and ReportHour are when the report is posted, in local time, defaults to
+	// Monday at 9
+	ReportDay  *string        `json:"report_day" yaml:"report_day"`
+	ReportHour *int           `json:"report_hour" yaml:"report_hour"`
+	Persona    persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	Enabled        bool
+	DataDir        string
+	Channels       []string
+	Exact          bool
+	OptOut         []string
+	Retention      time.Duration
+	ReportChannels []string
+	ReportTo       string
+	InactiveAfter  time.Duration
+	ReportDay      time.Weekday
+	ReportHour     int
+	Persona        persona.Config // Name and icon answers and reports are posted with
+}
+
+type state struct {
+	Users      map[string]time.Time `json:"users"`     // User ID -> last message
+	OptedOut   map[string]time.Time `json:"opted_out"` // User ID -> when they opted out
+	Since      time.Time            `json:"since"`     // When tracking began
+	LastReport time.Time            `json:"last_report,omitzero"`
+}
+
+// Heartbeat keeps when people were last active and answers mentions asking about them
+type Heartbeat struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	path        string
+	mu          sync.Mutex
+	state       state
+	dirty       bool
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Heartbeat {
+	if c.Retention <= 0 {
+		c.Retention = DefaultRetention
+	}
+	if c.InactiveAfter <= 0 {
+		c.InactiveAfter = DefaultInactiveAfter
+	}
+	h := &Heartbeat{
+		log:      log,
+		config:   c,
+		slack:    s,
+		path:     filepath.Join(c.DataDir, stateFile),
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+	h.load()
+	return h
+}
+
+// SetStatusTracker sets where the heartbeat reports its activity
+func (h *Heartbeat) SetStatusTracker(t *status.Tracker) {
+	h.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (h *Heartbeat) ProcessorType() string {
+	return "lastseen"
+}
+
+func (h *Heartbeat) Start(ctx context.Context) error {
+	h.isConnected.Store(true)
+	go h.handleEvents(ctx)
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.save()
+				h.reportIfDue(ctx)
+			}
+		}
+	}()
+	h.log.Debug("Last seen tracking started.",
+		zap.Strings("report_channels", h.config.ReportChannels),
+		zap.Stringer("report_day", h.config.ReportDay),
+		zap.Int("report_hour", h.config.ReportHour))
+	return nil
+}
+
+// Stop saves the last seen times collected since the last save
+func (h *Heartbeat) Stop(ctx context.Context) error {
+	if !h.isConnected.Load() {
+		return nil
+	}
+	close(h.stopCh)
+	h.isConnected.Store(false)
+	h.save()
+	return nil
+}
+
+// Answers reports whether the heartbeat replies to the mention text
+func (h *Heartbeat) Answers(channelID, text string) bool {
+	return lookupPattern.MatchString(text) || optPattern.MatchString(text)
+}
+
+// LastSeen returns when the user last posted, unless they opted out or weren't seen
+func (h *Heartbeat) LastSeen(userID string) (time.Time, bool) {
+	if h.optedOut(userID) {
+		return time.Time{}, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.state.Users[userID]
+	return t, ok
+}
+
+// PushEvent adds an event to be processed by the heartbeat
+func (h *Heartbeat) PushEvent(e event.Event) {
+	if !h.isConnected.Load() {
+		return
+	}
+
+	select {
+	case h.eventsCh <- e:
+	default:
+		h.log.Warn("Last seen events channel full, dropping event.")
+		h.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (h *Heartbeat) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-h.eventsCh:
+			h.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (h *Heartbeat) processEvent(ctx context.Context, e event.Event) {
+	if e.FromBot() || e.User == "" {
+		return
+	}
+	switch e.Data().(type) {
+	case *slackevents.MessageEvent:
+		h.track(e)
+	case *slackevents.AppMentionEvent:
+		h.track(e)
+		h.handleMention(ctx, e)
+	}
+}
+
+// track records a message in a channel as the user's latest. DMs aren't tracked.
+func (h *Heartbeat) track(e event.Event) {
+	if e.ChannelType == "im" || e.ChannelType == "mpim" || e.Channel == "" {
+		return
+	}
+	if e.SubType != "" && e.SubType != "thread_broadcast" && e.SubType != "file_share" {
+		return
+	}
+	if len(h.config.Channels) > 0 && !slices.Contains(h.config.Channels, e.Channel) {
+		return
+	}
+	if h.optedOut(e.User) {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now := h.now(); now.After(h.state.Users[e.User]) {
+		h.state.Users[e.User] = now
+		h.dirty = true
+	}
+}
+
+func (h *Heartbeat) handleMention(ctx context.Context, e event.Event) {
+	threadTS := e.ThreadTS
+	if threadTS == "" {
+		threadTS = e.TS
+	}
+	if m := optPattern.FindStringSubmatch(e.Text); m != nil {
+		h.status.Event()
+		h.setOptOut(e.User, strings.EqualFold(m[1], "out"))
+		text := "👋 You've opted out of activity tracking. I've forgotten when you were last active and won't keep track until you opt back in."
+		if strings.EqualFold(m[1], "in") {
+			text = "👍 You've opted in to activity tracking again."
+		}
+		// Only the user needs to see their choice
+		_, err := h.slack.Client().PostEphemeralContext(ctx, e.Channel, e.User,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionTS(e.ThreadTS),
+		)
+		if err != nil {
+			err = conversation.Error("chat.postEphemeral", conversation.KindFromID(e.Channel), err)
+			h.status.PostFailed(e.Channel, err)
+			h.log.Error("Failed to confirm activity tracking choice", zap.String("channel", e.Channel), zap.Error(err))
+		}
+		return
+	}
+	if m := lookupPattern.FindStringSubmatch(e.Text); m != nil {
+		h.status.Event()
+		h.post(ctx, e.Channel, threadTS, h.answer(cmp.Or(m[1], m[2])))
+	}
+}
+
+// answer describes when the user was last active
+func (h *Heartbeat) answer(userID string) string {
+	if h.optedOut(userID) {
+		return fmt.Sprintf("<@%s> has opted out of activity tracking.", userID)
+	}
+	h.mu.Lock()
+	seen, ok := h.state.Users[userID]
+	since := h.state.Since
+	h.mu.Unlock()
+	if !ok {
+		return fmt.Sprintf("I haven't seen <@%s> post since %s.", userID, timeformat.Slack(since, timeformat.Date))
+	}
+	return fmt.Sprintf("<@%s> was last active %s.", userID, h.ago(seen))
+}
+
+// ago describes a time in the past to the day, or to the hour with Exact
+func (h *Heartbeat) ago(t time.Time) string {
+	now := h.now()
+	if h.config.Exact {
+		switch d := now.Sub(t); {
+		case d < time.Hour:
+			return "within the last hour"
+		case d < 2*time.Hour:
+			return "an hour ago"
+		case d < 24*time.Hour:
+			return fmt.Sprintf("%d hours ago", int(d.Hours()))
+		}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+	switch days := int(today.Sub(day).Hours() / 24); {
+	case days <= 0:
+		return "today"
+	case days == 1:
+		return "yesterday"
+	case days < 14:
+		return fmt.Sprintf("%d days ago", days)
+	default:
+		return "on " + timeformat.Slack(t, timeformat.Date)
+	}
+}
+
+// optedOut reports whether the user opted out, themselves or in config
+func (h *Heartbeat) optedOut(userID string) bool {
+	if slices.Contains(h.config.OptOut, userID) {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.state.OptedOut[userID]
+	return ok
+}
+
+// setOptOut opts the user out, forgetting when they were last active, or back in
+func (h *Heartbeat) setOptOut(userID string, out bool) {
+	h.mu.Lock()
+	if out {
+		h.state.OptedOut[userID] = h.now()
+		delete(h.state.Users, userID)
+	} else {
+		delete(h.state.OptedOut, userID)
+	}
+	h.dirty = true
+	h.mu.Unlock()
+	h.save()
+	h.log.Info("Activity tracking choice changed", zap.String("user", userID), zap.Bool("opted_out", out))
+}
+
+func (h *Heartbeat) post(ctx context.Context, channelID, threadTS, text string) {
+	_, _, err := h.slack.Client().PostMessageContext(ctx, channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(threadTS),
+		h.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = conversation.Error("chat.postMessage", conversation.KindFromID(channelID), err)
+		h.status.PostFailed(channelID, err)
+		h.log.Error("Failed to post last seen answer", zap.String("channel", channelID), zap.Error(err))
+		return
+	}
+	h.status.Posted()
+}
+
+// Inactive is a channel's members who haven't posted for the inactive period
+type Inactive struct {
+	Channel string
+	Members []InactiveMember // Longest inactive first
+}
+
+type InactiveMember struct {
+	UserID   string
+	LastSeen time.Time // Zero when they haven't posted since tracking began
+}
+
+// InactiveMembers returns the members of the channel who haven't posted for the inactive
+// period. Bots and people who opted out are left out, and so are members never seen
+// until tracking has run for the whole period.
+func (h *Heartbeat) InactiveMembers(ctx context.Context, channelID string) (Inactive, error) {
+	inactive := Inactive{Channel: channelID}
+	members, err := h.listMembers(ctx, channelID)
+	if err != nil {
+		return inactive, err
+	}
+	members = slices.DeleteFunc(members, h.optedOut)
+	people := h.people(ctx, members)
+
+	cutoff := h.now().Add(-h.config.InactiveAfter)
+	h.mu.Lock()
+	trackedLongEnough := !h.state.Since.After(cutoff)
+	for _, userID := range people {
+		seen, ok := h.state.Users[userID]
+		switch {
+		case ok && seen.Before(cutoff):
+			inactive.Members = append(inactive.Members, InactiveMember{UserID: userID, LastSeen: seen})
+		case !ok && trackedLongEnough:
+			inactive.Members = append(inactive.Members, InactiveMember{UserID: userID})
+		}
+	}
+	h.mu.Unlock()
+	slices.SortFunc(inactive.Members, func(a, b InactiveMember) int {
+		return cmp.Or(a.LastSeen.Compare(b.LastSeen), strings.Compare(a.UserID, b.UserID))
+	})
+	return inactive, nil
+}
+
+func (h *Heartbeat) listMembers(ctx context.Context, channelID string) ([]string, error) {
+	var members []string
+	params := &slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: 200}
+	for {
+		page, cursor, err := h.slack.Client().GetUsersInConversationContext(ctx, params)
+		if err != nil {
+			return nil, conversation.Error("conversations.members", conversation.KindFromID(channelID), err)
+		}
+		members = append(members, page...)
+		if cursor == "" {
+			return members, nil
+		}
+		params.Cursor = cursor
+	}
+}
+
+// people leaves bots and deactivated accounts out of the user IDs. When they can't be
+// looked up every user is kept.
+func (h *Heartbeat) people(ctx context.Context, userIDs []string) []string {
+	var people []string
+	for batch := range slices.Chunk(userIDs, usersInfoBatch) {
+		users, err := h.slack.Client().GetUsersInfoContext(ctx, batch...)
+		if err != nil {
+			h.log.Warn("Failed to look up members, reporting them all", zap.Error(errs.NewSlackAPIError("users.info", err)))
+			people = append(people, batch...)
+			continue
+		}
+		for _, u := range *users {
+			if !u.IsBot && !u.Deleted && u.ID != "USLACKBOT" {
+				people = append(people, u.ID)
+			}
+		}
+	}
+	return people
+}
+
+// reportIfDue posts the inactive members of the report channels, once a week
+func (h *Heartbeat) reportIfDue(ctx context.Context) {
+	if h.config.ReportTo == "" || len(h.config.ReportChannels) == 0 {
+		return
+	}
+	h.mu.Lock()
+	due, ok := scheduler.WeeklyDue(h.now(), h.state.LastReport, h.config.ReportDay, h.config.ReportHour)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	var reports []Inactive
+	for _, channelID := range h.config.ReportChannels {
+		inactive, err := h.InactiveMembers(ctx, channelID)
+		if err != nil {
+			h.status.PostFailed(channelID, err)
+			h.log.Error("Failed to list inactive members", zap.String("channel", channelID), zap.Error(err))
+			return
+		}
+		reports = append(reports, inactive)
+	}
+	_, _, err := h.slack.Client().PostMessageContext(ctx, h.config.ReportTo,
+		slack.MsgOptionText(h.formatReport(reports), false),
+		h.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = conversation.Error("chat.postMessage", conversation.KindFromID(h.config.ReportTo), err)
+		h.status.PostFailed(h.config.ReportTo, err)
+		h.log.Error("Failed to post inactive members report", zap.String("channel", h.config.ReportTo), zap.Error(err))
+		return
+	}
+	h.status.Posted()
+	h.log.Info("Posted inactive members report", zap.Strings("channels", h.config.ReportChannels))
+
+	h.mu.Lock()
+	h.state.LastReport = due
+	h.dirty = true
+	h.mu.Unlock()
+	h.save()
+}
+
+// formatReport renders the inactive members of each channel as a Slack message
+func (h *Heartbeat) formatReport(reports []Inactive) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "💤 *Inactive members*, no messages in %d days\n", int(h.config.InactiveAfter.Hours()/24))
+	for _, r := range reports {
+		if len(r.Members) == 0 {
+			fmt.Fprintf(&b, "• <#%s>: everyone's been active\n", r.Channel)
+			continue
+		}
+		members := make([]string, 0, len(r.Members))
+		for _, m := range r.Members {
+			if m.LastSeen.IsZero() {
+				members = append(members, fmt.Sprintf("<@%s> (not seen)", m.UserID))
+				continue
+			}
+			members = append(members, fmt.Sprintf("<@%s> (%s)", m.UserID, h.ago(m.LastSeen)))
+		}
+		fmt.Fprintf(&b, "• <#%s>: %s\n", r.Channel, strings.Join(members, ", "))
+	}
+	return b.String()
+}
+
+func (h *Heartbeat) load() {
+	h.state = state{Users: make(map[string]time.Time), OptedOut: make(map[string]time.Time)}
+	data, err := os.ReadFile(h.path)
+	switch {
+	case os.IsNotExist(err):
+		h.state.Since = h.now()
+		h.dirty = true
+		return
+	case err != nil:
+		h.log.Error("Failed to read last seen times", zap.Error(err), zap.String("path", h.path))
+		h.state.Since = h.now()
+		return
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		h.log.Error("Failed to unmarshal last seen times", zap.Error(err), zap.String("path", h.path))
+		h.state.Since = h.now()
+		return
+	}
+	if s.Users == nil {
+		s.Users = make(map[string]time.Time)
+	}
+	if s.OptedOut == nil {
+		s.OptedOut = make(map[string]time.Time)
+	}
+	if s.Since.IsZero() {
+		s.Since = h.now()
+	}
+	h.state = s
+}
+
+// save writes the last seen times when they changed, dropping those past retention
+func (h *Heartbeat) save() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.dirty {
+		return
+	}
+	cutoff := h.now().Add(-h.config.Retention)
+	for userID, seen := range h.state.Users {
+		if seen.Before(cutoff) {
+			delete(h.state.Users, userID)
+		}
+	}
+	data, err := json.Marshal(h.state)
+	if err != nil {
+		h.log.Error("Failed to marshal last seen times", zap.Error(err))
+		return
+	}
+	tempFile := h.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		h.log.Error("Failed to save last seen times", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, h.path); err != nil {
+		h.log.Error("Failed to save last seen times", zap.Error(&errs.StorageError{Op: "rename", Path: h.path, Err: err}))
+		return
+	}
+	h.dirty = false
+}
@@ -0,0 +1,138 @@
+package lastseen
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+	"slackbot.arpa/tools/timeformat"
+)
+
+func TestHeartbeat_LastSeen(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts = append(posts, r.URL.Path+" "+r.FormValue("channel")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(slacktest.Posted))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2026, time.March, 10, 12, 0, 0, 0, time.Local)
+	s := slacktest.New(server.URL)
+	h := New(zap.NewNop(), Config{DataDir: dir, OptOut: []string{"U9"}}, s)
+	h.now = func() time.Time { return now }
+	message := func(user, channel, channelType string) {
+		h.processEvent(context.Background(), event.Callback(&slackevents.MessageEvent{
+			User: user, Channel: channel, ChannelType: channelType, Text: "hi", TimeStamp: "1.1",
+		}))
+	}
+	ask := func(user, text string) string {
+		posts = nil
+		h.processEvent(context.Background(), event.Callback(&slackevents.AppMentionEvent{User: user, Channel: "C1", Text: text, TimeStamp: "2.2"}))
+		if len(posts) != 1 {
+			t.Fatalf("posts = %v after %q, want one reply", posts, text)
+		}
+		return posts[0]
+	}
+
+	message("U1", "C1", "channel")
+	now = now.Add(3 * 24 * time.Hour)
+	message("U2", "D1", "im")
+	message("U9", "C1", "channel")
+
+	if got := ask("U3", "<@UBOT> when was <@U1> last active?"); !strings.Contains(got, "<@U1> was last active 3 days ago") {
+		t.Errorf("lookup reply = %q, want 3 days ago", got)
+	}
+	if got := ask("U3", "<@UBOT> last seen <@U2>"); !strings.Contains(got, "haven't seen <@U2>") {
+		t.Errorf("lookup reply = %q, want DMs left untracked", got)
+	}
+	if got := ask("U3", "<@UBOT> when did <@U9|bob> last post"); !strings.Contains(got, "opted out") {
+		t.Errorf("lookup reply = %q, want the configured opt out honored", got)
+	}
+
+	// Last seen times survive a restart
+	h.save()
+	h = New(zap.NewNop(), Config{DataDir: dir}, s)
+	h.now = func() time.Time { return now }
+	if seen, ok := h.LastSeen("U1"); !ok || !seen.Equal(now.Add(-3*24*time.Hour)) {
+		t.Fatalf("LastSeen() after reloading = %v, %v, want U1's message", seen, ok)
+	}
+
+	// Opting out forgets the user and stops tracking them
+	if got := ask("U1", "<@UBOT> opt out of activity tracking"); !strings.HasPrefix(got, "/chat.postEphemeral") {
+		t.Errorf("opt out reply = %q, want an ephemeral acknowledgement", got)
+	}
+	message("U1", "C1", "channel")
+	if _, ok := h.LastSeen("U1"); ok {
+		t.Error("LastSeen() after opting out = true")
+	}
+	if got := ask("U3", "<@UBOT> when was <@U1> last active?"); !strings.Contains(got, "opted out") {
+		t.Errorf("lookup reply = %q, want the opt out honored", got)
+	}
+	ask("U1", "<@UBOT> opt in to activity tracking")
+	message("U1", "C1", "channel")
+	if _, ok := h.LastSeen("U1"); !ok {
+		t.Error("LastSeen() after opting back in = false")
+	}
+
+	if !h.Answers("C1", "<@UBOT> when was <@U1> last active?") || h.Answers("C1", "<@UBOT> what's for lunch?") {
+		t.Error("Answers() should only match last seen questions")
+	}
+}
+
+func TestHeartbeat_Report(t *testing.T) {
+	var report string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/conversations.members":
+			_, _ = w.Write([]byte(`{"ok": true, "members": ["U1", "U2", "U3", "UBOT", "U9"]}`))
+		case "/users.info":
+			_, _ = w.Write([]byte(`{"ok": true, "users": [{"id": "U1"}, {"id": "U2"}, {"id": "U3"}, {"id": "UBOT", "is_bot": true}]}`))
+		case "/chat.postMessage":
+			report = r.FormValue("channel") + ": " + r.FormValue("text")
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "CADMIN", "ts": "9.9"}`))
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.Local) // A Monday
+	s := slacktest.New(server.URL)
+	h := New(zap.NewNop(), Config{
+		DataDir:        t.TempDir(),
+		OptOut:         []string{"U9"},
+		ReportChannels: []string{"C1"},
+		ReportTo:       "CADMIN",
+		ReportDay:      time.Monday,
+		ReportHour:     9,
+	}, s)
+	h.now = func() time.Time { return now }
+	h.state.Since = now.Add(-60 * 24 * time.Hour)
+	h.state.Users["U1"] = now.Add(-45 * 24 * time.Hour)
+	h.state.Users["U2"] = now.Add(-24 * time.Hour)
+
+	h.reportIfDue(context.Background())
+	if !strings.HasPrefix(report, "CADMIN: ") || !strings.Contains(report, "<@U1> (on "+timeformat.Slack(h.state.Users["U1"], timeformat.Date)+")") || !strings.Contains(report, "<@U3> (not seen)") {
+		t.Errorf("report = %q, want U1 and U3 inactive", report)
+	}
+	for _, quiet := range []string{"<@U2>", "<@UBOT>", "<@U9>"} {
+		if strings.Contains(report, quiet) {
+			t.Errorf("report = %q, want %s left out", report, quiet)
+		}
+	}
+
+	// Only once a week
+	report = ""
+	h.reportIfDue(context.Background())
+	if report != "" {
+		t.Errorf("report = %q, want nothing until next week", report)
+	}
+}
@@ -0,0 +1,268 @@
+// Package leader elects one of several replicas to respond to Slack events. Replicas
+// share a lease key in Redis with a TTL; the holder renews it, and a follower takes it
+// over once it expires, so a replica that dies or hangs hands over on its own. Redis
+// expires the key on its own clock, so the replicas' clocks don't need to agree.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	DefaultKey = "slackbot:leader"
+	DefaultTTL = 30 * time.Second
+	// MinTTL leaves room to renew a few times within a lease
+	MinTTL = 3 * time.Second
+
+	commandTimeout = 5 * time.Second
+)
+
+// renewScript extends the lease only while this replica still holds it
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes the lease only while this replica still holds it
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Address is the Redis server every replica shares, as host:port
+	Address  string `json:"address" yaml:"address"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// Key is the lease's key, defaults to slackbot:leader
+	Key *string `json:"key" yaml:"key"`
+	// TTL is how long a lease lasts without renewal, so how long failover takes
+	TTL *time.Duration `json:"ttl" yaml:"ttl"`
+	// ID names this replica, defaults to the hostname and process ID
+	ID *string `json:"id" yaml:"id"`
+}
+
+type Config struct {
+	Enabled  bool
+	Address  string
+	Username string
+	Password string
+	Key      string
+	TTL      time.Duration
+	ID       string
+}
+
+// DefaultID names a replica by its hostname and process ID
+func DefaultID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "slackbot"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Status is a replica's view of the lease
+type Status struct {
+	ID        string    `json:"id"`
+	Leader    bool      `json:"leader"`
+	Holder    string    `json:"holder,omitempty"` // Replica holding the lease when last checked
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Error     string    `json:"error,omitempty"` // Last failure to reach Redis
+}
+
+// Elector holds or waits for the lease
+type Elector struct {
+	log         *zap.Logger
+	config      Config
+	client      *redis.Client
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	status      *status.Tracker
+
+	mu            sync.Mutex
+	leader        bool
+	expires       time.Time // When this replica's lease runs out unless renewed, on its own clock
+	holder        string
+	holderExpires time.Time
+	lastErr       error
+}
+
+func New(log *zap.Logger, c Config) *Elector {
+	if c.Key == "" {
+		c.Key = DefaultKey
+	}
+	if c.TTL <= 0 {
+		c.TTL = DefaultTTL
+	}
+	if c.ID == "" {
+		c.ID = DefaultID()
+	}
+	return &Elector{
+		log:    log,
+		config: c,
+		client: redis.NewClient(&redis.Options{
+			Addr:         c.Address,
+			Username:     c.Username,
+			Password:     c.Password,
+			DialTimeout:  commandTimeout,
+			ReadTimeout:  commandTimeout,
+			WriteTimeout: commandTimeout,
+			// The next campaign is the retry
+			MaxRetries: -1,
+		}),
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// SetStatusTracker sets where leadership changes and lease failures are reported
+func (e *Elector) SetStatusTracker(t *status.Tracker) {
+	e.status = t
+}
+
+// Start tries to take the lease right away, then renews or retries it a few times per TTL
+func (e *Elector) Start(ctx context.Context) error {
+	e.isConnected.Store(true)
+	e.campaign(ctx)
+	go func() {
+		ticker := time.NewTicker(e.config.TTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.campaign(ctx)
+			}
+		}
+	}()
+	e.log.Debug("Leader election started", zap.String("id", e.config.ID), zap.Duration("ttl", e.config.TTL))
+	return nil
+}
+
+// Stop releases the lease, so a follower takes over without waiting for it to expire
+func (e *Elector) Stop(ctx context.Context) error {
+	if !e.isConnected.Load() {
+		return nil
+	}
+	close(e.stopCh)
+	e.isConnected.Store(false)
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+	if wasLeader {
+		if err := releaseScript.Run(ctx, e.client, []string{e.config.Key}, e.config.ID).Err(); err != nil {
+			e.log.Warn("Failed to release leadership", zap.Error(err))
+		}
+	}
+	return e.client.Close()
+}
+
+// campaign takes the lease when no one holds it and renews it when it's ours
+func (e *Elector) campaign(ctx context.Context) {
+	// Timed from before the request, so this replica's lease runs out no later than the key
+	now := e.now()
+	expires := now.Add(e.config.TTL)
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.mu.Unlock()
+
+	holder, holderTTL, err := e.acquire(ctx, wasLeader)
+
+	e.mu.Lock()
+	if err != nil {
+		// Keep leading until the lease we hold runs out, since no one else can take it sooner
+		e.lastErr = fmt.Errorf("renew lease: %w", err)
+		e.leader = e.leader && now.Before(e.expires)
+	} else {
+		e.lastErr = nil
+		e.holder, e.holderExpires = holder, now.Add(holderTTL)
+		e.leader = holder == e.config.ID
+		if e.leader {
+			e.expires = expires
+		}
+	}
+	leader, lastErr := e.leader, e.lastErr
+	e.mu.Unlock()
+
+	if lastErr != nil {
+		e.status.Error(lastErr)
+		e.log.Warn("Failed to renew leader lease", zap.Error(lastErr))
+	}
+	switch {
+	case leader && !wasLeader:
+		e.status.Event()
+		e.log.Info("Became leader, responding to events", zap.String("id", e.config.ID))
+	case !leader && wasLeader:
+		e.status.Event()
+		e.log.Warn("Lost leadership, ignoring events", zap.String("id", e.config.ID), zap.String("holder", holder))
+	}
+}
+
+// acquire renews the lease this replica holds or takes it with SET NX PX, then returns
+// who holds it and for how much longer
+func (e *Elector) acquire(ctx context.Context, renew bool) (string, time.Duration, error) {
+	ttl := e.config.TTL.Milliseconds()
+	if renew {
+		if err := renewScript.Run(ctx, e.client, []string{e.config.Key}, e.config.ID, ttl).Err(); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := e.client.SetNX(ctx, e.config.Key, e.config.ID, e.config.TTL).Err(); err != nil {
+		return "", 0, err
+	}
+	var holder *redis.StringCmd
+	var remaining *redis.DurationCmd
+	if _, err := e.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		holder = p.Get(ctx, e.config.Key)
+		remaining = p.PTTL(ctx, e.config.Key)
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return "", 0, err
+	}
+	return holder.Val(), max(remaining.Val(), 0), nil
+}
+
+// IsLeader reports whether this replica holds an unexpired lease
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && e.now().Before(e.expires)
+}
+
+// AllowEvent passes events on to processors only on the leader, so followers stay
+// connected and configured without responding
+func (e *Elector) AllowEvent(event.Event) bool {
+	return e.IsLeader()
+}
+
+// Leadership reports the replica's view of the lease for /health
+func (e *Elector) Leadership() Status {
+	leader := e.IsLeader()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := Status{ID: e.config.ID, Leader: leader, Holder: e.holder, ExpiresAt: e.holderExpires}
+	if e.lastErr != nil {
+		s.Error = e.lastErr.Error()
+	}
+	return s
+}
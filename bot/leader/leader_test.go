@@ -0,0 +1,123 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+)
+
+func newElector(t *testing.T, addr, id string, now *time.Time) *Elector {
+	e := New(zap.NewNop(), Config{Enabled: true, Address: addr, TTL: 30 * time.Second, ID: id})
+	e.now = func() time.Time { return *now }
+	t.Cleanup(func() { _ = e.Stop(context.Background()) })
+	return e
+}
+
+func TestElector_Failover(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		server.FastForward(d)
+	}
+	a := newElector(t, server.Addr(), "a", &now)
+	b := newElector(t, server.Addr(), "b", &now)
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("IsLeader() a = %v, b = %v, want only a leading", a.IsLeader(), b.IsLeader())
+	}
+	if got := b.Leadership(); got.Holder != "a" || got.Leader || !got.ExpiresAt.Equal(now.Add(30*time.Second)) {
+		t.Errorf("b.Leadership() = %+v, want a as the holder", got)
+	}
+
+	// Renewing keeps the lease past the first TTL
+	advance(20 * time.Second)
+	a.campaign(ctx)
+	advance(20 * time.Second)
+	b.campaign(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("after renewal IsLeader() a = %v, b = %v, want only a leading", a.IsLeader(), b.IsLeader())
+	}
+
+	// a stops renewing, so b takes over once the lease expires
+	advance(31 * time.Second)
+	if a.IsLeader() {
+		t.Error("a.IsLeader() = true after its lease expired")
+	}
+	b.campaign(ctx)
+	a.campaign(ctx)
+	if a.IsLeader() || !b.IsLeader() {
+		t.Fatalf("after expiry IsLeader() a = %v, b = %v, want only b leading", a.IsLeader(), b.IsLeader())
+	}
+	if a.AllowEvent(event.Event{}) || !b.AllowEvent(event.Event{}) {
+		t.Error("AllowEvent() should only pass events on the leader")
+	}
+}
+
+func TestElector_IgnoresClockSkew(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	ahead := now.Add(time.Hour) // b's clock runs an hour fast
+	a := newElector(t, server.Addr(), "a", &now)
+	b := newElector(t, server.Addr(), "b", &ahead)
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Errorf("IsLeader() a = %v, b = %v, want a to keep the lease it holds", a.IsLeader(), b.IsLeader())
+	}
+}
+
+func TestElector_StopReleases(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	a := newElector(t, server.Addr(), "a", &now)
+	b := newElector(t, server.Addr(), "b", &now)
+
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	b.campaign(ctx)
+	if b.IsLeader() {
+		t.Fatal("b.IsLeader() = true while a holds the lease")
+	}
+	if err := a.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	// b takes over at its next renewal without waiting for the TTL
+	b.campaign(ctx)
+	if !b.IsLeader() {
+		t.Error("b.IsLeader() = false after a released the lease")
+	}
+}
+
+func TestElector_RedisDown(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	a := newElector(t, server.Addr(), "a", &now)
+	a.campaign(ctx)
+
+	// The leader keeps its lease until it runs out, since no one can take it sooner
+	server.Close()
+	now = now.Add(10 * time.Second)
+	a.campaign(ctx)
+	if !a.IsLeader() || a.Leadership().Error == "" {
+		t.Errorf("IsLeader() = %v, Leadership() = %+v, want leading with the error", a.IsLeader(), a.Leadership())
+	}
+	now = now.Add(25 * time.Second)
+	a.campaign(ctx)
+	if a.IsLeader() {
+		t.Error("IsLeader() = true after the lease ran out without Redis")
+	}
+}
@@ -0,0 +1,460 @@
+package bot
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/aichat"
+	"slackbot.arpa/bot/chat"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/personastore"
+	"slackbot.arpa/bot/replylog"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/vibecheck"
+)
+
+// Chat, vibecheck, aichat and the scheduler follow the config file while the bot runs.
+// Each is started when its config is added and stopped when it's removed, while the HTTP
+// server keeps serving. Chat, vibecheck and the scheduler are restarted when their config
+// changes, and aichat takes persona changes in place so users keep their assigned personas.
+
+const (
+	// stopTimeout bounds stopping a service a config change turned off or replaced
+	stopTimeout = 10 * time.Second
+	// maxHeldEvents caps the events kept for a processor while it restarts
+	maxHeldEvents = 100
+)
+
+// appliedConfig is the config the hot-reloaded services were last created with
+type appliedConfig struct {
+	chat      chat.Config
+	vibecheck vibecheck.Config
+	reactions vibecheck.FileConfig
+	scheduler scheduler.Config
+}
+
+// newChat creates chat when there are responses or reaction thresholds configured
+func (s *Bot) newChat(c chat.Config) *chat.Chat {
+	s.applied.chat = c
+	if len(c.Responses)+len(c.ReactionThresholds) == 0 {
+		return nil
+	}
+	return chat.NewChat(s.logger.Named("chat"), c, s.slack.For("chat"))
+}
+
+// newVibecheck creates vibecheck when there are reactions configured
+func (s *Bot) newVibecheck(c vibecheck.Config, reactions vibecheck.FileConfig) *vibecheck.Vibecheck {
+	s.applied.vibecheck = c
+	s.applied.reactions = reactions
+	if len(reactions.GoodReactions) == 0 && len(reactions.BadReactions) == 0 {
+		return nil
+	}
+	v := vibecheck.NewVibecheck(s.logger.Named("vibecheck"), c, s.slack.For("vibecheck"))
+	if s.tasks != nil {
+		v.SetTasks(s.tasks)
+	}
+	_ = v.SetConfig(reactions)
+	return v
+}
+
+// newAIChat creates aichat when an LLM and personas are configured
+func (s *Bot) newAIChat(c aichat.Config) *aichat.AIChat {
+	if s.ai == nil || len(c.Personas) == 0 {
+		return nil
+	}
+	return aichat.NewAIChat(s.logger.Named("aichat"), c, s.slack.For("aichat"), s.ai.For("aichat"))
+}
+
+// newScheduler creates the scheduler when there are schedules configured
+func (s *Bot) newScheduler(c scheduler.Config) *scheduler.Scheduler {
+	s.applied.scheduler = c
+	if len(c.Schedules) == 0 {
+		return nil
+	}
+	sc := scheduler.New(s.logger.Named("scheduler"), c, s.slack.For("scheduler"))
+	if s.ai != nil {
+		sc.SetAI(s.ai.For("scheduler"))
+	}
+	return sc
+}
+
+// startReconciling has config changes start, stop and replace services from now on, and
+// catches up with changes made since Setup
+func (s *Bot) startReconciling(ctx context.Context) {
+	s.servicesMu.Lock()
+	s.runCtx = ctx
+	s.servicesMu.Unlock()
+	s.reconcileServices()
+}
+
+// stopReconciling leaves the services as they are for Shutdown to stop
+func (s *Bot) stopReconciling() {
+	s.servicesMu.Lock()
+	s.runCtx = nil
+	s.servicesMu.Unlock()
+}
+
+// reconcileServices brings chat, vibecheck and aichat in line with the current config
+func (s *Bot) reconcileServices() {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	if s.runCtx == nil {
+		return
+	}
+	s.reconcileChat(s.runCtx, s.configManager.GetChatConfig())
+	s.reconcileVibecheck(s.runCtx, s.configManager.GetVibecheckConfig(), s.configManager.GetFileConfig().Vibecheck)
+	s.reconcileAIChat(s.runCtx, s.configManager.GetAIChatConfig())
+	s.reconcileScheduler(s.runCtx, s.configManager.GetSchedulerConfig())
+}
+
+// reconcileChat restarts chat when its config changed. The running chat is stopped
+// before its replacement is created, since both keep state in the data dir, and the
+// events that arrive meanwhile are held for the replacement.
+func (s *Bot) reconcileChat(ctx context.Context, c chat.Config) {
+	if reflect.DeepEqual(c, s.applied.chat) {
+		return
+	}
+	var held *heldEvents
+	if prev := s.chat; prev != nil {
+		held = s.holdEvents(prev)
+		s.stopService(ctx, "chat", prev.Stop)
+	}
+	s.chat = s.newChat(c)
+	if s.chat != nil {
+		s.wireChat(s.chat)
+		if err := s.chat.Start(ctx); err != nil {
+			s.log.Error("Failed to start chat after a config change", zap.Error(err))
+			s.chat = nil
+		}
+	}
+	switch {
+	case held != nil && s.chat != nil:
+		s.releaseEvents(held, s.chat)
+		s.log.Info("Chat restarted with the changed config", zap.Int("responses", len(c.Responses)))
+	case s.chat != nil:
+		s.http.RegisterEventProcessor(s.chat)
+		s.log.Info("Chat started", zap.Int("responses", len(c.Responses)))
+	case held != nil:
+		s.releaseEvents(held, nil)
+		s.log.Info("Chat stopped - no responses configured")
+	}
+}
+
+// wireChat connects chat to the services it uses, in Setup and when a config change
+// creates it
+func (s *Bot) wireChat(c *chat.Chat) {
+	c.SetStatusTracker(s.status.Feature(c.ProcessorType()))
+	c.SetPresence(s.slack.Presence())
+	c.SetSilencer(s.incident)
+	if s.claims != nil {
+		c.SetClaimer(s.claims)
+	}
+	if s.outbox != nil {
+		c.SetOutbox(s.outbox)
+	}
+	if s.handoff != nil {
+		c.SetHandoffs(s.handoff)
+	}
+	if replies := s.replyLog(); replies != nil {
+		c.SetReplyLog(replies)
+	}
+	if s.explainer != nil {
+		c.SetTracer(s.explainer)
+	}
+}
+
+// reconcileVibecheck restarts vibecheck when its config or reactions changed. Bans are
+// kept in the data dir, so the replacement picks up where the running one left off, and
+// the events that arrive while it restarts are held for it.
+func (s *Bot) reconcileVibecheck(ctx context.Context, c vibecheck.Config, reactions vibecheck.FileConfig) {
+	if reflect.DeepEqual(c, s.applied.vibecheck) && reflect.DeepEqual(reactions, s.applied.reactions) {
+		return
+	}
+	var held *heldEvents
+	if prev := s.vibecheck; prev != nil {
+		held = s.holdEvents(prev)
+		s.stopService(ctx, "vibecheck", prev.Stop)
+	}
+	s.vibecheck = s.newVibecheck(c, reactions)
+	if s.vibecheck != nil {
+		s.wireVibecheck(s.vibecheck)
+		if err := s.vibecheck.Start(ctx); err != nil {
+			s.log.Error("Failed to start vibecheck after a config change", zap.Error(err))
+			s.vibecheck = nil
+		}
+	}
+	switch {
+	case held != nil && s.vibecheck != nil:
+		s.releaseEvents(held, s.vibecheck)
+		s.log.Info("Vibecheck restarted with the changed config")
+	case s.vibecheck != nil:
+		s.http.RegisterEventProcessor(s.vibecheck)
+		s.log.Info("Vibecheck started")
+	case held != nil:
+		s.releaseEvents(held, nil)
+		s.log.Info("Vibecheck stopped - no reactions configured")
+	}
+	if s.api != nil && s.vibecheck == nil {
+		s.api.SetBans(nil)
+	}
+}
+
+// wireVibecheck connects vibecheck to the services it uses, in Setup and when a config
+// change creates it
+func (s *Bot) wireVibecheck(v *vibecheck.Vibecheck) {
+	v.SetStatusTracker(s.status.Feature(v.ProcessorType()))
+	v.SetPresence(s.slack.Presence())
+	v.SetSilencer(s.incident)
+	if s.claims != nil {
+		v.SetClaimer(s.claims)
+	}
+	if s.ai != nil {
+		v.SetAI(s.ai.For("vibecheck"))
+	}
+	if s.api != nil {
+		s.api.SetBans(v)
+	}
+}
+
+// reconcileScheduler restarts the scheduler when the schedules changed
+func (s *Bot) reconcileScheduler(ctx context.Context, c scheduler.Config) {
+	if reflect.DeepEqual(c, s.applied.scheduler) {
+		return
+	}
+	prev := s.scheduler
+	if prev != nil {
+		s.stopService(ctx, "scheduler", prev.Stop)
+	}
+	s.scheduler = s.newScheduler(c)
+	if s.scheduler != nil {
+		s.wireScheduler(s.scheduler)
+		if err := s.scheduler.Start(ctx); err != nil {
+			s.log.Error("Failed to start scheduler after a config change", zap.Error(err))
+			s.scheduler = nil
+			return
+		}
+		s.log.Info("Scheduler started with the changed schedules", zap.Int("schedules", len(c.Schedules)))
+	} else if prev != nil {
+		s.log.Info("Scheduler stopped - no schedules configured")
+	}
+}
+
+// wireScheduler connects the scheduler to the services it uses, in Setup and when a
+// config change creates it
+func (s *Bot) wireScheduler(sc *scheduler.Scheduler) {
+	sc.SetStatusTracker(s.status.Feature("scheduler"))
+	sc.SetPresence(s.slack.Presence())
+	if s.leader != nil {
+		sc.SetLeader(s.leader)
+	}
+}
+
+// reconcileAIChat starts aichat when personas are added and stops it when they're all
+// removed. Otherwise the running aichat takes the changed personas in place, since
+// restarting it would lose the personas users were assigned.
+func (s *Bot) reconcileAIChat(ctx context.Context, c aichat.Config) {
+	switch {
+	case s.aichat != nil && len(c.Personas) > 0:
+		s.aichat.SetPersonas(c)
+	case s.aichat != nil:
+		s.stopAIChat(ctx)
+		s.log.Info("AI Chat stopped - no personas configured")
+	case len(c.Personas) > 0 && s.ai == nil:
+		s.log.Warn("AI Chat personas configured without an LLM, restart once one is configured")
+	case len(c.Personas) > 0:
+		s.aichat = s.newAIChat(c)
+		s.wireAIChat(s.aichat)
+		s.http.RegisterInteractionProcessor(s.aichat)
+		if err := s.aichat.Start(ctx); err != nil {
+			s.log.Error("Failed to start aichat after a config change", zap.Error(err))
+			s.http.UnregisterInteractionProcessor(s.aichat)
+			s.aichat = nil
+			return
+		}
+		s.http.RegisterEventProcessor(s.aichat)
+		s.startPersonaStore(ctx)
+		s.log.Info("AI Chat started", zap.Strings("personas", s.aichat.PersonaNames()))
+	}
+}
+
+// wireAIChat connects aichat to the services it uses and to the features that answer
+// for it, in Setup and when a config change creates it
+func (s *Bot) wireAIChat(a *aichat.AIChat) {
+	a.SetStatusTracker(s.status.Feature(a.ProcessorType()))
+	a.SetPresence(s.slack.Presence())
+	a.SetSilencer(s.incident)
+	if s.claims != nil {
+		a.SetClaimer(s.claims)
+	}
+	if s.outbox != nil {
+		a.SetOutbox(s.outbox)
+	}
+	if s.handoff != nil {
+		a.SetHandoffs(s.handoff)
+	}
+	if replies := s.replyLog(); replies != nil {
+		a.SetReplyLog(replies)
+	}
+	if s.explainer != nil {
+		a.SetTracer(s.explainer)
+	}
+	if s.facts != nil {
+		s.facts.SetQuietUnknown(true)
+		a.AddAnswerer(s.facts)
+	}
+	if s.lastSeen != nil {
+		a.AddAnswerer(s.lastSeen)
+	}
+	if s.karma != nil {
+		a.AddAnswerer(s.karma)
+	}
+	if s.api != nil {
+		s.api.SetPurger(a)
+	}
+	if s.retention != nil {
+		s.retention.Register("aichat", a)
+	}
+}
+
+// startPersonaStore starts the persona store for an aichat started after Setup, applying
+// the personas created from Slack over the configured ones
+func (s *Bot) startPersonaStore(ctx context.Context) {
+	personasConfig := s.configManager.GetPersonasConfig()
+	if !personasConfig.Enabled {
+		return
+	}
+	st := personastore.New(s.logger.Named("personas"), personasConfig, s.slack.For("personas"), s.aichat)
+	st.SetAI(s.ai.For("personas"))
+	st.SetStatusTracker(s.status.Feature(st.ProcessorType()))
+	s.aichat.AddAnswerer(st)
+	if err := st.Start(ctx); err != nil {
+		s.log.Error("Failed to start persona store after a config change", zap.Error(err))
+		return
+	}
+	s.personaStore = st
+	s.http.RegisterEventProcessor(st)
+	s.http.RegisterCommandProcessor(st)
+}
+
+// stopAIChat stops aichat and its persona store and unhooks them from the features that
+// used them
+func (s *Bot) stopAIChat(ctx context.Context) {
+	if s.personaStore != nil {
+		s.http.ReplaceEventProcessor(s.personaStore, nil)
+		s.http.UnregisterCommandProcessor(s.personaStore.Subcommand())
+		s.stopService(ctx, "persona store", s.personaStore.Stop)
+		s.personaStore = nil
+	}
+	s.http.ReplaceEventProcessor(s.aichat, nil)
+	s.http.UnregisterInteractionProcessor(s.aichat)
+	if s.facts != nil {
+		s.facts.SetQuietUnknown(false)
+	}
+	if s.api != nil {
+		s.api.SetPurger(nil)
+	}
+	if s.retention != nil {
+		s.retention.Unregister("aichat")
+	}
+	s.stopService(ctx, "aichat", s.aichat.Stop)
+	s.aichat = nil
+}
+
+// eventProcessor is a feature the HTTP server passes events to
+type eventProcessor interface {
+	PushEvent(event.Event)
+	ProcessorType() string
+}
+
+// heldEvents stands in for an event processor while it restarts, keeping the events that
+// arrive until its replacement takes them
+type heldEvents struct {
+	processorType string
+	mu            sync.Mutex
+	events        []event.Event
+	dropped       int
+}
+
+func (h *heldEvents) ProcessorType() string {
+	return h.processorType
+}
+
+func (h *heldEvents) PushEvent(e event.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.events) >= maxHeldEvents {
+		h.dropped++
+		return
+	}
+	h.events = append(h.events, e)
+}
+
+// holdEvents swaps a running processor for a stand-in that holds its events, so it can
+// be stopped without missing any
+func (s *Bot) holdEvents(prev eventProcessor) *heldEvents {
+	held := &heldEvents{processorType: prev.ProcessorType()}
+	s.http.ReplaceEventProcessor(prev, held)
+	return held
+}
+
+// releaseEvents swaps the stand-in for the replacement and passes it the held events,
+// or drops them when the feature was turned off
+func (s *Bot) releaseEvents(held *heldEvents, processor eventProcessor) {
+	if processor == nil {
+		s.http.ReplaceEventProcessor(held, nil)
+		return
+	}
+	s.http.ReplaceEventProcessor(held, processor)
+	// Nothing pushes to the stand-in once it's replaced
+	for _, e := range held.events {
+		processor.PushEvent(e)
+	}
+	if held.dropped > 0 {
+		s.log.Warn("Dropped events that arrived while a feature restarted",
+			zap.String("type", held.processorType),
+			zap.Int("count", held.dropped))
+	}
+}
+
+// stopService stops a service a config change turned off or replaced. It outlives the
+// run context so state is saved even if shutdown begins meanwhile.
+func (s *Bot) stopService(ctx context.Context, name string, stop func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+	defer cancel()
+	if err := stop(ctx); err != nil {
+		s.log.Error("Failed to stop service after a config change", zap.String("service", name), zap.Error(err))
+	}
+}
+
+// replyLog opens the reply log when the first feature that replies is wired, so a
+// retried event isn't answered twice across restarts
+func (s *Bot) replyLog() *replylog.Log {
+	if s.replies != nil {
+		return s.replies
+	}
+	replies, err := replylog.Open(s.configManager.GetConfig().DataDir)
+	if err != nil {
+		// Continue without it, at the risk of duplicate replies after a restart
+		s.log.Error("Failed to open reply log", zap.Error(err))
+		return nil
+	}
+	s.replies = replies
+	return replies
+}
+
+// runningAIChat returns the aichat a config change may have started or stopped
+func (s *Bot) runningAIChat() *aichat.AIChat {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	return s.aichat
+}
+
+// runningScheduler returns the scheduler a config change may have started or stopped
+func (s *Bot) runningScheduler() *scheduler.Scheduler {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	return s.scheduler
+}
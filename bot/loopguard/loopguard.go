@@ -0,0 +1,256 @@
+// Package loopguard protects event processors from message loops with other bots and
+// integrations that the BotID self-check cannot catch
+package loopguard
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+)
+
+type slackService interface {
+	Client() *slack.Client
+	BotUserID() string
+}
+
+type FileConfig struct {
+	Enabled      *bool          `json:"enabled" yaml:"enabled"`
+	BurstLimit   *int           `json:"burst_limit" yaml:"burst_limit"`
+	BurstWindow  *time.Duration `json:"burst_window" yaml:"burst_window"`
+	RepeatLimit  *int           `json:"repeat_limit" yaml:"repeat_limit"`
+	RepeatWindow *time.Duration `json:"repeat_window" yaml:"repeat_window"`
+	Cooldown     *time.Duration `json:"cooldown" yaml:"cooldown"`
+	AlertChannel *string        `json:"alert_channel" yaml:"alert_channel"`
+	DenyApps     []string       `json:"deny_apps" yaml:"deny_apps"`
+	DenyBots     []string       `json:"deny_bots" yaml:"deny_bots"`
+	DenyUsers    []string       `json:"deny_users" yaml:"deny_users"`
+}
+
+type Config struct {
+	Enabled      bool
+	BurstLimit   int           // Messages per bot within BurstWindow before tripping
+	BurstWindow  time.Duration // Window for per-bot burst detection
+	RepeatLimit  int           // Identical messages per bot and channel within RepeatWindow before tripping
+	RepeatWindow time.Duration // Window for identical-message loop detection
+	Cooldown     time.Duration // How long a tripped bot is ignored
+	AlertChannel string        // Channel notified when a loop is detected
+	DenyApps     []string      // Slack app IDs whose messages are always ignored
+	DenyBots     []string      // Slack bot IDs whose messages are always ignored
+	DenyUsers    []string      // Slack user IDs whose messages are always ignored
+}
+
+type Guard struct {
+	log       *zap.Logger
+	config    Config
+	slack     slackService
+	authors   map[string][]time.Time // author key -> recent message times
+	repeats   map[string][]time.Time // author, channel and text -> recent message times
+	cooldowns map[string]time.Time   // author key -> cooldown expiry
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Guard {
+	return &Guard{
+		log:       log,
+		config:    c,
+		slack:     s,
+		authors:   make(map[string][]time.Time),
+		repeats:   make(map[string][]time.Time),
+		cooldowns: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// message is the subset of a message event the guard inspects
+type message struct {
+	User    string
+	BotID   string
+	AppID   string
+	Channel string
+	Text    string
+	SubType string
+	// Counted is false for events that don't represent a new message, such as edits and
+	// the app_mention duplicate of a message event
+	Counted bool
+}
+
+// fromBot reports whether a bot or app sent the message, the only authors that can loop
+func (m message) fromBot() bool {
+	return m.BotID != "" || m.AppID != "" || m.SubType == "bot_message"
+}
+
+// author returns a stable key identifying who sent the message
+func (m message) author() string {
+	switch {
+	case m.AppID != "":
+		return "app:" + m.AppID
+	case m.BotID != "":
+		return "bot:" + m.BotID
+	default:
+		return "user:" + m.User
+	}
+}
+
+func messageFromEvent(e event.Event) (message, bool) {
+	m := message{User: e.User, BotID: e.BotID, Channel: e.Channel, Text: e.Text, SubType: e.SubType}
+	switch ev := e.Data().(type) {
+	case *slackevents.MessageEvent:
+		switch e.SubType {
+		case "", "bot_message", "thread_broadcast", "me_message", "file_share":
+			m.Counted = true
+		}
+		if ev.Message != nil && ev.Message.BotProfile != nil {
+			m.AppID = ev.Message.BotProfile.AppID
+		}
+		return m, true
+	case *slackevents.AppMentionEvent:
+		return m, true
+	}
+	return message{}, false
+}
+
+// AllowEvent reports whether an event should be passed on to event processors
+func (g *Guard) AllowEvent(e event.Event) bool {
+	m, ok := messageFromEvent(e)
+	if !ok {
+		return true
+	}
+	// Our own messages are already filtered by each processor
+	if m.User != "" && m.User == g.slack.BotUserID() {
+		return true
+	}
+	if g.isDenied(m) {
+		g.log.Debug("Ignoring message from denylisted author",
+			zap.String("author", m.author()),
+			zap.String("channel", m.Channel),
+		)
+		return false
+	}
+
+	reason, allowed := g.record(m)
+	if reason != "" {
+		g.log.Warn("Message loop detected, cooling down",
+			zap.String("author", m.author()),
+			zap.String("channel", m.Channel),
+			zap.String("reason", reason),
+			zap.Duration("cooldown", g.config.Cooldown),
+		)
+		go g.alert(m, reason)
+	}
+	return allowed
+}
+
+func (g *Guard) isDenied(m message) bool {
+	return (m.AppID != "" && slices.Contains(g.config.DenyApps, m.AppID)) ||
+		(m.BotID != "" && slices.Contains(g.config.DenyBots, m.BotID)) ||
+		(m.User != "" && slices.Contains(g.config.DenyUsers, m.User))
+}
+
+// record tracks a bot's message and returns a non-empty reason when it trips the breaker,
+// along with whether the message may be processed. People aren't counted, so a channel
+// all answering "+1" or the same chat trigger isn't mistaken for a loop.
+func (g *Guard) record(m message) (string, bool) {
+	if !m.fromBot() {
+		return "", true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	author := m.author()
+
+	for key, until := range g.cooldowns {
+		if !now.Before(until) {
+			delete(g.cooldowns, key)
+		}
+	}
+	if _, ok := g.cooldowns[author]; ok {
+		return "", false
+	}
+	if !m.Counted {
+		return "", true
+	}
+
+	if g.config.BurstLimit > 0 {
+		times := appendWithin(g.authors[author], now, g.config.BurstWindow)
+		g.authors[author] = times
+		if len(times) > g.config.BurstLimit {
+			delete(g.authors, author)
+			g.cooldowns[author] = now.Add(g.config.Cooldown)
+			return fmt.Sprintf("%d messages within %s", len(times), g.config.BurstWindow), false
+		}
+	}
+
+	if g.config.RepeatLimit > 0 {
+		text := strings.ToLower(strings.Join(strings.Fields(m.Text), " "))
+		if text != "" {
+			key := author + "\x00" + m.Channel + "\x00" + text
+			times := appendWithin(g.repeats[key], now, g.config.RepeatWindow)
+			g.repeats[key] = times
+			if len(times) > g.config.RepeatLimit {
+				delete(g.repeats, key)
+				g.cooldowns[author] = now.Add(g.config.Cooldown)
+				return fmt.Sprintf("identical message repeated %d times within %s", len(times), g.config.RepeatWindow), false
+			}
+		}
+	}
+
+	g.prune(now)
+	return "", true
+}
+
+// prune drops tracking entries that have aged out of every window
+func (g *Guard) prune(now time.Time) {
+	for key, times := range g.authors {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) > g.config.BurstWindow {
+			delete(g.authors, key)
+		}
+	}
+	for key, times := range g.repeats {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) > g.config.RepeatWindow {
+			delete(g.repeats, key)
+		}
+	}
+}
+
+// appendWithin appends now and drops times older than the window
+func appendWithin(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	kept := times[:0]
+	for _, t := range times {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	return append(kept, now)
+}
+
+// alert notifies the ops channel that the breaker tripped
+func (g *Guard) alert(m message, reason string) {
+	if g.config.AlertChannel == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	text := fmt.Sprintf(":rotating_light: Possible bot loop in <#%s> from `%s` (%s). Ignoring it for %s.",
+		m.Channel, m.author(), reason, g.config.Cooldown)
+	_, _, err := g.slack.Client().PostMessageContext(ctx, g.config.AlertChannel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		g.log.Error("Failed to send loop alert",
+			zap.String("channel", g.config.AlertChannel),
+			zap.Error(err),
+		)
+	}
+}
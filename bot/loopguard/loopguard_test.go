@@ -0,0 +1,145 @@
+package loopguard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func newTestGuard(c Config) (*Guard, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := New(zap.NewNop(), c, &slacktest.Slack{})
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func messageEvent(ev *slackevents.MessageEvent) event.Event {
+	return event.Callback(ev)
+}
+
+func TestGuard_Denylist(t *testing.T) {
+	g, _ := newTestGuard(Config{DenyApps: []string{"A1"}, DenyBots: []string{"B1"}, DenyUsers: []string{"U1"}})
+
+	tests := []struct {
+		name string
+		ev   *slackevents.MessageEvent
+		want bool
+	}{
+		{"denied user", &slackevents.MessageEvent{User: "U1", Channel: "C1", Text: "hi"}, false},
+		{"denied bot", &slackevents.MessageEvent{BotID: "B1", Channel: "C1", Text: "hi"}, false},
+		{"denied app", &slackevents.MessageEvent{BotID: "B2", Channel: "C1", Text: "hi",
+			Message: &slack.Msg{BotProfile: &slack.BotProfile{AppID: "A1"}}}, false},
+		{"allowed user", &slackevents.MessageEvent{User: "U2", Channel: "C1", Text: "hi"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.AllowEvent(messageEvent(tt.ev)); got != tt.want {
+				t.Errorf("AllowEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuard_BurstTripsCooldown(t *testing.T) {
+	g, now := newTestGuard(Config{BurstLimit: 3, BurstWindow: 10 * time.Second, Cooldown: time.Minute})
+
+	for i := range 3 {
+		ev := &slackevents.MessageEvent{BotID: "B9", Channel: "C1", Text: "msg " + string(rune('a'+i))}
+		if !g.AllowEvent(messageEvent(ev)) {
+			t.Fatalf("message %d should be allowed below the burst limit", i)
+		}
+	}
+	if g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B9", Channel: "C1", Text: "msg d"})) {
+		t.Fatal("expected burst to trip the breaker")
+	}
+
+	// Other authors are unaffected
+	if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{User: "U2", Channel: "C1", Text: "hello"})) {
+		t.Error("expected other authors to be allowed during an author cooldown")
+	}
+
+	*now = now.Add(30 * time.Second)
+	if g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B9", Channel: "C1", Text: "msg e"})) {
+		t.Error("expected author to stay in cooldown")
+	}
+
+	*now = now.Add(time.Minute)
+	if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B9", Channel: "C1", Text: "msg f"})) {
+		t.Error("expected author to be allowed after cooldown")
+	}
+}
+
+func TestGuard_BurstWindowSlides(t *testing.T) {
+	g, now := newTestGuard(Config{BurstLimit: 2, BurstWindow: 10 * time.Second, Cooldown: time.Minute})
+
+	for i := range 5 {
+		ev := &slackevents.MessageEvent{BotID: "B1", Channel: "C1", Text: "msg " + string(rune('a'+i))}
+		if !g.AllowEvent(messageEvent(ev)) {
+			t.Fatalf("message %d spaced outside the window should be allowed", i)
+		}
+		*now = now.Add(6 * time.Second)
+	}
+}
+
+func TestGuard_IdenticalMessageLoopCoolsBot(t *testing.T) {
+	g, _ := newTestGuard(Config{RepeatLimit: 2, RepeatWindow: time.Minute, Cooldown: time.Minute})
+
+	for i := range 2 {
+		ev := &slackevents.MessageEvent{BotID: "B1", Channel: "C1", Text: "Ping!"}
+		if !g.AllowEvent(messageEvent(ev)) {
+			t.Fatalf("message %d should be allowed below the repeat limit", i)
+		}
+	}
+	if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B2", Channel: "C1", Text: "ping!"})) {
+		t.Fatal("expected repeats to be counted per bot")
+	}
+	if g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B1", Channel: "C1", Text: "  ping!  "})) {
+		t.Fatal("expected identical messages to trip the breaker")
+	}
+	if g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B1", Channel: "C2", Text: "something else"})) {
+		t.Error("expected the bot to be in cooldown")
+	}
+	if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{User: "U1", Channel: "C1", Text: "ping!"})) {
+		t.Error("expected people in the channel to be unaffected")
+	}
+}
+
+func TestGuard_IgnoresPeople(t *testing.T) {
+	g, _ := newTestGuard(Config{BurstLimit: 2, BurstWindow: time.Minute, RepeatLimit: 2, RepeatWindow: time.Minute, Cooldown: time.Minute})
+
+	for _, user := range []string{"U1", "U2", "U3", "U4", "U5", "U1", "U1"} {
+		if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{User: user, Channel: "C1", Text: "lol"})) {
+			t.Fatalf("expected %s saying lol to be allowed", user)
+		}
+	}
+	if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B1", Channel: "C1", Text: "lol"})) {
+		t.Error("expected a bot's first message to be allowed after people repeat it")
+	}
+}
+
+func TestGuard_IgnoresOwnMessagesAndDuplicates(t *testing.T) {
+	g, _ := newTestGuard(Config{BurstLimit: 1, BurstWindow: time.Minute, Cooldown: time.Minute})
+
+	for range 3 {
+		if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{User: "UBOT", Channel: "C1", Text: "hi"})) {
+			t.Fatal("expected the bot's own messages to pass through")
+		}
+	}
+
+	// A mention produces both a message and an app_mention event; only one should count
+	if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B1", Channel: "C1", Text: "<@UBOT> hi"})) {
+		t.Fatal("expected first message to be allowed")
+	}
+	mention := event.Callback(&slackevents.AppMentionEvent{BotID: "B1", Channel: "C1", Text: "<@UBOT> hi"})
+	if !g.AllowEvent(mention) {
+		t.Error("expected the app_mention duplicate not to count toward the burst")
+	}
+	if !g.AllowEvent(messageEvent(&slackevents.MessageEvent{BotID: "B1", SubType: "message_changed", Channel: "C1"})) {
+		t.Error("expected edits not to count toward the burst")
+	}
+}
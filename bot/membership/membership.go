@@ -0,0 +1,346 @@
+// Package membership watches members joining and leaving configured channels. It can
+// post notices, keep a roster file in sync, and remove members of invite-only channels
+// who aren't on the allowlist.
+package membership
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+)
+
+const rosterFile = "roster.json"
+
+type slackService interface {
+	Client() *slack.Client
+	BotUserID() string
+}
+
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
+// ChannelConfig is how one channel is watched
+type ChannelConfig struct {
+	NotifyChannel string   `json:"notify_channel" yaml:"notify_channel"` // Where joins and leaves are posted, empty posts nothing
+	Roster        bool     `json:"roster" yaml:"roster"`                 // Keep the channel's members in the roster file
+	InviteOnly    bool     `json:"invite_only" yaml:"invite_only"`       // Remove members who join without being on Allowlist
+	Allowlist     []string `json:"allowlist" yaml:"allowlist"`           // User IDs allowed to join an invite-only channel
+}
+
+type FileConfig struct {
+	Channels map[string]ChannelConfig `json:"channels" yaml:"channels"`
+	Persona  persona.Config           `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	DataDir  string
+	Channels map[string]ChannelConfig // Channel ID -> how it's watched
+	Persona  persona.Config           // Name and icon notices are posted with
+}
+
+// Watcher handles member_joined_channel and member_left_channel events
+type Watcher struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	path        string
+	rosters     map[string][]string // channel ID -> sorted member IDs
+	mu          sync.Mutex
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+	presence    presence
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Watcher {
+	w := &Watcher{
+		log:      log,
+		config:   c,
+		slack:    s,
+		path:     filepath.Join(c.DataDir, rosterFile),
+		rosters:  make(map[string][]string),
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+	w.load()
+	return w
+}
+
+// SetStatusTracker sets where the watcher reports its activity
+func (w *Watcher) SetStatusTracker(t *status.Tracker) {
+	w.status = t
+}
+
+// SetPresence skips notices to channels the bot was removed from
+func (w *Watcher) SetPresence(p presence) {
+	w.presence = p
+}
+
+// ProcessorType returns a description of the processor type
+func (w *Watcher) ProcessorType() string {
+	return "membership"
+}
+
+// Start syncs rosters with the current channel members and then handles events
+func (w *Watcher) Start(ctx context.Context) error {
+	w.isConnected.Store(true)
+	go func() {
+		w.syncRosters(ctx)
+		w.handleEvents(ctx)
+	}()
+	w.log.Debug("Membership watcher started.", zap.Int("channels", len(w.config.Channels)))
+	return nil
+}
+
+func (w *Watcher) Stop(ctx context.Context) error {
+	if !w.isConnected.Load() {
+		return nil
+	}
+	close(w.stopCh)
+	w.isConnected.Store(false)
+	return nil
+}
+
+// PushEvent adds an event to be processed by the watcher
+func (w *Watcher) PushEvent(e event.Event) {
+	if !w.isConnected.Load() {
+		return
+	}
+
+	select {
+	case w.eventsCh <- e:
+	default:
+		w.log.Warn("Membership events channel full, dropping event.")
+		w.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (w *Watcher) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-w.eventsCh:
+			w.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (w *Watcher) processEvent(ctx context.Context, e event.Event) {
+	c, ok := w.config.Channels[e.Channel]
+	if !ok {
+		return
+	}
+	switch ev := e.Data().(type) {
+	case *slackevents.MemberJoinedChannelEvent:
+		w.status.Event()
+		w.handleJoined(ctx, c, ev)
+	case *slackevents.MemberLeftChannelEvent:
+		w.status.Event()
+		w.handleLeft(ctx, c, ev)
+	}
+}
+
+func (w *Watcher) handleJoined(ctx context.Context, c ChannelConfig, ev *slackevents.MemberJoinedChannelEvent) {
+	w.log.Debug("Member joined watched channel",
+		zap.String("user", ev.User),
+		zap.String("channel", ev.Channel),
+	)
+
+	if c.InviteOnly && !w.allowed(c, ev.User) {
+		kind := conversation.KindFromEventType(ev.ChannelType, ev.Channel)
+		if !kind.CanKick() {
+			w.log.Warn("Members can't be removed from this kind of conversation, skipping the allowlist",
+				zap.String("channel", ev.Channel),
+				zap.String("kind", string(kind)),
+			)
+		} else if err := conversation.Kick(ctx, w.slack.Client(), kind, ev.Channel, ev.User); err != nil {
+			w.status.Error(err)
+			w.log.Error("Failed to remove user missing from the allowlist",
+				zap.String("channel", ev.Channel),
+				zap.String("user", ev.User),
+				zap.Error(err),
+			)
+		} else {
+			w.log.Info("Removed user missing from the allowlist",
+				zap.String("channel", ev.Channel),
+				zap.String("user", ev.User),
+			)
+			w.notify(ctx, c, fmt.Sprintf("🚫 <@%s> isn't on the allowlist for <#%s> and was removed", ev.User, ev.Channel))
+			return
+		}
+	}
+
+	if c.Roster {
+		w.updateRoster(ev.Channel, ev.User, true)
+	}
+	w.notify(ctx, c, fmt.Sprintf("➡️ <@%s> joined <#%s>", ev.User, ev.Channel))
+}
+
+func (w *Watcher) handleLeft(ctx context.Context, c ChannelConfig, ev *slackevents.MemberLeftChannelEvent) {
+	w.log.Debug("Member left watched channel",
+		zap.String("user", ev.User),
+		zap.String("channel", ev.Channel),
+	)
+
+	if c.Roster {
+		w.updateRoster(ev.Channel, ev.User, false)
+	}
+	// Members removed for the allowlist were announced when they joined
+	if c.InviteOnly && !w.allowed(c, ev.User) {
+		return
+	}
+	w.notify(ctx, c, fmt.Sprintf("⬅️ <@%s> left <#%s>", ev.User, ev.Channel))
+}
+
+// allowed reports whether a user may stay in an invite-only channel. The bot is always allowed.
+func (w *Watcher) allowed(c ChannelConfig, userID string) bool {
+	return userID == w.slack.BotUserID() || slices.Contains(c.Allowlist, userID)
+}
+
+func (w *Watcher) notify(ctx context.Context, c ChannelConfig, text string) {
+	if c.NotifyChannel == "" {
+		return
+	}
+	if w.presence != nil && !w.presence.Present(ctx, c.NotifyChannel) {
+		w.log.Debug("Bot was removed from the notify channel, skipping notice", zap.String("channel", c.NotifyChannel))
+		return
+	}
+	_, _, err := w.slack.Client().PostMessageContext(ctx, c.NotifyChannel,
+		slack.MsgOptionText(text, false),
+		w.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		w.status.Error(err)
+		w.log.Error("Failed to post membership notice",
+			zap.String("channel", c.NotifyChannel),
+			zap.Error(err),
+		)
+		return
+	}
+	w.status.Posted()
+}
+
+// syncRosters replaces each roster with the channel's current members. Channels that
+// can't be listed keep the roster from disk.
+func (w *Watcher) syncRosters(ctx context.Context) {
+	changed := false
+	for channelID, c := range w.config.Channels {
+		if !c.Roster {
+			continue
+		}
+		members, err := w.listMembers(ctx, channelID)
+		if err != nil {
+			w.status.Error(err)
+			w.log.Error("Failed to sync channel roster",
+				zap.String("channel", channelID),
+				zap.Error(err),
+			)
+			continue
+		}
+		slices.Sort(members)
+		w.mu.Lock()
+		w.rosters[channelID] = members
+		w.mu.Unlock()
+		changed = true
+	}
+	if changed {
+		w.mu.Lock()
+		w.save()
+		w.mu.Unlock()
+	}
+}
+
+func (w *Watcher) listMembers(ctx context.Context, channelID string) ([]string, error) {
+	var members []string
+	params := &slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: 200}
+	for {
+		page, cursor, err := w.slack.Client().GetUsersInConversationContext(ctx, params)
+		if err != nil {
+			return nil, conversation.Error("conversations.members", conversation.KindFromID(channelID), err)
+		}
+		members = append(members, page...)
+		if cursor == "" {
+			return members, nil
+		}
+		params.Cursor = cursor
+	}
+}
+
+// updateRoster adds or removes a member and saves the roster if it changed
+func (w *Watcher) updateRoster(channelID, userID string, joined bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	members := w.rosters[channelID]
+	i, found := slices.BinarySearch(members, userID)
+	switch {
+	case joined && !found:
+		w.rosters[channelID] = slices.Insert(members, i, userID)
+	case !joined && found:
+		w.rosters[channelID] = slices.Delete(members, i, i+1)
+	default:
+		return
+	}
+	w.save()
+}
+
+// members returns a copy of a channel's roster
+func (w *Watcher) members(channelID string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.rosters[channelID])
+}
+
+func (w *Watcher) load() {
+	data, err := os.ReadFile(w.path)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case err != nil:
+		w.log.Error("Failed to read channel rosters", zap.Error(err), zap.String("path", w.path))
+		return
+	}
+	if err := json.Unmarshal(data, &w.rosters); err != nil {
+		w.log.Error("Failed to unmarshal channel rosters", zap.Error(err), zap.String("path", w.path))
+	}
+	for channelID := range w.rosters {
+		slices.Sort(w.rosters[channelID])
+	}
+}
+
+// save writes the rosters; callers must hold mu
+func (w *Watcher) save() {
+	data, err := json.MarshalIndent(w.rosters, "", "  ")
+	if err != nil {
+		w.log.Error("Failed to marshal channel rosters", zap.Error(err))
+		return
+	}
+	tempFile := w.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		w.log.Error("Failed to save channel rosters", zap.Error(&errs.StorageError{Op: "write", Path: tempFile, Err: err}))
+		return
+	}
+	if err := os.Rename(tempFile, w.path); err != nil {
+		w.log.Error("Failed to save channel rosters", zap.Error(&errs.StorageError{Op: "rename", Path: w.path, Err: err}))
+	}
+}
@@ -0,0 +1,128 @@
+package membership
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	posts  []string
+	kicked []string
+}
+
+func newTestWatcher(t *testing.T, dir string, channels map[string]ChannelConfig) (*Watcher, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		switch r.URL.Path {
+		case "/conversations.members":
+			if r.FormValue("cursor") == "" {
+				_, _ = w.Write([]byte(`{"ok": true, "members": ["U2", "UBOT"], "response_metadata": {"next_cursor": "next"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok": true, "members": ["U1"]}`))
+		case "/conversations.kick":
+			rec.kicked = append(rec.kicked, r.FormValue("channel")+"/"+r.FormValue("user"))
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		default:
+			rec.posts = append(rec.posts, r.FormValue("channel")+": "+r.FormValue("text"))
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.0"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	s := slacktest.New(srv.URL)
+	return New(zaptest.NewLogger(t), Config{DataDir: dir, Channels: channels}, s), rec
+}
+
+func joined(channel, user string) event.Event {
+	return event.Callback(&slackevents.MemberJoinedChannelEvent{Channel: channel, User: user, ChannelType: "C"})
+}
+
+func left(channel, user string) event.Event {
+	return event.Callback(&slackevents.MemberLeftChannelEvent{Channel: channel, User: user, ChannelType: "C"})
+}
+
+func TestWatcher_Roster(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	w, rec := newTestWatcher(t, dir, map[string]ChannelConfig{
+		"C1": {NotifyChannel: "CNOTIFY", Roster: true},
+	})
+
+	w.syncRosters(ctx)
+	if got := w.members("C1"); !slices.Equal(got, []string{"U1", "U2", "UBOT"}) {
+		t.Fatalf("Synced roster = %v, want every page of members", got)
+	}
+
+	w.processEvent(ctx, joined("C1", "U3"))
+	w.processEvent(ctx, left("C1", "U1"))
+	w.processEvent(ctx, joined("C9", "U4")) // not watched
+
+	want := []string{"U2", "U3", "UBOT"}
+	if got := w.members("C1"); !slices.Equal(got, want) {
+		t.Errorf("Roster = %v, want %v", got, want)
+	}
+	if len(rec.posts) != 2 || !strings.Contains(rec.posts[0], "<@U3> joined") || !strings.Contains(rec.posts[1], "<@U1> left") {
+		t.Errorf("Unexpected notices %v", rec.posts)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, rosterFile))
+	if err != nil {
+		t.Fatalf("Roster file not written: %v", err)
+	}
+	var saved map[string][]string
+	if err := json.Unmarshal(data, &saved); err != nil || !slices.Equal(saved["C1"], want) {
+		t.Errorf("Saved roster = %v (%v), want %v", saved["C1"], err, want)
+	}
+
+	// The roster is restored from disk
+	restored, _ := newTestWatcher(t, dir, w.config.Channels)
+	if got := restored.members("C1"); !slices.Equal(got, want) {
+		t.Errorf("Restored roster = %v, want %v", got, want)
+	}
+}
+
+func TestWatcher_InviteOnly(t *testing.T) {
+	ctx := context.Background()
+	w, rec := newTestWatcher(t, t.TempDir(), map[string]ChannelConfig{
+		"C1": {NotifyChannel: "CNOTIFY", InviteOnly: true, Allowlist: []string{"U1"}},
+	})
+
+	w.processEvent(ctx, joined("C1", "U1"))
+	w.processEvent(ctx, joined("C1", "UBOT"))
+	w.processEvent(ctx, joined("C1", "U2"))
+	w.processEvent(ctx, left("C1", "U2"))
+
+	if !slices.Equal(rec.kicked, []string{"C1/U2"}) {
+		t.Errorf("Kicked %v, want only the user missing from the allowlist", rec.kicked)
+	}
+	if len(rec.posts) != 3 || !strings.Contains(rec.posts[2], "<@U2> isn't on the allowlist") {
+		t.Errorf("Expected two joins and a removal notice without a leave notice, got %v", rec.posts)
+	}
+
+	// Members can't be removed from group DMs, so the allowlist isn't enforced there
+	w.config.Channels["G1"] = ChannelConfig{InviteOnly: true}
+	w.processEvent(ctx, event.Callback(&slackevents.MemberJoinedChannelEvent{Channel: "G1", User: "U3", ChannelType: "mpim"}))
+	if len(rec.kicked) != 1 {
+		t.Errorf("Group DM members should not be kicked, got %v", rec.kicked)
+	}
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"slackbot.arpa/bot/errs"
+)
+
+// readMessage returns the message text given with --message, or read from --message-file,
+// where - reads stdin
+func readMessage(stdin io.Reader, message, path string) (string, error) {
+	switch {
+	case message != "" && path != "":
+		return "", errors.New("set either --message or --message-file, not both")
+	case path == "-":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("read stdin: %w", err)
+		}
+		return string(data), nil
+	case path != "":
+		data, err := os.ReadFile(path) // #nosec G304 -- the path is the operator's own input
+		if err != nil {
+			return "", &errs.StorageError{Op: "read", Path: path, Err: err}
+		}
+		return string(data), nil
+	}
+	return message, nil
+}
+
+// parseVars parses key=value template variables. Slice flags split values on commas, so
+// a piece without = is rejoined to the value before it.
+func parseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	var last string
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			if last == "" {
+				return nil, fmt.Errorf("variable %q must be key=value", pair)
+			}
+			vars[last] += "," + pair
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("variable %q must be key=value", pair)
+		}
+		vars[key] = value
+		last = key
+	}
+	return vars, nil
+}
+
+// renderMessage fills a message's Go template with variables, e.g. {{.version}}. A
+// variable the template uses but wasn't given is an error rather than an empty string.
+func renderMessage(text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse message template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("render message template: %w", err)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
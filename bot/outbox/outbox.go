@@ -0,0 +1,329 @@
+// Package outbox holds replies from opted-in features for a send delay, so an operator
+// can cancel one before it's posted. While a reply is held the message it answers is
+// marked with a reaction, and reacting to that message with the cancel reaction drops
+// the reply. Held replies can also be listed and cancelled from the CLI. Paced features'
+// replies also wait about as long as they'd take to type, and are dropped when the
+// conversation moves on first.
+package outbox
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/emoji"
+)
+
+const (
+	DefaultCancelReaction  = "x"
+	DefaultPendingReaction = "hourglass_flowing_sand"
+)
+
+// Features whose replies can be held
+var Features = []string{"chat", "aichat"}
+
+type slackService interface {
+	Client() *slack.Client
+	BotUserID() string
+}
+
+type FileConfig struct {
+	// Features maps a feature to how long its replies are held, e.g. aichat: 10s.
+	// Features that aren't listed reply right away.
+	Features map[string]time.Duration `json:"features" yaml:"features"`
+	// Channels limits holding to these channel IDs, empty holds replies everywhere
+	Channels []string `json:"channels" yaml:"channels"`
+	// Operators are the user IDs whose cancel reaction drops a reply, empty lets anyone
+	Operators      []string `json:"operators" yaml:"operators"`
+	CancelReaction *string  `json:"cancel_reaction" yaml:"cancel_reaction"`
+	// PendingReaction marks the message being replied to while the reply is held, an
+	// empty string leaves it unmarked
+	PendingReaction *string `json:"pending_reaction" yaml:"pending_reaction"`
+	// Pacing delays replies as if they were being typed
+	Pacing PacingFileConfig `json:"pacing" yaml:"pacing"`
+}
+
+type Config struct {
+	Delays          map[string]time.Duration // Feature -> send delay
+	Channels        []string
+	Operators       []string
+	CancelReaction  string
+	PendingReaction string
+	Pacing          PacingConfig
+}
+
+// Held is a reply waiting out its send delay
+type Held struct {
+	ID        string    `json:"id"`
+	Feature   string    `json:"feature"`
+	Channel   string    `json:"channel"`
+	TriggerTS string    `json:"trigger_ts"` // Message being replied to
+	Preview   string    `json:"preview"`
+	SendAt    time.Time `json:"send_at"`
+}
+
+type held struct {
+	Held
+	timer  *time.Timer
+	marked bool // Held for a send delay, so the message is marked
+	paced  bool // Waiting as if typed, so a newer message drops it
+}
+
+// Outbox holds replies for their feature's send delay
+type Outbox struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	mu          sync.Mutex
+	held        map[string]*held
+	lastID      int
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Outbox {
+	return &Outbox{
+		log:      log,
+		config:   c,
+		slack:    s,
+		held:     make(map[string]*held),
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, 100),
+	}
+}
+
+// SetStatusTracker sets where the outbox reports its activity
+func (o *Outbox) SetStatusTracker(t *status.Tracker) {
+	o.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (o *Outbox) ProcessorType() string {
+	return "outbox"
+}
+
+func (o *Outbox) Start(ctx context.Context) error {
+	o.isConnected.Store(true)
+	go o.handleEvents(ctx)
+	o.log.Debug("Outbox started.", zap.Any("delays", o.config.Delays))
+	return nil
+}
+
+// Stop drops the replies still held
+func (o *Outbox) Stop(ctx context.Context) error {
+	if !o.isConnected.Load() {
+		return nil
+	}
+	close(o.stopCh)
+	o.isConnected.Store(false)
+
+	o.mu.Lock()
+	var dropped []*held
+	for id, h := range o.held {
+		if h.timer.Stop() {
+			dropped = append(dropped, h)
+		}
+		delete(o.held, id)
+	}
+	o.mu.Unlock()
+	for _, h := range dropped {
+		o.unmark(ctx, h)
+	}
+	if len(dropped) > 0 {
+		o.log.Info("Dropped held replies on shutdown", zap.Int("count", len(dropped)))
+	}
+	return nil
+}
+
+// Send posts a feature's reply with send, after the feature's send delay and pacing when
+// it has them. triggerTS is the message being replied to, where the pending mark and
+// cancel reactions go, and preview is the reply's text, which paces it.
+func (o *Outbox) Send(ctx context.Context, feature, channelID, triggerTS, preview string, send func(ctx context.Context)) {
+	hold := o.config.Delays[feature]
+	if len(o.config.Channels) > 0 && !slices.Contains(o.config.Channels, channelID) {
+		hold = 0
+	}
+	pace := o.config.Pacing.delay(feature, preview)
+	delay := hold + pace
+	if delay <= 0 || !o.isConnected.Load() {
+		send(ctx)
+		return
+	}
+
+	o.mu.Lock()
+	o.lastID++
+	h := &held{Held: Held{
+		ID:        strconv.Itoa(o.lastID),
+		Feature:   feature,
+		Channel:   channelID,
+		TriggerTS: triggerTS,
+		Preview:   preview,
+		SendAt:    time.Now().Add(delay),
+	}, marked: hold > 0, paced: pace > 0}
+	o.mu.Unlock()
+
+	// Mark first so a short delay can't unmark before the mark is added
+	if h.marked {
+		o.mark(ctx, h.Held)
+	}
+	o.mu.Lock()
+	h.timer = time.AfterFunc(delay, func() { o.release(ctx, h.ID, send) })
+	o.held[h.ID] = h
+	o.mu.Unlock()
+	o.log.Debug("Holding reply",
+		zap.String("id", h.ID),
+		zap.String("feature", feature),
+		zap.String("channel", channelID),
+		zap.Duration("delay", hold),
+		zap.Duration("pacing", pace))
+}
+
+// release sends a held reply once its delay is up, unless it was cancelled
+func (o *Outbox) release(ctx context.Context, id string, send func(ctx context.Context)) {
+	o.mu.Lock()
+	h, ok := o.held[id]
+	delete(o.held, id)
+	o.mu.Unlock()
+	if !ok || ctx.Err() != nil {
+		return
+	}
+	o.unmark(ctx, h)
+	send(ctx)
+}
+
+// Pending lists the held replies, soonest first
+func (o *Outbox) Pending() []Held {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending := make([]Held, 0, len(o.held))
+	for _, h := range o.held {
+		pending = append(pending, h.Held)
+	}
+	slices.SortFunc(pending, func(a, b Held) int { return a.SendAt.Compare(b.SendAt) })
+	return pending
+}
+
+// Cancel drops a held reply. It reports false when no reply with the ID is still held.
+func (o *Outbox) Cancel(ctx context.Context, id, by string) (Held, bool) {
+	o.mu.Lock()
+	h, ok := o.held[id]
+	if ok {
+		delete(o.held, id)
+		// A timer that already fired is sending the reply
+		ok = h.timer.Stop()
+	}
+	o.mu.Unlock()
+	if !ok {
+		return Held{}, false
+	}
+	o.unmark(ctx, h)
+	o.log.Info("Cancelled held reply",
+		zap.String("id", id),
+		zap.String("feature", h.Feature),
+		zap.String("channel", h.Channel),
+		zap.String("by", by))
+	return h.Held, true
+}
+
+// PushEvent adds an event to be processed by the outbox
+func (o *Outbox) PushEvent(e event.Event) {
+	if !o.isConnected.Load() {
+		return
+	}
+
+	select {
+	case o.eventsCh <- e:
+	default:
+		o.log.Warn("Outbox events channel full, dropping event.")
+		o.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+func (o *Outbox) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-o.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-o.eventsCh:
+			o.processEvent(ctx, e)
+		}
+	}
+}
+
+// processEvent cancels the replies to a message when an operator reacts to it with the
+// cancel reaction, and paced replies when a newer message is posted
+func (o *Outbox) processEvent(ctx context.Context, e event.Event) {
+	if _, ok := e.Data().(*slackevents.MessageEvent); ok {
+		o.conversationMoved(ctx, e)
+		return
+	}
+	ev, ok := e.Data().(*slackevents.ReactionAddedEvent)
+	if !ok || emoji.Base(ev.Reaction) != emoji.Name(o.config.CancelReaction) {
+		return
+	}
+	if e.User == "" || e.User == o.slack.BotUserID() {
+		return
+	}
+	if len(o.config.Operators) > 0 && !slices.Contains(o.config.Operators, e.User) {
+		o.log.Debug("Ignoring cancel reaction from a user who isn't an operator", zap.String("user", e.User))
+		return
+	}
+
+	o.mu.Lock()
+	var ids []string
+	for id, h := range o.held {
+		if h.Channel == e.Channel && h.TriggerTS == e.TS {
+			ids = append(ids, id)
+		}
+	}
+	o.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := o.Cancel(ctx, id, e.User); ok {
+			o.status.Event()
+		}
+	}
+}
+
+// mark reacts to the message being replied to while its reply is held
+func (o *Outbox) mark(ctx context.Context, h Held) {
+	if o.config.PendingReaction == "" || h.TriggerTS == "" {
+		return
+	}
+	err := o.slack.Client().AddReactionContext(ctx, emoji.Name(o.config.PendingReaction), slack.NewRefToMessage(h.Channel, h.TriggerTS))
+	if err != nil {
+		o.log.Warn("Failed to mark held reply", zap.String("id", h.ID), zap.Error(errs.NewSlackAPIError("reactions.add", err)))
+	}
+}
+
+// unmark removes the pending mark once a reply is sent or cancelled. Another held reply
+// to the same message keeps it.
+func (o *Outbox) unmark(ctx context.Context, h *held) {
+	if o.config.PendingReaction == "" || h.TriggerTS == "" || !h.marked {
+		return
+	}
+	o.mu.Lock()
+	for _, other := range o.held {
+		if other.marked && other.Channel == h.Channel && other.TriggerTS == h.TriggerTS {
+			o.mu.Unlock()
+			return
+		}
+	}
+	o.mu.Unlock()
+	err := o.slack.Client().RemoveReactionContext(ctx, emoji.Name(o.config.PendingReaction), slack.NewRefToMessage(h.Channel, h.TriggerTS))
+	if err != nil {
+		o.log.Warn("Failed to unmark held reply", zap.String("id", h.ID), zap.Error(errs.NewSlackAPIError("reactions.remove", err)))
+	}
+}
@@ -0,0 +1,200 @@
+package outbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func newTestOutbox(t *testing.T, c Config) (*Outbox, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		calls = append(calls, strings.TrimPrefix(r.URL.Path, "/")+" "+r.FormValue("name"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	if c.CancelReaction == "" {
+		c.CancelReaction = DefaultCancelReaction
+	}
+	o := New(zap.NewNop(), c, slacktest.New(server.URL))
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = o.Stop(context.Background()) })
+	return o, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(calls)
+	}
+}
+
+func TestOutbox_Send(t *testing.T) {
+	o, calls := newTestOutbox(t, Config{
+		Delays:          map[string]time.Duration{"aichat": 20 * time.Millisecond},
+		Channels:        []string{"C1"},
+		PendingReaction: DefaultPendingReaction,
+	})
+
+	sent := make(chan string, 3)
+	o.Send(context.Background(), "chat", "C1", "1.1", "hi", func(context.Context) { sent <- "chat" })
+	o.Send(context.Background(), "aichat", "C2", "1.2", "hi", func(context.Context) { sent <- "other channel" })
+	if len(sent) != 2 || len(o.Pending()) != 0 {
+		t.Fatalf("sent %d replies right away, want features without a delay and other channels unheld", len(sent))
+	}
+	<-sent
+	<-sent
+
+	o.Send(context.Background(), "aichat", "C1", "1.3", "held", func(context.Context) { sent <- "held" })
+	if pending := o.Pending(); len(pending) != 1 || pending[0].Preview != "held" {
+		t.Fatalf("Pending() = %+v, want the held reply", pending)
+	}
+	select {
+	case got := <-sent:
+		if got != "held" {
+			t.Errorf("sent %q, want the held reply", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("held reply wasn't sent after its delay")
+	}
+	want := []string{"reactions.add " + DefaultPendingReaction, "reactions.remove " + DefaultPendingReaction}
+	if got := calls(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestOutbox_CancelReaction(t *testing.T) {
+	o, _ := newTestOutbox(t, Config{
+		Delays:    map[string]time.Duration{"chat": time.Hour},
+		Operators: []string{"UOP"},
+	})
+
+	sent := false
+	o.Send(context.Background(), "chat", "C1", "1.1", "hi", func(context.Context) { sent = true })
+	o.Send(context.Background(), "chat", "C1", "2.2", "other", func(context.Context) { sent = true })
+
+	react := func(user, reaction string) event.Event {
+		return event.Callback(&slackevents.ReactionAddedEvent{
+			User:     user,
+			Reaction: reaction,
+			Item:     slackevents.Item{Channel: "C1", Timestamp: "1.1"},
+		})
+	}
+	o.processEvent(context.Background(), react("U1", "x"))
+	o.processEvent(context.Background(), react("UOP", "thumbsup"))
+	if len(o.Pending()) != 2 {
+		t.Fatalf("Pending() = %+v, want reactions from others or other emoji ignored", o.Pending())
+	}
+
+	o.processEvent(context.Background(), react("UOP", "x"))
+	if pending := o.Pending(); len(pending) != 1 || pending[0].TriggerTS != "2.2" {
+		t.Fatalf("Pending() = %+v, want only the reply to the reacted message cancelled", pending)
+	}
+
+	id := o.Pending()[0].ID
+	if _, ok := o.Cancel(context.Background(), id, "cli"); !ok {
+		t.Errorf("Cancel(%s) = false, want the held reply cancelled", id)
+	}
+	if _, ok := o.Cancel(context.Background(), id, "cli"); ok {
+		t.Errorf("Cancel(%s) twice = true, want false", id)
+	}
+	if sent {
+		t.Error("a cancelled reply was sent")
+	}
+}
+
+func TestPacingConfig_Delay(t *testing.T) {
+	c := PacingConfig{Features: []string{"aichat"}, Base: time.Second, PerChar: 10 * time.Millisecond, Max: 3 * time.Second}
+	if got := c.delay("chat", "hello"); got != 0 {
+		t.Errorf("delay() for an unpaced feature = %v, want 0", got)
+	}
+	if got := c.delay("aichat", "héllo"); got != time.Second+50*time.Millisecond {
+		t.Errorf("delay() = %v, want the base plus 5 characters", got)
+	}
+	if got := c.delay("aichat", strings.Repeat("a", 1000)); got != 3*time.Second {
+		t.Errorf("delay() for a long reply = %v, want the cap", got)
+	}
+
+	c.Jitter = time.Second
+	if got := c.delay("aichat", ""); got < time.Second || got > 2*time.Second {
+		t.Errorf("delay() with jitter = %v, want between the base and base plus jitter", got)
+	}
+}
+
+func TestOutbox_Pacing(t *testing.T) {
+	o, calls := newTestOutbox(t, Config{
+		Pacing:          PacingConfig{Features: []string{"aichat"}, Base: time.Hour, CancelOnNewer: true},
+		PendingReaction: DefaultPendingReaction,
+	})
+
+	sent := false
+	o.Send(context.Background(), "aichat", "C1", "100.000001", "hi", func(context.Context) { sent = true })
+	o.Send(context.Background(), "aichat", "C2", "100.000001", "hi", func(context.Context) { sent = true })
+	if len(o.Pending()) != 2 {
+		t.Fatalf("Pending() = %+v, want both paced replies waiting", o.Pending())
+	}
+
+	message := func(user, botID, subType, ts string) event.Event {
+		return event.New(slackevents.EventsAPIEvent{
+			Type: slackevents.CallbackEvent,
+			InnerEvent: slackevents.EventsAPIInnerEvent{Type: "message", Data: &slackevents.MessageEvent{
+				User: user, BotID: botID, SubType: subType, Channel: "C1", TimeStamp: ts,
+			}},
+		})
+	}
+	o.processEvent(context.Background(), message("U1", "", "", "100.000001"))
+	o.processEvent(context.Background(), message("UBOT", "B1", "", "101.0"))
+	o.processEvent(context.Background(), message("U1", "", "message_changed", "101.0"))
+	if len(o.Pending()) != 2 {
+		t.Fatalf("Pending() = %+v, want the trigger itself, the bot's posts and edits ignored", o.Pending())
+	}
+
+	o.processEvent(context.Background(), message("U1", "", "", "101.0"))
+	if pending := o.Pending(); len(pending) != 1 || pending[0].Channel != "C2" {
+		t.Fatalf("Pending() = %+v, want the reply in the channel that moved on dropped", pending)
+	}
+	if sent {
+		t.Error("a dropped paced reply was sent")
+	}
+	if got := calls(); len(got) != 0 {
+		t.Errorf("calls = %v, want paced replies left unmarked", got)
+	}
+}
+
+// BenchmarkOutbox_Send measures the outbox on the reply path for a feature it passes
+// straight through, which is most replies
+func BenchmarkOutbox_Send(b *testing.B) {
+	o := New(zap.NewNop(), Config{
+		Delays: map[string]time.Duration{"aichat": time.Minute},
+		Pacing: PacingConfig{Features: []string{"aichat"}, Base: time.Second},
+	}, &slacktest.Slack{})
+	if err := o.Start(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = o.Stop(context.Background()) }()
+	sent := 0
+	send := func(context.Context) { sent++ }
+
+	b.ReportAllocs()
+	for b.Loop() {
+		o.Send(context.Background(), "chat", "C1", "1.1", "hello there", send)
+	}
+	if sent == 0 {
+		b.Fatal("the reply was never sent")
+	}
+}
@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/tools/random"
+)
+
+const (
+	DefaultPacingBase    = time.Second
+	DefaultPacingPerChar = 20 * time.Millisecond
+	DefaultPacingJitter  = 2 * time.Second
+	DefaultPacingMax     = 8 * time.Second
+)
+
+type PacingFileConfig struct {
+	// Features lists the features whose replies wait as if they were being typed
+	Features []string `json:"features" yaml:"features"`
+	// Base is the wait before any typing, defaults to 1s
+	Base *time.Duration `json:"base" yaml:"base"`
+	// PerChar is the typing speed, added for each character of the reply, defaults to 20ms
+	PerChar *time.Duration `json:"per_char" yaml:"per_char"`
+	// Jitter adds up to this much at random, defaults to 2s
+	Jitter *time.Duration `json:"jitter" yaml:"jitter"`
+	// Max caps the wait, defaults to 8s
+	Max *time.Duration `json:"max" yaml:"max"`
+	// CancelOnNewer drops a paced reply when someone posts in the channel before it's sent,
+	// since the conversation moved on, defaults to true
+	CancelOnNewer *bool `json:"cancel_on_newer" yaml:"cancel_on_newer"`
+}
+
+type PacingConfig struct {
+	Features      []string
+	Base          time.Duration
+	PerChar       time.Duration
+	Jitter        time.Duration
+	Max           time.Duration
+	CancelOnNewer bool
+}
+
+// delay is how long a feature's reply waits before it's posted, as if it were typed
+func (c PacingConfig) delay(feature, text string) time.Duration {
+	if !slices.Contains(c.Features, feature) {
+		return 0
+	}
+	d := c.Base + time.Duration(utf8.RuneCountInString(text))*c.PerChar
+	if c.Jitter > 0 {
+		d += time.Duration(random.Float(0, float64(c.Jitter)))
+	}
+	if c.Max > 0 {
+		d = min(d, c.Max)
+	}
+	return d
+}
+
+// conversationMoved drops the paced replies to messages older than a new one in the
+// channel, which would answer a conversation that has moved on
+func (o *Outbox) conversationMoved(ctx context.Context, e event.Event) {
+	if !o.config.Pacing.CancelOnNewer || e.User == "" || e.BotID != "" || e.User == o.slack.BotUserID() {
+		return
+	}
+	// Edits, deletions and joins aren't the conversation moving on
+	if e.SubType != "" && e.SubType != "thread_broadcast" && e.SubType != "file_share" {
+		return
+	}
+	posted, err := strconv.ParseFloat(e.TS, 64)
+	if err != nil {
+		return
+	}
+
+	o.mu.Lock()
+	var ids []string
+	for id, h := range o.held {
+		if !h.paced || h.Channel != e.Channel {
+			continue
+		}
+		if trigger, err := strconv.ParseFloat(h.TriggerTS, 64); err == nil && trigger < posted {
+			ids = append(ids, id)
+		}
+	}
+	o.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := o.Cancel(ctx, id, "newer message"); ok {
+			o.status.Event()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/urfave/cli/v3"
+	"slackbot.arpa/bot/config"
+)
+
+// jsonOutput reports whether command results should be printed as JSON for scripts
+func jsonOutput(cmd *cli.Command) bool {
+	return cmd.Root().String("output") == config.OutputJSON
+}
+
+// writeJSON prints a command result as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// messageWriter is where commands print prompts, progress and notes. With JSON output
+// these go to stderr so stdout only holds the result.
+func messageWriter(cmd *cli.Command) io.Writer {
+	if jsonOutput(cmd) {
+		return cmd.Root().ErrWriter
+	}
+	return cmd.Root().Writer
+}
@@ -0,0 +1,39 @@
+// Package persona sets the display name and icon a feature posts with, so features like
+// obituaries or vibechecks can post as their own character instead of the bot user
+package persona
+
+import (
+	"github.com/slack-go/slack"
+	"slackbot.arpa/tools/emoji"
+)
+
+// Config is a feature's posting identity. Overriding the name or icon needs the
+// chat:write.customize scope.
+type Config struct {
+	Username  string `json:"username" yaml:"username"`     // Display name, e.g. Grim Reaper
+	IconEmoji string `json:"icon_emoji" yaml:"icon_emoji"` // Emoji shown as the avatar, e.g. rip
+	IconURL   string `json:"icon_url" yaml:"icon_url"`     // Image shown as the avatar, used without icon_emoji
+}
+
+// IsZero reports whether no persona is configured
+func (c Config) IsZero() bool {
+	return c.Username == "" && c.IconEmoji == "" && c.IconURL == ""
+}
+
+// MsgOption posts as the persona, or as the bot user when none is configured
+func (c Config) MsgOption() slack.MsgOption {
+	if c.IsZero() {
+		return slack.MsgOptionAsUser(true)
+	}
+	var options []slack.MsgOption
+	if c.Username != "" {
+		options = append(options, slack.MsgOptionUsername(c.Username))
+	}
+	switch {
+	case c.IconEmoji != "":
+		options = append(options, slack.MsgOptionIconEmoji(emoji.Code(c.IconEmoji)))
+	case c.IconURL != "":
+		options = append(options, slack.MsgOptionIconURL(c.IconURL))
+	}
+	return slack.MsgOptionCompose(options...)
+}
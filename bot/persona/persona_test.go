@@ -0,0 +1,33 @@
+package persona
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestConfig_MsgOption(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   map[string]string
+	}{
+		{"bot user", Config{}, map[string]string{"as_user": "true"}},
+		{"name and emoji", Config{Username: "Grim Reaper", IconEmoji: "rip"}, map[string]string{"username": "Grim Reaper", "icon_emoji": ":rip:"}},
+		{"emoji over url", Config{IconEmoji: ":cop:", IconURL: "https://example.com/cop.png"}, map[string]string{"icon_emoji": ":cop:"}},
+		{"url", Config{IconURL: "https://example.com/cop.png"}, map[string]string{"icon_url": "https://example.com/cop.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, values, err := slack.UnsafeApplyMsgOptions("xoxb-test", "C1", "https://slack.com/api/", tt.config.MsgOption())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"as_user", "username", "icon_emoji", "icon_url"} {
+				if got := values.Get(key); got != tt.want[key] {
+					t.Errorf("%s = %q, want %q", key, got, tt.want[key])
+				}
+			}
+		})
+	}
+}
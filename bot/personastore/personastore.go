@@ -0,0 +1,533 @@
+// Package personastore lets admins create, edit and delete aichat personas from Slack,
+// with "/slackbot persona create office_dj <prompt>" or the same words in a mention.
+// Personas are kept in the data dir and applied over the config file's personas of the
+// same name, without a restart. Prompts are checked for length and blocked words, and
+// reviewed by the LLM before they're saved.
+package personastore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/timeformat"
+)
+
+const (
+	stateFile = "personas.json"
+	// DefaultMaxPromptLength is the longest prompt accepted unless configured, in characters
+	DefaultMaxPromptLength = 2000
+	minPromptLength        = 20
+	// reviewTimeout bounds the LLM review of a prompt
+	reviewTimeout = 30 * time.Second
+)
+
+// commandPattern matches "<@U123> persona create office_dj You are..." and the text of the
+// slash command. Prompts can span lines.
+var commandPattern = regexp.MustCompile(`(?is)^\s*(?:<@[A-Z0-9]+>[\s,:]*)?persona\s+(create|edit|delete|show|list)\b\s*(.*?)\s*$`)
+
+// namePattern is what persona names may look like, e.g. office_dj
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+const usage = "Usage: /slackbot persona create <name> <prompt> | edit <name> <prompt> | delete <name> | show <name> | list"
+
+const reviewPrompt = `You review system prompts for personas of a chat bot in a workplace Slack.
+Reply ALLOW if the prompt below is fine to use. Reply BLOCK followed by a short reason if it
+asks the bot to harass, demean or target people, produce sexual or hateful content, reveal
+secrets, or ignore its safety rules. Reply with nothing else.
+
+Prompt:
+%s`
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type aiService interface {
+	LLM() llms.Model
+}
+
+// personas applies runtime personas, e.g. aichat
+type personas interface {
+	SetPersona(name, prompt string)
+	RemovePersona(name string)
+	PersonaNames() []string
+}
+
+type FileConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Admins are user IDs who can manage personas besides workspace admins and owners
+	Admins []string `json:"admins" yaml:"admins"`
+	// MaxPromptLength is the longest prompt accepted, in characters, defaults to 2000
+	MaxPromptLength *int `json:"max_prompt_length" yaml:"max_prompt_length"`
+	// Review has the LLM review each prompt before it's saved, defaults to true
+	Review *bool `json:"review" yaml:"review"`
+	// BlockedWords are rejected anywhere in a prompt
+	BlockedWords []string `json:"blocked_words" yaml:"blocked_words"`
+}
+
+type Config struct {
+	Enabled         bool
+	DataDir         string
+	Admins          []string
+	MaxPromptLength int
+	Review          bool
+	BlockedWords    []string
+}
+
+// Definition is a persona created from Slack
+type Definition struct {
+	Prompt    string    `json:"prompt"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// request is a persona command from a mention or the slash command
+type request struct {
+	action      string
+	name        string
+	prompt      string
+	userID      string
+	channelID   string
+	threadTS    string
+	responseURL string // Set for slash commands
+}
+
+// Store keeps the personas created from Slack and applies them
+type Store struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	ai          aiService
+	personas    personas
+	path        string
+	mu          sync.Mutex
+	defined     map[string]Definition
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	requestsCh  chan request
+	status      *status.Tracker
+}
+
+// New loads the stored personas and applies them
+func New(log *zap.Logger, c Config, s slackService, p personas) *Store {
+	if c.MaxPromptLength <= 0 {
+		c.MaxPromptLength = DefaultMaxPromptLength
+	}
+	st := &Store{
+		log:        log,
+		config:     c,
+		slack:      s,
+		personas:   p,
+		path:       filepath.Join(c.DataDir, stateFile),
+		defined:    make(map[string]Definition),
+		now:        time.Now,
+		stopCh:     make(chan struct{}),
+		eventsCh:   make(chan event.Event, 100),
+		requestsCh: make(chan request, 100),
+	}
+	st.load()
+	for _, name := range slices.Sorted(maps.Keys(st.defined)) {
+		p.SetPersona(name, st.defined[name].Prompt)
+	}
+	return st
+}
+
+// SetAI sets the model prompts are reviewed with; without it prompts aren't reviewed
+func (st *Store) SetAI(a aiService) {
+	st.ai = a
+}
+
+// SetStatusTracker sets where the store reports its activity
+func (st *Store) SetStatusTracker(t *status.Tracker) {
+	st.status = t
+}
+
+// ProcessorType returns a description of the processor type
+func (st *Store) ProcessorType() string {
+	return "personas"
+}
+
+// Subcommand is the slash command word handled by the store
+func (st *Store) Subcommand() string {
+	return "persona"
+}
+
+func (st *Store) Start(ctx context.Context) error {
+	st.isConnected.Store(true)
+	go st.handleEvents(ctx)
+	st.log.Debug("Persona store started.", zap.Int("personas", len(st.Definitions())))
+	return nil
+}
+
+func (st *Store) Stop(ctx context.Context) error {
+	if !st.isConnected.Load() {
+		return nil
+	}
+	close(st.stopCh)
+	st.isConnected.Store(false)
+	return nil
+}
+
+// Answers reports whether the store replies to the mention text
+func (st *Store) Answers(channelID, text string) bool {
+	return commandPattern.MatchString(text)
+}
+
+// Definitions returns the personas created from Slack by name
+func (st *Store) Definitions() map[string]Definition {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return maps.Clone(st.defined)
+}
+
+// PushEvent adds an event to be processed by the store
+func (st *Store) PushEvent(e event.Event) {
+	if !st.isConnected.Load() {
+		return
+	}
+
+	select {
+	case st.eventsCh <- e:
+	default:
+		st.log.Warn("Persona events channel full, dropping event.")
+		st.status.Dropped(e.Channel, status.DropQueueFull)
+	}
+}
+
+// HandleCommand answers list and show right away. Changes are reviewed in the background
+// and the result is sent to the command's response URL.
+func (st *Store) HandleCommand(ctx context.Context, cmd slack.SlashCommand, args []string) string {
+	text := strings.TrimSpace(cmd.Text)
+	m := commandPattern.FindStringSubmatch(text)
+	if m == nil {
+		return usage
+	}
+	r := newRequest(m)
+	r.userID, r.channelID, r.responseURL = cmd.UserID, cmd.ChannelID, cmd.ResponseURL
+	st.status.Event()
+	switch r.action {
+	case "list", "show":
+		return st.describe(r)
+	}
+	if !st.isConnected.Load() {
+		return "Personas can't be changed right now, try again shortly."
+	}
+	select {
+	case st.requestsCh <- r:
+		return fmt.Sprintf("⏳ Working on `%s`…", r.name)
+	default:
+		st.status.Dropped(cmd.ChannelID, status.DropQueueFull)
+		return "Too many persona changes at once, try again shortly."
+	}
+}
+
+func newRequest(m []string) request {
+	r := request{action: strings.ToLower(m[1])}
+	name, prompt := m[2], ""
+	if i := strings.IndexAny(m[2], " \t\n"); i >= 0 {
+		name, prompt = m[2][:i], m[2][i+1:]
+	}
+	r.name, r.prompt = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(prompt)
+	return r
+}
+
+func (st *Store) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-st.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-st.eventsCh:
+			st.processEvent(e.Context(ctx), e)
+		case r := <-st.requestsCh:
+			st.reply(ctx, r, st.change(ctx, r))
+		}
+	}
+}
+
+func (st *Store) processEvent(ctx context.Context, e event.Event) {
+	ev, ok := e.Data().(*slackevents.AppMentionEvent)
+	if !ok || e.FromBot() {
+		return
+	}
+	m := commandPattern.FindStringSubmatch(ev.Text)
+	if m == nil {
+		return
+	}
+	st.status.Event()
+	r := newRequest(m)
+	r.userID, r.channelID, r.threadTS = ev.User, ev.Channel, ev.ThreadTimeStamp
+	switch r.action {
+	case "list", "show":
+		st.reply(ctx, r, st.describe(r))
+	default:
+		st.reply(ctx, r, st.change(ctx, r))
+	}
+}
+
+// describe lists the personas or shows one created from Slack
+func (st *Store) describe(r request) string {
+	defined := st.Definitions()
+	if r.action == "list" {
+		names := st.personas.PersonaNames()
+		if len(names) == 0 {
+			return "There are no personas."
+		}
+		var b strings.Builder
+		b.WriteString("*Personas*\n")
+		for _, name := range names {
+			if d, ok := defined[name]; ok {
+				fmt.Fprintf(&b, "• `%s` (set by <@%s> on %s)\n", name, d.UpdatedBy, timeformat.Slack(d.UpdatedAt, timeformat.Date))
+				continue
+			}
+			fmt.Fprintf(&b, "• `%s` (config file)\n", name)
+		}
+		return b.String()
+	}
+
+	if r.name == "" {
+		return usage
+	}
+	if d, ok := defined[r.name]; ok {
+		return fmt.Sprintf("`%s`, set by <@%s> on %s:\n>>> %s", r.name, d.UpdatedBy, timeformat.Slack(d.UpdatedAt, timeformat.Date), d.Prompt)
+	}
+	if slices.Contains(st.personas.PersonaNames(), r.name) {
+		return fmt.Sprintf("`%s` is set in the config file.", r.name)
+	}
+	return fmt.Sprintf("There's no persona named `%s`.", r.name)
+}
+
+// change creates, edits or deletes a persona for an admin and returns the reply
+func (st *Store) change(ctx context.Context, r request) string {
+	if r.name == "" {
+		return usage
+	}
+	admin, err := st.isAdmin(ctx, r.userID)
+	if err != nil {
+		st.status.Error(err)
+		st.log.Error("Failed to check persona admin", zap.String("user", r.userID), zap.Error(err))
+		return "I couldn't check your permissions, try again shortly."
+	}
+	if !admin {
+		st.log.Warn("Refused persona change from non-admin", zap.String("user", r.userID), zap.String("action", r.action), zap.String("persona", r.name))
+		return "Only workspace admins and persona admins can change personas."
+	}
+
+	exists := slices.Contains(st.personas.PersonaNames(), r.name)
+	switch r.action {
+	case "create", "edit":
+		switch {
+		case r.action == "create" && exists:
+			return fmt.Sprintf("`%s` already exists, use `persona edit %s <prompt>` to change it.", r.name, r.name)
+		case r.action == "edit" && !exists:
+			return fmt.Sprintf("There's no persona named `%s`, use `persona create` to add it.", r.name)
+		}
+		if err := st.validate(ctx, r.name, r.prompt); err != nil {
+			st.log.Info("Rejected persona", zap.String("user", r.userID), zap.String("persona", r.name), zap.Error(err))
+			return fmt.Sprintf("❌ `%s` wasn't saved: %s", r.name, err)
+		}
+		if err := st.set(r.name, r.prompt, r.userID); err != nil {
+			st.status.Error(err)
+			st.log.Error("Failed to save persona", zap.String("persona", r.name), zap.Error(err))
+			return "I couldn't save the persona, try again shortly."
+		}
+		return fmt.Sprintf("✅ `%s` is saved and used from the next reply.", r.name)
+	case "delete":
+		deleted, err := st.delete(r.name, r.userID)
+		if err != nil {
+			st.status.Error(err)
+			st.log.Error("Failed to delete persona", zap.String("persona", r.name), zap.Error(err))
+			return "I couldn't delete the persona, try again shortly."
+		}
+		switch {
+		case !deleted && exists:
+			return fmt.Sprintf("`%s` is set in the config file and can only be removed there.", r.name)
+		case !deleted:
+			return fmt.Sprintf("There's no persona named `%s`.", r.name)
+		case slices.Contains(st.personas.PersonaNames(), r.name):
+			return fmt.Sprintf("🗑️ Deleted `%s`; the config file's prompt is used again.", r.name)
+		}
+		return fmt.Sprintf("🗑️ Deleted `%s`.", r.name)
+	}
+	return usage
+}
+
+// isAdmin reports whether the user is a configured persona admin or a workspace admin or
+// owner
+func (st *Store) isAdmin(ctx context.Context, userID string) (bool, error) {
+	if slices.Contains(st.config.Admins, userID) {
+		return true, nil
+	}
+	u, err := st.slack.Client().GetUserInfoContext(ctx, userID)
+	if err != nil {
+		return false, errs.NewSlackAPIError("users.info", err)
+	}
+	return u.IsAdmin || u.IsOwner || u.IsPrimaryOwner, nil
+}
+
+// validate checks a persona's name and prompt, then has the LLM review the prompt
+func (st *Store) validate(ctx context.Context, name, prompt string) error {
+	if !namePattern.MatchString(name) {
+		return errors.New("names are up to 32 lowercase letters, digits, - and _")
+	}
+	switch n := utf8.RuneCountInString(prompt); {
+	case n < minPromptLength:
+		return fmt.Errorf("the prompt must be at least %d characters", minPromptLength)
+	case n > st.config.MaxPromptLength:
+		return fmt.Errorf("the prompt is %d characters, the limit is %d", n, st.config.MaxPromptLength)
+	}
+	lower := strings.ToLower(prompt)
+	for _, word := range st.config.BlockedWords {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			return fmt.Errorf("the prompt contains the blocked word %q", word)
+		}
+	}
+	if !st.config.Review || st.ai == nil || st.ai.LLM() == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reviewTimeout)
+	defer cancel()
+	verdict, err := llms.GenerateFromSinglePrompt(ctx, st.ai.LLM(), fmt.Sprintf(reviewPrompt, prompt), llms.WithMaxTokens(60))
+	if err != nil {
+		st.status.Error(err)
+		st.log.Error("Failed to review persona prompt", zap.String("persona", name), zap.Error(errs.NewLLMError("review persona", err)))
+		return errors.New("the prompt couldn't be reviewed, try again shortly")
+	}
+	verdict = strings.TrimSpace(verdict)
+	switch {
+	case strings.HasPrefix(strings.ToUpper(verdict), "ALLOW"):
+		return nil
+	case strings.HasPrefix(strings.ToUpper(verdict), "BLOCK"):
+		reason := strings.TrimLeft(verdict[len("BLOCK"):], " :-–—")
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return fmt.Errorf("the review flagged it (%s)", reason)
+	}
+	st.log.Warn("Unexpected persona review verdict", zap.String("persona", name), zap.String("verdict", verdict))
+	return errors.New("the review was inconclusive, try rewording the prompt")
+}
+
+// set saves a persona and applies it
+func (st *Store) set(name, prompt, userID string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := st.now()
+	d, ok := st.defined[name]
+	if !ok {
+		d = Definition{CreatedBy: userID, CreatedAt: now}
+	}
+	d.Prompt, d.UpdatedBy, d.UpdatedAt = prompt, userID, now
+	previous, hadPrevious := st.defined[name]
+	st.defined[name] = d
+	if err := st.save(); err != nil {
+		if hadPrevious {
+			st.defined[name] = previous
+		} else {
+			delete(st.defined, name)
+		}
+		return err
+	}
+	st.personas.SetPersona(name, prompt)
+	st.log.Info("Persona saved", zap.String("persona", name), zap.String("user", userID), zap.Bool("created", !ok))
+	return nil
+}
+
+// delete removes a persona created from Slack, reporting whether there was one
+func (st *Store) delete(name, userID string) (bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	d, ok := st.defined[name]
+	if !ok {
+		return false, nil
+	}
+	delete(st.defined, name)
+	if err := st.save(); err != nil {
+		st.defined[name] = d
+		return false, err
+	}
+	st.personas.RemovePersona(name)
+	st.log.Info("Persona deleted", zap.String("persona", name), zap.String("user", userID))
+	return true, nil
+}
+
+// reply answers the request where it came from, only to the person who sent it
+func (st *Store) reply(ctx context.Context, r request, text string) {
+	var err error
+	if r.responseURL != "" {
+		err = slack.PostWebhookContext(ctx, r.responseURL, &slack.WebhookMessage{Text: text, ResponseType: slack.ResponseTypeEphemeral})
+	} else {
+		_, err = st.slack.Client().PostEphemeralContext(ctx, r.channelID, r.userID,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionTS(r.threadTS),
+		)
+		if err != nil {
+			err = conversation.Error("chat.postEphemeral", conversation.KindFromID(r.channelID), err)
+		}
+	}
+	if err != nil {
+		st.status.PostFailed(r.channelID, err)
+		st.log.Error("Failed to reply to persona command", zap.String("channel", r.channelID), zap.Error(err))
+		return
+	}
+	st.status.Posted()
+}
+
+func (st *Store) load() {
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			st.log.Error("Failed to read personas", zap.Error(err), zap.String("path", st.path))
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &st.defined); err != nil {
+		st.log.Error("Failed to unmarshal personas", zap.Error(err), zap.String("path", st.path))
+		st.defined = make(map[string]Definition)
+	}
+	if st.defined == nil {
+		st.defined = make(map[string]Definition)
+	}
+}
+
+// save writes the personas, with the lock held
+func (st *Store) save() error {
+	data, err := json.Marshal(st.defined)
+	if err != nil {
+		return err
+	}
+	tempFile := st.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return &errs.StorageError{Op: "write", Path: tempFile, Err: err}
+	}
+	if err := os.Rename(tempFile, st.path); err != nil {
+		return &errs.StorageError{Op: "rename", Path: st.path, Err: err}
+	}
+	return nil
+}
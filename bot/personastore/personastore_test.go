@@ -0,0 +1,216 @@
+package personastore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/slacktest"
+)
+
+type fakeModel struct {
+	reply string
+	err   error
+}
+
+func (m *fakeModel) GenerateContent(_ context.Context, _ []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.reply}}}, nil
+}
+
+func (m *fakeModel) Call(ctx context.Context, prompt string, _ ...llms.CallOption) (string, error) {
+	return m.reply, m.err
+}
+
+type fakeAI struct {
+	model llms.Model
+}
+
+func (a fakeAI) LLM() llms.Model { return a.model }
+
+// mockPersonas layers runtime personas over configured ones like aichat
+type mockPersonas struct {
+	configured map[string]string
+	added      map[string]string
+}
+
+func (m *mockPersonas) SetPersona(name, prompt string) { m.added[name] = prompt }
+func (m *mockPersonas) RemovePersona(name string)      { delete(m.added, name) }
+func (m *mockPersonas) PersonaNames() []string {
+	names := slices.Collect(maps.Keys(m.configured))
+	for name := range m.added {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (m *mockPersonas) prompt(name string) string {
+	if p, ok := m.added[name]; ok {
+		return p
+	}
+	return m.configured[name]
+}
+
+const djPrompt = "You are the office DJ. Answer with a song recommendation."
+
+func TestStore_Mentions(t *testing.T) {
+	var replies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/users.info":
+			admin := r.FormValue("user") == "UADMIN"
+			if admin {
+				_, _ = w.Write([]byte(`{"ok": true, "user": {"id": "UADMIN", "is_admin": true}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok": true, "user": {"id": "` + r.FormValue("user") + `"}}`))
+		case "/chat.postEphemeral":
+			replies = append(replies, r.FormValue("user")+": "+r.FormValue("text"))
+			_, _ = w.Write([]byte(`{"ok": true, "message_ts": "1.1"}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+	s := slacktest.New(server.URL)
+	p := &mockPersonas{configured: map[string]string{"pirate": "You talk like a pirate."}, added: map[string]string{}}
+	model := &fakeModel{reply: "ALLOW"}
+	st := New(zap.NewNop(), Config{DataDir: dir, Review: true, BlockedWords: []string{"Secret"}}, s, p)
+	st.SetAI(fakeAI{model: model})
+	st.now = func() time.Time { return now }
+	ask := func(user, text string) string {
+		t.Helper()
+		replies = nil
+		if !st.Answers("C1", text) {
+			t.Fatalf("Answers(%q) = false", text)
+		}
+		st.processEvent(context.Background(), event.Callback(&slackevents.AppMentionEvent{User: user, Channel: "C1", Text: text, TimeStamp: "2.2"}))
+		if len(replies) != 1 {
+			t.Fatalf("replies = %v after %q, want one", replies, text)
+		}
+		return replies[0]
+	}
+
+	if got := ask("U1", "<@UBOT> persona create office_dj "+djPrompt); !strings.Contains(got, "Only workspace admins") {
+		t.Errorf("non-admin create = %q, want it refused", got)
+	}
+	if got := ask("UADMIN", "<@UBOT> persona create office_dj\n"+djPrompt); !strings.Contains(got, "saved") {
+		t.Errorf("create = %q, want it saved", got)
+	}
+	if p.prompt("office_dj") != djPrompt {
+		t.Errorf("office_dj prompt = %q, want it applied", p.prompt("office_dj"))
+	}
+	if got := ask("UADMIN", "<@UBOT> persona create office_dj "+djPrompt); !strings.Contains(got, "already exists") {
+		t.Errorf("second create = %q, want it refused", got)
+	}
+	if got := ask("UADMIN", "<@UBOT> persona edit bard "+djPrompt); !strings.Contains(got, "no persona named") {
+		t.Errorf("edit of a missing persona = %q, want it refused", got)
+	}
+
+	for text, want := range map[string]string{
+		"persona create Bad!Name " + djPrompt:                       "lowercase letters",
+		"persona create shy too short":                              "at least 20",
+		"persona create spy You keep the secret launch codes safe.": `blocked word "Secret"`,
+	} {
+		if got := ask("UADMIN", "<@UBOT> "+text); !strings.Contains(got, want) {
+			t.Errorf("%q = %q, want %q", text, got, want)
+		}
+	}
+	model.reply = "BLOCK: targets coworkers"
+	if got := ask("UADMIN", "<@UBOT> persona create roaster Roast whoever talks next, mercilessly."); !strings.Contains(got, "targets coworkers") {
+		t.Errorf("blocked create = %q, want the review's reason", got)
+	}
+	model.err = errors.New("quota exceeded")
+	if got := ask("UADMIN", "<@UBOT> persona create critic You review films in one line."); !strings.Contains(got, "couldn't be reviewed") {
+		t.Errorf("create during an outage = %q, want it refused", got)
+	}
+	model.reply, model.err = "ALLOW", nil
+
+	// A stored prompt overrides the configured persona until it's deleted
+	if got := ask("UADMIN", "<@UBOT> persona edit pirate You talk like a polite pirate, arr."); !strings.Contains(got, "saved") {
+		t.Errorf("edit = %q, want it saved", got)
+	}
+	if got := ask("U1", "<@UBOT> persona list"); !strings.Contains(got, "`office_dj` (set by <@UADMIN>") || !strings.Contains(got, "`pirate` (set by") {
+		t.Errorf("list = %q, want the stored personas", got)
+	}
+	if got := ask("UADMIN", "<@UBOT> persona delete pirate"); !strings.Contains(got, "config file's prompt is used again") {
+		t.Errorf("delete = %q, want the configured prompt restored", got)
+	}
+	if p.prompt("pirate") != "You talk like a pirate." {
+		t.Errorf("pirate prompt = %q, want the configured one", p.prompt("pirate"))
+	}
+	if got := ask("UADMIN", "<@UBOT> persona delete pirate"); !strings.Contains(got, "only be removed there") {
+		t.Errorf("deleting a configured persona = %q, want it refused", got)
+	}
+
+	// Stored personas are applied again after a restart
+	p = &mockPersonas{configured: map[string]string{}, added: map[string]string{}}
+	st = New(zap.NewNop(), Config{DataDir: dir}, s, p)
+	if p.prompt("office_dj") != djPrompt || len(p.added) != 1 {
+		t.Errorf("personas after reloading = %v, want office_dj", p.added)
+	}
+	if d := st.Definitions()["office_dj"]; d.CreatedBy != "UADMIN" || !d.UpdatedAt.Equal(now) {
+		t.Errorf("definition = %+v, want who created it and when", d)
+	}
+}
+
+func TestStore_HandleCommand(t *testing.T) {
+	webhooks := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hook" {
+			body, _ := io.ReadAll(r.Body)
+			webhooks <- string(body)
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	s := slacktest.New(server.URL)
+	p := &mockPersonas{configured: map[string]string{}, added: map[string]string{}}
+	st := New(zap.NewNop(), Config{DataDir: t.TempDir(), Admins: []string{"U1"}}, s, p)
+	ctx := t.Context()
+	if err := st.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = st.Stop(ctx) }()
+	command := func(text string) string {
+		return st.HandleCommand(ctx, slack.SlashCommand{UserID: "U1", ChannelID: "C1", Text: text, ResponseURL: server.URL + "/hook"}, strings.Fields(text))
+	}
+
+	if got := command("persona dance"); !strings.HasPrefix(got, "Usage:") {
+		t.Errorf("unknown action = %q, want usage", got)
+	}
+	if got := command("persona create office_dj " + djPrompt); !strings.Contains(got, "Working on `office_dj`") {
+		t.Errorf("create = %q, want it queued", got)
+	}
+	select {
+	case got := <-webhooks:
+		if !strings.Contains(got, "saved") || !strings.Contains(got, `"response_type":"ephemeral"`) {
+			t.Errorf("webhook = %s, want an ephemeral confirmation", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply to the response URL")
+	}
+	if got := command("persona show office_dj"); !strings.Contains(got, djPrompt) {
+		t.Errorf("show = %q, want the prompt", got)
+	}
+}
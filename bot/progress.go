@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// confirm asks a y/N question, treating anything but "y" or "yes" (including EOF) as no
+func confirm(r io.Reader, w io.Writer, question string) bool {
+	_, _ = fmt.Fprintf(w, "%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// progressReporter prints periodic status lines with counts and an ETA for long-running
+// commands, and collects failures for a summary at the end
+type progressReporter struct {
+	w        io.Writer
+	verb     string // e.g. "Deleted"
+	total    int
+	interval time.Duration
+	now      func() time.Time
+
+	start      time.Time
+	lastReport time.Time
+	done       int
+	failures   map[string][]string // reason -> item IDs
+}
+
+func newProgressReporter(w io.Writer, verb string, total int) *progressReporter {
+	p := &progressReporter{
+		w:        w,
+		verb:     verb,
+		total:    total,
+		interval: 5 * time.Second,
+		now:      time.Now,
+		failures: make(map[string][]string),
+	}
+	p.start = p.now()
+	p.lastReport = p.start
+	return p
+}
+
+// Success records a completed item
+func (p *progressReporter) Success() {
+	p.done++
+	p.maybeReport()
+}
+
+// Failure records a failed item under a short reason used to group the summary
+func (p *progressReporter) Failure(id, reason string) {
+	p.failures[reason] = append(p.failures[reason], id)
+	p.maybeReport()
+}
+
+func (p *progressReporter) failed() int {
+	var n int
+	for _, ids := range p.failures {
+		n += len(ids)
+	}
+	return n
+}
+
+func (p *progressReporter) maybeReport() {
+	now := p.now()
+	processed := p.done + p.failed()
+	if now.Sub(p.lastReport) < p.interval && processed != p.total {
+		return
+	}
+	p.lastReport = now
+
+	line := fmt.Sprintf("%s %d/%d (%d failed)", p.verb, p.done, p.total, p.failed())
+	if remaining := p.total - processed; remaining > 0 && processed > 0 {
+		perItem := now.Sub(p.start) / time.Duration(processed)
+		line += fmt.Sprintf(", ETA %s", (perItem * time.Duration(remaining)).Round(time.Second))
+	}
+	_, _ = fmt.Fprintln(p.w, line)
+}
+
+// Summary prints the totals and failures grouped by reason
+func (p *progressReporter) Summary() {
+	elapsed := p.now().Sub(p.start).Round(time.Second)
+	_, _ = fmt.Fprintf(p.w, "%s %d of %d in %s\n", p.verb, p.done, p.total, elapsed)
+	if len(p.failures) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(p.w, "%d failed:\n", p.failed())
+	for _, reason := range slices.Sorted(maps.Keys(p.failures)) {
+		ids := p.failures[reason]
+		_, _ = fmt.Fprintf(p.w, "  %s (%d): %s\n", reason, len(ids), strings.Join(ids, ", "))
+	}
+}
@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"slackbot.arpa/bot/config"
+	"slackbot.arpa/bot/control"
+)
+
+// runRemote sends the command line to the running bot and prints what the command printed
+func (s *Bot) runRemote(ctx context.Context, cmd *cli.Command) error {
+	return s.runRemoteArgs(ctx, cmd, cmd.Root().Args().Slice())
+}
+
+// runRemoteArgs runs a command in the running bot with args rewritten by the caller, e.g.
+// with a local file's content in place of its path
+func (s *Bot) runRemoteArgs(ctx context.Context, cmd *cli.Command, args []string) error {
+	root := cmd.Root()
+	resp, err := s.remote.Run(ctx, control.Request{
+		Args:   args,
+		Output: root.String("output"),
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = io.WriteString(root.Writer, resp.Stdout)
+	_, _ = io.WriteString(root.ErrWriter, resp.Stderr)
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// runCommand runs a command for the control socket with the bot's services. There's no
+// terminal, so confirmation prompts are declined and need --yes.
+func (s *Bot) runCommand(ctx context.Context, req control.Request, stdout, stderr io.Writer) error {
+	root := &cli.Command{
+		Name:      "slackbot",
+		Reader:    strings.NewReader(""),
+		Writer:    stdout,
+		ErrWriter: stderr,
+		Flags:     []cli.Flag{config.OutputFlag()},
+		Commands:  Commands(s),
+		// Return usage errors to the caller rather than exiting the bot
+		ExitErrHandler: func(context.Context, *cli.Command, error) {},
+	}
+	output := req.Output
+	if output == "" {
+		output = config.OutputText
+	}
+	args := append([]string{root.Name, "--output", output}, req.Args...)
+	return root.Run(ctx, args)
+}
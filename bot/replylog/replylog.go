@@ -0,0 +1,112 @@
+// Package replylog records which Slack events the bot has replied to and with which
+// message. Features check it before replying, so an event Slack retries after a crash or
+// a slow acknowledgement isn't answered twice, even across restarts.
+package replylog
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+	"slackbot.arpa/bot/errs"
+)
+
+const (
+	dbFile = "replies.db"
+
+	// Retention is how long replies are remembered. Slack stops retrying an event within
+	// the hour, so a day leaves room for restarts that take a while.
+	Retention = 24 * time.Hour
+	// pruneInterval is how often expired replies are removed while recording new ones
+	pruneInterval = time.Hour
+)
+
+// Log is the record of replied events, keyed by feature and Slack event ID
+type Log struct {
+	db   *sql.DB
+	path string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// Open opens the reply log in the data directory, removing replies past retention
+func Open(dataDir string) (*Log, error) {
+	path := filepath.Join(dataDir, dbFile)
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, &errs.StorageError{Op: "open", Path: path, Err: err}
+	}
+	if _, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS replies (
+		feature TEXT NOT NULL,
+		event_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL,
+		ts TEXT NOT NULL,
+		replied_at INTEGER NOT NULL,
+		PRIMARY KEY (feature, event_id)
+	)`); err != nil {
+		_ = db.Close()
+		return nil, &errs.StorageError{Op: "migrate schema", Path: path, Err: err}
+	}
+	l := &Log{db: db, path: path, now: time.Now}
+	if err := l.prune(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close closes the database connection
+func (l *Log) Close() error {
+	return l.db.Close()
+}
+
+// Replied reports whether a feature already replied to an event, and the timestamp of
+// the reply when it was recorded with one
+func (l *Log) Replied(feature, eventID string) (string, bool, error) {
+	var ts string
+	err := l.db.QueryRow(`SELECT ts FROM replies WHERE feature = ? AND event_id = ? AND replied_at > ?`,
+		feature, eventID, l.now().Add(-Retention).UnixMilli()).Scan(&ts)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", false, nil
+	case err != nil:
+		return "", false, &errs.StorageError{Op: "read", Path: l.path, Err: err}
+	}
+	return ts, true, nil
+}
+
+// Record remembers that a feature replied to an event with the message at ts
+func (l *Log) Record(feature, eventID, channelID, ts string) error {
+	_, err := l.db.Exec(`
+	INSERT INTO replies (feature, event_id, channel_id, ts, replied_at) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(feature, event_id) DO UPDATE SET channel_id = excluded.channel_id, ts = excluded.ts, replied_at = excluded.replied_at`,
+		feature, eventID, channelID, ts, l.now().UnixMilli())
+	if err != nil {
+		return &errs.StorageError{Op: "write", Path: l.path, Err: err}
+	}
+
+	l.mu.Lock()
+	due := l.now().Sub(l.lastPrune) >= pruneInterval
+	l.mu.Unlock()
+	if due {
+		return l.prune()
+	}
+	return nil
+}
+
+// prune removes replies past retention
+func (l *Log) prune() error {
+	now := l.now()
+	if _, err := l.db.Exec(`DELETE FROM replies WHERE replied_at <= ?`, now.Add(-Retention).UnixMilli()); err != nil {
+		return &errs.StorageError{Op: "prune", Path: l.path, Err: err}
+	}
+	l.mu.Lock()
+	l.lastPrune = now
+	l.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,56 @@
+package replylog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLog_Replied(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	if _, ok, err := l.Replied("aichat", "Ev1"); err != nil || ok {
+		t.Fatalf("Replied() = %v, %v, want no reply yet", ok, err)
+	}
+	if err := l.Record("aichat", "Ev1", "C1", "1.1"); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if ts, ok, err := l.Replied("aichat", "Ev1"); err != nil || !ok || ts != "1.1" {
+		t.Errorf("Replied() = %q, %v, %v, want the reply's timestamp", ts, ok, err)
+	}
+	if _, ok, _ := l.Replied("chat", "Ev1"); ok {
+		t.Error("Replied() for another feature = true, want features tracked separately")
+	}
+
+	// Replies survive a restart
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	l, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	l.now = func() time.Time { return now }
+	if _, ok, _ := l.Replied("aichat", "Ev1"); !ok {
+		t.Error("Replied() after reopening = false, want the reply remembered")
+	}
+
+	// And are forgotten after retention
+	now = now.Add(Retention + time.Minute)
+	if _, ok, _ := l.Replied("aichat", "Ev1"); ok {
+		t.Error("Replied() after retention = true, want the reply forgotten")
+	}
+	if err := l.Record("aichat", "Ev2", "C1", "2.2"); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	var count int
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM replies`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("replies = %d, %v, want the expired reply pruned", count, err)
+	}
+}
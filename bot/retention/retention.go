@@ -0,0 +1,306 @@
+// Package retention expires persisted user data on a schedule. Each kind of data belongs
+// to a namespace, e.g. aichat's stored conversations to context and the moderation and
+// feedback logs to audit, and is kept for its namespace's period unless its feature
+// overrides it. Stores that can soft-delete hide expired records first and remove them a
+// grace period later, so lengthening a period in time brings them back.
+package retention
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	DefaultInterval = 24 * time.Hour
+	DefaultGrace    = 7 * 24 * time.Hour
+)
+
+// Stores maps each feature with persisted user data to its namespace
+var Stores = map[string]string{
+	"aichat":   "context",
+	"feedback": "audit",
+	"filescan": "audit",
+}
+
+// Namespaces returns the namespaces of the stores, sorted
+func Namespaces() []string {
+	namespaces := slices.Sorted(maps.Values(Stores))
+	return slices.Compact(namespaces)
+}
+
+// Period is how long records are kept: a duration like 720h, a number of days like 30d,
+// or forever
+type Period time.Duration
+
+// Forever keeps records until they're deleted some other way
+const Forever Period = 0
+
+// ParsePeriod parses a period like 90d, 2160h or forever
+func ParsePeriod(s string) (Period, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "forever") {
+		return Forever, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid period %q", s)
+		}
+		return Period(time.Duration(n) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period %q, e.g. 30d, 720h or forever", s)
+	}
+	return Period(d), nil
+}
+
+func (p *Period) UnmarshalText(text []byte) error {
+	parsed, err := ParsePeriod(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+func (p Period) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p Period) String() string {
+	d := time.Duration(p)
+	switch {
+	case p == Forever:
+		return "forever"
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+type FileConfig struct {
+	// Namespaces are how long each namespace's records are kept, e.g. context: 30d
+	Namespaces map[string]Period `json:"namespaces" yaml:"namespaces"`
+	// Features override their namespace's period for one feature's records
+	Features map[string]Period `json:"features" yaml:"features"`
+	// Grace is how long soft-deleted records are kept before they're removed
+	Grace *Period `json:"grace" yaml:"grace"`
+	// Interval is the time between sweeps, defaults to daily
+	Interval *time.Duration `json:"interval" yaml:"interval"`
+}
+
+type Config struct {
+	Namespaces map[string]time.Duration // Zero keeps records forever
+	Features   map[string]time.Duration
+	Grace      time.Duration
+	Interval   time.Duration
+}
+
+// Enabled reports whether any records expire
+func (c Config) Enabled() bool {
+	return len(c.Namespaces) > 0 || len(c.Features) > 0
+}
+
+// Period returns how long the feature's records are kept, zero for forever
+func (c Config) Period(feature string) time.Duration {
+	if d, ok := c.Features[feature]; ok {
+		return d
+	}
+	return c.Namespaces[Stores[feature]]
+}
+
+// Expired counts the records a sweep expired from a store
+type Expired struct {
+	SoftDeleted int64 // Hidden, and removed after the grace period
+	Purged      int64 // Removed
+}
+
+// Store is persisted user data the sweeper expires
+type Store interface {
+	// Expire deletes records from before the cutoff, or none when it's zero. Stores that
+	// soft-delete hide them instead, remove those hidden before purgeBefore, and bring
+	// back hidden records the cutoff no longer covers.
+	Expire(cutoff, purgeBefore time.Time) (Expired, error)
+}
+
+type trackers interface {
+	Feature(name string) *status.Tracker
+}
+
+type store struct {
+	feature string
+	store   Store
+}
+
+// Sweeper expires the registered stores' records on a schedule
+type Sweeper struct {
+	log         *zap.Logger
+	config      Config
+	trackers    trackers
+	mu          sync.Mutex
+	stores      []store
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+}
+
+func New(log *zap.Logger, c Config, t trackers) *Sweeper {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Grace <= 0 {
+		c.Grace = DefaultGrace
+	}
+	return &Sweeper{
+		log:      log,
+		config:   c,
+		trackers: t,
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Register adds a feature's store, which must be in Stores
+func (s *Sweeper) Register(feature string, st Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stores = append(s.stores, store{feature: feature, store: st})
+}
+
+// Unregister removes a feature's stores, e.g. when the feature stops on a config change
+func (s *Sweeper) Unregister(feature string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stores = slices.DeleteFunc(s.stores, func(st store) bool { return st.feature == feature })
+}
+
+func (s *Sweeper) Start(ctx context.Context) error {
+	s.isConnected.Store(true)
+	go func() {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			_ = s.Sweep()
+			select {
+			case <-s.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	s.log.Debug("Retention sweeps scheduled",
+		zap.Duration("interval", s.config.Interval),
+		zap.Duration("grace", s.config.Grace))
+	return nil
+}
+
+func (s *Sweeper) Stop(ctx context.Context) error {
+	if !s.isConnected.Load() {
+		return nil
+	}
+	close(s.stopCh)
+	s.isConnected.Store(false)
+	return nil
+}
+
+// Sweep expires every store's records past their period, returning the stores' errors
+func (s *Sweeper) Sweep() error {
+	s.mu.Lock()
+	stores := slices.Clone(s.stores)
+	s.mu.Unlock()
+
+	now := s.now()
+	var failed []error
+	for _, st := range stores {
+		var cutoff time.Time
+		period := s.config.Period(st.feature)
+		if period > 0 {
+			cutoff = now.Add(-period)
+		}
+		namespace := Stores[st.feature]
+		tracker := s.trackers.Feature(st.feature)
+		expired, err := st.store.Expire(cutoff, now.Add(-s.config.Grace))
+		if err != nil {
+			tracker.Error(err)
+			s.log.Error("Failed to expire records", zap.String("feature", st.feature), zap.String("namespace", namespace), zap.Error(err))
+			failed = append(failed, fmt.Errorf("expire %s records: %w", st.feature, err))
+			continue
+		}
+		tracker.Expired(namespace, expired.SoftDeleted, expired.Purged)
+		if expired.SoftDeleted > 0 || expired.Purged > 0 {
+			s.log.Info("Expired records",
+				zap.String("feature", st.feature),
+				zap.String("namespace", namespace),
+				zap.Duration("period", period),
+				zap.Int64("soft_deleted", expired.SoftDeleted),
+				zap.Int64("purged", expired.Purged))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// ExpireJSONL removes the lines of a JSONL file whose time field is before the cutoff,
+// returning how many were removed. Lines without a time are kept. The caller serializes
+// writes to the file.
+func ExpireJSONL(path string, cutoff time.Time) (int64, error) {
+	if cutoff.IsZero() {
+		return 0, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the data dir
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, &errs.StorageError{Op: "read", Path: path, Err: err}
+	}
+
+	var kept bytes.Buffer
+	var removed int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Time time.Time `json:"time"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err == nil && !line.Time.IsZero() && line.Time.Before(cutoff) {
+			removed++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, &errs.StorageError{Op: "read", Path: path, Err: err}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, kept.Bytes(), 0o600); err != nil {
+		return 0, &errs.StorageError{Op: "write", Path: tempFile, Err: err}
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return 0, &errs.StorageError{Op: "rename", Path: path, Err: err}
+	}
+	return removed, nil
+}
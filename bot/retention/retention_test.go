@@ -0,0 +1,121 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/status"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Period
+	}{
+		{"30d", Period(30 * 24 * time.Hour)},
+		{"720h", Period(720 * time.Hour)},
+		{"forever", Forever},
+		{"Forever", Forever},
+	}
+	for _, tt := range tests {
+		got, err := ParsePeriod(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePeriod(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "0d", "-1h", "soon", "1w"} {
+		if _, err := ParsePeriod(bad); err == nil {
+			t.Errorf("ParsePeriod(%q) error = nil", bad)
+		}
+	}
+	if got := Period(90 * 24 * time.Hour).String(); got != "90d" {
+		t.Errorf("String() = %q, want 90d", got)
+	}
+}
+
+type mockStore struct {
+	cutoff, purgeBefore time.Time
+	expired             Expired
+}
+
+func (m *mockStore) Expire(cutoff, purgeBefore time.Time) (Expired, error) {
+	m.cutoff, m.purgeBefore = cutoff, purgeBefore
+	return m.expired, nil
+}
+
+func TestSweeper_Sweep(t *testing.T) {
+	now := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	registry := status.NewRegistry()
+	s := New(zap.NewNop(), Config{
+		Namespaces: map[string]time.Duration{"context": 30 * 24 * time.Hour, "audit": 90 * 24 * time.Hour},
+		Features:   map[string]time.Duration{"feedback": 0},
+	}, registry)
+	s.now = func() time.Time { return now }
+
+	aichat := &mockStore{expired: Expired{SoftDeleted: 4, Purged: 2}}
+	feedback := &mockStore{}
+	filescan := &mockStore{expired: Expired{Purged: 1}}
+	s.Register("aichat", aichat)
+	s.Register("feedback", feedback)
+	s.Register("filescan", filescan)
+	if err := s.Sweep(); err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+
+	if !aichat.cutoff.Equal(now.AddDate(0, 0, -30)) || !aichat.purgeBefore.Equal(now.Add(-DefaultGrace)) {
+		t.Errorf("aichat cutoff = %v, purge before %v, want the context period and default grace", aichat.cutoff, aichat.purgeBefore)
+	}
+	if !feedback.cutoff.IsZero() {
+		t.Errorf("feedback cutoff = %v, want the override to keep it forever", feedback.cutoff)
+	}
+	if !filescan.cutoff.Equal(now.AddDate(0, 0, -90)) {
+		t.Errorf("filescan cutoff = %v, want the audit period", filescan.cutoff)
+	}
+
+	var b strings.Builder
+	if err := registry.WriteMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`slackbot_records_expired_total{feature="aichat",namespace="context",action="soft_deleted"} 4`,
+		`slackbot_records_expired_total{feature="aichat",namespace="context",action="purged"} 2`,
+		`slackbot_records_expired_total{feature="filescan",namespace="audit",action="purged"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics missing %s:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestExpireJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	content := `{"time":"2026-01-01T00:00:00Z","id":"old"}
+{"id":"untimed"}
+{"time":"2026-03-01T00:00:00Z","id":"new"}
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, err := ExpireJSONL(path, time.Time{}); err != nil || removed != 0 {
+		t.Errorf("ExpireJSONL() without a cutoff = %d, %v, want nothing removed", removed, err)
+	}
+	removed, err := ExpireJSONL(path, time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || removed != 1 {
+		t.Fatalf("ExpireJSONL() = %d, %v, want 1 removed", removed, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); strings.Contains(got, "old") || !strings.Contains(got, "untimed") || !strings.Contains(got, "new") {
+		t.Errorf("file = %q, want only the old line removed", got)
+	}
+	if removed, err := ExpireJSONL(filepath.Join(t.TempDir(), "missing.jsonl"), time.Now()); err != nil || removed != 0 {
+		t.Errorf("ExpireJSONL() of a missing file = %d, %v, want nothing", removed, err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package retract undoes a feature's response to a message when its author edits the
+// message so it no longer matches, e.g. removing the word "vibe". Features record the
+// reactions they add and the replies they post in an index keyed by the message, and
+// look the message up when a message_changed event arrives within the edit window.
+package retract
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/errs"
+)
+
+// DefaultWindow is how long after a response an edit retracts it unless configured
+const DefaultWindow = 5 * time.Minute
+
+type FileConfig struct {
+	// Reactions removes the reactions the feature added to the edited message
+	Reactions *bool `json:"reactions" yaml:"reactions"`
+	// Messages deletes the replies the feature posted to the edited message
+	Messages *bool `json:"messages" yaml:"messages"`
+	// Window is how long after the response an edit still retracts it, defaults to 5m
+	Window *time.Duration `json:"window" yaml:"window"`
+}
+
+type Config struct {
+	Reactions bool
+	Messages  bool
+	Window    time.Duration
+}
+
+// Enabled reports whether edits retract anything
+func (c Config) Enabled() bool {
+	return c.Reactions || c.Messages
+}
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+// response is what a feature did in reply to one message
+type response struct {
+	at        time.Time
+	reactions []string
+	replies   []string // Timestamps of the replies
+}
+
+// Result is what was undone for an edited message
+type Result struct {
+	Reactions int
+	Replies   int
+}
+
+// Index remembers a feature's responses for the edit window
+type Index struct {
+	config    Config
+	slack     slackService
+	now       func() time.Time
+	mu        sync.Mutex
+	responses map[string]*response // channel:ts -> response
+}
+
+func NewIndex(c Config, s slackService) *Index {
+	if c.Window <= 0 {
+		c.Window = DefaultWindow
+	}
+	return &Index{
+		config:    c,
+		slack:     s,
+		now:       time.Now,
+		responses: make(map[string]*response),
+	}
+}
+
+// Reacted records a reaction added to the message at ts
+func (i *Index) Reacted(channelID, ts, reaction string) {
+	if i == nil || !i.config.Reactions {
+		return
+	}
+	i.record(channelID, ts, func(r *response) { r.reactions = append(r.reactions, reaction) })
+}
+
+// Replied records a reply posted to the message at ts
+func (i *Index) Replied(channelID, ts, replyTS string) {
+	if i == nil || !i.config.Messages || replyTS == "" {
+		return
+	}
+	i.record(channelID, ts, func(r *response) { r.replies = append(r.replies, replyTS) })
+}
+
+func (i *Index) record(channelID, ts string, update func(*response)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := i.now()
+	for key, r := range i.responses {
+		if now.Sub(r.at) > i.config.Window {
+			delete(i.responses, key)
+		}
+	}
+	key := channelID + ":" + ts
+	r, ok := i.responses[key]
+	if !ok {
+		r = &response{at: now}
+		i.responses[key] = r
+	}
+	update(r)
+}
+
+// Responded reports whether the index holds a response to the message at ts that an edit
+// can still retract
+func (i *Index) Responded(channelID, ts string) bool {
+	if i == nil {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	r, ok := i.responses[channelID+":"+ts]
+	return ok && i.now().Sub(r.at) <= i.config.Window
+}
+
+// Retract removes the reactions and deletes the replies recorded for the message at ts,
+// when the response is within the edit window. The response is forgotten either way.
+func (i *Index) Retract(ctx context.Context, channelID, ts string) (Result, error) {
+	if i == nil {
+		return Result{}, nil
+	}
+	i.mu.Lock()
+	key := channelID + ":" + ts
+	r, ok := i.responses[key]
+	delete(i.responses, key)
+	i.mu.Unlock()
+	if !ok || i.now().Sub(r.at) > i.config.Window {
+		return Result{}, nil
+	}
+
+	var result Result
+	var failures []error
+	slices.Sort(r.reactions)
+	for _, reaction := range slices.Compact(r.reactions) {
+		if err := i.slack.Client().RemoveReactionContext(ctx, reaction, slack.NewRefToMessage(channelID, ts)); err != nil {
+			failures = append(failures, errs.NewSlackAPIError("reactions.remove", err))
+			continue
+		}
+		result.Reactions++
+	}
+	for _, replyTS := range r.replies {
+		if _, _, err := i.slack.Client().DeleteMessageContext(ctx, channelID, replyTS); err != nil {
+			failures = append(failures, errs.NewSlackAPIError("chat.delete", err))
+			continue
+		}
+		result.Replies++
+	}
+	return result, errors.Join(failures...)
+}
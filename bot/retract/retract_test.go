@@ -0,0 +1,74 @@
+package retract
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestIndex_Retract(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		calls = append(calls, r.URL.Path+" "+r.PostForm.Get("name")+r.PostForm.Get("ts"))
+		if r.PostForm.Get("ts") == "3.0" {
+			_, _ = w.Write([]byte(`{"ok": false, "error": "message_not_found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	now := time.Unix(1700000000, 0)
+	i := NewIndex(Config{Reactions: true, Messages: true, Window: time.Minute}, slacktest.New(srv.URL))
+	i.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	i.Reacted("C1", "1.0", "ok")
+	i.Reacted("C1", "1.0", "ok")
+	i.Replied("C1", "1.0", "2.0")
+	i.Replied("C1", "1.0", "3.0")
+	if !i.Responded("C1", "1.0") || i.Responded("C1", "9.0") {
+		t.Fatal("Responded() should report only the recorded message")
+	}
+	result, err := i.Retract(ctx, "C1", "1.0")
+	if err == nil {
+		t.Error("Retract() error = nil, want the failed delete reported")
+	}
+	if result != (Result{Reactions: 1, Replies: 1}) {
+		t.Errorf("Retract() = %+v, want the reaction removed once and one reply deleted", result)
+	}
+	if !slices.Equal(calls, []string{"/reactions.remove ok", "/chat.delete 2.0", "/chat.delete 3.0"}) {
+		t.Errorf("calls = %q", calls)
+	}
+	if i.Responded("C1", "1.0") {
+		t.Error("Responded() = true after Retract(), want the response forgotten")
+	}
+
+	// Edits after the window leave the response alone
+	calls = nil
+	i.Replied("C1", "4.0", "5.0")
+	now = now.Add(2 * time.Minute)
+	if result, err := i.Retract(ctx, "C1", "4.0"); err != nil || result != (Result{}) || len(calls) != 0 {
+		t.Errorf("Retract() after the window = %+v, %v with calls %q, want nothing undone", result, err, calls)
+	}
+}
+
+func TestIndex_Disabled(t *testing.T) {
+	i := NewIndex(Config{Messages: true}, &slacktest.Slack{})
+	i.Reacted("C1", "1.0", "ok")
+	if i.Responded("C1", "1.0") {
+		t.Error("Responded() = true, want reactions not recorded when they aren't retracted")
+	}
+
+	var nilIndex *Index
+	nilIndex.Replied("C1", "1.0", "2.0")
+	if nilIndex.Responded("C1", "1.0") {
+		t.Error("Responded() on a nil index = true")
+	}
+}
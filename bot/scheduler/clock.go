@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const clockLayout = "15:04"
+
+// ParseWeekday parses a day name like "monday" or "mon"
+func ParseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// ParseClock parses a time of day like "09:00" into the time since midnight. "24:00" is
+// the end of the day.
+func ParseClock(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a time like 09:00", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// SinceMidnight is the time of day of t, to the minute, in t's location
+func SinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// LastWeekly returns the most recent time at or before now that's on day at hour, in
+// now's location
+func LastWeekly(now time.Time, day time.Weekday, hour int) time.Time {
+	t := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	t = t.AddDate(0, 0, -((int(t.Weekday()) - int(day) + 7) % 7))
+	if t.After(now) {
+		t = t.AddDate(0, 0, -7)
+	}
+	return t
+}
+
+// WeeklyDue is for work done once a week on day at hour, e.g. a weekly report, last done
+// at last. It returns the most recent time the work was due and whether it's still to do.
+func WeeklyDue(now, last time.Time, day time.Weekday, hour int) (time.Time, bool) {
+	due := LastWeekly(now, day, hour)
+	return due, last.Before(due)
+}
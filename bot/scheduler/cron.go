@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors are the shorthands accepted in place of the five fields
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	dayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// field is the set of values a cron field matches, one bit per value
+type field uint64
+
+func (f field) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+// Cron is a parsed cron expression: minute, hour, day of month, month and day of week
+type Cron struct {
+	minute, hour, dom, month, dow field
+	// domAny and dowAny are set when the day field is *. When both day fields are
+	// restricted a day matching either one matches, as in cron.
+	domAny, dowAny bool
+}
+
+// ParseCron parses a five field cron expression such as "30 9 * * mon-fri", or one of
+// @hourly, @daily, @weekly, @monthly and @yearly. Fields accept *, lists, ranges and
+// steps, and month and weekday names.
+func ParseCron(expr string) (Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Cron{}, fmt.Errorf("want 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+	var c Cron
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return Cron{}, fmt.Errorf("minute: %w", err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return Cron{}, fmt.Errorf("hour: %w", err)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return Cron{}, fmt.Errorf("day of month: %w", err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return Cron{}, fmt.Errorf("month: %w", err)
+	}
+	// 7 is Sunday too
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return Cron{}, fmt.Errorf("weekday: %w", err)
+	}
+	if c.dow.has(7) {
+		c.dow |= 1
+	}
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps, like 1,15 or
+// 9-17/2 or */10
+func parseField(s string, lo, hi int, names map[string]int) (field, error) {
+	var f field
+	for part := range strings.SplitSeq(s, ",") {
+		span, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+		start, end := lo, hi
+		if span != "*" {
+			first, last, isRange := strings.Cut(span, "-")
+			var err error
+			if start, err = parseValue(first, lo, hi, names); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if end, err = parseValue(last, lo, hi, names); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				end = start
+			}
+			if start > end {
+				return 0, fmt.Errorf("range %q starts after it ends", span)
+			}
+		}
+		for v := start; v <= end; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+func parseValue(s string, lo, hi int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("%d is outside %d-%d", v, lo, hi)
+	}
+	return v, nil
+}
+
+// matchDay reports whether the day of t matches the day of month and weekday fields
+func (c Cron) matchDay(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time after t the expression matches, in t's location, or the
+// zero time when it never matches, e.g. on February 30
+func (c Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !c.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,365 @@
+// Package scheduler posts messages on cron schedules from the schedules section of the
+// config file, e.g. a weekly reminder or a daily standup prompt. A message is a template
+// filled in with the date when it's posted, and can be handed to the LLM as a prompt so
+// each post is freshly written. Runs missed while the bot was down aren't caught up.
+// Tasks runs the one-off delayed work features schedule, e.g. vibecheck's kicks.
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/tools/timeformat"
+)
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type aiService interface {
+	LLM() llms.Model
+}
+
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
+// leader reports whether this replica should post, so replicas don't post twice
+type leader interface {
+	IsLeader() bool
+}
+
+type ScheduleFileConfig struct {
+	// Name identifies the schedule in logs and the CLI
+	Name string `json:"name" yaml:"name"`
+	// Cron is when to post, e.g. "30 9 * * mon-fri" or @daily
+	Cron string `json:"cron" yaml:"cron"`
+	// Channel is the channel ID to post in
+	Channel string `json:"channel" yaml:"channel"`
+	// Message is a Go template with .Weekday, .Date and .Now, e.g. "Happy {{.Weekday}}!"
+	Message string `json:"message" yaml:"message"`
+	// AI posts what the LLM writes for the message instead of the message itself
+	AI *bool `json:"ai" yaml:"ai"`
+	// Timezone the cron expression and the date are in, e.g. America/Denver, defaults to
+	// the server's
+	Timezone *string        `json:"timezone" yaml:"timezone"`
+	Persona  persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	Schedules []Schedule
+}
+
+// Schedule is a validated schedule, see ParseCron and ParseMessage
+type Schedule struct {
+	Name     string
+	Cron     string
+	Channel  string
+	Message  string
+	AI       bool
+	Timezone string // Empty is the server's
+	Persona  persona.Config
+}
+
+// Run is when a schedule posts next
+type Run struct {
+	Name    string    `json:"name"`
+	Cron    string    `json:"cron"`
+	Channel string    `json:"channel"`
+	AI      bool      `json:"ai"`
+	Next    time.Time `json:"next"`
+}
+
+// messageData is what a message template is filled in with
+type messageData struct {
+	Now     time.Time // When the message is posted, in the schedule's timezone
+	Weekday string    // e.g. Monday
+	Date    string    // A Slack date token, or e.g. January 2 in an AI prompt
+}
+
+// ParseMessage parses a message template
+func ParseMessage(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// job is a schedule ready to run
+type job struct {
+	Schedule
+	cron    Cron
+	loc     *time.Location
+	message *template.Template
+	next    time.Time
+}
+
+// Scheduler posts the configured messages on their schedules
+type Scheduler struct {
+	log         *zap.Logger
+	slack       slackService
+	ai          aiService
+	mu          sync.Mutex
+	jobs        []*job
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	status      *status.Tracker
+	presence    presence
+	leader      leader
+}
+
+// New creates a scheduler for schedules validated by the config. One that fails to
+// parse regardless is logged and skipped.
+func New(log *zap.Logger, c Config, s slackService) *Scheduler {
+	sc := &Scheduler{
+		log:    log,
+		slack:  s,
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+	}
+	for _, schedule := range c.Schedules {
+		j, err := newJob(schedule)
+		if err != nil {
+			log.Error("Skipping invalid schedule", zap.String("name", schedule.Name), zap.Error(err))
+			continue
+		}
+		sc.jobs = append(sc.jobs, j)
+	}
+	return sc
+}
+
+func newJob(s Schedule) (*job, error) {
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("cron: %w", err)
+	}
+	message, err := ParseMessage(s.Name, s.Message)
+	if err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+	loc := time.Local
+	if s.Timezone != "" {
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+	}
+	return &job{Schedule: s, cron: cron, loc: loc, message: message}, nil
+}
+
+// SetAI sets the LLM that writes the messages of schedules with ai set
+func (sc *Scheduler) SetAI(a aiService) {
+	sc.ai = a
+}
+
+// SetPresence skips posting in channels the bot was removed from
+func (sc *Scheduler) SetPresence(p presence) {
+	sc.presence = p
+}
+
+// SetLeader posts only while this replica is the leader
+func (sc *Scheduler) SetLeader(l leader) {
+	sc.leader = l
+}
+
+// SetStatusTracker sets the tracker that records the feature's activity
+func (sc *Scheduler) SetStatusTracker(t *status.Tracker) {
+	sc.status = t
+}
+
+func (sc *Scheduler) Start(ctx context.Context) error {
+	sc.isConnected.Store(true)
+	for _, j := range sc.jobs {
+		if j.AI && sc.ai == nil {
+			sc.log.Warn("Schedule uses the AI but no LLM is configured, it won't post",
+				zap.String("name", j.Name))
+		}
+	}
+	go sc.run(ctx)
+	sc.log.Debug("Scheduler started.", zap.Int("schedules", len(sc.jobs)))
+	return nil
+}
+
+func (sc *Scheduler) Stop(_ context.Context) error {
+	if !sc.isConnected.Load() {
+		return nil
+	}
+	close(sc.stopCh)
+	sc.isConnected.Store(false)
+	return nil
+}
+
+func (sc *Scheduler) run(ctx context.Context) {
+	sc.mu.Lock()
+	now := sc.now()
+	for _, j := range sc.jobs {
+		j.next = j.cron.Next(now.In(j.loc))
+	}
+	sc.mu.Unlock()
+	for {
+		next, ok := sc.nextRun()
+		if !ok {
+			sc.log.Info("No schedules will run")
+			return
+		}
+		timer := time.NewTimer(next.Sub(sc.now()))
+		select {
+		case <-sc.stopCh:
+			timer.Stop()
+			return
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			sc.runDue(ctx)
+		}
+	}
+}
+
+// nextRun is when the next schedule is due
+func (sc *Scheduler) nextRun() (time.Time, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var next time.Time
+	for _, j := range sc.jobs {
+		if !j.next.IsZero() && (next.IsZero() || j.next.Before(next)) {
+			next = j.next
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// runDue posts the schedules that are due and moves each to its next run
+func (sc *Scheduler) runDue(ctx context.Context) {
+	sc.mu.Lock()
+	now := sc.now()
+	var due []*job
+	for _, j := range sc.jobs {
+		if !j.next.IsZero() && !j.next.After(now) {
+			due = append(due, j)
+			j.next = j.cron.Next(now.In(j.loc))
+		}
+	}
+	sc.mu.Unlock()
+	for _, j := range due {
+		if sc.leader != nil && !sc.leader.IsLeader() {
+			sc.log.Debug("Not the leader, skipping schedule", zap.String("name", j.Name))
+			continue
+		}
+		if err := sc.post(ctx, j, now); err != nil {
+			sc.log.Error("Failed to post scheduled message", zap.String("name", j.Name), zap.Error(err))
+		}
+	}
+}
+
+// RunNow posts a schedule's message immediately, off its schedule
+func (sc *Scheduler) RunNow(ctx context.Context, name string) error {
+	i := slices.IndexFunc(sc.jobs, func(j *job) bool { return j.Name == name })
+	if i < 0 {
+		return fmt.Errorf("no schedule %q", name)
+	}
+	return sc.post(ctx, sc.jobs[i], sc.now())
+}
+
+// Runs lists the schedules and when each posts next
+func (sc *Scheduler) Runs() []Run {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	runs := make([]Run, 0, len(sc.jobs))
+	for _, j := range sc.jobs {
+		next := j.next
+		if next.IsZero() {
+			next = j.cron.Next(sc.now().In(j.loc))
+		}
+		runs = append(runs, Run{Name: j.Name, Cron: j.Cron, Channel: j.Channel, AI: j.AI, Next: next})
+	}
+	return runs
+}
+
+func (sc *Scheduler) post(ctx context.Context, j *job, now time.Time) error {
+	sc.status.Event()
+	if sc.presence != nil && !sc.presence.Present(ctx, j.Channel) {
+		sc.log.Info("Bot was removed from the channel, skipping scheduled message",
+			zap.String("name", j.Name),
+			zap.String("channel", j.Channel))
+		return nil
+	}
+	text, err := sc.render(ctx, j, now)
+	if err != nil {
+		sc.status.Error(err)
+		return err
+	}
+	_, _, err = sc.slack.Client().PostMessageContext(ctx, j.Channel,
+		slack.MsgOptionText(text, false),
+		j.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = conversation.Error("chat.postMessage", conversation.KindFromID(j.Channel), err)
+		sc.status.PostFailed(j.Channel, err)
+		return err
+	}
+	sc.status.Posted()
+	sc.log.Info("Posted scheduled message", zap.String("name", j.Name), zap.String("channel", j.Channel))
+	return nil
+}
+
+// render fills in the message template, then has the LLM write the message from it when
+// the schedule uses the AI
+func (sc *Scheduler) render(ctx context.Context, j *job, now time.Time) (string, error) {
+	local := now.In(j.loc)
+	// A posted date is shown in each reader's timezone, but the LLM can't read Slack's
+	// date tokens so its prompt has the date in the schedule's timezone
+	date := timeformat.Slack(local, timeformat.Date)
+	if j.AI {
+		date = timeformat.In(now, j.loc.String(), "January 2")
+	}
+	var b bytes.Buffer
+	err := j.message.Execute(&b, messageData{
+		Now:     local,
+		Weekday: local.Weekday().String(),
+		Date:    date,
+	})
+	if err != nil {
+		return "", fmt.Errorf("render message: %w", err)
+	}
+	text := strings.TrimSpace(b.String())
+	if !j.AI {
+		return text, nil
+	}
+	if sc.ai == nil {
+		return "", errors.New("no LLM is configured to write the message")
+	}
+	return sc.generate(ctx, text)
+}
+
+const systemPrompt = `You write a message the bot posts to a Slack channel on a schedule. ` +
+	`Follow the instructions below. Keep it short and casual, a few sentences at most. ` +
+	`Output only the message itself — no preamble, no quotes.`
+
+func (sc *Scheduler) generate(ctx context.Context, prompt string) (string, error) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	}
+	resp, err := sc.ai.LLM().GenerateContent(ctx, messages, llms.WithMaxTokens(400))
+	if err != nil {
+		return "", errs.NewLLMError("generate content", err)
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Content) == "" {
+		return "", errors.New("empty response from LLM")
+	}
+	return strings.TrimSpace(resp.Choices[0].Content), nil
+}
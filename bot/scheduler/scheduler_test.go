@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/slacktest"
+	"slackbot.arpa/tools/timeformat"
+)
+
+type mockLeader bool
+
+func (l mockLeader) IsLeader() bool { return bool(l) }
+
+func TestCron_Next(t *testing.T) {
+	// Tuesday
+	at := time.Date(2026, time.March, 10, 9, 45, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"30 9 * * mon-fri", time.Date(2026, time.March, 11, 9, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, time.March, 10, 10, 0, 0, 0, time.UTC)},
+		{"0 17 * * 5", time.Date(2026, time.March, 13, 17, 0, 0, 0, time.UTC)},
+		{"0 9 1 * *", time.Date(2026, time.April, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 9 1,15 * sun", time.Date(2026, time.March, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * jun *", time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+		}
+		if got := c.Next(at); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 5-1 * * *", "*/0 * * * *", "0 9 * * funday"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) = nil error, want an error", expr)
+		}
+	}
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	var posts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posts = append(posts, r.FormValue("channel")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(slacktest.Posted))
+	}))
+	defer server.Close()
+
+	s := slacktest.New(server.URL)
+	sc := New(zap.NewNop(), Config{Schedules: []Schedule{
+		{Name: "standup", Cron: "0 9 * * mon-fri", Channel: "C1", Message: "Happy {{.Weekday}}, {{.Date}}! What are you working on?"},
+		{Name: "weekly", Cron: "0 9 * * mon", Channel: "C2", Message: "New week"},
+		{Name: "broken", Cron: "never", Channel: "C3", Message: "Skipped"},
+	}}, s)
+	if len(sc.jobs) != 2 {
+		t.Fatalf("jobs = %d, want the invalid schedule skipped", len(sc.jobs))
+	}
+
+	// Tuesday
+	now := time.Date(2026, time.March, 10, 9, 0, 0, 0, time.Local)
+	sc.now = func() time.Time { return now }
+	for _, j := range sc.jobs {
+		j.next = j.cron.Next(now.Add(-time.Minute))
+	}
+	sc.runDue(context.Background())
+	want := "C1: Happy Tuesday, " + timeformat.Slack(now, timeformat.Date) + "! What are you working on?"
+	if len(posts) != 1 || posts[0] != want {
+		t.Errorf("posts = %q, want only the standup", posts)
+	}
+	if next, _ := sc.nextRun(); !next.Equal(time.Date(2026, time.March, 11, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("nextRun() = %v, want Wednesday at 9", next)
+	}
+
+	posts = nil
+	sc.SetLeader(mockLeader(false))
+	now = now.AddDate(0, 0, 1)
+	sc.runDue(context.Background())
+	if len(posts) != 0 {
+		t.Errorf("posts = %q, want none while not the leader", posts)
+	}
+
+	if err := sc.RunNow(context.Background(), "weekly"); err != nil || len(posts) != 1 || posts[0] != "C2: New week" {
+		t.Errorf("RunNow() error = %v, posts = %q, want the weekly message", err, posts)
+	}
+	if err := sc.RunNow(context.Background(), "missing"); err == nil {
+		t.Error("RunNow() of an unknown schedule = nil error, want an error")
+	}
+}
+
+func TestTasks(t *testing.T) {
+	tasks := NewTasks(2)
+	group := tasks.Group()
+
+	ran := make(chan error, 1)
+	if !group.After("C1/U1", time.Millisecond, func(ctx context.Context) { ran <- ctx.Err() }) {
+		t.Fatal("After() = false, want the task scheduled")
+	}
+	select {
+	case err := <-ran:
+		if err != nil {
+			t.Errorf("task context error = %v, want a live context", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task didn't run")
+	}
+
+	var late atomic.Bool
+	group.After("C1/U2", time.Hour, func(context.Context) { late.Store(true) })
+	if group.After("C1/U2", time.Hour, func(context.Context) {}) {
+		t.Error("After() = true for a pending key, want false")
+	}
+	// The limit is shared by every group
+	other := tasks.Group()
+	other.After("C2/U1", time.Hour, func(context.Context) {})
+	if other.After("C2/U2", time.Hour, func(context.Context) {}) {
+		t.Error("After() = true past the shared limit, want false")
+	}
+	if got := tasks.Pending(); got != 2 {
+		t.Errorf("Pending() = %d, want 2", got)
+	}
+
+	// Stopping a feature's group leaves the others running
+	if got := group.Stop(); got != 1 {
+		t.Errorf("Stop() = %d, want 1 dropped task", got)
+	}
+	if late.Load() || group.Pending() != 0 || other.Pending() != 1 || tasks.Pending() != 1 {
+		t.Error("only the stopped group's pending task should be dropped")
+	}
+	if group.After("C1/U3", time.Millisecond, func(context.Context) {}) {
+		t.Error("After() = true after Stop, want false")
+	}
+
+	if got := tasks.Stop(); got != 1 {
+		t.Errorf("Tasks.Stop() = %d, want the other group's task dropped", got)
+	}
+	if tasks.Group().After("C3/U1", time.Millisecond, func(context.Context) {}) {
+		t.Error("After() = true on a group created after Stop, want false")
+	}
+}
+func TestParseWeekday(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Weekday
+		ok   bool
+	}{
+		{"monday", time.Monday, true},
+		{"Fri", time.Friday, true},
+		{" SUNDAY ", time.Sunday, true},
+		{"someday", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseWeekday(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseWeekday(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	for in, want := range map[string]time.Duration{"09:30": 9*time.Hour + 30*time.Minute, " 00:00": 0, "24:00": 24 * time.Hour} {
+		if got, err := ParseClock(in); err != nil || got != want {
+			t.Errorf("ParseClock(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"9am", "25:00", ""} {
+		if _, err := ParseClock(in); err == nil {
+			t.Errorf("ParseClock(%q) should fail", in)
+		}
+	}
+}
+
+func TestLastWeekly(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		// Wednesday, after Monday's report
+		{time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+		// Monday, before the report hour
+		{time.Date(2026, 3, 9, 8, 59, 0, 0, time.UTC), time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+		// Monday, at the report hour
+		{time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		// Sunday
+		{time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := LastWeekly(tt.now, time.Monday, 9); !got.Equal(tt.want) {
+			t.Errorf("LastWeekly(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxPendingTasks caps how many delayed tasks can wait at once across features, so
+// a flood of e.g. failed vibechecks or rejoins can't pile up timers
+const DefaultMaxPendingTasks = 100
+
+// Tasks runs one-off work after a delay for features that wait before acting, e.g.
+// vibecheck kicking a user a few seconds after its verdict. Features schedule on their
+// own TaskGroup, stopped with the feature, and stopping Tasks stops every group.
+type Tasks struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	limit   int
+	mu      sync.Mutex
+	pending int
+	groups  map[*TaskGroup]struct{}
+	stopped bool
+}
+
+func NewTasks(limit int) *Tasks {
+	if limit <= 0 {
+		limit = DefaultMaxPendingTasks
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tasks{
+		ctx:    ctx,
+		cancel: cancel,
+		limit:  limit,
+		groups: make(map[*TaskGroup]struct{}),
+	}
+}
+
+// Group returns a new group of tasks for a feature
+func (t *Tasks) Group() *TaskGroup {
+	ctx, cancel := context.WithCancel(t.ctx)
+	g := &TaskGroup{
+		tasks:   t,
+		ctx:     ctx,
+		cancel:  cancel,
+		pending: make(map[string]*time.Timer),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		g.stopped = true
+	} else {
+		t.groups[g] = struct{}{}
+	}
+	return g
+}
+
+// Pending is the number of tasks waiting to run across groups
+func (t *Tasks) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending
+}
+
+// reserve takes a slot for a pending task, reporting false when the limit is reached
+func (t *Tasks) reserve() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped || t.pending >= t.limit {
+		return false
+	}
+	t.pending++
+	return true
+}
+
+func (t *Tasks) release(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending -= n
+}
+
+// Stop stops every group, dropping their pending tasks and waiting for running ones. It
+// reports how many pending tasks were dropped.
+func (t *Tasks) Stop() int {
+	t.mu.Lock()
+	t.stopped = true
+	groups := make([]*TaskGroup, 0, len(t.groups))
+	for g := range t.groups {
+		groups = append(groups, g)
+	}
+	t.mu.Unlock()
+
+	t.cancel()
+	var dropped int
+	for _, g := range groups {
+		dropped += g.Stop()
+	}
+	return dropped
+}
+
+// TaskGroup is a feature's tasks. They share a context that's cancelled when the group
+// stops, which also drops tasks that haven't started and waits for running ones, so
+// nothing outlives the feature.
+type TaskGroup struct {
+	tasks   *Tasks
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+	stopped bool
+}
+
+// After runs fn once d has passed. Tasks are keyed so the same work is only scheduled
+// once; it reports false when key is already pending, the limit is reached, or the
+// group was stopped.
+func (g *TaskGroup) After(key string, d time.Duration, fn func(ctx context.Context)) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return false
+	}
+	if _, ok := g.pending[key]; ok {
+		return false
+	}
+	if !g.tasks.reserve() {
+		return false
+	}
+
+	g.wg.Add(1)
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		g.mu.Lock()
+		current, ok := g.pending[key]
+		if ok && current == timer {
+			delete(g.pending, key)
+		}
+		g.mu.Unlock()
+		// Stop already released tasks it dropped
+		if !ok || current != timer {
+			return
+		}
+		g.tasks.release(1)
+		defer g.wg.Done()
+		fn(g.ctx)
+	})
+	g.pending[key] = timer
+	return true
+}
+
+// Pending is the number of the group's tasks waiting to run
+func (g *TaskGroup) Pending() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.pending)
+}
+
+// Stop drops pending tasks, cancels running ones and waits for them to return. It
+// reports how many pending tasks were dropped.
+func (g *TaskGroup) Stop() int {
+	g.mu.Lock()
+	g.stopped = true
+	dropped := len(g.pending)
+	for key, timer := range g.pending {
+		timer.Stop()
+		delete(g.pending, key)
+		g.wg.Done()
+	}
+	g.tasks.release(dropped)
+	g.mu.Unlock()
+
+	g.cancel()
+	g.wg.Wait()
+
+	g.tasks.mu.Lock()
+	delete(g.tasks.groups, g)
+	g.tasks.mu.Unlock()
+	return dropped
+}
@@ -0,0 +1,321 @@
+// Package selftest runs a nightly check of the bot's dependencies: Slack auth, posting
+// and deleting a message, an LLM call and the data directory. The results are posted to
+// an ops channel so expired tokens or an exhausted quota are noticed before users do.
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+)
+
+const (
+	stateFile = "selftest.json"
+
+	DefaultHour = 3
+
+	// checkInterval is how often the schedule is checked, so a run missed while the bot
+	// was down happens soon after it starts
+	checkInterval = 5 * time.Minute
+	// checkTimeout bounds each check
+	checkTimeout = 30 * time.Second
+)
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+type aiService interface {
+	LLM() llms.Model
+}
+
+type FileConfig struct {
+	// Channel is where results are posted, empty disables the self-test
+	Channel *string `json:"channel" yaml:"channel"`
+	// TestChannel is where the canary message is posted and deleted, defaults to Channel
+	TestChannel *string `json:"test_channel" yaml:"test_channel"`
+	// Hour is when the self-test runs each day, in local time, defaults to 3
+	Hour    *int           `json:"hour" yaml:"hour"`
+	Persona persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	DataDir     string
+	Channel     string
+	TestChannel string
+	Hour        int
+	Persona     persona.Config // Name and icon results are posted with
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name     string        `json:"name"`
+	Error    string        `json:"error,omitempty"`
+	Skipped  string        `json:"skipped,omitempty"` // Why the check didn't run
+	Duration time.Duration `json:"duration"`
+}
+
+// OK reports whether the check passed or was skipped
+func (r Result) OK() bool {
+	return r.Error == ""
+}
+
+type state struct {
+	LastRun time.Time `json:"last_run,omitzero"`
+}
+
+// SelfTest runs the checks on a schedule and reports them
+type SelfTest struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	ai          aiService
+	path        string
+	now         func() time.Time
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	status      *status.Tracker
+}
+
+func New(log *zap.Logger, c Config, s slackService) *SelfTest {
+	if c.TestChannel == "" {
+		c.TestChannel = c.Channel
+	}
+	return &SelfTest{
+		log:    log,
+		config: c,
+		slack:  s,
+		path:   filepath.Join(c.DataDir, stateFile),
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// SetAI sets the model the LLM check calls. Without one the check is skipped.
+func (t *SelfTest) SetAI(ai aiService) {
+	t.ai = ai
+}
+
+// SetStatusTracker sets where runs and failures are reported
+func (t *SelfTest) SetStatusTracker(tracker *status.Tracker) {
+	t.status = tracker
+}
+
+// Start runs the self-test each day at the configured hour
+func (t *SelfTest) Start(ctx context.Context) error {
+	t.isConnected.Store(true)
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.runIfDue(ctx)
+			}
+		}
+	}()
+	t.log.Debug("Self-test scheduled",
+		zap.String("channel", t.config.Channel),
+		zap.String("test_channel", t.config.TestChannel),
+		zap.Int("hour", t.config.Hour))
+	return nil
+}
+
+func (t *SelfTest) Stop(ctx context.Context) error {
+	if !t.isConnected.Load() {
+		return nil
+	}
+	close(t.stopCh)
+	t.isConnected.Store(false)
+	return nil
+}
+
+// lastRunTime returns the most recent scheduled run at or before now
+func (t *SelfTest) lastRunTime(now time.Time) time.Time {
+	due := time.Date(now.Year(), now.Month(), now.Day(), t.config.Hour, 0, 0, 0, now.Location())
+	if due.After(now) {
+		due = due.AddDate(0, 0, -1)
+	}
+	return due
+}
+
+// runIfDue runs the self-test once per scheduled time and posts the results
+func (t *SelfTest) runIfDue(ctx context.Context) {
+	due := t.lastRunTime(t.now())
+	if !t.load().LastRun.Before(due) {
+		return
+	}
+	// Recorded first, so a run that fails to post isn't retried every few minutes
+	t.save(state{LastRun: due})
+	t.Report(ctx, t.Run(ctx))
+}
+
+// Run runs every check and returns the results in order
+func (t *SelfTest) Run(ctx context.Context) []Result {
+	checks := []struct {
+		name string
+		fn   func(ctx context.Context) (skipped string, err error)
+	}{
+		{"slack_auth", t.checkAuth},
+		{"slack_post", t.checkPost},
+		{"llm", t.checkLLM},
+		{"storage", t.checkStorage},
+	}
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		start := time.Now()
+		skipped, err := check.fn(checkCtx)
+		cancel()
+		result := Result{Name: check.name, Skipped: skipped, Duration: time.Since(start).Round(time.Millisecond)}
+		if err != nil {
+			result.Error = err.Error()
+			t.status.Error(fmt.Errorf("self-test %s: %w", check.name, err))
+			t.log.Warn("Self-test check failed", zap.String("check", check.name), zap.Error(err))
+		}
+		results = append(results, result)
+	}
+	t.status.Event()
+	return results
+}
+
+// Report posts the results to the ops channel
+func (t *SelfTest) Report(ctx context.Context, results []Result) {
+	_, _, err := t.slack.Client().PostMessageContext(ctx, t.config.Channel,
+		slack.MsgOptionText(Format(results), false),
+		t.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		t.status.PostFailed(t.config.Channel, err)
+		t.log.Error("Failed to post self-test results", zap.String("channel", t.config.Channel), zap.Error(err))
+		return
+	}
+	t.status.Posted()
+}
+
+func (t *SelfTest) checkAuth(ctx context.Context) (string, error) {
+	if _, err := t.slack.Client().AuthTestContext(ctx); err != nil {
+		return "", errs.NewSlackAPIError("auth.test", err)
+	}
+	return "", nil
+}
+
+// checkPost posts a canary message and deletes it
+func (t *SelfTest) checkPost(ctx context.Context) (string, error) {
+	client := t.slack.Client()
+	channel, ts, err := client.PostMessageContext(ctx, t.config.TestChannel,
+		slack.MsgOptionText("Self-test canary, deleting it now.", false),
+		t.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		return "", errs.NewSlackAPIError("chat.postMessage", err)
+	}
+	if _, _, err := client.DeleteMessageContext(ctx, channel, ts); err != nil {
+		return "", errs.NewSlackAPIError("chat.delete", err)
+	}
+	return "", nil
+}
+
+func (t *SelfTest) checkLLM(ctx context.Context) (string, error) {
+	if t.ai == nil || t.ai.LLM() == nil {
+		return "AI isn't configured", nil
+	}
+	reply, err := llms.GenerateFromSinglePrompt(ctx, t.ai.LLM(), "Reply with the word OK.", llms.WithMaxTokens(5))
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(reply) == "" {
+		return "", errors.New("empty reply")
+	}
+	return "", nil
+}
+
+// checkStorage writes, reads back and removes a file in the data directory
+func (t *SelfTest) checkStorage(ctx context.Context) (string, error) {
+	path := filepath.Join(t.config.DataDir, ".selftest")
+	want := t.now().Format(time.RFC3339Nano)
+	if err := os.WriteFile(path, []byte(want), 0600); err != nil {
+		return "", &errs.StorageError{Op: "write", Path: path, Err: err}
+	}
+	defer func() { _ = os.Remove(path) }()
+	got, err := os.ReadFile(path) // #nosec G304 -- path is in the data directory
+	if err != nil {
+		return "", &errs.StorageError{Op: "read", Path: path, Err: err}
+	}
+	if string(got) != want {
+		return "", &errs.StorageError{Op: "read", Path: path, Err: errors.New("content doesn't match what was written")}
+	}
+	return "", nil
+}
+
+// Format renders results as a Slack message
+func Format(results []Result) string {
+	var failed int
+	for _, r := range results {
+		if !r.OK() {
+			failed++
+		}
+	}
+	var b strings.Builder
+	if failed == 0 {
+		fmt.Fprintf(&b, ":white_check_mark: *Self-test passed*\n")
+	} else {
+		fmt.Fprintf(&b, ":x: *Self-test failed* %d of %d checks\n", failed, len(results))
+	}
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(&b, "• %s: failed, %s\n", r.Name, r.Error)
+		case r.Skipped != "":
+			fmt.Fprintf(&b, "• %s: skipped, %s\n", r.Name, r.Skipped)
+		default:
+			fmt.Fprintf(&b, "• %s: ok in %s\n", r.Name, r.Duration)
+		}
+	}
+	return b.String()
+}
+
+func (t *SelfTest) load() state {
+	var s state
+	data, err := os.ReadFile(t.path)
+	switch {
+	case os.IsNotExist(err):
+		return s
+	case err != nil:
+		t.log.Error("Failed to read self-test state", zap.Error(err), zap.String("path", t.path))
+		return s
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.log.Error("Failed to unmarshal self-test state", zap.Error(err), zap.String("path", t.path))
+	}
+	return s
+}
+
+func (t *SelfTest) save(s state) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.log.Error("Failed to marshal self-test state", zap.Error(err))
+		return
+	}
+	if err := os.WriteFile(t.path, data, 0600); err != nil {
+		t.log.Error("Failed to save self-test state", zap.Error(&errs.StorageError{Op: "write", Path: t.path, Err: err}))
+	}
+}
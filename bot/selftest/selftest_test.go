@@ -0,0 +1,157 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/slacktest"
+)
+
+type fakeModel struct {
+	reply string
+	err   error
+}
+
+func (m *fakeModel) GenerateContent(_ context.Context, _ []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.reply}}}, nil
+}
+
+func (m *fakeModel) Call(ctx context.Context, prompt string, _ ...llms.CallOption) (string, error) {
+	return m.reply, m.err
+}
+
+type fakeAI struct {
+	model llms.Model
+}
+
+func (a fakeAI) LLM() llms.Model { return a.model }
+
+// slackServer answers auth.test, chat.postMessage and chat.delete, failing auth when
+// the token is expired
+type slackServer struct {
+	mu      sync.Mutex
+	expired bool
+	calls   []string
+	posts   []string
+}
+
+func (s *slackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	method := strings.TrimPrefix(r.URL.Path, "/")
+	s.calls = append(s.calls, method+" "+r.FormValue("channel"))
+	switch {
+	case s.expired && method == "auth.test":
+		_, _ = w.Write([]byte(`{"ok": false, "error": "token_expired"}`))
+	case method == "chat.postMessage":
+		s.posts = append(s.posts, r.FormValue("channel")+": "+r.FormValue("text"))
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "` + r.FormValue("channel") + `", "ts": "1.1"}`))
+	default:
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+	}
+}
+
+func newSelfTest(t *testing.T, server *slackServer) *SelfTest {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	s := slacktest.New(httpServer.URL)
+	return New(zap.NewNop(), Config{DataDir: t.TempDir(), Channel: "COPS", TestChannel: "CTEST", Hour: 3}, s)
+}
+
+func TestSelfTest_Run(t *testing.T) {
+	server := &slackServer{}
+	st := newSelfTest(t, server)
+
+	results := st.Run(context.Background())
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.Name)
+		if !r.OK() {
+			t.Errorf("Run() %s failed: %s", r.Name, r.Error)
+		}
+	}
+	if got := strings.Join(names, ","); got != "slack_auth,slack_post,llm,storage" {
+		t.Errorf("Run() checks = %s, want slack_auth,slack_post,llm,storage", got)
+	}
+	if results[2].Skipped == "" {
+		t.Error("Run() llm wasn't skipped without AI")
+	}
+	if got := strings.Join(server.calls, ","); got != "auth.test ,chat.postMessage CTEST,chat.delete CTEST" {
+		t.Errorf("Slack calls = %s, want the canary posted and deleted in the test channel", got)
+	}
+
+	server.expired = true
+	st.SetAI(fakeAI{model: &fakeModel{err: errors.New("insufficient_quota")}})
+	results = st.Run(context.Background())
+	if results[0].OK() || !strings.Contains(results[0].Error, "token_expired") {
+		t.Errorf("Run() slack_auth = %+v, want the expired token reported", results[0])
+	}
+	if results[2].OK() || !strings.Contains(results[2].Error, "insufficient_quota") {
+		t.Errorf("Run() llm = %+v, want the quota error reported", results[2])
+	}
+
+	summary := Format(results)
+	for _, want := range []string{"Self-test failed* 2 of 4 checks", "• slack_auth: failed", "• storage: ok in"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Format() = %q, want it to contain %q", summary, want)
+		}
+	}
+}
+
+func TestSelfTest_RunIfDue(t *testing.T) {
+	server := &slackServer{}
+	st := newSelfTest(t, server)
+	st.SetAI(fakeAI{model: &fakeModel{reply: "OK"}})
+	now := time.Date(2026, 3, 2, 2, 0, 0, 0, time.Local)
+	st.now = func() time.Time { return now }
+
+	// The first run happens at the first check, then once a day at the hour
+	st.runIfDue(context.Background())
+	if len(server.posts) != 2 || !strings.HasPrefix(server.posts[0], "CTEST: Self-test canary") {
+		t.Fatalf("posts = %q, want the canary and the summary", server.posts)
+	}
+	if !strings.HasPrefix(server.posts[1], "COPS: :white_check_mark: *Self-test passed*") {
+		t.Errorf("posts[1] = %q, want a passing summary in the ops channel", server.posts[1])
+	}
+
+	now = now.Add(30 * time.Minute)
+	st.runIfDue(context.Background())
+	if len(server.posts) != 2 {
+		t.Errorf("posts = %q, want no second run before the hour", server.posts)
+	}
+
+	now = time.Date(2026, 3, 2, 3, 5, 0, 0, time.Local)
+	st.runIfDue(context.Background())
+	if len(server.posts) != 4 {
+		t.Errorf("posts = %q, want a run after the hour", server.posts)
+	}
+}
+
+func TestSelfTest_LastRunTime(t *testing.T) {
+	st := New(zap.NewNop(), Config{Hour: 3}, nil)
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 3, 2, 2, 59, 0, 0, time.UTC), time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := st.lastRunTime(tt.now); !got.Equal(tt.want) {
+			t.Errorf("lastRunTime(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
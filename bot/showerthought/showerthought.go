@@ -8,13 +8,14 @@ import (
 
 	goslack "github.com/slack-go/slack"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/status"
 	"slackbot.arpa/tools/random"
 )
 
 type aiService interface {
-	LLM() *openai.LLM
+	LLM() llms.Model
 }
 
 type slackService interface {
@@ -22,6 +23,11 @@ type slackService interface {
 	BotUserID() string
 }
 
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
 type FileConfig struct {
 	Enabled            *bool `json:"enabled" yaml:"enabled"`
 	BusinessHoursStart *int  `json:"business_hours_start" yaml:"business_hours_start"`
@@ -36,11 +42,13 @@ type Config struct {
 }
 
 type ShowerThought struct {
-	log    *zap.Logger
-	config Config
-	slack  slackService
-	ai     aiService
-	stopCh chan struct{}
+	log      *zap.Logger
+	config   Config
+	slack    slackService
+	ai       aiService
+	stopCh   chan struct{}
+	status   *status.Tracker
+	presence presence
 }
 
 func New(log *zap.Logger, c Config, s slackService, a aiService) *ShowerThought {
@@ -53,6 +61,16 @@ func New(log *zap.Logger, c Config, s slackService, a aiService) *ShowerThought
 	}
 }
 
+// SetPresence skips posting while the bot is removed from the notify channel
+func (st *ShowerThought) SetPresence(p presence) {
+	st.presence = p
+}
+
+// SetStatusTracker sets the tracker that records the feature's activity
+func (st *ShowerThought) SetStatusTracker(t *status.Tracker) {
+	st.status = t
+}
+
 func (st *ShowerThought) Start(ctx context.Context) error {
 	go st.run(ctx)
 	return nil
@@ -126,8 +144,15 @@ const systemPrompt = `You generate shower thoughts — those random, funny, or m
 	`Keep it to 1–2 sentences. Output only the thought itself — no preamble, no quotes.`
 
 func (st *ShowerThought) postShowerThought(ctx context.Context) {
+	st.status.Event()
+	if st.presence != nil && !st.presence.Present(ctx, st.config.NotifyChannel) {
+		st.log.Info("Bot was removed from the notify channel, skipping shower thought",
+			zap.String("channel", st.config.NotifyChannel))
+		return
+	}
 	thought, err := st.generateShowerThought(ctx)
 	if err != nil {
+		st.status.Error(err)
 		st.log.Error("Failed to generate shower thought", zap.Error(err))
 		return
 	}
@@ -148,12 +173,14 @@ func (st *ShowerThought) postShowerThought(ctx context.Context) {
 		goslack.MsgOptionAsUser(true),
 	)
 	if err != nil {
+		st.status.Error(err)
 		st.log.Error("Failed to post shower thought",
 			zap.String("channel", st.config.NotifyChannel),
 			zap.Error(err),
 		)
 		return
 	}
+	st.status.Posted()
 
 	st.log.Info("Posted shower thought", zap.String("channel", st.config.NotifyChannel))
 }
@@ -195,7 +222,7 @@ func (st *ShowerThought) generateShowerThought(ctx context.Context) (string, err
 		llms.WithPresencePenalty(0.5),
 	)
 	if err != nil {
-		return "", fmt.Errorf("generate content: %w", err)
+		return "", errs.NewLLMError("generate content", err)
 	}
 
 	if len(resp.Choices) == 0 || resp.Choices[0].Content == "" {
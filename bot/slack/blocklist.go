@@ -0,0 +1,319 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	BlocklistBlock  = "block"
+	BlocklistRedact = "redact"
+
+	DefaultBlocklistReplacement = "[redacted]"
+
+	// blockedError is the error Slack calls the blocklist stopped fail with
+	blockedError = "blocked_by_blocklist"
+)
+
+// blocklistFields are the arguments of a form request that carry text people read
+var blocklistFields = []string{"text", "blocks", "attachments", "files", "initial_comment", "title", "topic", "purpose"}
+
+// blocklistJSONFields are the blocklistFields holding JSON, which must still parse once
+// redacted
+var blocklistJSONFields = []string{"blocks", "attachments", "files"}
+
+// blocklistMediaTypes are the request bodies checked against the blocklist
+var blocklistMediaTypes = []string{"application/x-www-form-urlencoded", "application/json", "multipart/form-data"}
+
+type BlocklistFileConfig struct {
+	// Phrases are matched regardless of case, as whole words when they begin and end with one
+	Phrases []string `json:"phrases" yaml:"phrases"`
+	// Patterns are regular expressions, e.g. "xox[bp]-[0-9A-Za-z-]+" for Slack tokens
+	Patterns []string `json:"patterns" yaml:"patterns"`
+	// Action is block, the default, to drop the message, or redact to send it with the
+	// matches replaced
+	Action *string `json:"action" yaml:"action"`
+	// Replacement is what redacted matches are replaced with, defaults to [redacted]
+	Replacement *string `json:"replacement" yaml:"replacement"`
+}
+
+// Blocklist is text the bot must never send, from any feature
+type Blocklist struct {
+	Phrases     []string
+	Patterns    []string
+	Redact      bool
+	Replacement string
+}
+
+// blocklistRule is a compiled phrase or pattern, named after its config key
+type blocklistRule struct {
+	key string
+	re  *regexp.Regexp
+}
+
+// BlocklistPattern compiles a blocklist pattern, which mustn't match empty text
+func BlocklistPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString("") {
+		return nil, errors.New("must not match empty text")
+	}
+	return re, nil
+}
+
+// compileBlocklist compiles the phrases and patterns validated by the config
+func compileBlocklist(b Blocklist) []blocklistRule {
+	var rules []blocklistRule
+	for i, phrase := range b.Phrases {
+		phrase = strings.TrimSpace(phrase)
+		if phrase == "" {
+			continue
+		}
+		expr := regexp.QuoteMeta(phrase)
+		if isWordByte(phrase[0]) {
+			expr = `\b` + expr
+		}
+		if isWordByte(phrase[len(phrase)-1]) {
+			expr += `\b`
+		}
+		rules = append(rules, blocklistRule{key: fmt.Sprintf("phrases[%d]", i), re: regexp.MustCompile(`(?i)` + expr)})
+	}
+	for i, pattern := range b.Patterns {
+		if re, err := BlocklistPattern(pattern); err == nil {
+			rules = append(rules, blocklistRule{key: fmt.Sprintf("patterns[%d]", i), re: re})
+		}
+	}
+	return rules
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// blocklistTransport keeps blocklisted text out of every call the bot makes. A call
+// carrying some is answered with a blocked_by_blocklist error without reaching Slack,
+// or sent with the text redacted.
+type blocklistTransport struct {
+	base        http.RoundTripper
+	log         *zap.Logger
+	rules       []blocklistRule
+	redact      bool
+	replacement string
+}
+
+func (t *blocklistTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if slices.Contains(shadowReads, method) || req.Body == nil || !slices.Contains(blocklistMediaTypes, mediaType) {
+		return t.base.RoundTrip(req)
+	}
+	raw, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read %s request: %w", method, err)
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+
+	var body []byte
+	var matched []string
+	blocked := !t.redact
+	switch mediaType {
+	case "application/json":
+		// The text may be nested anywhere in a JSON request, so every string in it is checked
+		var redacted bool
+		body, matched, redacted = t.applyJSON(raw)
+		blocked = blocked || !redacted
+	case "multipart/form-data":
+		// Uploads, e.g. chat's file snippets, carry text in the file itself
+		var redacted bool
+		body, matched, redacted, err = t.applyMultipart(raw, params["boundary"])
+		if err != nil {
+			return nil, fmt.Errorf("read %s request: %w", method, err)
+		}
+		blocked = blocked || !redacted
+	default:
+		values, err := requestValues(req)
+		if err != nil {
+			return nil, fmt.Errorf("read %s request: %w", method, err)
+		}
+		for _, key := range blocklistFields {
+			if !values.Has(key) {
+				continue
+			}
+			var text string
+			var keys []string
+			if slices.Contains(blocklistJSONFields, key) {
+				var redacted []byte
+				var ok bool
+				redacted, keys, ok = t.applyJSON([]byte(values.Get(key)))
+				text = string(redacted)
+				blocked = blocked || !ok
+			} else {
+				text, keys = t.apply(values.Get(key))
+			}
+			if len(keys) == 0 {
+				continue
+			}
+			matched = append(matched, keys...)
+			values.Set(key, text)
+		}
+		body = []byte(values.Encode())
+	}
+	if len(matched) == 0 {
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+		return t.base.RoundTrip(req)
+	}
+	if mediaType == "application/x-www-form-urlencoded" {
+		// The query was merged into the values, so it all goes in the body
+		req.URL.RawQuery = ""
+	}
+
+	slices.Sort(matched)
+	t.log.Warn("Outbound text matched the blocklist",
+		zap.String("method", method),
+		zap.Strings("rules", slices.Compact(matched)),
+		zap.Bool("blocked", blocked))
+	if blocked {
+		return shadowResponse(req, `{"ok":false,"error":"`+blockedError+`"}`), nil
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return t.base.RoundTrip(req)
+}
+
+// apply replaces the blocklisted text with the replacement, returning the keys of the
+// rules that matched
+func (t *blocklistTransport) apply(text string) (string, []string) {
+	var keys []string
+	for _, rule := range t.rules {
+		if !rule.re.MatchString(text) {
+			continue
+		}
+		keys = append(keys, rule.key)
+		text = rule.re.ReplaceAllLiteralString(text, t.replacement)
+	}
+	return text, keys
+}
+
+// applyJSON redacts the blocklisted text in a JSON document's string values, leaving its
+// keys and structure alone, and reports false when a match can't be redacted because the
+// document doesn't parse
+func (t *blocklistTransport) applyJSON(data []byte) ([]byte, []string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		_, keys := t.apply(string(data))
+		return data, keys, len(keys) == 0
+	}
+	var keys []string
+	doc = t.applyValue(doc, &keys)
+	if len(keys) == 0 {
+		return data, nil, true
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return data, keys, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), keys, true
+}
+
+// applyValue redacts the strings in a decoded JSON value
+func (t *blocklistTransport) applyValue(v any, keys *[]string) any {
+	switch v := v.(type) {
+	case string:
+		text, matched := t.apply(v)
+		*keys = append(*keys, matched...)
+		return text
+	case []any:
+		for i := range v {
+			v[i] = t.applyValue(v[i], keys)
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = t.applyValue(v[key], keys)
+		}
+	}
+	return v
+}
+
+// applyMultipart redacts the blocklisted text in a multipart form's text fields and
+// uploaded files, reporting false when a match is in a binary file that can't be redacted
+func (t *blocklistTransport) applyMultipart(data []byte, boundary string) ([]byte, []string, bool, error) {
+	if boundary == "" {
+		return nil, nil, false, errors.New("multipart request without a boundary")
+	}
+	reader := multipart.NewReader(bytes.NewReader(data), boundary)
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return nil, nil, false, err
+	}
+	var matched []string
+	redacted := true
+	for {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		var keys []string
+		switch {
+		case part.FileName() != "":
+			var text string
+			text, keys = t.apply(string(content))
+			if len(keys) > 0 && !utf8.Valid(content) {
+				redacted = false
+			} else {
+				content = []byte(text)
+			}
+		case slices.Contains(blocklistJSONFields, part.FormName()):
+			var ok bool
+			content, keys, ok = t.applyJSON(content)
+			redacted = redacted && ok
+		case slices.Contains(blocklistFields, part.FormName()):
+			var text string
+			text, keys = t.apply(string(content))
+			content = []byte(text)
+		}
+		matched = append(matched, keys...)
+		w, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, nil, false, err
+	}
+	return buf.Bytes(), matched, redacted, nil
+}
@@ -0,0 +1,104 @@
+package slack
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+)
+
+// circuitBreaker tracks consecutive Slack API failures. It opens after threshold
+// failures and closes on the first success once the cooldown has elapsed. Requests
+// are never blocked; features consult Available to pause non-essential posting.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	onChange  func(open bool, openedAt time.Time)
+
+	mu          sync.Mutex
+	failures    int
+	open        bool
+	openedAt    time.Time
+	lastFailure time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, onChange func(bool, time.Time)) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		onChange:  onChange,
+	}
+}
+
+// Open reports whether the breaker is open
+func (b *circuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+// record updates the breaker with the outcome of a request
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	changed := false
+	now := b.now()
+
+	switch {
+	case failed:
+		b.failures++
+		b.lastFailure = now
+		if !b.open && b.failures >= b.threshold {
+			b.open = true
+			b.openedAt = now
+			changed = true
+		}
+	case b.open:
+		// Stay open until Slack has been healthy for a full cooldown
+		if now.Sub(b.lastFailure) >= b.cooldown {
+			b.open = false
+			b.failures = 0
+			changed = true
+		}
+	default:
+		b.failures = 0
+	}
+
+	open := b.open
+	openedAt := b.openedAt
+	b.mu.Unlock()
+
+	if changed && b.onChange != nil {
+		b.onChange(open, openedAt)
+	}
+}
+
+// breakerTransport reports Slack API network errors and 5xx responses to the breaker
+type breakerTransport struct {
+	base    http.RoundTripper
+	breaker *circuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		// Canceled requests say nothing about Slack's health
+		if req.Context().Err() == nil {
+			t.breaker.record(true)
+		}
+		return resp, err
+	}
+	t.breaker.record(resp.StatusCode >= http.StatusInternalServerError)
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+)
+
+// ErrDMUnavailable is returned when a user can't receive direct messages from the bot,
+// e.g. they disabled the app's messages tab, deactivated their account, or are a bot
+var ErrDMUnavailable = errors.New("user can't receive direct messages")
+
+// dmUnavailableCodes are Slack error codes meaning the user can't be messaged
+var dmUnavailableCodes = []string{
+	"cannot_dm_bot",
+	"messages_tab_disabled",
+	"user_disabled",
+	"user_not_found",
+	"user_not_visible",
+	"account_inactive",
+}
+
+// OpenDM returns the IM channel ID for a user, calling conversations.open on first use
+func (s *Slack) OpenDM(ctx context.Context, userID string) (string, error) {
+	s.dmMu.Lock()
+	channelID, ok := s.dmChannels[userID]
+	s.dmMu.Unlock()
+	if ok {
+		return channelID, nil
+	}
+
+	channel, _, _, err := s.client.OpenConversationContext(ctx, &slack.OpenConversationParameters{
+		Users:    []string{userID},
+		ReturnIM: true,
+	})
+	if err != nil {
+		return "", dmError("conversations.open", userID, err)
+	}
+
+	s.dmMu.Lock()
+	if s.dmChannels == nil {
+		s.dmChannels = make(map[string]string)
+	}
+	s.dmChannels[userID] = channel.ID
+	s.dmMu.Unlock()
+	return channel.ID, nil
+}
+
+// PostDM sends a direct message to a user and returns the message timestamp. Errors for
+// users who can't receive DMs wrap ErrDMUnavailable. While the user has Do Not Disturb
+// on, DMs of a kind that isn't urgent are held until it ends and the timestamp is empty.
+func (s *Slack) PostDM(ctx context.Context, userID, kind string, opts ...slack.MsgOption) (string, error) {
+	if until, ok := s.dndUntil(ctx, userID, kind); ok {
+		s.holdDM(heldDM{userID: userID, kind: kind, opts: opts, until: until})
+		s.log.Info("Holding DM until Do Not Disturb ends",
+			zap.String("user", userID),
+			zap.String("kind", kind),
+			zap.Time("until", until))
+		return "", nil
+	}
+	return s.postDM(ctx, userID, opts...)
+}
+
+func (s *Slack) postDM(ctx context.Context, userID string, opts ...slack.MsgOption) (string, error) {
+	channelID, err := s.OpenDM(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, ts, err := s.client.PostMessageContext(ctx, channelID, opts...)
+	if err != nil && errorCode(err) == "channel_not_found" {
+		// The cached IM channel is stale, e.g. the conversation was closed; reopen it once
+		s.forgetDM(userID)
+		if channelID, err = s.OpenDM(ctx, userID); err != nil {
+			return "", err
+		}
+		_, ts, err = s.client.PostMessageContext(ctx, channelID, opts...)
+	}
+	if err != nil {
+		return "", dmError("chat.postMessage", userID, err)
+	}
+	return ts, nil
+}
+
+func (s *Slack) forgetDM(userID string) {
+	s.dmMu.Lock()
+	delete(s.dmChannels, userID)
+	s.dmMu.Unlock()
+}
+
+// dmError wraps a Slack API failure, marking users who can't receive DMs
+func dmError(op, userID string, err error) error {
+	apiErr := errs.NewSlackAPIError(op, err)
+	if slices.Contains(dmUnavailableCodes, apiErr.Code) {
+		return fmt.Errorf("DM user %s: %w: %w", userID, ErrDMUnavailable, apiErr)
+	}
+	return fmt.Errorf("DM user %s: %w", userID, apiErr)
+}
+
+func errorCode(err error) string {
+	return errs.NewSlackAPIError("", err).Code
+}
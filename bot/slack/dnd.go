@@ -0,0 +1,107 @@
+package slack
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+// heldDMInterval is how often held DMs are checked for delivery
+const heldDMInterval = time.Minute
+
+// heldDM is a direct message waiting for the recipient's Do Not Disturb to end
+type heldDM struct {
+	userID string
+	kind   string
+	opts   []slack.MsgOption
+	until  time.Time
+}
+
+// dndUntil reports when the user's Do Not Disturb ends if a DM of kind should wait for
+// it. DMs are sent right away when the status can't be read, e.g. without dnd:read.
+func (s *Slack) dndUntil(ctx context.Context, userID, kind string) (time.Time, bool) {
+	if !s.config.HoldDMsDuringDND || slices.Contains(s.config.UrgentDMKinds, kind) {
+		return time.Time{}, false
+	}
+	status, err := s.client.GetDNDInfoContext(ctx, &userID)
+	if err != nil {
+		s.log.Debug("Failed to read Do Not Disturb status, sending DM now",
+			zap.String("user", userID),
+			zap.Error(err))
+		return time.Time{}, false
+	}
+	return dndEnd(status, time.Now())
+}
+
+// dndEnd returns when an active snooze or scheduled Do Not Disturb window ends
+func dndEnd(status *slack.DNDStatus, now time.Time) (time.Time, bool) {
+	var end time.Time
+	if status.SnoozeEnabled && status.SnoozeEndTime > 0 {
+		if t := time.Unix(int64(status.SnoozeEndTime), 0); t.After(now) {
+			end = t
+		}
+	}
+	if status.Enabled && status.NextStartTimestamp > 0 && status.NextEndTimestamp > 0 {
+		start := time.Unix(int64(status.NextStartTimestamp), 0)
+		stop := time.Unix(int64(status.NextEndTimestamp), 0)
+		if !now.Before(start) && now.Before(stop) && stop.After(end) {
+			end = stop
+		}
+	}
+	return end, !end.IsZero()
+}
+
+func (s *Slack) holdDM(dm heldDM) {
+	s.dmMu.Lock()
+	s.heldDMs = append(s.heldDMs, dm)
+	s.dmMu.Unlock()
+}
+
+// deliverHeldDMs sends held DMs once Do Not Disturb ends until ctx is cancelled. DMs
+// still held then are dropped.
+func (s *Slack) deliverHeldDMs(ctx context.Context) {
+	ticker := time.NewTicker(heldDMInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.dmMu.Lock()
+			dropped := len(s.heldDMs)
+			s.heldDMs = nil
+			s.dmMu.Unlock()
+			if dropped > 0 {
+				s.log.Warn("Dropping DMs held for Do Not Disturb on shutdown", zap.Int("count", dropped))
+			}
+			return
+		case <-ticker.C:
+			s.sendDueDMs(ctx, time.Now())
+		}
+	}
+}
+
+// sendDueDMs sends held DMs whose Do Not Disturb window has passed. A DM is held again
+// if the user snoozed notifications since.
+func (s *Slack) sendDueDMs(ctx context.Context, now time.Time) {
+	s.dmMu.Lock()
+	var due []heldDM
+	s.heldDMs = slices.DeleteFunc(s.heldDMs, func(dm heldDM) bool {
+		if dm.until.After(now) {
+			return false
+		}
+		due = append(due, dm)
+		return true
+	})
+	s.dmMu.Unlock()
+
+	for _, dm := range due {
+		if _, err := s.PostDM(ctx, dm.userID, dm.kind, dm.opts...); err != nil {
+			s.log.Error("Failed to send held DM",
+				zap.String("user", dm.userID),
+				zap.String("kind", dm.kind),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/errs"
+)
+
+// ErrMessageNotFound is returned when no message exists at the requested timestamp
+var ErrMessageNotFound = errors.New("message not found")
+
+// maxContextMessages caps the surrounding messages fetched on each side of a message
+const maxContextMessages = 100
+
+// Permalink returns the permanent URL for a message
+func (s *Slack) Permalink(ctx context.Context, channelID, ts string) (string, error) {
+	link, err := s.client.GetPermalinkContext(ctx, &slack.PermalinkParameters{
+		Channel: channelID,
+		Ts:      ts,
+	})
+	if err != nil {
+		return "", errs.NewSlackAPIError("chat.getPermalink", err)
+	}
+	return link, nil
+}
+
+// Message fetches a single message by timestamp. Thread replies aren't returned by
+// conversations.history, so they are looked up with conversations.replies.
+func (s *Slack) Message(ctx context.Context, channelID, ts string) (slack.Message, error) {
+	history, err := s.client.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Latest:    ts,
+		Oldest:    ts,
+		Inclusive: true,
+		Limit:     1,
+	})
+	if err != nil {
+		return slack.Message{}, errs.NewSlackAPIError("conversations.history", err)
+	}
+	if i := slices.IndexFunc(history.Messages, func(m slack.Message) bool { return m.Timestamp == ts }); i >= 0 {
+		return history.Messages[i], nil
+	}
+
+	replies, _, _, err := s.client.GetConversationRepliesContext(ctx, &slack.GetConversationRepliesParameters{
+		ChannelID: channelID,
+		Timestamp: ts,
+		Latest:    ts,
+		Oldest:    ts,
+		Inclusive: true,
+		Limit:     1,
+	})
+	if err != nil {
+		if errorCode(err) == "thread_not_found" {
+			return slack.Message{}, fmt.Errorf("%s in %s: %w", ts, channelID, ErrMessageNotFound)
+		}
+		return slack.Message{}, errs.NewSlackAPIError("conversations.replies", err)
+	}
+	if i := slices.IndexFunc(replies, func(m slack.Message) bool { return m.Timestamp == ts }); i >= 0 {
+		return replies[i], nil
+	}
+	return slack.Message{}, fmt.Errorf("%s in %s: %w", ts, channelID, ErrMessageNotFound)
+}
+
+// MessageContext returns up to n channel messages before and after ts, along with the
+// message itself, oldest first. n is capped at 100.
+func (s *Slack) MessageContext(ctx context.Context, channelID, ts string, n int) ([]slack.Message, error) {
+	n = min(max(n, 0), maxContextMessages)
+
+	// Messages up to and including ts, newest first
+	before, err := s.client.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Latest:    ts,
+		Inclusive: true,
+		Limit:     n + 1,
+	})
+	if err != nil {
+		return nil, errs.NewSlackAPIError("conversations.history", err)
+	}
+	if !slices.ContainsFunc(before.Messages, func(m slack.Message) bool { return m.Timestamp == ts }) {
+		return nil, fmt.Errorf("%s in %s: %w", ts, channelID, ErrMessageNotFound)
+	}
+
+	messages := slices.Clone(before.Messages)
+	if n > 0 {
+		// With only oldest set, Slack returns the messages immediately after it
+		after, err := s.client.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
+			ChannelID: channelID,
+			Oldest:    ts,
+			Limit:     n,
+		})
+		if err != nil {
+			return nil, errs.NewSlackAPIError("conversations.history", err)
+		}
+		messages = append(messages, after.Messages...)
+	}
+
+	slices.SortFunc(messages, func(a, b slack.Message) int {
+		return compareTS(a.Timestamp, b.Timestamp)
+	})
+	messages = slices.CompactFunc(messages, func(a, b slack.Message) bool {
+		return a.Timestamp == b.Timestamp
+	})
+
+	// Trim to n on each side in case Slack returned extra messages
+	i := slices.IndexFunc(messages, func(m slack.Message) bool { return m.Timestamp == ts })
+	return messages[max(i-n, 0):min(i+n+1, len(messages))], nil
+}
+
+// compareTS orders Slack timestamps ("seconds.micros"), which sort numerically rather
+// than lexically when the seconds differ in length
+func compareTS(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
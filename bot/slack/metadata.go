@@ -0,0 +1,86 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/event"
+)
+
+// MetadataEventType is the event type of the metadata attached to the bot's messages
+const MetadataEventType = "slackbot_post"
+
+// metadataMethods are the Slack methods that accept message metadata
+var metadataMethods = []string{"chat.postMessage", "chat.update", "chat.scheduleMessage"}
+
+// Metadata is what the bot attaches to the messages it posts
+type Metadata struct {
+	Feature string
+	// CorrelationID is the ID of the Slack event the message responds to, when it does
+	CorrelationID string
+}
+
+// PostedBy returns the metadata the bot attached to a message, reporting whether it has
+// any. Read history with include_all_metadata for messages to carry it.
+func PostedBy(msg slack.Message) (Metadata, bool) {
+	if msg.Metadata.EventType != MetadataEventType {
+		return Metadata{}, false
+	}
+	var m Metadata
+	m.Feature, _ = msg.Metadata.EventPayload["feature"].(string)
+	m.CorrelationID, _ = msg.Metadata.EventPayload["correlation_id"].(string)
+	return m, true
+}
+
+// metadataTransport attaches metadata naming the feature, and the event it responds to,
+// to the messages a feature posts. Metadata the feature set itself is left alone.
+type metadataTransport struct {
+	base    http.RoundTripper
+	feature string
+}
+
+func (t *metadataTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if !slices.Contains(metadataMethods, path.Base(req.URL.Path)) || mediaType != "application/x-www-form-urlencoded" {
+		return t.base.RoundTrip(req)
+	}
+	values, err := requestValues(req)
+	if err != nil {
+		return nil, fmt.Errorf("read %s request: %w", path.Base(req.URL.Path), err)
+	}
+	if values.Get("metadata") == "" {
+		data, err := json.Marshal(newMetadata(req.Context(), t.feature))
+		if err != nil {
+			return nil, err
+		}
+		values.Set("metadata", string(data))
+	}
+
+	// The query was merged into the values, so it all goes in the body
+	body := []byte(values.Encode())
+	req = req.Clone(req.Context())
+	req.URL.RawQuery = ""
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return t.base.RoundTrip(req)
+}
+
+// newMetadata is what a feature attaches to a message it posts with ctx
+func newMetadata(ctx context.Context, feature string) slack.SlackMetadata {
+	payload := map[string]any{"feature": feature}
+	if id := event.CorrelationID(ctx); id != "" {
+		payload["correlation_id"] = id
+	}
+	return slack.SlackMetadata{EventType: MetadataEventType, EventPayload: payload}
+}
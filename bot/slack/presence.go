@@ -0,0 +1,81 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"slackbot.arpa/bot/conversation"
+)
+
+// postingMethods are the API methods whose failures tell whether the bot is still in a channel
+var postingMethods = map[string]bool{
+	"chat.postMessage":             true,
+	"chat.postEphemeral":           true,
+	"chat.update":                  true,
+	"chat.meMessage":               true,
+	"reactions.add":                true,
+	"files.completeUploadExternal": true,
+}
+
+// presenceTransport reports posts that fail because the bot is no longer in the channel,
+// so features can skip the channel instead of failing on every post
+type presenceTransport struct {
+	base     http.RoundTripper
+	presence *conversation.Presence
+}
+
+func (t *presenceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !postingMethods[path.Base(req.URL.Path)] || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	channel := channelParam(req.Header.Get("Content-Type"), body)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || channel == "" {
+		return resp, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	if err != nil {
+		return resp, nil
+	}
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(respBody, &result) == nil && !result.OK {
+		t.presence.Failed(channel, result.Error)
+	}
+	return resp, nil
+}
+
+// channelParam reads the channel a form or JSON API request is for
+func channelParam(contentType string, body []byte) string {
+	var params struct {
+		Channel   string `json:"channel"`
+		ChannelID string `json:"channel_id"`
+	}
+	if strings.HasPrefix(contentType, "application/json") {
+		_ = json.Unmarshal(body, &params)
+	} else if values, err := url.ParseQuery(string(body)); err == nil {
+		params.Channel, params.ChannelID = values.Get("channel"), values.Get("channel_id")
+	}
+	if params.Channel != "" {
+		return params.Channel
+	}
+	return params.ChannelID
+}
@@ -0,0 +1,59 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+)
+
+const (
+	retryAttempts = 4
+	retryMaxDelay = 30 * time.Second
+)
+
+// retryBaseDelay is the wait before the first retry, doubling for each attempt after
+var retryBaseDelay = time.Second
+
+// Retry calls fn until it succeeds, fails with a non-retryable error, or runs out of
+// attempts. Delays back off exponentially, or follow Slack's Retry-After when rate
+// limited. Errors are returned as a SlackAPIError for op, e.g. "reactions.add", unless
+// fn already returned one.
+func (s *Slack) Retry(ctx context.Context, op string, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var apiErr *errs.SlackAPIError
+		if !errors.As(err, &apiErr) {
+			err = errs.NewSlackAPIError(op, err)
+		}
+		if attempt == retryAttempts || !errs.Retryable(err) {
+			return err
+		}
+
+		wait := delay
+		var rateLimited *slack.RateLimitedError
+		if errors.As(err, &rateLimited) {
+			wait = rateLimited.RetryAfter
+		}
+		wait = min(wait, retryMaxDelay)
+		s.log.Warn("Retrying Slack API call",
+			zap.String("op", op),
+			zap.Int("attempt", attempt),
+			zap.Duration("wait", wait),
+			zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return errs.NewSlackAPIError(op, ctx.Err())
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,263 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+)
+
+// ShadowFeatures are the features that can run in shadow mode
+var ShadowFeatures = []string{
+	"aichat", "analytics", "chat", "facts", "feedback", "filescan", "handoff", "lastseen",
+	"membership", "selftest", "showerthought", "topic", "userwatch", "vibecheck",
+}
+
+// shadowReads are the Slack methods a shadowed feature still calls, since they only read.
+// Every other method is recorded instead of sent.
+var shadowReads = []string{
+	"auth.test", "bookmarks.list", "bots.info", "canvases.sections.lookup", "chat.getPermalink",
+	"conversations.history", "conversations.info", "conversations.list", "conversations.members",
+	"conversations.open", "conversations.replies", "dnd.info", "emoji.list", "files.info",
+	"files.list", "pins.list", "reactions.get", "reactions.list", "search.messages", "team.info",
+	"usergroups.list", "usergroups.users.list", "users.conversations", "users.getPresence",
+	"users.info", "users.list", "users.lookupByEmail", "users.profile.get",
+}
+
+// shadowUploadHost receives the file contents of shadowed uploads, which are dropped
+const shadowUploadHost = "shadow.invalid"
+
+// ShadowCall is a Slack call a feature in shadow mode would have made
+type ShadowCall struct {
+	Time     time.Time         `json:"time"`
+	Feature  string            `json:"feature"`
+	Method   string            `json:"method"`
+	Channel  string            `json:"channel,omitempty"`
+	Text     string            `json:"text,omitempty"`
+	ThreadTS string            `json:"thread_ts,omitempty"`
+	Params   map[string]string `json:"params,omitempty"` // Every other argument, without the token
+}
+
+// Feature is the Slack service handed to one feature. In shadow mode the feature still
+// reads from Slack, but the messages, reactions and other changes it would make are
+// logged and recorded in the shadow log instead of sent, so it can be tried against live
+// traffic.
+type Feature struct {
+	*Slack
+	name   string
+	shadow bool
+
+	once   sync.Once
+	client *slack.Client
+}
+
+// For returns the Slack service for a feature, shadowed when the feature is configured
+// for shadow mode
+func (s *Slack) For(feature string) *Feature {
+	return &Feature{Slack: s, name: feature, shadow: slices.Contains(s.config.Shadow, feature)}
+}
+
+// Shadowed reports whether the feature runs in shadow mode
+func (f *Feature) Shadowed() bool {
+	return f.shadow
+}
+
+// Client returns the Slack client, which records writes in shadow mode and attaches
+// metadata to messages when that's enabled
+func (f *Feature) Client() *slack.Client {
+	if !f.shadow && !f.config.MessageMetadata {
+		return f.Slack.Client()
+	}
+	f.once.Do(func() {
+		transport := f.transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		if f.shadow {
+			transport = &shadowTransport{base: transport, record: f.record}
+		}
+		if f.config.MessageMetadata {
+			transport = &metadataTransport{base: transport, feature: f.name}
+		}
+		opts := append(slices.Clone(f.clientOpts), slack.OptionHTTPClient(&http.Client{Transport: transport}))
+		f.client = slack.New(f.config.Token, opts...)
+	})
+	return f.client
+}
+
+// PostDM records the direct message in shadow mode rather than sending it
+func (f *Feature) PostDM(ctx context.Context, userID, kind string, opts ...slack.MsgOption) (string, error) {
+	if f.config.MessageMetadata {
+		opts = append(opts, slack.MsgOptionMetadata(newMetadata(ctx, f.name)))
+	}
+	if !f.shadow {
+		return f.Slack.PostDM(ctx, userID, kind, opts...)
+	}
+	_, values, err := slack.UnsafeApplyMsgOptions("", userID, "", opts...)
+	if err != nil {
+		return "", err
+	}
+	values.Set("dm_kind", kind)
+	f.record("chat.postMessage", values)
+	return shadowTS(), nil
+}
+
+// record logs a shadowed call and appends it to the shadow log
+func (f *Feature) record(method string, values url.Values) {
+	call := ShadowCall{Time: time.Now(), Feature: f.name, Method: method, Params: map[string]string{}}
+	for key := range values {
+		switch key {
+		case "token":
+		case "channel", "channel_id":
+			call.Channel = values.Get(key)
+		case "text":
+			call.Text = values.Get(key)
+		case "thread_ts":
+			call.ThreadTS = values.Get(key)
+		default:
+			call.Params[key] = values.Get(key)
+		}
+	}
+	f.log.Info("Shadow mode, not sending",
+		zap.String("feature", call.Feature),
+		zap.String("method", call.Method),
+		zap.String("channel", call.Channel),
+		zap.String("thread_ts", call.ThreadTS),
+		zap.String("text", call.Text))
+	if f.config.ShadowLog == "" {
+		return
+	}
+
+	data, err := json.Marshal(call)
+	if err != nil {
+		f.log.Error("Failed to marshal shadow call", zap.Error(err))
+		return
+	}
+	f.shadowMu.Lock()
+	defer f.shadowMu.Unlock()
+	file, err := os.OpenFile(f.config.ShadowLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		f.log.Error("Failed to record shadow call", zap.Error(&errs.StorageError{Op: "open", Path: f.config.ShadowLog, Err: err}))
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		f.log.Error("Failed to record shadow call", zap.Error(&errs.StorageError{Op: "write", Path: f.config.ShadowLog, Err: err}))
+	}
+}
+
+// shadowTransport sends reads to Slack and answers everything else itself, with a
+// response shaped like Slack's so the feature carries on as if it had been sent
+type shadowTransport struct {
+	base   http.RoundTripper
+	record func(method string, values url.Values)
+}
+
+func (t *shadowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == shadowUploadHost {
+		return shadowResponse(req, `OK`), nil
+	}
+	method := path.Base(req.URL.Path)
+	if slices.Contains(shadowReads, method) {
+		return t.base.RoundTrip(req)
+	}
+
+	values, err := requestValues(req)
+	if err != nil {
+		return nil, fmt.Errorf("read shadowed %s request: %w", method, err)
+	}
+	t.record(method, values)
+
+	channel := values.Get("channel")
+	if channel == "" {
+		channel = values.Get("channel_id")
+	}
+	ts := shadowTS()
+	var body any = map[string]any{"ok": true}
+	switch {
+	case method == "files.getUploadURLExternal":
+		body = map[string]any{"ok": true, "upload_url": "https://" + shadowUploadHost + "/upload", "file_id": "FSHADOW"}
+	case method == "files.completeUploadExternal":
+		body = map[string]any{"ok": true, "files": []map[string]string{{"id": "FSHADOW"}}}
+	case method == "canvases.create":
+		body = map[string]any{"ok": true, "canvas_id": "FSHADOW"}
+	case method == "bookmarks.add":
+		body = map[string]any{"ok": true, "bookmark": map[string]string{"id": "BkSHADOW", "channel_id": channel}}
+	case strings.HasPrefix(method, "chat."):
+		body = map[string]any{"ok": true, "channel": channel, "ts": ts, "message_ts": ts, "scheduled_message_id": "QSHADOW"}
+	case strings.HasPrefix(method, "conversations."):
+		body = map[string]any{"ok": true, "channel": map[string]string{"id": channel}}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return shadowResponse(req, string(data)), nil
+}
+
+// requestValues reads the arguments of a Slack API request, sent as a form or as JSON
+func requestValues(req *http.Request) (url.Values, error) {
+	values := req.URL.Query()
+	if req.Body == nil {
+		return values, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range form {
+			values[key] = v
+		}
+	case "application/json":
+		var fields map[string]any
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		for key, v := range fields {
+			if s, ok := v.(string); ok {
+				values.Set(key, s)
+				continue
+			}
+			raw, _ := json.Marshal(v)
+			values.Set(key, string(raw))
+		}
+	}
+	return values, nil
+}
+
+func shadowResponse(req *http.Request, body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Request:    req,
+	}
+}
+
+// shadowTS is a message timestamp for a message that was never sent
+func shadowTS() string {
+	now := time.Now()
+	return fmt.Sprintf("%d.%06d", now.Unix(), now.Nanosecond()/1000)
+}
@@ -2,11 +2,17 @@ package slack
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/slack-go/slack"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/tools/emoji"
 )
 
 type Config struct {
@@ -14,35 +20,85 @@ type Config struct {
 	SigningSecret     string
 	Debug             bool
 	PreferredChannels []string
+	BreakerThreshold  int           // Consecutive API failures before the circuit breaker opens
+	BreakerCooldown   time.Duration // Healthy period required before the breaker closes
+	NotifyChannel     string        // Channel for the recovery notice when the breaker closes
+	RejoinChannels    bool          // Rejoin public channels the bot was removed from instead of skipping them
+	HoldDMsDuringDND  bool          // Hold DMs until the recipient's Do Not Disturb ends
+	UrgentDMKinds     []string      // DM kinds sent even during Do Not Disturb, e.g. memory
+	Shadow            []string      // Features whose writes are recorded instead of sent
+	ShadowLog         string        // JSON lines file shadowed calls are recorded in
+	MessageMetadata   bool          // Attach metadata naming the posting feature to messages
+	Blocklist         Blocklist     // Text no feature may send
+
+	// SignatureTolerance is how far a request timestamp may drift from local time
+	SignatureTolerance time.Duration
 }
 
+// ErrCircuitOpen is reported while Slack API calls are failing repeatedly
+var ErrCircuitOpen = errors.New("slack API circuit breaker open")
+
 type Slack struct {
 	log      *zap.Logger
 	config   Config
 	client   *slack.Client
 	authResp *slack.AuthTestResponse
+	breaker  *circuitBreaker
+	verifier *requestVerifier
+	presence *conversation.Presence
+	emoji    *emoji.Catalog
+
+	// How the client was built, so shadowed features can build their own
+	transport  http.RoundTripper
+	clientOpts []slack.Option
+	shadowMu   sync.Mutex // Serializes writes to the shadow log
+
+	dmMu       sync.Mutex
+	dmChannels map[string]string // userID -> IM channel ID
+	heldDMs    []heldDM          // DMs waiting for Do Not Disturb to end
+
+	usersMu    sync.Mutex
+	users      map[string]cachedUser
+	userList   []slack.User
+	userListAt time.Time
 }
 
 func NewSlack(log *zap.Logger, config Config) *Slack {
 	return &Slack{
-		log:    log,
-		config: config,
+		log:      log,
+		config:   config,
+		verifier: newRequestVerifier(config.SigningSecret, config.SignatureTolerance),
 	}
 }
 
 func (s *Slack) Setup(ctx context.Context) error {
 	if s.config.Token == "" {
-		return fmt.Errorf("no Slack authentication credentials provided")
+		return &errs.ConfigError{Key: "slack-token", Err: errors.New("no Slack authentication credentials provided")}
 	}
 
-	clientOpts := []slack.Option{
-		slack.OptionDebug(s.config.Debug),
+	s.breaker = newCircuitBreaker(s.config.BreakerThreshold, s.config.BreakerCooldown, s.onBreakerChange)
+	s.presence = conversation.NewPresence(s.log.Named("presence"), s, s.config.RejoinChannels)
+	s.transport = &breakerTransport{
+		base:    &presenceTransport{base: http.DefaultTransport, presence: s.presence},
+		breaker: s.breaker,
+	}
+	// Blocked calls never reach Slack, so they don't count against the breaker
+	if rules := compileBlocklist(s.config.Blocklist); len(rules) > 0 {
+		s.transport = &blocklistTransport{
+			base:        s.transport,
+			log:         s.log.Named("blocklist"),
+			rules:       rules,
+			redact:      s.config.Blocklist.Redact,
+			replacement: s.config.Blocklist.Replacement,
+		}
 	}
+	s.clientOpts = []slack.Option{slack.OptionDebug(s.config.Debug)}
 
-	s.client = slack.New(s.config.Token, clientOpts...)
+	s.client = slack.New(s.config.Token, append(s.clientOpts, slack.OptionHTTPClient(&http.Client{Transport: s.transport}))...)
+	s.emoji = emoji.NewCatalog(s.client.GetEmojiContext, time.Hour)
 
 	if resp, err := s.client.AuthTest(); err != nil {
-		return fmt.Errorf("authenticate with Slack: %w", err)
+		return fmt.Errorf("authenticate with Slack: %w", errs.NewSlackAPIError("auth.test", err))
 	} else {
 		s.authResp = resp
 	}
@@ -60,22 +116,45 @@ func (s *Slack) Start(ctx context.Context) error {
 	}
 
 	for _, channel := range s.config.PreferredChannels {
-		_, _, _, err := s.client.JoinConversationContext(ctx, channel)
-		if err != nil {
-			s.log.Error("Failed to join channel", zap.String("channel", channel), zap.Error(err))
-		}
+		s.joinPreferredChannel(ctx, channel)
 	}
 
+	go s.probeWhileOpen(ctx)
+	go s.deliverHeldDMs(ctx)
+
 	return nil
 }
 
+// joinPreferredChannel joins a public channel. The bot can't join private channels or
+// DMs on its own, so it only checks that it was invited to those.
+func (s *Slack) joinPreferredChannel(ctx context.Context, channel string) {
+	kind, info, err := conversation.Lookup(ctx, s.client, channel)
+	if err != nil {
+		s.log.Error("Failed to look up preferred channel", zap.String("channel", channel), zap.Error(err))
+		return
+	}
+	if !kind.CanJoin() {
+		if !info.IsMember && kind != conversation.DM {
+			s.log.Warn("The bot can't join this kind of channel on its own - invite it instead",
+				zap.String("channel", channel),
+				zap.String("kind", string(kind)))
+		}
+		return
+	}
+	if _, _, _, err := s.client.JoinConversationContext(ctx, channel); err != nil {
+		s.log.Error("Failed to join channel",
+			zap.String("channel", channel),
+			zap.Error(conversation.Error("conversations.join", kind, err)))
+	}
+}
+
 func (s *Slack) Stop(ctx context.Context) error {
 	if s.client == nil {
 		return nil // No client to stop
 	}
 
 	if err := s.client.SetUserPresenceContext(ctx, "away"); err != nil {
-		return fmt.Errorf("user presence away: %w", err)
+		return fmt.Errorf("user presence away: %w", errs.NewSlackAPIError("users.setPresence", err))
 	}
 	return nil
 }
@@ -84,24 +163,6 @@ func (s *Slack) Client() *slack.Client {
 	return s.client
 }
 
-// VerifyRequest validates the request body against the Slack signing secret
-func (s *Slack) VerifyRequest(header http.Header, body []byte) error {
-	sv, err := slack.NewSecretsVerifier(header, s.config.SigningSecret)
-	if err != nil {
-		return fmt.Errorf("create secrets verifier: %w", err)
-	}
-
-	if _, err := sv.Write(body); err != nil {
-		return fmt.Errorf("write to secrets verifier: %w", err)
-	}
-
-	if err := sv.Ensure(); err != nil {
-		return fmt.Errorf("verify request signature: %w", err)
-	}
-
-	return nil
-}
-
 func (s *Slack) OrgURL() string {
 	return s.authResp.URL
 }
@@ -113,3 +174,75 @@ func (s *Slack) BotUserID() string {
 	}
 	return ""
 }
+
+// Emoji caches the workspace's custom emoji, which needs the emoji:read scope
+func (s *Slack) Emoji() *emoji.Catalog {
+	return s.emoji
+}
+
+// Presence tracks the channels the bot was removed from. Register it as an event
+// processor to learn about removals before a post fails.
+func (s *Slack) Presence() *conversation.Presence {
+	return s.presence
+}
+
+// Available reports whether non-essential features should post to Slack. It is false
+// while the circuit breaker is open.
+func (s *Slack) Available() bool {
+	return s.breaker == nil || !s.breaker.Open()
+}
+
+// HealthCheck returns a SlackAPIError wrapping ErrCircuitOpen while the circuit breaker is open
+func (s *Slack) HealthCheck() error {
+	if !s.Available() {
+		return errs.NewSlackAPIError("circuit breaker", ErrCircuitOpen)
+	}
+	return nil
+}
+
+// onBreakerChange logs breaker transitions and posts a recovery notice when it closes
+func (s *Slack) onBreakerChange(open bool, openedAt time.Time) {
+	if open {
+		s.log.Warn("Slack API circuit breaker opened, pausing non-essential posting",
+			zap.Int("threshold", s.breaker.threshold),
+			zap.Duration("cooldown", s.breaker.cooldown))
+		return
+	}
+
+	downtime := time.Since(openedAt).Round(time.Second)
+	s.log.Info("Slack API circuit breaker closed", zap.Duration("downtime", downtime))
+	if s.config.NotifyChannel == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		text := fmt.Sprintf(":white_check_mark: Slack API recovered after %s of errors. Resuming normal activity.", downtime)
+		if _, _, err := s.client.PostMessageContext(ctx, s.config.NotifyChannel,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionAsUser(true),
+		); err != nil {
+			s.log.Error("Failed to post Slack recovery notice", zap.Error(err))
+		}
+	}()
+}
+
+// probeWhileOpen periodically calls auth.test while the breaker is open so it can close
+// even when every non-essential feature is paused
+func (s *Slack) probeWhileOpen(ctx context.Context) {
+	ticker := time.NewTicker(s.breaker.cooldown)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Available() {
+				continue
+			}
+			if _, err := s.client.AuthTestContext(ctx); err != nil {
+				s.log.Debug("Slack API probe failed", zap.Error(err))
+			}
+		}
+	}
+}
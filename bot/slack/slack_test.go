@@ -1,11 +1,32 @@
 package slack
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/slack-go/slack"
 	"go.uber.org/zap/zaptest"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/event"
 )
 
 func TestNewSlack(t *testing.T) {
@@ -59,7 +80,10 @@ func TestSlack_Setup_EmptyToken(t *testing.T) {
 		t.Error("Setup() with empty token should return error")
 	}
 
-	expectedError := "no Slack authentication credentials provided"
+	expectedError := "config slack-token: no Slack authentication credentials provided"
+	if errs.Category(err) != errs.CategoryConfig {
+		t.Errorf("Setup() error category = %q, want %q", errs.Category(err), errs.CategoryConfig)
+	}
 	if err.Error() != expectedError {
 		t.Errorf("Setup() error = %v, want %v", err.Error(), expectedError)
 	}
@@ -316,4 +340,742 @@ func BenchmarkSlack_Setup(b *testing.B) {
 		// Note: This will error due to invalid token, but we're measuring performance
 		_ = slack.Setup(ctx)
 	}
-}
\ No newline at end of file
+}
+
+func TestCircuitBreaker_OpensAndCloses(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var changes []bool
+	b := newCircuitBreaker(3, time.Minute, func(open bool, _ time.Time) {
+		changes = append(changes, open)
+	})
+	b.now = func() time.Time { return now }
+
+	b.record(true)
+	b.record(true)
+	b.record(false) // success resets the failure count
+	b.record(true)
+	b.record(true)
+	if b.Open() {
+		t.Fatal("breaker should stay closed below the threshold")
+	}
+
+	b.record(true)
+	if !b.Open() {
+		t.Fatal("breaker should open after consecutive failures")
+	}
+
+	now = now.Add(30 * time.Second)
+	b.record(false)
+	if !b.Open() {
+		t.Error("breaker should stay open until the cooldown has elapsed")
+	}
+
+	now = now.Add(20 * time.Second)
+	b.record(true) // failure during cooldown extends it
+	now = now.Add(50 * time.Second)
+	b.record(false)
+	if !b.Open() {
+		t.Error("breaker should stay open for a full cooldown after the last failure")
+	}
+
+	now = now.Add(10 * time.Second)
+	b.record(false)
+	if b.Open() {
+		t.Error("breaker should close after a healthy cooldown")
+	}
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("expected open then close transitions, got %v", changes)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestBreakerTransport_CountsServerErrors(t *testing.T) {
+	b := newCircuitBreaker(2, time.Minute, nil)
+	status := http.StatusInternalServerError
+	var netErr error
+	transport := &breakerTransport{
+		base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if netErr != nil {
+				return nil, netErr
+			}
+			return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+		}),
+		breaker: b,
+	}
+
+	req := httptest.NewRequest("POST", "https://slack.com/api/chat.postMessage", nil)
+	_, _ = transport.RoundTrip(req)
+	status = http.StatusBadRequest
+	_, _ = transport.RoundTrip(req)
+	if b.Open() {
+		t.Fatal("4xx responses should reset the failure count")
+	}
+
+	netErr = errors.New("connection refused")
+	_, _ = transport.RoundTrip(req)
+	_, _ = transport.RoundTrip(req)
+	if !b.Open() {
+		t.Error("network errors should open the breaker")
+	}
+}
+
+func TestSlack_Available(t *testing.T) {
+	s := NewSlack(zaptest.NewLogger(t), Config{})
+	if !s.Available() || s.HealthCheck() != nil {
+		t.Error("Slack should be available before Setup()")
+	}
+
+	s.breaker = newCircuitBreaker(1, time.Minute, nil)
+	s.breaker.record(true)
+	if s.Available() {
+		t.Error("Slack should be unavailable while the breaker is open")
+	}
+	if !errors.Is(s.HealthCheck(), ErrCircuitOpen) {
+		t.Errorf("HealthCheck() = %v, want %v", s.HealthCheck(), ErrCircuitOpen)
+	}
+}
+
+func TestSlack_PostDM(t *testing.T) {
+	var opens, posts atomic.Int32
+	disabled := "UDISABLED"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/conversations.open":
+			opens.Add(1)
+			if r.Form.Get("users") == disabled {
+				_, _ = w.Write([]byte(`{"ok":false,"error":"messages_tab_disabled"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"channel":{"id":"D123"}}`))
+		case "/chat.postMessage":
+			// The first post reports a stale channel to exercise the reopen path
+			if posts.Add(1) == 1 {
+				_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"channel":"D123","ts":"1.000"}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSlack(zaptest.NewLogger(t), Config{})
+	s.client = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+	ctx := context.Background()
+
+	ts, err := s.PostDM(ctx, "U1", "test", slack.MsgOptionText("hi", false))
+	if err != nil {
+		t.Fatalf("PostDM() error = %v", err)
+	}
+	if ts != "1.000" {
+		t.Errorf("PostDM() ts = %q, want %q", ts, "1.000")
+	}
+	if opens.Load() != 2 {
+		t.Errorf("conversations.open calls = %d, want 2 after a stale channel", opens.Load())
+	}
+
+	if _, err := s.PostDM(ctx, "U1", "test", slack.MsgOptionText("again", false)); err != nil {
+		t.Fatalf("PostDM() error = %v", err)
+	}
+	if opens.Load() != 2 {
+		t.Errorf("conversations.open calls = %d, want the IM channel to be cached", opens.Load())
+	}
+
+	_, err = s.PostDM(ctx, disabled, "test", slack.MsgOptionText("hi", false))
+	if !errors.Is(err, ErrDMUnavailable) {
+		t.Errorf("PostDM() error = %v, want %v", err, ErrDMUnavailable)
+	}
+	var apiErr *errs.SlackAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "messages_tab_disabled" {
+		t.Errorf("PostDM() error = %v, want a SlackAPIError with the Slack code", err)
+	}
+}
+
+func TestSlack_MessageHelpers(t *testing.T) {
+	// Channel messages ts 1-5, plus a thread reply at 3.5
+	channel := []string{"5.000", "4.000", "3.000", "2.000", "1.000"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		latest, oldest := r.Form.Get("latest"), r.Form.Get("oldest")
+		switch r.URL.Path {
+		case "/chat.getPermalink":
+			_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","permalink":"https://example.slack.com/archives/C1/p` + r.Form.Get("message_ts") + `"}`))
+		case "/conversations.history":
+			var msgs []string
+			for _, ts := range channel {
+				if latest != "" && (ts > latest || ts == latest && r.Form.Get("inclusive") != "1") {
+					continue
+				}
+				if oldest != "" && (ts < oldest || ts == oldest && r.Form.Get("inclusive") != "1") {
+					continue
+				}
+				msgs = append(msgs, `{"type":"message","ts":"`+ts+`"}`)
+			}
+			// Newest first like Slack, keeping the messages nearest latest/oldest
+			if oldest != "" && latest == "" {
+				slices.Reverse(msgs)
+			}
+			limit, _ := strconv.Atoi(r.Form.Get("limit"))
+			msgs = msgs[:min(limit, len(msgs))]
+			if oldest != "" && latest == "" {
+				slices.Reverse(msgs)
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"messages":[` + strings.Join(msgs, ",") + `]}`))
+		case "/conversations.replies":
+			if r.Form.Get("ts") != "3.500" {
+				_, _ = w.Write([]byte(`{"ok":false,"error":"thread_not_found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"messages":[{"type":"message","ts":"3.500","thread_ts":"3.000"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSlack(zaptest.NewLogger(t), Config{})
+	s.client = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+	ctx := context.Background()
+
+	link, err := s.Permalink(ctx, "C1", "3.000")
+	if err != nil || !strings.HasSuffix(link, "/p3.000") {
+		t.Errorf("Permalink() = %q, %v", link, err)
+	}
+
+	if msg, err := s.Message(ctx, "C1", "2.000"); err != nil || msg.Timestamp != "2.000" {
+		t.Errorf("Message() = %q, %v, want channel message 2.000", msg.Timestamp, err)
+	}
+	if msg, err := s.Message(ctx, "C1", "3.500"); err != nil || msg.ThreadTimestamp != "3.000" {
+		t.Errorf("Message() = %q, %v, want thread reply 3.500", msg.Timestamp, err)
+	}
+	if _, err := s.Message(ctx, "C1", "9.000"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Message() error = %v, want %v", err, ErrMessageNotFound)
+	}
+
+	msgs, err := s.MessageContext(ctx, "C1", "3.000", 1)
+	if err != nil {
+		t.Fatalf("MessageContext() error = %v", err)
+	}
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.Timestamp)
+	}
+	if want := []string{"2.000", "3.000", "4.000"}; !slices.Equal(got, want) {
+		t.Errorf("MessageContext() = %v, want %v", got, want)
+	}
+	if _, err := s.MessageContext(ctx, "C1", "9.000", 2); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("MessageContext() error = %v, want %v", err, ErrMessageNotFound)
+	}
+}
+
+func TestCompareTS(t *testing.T) {
+	if compareTS("999.000001", "1000.000001") >= 0 {
+		t.Error("shorter timestamps should sort first")
+	}
+	if compareTS("1000.000002", "1000.000001") <= 0 {
+		t.Error("later timestamps should sort last")
+	}
+}
+
+func signRequest(secret string, ts int64, body []byte) http.Header {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "v0:%d:%s", ts, body)
+	header := make(http.Header)
+	header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+	header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return header
+}
+
+func TestSlack_VerifyRequest(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewSlack(zaptest.NewLogger(t), Config{SigningSecret: "secret", SignatureTolerance: 10 * time.Minute})
+	s.verifier.now = func() time.Time { return now }
+	body := []byte(`{"type":"event_callback"}`)
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr error
+	}{
+		{"valid", signRequest("secret", now.Unix(), body), nil},
+		{"replayed", signRequest("secret", now.Unix(), body), ErrReplayedRequest},
+		{"skewed within tolerance", signRequest("secret", now.Add(-8*time.Minute).Unix(), body), nil},
+		{"future within tolerance", signRequest("secret", now.Add(8*time.Minute).Unix(), body), nil},
+		{"stale", signRequest("secret", now.Add(-11*time.Minute).Unix(), body), ErrStaleTimestamp},
+		{"wrong secret", signRequest("other", now.Add(time.Second).Unix(), body), ErrInvalidSignature},
+		{"missing headers", make(http.Header), ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.VerifyRequest(tt.header, body)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("VerifyRequest() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyRequest() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	failures := s.VerificationFailures()
+	for _, reason := range []string{"replayed", "stale_timestamp", "invalid_signature", "missing_headers"} {
+		if failures[reason] != 1 {
+			t.Errorf("VerificationFailures()[%q] = %d, want 1", reason, failures[reason])
+		}
+	}
+
+	// Once the original timestamp leaves the window the signature is forgotten
+	now = now.Add(20 * time.Minute)
+	s.verifier.mu.Lock()
+	s.verifier.prune(now)
+	remaining := len(s.verifier.seen)
+	s.verifier.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("Expected expired signatures to be pruned, %d remain", remaining)
+	}
+}
+
+func TestSlack_Retry(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+	s := NewSlack(zaptest.NewLogger(t), Config{})
+	ctx := context.Background()
+
+	var calls int
+	err := s.Retry(ctx, "reactions.add", func() error {
+		calls++
+		if calls < 3 {
+			return slack.StatusCodeError{Code: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Retry() = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = s.Retry(ctx, "reactions.add", func() error {
+		calls++
+		return slack.SlackErrorResponse{Err: "already_reacted"}
+	})
+	var apiErr *errs.SlackAPIError
+	if calls != 1 || !errors.As(err, &apiErr) || apiErr.Code != "already_reacted" {
+		t.Errorf("Retry() = %v after %d calls, want one attempt for a non-retryable error", err, calls)
+	}
+
+	calls = 0
+	err = s.Retry(ctx, "pins.add", func() error {
+		calls++
+		return &slack.RateLimitedError{RetryAfter: time.Millisecond}
+	})
+	if calls != retryAttempts || !errs.Retryable(err) {
+		t.Errorf("Retry() = %v after %d calls, want %d attempts", err, calls, retryAttempts)
+	}
+}
+
+func TestSlack_UserCache(t *testing.T) {
+	var infoCalls, listCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users.info":
+			infoCalls.Add(1)
+			_, _ = w.Write([]byte(`{"ok":true,"user":{"id":"` + r.Form.Get("user") + `","name":"alice"}}`))
+		case "/users.list":
+			listCalls.Add(1)
+			_, _ = w.Write([]byte(`{"ok":true,"members":[
+				{"id":"U1","name":"alice","profile":{"display_name":"Al","real_name":"Alice Smith"}},
+				{"id":"U2","name":"bob","profile":{"display_name":"Alice Smith"}},
+				{"id":"U3","name":"carol"}
+			]}`))
+		case "/users.conversations":
+			if r.Form.Get("user") == "" {
+				_, _ = w.Write([]byte(`{"ok":true,"channels":[{"id":"C1","name":"general"},{"id":"C2","name":"bots"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"channels":[{"id":"C1","name":"general"},{"id":"C3","name":"private"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSlack(zaptest.NewLogger(t), Config{})
+	s.client = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+	ctx := context.Background()
+
+	for range 2 {
+		if u, err := s.User(ctx, "U9"); err != nil || u.ID != "U9" {
+			t.Fatalf("User() = %+v, %v", u, err)
+		}
+	}
+	if infoCalls.Load() != 1 {
+		t.Errorf("users.info calls = %d, want 1 with caching", infoCalls.Load())
+	}
+
+	matches, err := s.FindUsers(ctx, "alice smith")
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("FindUsers() = %v, %v, want both users named Alice Smith", matches, err)
+	}
+	if matches, _ := s.FindUsers(ctx, "@carol"); len(matches) != 1 || matches[0].ID != "U3" {
+		t.Errorf("FindUsers(@carol) = %v", matches)
+	}
+	if listCalls.Load() != 1 {
+		t.Errorf("users.list calls = %d, want 1 with caching", listCalls.Load())
+	}
+	if _, err := s.User(ctx, "U2"); err != nil || infoCalls.Load() != 1 {
+		t.Errorf("User() should be served from the cached listing, users.info calls = %d", infoCalls.Load())
+	}
+
+	shared, err := s.SharedChannels(ctx, "U1")
+	if err != nil || len(shared) != 1 || shared[0].ID != "C1" {
+		t.Errorf("SharedChannels() = %v, %v, want only C1", shared, err)
+	}
+}
+
+func TestPresenceTransport_MarksRemovedChannels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/chat.postMessage" {
+			_, _ = w.Write([]byte(`{"ok": false, "error": "not_in_channel"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": false, "error": "ratelimited"}`))
+	}))
+	defer srv.Close()
+
+	presence := conversation.NewPresence(zaptest.NewLogger(t), &Slack{}, false)
+	client := slack.New("xoxb-test",
+		slack.OptionAPIURL(srv.URL+"/"),
+		slack.OptionHTTPClient(&http.Client{Transport: &presenceTransport{base: http.DefaultTransport, presence: presence}}),
+	)
+
+	_, _, err := client.PostMessage("C1", slack.MsgOptionText("hi", false))
+	if err == nil || !strings.Contains(err.Error(), "not_in_channel") {
+		t.Fatalf("The client should still see the API error, got %v", err)
+	}
+	_ = client.AddReaction("wave", slack.NewRefToMessage("C2", "1.0"))
+
+	removed := presence.Removed()
+	if len(removed) != 1 || removed["C1"] != "not_in_channel" {
+		t.Errorf("Removed = %v, want only C1", removed)
+	}
+	if presence.Present(context.Background(), "C1") {
+		t.Error("The bot should not be present in C1")
+	}
+}
+
+func TestDNDEnd(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		name   string
+		status slack.DNDStatus
+		want   int64
+	}{
+		{"off", slack.DNDStatus{}, 0},
+		{"inside schedule", slack.DNDStatus{Enabled: true, NextStartTimestamp: 900, NextEndTimestamp: 1500}, 1500},
+		{"before schedule", slack.DNDStatus{Enabled: true, NextStartTimestamp: 1100, NextEndTimestamp: 1500}, 0},
+		{"snoozed", slack.DNDStatus{SnoozeInfo: slack.SnoozeInfo{SnoozeEnabled: true, SnoozeEndTime: 1200}}, 1200},
+		{"snooze past schedule", slack.DNDStatus{Enabled: true, NextStartTimestamp: 900, NextEndTimestamp: 1100, SnoozeInfo: slack.SnoozeInfo{SnoozeEnabled: true, SnoozeEndTime: 1200}}, 1200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, ok := dndEnd(&tt.status, now)
+			if ok != (tt.want != 0) || (ok && end.Unix() != tt.want) {
+				t.Errorf("dndEnd() = %v, %v, want %d", end, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlack_PostDM_HeldDuringDND(t *testing.T) {
+	var posts atomic.Int32
+	var snoozeEnd atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/dnd.info":
+			_, _ = fmt.Fprintf(w, `{"ok":true,"snooze_enabled":true,"snooze_endtime":%d}`, snoozeEnd.Load())
+		case "/conversations.open":
+			_, _ = w.Write([]byte(`{"ok":true,"channel":{"id":"D123"}}`))
+		case "/chat.postMessage":
+			posts.Add(1)
+			_, _ = w.Write([]byte(`{"ok":true,"channel":"D123","ts":"1.000"}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSlack(zaptest.NewLogger(t), Config{HoldDMsDuringDND: true, UrgentDMKinds: []string{"urgent"}})
+	s.client = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+	ctx := context.Background()
+	snoozeEnd.Store(time.Now().Add(time.Hour).Unix())
+
+	if ts, err := s.PostDM(ctx, "U1", "reminder", slack.MsgOptionText("hi", false)); err != nil || ts != "" {
+		t.Fatalf("PostDM() = %q, %v, want the DM held", ts, err)
+	}
+	if _, err := s.PostDM(ctx, "U1", "urgent", slack.MsgOptionText("now", false)); err != nil {
+		t.Fatalf("PostDM() error = %v", err)
+	}
+	if posts.Load() != 1 {
+		t.Fatalf("posts = %d, want only the urgent DM sent", posts.Load())
+	}
+
+	s.sendDueDMs(ctx, time.Now())
+	if posts.Load() != 1 || len(s.heldDMs) != 1 {
+		t.Fatalf("posts = %d with %d held, want the DM held until DND ends", posts.Load(), len(s.heldDMs))
+	}
+
+	snoozeEnd.Store(0)
+	s.sendDueDMs(ctx, time.Now().Add(2*time.Hour))
+	if posts.Load() != 2 || len(s.heldDMs) != 0 {
+		t.Errorf("posts = %d with %d held, want the held DM sent after DND", posts.Load(), len(s.heldDMs))
+	}
+}
+
+func TestFeature_Shadow(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"user":{"id":"U1","name":"alice"}}`))
+	}))
+	defer srv.Close()
+
+	shadowLog := filepath.Join(t.TempDir(), "shadow.jsonl")
+	s := NewSlack(zaptest.NewLogger(t), Config{Token: "xoxb-test", Shadow: []string{"aichat"}, ShadowLog: shadowLog})
+	s.clientOpts = []slack.Option{slack.OptionAPIURL(srv.URL + "/")}
+	s.client = slack.New("xoxb-test", s.clientOpts...)
+	ctx := context.Background()
+
+	live := s.For("chat")
+	if live.Shadowed() || live.Client() != s.client {
+		t.Error("a feature not in shadow mode should use the live client")
+	}
+
+	f := s.For("aichat")
+	if !f.Shadowed() {
+		t.Fatal("Shadowed() = false for a configured feature")
+	}
+	// Reads still reach Slack
+	if user, err := f.Client().GetUserInfoContext(ctx, "U1"); err != nil || user.Name != "alice" {
+		t.Fatalf("GetUserInfoContext() = %+v, %v, want the read sent", user, err)
+	}
+	channel, ts, err := f.Client().PostMessageContext(ctx, "C1", slack.MsgOptionText("hello", false), slack.MsgOptionTS("1.1"))
+	if err != nil || channel != "C1" || ts == "" {
+		t.Fatalf("PostMessageContext() = %q, %q, %v, want a response like Slack's", channel, ts, err)
+	}
+	if _, err := f.Client().InviteUsersToConversationContext(ctx, "C1", "U2"); err != nil {
+		t.Fatalf("InviteUsersToConversationContext() error = %v", err)
+	}
+	if _, err := f.PostDM(ctx, "U2", "memory", slack.MsgOptionText("psst", false)); err != nil {
+		t.Fatalf("PostDM() error = %v", err)
+	}
+	if !slices.Equal(sent, []string{"/users.info"}) {
+		t.Errorf("sent = %v, want only the read sent to Slack", sent)
+	}
+
+	data, err := os.ReadFile(shadowLog)
+	if err != nil {
+		t.Fatalf("read shadow log: %v", err)
+	}
+	var calls []ShadowCall
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var call ShadowCall
+		if err := json.Unmarshal([]byte(line), &call); err != nil {
+			t.Fatalf("unmarshal shadow call: %v", err)
+		}
+		calls = append(calls, call)
+	}
+	if len(calls) != 3 {
+		t.Fatalf("shadow log = %s, want three calls", data)
+	}
+	if c := calls[0]; c.Feature != "aichat" || c.Method != "chat.postMessage" || c.Channel != "C1" || c.Text != "hello" || c.ThreadTS != "1.1" {
+		t.Errorf("shadow call = %+v, want the message that would have been posted", c)
+	}
+	if _, ok := calls[0].Params["token"]; ok {
+		t.Error("shadow log recorded the token")
+	}
+	if c := calls[1]; c.Method != "conversations.invite" || c.Params["users"] != "U2" {
+		t.Errorf("shadow call = %+v, want the invite", c)
+	}
+	if c := calls[2]; c.Channel != "U2" || c.Text != "psst" || c.Params["dm_kind"] != "memory" {
+		t.Errorf("shadow call = %+v, want the DM", c)
+	}
+}
+
+func TestFeature_MessageMetadata(t *testing.T) {
+	var metadata []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		metadata = append(metadata, r.PostForm.Get("metadata"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.1"}`))
+	}))
+	defer srv.Close()
+
+	s := NewSlack(zaptest.NewLogger(t), Config{Token: "xoxb-test", MessageMetadata: true})
+	s.clientOpts = []slack.Option{slack.OptionAPIURL(srv.URL + "/")}
+	s.client = slack.New("xoxb-test", s.clientOpts...)
+	ctx := event.WithCorrelationID(context.Background(), "Ev1")
+
+	f := s.For("aichat")
+	if _, _, err := f.Client().PostMessageContext(ctx, "C1", slack.MsgOptionText("hello", false)); err != nil {
+		t.Fatalf("PostMessageContext() error = %v", err)
+	}
+	// Metadata the feature set itself is kept
+	own := slack.SlackMetadata{EventType: "standup_summary", EventPayload: map[string]any{"team": "web"}}
+	if _, _, err := f.Client().PostMessageContext(ctx, "C1", slack.MsgOptionText("hi", false), slack.MsgOptionMetadata(own)); err != nil {
+		t.Fatalf("PostMessageContext() error = %v", err)
+	}
+	if len(metadata) != 2 {
+		t.Fatalf("metadata = %v, want two posts", metadata)
+	}
+
+	var got slack.SlackMetadata
+	if err := json.Unmarshal([]byte(metadata[0]), &got); err != nil {
+		t.Fatalf("unmarshal metadata %q: %v", metadata[0], err)
+	}
+	m, ok := PostedBy(slack.Message{Msg: slack.Msg{Metadata: got}})
+	if !ok || m.Feature != "aichat" || m.CorrelationID != "Ev1" {
+		t.Errorf("PostedBy() = %+v, %v, want aichat responding to Ev1", m, ok)
+	}
+	if !strings.Contains(metadata[1], "standup_summary") {
+		t.Errorf("metadata = %s, want the feature's own metadata", metadata[1])
+	}
+	if _, ok := PostedBy(slack.Message{}); ok {
+		t.Error("PostedBy() = true for a message without metadata")
+	}
+}
+
+func TestBlocklistTransport(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		sent = append(sent, r.PostForm.Get("text"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.1"}`))
+	}))
+	defer srv.Close()
+
+	blocklist := Blocklist{Phrases: []string{"Project Falcon", "bob"}, Patterns: []string{`xox[bp]-[0-9A-Za-z-]+`}, Replacement: "[redacted]"}
+	client := func(redact bool) *slack.Client {
+		transport := &blocklistTransport{base: http.DefaultTransport, log: zaptest.NewLogger(t), rules: compileBlocklist(blocklist), redact: redact, replacement: "[redacted]"}
+		return slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"), slack.OptionHTTPClient(&http.Client{Transport: transport}))
+	}
+
+	blocking := client(false)
+	if _, _, err := blocking.PostMessage("C1", slack.MsgOptionText("bobcat sightings are up", false)); err != nil {
+		t.Fatalf("PostMessage() error = %v, want a phrase inside a word allowed", err)
+	}
+	if _, _, err := blocking.PostMessage("C1", slack.MsgOptionText("the project falcon launch", false)); err == nil || err.Error() != blockedError {
+		t.Errorf("PostMessage() error = %v, want %s", err, blockedError)
+	}
+	if _, _, err := blocking.PostMessage("C1", slack.MsgOptionBlocks(slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "Ask Bob", false, false), nil, nil))); err == nil {
+		t.Error("PostMessage() with blocks = nil error, want the blocks checked too")
+	}
+	if len(sent) != 1 {
+		t.Errorf("sent = %q, want only the allowed message sent", sent)
+	}
+
+	sent = nil
+	if _, _, err := client(true).PostMessage("C1", slack.MsgOptionText("token xoxb-123-abc, ask Bob", false)); err != nil {
+		t.Fatalf("PostMessage() error = %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "token [redacted], ask [redacted]" {
+		t.Errorf("sent = %q, want the matches redacted", sent)
+	}
+}
+
+func TestBlocklistTransport_Bodies(t *testing.T) {
+	var sent [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		sent = append(sent, data)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	blocklist := Blocklist{Phrases: []string{"text", "Project Falcon"}}
+	client := func(redact bool) *http.Client {
+		return &http.Client{Transport: &blocklistTransport{base: http.DefaultTransport, log: zaptest.NewLogger(t), rules: compileBlocklist(blocklist), redact: redact, replacement: "[redacted]"}}
+	}
+	blocked := func(resp *http.Response) bool {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return strings.Contains(string(data), blockedError)
+	}
+
+	upload := func(content string) (*bytes.Buffer, string) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		_ = writer.WriteField("filename", "notes.txt")
+		part, _ := writer.CreateFormFile("file", "notes.txt")
+		_, _ = part.Write([]byte(content))
+		_ = writer.Close()
+		return &buf, writer.FormDataContentType()
+	}
+
+	t.Run("json keys", func(t *testing.T) {
+		sent = nil
+		body := `{"channel":"C1","text":"all clear","blocks":[{"type":"section","text":{"type":"mrkdwn","text":"the project falcon launch"}}]}`
+		resp, err := client(false).Post(srv.URL+"/chat.postMessage", "application/json", strings.NewReader(`{"channel":"C1","text":"all clear"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blocked(resp) || len(sent) != 1 {
+			t.Errorf("sent = %q, want a phrase matching only a key allowed", sent)
+		}
+
+		resp, err = client(true).Post(srv.URL+"/chat.postMessage", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		want := `{"blocks":[{"text":{"text":"the [redacted] launch","type":"mrkdwn"},"type":"section"}],"channel":"C1","text":"all clear"}`
+		if len(sent) != 2 || string(sent[1]) != want {
+			t.Errorf("sent = %q, want only the string values redacted", sent)
+		}
+	})
+
+	t.Run("upload blocked", func(t *testing.T) {
+		sent = nil
+		body, contentType := upload("notes on the Project Falcon launch")
+		resp, err := client(false).Post(srv.URL+"/upload/v1/abc", contentType, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !blocked(resp) || len(sent) != 0 {
+			t.Errorf("sent = %q, want the upload blocked", sent)
+		}
+	})
+
+	t.Run("upload redacted", func(t *testing.T) {
+		sent = nil
+		body, contentType := upload("notes on the Project Falcon launch")
+		resp, err := client(true).Post(srv.URL+"/upload/v1/abc", contentType, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if len(sent) != 1 || !strings.Contains(string(sent[0]), "notes on the [redacted] launch") ||
+			strings.Contains(string(sent[0]), "Falcon") || !strings.Contains(string(sent[0]), `filename="notes.txt"`) {
+			t.Errorf("sent = %q, want the file content redacted", sent)
+		}
+	})
+
+	t.Run("binary upload", func(t *testing.T) {
+		sent = nil
+		body, contentType := upload("\xff\xfeProject Falcon")
+		resp, err := client(true).Post(srv.URL+"/upload/v1/abc", contentType, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !blocked(resp) || len(sent) != 0 {
+			t.Errorf("sent = %q, want a binary upload that can't be redacted blocked", sent)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/errs"
+)
+
+// userCacheTTL is how long looked-up users and the workspace user list are reused
+const userCacheTTL = 10 * time.Minute
+
+type cachedUser struct {
+	user    slack.User
+	fetched time.Time
+}
+
+// User returns a user by ID from the shared cache, calling users.info when it's missing
+// or stale
+func (s *Slack) User(ctx context.Context, userID string) (slack.User, error) {
+	s.usersMu.Lock()
+	cached, ok := s.users[userID]
+	s.usersMu.Unlock()
+	if ok && time.Since(cached.fetched) < userCacheTTL {
+		return cached.user, nil
+	}
+
+	user, err := s.client.GetUserInfoContext(ctx, userID)
+	if err != nil {
+		return slack.User{}, errs.NewSlackAPIError("users.info", err)
+	}
+	s.cacheUsers(*user)
+	return *user, nil
+}
+
+// UserByEmail looks up a user by email address. Requires the users:read.email scope.
+func (s *Slack) UserByEmail(ctx context.Context, email string) (slack.User, error) {
+	user, err := s.client.GetUserByEmailContext(ctx, email)
+	if err != nil {
+		return slack.User{}, errs.NewSlackAPIError("users.lookupByEmail", err)
+	}
+	s.cacheUsers(*user)
+	return *user, nil
+}
+
+// FindUsers returns users whose handle, display name or real name matches name
+// case-insensitively, ignoring a leading @
+func (s *Slack) FindUsers(ctx context.Context, name string) ([]slack.User, error) {
+	users, err := s.listUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+	var matches []slack.User
+	for _, u := range users {
+		for _, candidate := range []string{u.Name, u.Profile.DisplayName, u.Profile.RealName, u.RealName} {
+			if candidate != "" && strings.EqualFold(candidate, name) {
+				matches = append(matches, u)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// listUsers returns every workspace user, reusing the last listing within the TTL
+func (s *Slack) listUsers(ctx context.Context) ([]slack.User, error) {
+	s.usersMu.Lock()
+	list, listedAt := s.userList, s.userListAt
+	s.usersMu.Unlock()
+	if list != nil && time.Since(listedAt) < userCacheTTL {
+		return list, nil
+	}
+
+	users, err := s.client.GetUsersContext(ctx)
+	if err != nil {
+		return nil, errs.NewSlackAPIError("users.list", err)
+	}
+	s.cacheUsers(users...)
+
+	s.usersMu.Lock()
+	s.userList, s.userListAt = users, time.Now()
+	s.usersMu.Unlock()
+	return users, nil
+}
+
+func (s *Slack) cacheUsers(users ...slack.User) {
+	now := time.Now()
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	if s.users == nil {
+		s.users = make(map[string]cachedUser)
+	}
+	for _, u := range users {
+		s.users[u.ID] = cachedUser{user: u, fetched: now}
+	}
+}
+
+// SharedChannels returns the channels that both the bot and the user are members of
+func (s *Slack) SharedChannels(ctx context.Context, userID string) ([]slack.Channel, error) {
+	botChannels, err := s.memberChannels(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	userChannels, err := s.memberChannels(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	member := make(map[string]bool, len(userChannels))
+	for _, c := range userChannels {
+		member[c.ID] = true
+	}
+	var shared []slack.Channel
+	for _, c := range botChannels {
+		if member[c.ID] {
+			shared = append(shared, c)
+		}
+	}
+	return shared, nil
+}
+
+// memberChannels pages through users.conversations; an empty userID means the bot
+func (s *Slack) memberChannels(ctx context.Context, userID string) ([]slack.Channel, error) {
+	params := &slack.GetConversationsForUserParameters{
+		UserID:          userID,
+		Types:           []string{"public_channel", "private_channel"},
+		Limit:           200,
+		ExcludeArchived: true,
+	}
+	var channels []slack.Channel
+	for {
+		page, cursor, err := s.client.GetConversationsForUserContext(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("list channels for %q: %w", userID, errs.NewSlackAPIError("users.conversations", err))
+		}
+		channels = append(channels, page...)
+		if cursor == "" {
+			return channels, nil
+		}
+		params.Cursor = cursor
+	}
+}
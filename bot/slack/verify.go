@@ -0,0 +1,159 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultSignatureTolerance is the timestamp window Slack recommends for signed requests
+const DefaultSignatureTolerance = 5 * time.Minute
+
+const (
+	headerSignature = "X-Slack-Signature"
+	headerTimestamp = "X-Slack-Request-Timestamp"
+)
+
+// Request verification failures, each counted under its reason in VerificationFailures
+var (
+	ErrMissingSignature = errors.New("missing signature headers")
+	ErrInvalidTimestamp = errors.New("invalid request timestamp")
+	ErrStaleTimestamp   = errors.New("request timestamp outside tolerance")
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrReplayedRequest  = errors.New("request signature already used")
+)
+
+var failureReasons = map[error]string{
+	ErrMissingSignature: "missing_headers",
+	ErrInvalidTimestamp: "invalid_timestamp",
+	ErrStaleTimestamp:   "stale_timestamp",
+	ErrInvalidSignature: "invalid_signature",
+	ErrReplayedRequest:  "replayed",
+}
+
+// requestVerifier checks Slack request signatures within a timestamp window and rejects
+// signatures it has already accepted, so captured requests can't be replayed
+type requestVerifier struct {
+	secret    string
+	tolerance time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // signature -> when it can be forgotten
+	lastPrune time.Time
+	failures  map[string]uint64 // reason -> count
+}
+
+func newRequestVerifier(secret string, tolerance time.Duration) *requestVerifier {
+	if tolerance <= 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+	return &requestVerifier{
+		secret:    secret,
+		tolerance: tolerance,
+		now:       time.Now,
+		seen:      make(map[string]time.Time),
+		failures:  make(map[string]uint64),
+	}
+}
+
+func (v *requestVerifier) verify(header http.Header, body []byte) error {
+	signature := header.Get(headerSignature)
+	rawTS := header.Get(headerTimestamp)
+	if signature == "" || rawTS == "" {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(rawTS, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidTimestamp, rawTS)
+	}
+	now := v.now()
+	// Compare in both directions so a fast sender clock can't extend the window
+	if skew := now.Sub(time.Unix(unix, 0)).Abs(); skew > v.tolerance {
+		return fmt.Errorf("%w: skew %s exceeds %s", ErrStaleTimestamp, skew.Round(time.Second), v.tolerance)
+	}
+
+	mac := hmac.New(sha256.New, []byte(v.secret))
+	_, _ = fmt.Fprintf(mac, "v0:%s:", rawTS)
+	_, _ = mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.prune(now)
+	if _, ok := v.seen[expected]; ok {
+		return ErrReplayedRequest
+	}
+	// The timestamp stays valid until tolerance after it, so remember it until then
+	v.seen[expected] = time.Unix(unix, 0).Add(v.tolerance)
+	return nil
+}
+
+// prune forgets signatures whose timestamps have left the window, at most once a minute
+func (v *requestVerifier) prune(now time.Time) {
+	if now.Sub(v.lastPrune) < time.Minute {
+		return
+	}
+	v.lastPrune = now
+	maps.DeleteFunc(v.seen, func(_ string, expires time.Time) bool {
+		return now.After(expires)
+	})
+}
+
+// record counts a failure under its reason and returns the reason
+func (v *requestVerifier) record(err error) string {
+	reason := "other"
+	for target, r := range failureReasons {
+		if errors.Is(err, target) {
+			reason = r
+			break
+		}
+	}
+	v.mu.Lock()
+	v.failures[reason]++
+	v.mu.Unlock()
+	return reason
+}
+
+func (v *requestVerifier) failureCounts() map[string]uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return maps.Clone(v.failures)
+}
+
+// VerifyRequest validates the request body against the Slack signing secret, rejecting
+// timestamps outside the configured tolerance and signatures that were already used
+func (s *Slack) VerifyRequest(header http.Header, body []byte) error {
+	err := s.verifier.verify(header, body)
+	if err == nil {
+		return nil
+	}
+
+	reason := s.verifier.record(err)
+	s.log.Warn("Slack request verification failed",
+		zap.String("reason", reason),
+		zap.String("timestamp", header.Get(headerTimestamp)),
+		zap.Uint64("failures", s.verifier.failureCounts()[reason]),
+		zap.Error(err),
+	)
+	return fmt.Errorf("verify request signature: %w", err)
+}
+
+// VerificationFailures returns the number of rejected requests by reason
+func (s *Slack) VerificationFailures() map[string]uint64 {
+	return s.verifier.failureCounts()
+}
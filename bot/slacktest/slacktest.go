@@ -0,0 +1,39 @@
+// Package slacktest fakes the Slack service features are given, for their tests
+package slacktest
+
+import (
+	"context"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// BotUserID is the bot's user ID
+	BotUserID = "UBOT"
+	// Posted answers a call that posts a message, e.g. chat.postMessage
+	Posted = `{"ok": true, "channel": "C1", "ts": "9.9"}`
+)
+
+// Slack is a Slack service whose client calls a test server, e.g. from httptest
+type Slack struct {
+	client *slack.Client
+}
+
+// New returns a Slack calling the Slack API at url
+func New(url string) *Slack {
+	return &Slack{client: slack.New("xoxb-test", slack.OptionAPIURL(url+"/"))}
+}
+
+// WithClient returns a Slack calling the Slack API with client
+func WithClient(client *slack.Client) *Slack {
+	return &Slack{client: client}
+}
+
+func (s *Slack) Client() *slack.Client { return s.client }
+
+func (s *Slack) BotUserID() string { return BotUserID }
+
+func (s *Slack) Available() bool { return true }
+
+// Retry calls fn once
+func (s *Slack) Retry(ctx context.Context, op string, fn func() error) error { return fn() }
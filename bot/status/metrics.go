@@ -0,0 +1,241 @@
+package status
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/errs"
+)
+
+// Drop reasons passed to Tracker.Dropped
+const (
+	DropQueueFull = "queue_full"
+	// DropReplied is an event Slack retried after the feature already replied to it
+	DropReplied = "already_replied"
+	// DropClaimed is a message another feature claimed the response to
+	DropClaimed = "claimed"
+)
+
+// labelValues are a sample's label values after feature, in the order of its metric's labels
+type labelValues [2]string
+
+// counters are a tracker's running totals and sizes, exposed to Prometheus by WriteMetrics
+type counters struct {
+	events      uint64
+	posts       uint64
+	errors      uint64
+	dropped     map[labelValues]uint64
+	postFailed  map[labelValues]uint64
+	rateLimited map[labelValues]uint64
+	sizes       map[labelValues]uint64
+	expired     map[labelValues]uint64
+}
+
+func inc(m *map[labelValues]uint64, k labelValues) {
+	if *m == nil {
+		*m = make(map[labelValues]uint64)
+	}
+	(*m)[k]++
+}
+
+// Dropped records an event in the channel the feature dropped without handling, e.g.
+// because its queue was full
+func (t *Tracker) Dropped(channelID, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	inc(&t.counters.dropped, labelValues{channelID, reason})
+	t.mu.Unlock()
+}
+
+// PostFailed records a failed post or other Slack call in the channel, such as a
+// reaction or kick, as the feature's most recent error.
+// Failures are counted by the Slack error code, e.g. missing_scope, so alerts can tell a
+// missing scope in one channel from an outage. A Slack rate limit also counts as one.
+func (t *Tracker) PostFailed(channelID string, err error) {
+	if t == nil || err == nil {
+		return
+	}
+	t.Error(err)
+	t.mu.Lock()
+	inc(&t.counters.postFailed, labelValues{channelID, failureReason(err)})
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		inc(&t.counters.rateLimited, labelValues{channelID})
+	}
+	t.mu.Unlock()
+}
+
+// RateLimited records an event in the channel the feature skipped or delayed to stay
+// under a rate limit
+func (t *Tracker) RateLimited(channelID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	inc(&t.counters.rateLimited, labelValues{channelID})
+	t.mu.Unlock()
+}
+
+// Size records how many entries an in-memory map of the feature holds, e.g. per-user
+// state, so unbounded growth shows up before it becomes a problem
+func (t *Tracker) Size(name string, n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.counters.sizes == nil {
+		t.counters.sizes = make(map[labelValues]uint64)
+	}
+	t.counters.sizes[labelValues{name}] = uint64(max(n, 0))
+	t.mu.Unlock()
+}
+
+// Expired records how many of the feature's stored records in the retention namespace a
+// sweep soft-deleted and purged
+func (t *Tracker) Expired(namespace string, softDeleted, purged int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.counters.expired == nil {
+		t.counters.expired = make(map[labelValues]uint64)
+	}
+	t.counters.expired[labelValues{namespace, "soft_deleted"}] += uint64(max(softDeleted, 0))
+	t.counters.expired[labelValues{namespace, "purged"}] += uint64(max(purged, 0))
+	t.mu.Unlock()
+}
+
+// failureReason returns the Slack error code of a failed call, or the error category
+// when Slack didn't give one
+func failureReason(err error) string {
+	var apiErr *errs.SlackAPIError
+	if !errors.As(err, &apiErr) {
+		apiErr = errs.NewSlackAPIError("", err)
+	}
+	if apiErr.Code != "" {
+		return apiErr.Code
+	}
+	return errs.Category(err)
+}
+
+// metric is one counter or gauge family in the Prometheus text format
+type metric struct {
+	name   string
+	help   string
+	kind   string   // counter or gauge
+	labels []string // after feature
+	values func(c *counters) map[labelValues]uint64
+}
+
+var metrics = []metric{
+	{
+		name:   "slackbot_events_total",
+		help:   "Events processed by a feature.",
+		values: func(c *counters) map[labelValues]uint64 { return total(c.events) },
+	},
+	{
+		name:   "slackbot_posts_total",
+		help:   "Messages posted by a feature.",
+		values: func(c *counters) map[labelValues]uint64 { return total(c.posts) },
+	},
+	{
+		name:   "slackbot_errors_total",
+		help:   "Errors recorded by a feature.",
+		values: func(c *counters) map[labelValues]uint64 { return total(c.errors) },
+	},
+	{
+		name:   "slackbot_events_dropped_total",
+		help:   "Events a feature dropped without handling, by channel and reason.",
+		labels: []string{"channel", "reason"},
+		values: func(c *counters) map[labelValues]uint64 { return c.dropped },
+	},
+	{
+		name:   "slackbot_post_failures_total",
+		help:   "Failed posts and other Slack calls by feature, channel and Slack error.",
+		labels: []string{"channel", "reason"},
+		values: func(c *counters) map[labelValues]uint64 { return c.postFailed },
+	},
+	{
+		name:   "slackbot_rate_limited_total",
+		help:   "Events a feature skipped or posts Slack rejected for rate limits, by channel.",
+		labels: []string{"channel"},
+		values: func(c *counters) map[labelValues]uint64 { return c.rateLimited },
+	},
+	{
+		name:   "slackbot_state_entries",
+		help:   "Entries held in a feature's in-memory state, by state.",
+		kind:   "gauge",
+		labels: []string{"state"},
+		values: func(c *counters) map[labelValues]uint64 { return c.sizes },
+	},
+	{
+		name:   "slackbot_records_expired_total",
+		help:   "Stored records a retention sweep soft-deleted or purged, by namespace.",
+		labels: []string{"namespace", "action"},
+		values: func(c *counters) map[labelValues]uint64 { return c.expired },
+	},
+}
+
+func total(n uint64) map[labelValues]uint64 {
+	if n == 0 {
+		return nil
+	}
+	return map[labelValues]uint64{{}: n}
+}
+
+// sample is one labeled counter value
+type sample struct {
+	feature string
+	key     labelValues
+	value   uint64
+}
+
+// WriteMetrics writes every feature's counters in the Prometheus text exposition format
+func (r *Registry) WriteMetrics(w io.Writer) error {
+	r.mu.Lock()
+	trackers := make([]*Tracker, 0, len(r.trackers))
+	for _, t := range r.trackers {
+		trackers = append(trackers, t)
+	}
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		var samples []sample
+		for _, t := range trackers {
+			t.mu.Lock()
+			for k, v := range m.values(&t.counters) {
+				samples = append(samples, sample{feature: t.name, key: k, value: v})
+			}
+			t.mu.Unlock()
+		}
+		slices.SortFunc(samples, func(a, b sample) int {
+			return cmp.Or(
+				strings.Compare(a.feature, b.feature),
+				strings.Compare(a.key[0], b.key[0]),
+				strings.Compare(a.key[1], b.key[1]),
+			)
+		})
+
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, cmp.Or(m.kind, "counter"))
+		for _, s := range samples {
+			fmt.Fprintf(&b, `%s{feature="%s"`, m.name, labelEscaper.Replace(s.feature))
+			for i, label := range m.labels {
+				fmt.Fprintf(&b, `,%s="%s"`, label, labelEscaper.Replace(s.key[i]))
+			}
+			fmt.Fprintf(&b, "} %d\n", s.value)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// labelEscaper escapes label values the way the Prometheus text format expects
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
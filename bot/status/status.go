@@ -0,0 +1,319 @@
+// Package status tracks when each feature last processed an event, posted a message, or
+// failed, so operators can see which features are alive
+package status
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/trigger"
+)
+
+const (
+	eventChannelSize = 10
+	// activityMinutes is how many minutes of per-minute event counts are kept
+	activityMinutes = 60
+)
+
+// FeatureStatus is a point-in-time view of a feature's activity
+type FeatureStatus struct {
+	Name      string     `json:"name"`
+	LastEvent *time.Time `json:"last_event,omitempty"`
+	LastPost  *time.Time `json:"last_post,omitempty"`
+	LastError *time.Time `json:"last_error,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Activity counts events per minute over the last hour, oldest first
+	Activity []int `json:"activity,omitempty"`
+}
+
+// Tracker records activity for a single feature. A nil Tracker ignores all calls so
+// features work without a registry.
+type Tracker struct {
+	name      string
+	now       func() time.Time
+	mu        sync.Mutex
+	lastEvent time.Time
+	lastPost  time.Time
+	lastError time.Time
+	err       string
+	activity  [activityMinutes]int // event counts indexed by unix minute
+	minute    int64                // unix minute of the newest activity bucket
+	counters  counters
+}
+
+// Event records that the feature processed an event
+func (t *Tracker) Event() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.lastEvent = t.now()
+	t.advance(t.lastEvent)
+	t.activity[t.minute%activityMinutes]++
+	t.counters.events++
+	t.mu.Unlock()
+}
+
+// Posted records that the feature posted a message
+func (t *Tracker) Posted() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.lastPost = t.now()
+	t.counters.posts++
+	t.mu.Unlock()
+}
+
+// Error records the feature's most recent failure
+func (t *Tracker) Error(err error) {
+	if t == nil || err == nil {
+		return
+	}
+	t.mu.Lock()
+	t.lastError = t.now()
+	t.err = err.Error()
+	t.counters.errors++
+	t.mu.Unlock()
+}
+
+// advance clears the buckets of minutes that passed without events; callers must hold mu
+func (t *Tracker) advance(now time.Time) {
+	minute := now.Unix() / 60
+	for m := t.minute + 1; m <= minute && m <= t.minute+activityMinutes; m++ {
+		t.activity[m%activityMinutes] = 0
+	}
+	t.minute = max(t.minute, minute)
+}
+
+func (t *Tracker) snapshot() FeatureStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := FeatureStatus{
+		Name:      t.name,
+		LastEvent: timePtr(t.lastEvent),
+		LastPost:  timePtr(t.lastPost),
+		LastError: timePtr(t.lastError),
+		Error:     t.err,
+	}
+	if !t.lastEvent.IsZero() {
+		t.advance(t.now())
+		s.Activity = make([]int, activityMinutes)
+		for i := range s.Activity {
+			s.Activity[i] = t.activity[(t.minute+1+int64(i))%activityMinutes]
+		}
+	}
+	return s
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Registry holds a Tracker per feature
+type Registry struct {
+	now      func() time.Time
+	mu       sync.Mutex
+	trackers map[string]*Tracker
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		now:      time.Now,
+		trackers: make(map[string]*Tracker),
+	}
+}
+
+// Feature returns the tracker for the named feature, creating it if needed
+func (r *Registry) Feature(name string) *Tracker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.trackers[name]; ok {
+		return t
+	}
+	t := &Tracker{name: name, now: r.now}
+	r.trackers[name] = t
+	return t
+}
+
+// Snapshot returns the status of every registered feature sorted by name
+func (r *Registry) Snapshot() []FeatureStatus {
+	r.mu.Lock()
+	trackers := make([]*Tracker, 0, len(r.trackers))
+	for _, t := range r.trackers {
+		trackers = append(trackers, t)
+	}
+	r.mu.Unlock()
+
+	statuses := make([]FeatureStatus, 0, len(trackers))
+	for _, t := range trackers {
+		statuses = append(statuses, t.snapshot())
+	}
+	slices.SortFunc(statuses, func(a, b FeatureStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return statuses
+}
+
+// requestPattern matches a mention asking only for "status", e.g. "<@U123> status?"
+var requestPattern = regexp.MustCompile(`(?i)^\s*(<@[A-Z0-9]+>\s*)?status\s*[?!.]*\s*$`)
+
+// IsRequest reports whether the message text is a status request
+func IsRequest(text string) bool {
+	return requestPattern.MatchString(text)
+}
+
+// IsAddressedRequest reports whether the text is a status request, either through a
+// mention or by starting with a trigger alias such as "hey bot, status"
+func IsAddressedRequest(text string, triggers *trigger.Matcher) bool {
+	if IsRequest(text) {
+		return true
+	}
+	rest, ok := triggers.TrimPrefix(text)
+	return ok && IsRequest(rest)
+}
+
+// Format renders statuses as a Slack message
+func Format(statuses []FeatureStatus, now time.Time) string {
+	if len(statuses) == 0 {
+		return "No features are running."
+	}
+
+	ago := func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return now.Sub(*t).Round(time.Second).String() + " ago"
+	}
+
+	var b strings.Builder
+	b.WriteString("*Feature status*\n")
+	for _, s := range statuses {
+		fmt.Fprintf(&b, "• *%s*: last event %s, last post %s", s.Name, ago(s.LastEvent), ago(s.LastPost))
+		if s.LastError != nil {
+			fmt.Fprintf(&b, ", last error %s (`%s`)", ago(s.LastError), s.Error)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+type slackService interface {
+	Client() *slack.Client
+}
+
+// Responder replies to "@bot status" mentions with the registry snapshot
+type Responder struct {
+	log         *zap.Logger
+	slack       slackService
+	registry    *Registry
+	triggers    *trigger.Matcher
+	stopCh      chan struct{}
+	eventsCh    chan event.Event
+	isConnected atomic.Bool
+}
+
+// NewResponder creates a responder that also answers messages addressed with a trigger alias
+func NewResponder(log *zap.Logger, s slackService, r *Registry, aliases []string) *Responder {
+	return &Responder{
+		log:      log,
+		slack:    s,
+		registry: r,
+		triggers: trigger.NewMatcher(aliases),
+		stopCh:   make(chan struct{}),
+		eventsCh: make(chan event.Event, eventChannelSize),
+	}
+}
+
+// ProcessorType returns a description of the processor type
+func (s *Responder) ProcessorType() string {
+	return "status"
+}
+
+func (s *Responder) Start(ctx context.Context) error {
+	s.isConnected.Store(true)
+	go s.handleEvents(ctx)
+	return nil
+}
+
+func (s *Responder) Stop(ctx context.Context) error {
+	if !s.isConnected.Load() {
+		return nil
+	}
+	close(s.stopCh)
+	s.isConnected.Store(false)
+	return nil
+}
+
+// PushEvent adds an event to be processed by the status responder
+func (s *Responder) PushEvent(e event.Event) {
+	if !s.isConnected.Load() {
+		return
+	}
+
+	select {
+	case s.eventsCh <- e:
+	default:
+		s.log.Warn("Status events channel full, dropping event.")
+	}
+}
+
+func (s *Responder) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		case e := <-s.eventsCh:
+			s.processEvent(e.Context(ctx), e)
+		}
+	}
+}
+
+func (s *Responder) processEvent(ctx context.Context, e event.Event) {
+	if e.FromBot() {
+		return
+	}
+	switch e.Data().(type) {
+	case *slackevents.AppMentionEvent:
+		if IsRequest(e.Text) {
+			s.reply(ctx, e.Channel, e.ThreadTS)
+		}
+	case *slackevents.MessageEvent:
+		// Formal mentions are answered from the app_mention event
+		if e.SubType != "" || strings.Contains(e.Text, "<@") {
+			return
+		}
+		if rest, ok := s.triggers.TrimPrefix(e.Text); ok && IsRequest(rest) {
+			s.reply(ctx, e.Channel, e.ThreadTS)
+		}
+	}
+}
+
+func (s *Responder) reply(ctx context.Context, channel, threadTS string) {
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(Format(s.registry.Snapshot(), time.Now()), false),
+		slack.MsgOptionAsUser(true),
+	}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+	}
+	if _, _, err := s.slack.Client().PostMessageContext(ctx, channel, opts...); err != nil {
+		s.log.Error("Failed to post status", zap.String("channel", channel), zap.Error(err))
+	}
+}
@@ -0,0 +1,161 @@
+package status
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/trigger"
+)
+
+func TestRegistry_Snapshot(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+
+	chat := r.Feature("chat")
+	if r.Feature("chat") != chat {
+		t.Fatal("Feature() should return the same tracker for a name")
+	}
+	chat.Event()
+	chat.Posted()
+	r.Feature("aichat").Error(errors.New("rate limited"))
+
+	statuses := r.Snapshot()
+	if len(statuses) != 2 || statuses[0].Name != "aichat" || statuses[1].Name != "chat" {
+		t.Fatalf("Unexpected snapshot order: %+v", statuses)
+	}
+	if statuses[0].LastEvent != nil || statuses[0].LastError == nil || statuses[0].Error != "rate limited" {
+		t.Errorf("Unexpected aichat status: %+v", statuses[0])
+	}
+	if statuses[1].LastEvent == nil || !statuses[1].LastPost.Equal(now) {
+		t.Errorf("Unexpected chat status: %+v", statuses[1])
+	}
+}
+
+func TestTracker_Activity(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+	tr := r.Feature("chat")
+
+	tr.Event()
+	tr.Event()
+	now = now.Add(2 * time.Minute)
+	tr.Event()
+
+	activity := r.Snapshot()[0].Activity
+	if len(activity) != activityMinutes {
+		t.Fatalf("Activity has %d minutes, want %d", len(activity), activityMinutes)
+	}
+	if got := activity[activityMinutes-3:]; got[0] != 2 || got[1] != 0 || got[2] != 1 {
+		t.Errorf("Recent activity = %v, want [2 0 1]", got)
+	}
+
+	// Counts older than the window are dropped
+	now = now.Add(90 * time.Minute)
+	activity = r.Snapshot()[0].Activity
+	for _, n := range activity {
+		if n != 0 {
+			t.Fatalf("Activity should be empty after an idle hour, got %v", activity)
+		}
+	}
+}
+
+func TestTracker_NilSafe(t *testing.T) {
+	var tr *Tracker
+	tr.Event()
+	tr.Posted()
+	tr.Error(errors.New("ignored"))
+}
+
+func TestIsRequest(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"<@U123ABC> status", true},
+		{"<@U123ABC> Status?", true},
+		{"status", true},
+		{"<@U123ABC> what's your status on the report", false},
+		{"status update please", false},
+	}
+	for _, tt := range tests {
+		if got := IsRequest(tt.text); got != tt.want {
+			t.Errorf("IsRequest(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIsAddressedRequest(t *testing.T) {
+	triggers := trigger.NewMatcher([]string{"hey bot"})
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"<@U123ABC> status", true},
+		{"hey bot, status?", true},
+		{"Hey Bot status", true},
+		{"hey bot what is the status", false},
+		{"status", true},
+	}
+	for _, tt := range tests {
+		if got := IsAddressedRequest(tt.text, triggers); got != tt.want {
+			t.Errorf("IsAddressedRequest(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := now.Add(-90 * time.Second)
+	text := Format([]FeatureStatus{
+		{Name: "chat", LastEvent: &event},
+		{Name: "aichat", LastError: &event, Error: "boom"},
+	}, now)
+
+	for _, want := range []string{"*chat*: last event 1m30s ago, last post never", "last error 1m30s ago (`boom`)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Format() missing %q in:\n%s", want, text)
+		}
+	}
+	if Format(nil, now) != "No features are running." {
+		t.Error("Format() should report when no features are running")
+	}
+}
+
+func TestRegistry_WriteMetrics(t *testing.T) {
+	r := NewRegistry()
+	vibecheck := r.Feature("vibecheck")
+	vibecheck.Event()
+	vibecheck.PostFailed("CGENERAL", errs.NewSlackAPIError("conversations.kick", slack.SlackErrorResponse{Err: "missing_scope"}))
+	vibecheck.PostFailed("CGENERAL", errs.NewSlackAPIError("chat.postMessage", &slack.RateLimitedError{RetryAfter: time.Second}))
+	vibecheck.Dropped("CRANDOM", DropQueueFull)
+	r.Feature("aichat").RateLimited("CRANDOM")
+
+	var b strings.Builder
+	if err := r.WriteMetrics(&b); err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE slackbot_post_failures_total counter\n",
+		`slackbot_events_total{feature="vibecheck"} 1`,
+		`slackbot_errors_total{feature="vibecheck"} 2`,
+		`slackbot_post_failures_total{feature="vibecheck",channel="CGENERAL",reason="missing_scope"} 1`,
+		`slackbot_post_failures_total{feature="vibecheck",channel="CGENERAL",reason="ratelimited"} 1`,
+		`slackbot_events_dropped_total{feature="vibecheck",channel="CRANDOM",reason="queue_full"} 1`,
+		`slackbot_rate_limited_total{feature="aichat",channel="CRANDOM"} 1`,
+		`slackbot_rate_limited_total{feature="vibecheck",channel="CGENERAL"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("WriteMetrics() missing %q in:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), `slackbot_posts_total{`) {
+		t.Errorf("WriteMetrics() should skip counters that are zero:\n%s", b.String())
+	}
+}
@@ -0,0 +1,44 @@
+// Package subtype decides which Slack message subtypes features respond to, so system
+// messages like "X has joined the channel" or edits don't trigger pattern matches
+package subtype
+
+import (
+	"slices"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+// DefaultAllowed are the subtypes treated like ordinary messages when none are configured.
+// Plain messages, which have no subtype, are always allowed.
+var DefaultAllowed = []string{"thread_broadcast", "file_share", "me_message"}
+
+// Filter allows plain messages and a configured set of subtypes from human authors
+type Filter struct {
+	allowed []string
+}
+
+// NewFilter allows the given subtypes in addition to plain messages, or DefaultAllowed
+// when none are given
+func NewFilter(allowed []string) *Filter {
+	if len(allowed) == 0 {
+		allowed = DefaultAllowed
+	}
+	return &Filter{allowed: allowed}
+}
+
+// AllowSubtype reports whether a message with this subtype should be handled
+func (f *Filter) AllowSubtype(subtype string) bool {
+	if subtype == "" {
+		return true
+	}
+	if f == nil {
+		return slices.Contains(DefaultAllowed, subtype)
+	}
+	return slices.Contains(f.allowed, subtype)
+}
+
+// Allow reports whether a message event should be handled: it has an allowed subtype and
+// was written by a person rather than a bot
+func (f *Filter) Allow(ev *slackevents.MessageEvent) bool {
+	return ev.BotID == "" && ev.User != "" && f.AllowSubtype(ev.SubType)
+}
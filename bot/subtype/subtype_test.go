@@ -0,0 +1,37 @@
+package subtype
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestFilter_Allow(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		ev      slackevents.MessageEvent
+		want    bool
+	}{
+		{"plain message", nil, slackevents.MessageEvent{User: "U1"}, true},
+		{"default subtype", nil, slackevents.MessageEvent{User: "U1", SubType: "thread_broadcast"}, true},
+		{"channel join", nil, slackevents.MessageEvent{User: "U1", SubType: "channel_join"}, false},
+		{"edit", nil, slackevents.MessageEvent{User: "U1", SubType: "message_changed"}, false},
+		{"bot", nil, slackevents.MessageEvent{User: "U1", BotID: "B1"}, false},
+		{"no user", nil, slackevents.MessageEvent{SubType: "file_share"}, false},
+		{"configured", []string{"channel_join"}, slackevents.MessageEvent{User: "U1", SubType: "channel_join"}, true},
+		{"configured replaces defaults", []string{"channel_join"}, slackevents.MessageEvent{User: "U1", SubType: "file_share"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewFilter(tt.allowed).Allow(&tt.ev); got != tt.want {
+				t.Errorf("Allow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var nilFilter *Filter
+	if !nilFilter.AllowSubtype("") || nilFilter.AllowSubtype("channel_join") {
+		t.Error("nil Filter should use the defaults")
+	}
+}
@@ -0,0 +1,212 @@
+// Package topic sets channel topics and purposes, and keeps them fixed in configured
+// channels by restoring them when someone changes them.
+package topic
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
+)
+
+const DefaultInterval = 5 * time.Minute
+
+// Fields of a channel that can be set
+const (
+	FieldTopic   = "topic"
+	FieldPurpose = "purpose"
+)
+
+type slackService interface {
+	Client() *slack.Client
+	Retry(ctx context.Context, op string, fn func() error) error
+}
+
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
+// ChannelConfig is what's kept in one channel. Unset fields aren't managed.
+type ChannelConfig struct {
+	Topic   *string `json:"topic" yaml:"topic"`
+	Purpose *string `json:"purpose" yaml:"purpose"`
+}
+
+type FileConfig struct {
+	Channels map[string]ChannelConfig `json:"channels" yaml:"channels"`
+	// Interval is how often the channels are checked, defaults to 5m
+	Interval *time.Duration `json:"interval" yaml:"interval"`
+	// Notify posts a notice in the channel when a change is reverted, defaults to true
+	Notify  *bool          `json:"notify" yaml:"notify"`
+	Persona persona.Config `json:"persona" yaml:"persona"`
+}
+
+type Config struct {
+	Channels map[string]ChannelConfig // Channel ID -> topic and purpose kept there
+	Interval time.Duration
+	Notify   bool
+	Persona  persona.Config // Name and icon notices are posted with
+}
+
+// Set changes a channel's topic or purpose
+func Set(ctx context.Context, s slackService, channelID, field, text string) error {
+	op, call := "conversations.setTopic", s.Client().SetTopicOfConversationContext
+	switch field {
+	case FieldTopic:
+	case FieldPurpose:
+		op, call = "conversations.setPurpose", s.Client().SetPurposeOfConversationContext
+	default:
+		return fmt.Errorf("unknown channel field %q, want %s or %s", field, FieldTopic, FieldPurpose)
+	}
+	return s.Retry(ctx, op, func() error {
+		_, err := call(ctx, channelID, text)
+		return err
+	})
+}
+
+// Guard restores the topic and purpose of configured channels when they drift
+type Guard struct {
+	log         *zap.Logger
+	config      Config
+	slack       slackService
+	isConnected atomic.Bool
+	stopCh      chan struct{}
+	status      *status.Tracker
+	presence    presence
+}
+
+func New(log *zap.Logger, c Config, s slackService) *Guard {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	return &Guard{
+		log:    log,
+		config: c,
+		slack:  s,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// SetStatusTracker sets where restores and failures are reported
+func (g *Guard) SetStatusTracker(t *status.Tracker) {
+	g.status = t
+}
+
+// SetPresence skips channels the bot was removed from
+func (g *Guard) SetPresence(p presence) {
+	g.presence = p
+}
+
+// Start checks the channels right away and then on every interval
+func (g *Guard) Start(ctx context.Context) error {
+	g.isConnected.Store(true)
+	go func() {
+		ticker := time.NewTicker(g.config.Interval)
+		defer ticker.Stop()
+		for {
+			g.checkAll(ctx)
+			select {
+			case <-g.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	g.log.Debug("Topic guard started.",
+		zap.Int("channels", len(g.config.Channels)),
+		zap.Duration("interval", g.config.Interval))
+	return nil
+}
+
+func (g *Guard) Stop(ctx context.Context) error {
+	if !g.isConnected.Load() {
+		return nil
+	}
+	close(g.stopCh)
+	g.isConnected.Store(false)
+	return nil
+}
+
+func (g *Guard) checkAll(ctx context.Context) {
+	for _, channelID := range slices.Sorted(maps.Keys(g.config.Channels)) {
+		if ctx.Err() != nil {
+			return
+		}
+		if g.presence != nil && !g.presence.Present(ctx, channelID) {
+			g.log.Debug("Bot was removed from the channel, skipping topic check", zap.String("channel", channelID))
+			continue
+		}
+		g.check(ctx, channelID, g.config.Channels[channelID])
+	}
+}
+
+// check restores the channel's topic and purpose if they differ from the config
+func (g *Guard) check(ctx context.Context, channelID string, c ChannelConfig) {
+	info, err := g.slack.Client().GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: channelID})
+	if err != nil {
+		err = errs.NewSlackAPIError("conversations.info", err)
+		g.status.Error(err)
+		g.log.Error("Failed to look up channel topic", zap.String("channel", channelID), zap.Error(err))
+		return
+	}
+	g.status.Event()
+	if c.Topic != nil && drifted(info.Topic.Value, *c.Topic) {
+		g.restore(ctx, channelID, FieldTopic, *c.Topic, info.Topic.Creator)
+	}
+	if c.Purpose != nil && drifted(info.Purpose.Value, *c.Purpose) {
+		g.restore(ctx, channelID, FieldPurpose, *c.Purpose, info.Purpose.Creator)
+	}
+}
+
+// drifted reports whether the current value differs from the configured one. Slack
+// returns the value HTML escaped.
+func drifted(current, want string) bool {
+	return strings.TrimSpace(html.UnescapeString(current)) != strings.TrimSpace(want)
+}
+
+func (g *Guard) restore(ctx context.Context, channelID, field, text, changedBy string) {
+	if err := Set(ctx, g.slack, channelID, field, text); err != nil {
+		g.status.Error(err)
+		g.log.Error("Failed to restore channel "+field,
+			zap.String("channel", channelID),
+			zap.Error(err))
+		return
+	}
+	g.log.Info("Restored channel "+field,
+		zap.String("channel", channelID),
+		zap.String("changed_by", changedBy))
+	if g.config.Notify {
+		g.notify(ctx, channelID, field, changedBy)
+	}
+}
+
+func (g *Guard) notify(ctx context.Context, channelID, field, changedBy string) {
+	text := fmt.Sprintf("📌 The channel %s is kept as configured, so it was restored", field)
+	if changedBy != "" {
+		text = fmt.Sprintf("📌 <@%s> changed the channel %s, but it's kept as configured, so it was restored", changedBy, field)
+	}
+	_, _, err := g.slack.Client().PostMessageContext(ctx, channelID,
+		slack.MsgOptionText(text, false),
+		g.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		err = errs.NewSlackAPIError("chat.postMessage", err)
+		g.status.Error(err)
+		g.log.Error("Failed to post topic notice", zap.String("channel", channelID), zap.Error(err))
+		return
+	}
+	g.status.Posted()
+}
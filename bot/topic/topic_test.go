@@ -0,0 +1,57 @@
+package topic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/slacktest"
+)
+
+func TestGuard_Check(t *testing.T) {
+	var methods []string
+	var topic, notice string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		methods = append(methods, method)
+		switch method {
+		case "conversations.info":
+			_, _ = w.Write([]byte(`{"ok": true, "channel": {"id": "C1",
+				"topic": {"value": "lunch &amp; games", "creator": "U2"},
+				"purpose": {"value": "On-call: <@U9> &amp; friends", "creator": "U1"}}}`))
+		case "conversations.setTopic":
+			topic = r.FormValue("topic")
+			_, _ = w.Write([]byte(`{"ok": true, "channel": {"id": "C1"}}`))
+		case "chat.postMessage":
+			notice = r.FormValue("text")
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+		}
+	}))
+	defer server.Close()
+
+	wantTopic, wantPurpose := "On-call: <@U1>", "On-call: <@U9> & friends"
+	g := New(zap.NewNop(), Config{Notify: true}, slacktest.New(server.URL))
+	g.check(context.Background(), "C1", ChannelConfig{Topic: &wantTopic, Purpose: &wantPurpose})
+
+	want := []string{"conversations.info", "conversations.setTopic", "chat.postMessage"}
+	if !slices.Equal(methods, want) {
+		t.Fatalf("calls = %v, want %v with the matching purpose left alone", methods, want)
+	}
+	if topic != wantTopic {
+		t.Errorf("restored topic = %q, want %q", topic, wantTopic)
+	}
+	if !strings.Contains(notice, "<@U2> changed the channel topic") {
+		t.Errorf("notice = %q, want who changed the topic", notice)
+	}
+}
+
+func TestSet_UnknownField(t *testing.T) {
+	if err := Set(context.Background(), &slacktest.Slack{}, "C1", "name", "x"); err == nil {
+		t.Error("Set() = nil, want an error for an unknown field")
+	}
+}
@@ -0,0 +1,61 @@
+// Package trigger matches configurable alias words that address the bot without a
+// formal <@UXXXX> mention, such as "hey bot" or a custom nickname
+package trigger
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultAliases are used when no aliases are configured
+var DefaultAliases = []string{"bot"}
+
+// Matcher finds aliases case-insensitively on word boundaries, so "bot" matches
+// "@bot" and "Bot," but not "robot" or "bottom"
+type Matcher struct {
+	anywhere *regexp.Regexp
+	prefix   *regexp.Regexp
+}
+
+// NewMatcher compiles the aliases. Whitespace within an alias matches any run of
+// whitespace. A matcher with no aliases never matches.
+func NewMatcher(aliases []string) *Matcher {
+	var parts []string
+	for _, alias := range aliases {
+		fields := strings.Fields(alias)
+		if len(fields) == 0 {
+			continue
+		}
+		for i, f := range fields {
+			fields[i] = regexp.QuoteMeta(f)
+		}
+		parts = append(parts, strings.Join(fields, `\s+`))
+	}
+	if len(parts) == 0 {
+		return &Matcher{}
+	}
+
+	alt := "(?:" + strings.Join(parts, "|") + ")"
+	return &Matcher{
+		anywhere: regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])` + alt + `(?:[^\p{L}\p{N}_]|$)`),
+		prefix:   regexp.MustCompile(`(?i)^\s*@?` + alt + `(?:[\s,:;!.?]+|$)`),
+	}
+}
+
+// Match reports whether text contains an alias
+func (m *Matcher) Match(text string) bool {
+	return m != nil && m.anywhere != nil && m.anywhere.MatchString(text)
+}
+
+// TrimPrefix removes a leading alias and the punctuation after it, e.g. "hey bot, status"
+// becomes "status". It reports whether the text started with an alias.
+func (m *Matcher) TrimPrefix(text string) (string, bool) {
+	if m == nil || m.prefix == nil {
+		return text, false
+	}
+	loc := m.prefix.FindStringIndex(text)
+	if loc == nil {
+		return text, false
+	}
+	return text[loc[1]:], true
+}
@@ -0,0 +1,62 @@
+package trigger
+
+import "testing"
+
+func TestMatcher_Match(t *testing.T) {
+	m := NewMatcher([]string{"bot", "hey  robo", "c-3po"})
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"bot", true},
+		{"hey @bot what's up", true},
+		{"BOT!", true},
+		{"Hey Robo, tell me a joke", true},
+		{"hey\nrobo", true},
+		{"ask c-3po", true},
+		{"robot", false},
+		{"bottom text", false},
+		{"UBOTID", false},
+		{"hey roboto", false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.text); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_TrimPrefix(t *testing.T) {
+	m := NewMatcher([]string{"hey bot", "jeeves"})
+
+	tests := []struct {
+		text   string
+		want   string
+		wantOK bool
+	}{
+		{"hey bot, status", "status", true},
+		{"Jeeves: status?", "status?", true},
+		{"@jeeves status", "status", true},
+		{"jeeves", "", true},
+		{"status hey bot", "status hey bot", false},
+		{"jeevesy status", "jeevesy status", false},
+	}
+	for _, tt := range tests {
+		got, ok := m.TrimPrefix(tt.text)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("TrimPrefix(%q) = (%q, %v), want (%q, %v)", tt.text, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMatcher_Empty(t *testing.T) {
+	for _, m := range []*Matcher{nil, NewMatcher(nil), NewMatcher([]string{"  "})} {
+		if m.Match("bot") {
+			t.Error("Empty matcher should never match")
+		}
+		if _, ok := m.TrimPrefix("bot status"); ok {
+			t.Error("Empty matcher should never trim")
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+)
+
+const (
+	// EulogyActionID identifies the button on a deleted user's notification that opens
+	// the eulogy modal
+	EulogyActionID = "userwatch_eulogy"
+	// EulogyCallbackID identifies the eulogy modal
+	EulogyCallbackID = "userwatch_eulogy"
+
+	eulogyBlockID = "eulogy"
+	eulogyInputID = "text"
+)
+
+// eulogyMetadata is carried in the modal's private metadata so the submission can reply
+// in the notification's thread
+type eulogyMetadata struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	Name    string `json:"name"`
+}
+
+// eulogyBlocks is the button posted with a deleted user's notification
+func eulogyBlocks(user *slack.User) []slack.Block {
+	button := slack.NewButtonBlockElement(EulogyActionID, displayName(user),
+		slack.NewTextBlockObject(slack.PlainTextType, "Write a eulogy", false, false))
+	return []slack.Block{slack.NewActionBlock("userwatch_obituary_actions", button)}
+}
+
+func displayName(user *slack.User) string {
+	if user.RealName != "" {
+		return user.RealName
+	}
+	return user.Name
+}
+
+// HandleEulogyAction opens the eulogy modal for the notification whose button was clicked
+func (o *UserWatch) HandleEulogyAction(ctx context.Context, callback slack.InteractionCallback, action *slack.BlockAction) {
+	metadata, err := json.Marshal(eulogyMetadata{
+		Channel: callback.Channel.ID,
+		TS:      callback.Message.Timestamp,
+		Name:    action.Value,
+	})
+	if err != nil {
+		o.log.Error("Failed to encode eulogy metadata", zap.Error(err))
+		return
+	}
+
+	input := slack.NewPlainTextInputBlockElement(
+		slack.NewTextBlockObject(slack.PlainTextType, "A few kind words", false, false),
+		eulogyInputID,
+	).WithMultiline(true)
+	view := slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      EulogyCallbackID,
+		PrivateMetadata: string(metadata),
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "Eulogy", false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, "Post", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewInputBlock(eulogyBlockID,
+				slack.NewTextBlockObject(slack.PlainTextType, "Remember "+action.Value, false, false),
+				nil, input),
+		}},
+	}
+	if _, err := o.slack.Client().OpenViewContext(ctx, callback.TriggerID, view); err != nil {
+		o.log.Error("Failed to open eulogy modal", zap.Error(err), zap.String("user", callback.User.ID))
+	}
+}
+
+// HandleEulogySubmission posts a submitted eulogy in the notification's thread, keeping
+// the modal open with an error when it can't be posted
+func (o *UserWatch) HandleEulogySubmission(ctx context.Context, callback slack.InteractionCallback) *slack.ViewSubmissionResponse {
+	text := strings.TrimSpace(callback.View.State.Values[eulogyBlockID][eulogyInputID].Value)
+	if text == "" {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{eulogyBlockID: "Write a few words first"})
+	}
+
+	var metadata eulogyMetadata
+	if err := json.Unmarshal([]byte(callback.View.PrivateMetadata), &metadata); err != nil || metadata.Channel == "" {
+		o.log.Error("Eulogy submitted without the notification it belongs to", zap.Error(err))
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{eulogyBlockID: "This notification can't be replied to"})
+	}
+
+	_, _, err := o.slack.Client().PostMessageContext(ctx, metadata.Channel,
+		slack.MsgOptionText(fmt.Sprintf("<@%s> remembers %s:\n>%s", callback.User.ID, metadata.Name,
+			strings.ReplaceAll(text, "\n", "\n>")), false),
+		slack.MsgOptionTS(metadata.TS),
+		o.persona.MsgOption(),
+	)
+	if err != nil {
+		o.log.Error("Failed to post eulogy",
+			zap.Error(conversation.Error("chat.postMessage", conversation.KindFromID(metadata.Channel), err)),
+			zap.String("channel", metadata.Channel))
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{eulogyBlockID: "Couldn't post the eulogy, try again"})
+	}
+	o.log.Info("Posted eulogy", zap.String("user", callback.User.ID), zap.String("name", metadata.Name))
+	return nil
+}
@@ -0,0 +1,81 @@
+package user
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// RosterUser is a workspace member in an exported roster
+type RosterUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	RealName string `json:"real_name"`
+	Title    string `json:"title"`
+	Email    string `json:"email"` // Empty without the users:read.email scope
+	Deleted  bool   `json:"deleted"`
+	// Known is whether the user watch tracks the user, so it posts when they're deleted
+	Known bool `json:"known"`
+}
+
+// Roster lists the workspace's members from Slack alongside the users the watch knows,
+// for reconciling the two. Known users Slack no longer lists keep their stored names.
+// Deactivated users are only included with includeDeleted, and bots never are.
+func (o *UserWatch) Roster(ctx context.Context, includeDeleted bool) ([]RosterUser, error) {
+	known, err := o.knownSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	roster := make(map[string]RosterUser, len(known))
+	listed := make(map[string]struct{}, len(known))
+	_, err = o.pageUsers(ctx, func(u slack.User) {
+		listed[u.ID] = struct{}{}
+		if u.ID == "" || u.IsBot || (u.Deleted && !includeDeleted) {
+			return
+		}
+		_, isKnown := known[u.ID]
+		roster[u.ID] = RosterUser{
+			ID:       u.ID,
+			Name:     u.Name,
+			RealName: cmp.Or(u.Profile.RealName, u.RealName),
+			Title:    u.Profile.Title,
+			Email:    u.Profile.Email,
+			Deleted:  u.Deleted,
+			Known:    isKnown,
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	for id, u := range known {
+		if _, ok := listed[id]; !ok {
+			roster[id] = RosterUser{ID: u.ID, Name: u.Name, RealName: u.RealName, Known: true}
+		}
+	}
+	return slices.SortedFunc(maps.Values(roster), func(a, b RosterUser) int { return strings.Compare(a.ID, b.ID) }), nil
+}
+
+// knownSnapshot copies the known users, read from disk when the watch isn't running, e.g.
+// for a command run without the bot
+func (o *UserWatch) knownSnapshot() (map[string]User, error) {
+	o.mutex.Lock()
+	known := maps.Clone(o.knownUsers)
+	o.mutex.Unlock()
+	if len(known) > 0 {
+		return known, nil
+	}
+	stored, err := o.loadUsersFromDisk()
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		stored = make(map[string]User)
+	}
+	return stored, nil
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"time"
+
+	"slackbot.arpa/tools/random"
+)
+
+const (
+	defaultPollJitter    = 0.1
+	defaultBurstDuration = 10 * time.Minute
+	maxBackoffExponent   = 6
+)
+
+// PollConfig tunes how often the user list is checked
+type PollConfig struct {
+	Interval      time.Duration // Base interval, defaults to a minute
+	Jitter        float64       // Fraction of the interval added or removed at random, defaults to 0.1
+	BurstInterval time.Duration // Interval after users were added or removed, defaults to a quarter of Interval
+	BurstDuration time.Duration // How long the faster interval lasts, defaults to 10 minutes
+	MaxInterval   time.Duration // Longest interval while backing off, defaults to 15 times Interval
+}
+
+// syncResult describes one check of the user list
+type syncResult struct {
+	Changes    int // Users added or removed
+	RateLimits int // Rate limit responses waited out while listing
+}
+
+// pollScheduler picks the delay before the next check: backing off after rate limits or
+// failures, polling faster for a while after a burst of changes, and adding jitter so
+// restarts don't line up with other clients' polls
+type pollScheduler struct {
+	config     PollConfig
+	backoff    int // Consecutive checks that were rate limited or failed
+	burstUntil time.Time
+}
+
+func newPollScheduler(c PollConfig) *pollScheduler {
+	if c.Interval <= 0 {
+		c.Interval = defaultPollInterval
+	}
+	if c.Jitter < 0 || c.Jitter >= 1 {
+		c.Jitter = defaultPollJitter
+	}
+	if c.BurstInterval <= 0 {
+		c.BurstInterval = c.Interval / 4
+	}
+	if c.BurstDuration <= 0 {
+		c.BurstDuration = defaultBurstDuration
+	}
+	if c.MaxInterval <= 0 {
+		c.MaxInterval = 15 * c.Interval
+	}
+	return &pollScheduler{config: c}
+}
+
+// next returns the delay after a check with the given result and error
+func (p *pollScheduler) next(now time.Time, result syncResult, err error) time.Duration {
+	if err != nil || result.RateLimits > 0 {
+		p.backoff = min(p.backoff+1, maxBackoffExponent)
+	} else {
+		p.backoff = 0
+	}
+	if err == nil && result.Changes > 0 {
+		p.burstUntil = now.Add(p.config.BurstDuration)
+	}
+
+	delay := p.config.Interval
+	switch {
+	case p.backoff > 0:
+		delay = min(delay<<p.backoff, p.config.MaxInterval)
+	case now.Before(p.burstUntil):
+		delay = p.config.BurstInterval
+	}
+	return p.jitter(delay)
+}
+
+func (p *pollScheduler) jitter(d time.Duration) time.Duration {
+	if p.config.Jitter == 0 {
+		return d
+	}
+	return time.Duration(float64(d) * random.Float(1-p.config.Jitter, 1+p.config.Jitter))
+}
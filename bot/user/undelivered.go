@@ -0,0 +1,322 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+)
+
+const (
+	DefaultRetryBackoff     = time.Minute
+	DefaultRetryMaxBackoff  = time.Hour
+	DefaultRetryMaxAttempts = 10
+	DefaultRetryMaxQueued   = 100
+
+	undeliveredFile    = "undelivered.json"
+	retryCheckInterval = 30 * time.Second
+)
+
+// Kinds of user notifications
+const (
+	NotificationAdded   = "added"
+	NotificationDeleted = "deleted"
+)
+
+type RetryFileConfig struct {
+	// Backoff is the wait before retrying a notification that failed to post, doubled
+	// after each failed retry, defaults to 1m
+	Backoff *time.Duration `json:"backoff" yaml:"backoff"`
+	// MaxBackoff caps the wait between retries, defaults to 1h
+	MaxBackoff *time.Duration `json:"max_backoff" yaml:"max_backoff"`
+	// MaxAttempts is how many times a notification is posted before it's dead-lettered,
+	// kept for inspection but no longer retried, defaults to 10
+	MaxAttempts *int `json:"max_attempts" yaml:"max_attempts"`
+	// MaxQueued caps the undelivered notifications kept, dropping the oldest, defaults to 100
+	MaxQueued *int `json:"max_queued" yaml:"max_queued"`
+}
+
+type RetryConfig struct {
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	MaxAttempts int
+	MaxQueued   int
+}
+
+// Notification is a user notification that failed to post, queued to be retried
+type Notification struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"` // NotificationAdded or NotificationDeleted
+	User        User      `json:"user"`
+	QueuedAt    time.Time `json:"queued_at"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	NextAttempt time.Time `json:"next_attempt"`
+	// Dead is set once MaxAttempts failed, after which it's only retried from the CLI
+	Dead bool `json:"dead"`
+}
+
+// undelivered is the persisted queue of notifications that failed to post
+type undelivered struct {
+	mu     sync.Mutex
+	config RetryConfig
+	path   string
+	queue  []Notification
+	now    func() time.Time
+}
+
+func newUndelivered(log *zap.Logger, c RetryConfig, dataDir string) *undelivered {
+	if c.Backoff <= 0 {
+		c.Backoff = DefaultRetryBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if c.MaxQueued <= 0 {
+		c.MaxQueued = DefaultRetryMaxQueued
+	}
+	u := &undelivered{config: c, now: time.Now}
+	if dataDir != "" {
+		u.path = filepath.Join(dataDir, undeliveredFile)
+	}
+	if err := u.load(); err != nil {
+		log.Warn("Failed to load undelivered notifications", zap.Error(err))
+	}
+	return u
+}
+
+// backoff is the wait after a notification's failed attempts
+func (u *undelivered) backoff(attempts int) time.Duration {
+	d := u.config.Backoff
+	for i := 1; i < attempts && d < u.config.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, u.config.MaxBackoff)
+}
+
+// failed records a failed attempt, queueing the notification when it's new. A rate limit
+// is waited out instead of backing off.
+func (u *undelivered) failed(n Notification, err error) Notification {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	if i := slices.IndexFunc(u.queue, func(q Notification) bool { return q.ID == n.ID }); i >= 0 {
+		n = u.queue[i]
+		u.queue = slices.Delete(u.queue, i, i+1)
+	} else {
+		n.QueuedAt = now
+	}
+	n.Attempts++
+	n.LastError = err.Error()
+	n.NextAttempt = now.Add(u.backoff(n.Attempts))
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		n.NextAttempt = now.Add(rateLimited.RetryAfter)
+	}
+	n.Dead = n.Attempts >= u.config.MaxAttempts
+	u.queue = append(u.queue, n)
+	if len(u.queue) > u.config.MaxQueued {
+		u.queue = slices.Delete(u.queue, 0, len(u.queue)-u.config.MaxQueued)
+	}
+	return n
+}
+
+// delivered removes a notification that was posted
+func (u *undelivered) delivered(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.queue = slices.DeleteFunc(u.queue, func(n Notification) bool { return n.ID == id })
+}
+
+// due returns the notifications to retry now
+func (u *undelivered) due() []Notification {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	var due []Notification
+	for _, n := range u.queue {
+		if !n.Dead && !n.NextAttempt.After(now) {
+			due = append(due, n)
+		}
+	}
+	return due
+}
+
+func (u *undelivered) list() []Notification {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.Clone(u.queue)
+}
+
+// requeue makes dead-lettered notifications, or the one with the id, due again with
+// their attempts reset, returning how many were requeued
+func (u *undelivered) requeue(id string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var requeued int
+	for i, n := range u.queue {
+		if (id == "" && n.Dead) || n.ID == id {
+			u.queue[i].Dead = false
+			u.queue[i].Attempts = 0
+			u.queue[i].NextAttempt = u.now()
+			requeued++
+		}
+	}
+	return requeued
+}
+
+// discard removes the notification with the id, or every dead-lettered one, returning
+// how many were removed
+func (u *undelivered) discard(id string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	before := len(u.queue)
+	u.queue = slices.DeleteFunc(u.queue, func(n Notification) bool {
+		return (id == "" && n.Dead) || n.ID == id
+	})
+	return before - len(u.queue)
+}
+
+func (u *undelivered) load() error {
+	if u.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(u.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return &errs.StorageError{Op: "read", Path: u.path, Err: err}
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err := json.Unmarshal(data, &u.queue); err != nil {
+		return &errs.StorageError{Op: "decode", Path: u.path, Err: err}
+	}
+	return nil
+}
+
+func (u *undelivered) save() error {
+	if u.path == "" {
+		return nil
+	}
+	u.mu.Lock()
+	data, err := json.MarshalIndent(u.queue, "", "  ")
+	u.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal undelivered notifications: %w", err)
+	}
+	tempFile := u.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return &errs.StorageError{Op: "write", Path: tempFile, Err: err}
+	}
+	if err := os.Rename(tempFile, u.path); err != nil {
+		return &errs.StorageError{Op: "rename", Path: u.path, Err: err}
+	}
+	return nil
+}
+
+// deliver posts a notification about a user, queueing it to retry when it fails or the
+// bot was removed from the notify channel
+func (o *UserWatch) deliver(ctx context.Context, kind string, user *slack.User) {
+	n := Notification{ID: kind + "-" + user.ID, Kind: kind, User: compactUser(*user)}
+	if !o.canNotify(ctx) {
+		o.queueUndelivered(n, errors.New("the bot was removed from the notify channel"))
+		return
+	}
+	if err := o.postNotification(ctx, kind, user); err != nil {
+		o.status.Error(err)
+		o.log.Error("send notification", zap.Error(err), zap.String("channel", o.notifyChannel))
+		o.queueUndelivered(n, err)
+		return
+	}
+	o.status.Posted()
+}
+
+func (o *UserWatch) postNotification(ctx context.Context, kind string, user *slack.User) error {
+	options := []slack.MsgOption{
+		slack.MsgOptionAttachments(o.userAttachment(kind, user)),
+		o.persona.MsgOption(),
+	}
+	if kind == NotificationDeleted {
+		options = append(options, slack.MsgOptionBlocks(eulogyBlocks(user)...))
+	}
+	_, _, err := o.slack.Client().PostMessageContext(ctx, o.notifyChannel, options...)
+	if err != nil {
+		return conversation.Error("chat.postMessage", o.notifyKind, err)
+	}
+	return nil
+}
+
+func (o *UserWatch) queueUndelivered(n Notification, err error) {
+	n = o.undelivered.failed(n, err)
+	if n.Dead {
+		o.log.Error("Gave up on a user notification, inspect it with `slackbot users undelivered list`",
+			zap.String("id", n.ID),
+			zap.Int("attempts", n.Attempts),
+			zap.Error(err))
+	} else {
+		o.log.Info("Queued a user notification to retry",
+			zap.String("id", n.ID),
+			zap.Int("attempts", n.Attempts),
+			zap.Time("next_attempt", n.NextAttempt))
+	}
+	if err := o.undelivered.save(); err != nil {
+		o.log.Warn("Failed to save undelivered notifications", zap.Error(err))
+	}
+}
+
+// retryUndelivered posts the queued notifications that are due. While the bot is
+// removed from the notify channel they wait without using up attempts.
+func (o *UserWatch) retryUndelivered(ctx context.Context) {
+	due := o.undelivered.due()
+	if len(due) == 0 || (o.presence != nil && !o.presence.Present(ctx, o.notifyChannel)) {
+		return
+	}
+	for _, n := range due {
+		if err := o.postNotification(ctx, n.Kind, n.User.slackUser()); err != nil {
+			o.status.Error(err)
+			o.queueUndelivered(n, err)
+			continue
+		}
+		o.undelivered.delivered(n.ID)
+		o.status.Posted()
+		o.log.Info("Delivered a queued user notification",
+			zap.String("id", n.ID),
+			zap.Duration("delay", time.Since(n.QueuedAt)))
+	}
+	if err := o.undelivered.save(); err != nil {
+		o.log.Warn("Failed to save undelivered notifications", zap.Error(err))
+	}
+}
+
+// Undelivered lists the notifications waiting to be retried and the dead-lettered ones
+func (o *UserWatch) Undelivered() []Notification {
+	return o.undelivered.list()
+}
+
+// RetryUndelivered makes the notification with the id, or every dead-lettered one, due
+// again with its attempts reset. It's posted on the watch's next retry check.
+func (o *UserWatch) RetryUndelivered(id string) (int, error) {
+	n := o.undelivered.requeue(id)
+	return n, o.undelivered.save()
+}
+
+// DiscardUndelivered drops the notification with the id, or every dead-lettered one
+func (o *UserWatch) DiscardUndelivered(id string) (int, error) {
+	n := o.undelivered.discard(id)
+	return n, o.undelivered.save()
+}
@@ -3,51 +3,93 @@ package user
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/slack-go/slack"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/status"
 )
 
-const watchInterval = 1 * time.Minute
+const (
+	defaultPollInterval = 1 * time.Minute
+	usersPageSize       = 200
+)
 
 type slackService interface {
 	Client() *slack.Client
 	OrgURL() string
 }
 
-// User represents a simplified Slack user for persistence
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
+// User represents a simplified Slack user, kept in memory and persisted in place of the
+// full profile
 type User struct {
 	ID       string `json:"id"`
 	Name     string `json:"name"`
 	RealName string `json:"real_name"`
 }
 
+func compactUser(u slack.User) User {
+	return User{ID: u.ID, Name: u.Name, RealName: u.RealName}
+}
+
+// slackUser expands a stored user for notifications
+func (u User) slackUser() *slack.User {
+	return &slack.User{ID: u.ID, Name: u.Name, RealName: u.RealName, Profile: slack.UserProfile{RealName: u.RealName}}
+}
+
 type Config struct {
 	NotifyChannel string
 	DataDir       string
+	Poll          PollConfig
+	Persona       persona.Config // Name and icon notifications are posted with
+	Retry         RetryConfig    // Retries of notifications that failed to post
 }
 
 type FileConfig struct {
-	NotifyChannel *string `json:"notify_channel" yaml:"notify_channel"`
+	NotifyChannel     *string        `json:"notify_channel" yaml:"notify_channel"`
+	PollInterval      *time.Duration `json:"poll_interval" yaml:"poll_interval"`
+	PollJitter        *float64       `json:"poll_jitter" yaml:"poll_jitter"`
+	BurstPollInterval *time.Duration `json:"burst_poll_interval" yaml:"burst_poll_interval"`
+	BurstDuration     *time.Duration `json:"burst_duration" yaml:"burst_duration"`
+	MaxPollInterval   *time.Duration `json:"max_poll_interval" yaml:"max_poll_interval"`
+	Persona           persona.Config `json:"persona" yaml:"persona"`
+	// Retry configures retrying notifications that failed to post, e.g. while the notify
+	// channel was archived or the bot was removed from it
+	Retry RetryFileConfig `json:"retry" yaml:"retry"`
 }
 
 type UserWatch struct {
 	log           *zap.Logger
 	slack         slackService
 	notifyChannel string
-	ticker        *time.Ticker
+	notifyKind    conversation.Kind
+	persona       persona.Config
+	poll          *pollScheduler
 	cancel        context.CancelFunc
 	mutex         sync.Mutex
-	knownUsers    map[string]*slack.User
+	knownUsers    map[string]User
 	usersFile     string
+	introFile     string
+	status        *status.Tracker
+	presence      presence
+	undelivered   *undelivered
 }
 
 func NewUserWatch(log *zap.Logger, c Config, s slackService) *UserWatch {
@@ -60,22 +102,49 @@ func NewUserWatch(log *zap.Logger, c Config, s slackService) *UserWatch {
 	}
 
 	usersFile := ""
+	introFile := ""
 	if c.DataDir != "" {
 		usersFile = filepath.Join(c.DataDir, "users.json")
+		introFile = filepath.Join(c.DataDir, "intro_posted.json")
 	}
 
 	return &UserWatch{
 		log:           log,
 		notifyChannel: c.NotifyChannel,
-		knownUsers:    make(map[string]*slack.User),
+		notifyKind:    conversation.KindFromID(c.NotifyChannel),
+		persona:       c.Persona,
+		poll:          newPollScheduler(c.Poll),
+		knownUsers:    make(map[string]User),
 		usersFile:     usersFile,
+		introFile:     introFile,
 		slack:         s,
+		undelivered:   newUndelivered(log, c.Retry, c.DataDir),
+	}
+}
+
+// SetPresence skips notifications while the bot is removed from the notify channel
+func (o *UserWatch) SetPresence(p presence) {
+	o.presence = p
+}
+
+// canNotify reports whether the bot is still in the notify channel
+func (o *UserWatch) canNotify(ctx context.Context) bool {
+	if o.presence != nil && !o.presence.Present(ctx, o.notifyChannel) {
+		o.log.Info("Bot was removed from the notify channel, skipping notification",
+			zap.String("channel", o.notifyChannel))
+		return false
 	}
+	return true
+}
+
+// SetStatusTracker sets the tracker that records the feature's activity
+func (o *UserWatch) SetStatusTracker(t *status.Tracker) {
+	o.status = t
 }
 
 func (o *UserWatch) Start(ctx context.Context) error {
 	if o.notifyChannel == "" {
-		return fmt.Errorf("notification channel is not set")
+		return &errs.ConfigError{Key: "slack-user-notify-channel", Err: errors.New("notification channel is not set")}
 	}
 
 	// Verify the channel format and existence early
@@ -86,60 +155,23 @@ func (o *UserWatch) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	o.cancel = cancel
 
-	o.ticker = time.NewTicker(watchInterval)
-
 	previousUsers, err := o.loadUsersFromDisk()
 	if err != nil {
 		o.log.Warn("Failed to load previous users from disk", zap.Error(err))
 	}
+	o.mutex.Lock()
+	maps.Copy(o.knownUsers, previousUsers)
+	o.mutex.Unlock()
 
-	if err := o.fetchAllUsers(ctx); err != nil {
-		return fmt.Errorf("fetch initial user list: %w", err)
-	}
-
+	// Diff against the persisted users to catch changes while the service was down. Without
+	// a previous list every user would be new, so only record them.
 	if len(previousUsers) > 0 {
 		o.log.Debug("Checking for user changes while service was down",
-			zap.Int("previous_count", len(previousUsers)),
-			zap.Int("current_count", len(o.knownUsers)))
-
-		var deletedUsers []slack.User
-		var addedUsers []slack.User
-		
-		// Check for deleted users
-		for id, user := range previousUsers {
-			if _, exists := o.knownUsers[id]; !exists {
-				deletedUsers = append(deletedUsers, *user)
-			}
-		}
-		
-		// Check for added users
-		for id, user := range o.knownUsers {
-			if _, exists := previousUsers[id]; !exists {
-				addedUsers = append(addedUsers, *user)
-			}
-		}
-
-		for _, user := range deletedUsers {
-			o.notifyUserDeleted(ctx, &user)
-		}
-		
-		for _, user := range addedUsers {
-			o.notifyUserAdded(ctx, &user)
-		}
-
-		if len(deletedUsers) > 0 {
-			o.log.Info("Detected users deleted while service was down",
-				zap.Int("count", len(deletedUsers)))
-		}
-		
-		if len(addedUsers) > 0 {
-			o.log.Info("Detected users added while service was down",
-				zap.Int("count", len(addedUsers)))
-		}
+			zap.Int("previous_count", len(previousUsers)))
 	}
-
-	if err := o.saveUsersToDisk(); err != nil {
-		o.log.Warn("Failed to save initial users to disk", zap.Error(err))
+	result, err := o.syncUsers(ctx, len(previousUsers) > 0, true)
+	if err != nil {
+		return fmt.Errorf("fetch initial user list: %w", errs.NewSlackAPIError("users.list", err))
 	}
 
 	o.sendStartupMessage(ctx)
@@ -147,13 +179,27 @@ func (o *UserWatch) Start(ctx context.Context) error {
 	o.log.Debug("UserWatch service started, monitoring for user additions and deletions")
 
 	go func() {
-		defer o.ticker.Stop()
+		timer := time.NewTimer(o.poll.next(time.Now(), result, nil))
+		defer timer.Stop()
+		retry := time.NewTicker(retryCheckInterval)
+		defer retry.Stop()
 		for {
 			select {
-			case <-o.ticker.C:
-				if err := o.checkForUserChanges(ctx); err != nil {
+			case <-retry.C:
+				o.retryUndelivered(ctx)
+			case <-timer.C:
+				o.status.Event()
+				result, err := o.checkForUserChanges(ctx)
+				if err != nil {
+					o.status.Error(err)
 					o.log.Error("Error checking for user changes", zap.Error(err))
 				}
+				delay := o.poll.next(time.Now(), result, err)
+				o.log.Debug("Next user check scheduled",
+					zap.Duration("delay", delay),
+					zap.Int("rate_limits", result.RateLimits),
+					zap.Int("changes", result.Changes))
+				timer.Reset(delay)
 			case <-ctx.Done():
 				return
 			}
@@ -171,37 +217,47 @@ func (o *UserWatch) Stop(ctx context.Context) error {
 	if o.cancel != nil {
 		o.cancel()
 	}
-	if o.ticker != nil {
-		o.ticker.Stop()
-	}
 	return nil
 }
 
-// fetchAllUsers gets all users from the Slack workspace and stores them in a map
-func (o *UserWatch) fetchAllUsers(ctx context.Context) error {
-	o.mutex.Lock()
-	defer o.mutex.Unlock()
-
-	o.log.Debug("Fetching all users from Slack")
-
-	var users []slack.User
-	var err error
-
-	users, err = o.slack.Client().GetUsersContext(ctx)
-	if err != nil {
-		return err
-	}
+// listUsers streams valid users from Slack a page at a time, so the full list is never
+// held in memory, waiting out rate limits between pages. It returns how many rate limit
+// responses it waited out.
+func (o *UserWatch) listUsers(ctx context.Context, fn func(slack.User)) (int, error) {
+	return o.pageUsers(ctx, func(user slack.User) {
+		if isValidUser(user) {
+			fn(user)
+		}
+	})
+}
 
-	// Update our known users map
-	for _, user := range users {
-		if !isValidUser(user) {
+// pageUsers streams every user from Slack, including bots and deactivated users
+func (o *UserWatch) pageUsers(ctx context.Context, fn func(slack.User)) (int, error) {
+	rateLimits := 0
+	p := o.slack.Client().GetUsersPaginated(slack.GetUsersOptionLimit(usersPageSize))
+	for {
+		var err error
+		p, err = p.Next(ctx)
+		if p.Done(err) {
+			return rateLimits, nil
+		}
+		var rateLimited *slack.RateLimitedError
+		if errors.As(err, &rateLimited) {
+			rateLimits++
+			select {
+			case <-ctx.Done():
+				return rateLimits, ctx.Err()
+			case <-time.After(rateLimited.RetryAfter):
+			}
 			continue
 		}
-		o.knownUsers[user.ID] = &user
+		if err != nil {
+			return rateLimits, err
+		}
+		for _, user := range p.Users {
+			fn(user)
+		}
 	}
-
-	o.log.Debug("Fetched all users", zap.Int("count", len(o.knownUsers)))
-	return nil
 }
 
 func isValidUser(user slack.User) bool {
@@ -209,150 +265,98 @@ func isValidUser(user slack.User) bool {
 }
 
 // checkForUserChanges compares the current user list with our stored list for additions and deletions
-func (o *UserWatch) checkForUserChanges(ctx context.Context) error {
+func (o *UserWatch) checkForUserChanges(ctx context.Context) (syncResult, error) {
 	o.log.Debug("Checking for user changes")
+	return o.syncUsers(ctx, true, false)
+}
 
+// syncUsers diffs the workspace's users against the known users page by page, keeping
+// only the IDs seen and the changes in memory. Known users are only updated once the
+// whole list was read, so a failed listing never reports users as deleted.
+func (o *UserWatch) syncUsers(ctx context.Context, notify, save bool) (syncResult, error) {
 	o.mutex.Lock()
-	currentUsers := make(map[string]*slack.User)
-	maps.Copy(currentUsers, o.knownUsers)
+	seen := make(map[string]struct{}, len(o.knownUsers))
 	o.mutex.Unlock()
 
-	users, err := o.slack.Client().GetUsersContext(ctx)
-	if err != nil {
-		return err
-	}
-
-	newUserMap := make(map[string]slack.User)
-	for _, user := range users {
-		if !isValidUser(user) {
-			continue
-		}
-		newUserMap[user.ID] = user
-	}
-
-	// Find deleted users
-	var deletedUsers []slack.User
-	for id, user := range currentUsers {
-		if _, exists := newUserMap[id]; !exists {
-			deletedUsers = append(deletedUsers, *user)
+	var addedUsers, updatedUsers []User
+	rateLimits, err := o.listUsers(ctx, func(user slack.User) {
+		seen[user.ID] = struct{}{}
+		current := compactUser(user)
+		o.mutex.Lock()
+		known, exists := o.knownUsers[user.ID]
+		o.mutex.Unlock()
+		switch {
+		case !exists:
+			addedUsers = append(addedUsers, current)
+		case known != current:
+			updatedUsers = append(updatedUsers, current)
 		}
+	})
+	result := syncResult{RateLimits: rateLimits}
+	if err != nil {
+		return result, err
 	}
 
-	// Find added users
-	var addedUsers []slack.User
-	for id, user := range newUserMap {
-		if _, exists := currentUsers[id]; !exists {
-			addedUsers = append(addedUsers, user)
+	var deletedUsers []User
+	o.mutex.Lock()
+	for id, user := range o.knownUsers {
+		if _, exists := seen[id]; !exists {
+			deletedUsers = append(deletedUsers, user)
+			delete(o.knownUsers, id)
 		}
 	}
-
-	// Check for modified users
-	hasChanges := len(deletedUsers) > 0 || len(addedUsers) > 0
-	if !hasChanges {
-		for id, newUser := range newUserMap {
-			if oldUser, exists := currentUsers[id]; exists &&
-				(oldUser.Name != newUser.Name ||
-					oldUser.RealName != newUser.RealName) {
-				hasChanges = true
-				break
-			}
-		}
+	for _, user := range addedUsers {
+		o.knownUsers[user.ID] = user
 	}
-
-	// Update our known users map
-	o.mutex.Lock()
-	o.knownUsers = make(map[string]*slack.User)
-	for id, user := range newUserMap {
-		userCopy := user // Create a copy to avoid reference issues
-		o.knownUsers[id] = &userCopy
+	for _, user := range updatedUsers {
+		o.knownUsers[user.ID] = user
 	}
+	count := len(o.knownUsers)
 	o.mutex.Unlock()
 
-	// Send notifications for deleted users
-	for _, user := range deletedUsers {
-		o.notifyUserDeleted(ctx, &user)
-	}
-
-	// Send notifications for added users
-	for _, user := range addedUsers {
-		o.notifyUserAdded(ctx, &user)
-	}
-
-	if len(deletedUsers) > 0 {
-		o.log.Info("Detected deleted users.", zap.Int("count", len(deletedUsers)))
-	}
+	o.log.Debug("Synced users", zap.Int("count", count))
 
-	if len(addedUsers) > 0 {
-		o.log.Info("Detected added users.", zap.Int("count", len(addedUsers)))
+	if notify {
+		for _, user := range deletedUsers {
+			o.notifyUserDeleted(ctx, user.slackUser())
+		}
+		for _, user := range addedUsers {
+			o.notifyUserAdded(ctx, user.slackUser())
+		}
+		if len(deletedUsers) > 0 {
+			o.log.Info("Detected deleted users.", zap.Int("count", len(deletedUsers)))
+		}
+		if len(addedUsers) > 0 {
+			o.log.Info("Detected added users.", zap.Int("count", len(addedUsers)))
+		}
 	}
 
-	if hasChanges {
+	if save || len(deletedUsers) > 0 || len(addedUsers) > 0 || len(updatedUsers) > 0 {
 		o.log.Debug("Changes detected in user list, saving to disk.")
 		if err := o.saveUsersToDisk(); err != nil {
 			o.log.Warn("Failed to save users to disk.", zap.Error(err))
 		}
 	}
 
-	return nil
+	result.Changes = len(addedUsers) + len(deletedUsers)
+	return result, nil
 }
 
 // notifyUserAdded sends a notification to the configured channel about a new user
 func (o *UserWatch) notifyUserAdded(ctx context.Context, user *slack.User) {
 	o.log.Info("User added.", zap.String("user_id", user.ID), zap.String("user_name", user.RealName))
-
-	var identity string
-	if user.RealName != "" && user.RealName != user.Name {
-		identity = fmt.Sprintf("*%s* (%s)", user.RealName, user.Name)
-	} else {
-		identity = fmt.Sprintf("*%s*", user.Name)
-	}
-	userTitle := "User"
-	if user.IsBot {
-		userTitle = "Bot"
-	}
-
-	message := fmt.Sprintf("%s %s has been added to the Slack organization.", userTitle, identity)
-	profileLink := fmt.Sprintf("%steam/%s", o.slack.OrgURL(), user.ID)
-	actions := []slack.AttachmentAction{
-		{
-			Type: "button",
-			Text: "View Profile",
-			URL:  profileLink,
-		},
-	}
-	if user.Profile.RealName != "" && !user.IsBot {
-		actions = append(actions, slack.AttachmentAction{
-			Type: "button",
-			Text: "View LinkedIn",
-			URL:  linkedinURL(user.Profile.RealName),
-		})
-	}
-	attachment := slack.Attachment{
-		Color:      "#36a64f", // Green color
-		Title:      fmt.Sprintf(":wave: %s Added", userTitle),
-		Text:       message,
-		Footer:     fmt.Sprintf("%s ID: %s; Monitoring %d total users", userTitle, user.ID, len(o.knownUsers)),
-		FooterIcon: "https://platform.slack-edge.com/img/default_application_icon.png",
-		Ts:         json.Number(fmt.Sprintf("%d", time.Now().Unix())),
-		Actions:    actions,
-	}
-
-	_, _, err := o.slack.Client().PostMessageContext(
-		ctx,
-		o.notifyChannel,
-		slack.MsgOptionAttachments(attachment),
-		slack.MsgOptionAsUser(true),
-	)
-	if err != nil {
-		o.log.Error("send notification", zap.Error(err), zap.String("channel", o.notifyChannel))
-	}
+	o.deliver(ctx, NotificationAdded, user)
 }
 
 // TODO: batch attachments together in single message for multiple users
 // notifyUserDeleted sends a notification to the configured channel about a deleted user
 func (o *UserWatch) notifyUserDeleted(ctx context.Context, user *slack.User) {
 	o.log.Info("User deleted.", zap.String("user_id", user.ID), zap.String("user_name", user.RealName))
+	o.deliver(ctx, NotificationDeleted, user)
+}
 
+// userAttachment describes a user who was added or deleted, with links to their profile
+func (o *UserWatch) userAttachment(kind string, user *slack.User) slack.Attachment {
 	var identity string
 	if user.RealName != "" && user.RealName != user.Name {
 		identity = fmt.Sprintf("*%s* (%s)", user.RealName, user.Name)
@@ -364,7 +368,6 @@ func (o *UserWatch) notifyUserDeleted(ctx context.Context, user *slack.User) {
 		userTitle = "Bot"
 	}
 
-	message := fmt.Sprintf("%s %s has been deleted from the Slack organization.", userTitle, identity)
 	profileLink := fmt.Sprintf("%steam/%s", o.slack.OrgURL(), user.ID)
 	actions := []slack.AttachmentAction{
 		{
@@ -380,62 +383,55 @@ func (o *UserWatch) notifyUserDeleted(ctx context.Context, user *slack.User) {
 			URL:  linkedinURL(user.Profile.RealName),
 		})
 	}
+	o.mutex.Lock()
+	monitoring := len(o.knownUsers)
+	o.mutex.Unlock()
 	attachment := slack.Attachment{
-		Color:      "#FF5733", // Red-orange color
-		Title:      fmt.Sprintf(":rip: %s Deleted", userTitle),
-		Text:       message,
-		Footer:     fmt.Sprintf("%s ID: %s; Monitoring %d remaining users", userTitle, user.ID, len(o.knownUsers)),
+		Color:      "#36a64f", // Green color
+		Title:      fmt.Sprintf(":wave: %s Added", userTitle),
+		Text:       fmt.Sprintf("%s %s has been added to the Slack organization.", userTitle, identity),
+		Footer:     fmt.Sprintf("%s ID: %s; Monitoring %d total users", userTitle, user.ID, monitoring),
 		FooterIcon: "https://platform.slack-edge.com/img/default_application_icon.png",
 		Ts:         json.Number(fmt.Sprintf("%d", time.Now().Unix())),
 		Actions:    actions,
 	}
-
-	_, _, err := o.slack.Client().PostMessageContext(
-		ctx,
-		o.notifyChannel,
-		slack.MsgOptionAttachments(attachment),
-		slack.MsgOptionAsUser(true),
-	)
-	if err != nil {
-		o.log.Error("send notification", zap.Error(err), zap.String("channel", o.notifyChannel))
+	if kind == NotificationDeleted {
+		attachment.Color = "#FF5733" // Red-orange color
+		attachment.Title = fmt.Sprintf(":rip: %s Deleted", userTitle)
+		attachment.Text = fmt.Sprintf("%s %s has been deleted from the Slack organization.", userTitle, identity)
+		attachment.Footer = fmt.Sprintf("%s ID: %s; Monitoring %d remaining users", userTitle, user.ID, monitoring)
 	}
+	return attachment
 }
 
 func linkedinURL(name string) string {
 	return fmt.Sprintf("https://www.linkedin.com/search/results/people/?keywords=%s", url.PathEscape(name))
 }
 
+// introRecord is persisted after the startup notification is posted so it is only sent once
+// per notification channel
+type introRecord struct {
+	Channel  string    `json:"channel"`
+	TS       string    `json:"ts"`
+	PostedAt time.Time `json:"posted_at"`
+}
+
 // sendStartupMessage sends a notification to the configured channel to confirm the bot is running
-// but only if the bot hasn't posted any messages to the channel before
+// but only if it hasn't already been posted to that channel. Without a data directory the
+// notification is sent on every startup.
 func (o *UserWatch) sendStartupMessage(ctx context.Context) {
-	authTest, err := o.slack.Client().AuthTestContext(ctx)
-	if err != nil {
-		o.log.Error("Failed to get bot identity, sending notification anyway", zap.Error(err))
-	} else {
-		botUserID := authTest.UserID
-		o.log.Debug("Bot identity", zap.String("user_id", botUserID), zap.String("bot_name", authTest.User))
-
-		if !o.validateChannel(ctx) {
-			o.log.Error("Skipping startup notification due to channel validation failure")
-			return
-		}
+	if !o.canNotify(ctx) {
+		return
+	}
+	if o.introPosted() {
+		o.log.Debug("Startup notification already posted to the channel, skipping",
+			zap.String("channel", o.notifyChannel))
+		return
+	}
 
-		history, err := o.slack.Client().GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
-			ChannelID: o.notifyChannel,
-			Limit:     100,
-		})
-		if err != nil {
-			o.log.Error("Failed to get channel history, sending notification anyway",
-				zap.Error(err),
-				zap.String("channel", o.notifyChannel))
-		} else {
-			for _, msg := range history.Messages {
-				if (msg.User == botUserID || (msg.BotID != "" && msg.Username == authTest.User)) && isIntroMessage(msg) {
-					o.log.Debug("Bot has already posted messages to the channel, skipping startup notification")
-					return
-				}
-			}
-		}
+	if !o.validateChannel(ctx) {
+		o.log.Error("Skipping startup notification due to channel validation failure")
+		return
 	}
 
 	o.log.Info("Sending startup notification", zap.String("channel", o.notifyChannel))
@@ -449,34 +445,65 @@ func (o *UserWatch) sendStartupMessage(ctx context.Context) {
 		Ts:         json.Number(fmt.Sprintf("%d", time.Now().Unix())),
 	}
 
-	_, _, err = o.slack.Client().PostMessageContext(
+	_, ts, err := o.slack.Client().PostMessageContext(
 		ctx,
 		o.notifyChannel,
 		slack.MsgOptionAttachments(attachment),
-		slack.MsgOptionAsUser(true),
+		o.persona.MsgOption(),
 	)
 	if err != nil {
-		// Log the channel ID for debugging purposes
-		o.log.Error("Failed to send startup notification - check that the channel ID is correct and in the format 'C0123456789'",
-			zap.Error(err),
+		o.log.Error("Failed to send startup notification",
+			zap.Error(conversation.Error("chat.postMessage", o.notifyKind, err)),
 			zap.String("channel", o.notifyChannel))
+		return
+	}
 
-		// The channel ID is likely incorrect. Let's output some recommendations.
-		if err.Error() == "channel_not_found" {
-			o.log.Warn("The channel may not exist or the bot may not have been added to the channel.",
-				zap.String("channel", o.notifyChannel),
-				zap.String("recommendation", "Make sure to invite the bot to the channel or check the channel ID"))
-		}
+	if err := o.saveIntroRecord(introRecord{Channel: o.notifyChannel, TS: ts, PostedAt: time.Now()}); err != nil {
+		o.log.Warn("Failed to record startup notification", zap.Error(err))
 	}
 }
 
-func isIntroMessage(msg slack.Message) bool {
-	for _, a := range msg.Attachments {
-		if a.Title == "Status" && strings.Contains(a.Text, "user monitoring feature") {
-			return true
+// introPosted reports whether the startup notification was already posted to the current channel
+func (o *UserWatch) introPosted() bool {
+	if o.introFile == "" {
+		return false
+	}
+
+	data, err := os.ReadFile(o.introFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			o.log.Warn("Failed to read startup notification record", zap.String("file", o.introFile), zap.Error(err))
 		}
+		return false
+	}
+
+	var record introRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		o.log.Warn("Failed to decode startup notification record", zap.String("file", o.introFile), zap.Error(err))
+		return false
 	}
-	return false
+	return record.Channel == o.notifyChannel
+}
+
+// saveIntroRecord writes the startup notification record
+func (o *UserWatch) saveIntroRecord(record introRecord) error {
+	if o.introFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal intro record: %w", err)
+	}
+
+	tempFile := o.introFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return &errs.StorageError{Op: "write", Path: tempFile, Err: err}
+	}
+	if err := os.Rename(tempFile, o.introFile); err != nil {
+		return &errs.StorageError{Op: "rename", Path: o.introFile, Err: err}
+	}
+	return nil
 }
 
 // saveUsersToDisk saves the current known users to disk
@@ -489,14 +516,7 @@ func (o *UserWatch) saveUsersToDisk() error {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 
-	users := make([]User, 0, len(o.knownUsers))
-	for _, user := range o.knownUsers {
-		users = append(users, User{
-			ID:       user.ID,
-			Name:     user.Name,
-			RealName: user.RealName,
-		})
-	}
+	users := slices.SortedFunc(maps.Values(o.knownUsers), func(a, b User) int { return strings.Compare(a.ID, b.ID) })
 
 	tempFile := o.usersFile + ".tmp"
 
@@ -506,18 +526,18 @@ func (o *UserWatch) saveUsersToDisk() error {
 	}
 
 	if err := os.WriteFile(tempFile, data, 0600); err != nil {
-		return fmt.Errorf("write temp file: %w", err)
+		return &errs.StorageError{Op: "write", Path: tempFile, Err: err}
 	}
 
 	if err := os.Rename(tempFile, o.usersFile); err != nil {
-		return fmt.Errorf("rename temp file: %w", err)
+		return &errs.StorageError{Op: "rename", Path: o.usersFile, Err: err}
 	}
 
 	o.log.Debug("Saved users to disk", zap.String("file", o.usersFile), zap.Int("count", len(users)))
 	return nil
 }
 
-func (o *UserWatch) loadUsersFromDisk() (map[string]*slack.User, error) {
+func (o *UserWatch) loadUsersFromDisk() (map[string]User, error) {
 	if o.usersFile == "" {
 		o.log.Debug("No users file configured, skipping load")
 		return nil, nil
@@ -530,21 +550,17 @@ func (o *UserWatch) loadUsersFromDisk() (map[string]*slack.User, error) {
 
 	data, err := os.ReadFile(o.usersFile)
 	if err != nil {
-		return nil, fmt.Errorf("read users file: %w", err)
+		return nil, &errs.StorageError{Op: "read", Path: o.usersFile, Err: err}
 	}
 
 	var users []User
 	if err := json.Unmarshal(data, &users); err != nil {
-		return nil, fmt.Errorf("unmarshal users: %w", err)
+		return nil, &errs.StorageError{Op: "decode", Path: o.usersFile, Err: err}
 	}
 
-	result := make(map[string]*slack.User)
+	result := make(map[string]User, len(users))
 	for _, user := range users {
-		result[user.ID] = &slack.User{
-			ID:       user.ID,
-			Name:     user.Name,
-			RealName: user.RealName,
-		}
+		result[user.ID] = user
 	}
 
 	o.log.Debug("Loaded users from disk", zap.String("file", o.usersFile), zap.Int("count", len(result)))
@@ -552,23 +568,28 @@ func (o *UserWatch) loadUsersFromDisk() (map[string]*slack.User, error) {
 }
 
 func (o *UserWatch) validateChannel(ctx context.Context) bool {
-	if len(o.notifyChannel) < 9 || !strings.HasPrefix(o.notifyChannel, "C") {
-		o.log.Warn("Channel ID format may be invalid - should typically be 'C' followed by alphanumeric chars",
+	if !conversation.ValidID(o.notifyChannel) {
+		o.log.Warn("Channel ID format may be invalid - should be 'C', 'G' or 'D' followed by alphanumeric chars",
 			zap.String("channel", o.notifyChannel))
 	}
 
-	_, err := o.slack.Client().GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{
-		ChannelID:     o.notifyChannel,
-		IncludeLocale: false,
-	})
+	kind, info, err := conversation.Lookup(ctx, o.slack.Client(), o.notifyChannel)
 	if err != nil {
 		o.log.Error("Channel not found or not accessible - check the channel ID and bot permissions",
 			zap.Error(err),
 			zap.String("channel", o.notifyChannel),
-			zap.String("recommendation", "Make sure to invite the bot to the channel"))
+			zap.String("kind", string(kind)))
 		return false
 	}
+	o.notifyKind = kind
+
+	if !info.IsMember && kind != conversation.DM {
+		o.log.Warn("The bot isn't a member of the notification channel",
+			zap.String("channel", o.notifyChannel),
+			zap.String("kind", string(kind)),
+			zap.String("recommendation", "Invite the bot to the "+string(kind)))
+	}
 
-	o.log.Debug("Channel validation successful", zap.String("channel", o.notifyChannel))
+	o.log.Debug("Channel validation successful", zap.String("channel", o.notifyChannel), zap.String("kind", string(kind)))
 	return true
 }
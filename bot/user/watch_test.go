@@ -1,11 +1,18 @@
 package user
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/slack-go/slack"
 	"go.uber.org/zap"
@@ -14,12 +21,11 @@ import (
 // mockSlackService implements slackService interface for testing
 type mockSlackService struct {
 	orgURL string
+	client *slack.Client
 }
 
 func (m *mockSlackService) Client() *slack.Client {
-	// This is a bit tricky since slack.Client methods aren't easily mockable
-	// In real implementation, we'd use an interface for slack operations
-	return nil
+	return m.client
 }
 
 func (m *mockSlackService) OrgURL() string {
@@ -156,7 +162,7 @@ func TestUserWatch_SaveAndLoadUsers(t *testing.T) {
 	watch := NewUserWatch(logger, config, mockSlack)
 
 	// Add some test users
-	testUsers := map[string]*slack.User{
+	testUsers := map[string]User{
 		"U1234567890": {
 			ID:       "U1234567890",
 			Name:     "testuser1",
@@ -411,4 +417,280 @@ func BenchmarkLinkedinURL(b *testing.B) {
 			linkedinURL(name)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestUserWatch_IntroRecord(t *testing.T) {
+	tempDir := t.TempDir()
+	watch := NewUserWatch(zap.NewNop(), Config{NotifyChannel: "C1234567890", DataDir: tempDir}, &mockSlackService{})
+
+	if watch.introPosted() {
+		t.Fatal("introPosted() should be false before any record is saved")
+	}
+
+	if err := watch.saveIntroRecord(introRecord{Channel: "C1234567890", TS: "123.456"}); err != nil {
+		t.Fatalf("saveIntroRecord() error = %v", err)
+	}
+	if !watch.introPosted() {
+		t.Error("introPosted() should be true after saving a record for the channel")
+	}
+
+	// A different notify channel gets its own intro
+	watch.notifyChannel = "C0987654321"
+	if watch.introPosted() {
+		t.Error("introPosted() should be false when the notify channel changed")
+	}
+
+	noDataDir := NewUserWatch(zap.NewNop(), Config{NotifyChannel: "C1234567890"}, &mockSlackService{})
+	if noDataDir.introPosted() {
+		t.Error("introPosted() should be false without a data directory")
+	}
+}
+
+func TestUserWatch_SyncUsers(t *testing.T) {
+	var posts []string
+	failSecondPage := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/chat.postMessage":
+			posts = append(posts, r.FormValue("attachments"))
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.0"}`))
+		case r.FormValue("cursor") == "":
+			_, _ = w.Write([]byte(`{"ok": true, "members": [
+				{"id": "U2", "name": "renamed", "real_name": "User Two"},
+				{"id": "U3", "name": "new", "real_name": "New User"}
+			], "response_metadata": {"next_cursor": "page2"}}`))
+		case failSecondPage:
+			_, _ = w.Write([]byte(`{"ok": false, "error": "internal_error"}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok": true, "members": [
+				{"id": "B1", "name": "bot", "is_bot": true},
+				{"id": "U5", "name": "gone", "deleted": true}
+			]}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := &mockSlackService{orgURL: "https://test.slack.com/", client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	watch := NewUserWatch(zap.NewNop(), Config{NotifyChannel: "C1234567890", DataDir: t.TempDir()}, s)
+	watch.knownUsers = map[string]User{
+		"U1": {ID: "U1", Name: "old"},
+		"U2": {ID: "U2", Name: "two", RealName: "User Two"},
+	}
+
+	failSecondPage = true
+	if _, err := watch.syncUsers(context.Background(), true, false); err == nil {
+		t.Fatal("syncUsers() should fail when a page fails")
+	}
+	if len(watch.knownUsers) != 2 || len(posts) != 0 {
+		t.Fatalf("a failed listing should change nothing, got %v and %d posts", watch.knownUsers, len(posts))
+	}
+
+	failSecondPage = false
+	result, err := watch.syncUsers(context.Background(), true, false)
+	if err != nil {
+		t.Fatalf("syncUsers() error = %v", err)
+	}
+	if result.Changes != 2 {
+		t.Errorf("syncUsers() changes = %d, want 2", result.Changes)
+	}
+	want := map[string]User{
+		"U2": {ID: "U2", Name: "renamed", RealName: "User Two"},
+		"U3": {ID: "U3", Name: "new", RealName: "New User"},
+	}
+	if len(watch.knownUsers) != len(want) || watch.knownUsers["U2"] != want["U2"] || watch.knownUsers["U3"] != want["U3"] {
+		t.Errorf("knownUsers = %v, want %v", watch.knownUsers, want)
+	}
+	if len(posts) != 2 || !strings.Contains(posts[0], "Deleted") || !strings.Contains(posts[1], "Added") {
+		t.Errorf("expected a deleted and an added notification, got %v", posts)
+	}
+
+	saved, err := watch.loadUsersFromDisk()
+	if err != nil || len(saved) != 2 {
+		t.Errorf("expected changes to be saved, got %v, %v", saved, err)
+	}
+}
+
+func TestPollScheduler(t *testing.T) {
+	p := newPollScheduler(PollConfig{Interval: time.Minute, BurstDuration: 5 * time.Minute, MaxInterval: 5 * time.Minute})
+	now := time.Now()
+
+	if got := p.next(now, syncResult{}, nil); got != time.Minute {
+		t.Errorf("next() = %v, want the base interval", got)
+	}
+	if got := p.next(now, syncResult{RateLimits: 1}, nil); got != 2*time.Minute {
+		t.Errorf("next() after a rate limit = %v, want 2m", got)
+	}
+	if got := p.next(now, syncResult{}, errors.New("boom")); got != 4*time.Minute {
+		t.Errorf("next() after a failure = %v, want 4m", got)
+	}
+	if got := p.next(now, syncResult{RateLimits: 3}, nil); got != 5*time.Minute {
+		t.Errorf("next() = %v, want backoff capped at 5m", got)
+	}
+
+	if got := p.next(now, syncResult{Changes: 3}, nil); got != 15*time.Second {
+		t.Errorf("next() after changes = %v, want the burst interval", got)
+	}
+	if got := p.next(now.Add(4*time.Minute), syncResult{}, nil); got != 15*time.Second {
+		t.Errorf("next() during the burst = %v, want the burst interval", got)
+	}
+	if got := p.next(now.Add(6*time.Minute), syncResult{}, nil); got != time.Minute {
+		t.Errorf("next() after the burst = %v, want the base interval", got)
+	}
+
+	p = newPollScheduler(PollConfig{Interval: time.Minute, Jitter: 0.1})
+	for range 20 {
+		if got := p.next(now, syncResult{}, nil); got < 54*time.Second || got > 66*time.Second {
+			t.Fatalf("next() = %v, want within 10%% of the interval", got)
+		}
+	}
+}
+
+func TestUserWatch_Roster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok": true, "members": [
+			{"id": "U2", "name": "two", "real_name": "User Two", "profile": {"real_name": "User Two", "title": "Engineer", "email": "two@example.com"}},
+			{"id": "U3", "name": "new", "real_name": "New User"},
+			{"id": "B1", "name": "bot", "is_bot": true},
+			{"id": "U5", "name": "gone", "deleted": true}
+		]}`))
+	}))
+	defer srv.Close()
+
+	s := &mockSlackService{client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	dir := t.TempDir()
+	stored := NewUserWatch(zap.NewNop(), Config{DataDir: dir}, s)
+	stored.knownUsers = map[string]User{
+		"U1": {ID: "U1", Name: "old", RealName: "Old User"},
+		"U2": {ID: "U2", Name: "two", RealName: "User Two"},
+		"U5": {ID: "U5", Name: "gone"},
+	}
+	if err := stored.saveUsersToDisk(); err != nil {
+		t.Fatalf("saveUsersToDisk() error = %v", err)
+	}
+
+	// A watch that isn't running reads the known users from disk
+	watch := NewUserWatch(zap.NewNop(), Config{DataDir: dir}, s)
+	roster, err := watch.Roster(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Roster() error = %v", err)
+	}
+	want := []RosterUser{
+		{ID: "U1", Name: "old", RealName: "Old User", Known: true},
+		{ID: "U2", Name: "two", RealName: "User Two", Title: "Engineer", Email: "two@example.com", Known: true},
+		{ID: "U3", Name: "new", RealName: "New User"},
+	}
+	if !slices.Equal(roster, want) {
+		t.Errorf("Roster() = %+v, want %+v", roster, want)
+	}
+
+	roster, err = watch.Roster(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Roster() error = %v", err)
+	}
+	if len(roster) != 4 || roster[3] != (RosterUser{ID: "U5", Name: "gone", Deleted: true, Known: true}) {
+		t.Errorf("Roster() with deleted users = %+v, want U5 marked deleted", roster)
+	}
+}
+
+func TestUserWatch_Undelivered(t *testing.T) {
+	failing := true
+	var posts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if failing {
+			_, _ = w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`))
+			return
+		}
+		posts++
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.0"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	s := &mockSlackService{orgURL: "https://test.slack.com/", client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	config := Config{NotifyChannel: "C1234567890", DataDir: dir, Retry: RetryConfig{Backoff: time.Minute, MaxBackoff: time.Hour, MaxAttempts: 3}}
+	watch := NewUserWatch(zap.NewNop(), config, s)
+	now := time.Unix(1700000000, 0)
+	watch.undelivered.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	watch.notifyUserDeleted(ctx, &slack.User{ID: "U1", Name: "gone"})
+	queued := watch.Undelivered()
+	if len(queued) != 1 || queued[0].ID != "deleted-U1" || queued[0].Attempts != 1 || !queued[0].NextAttempt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Undelivered() = %+v, want the failed notification queued for a minute", queued)
+	}
+
+	// Not due yet, then failing again backs off twice as long and gives up at the cap
+	watch.retryUndelivered(ctx)
+	if watch.Undelivered()[0].Attempts != 1 {
+		t.Error("retryUndelivered() retried a notification before it was due")
+	}
+	now = now.Add(time.Minute)
+	watch.retryUndelivered(ctx)
+	if n := watch.Undelivered()[0]; n.Attempts != 2 || !n.NextAttempt.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("after a failed retry = %+v, want the backoff doubled", n)
+	}
+	now = now.Add(2 * time.Minute)
+	watch.retryUndelivered(ctx)
+	if n := watch.Undelivered()[0]; !n.Dead {
+		t.Errorf("after %d attempts = %+v, want it dead-lettered", n.Attempts, n)
+	}
+
+	// Dead letters survive a restart and are only retried when requeued
+	watch = NewUserWatch(zap.NewNop(), config, s)
+	watch.undelivered.now = func() time.Time { return now }
+	failing = false
+	now = now.Add(24 * time.Hour)
+	watch.retryUndelivered(ctx)
+	if posts != 0 {
+		t.Error("retryUndelivered() posted a dead-lettered notification")
+	}
+	if n, err := watch.RetryUndelivered(""); n != 1 || err != nil {
+		t.Fatalf("RetryUndelivered() = %d, %v", n, err)
+	}
+	watch.retryUndelivered(ctx)
+	if posts != 1 || len(watch.Undelivered()) != 0 {
+		t.Errorf("posts = %d, undelivered = %+v, want the requeued notification delivered", posts, watch.Undelivered())
+	}
+	if _, err := os.Stat(filepath.Join(dir, undeliveredFile)); err != nil {
+		t.Errorf("undelivered notifications weren't saved: %v", err)
+	}
+}
+
+func TestUserWatch_Eulogy(t *testing.T) {
+	var posted url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		posted = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "2.0"}`))
+	}))
+	defer srv.Close()
+
+	s := &mockSlackService{orgURL: "https://test.slack.com/", client: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))}
+	watch := NewUserWatch(zap.NewNop(), Config{NotifyChannel: "C1"}, s)
+	submit := func(text, metadata string) *slack.ViewSubmissionResponse {
+		var callback slack.InteractionCallback
+		callback.User.ID = "U2"
+		callback.View.PrivateMetadata = metadata
+		callback.View.State = &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+			eulogyBlockID: {eulogyInputID: {Value: text}},
+		}}
+		return watch.HandleEulogySubmission(context.Background(), callback)
+	}
+
+	if response := submit("  ", `{"channel": "C1", "ts": "1.0", "name": "Ada"}`); response == nil || response.Errors[eulogyBlockID] == "" {
+		t.Errorf("empty eulogy response = %+v, want an error keeping the modal open", response)
+	}
+	if response := submit("kind words", ""); response == nil || posted != nil {
+		t.Errorf("eulogy without metadata = %+v, posted %v, want an error and nothing posted", response, posted)
+	}
+	if response := submit("kind words", `{"channel": "C1", "ts": "1.0", "name": "Ada"}`); response != nil {
+		t.Fatalf("HandleEulogySubmission() = %+v, want the modal closed", response)
+	}
+	if posted.Get("thread_ts") != "1.0" || !strings.Contains(posted.Get("text"), "<@U2> remembers Ada") {
+		t.Errorf("posted %v, want the eulogy in the notification's thread", posted)
+	}
+}
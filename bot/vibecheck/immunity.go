@@ -0,0 +1,157 @@
+package vibecheck
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	immunityFile     = "vibecheck_immunity.json"
+	defaultMaxTokens = 3
+)
+
+// ImmunityConfig controls one-time immunity tokens that are spent automatically to
+// cancel a failed vibecheck
+type ImmunityConfig struct {
+	// StreakLength is the number of consecutive passes that earns a token; unset or 0
+	// disables earning tokens from streaks
+	StreakLength *int `json:"streak_length" yaml:"streak_length"`
+	// MaxTokens caps how many tokens a user can hold, defaults to 3
+	MaxTokens *int `json:"max_tokens" yaml:"max_tokens"`
+}
+
+func (c ImmunityConfig) streakLength() int {
+	if c.StreakLength == nil {
+		return 0
+	}
+	return max(*c.StreakLength, 0)
+}
+
+func (c ImmunityConfig) maxTokens() int {
+	if c.MaxTokens == nil || *c.MaxTokens < 1 {
+		return defaultMaxTokens
+	}
+	return *c.MaxTokens
+}
+
+// immunityBalance is a user's persisted token count and current pass streak
+type immunityBalance struct {
+	Tokens int `json:"tokens"`
+	Streak int `json:"streak"`
+}
+
+// immunityLedger tracks immunity tokens per user, writing the whole ledger to disk on
+// every change since it only grows with the number of users who've been vibechecked
+type immunityLedger struct {
+	log      *zap.Logger
+	config   ImmunityConfig
+	filePath string
+	users    map[string]immunityBalance
+	mu       sync.Mutex
+}
+
+func newImmunityLedger(log *zap.Logger, config ImmunityConfig, dataDir string) *immunityLedger {
+	l := &immunityLedger{
+		log:      log,
+		config:   config,
+		filePath: filepath.Join(dataDir, immunityFile),
+		users:    make(map[string]immunityBalance),
+	}
+
+	data, err := os.ReadFile(l.filePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		log.Error("Failed to read immunity tokens", zap.Error(err), zap.String("path", l.filePath))
+	default:
+		if err := json.Unmarshal(data, &l.users); err != nil {
+			log.Error("Failed to unmarshal immunity tokens", zap.Error(err), zap.String("path", l.filePath))
+		}
+	}
+	return l
+}
+
+// RecordPass extends the user's streak and reports whether it earned a token
+func (l *immunityLedger) RecordPass(userID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.users[userID]
+	b.Streak++
+	earned := false
+	if n := l.config.streakLength(); n > 0 && b.Streak >= n {
+		b.Streak = 0
+		if b.Tokens < l.config.maxTokens() {
+			b.Tokens++
+			earned = true
+		}
+	}
+	l.users[userID] = b
+	l.save()
+	return earned
+}
+
+// RecordFail resets the user's streak
+func (l *immunityLedger) RecordFail(userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.users[userID]
+	if !ok || b.Streak == 0 {
+		return
+	}
+	b.Streak = 0
+	l.users[userID] = b
+	l.save()
+}
+
+// Consume spends one token, reporting whether the user had one. The streak is reset
+// because the vibecheck still counts as failed.
+func (l *immunityLedger) Consume(userID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.users[userID]
+	if b.Tokens == 0 {
+		return false
+	}
+	b.Tokens--
+	b.Streak = 0
+	l.users[userID] = b
+	l.save()
+	return true
+}
+
+// Grant adds n tokens up to the configured maximum and returns the new balance
+func (l *immunityLedger) Grant(userID string, n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.users[userID]
+	b.Tokens = min(b.Tokens+max(n, 0), l.config.maxTokens())
+	l.users[userID] = b
+	l.save()
+	return b.Tokens
+}
+
+// Balance returns the user's current token count
+func (l *immunityLedger) Balance(userID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.users[userID].Tokens
+}
+
+// save writes the ledger; callers must hold mu
+func (l *immunityLedger) save() {
+	data, err := json.Marshal(l.users)
+	if err == nil {
+		err = writeFileAtomic(l.filePath, data)
+	}
+	if err != nil {
+		l.log.Error("Failed to save immunity tokens", zap.Error(err), zap.String("path", l.filePath))
+	}
+}
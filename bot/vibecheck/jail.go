@@ -0,0 +1,110 @@
+package vibecheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/tools/timeformat"
+)
+
+// Punishments for failing a vibecheck
+const (
+	PunishmentKick = "kick" // Remove the user from the channel until the ban expires
+	PunishmentJail = "jail" // Invite the user to the jail channel and announce their release
+)
+
+// Punishments are the punishments a channel can use
+var Punishments = []string{PunishmentKick, PunishmentJail}
+
+// JailConfig sends users who fail a vibecheck to a "vibe jail" channel instead of
+// kicking them, which keeps the gag without the scopes to remove members
+type JailConfig struct {
+	// Channel is the vibe jail users are invited to and sentenced in
+	Channel *string `json:"channel" yaml:"channel"`
+	// Punishment is kick (default) or jail, for channels without their own
+	Punishment *string `json:"punishment" yaml:"punishment"`
+	// Channels sets the punishment per channel ID, e.g. jail in a busy channel and kick
+	// everywhere else
+	Channels map[string]string `json:"channels" yaml:"channels"`
+}
+
+func (c JailConfig) channel() string {
+	if c.Channel == nil {
+		return ""
+	}
+	return strings.TrimSpace(*c.Channel)
+}
+
+// punishment returns what failing a vibecheck in the channel does
+func (c JailConfig) punishment(channelID string) string {
+	if p, ok := c.Channels[channelID]; ok && p != "" {
+		return p
+	}
+	if c.Punishment != nil && *c.Punishment != "" {
+		return *c.Punishment
+	}
+	return PunishmentKick
+}
+
+// jail invites a user who failed a vibecheck to the jail channel and posts their
+// sentence there. They stay in the channel they failed in.
+func (c *Vibecheck) jail(ctx context.Context, ev *slackevents.MessageEvent) {
+	jail := c.config.Jail.channel()
+	c.kickedUsers.AddJailedUser(ev.User, ev.Channel, c.config.BanDuration)
+
+	// A repeat offender may still be in jail from an earlier sentence
+	_, err := c.slack.Client().InviteUsersToConversationContext(ctx, jail, ev.User)
+	if err != nil && errs.NewSlackAPIError("conversations.invite", err).Code != "already_in_channel" {
+		err = conversation.Error("conversations.invite", conversation.KindFromID(jail), err)
+		c.status.PostFailed(jail, err)
+		c.log.Error("Failed to invite user to vibe jail",
+			zap.String("channel", jail),
+			zap.String("user", ev.User),
+			zap.Error(err),
+		)
+	}
+
+	message := fmt.Sprintf("🚔 <@%s> is sentenced to %s in vibe jail for failing the vibecheck in <#%s>",
+		ev.User, timeformat.Duration(c.config.BanDuration), ev.Channel)
+	c.postJail(ctx, message)
+	c.log.Info("User sent to vibe jail due to low vibe.",
+		zap.String("channel", ev.Channel),
+		zap.String("user", ev.User),
+		zap.String("jail", jail),
+	)
+}
+
+// release announces in the jail channel that a user served their sentence
+func (c *Vibecheck) release(ctx context.Context, user kickedUser) {
+	c.postJail(ctx, fmt.Sprintf("🔓 <@%s> served their time and is released from vibe jail", user.UserID))
+	c.log.Info("User released from vibe jail",
+		zap.String("channel", user.ChannelID),
+		zap.String("user", user.UserID),
+		zap.Time("jailed_at", user.KickedAt),
+	)
+}
+
+func (c *Vibecheck) postJail(ctx context.Context, message string) {
+	jail := c.config.Jail.channel()
+	_, _, err := c.slack.Client().PostMessageContext(ctx, jail,
+		slack.MsgOptionText(message, false),
+		c.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		c.status.PostFailed(jail, err)
+		c.log.Error("Failed to post in vibe jail",
+			zap.String("channel", jail),
+			zap.Error(err),
+		)
+		return
+	}
+	c.status.Posted()
+}
+
+// sentence formats a ban duration without zero units, e.g. 5m rather than 5m0s
@@ -0,0 +1,276 @@
+package vibecheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"slackbot.arpa/bot/errs"
+	"slackbot.arpa/tools/random"
+)
+
+// Judgement strategies selectable in JudgementConfig
+const (
+	StrategyRandom  = "random"
+	StrategyStreak  = "streak"
+	StrategyLLM     = "llm"
+	StrategyWebhook = "webhook"
+)
+
+const (
+	defaultPassWeight      = 0.8
+	defaultWednesdayWeight = 0.2
+	defaultStreakPenalty   = 0.1
+	defaultMinPassWeight   = 0.1
+	defaultWebhookTimeout  = 5 * time.Second
+	defaultJudgePrompt     = `You judge the vibe of a Slack message. Good vibes are friendly, fun, ` +
+		`curious or kind. Bad vibes are grumpy, rude, low effort or trying too hard. ` +
+		`Reply with exactly one word: PASS or FAIL.`
+)
+
+type aiService interface {
+	LLM() llms.Model
+}
+
+// JudgementConfig selects the strategy that decides whether a vibecheck passes. Every
+// field is optional; the random strategy with the built-in weights is the default.
+type JudgementConfig struct {
+	// Strategy is one of random, streak, llm or webhook
+	Strategy string `json:"strategy" yaml:"strategy"`
+	// PassWeight is the chance of passing, replaced by WednesdayWeight on Wednesdays
+	PassWeight      *float64 `json:"pass_weight" yaml:"pass_weight"`
+	WednesdayWeight *float64 `json:"wednesday_weight" yaml:"wednesday_weight"`
+	// StreakPenalty lowers the pass chance for each consecutive pass by the same user,
+	// down to MinPassWeight. A failure resets the streak.
+	StreakPenalty *float64 `json:"streak_penalty" yaml:"streak_penalty"`
+	MinPassWeight *float64 `json:"min_pass_weight" yaml:"min_pass_weight"`
+	// Prompt is the system prompt for the llm strategy
+	Prompt *string `json:"prompt" yaml:"prompt"`
+	// WebhookURL receives a JSON POST of the message and must answer {"passed": bool}
+	WebhookURL     string         `json:"webhook_url" yaml:"webhook_url"`
+	WebhookTimeout *time.Duration `json:"webhook_timeout" yaml:"webhook_timeout"`
+}
+
+// judgeRequest is the message being vibechecked
+type judgeRequest struct {
+	UserID    string    `json:"user_id"`
+	ChannelID string    `json:"channel_id"`
+	Text      string    `json:"text"`
+	Time      time.Time `json:"time"`
+}
+
+// judge decides whether a message passes the vibecheck
+type judge interface {
+	Judge(ctx context.Context, req judgeRequest) (bool, error)
+}
+
+// newJudge builds the configured strategy. Strategies that depend on an external service
+// fall back to the random judge when it's unavailable or fails.
+func newJudge(c JudgementConfig, ai aiService) (judge, error) {
+	if err := c.Check(ai != nil); err != nil {
+		return nil, err
+	}
+	base := &randomJudge{
+		passWeight:      floatOr(c.PassWeight, defaultPassWeight),
+		wednesdayWeight: floatOr(c.WednesdayWeight, defaultWednesdayWeight),
+	}
+
+	switch c.Strategy {
+	case "", StrategyRandom:
+		return base, nil
+	case StrategyStreak:
+		return &streakJudge{
+			base:    base,
+			penalty: floatOr(c.StreakPenalty, defaultStreakPenalty),
+			min:     floatOr(c.MinPassWeight, defaultMinPassWeight),
+			streaks: make(map[string]int),
+		}, nil
+	case StrategyLLM:
+		prompt := defaultJudgePrompt
+		if c.Prompt != nil && *c.Prompt != "" {
+			prompt = *c.Prompt
+		}
+		return &fallbackJudge{primary: &llmJudge{ai: ai, prompt: prompt}, fallback: base}, nil
+	case StrategyWebhook:
+		timeout := defaultWebhookTimeout
+		if c.WebhookTimeout != nil && *c.WebhookTimeout > 0 {
+			timeout = *c.WebhookTimeout
+		}
+		return &fallbackJudge{
+			primary:  &webhookJudge{url: c.WebhookURL, client: &http.Client{Timeout: timeout}},
+			fallback: base,
+		}, nil
+	}
+	return base, nil
+}
+
+// Check validates the strategy and the fields it requires. llm is whether an LLM is
+// configured for the llm strategy.
+func (c JudgementConfig) Check(llm bool) error {
+	switch c.Strategy {
+	case "", StrategyRandom, StrategyStreak:
+	case StrategyLLM:
+		if !llm {
+			return &errs.ConfigError{Key: "vibecheck.judgement.strategy", Err: errors.New("llm strategy requires an OpenAI API key or LLM provider")}
+		}
+	case StrategyWebhook:
+		if c.WebhookURL == "" {
+			return &errs.ConfigError{Key: "vibecheck.judgement.webhook_url", Err: errors.New("webhook strategy requires a URL")}
+		}
+	default:
+		return &errs.ConfigError{Key: "vibecheck.judgement.strategy", Err: fmt.Errorf("unknown strategy %q, must be one of %s, %s, %s or %s", c.Strategy, StrategyRandom, StrategyStreak, StrategyLLM, StrategyWebhook)}
+	}
+	return nil
+}
+
+func floatOr(v *float64, fallback float64) float64 {
+	if v != nil {
+		return *v
+	}
+	return fallback
+}
+
+// randomJudge passes with a fixed weight, which is lower on Wednesdays
+type randomJudge struct {
+	passWeight      float64
+	wednesdayWeight float64
+}
+
+func (j *randomJudge) weight(t time.Time) float64 {
+	if t.Local().Weekday() == time.Wednesday {
+		return j.wednesdayWeight
+	}
+	return j.passWeight
+}
+
+func (j *randomJudge) Judge(_ context.Context, req judgeRequest) (bool, error) {
+	return random.Bool(j.weight(req.Time)), nil
+}
+
+// streakJudge makes consecutive passes by the same user less likely
+type streakJudge struct {
+	base    *randomJudge
+	penalty float64
+	min     float64
+	mu      sync.Mutex
+	streaks map[string]int // userID -> consecutive passes
+}
+
+func (j *streakJudge) passWeight(userID string, t time.Time) float64 {
+	j.mu.Lock()
+	streak := j.streaks[userID]
+	j.mu.Unlock()
+	return max(j.base.weight(t)-float64(streak)*j.penalty, j.min)
+}
+
+func (j *streakJudge) Judge(_ context.Context, req judgeRequest) (bool, error) {
+	passed := random.Bool(j.passWeight(req.UserID, req.Time))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if passed {
+		j.streaks[req.UserID]++
+	} else {
+		delete(j.streaks, req.UserID)
+	}
+	return passed, nil
+}
+
+// llmJudge asks the language model to judge the message text
+type llmJudge struct {
+	ai     aiService
+	prompt string
+}
+
+func (j *llmJudge) Judge(ctx context.Context, req judgeRequest) (bool, error) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, j.prompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, req.Text),
+	}
+	resp, err := j.ai.LLM().GenerateContent(ctx, messages,
+		llms.WithTemperature(0.2),
+		llms.WithMaxTokens(5),
+	)
+	if err != nil {
+		return false, errs.NewLLMError("judge vibe", err)
+	}
+	if len(resp.Choices) == 0 {
+		return false, fmt.Errorf("empty response from LLM")
+	}
+	return parseVerdict(resp.Choices[0].Content)
+}
+
+// parseVerdict reads a PASS or FAIL answer
+func parseVerdict(s string) (bool, error) {
+	verdict := strings.ToUpper(strings.TrimSpace(s))
+	switch {
+	case strings.HasPrefix(verdict, "PASS"):
+		return true, nil
+	case strings.HasPrefix(verdict, "FAIL"):
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected verdict %q", s)
+}
+
+// webhookJudge delegates the decision to an external service
+type webhookJudge struct {
+	url    string
+	client *http.Client
+}
+
+func (j *webhookJudge) Judge(ctx context.Context, req judgeRequest) (bool, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, fmt.Errorf("marshal judge request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, j.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create judge request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := j.client.Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("call judge webhook: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("judge webhook returned %s", resp.Status)
+	}
+
+	var result struct {
+		Passed *bool `json:"passed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("decode judge response: %w", err)
+	}
+	if result.Passed == nil {
+		return false, fmt.Errorf("judge response missing \"passed\"")
+	}
+	return *result.Passed, nil
+}
+
+// fallbackJudge uses the fallback when the primary judge fails, returning the primary's
+// error alongside the fallback verdict so it can be logged
+type fallbackJudge struct {
+	primary  judge
+	fallback judge
+}
+
+func (j *fallbackJudge) Judge(ctx context.Context, req judgeRequest) (bool, error) {
+	passed, err := j.primary.Judge(ctx, req)
+	if err == nil {
+		return passed, nil
+	}
+	passed, _ = j.fallback.Judge(ctx, req)
+	return passed, err
+}
@@ -1,6 +1,8 @@
 package vibecheck
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -8,9 +10,17 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/errs"
 )
 
-const kickedUsersFile = "kicked_users.json"
+const (
+	kickedUsersFile    = "kicked_users.json"
+	kickedJournalFile  = "kicked_users.journal"
+	minJournalCompact  = 256 // Journal entries before compaction is considered
+	journalOpPut       = "put"
+	journalOpDelete    = "delete"
+	reinvitedRetention = 24 * time.Hour
+)
 
 // kickedUser represents a user who has been kicked from a channel
 type kickedUser struct {
@@ -19,15 +29,30 @@ type kickedUser struct {
 	KickedAt   time.Time `json:"kicked_at"`
 	ReinviteAt time.Time `json:"reinvite_at"`
 	Reinvited  bool      `json:"reinvited"`
+	Jailed     bool      `json:"jailed,omitempty"` // Sent to vibe jail instead of kicked, so only released
+}
+
+// journalEntry is a single change appended to the journal between snapshots
+type journalEntry struct {
+	Op   string      `json:"op"`
+	Key  string      `json:"key"`
+	User *kickedUser `json:"user,omitempty"`
 }
 
-// kickedUsersManager manages kicked users and handles persistence
+// kickedUsersManager manages kicked users and handles persistence. Changes are appended
+// to a journal and periodically compacted into an atomically written snapshot, so a
+// change costs one small write regardless of how many users are tracked.
 type kickedUsersManager struct {
-	log      *zap.Logger
-	users    map[string]kickedUser // key is userID+channelID
-	dataDir  string
-	filePath string
-	mu       sync.RWMutex
+	log             *zap.Logger
+	users           map[string]kickedUser          // key is userID+channelID
+	pending         map[string]map[string]struct{} // channelID -> keys awaiting reinvite
+	dataDir         string
+	filePath        string
+	journalPath     string
+	journal         *os.File
+	journalEntries  int
+	minCompactCount int
+	mu              sync.RWMutex
 }
 
 // newKickedUsersManager creates a new manager for kicked users
@@ -40,10 +65,13 @@ func newKickedUsersManager(log *zap.Logger, dataDir string) *kickedUsersManager
 	)
 
 	manager := &kickedUsersManager{
-		log:      log,
-		users:    make(map[string]kickedUser),
-		dataDir:  dataDir,
-		filePath: filePath,
+		log:             log,
+		users:           make(map[string]kickedUser),
+		pending:         make(map[string]map[string]struct{}),
+		dataDir:         dataDir,
+		filePath:        filePath,
+		journalPath:     filepath.Join(dataDir, kickedJournalFile),
+		minCompactCount: minJournalCompact,
 	}
 
 	// Ensure data directory exists
@@ -54,6 +82,9 @@ func newKickedUsersManager(log *zap.Logger, dataDir string) *kickedUsersManager
 	}
 
 	manager.loadFromDisk()
+	if manager.journalEntries > 0 {
+		manager.compact()
+	}
 	return manager
 }
 
@@ -63,26 +94,35 @@ func (m *kickedUsersManager) generateKey(userID, channelID string) string {
 }
 
 // IsUserBanned checks if a user is currently banned from a channel
-func (k *kickedUsersManager) IsUserBanned(userID, channelID string) (kickedUser, bool) {
-	k.mu.RLock()
-	defer k.mu.RUnlock()
-	
-	key := k.generateKey(userID, channelID)
-	user, exists := k.users[key]
+func (m *kickedUsersManager) IsUserBanned(userID, channelID string) (kickedUser, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key := m.generateKey(userID, channelID)
+	user, exists := m.users[key]
 	if !exists || user.Reinvited {
 		return kickedUser{}, false
 	}
-	
+
 	// Check if ban time has expired
 	if time.Now().After(user.ReinviteAt) {
 		return kickedUser{}, false
 	}
-	
+
 	return user, true
 }
 
 // AddKickedUser adds a user to the kicked list with a reinvite time
 func (m *kickedUsersManager) AddKickedUser(userID, channelID string, timeout time.Duration) {
+	m.add(userID, channelID, timeout, false)
+}
+
+// AddJailedUser adds a user sent to vibe jail, who is released rather than reinvited
+func (m *kickedUsersManager) AddJailedUser(userID, channelID string, timeout time.Duration) {
+	m.add(userID, channelID, timeout, true)
+}
+
+func (m *kickedUsersManager) add(userID, channelID string, timeout time.Duration, jailed bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -94,20 +134,23 @@ func (m *kickedUsersManager) AddKickedUser(userID, channelID string, timeout tim
 		zap.String("channel_id", channelID),
 		zap.Time("kicked_at", now),
 		zap.Time("reinvite_at", now.Add(timeout)),
+		zap.Bool("jailed", jailed),
 	)
 
-	m.users[key] = kickedUser{
+	user := kickedUser{
 		UserID:     userID,
 		ChannelID:  channelID,
 		KickedAt:   now,
 		ReinviteAt: now.Add(timeout),
 		Reinvited:  false,
+		Jailed:     jailed,
 	}
-
-	m.saveToDisk()
+	m.put(key, user)
+	m.appendJournal(journalEntry{Op: journalOpPut, Key: key, User: &user})
 }
 
-// GetUsersToReinvite returns all users who should be reinvited now
+// GetUsersToReinvite returns all users who should be reinvited now. Only channels with
+// users awaiting reinvite are scanned.
 func (m *kickedUsersManager) GetUsersToReinvite() []kickedUser {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -115,8 +158,13 @@ func (m *kickedUsersManager) GetUsersToReinvite() []kickedUser {
 	var usersToReinvite []kickedUser
 	now := time.Now()
 
-	for key, user := range m.users {
-		if !user.Reinvited && now.After(user.ReinviteAt) {
+	for _, keys := range m.pending {
+		for key := range keys {
+			user := m.users[key]
+			if !now.After(user.ReinviteAt) {
+				continue
+			}
+
 			m.log.Debug("Found user ready for reinvite",
 				zap.String("user_id", user.UserID),
 				zap.String("channel_id", user.ChannelID),
@@ -128,15 +176,38 @@ func (m *kickedUsersManager) GetUsersToReinvite() []kickedUser {
 
 			// Mark as reinvited
 			user.Reinvited = true
-			m.users[key] = user
+			m.put(key, user)
+			m.appendJournal(journalEntry{Op: journalOpPut, Key: key, User: &user})
 		}
 	}
 
-	if len(usersToReinvite) > 0 {
-		m.saveToDisk()
+	return usersToReinvite
+}
+
+// PendingInChannel returns the users in a channel who are still waiting to be reinvited
+func (m *kickedUsersManager) PendingInChannel(channelID string) []kickedUser {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]kickedUser, 0, len(m.pending[channelID]))
+	for key := range m.pending[channelID] {
+		users = append(users, m.users[key])
 	}
+	return users
+}
 
-	return usersToReinvite
+// Pending returns every user still waiting to be reinvited
+func (m *kickedUsersManager) Pending() []kickedUser {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []kickedUser
+	for _, keys := range m.pending {
+		for key := range keys {
+			users = append(users, m.users[key])
+		}
+	}
+	return users
 }
 
 // CleanupReinvitedUsers removes users who have been reinvited for more than a day
@@ -144,62 +215,213 @@ func (m *kickedUsersManager) CleanupReinvitedUsers() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	oneDayAgo := time.Now().Add(-24 * time.Hour)
-	needSave := false
+	cutoff := time.Now().Add(-reinvitedRetention)
 	removedCount := 0
 
 	for key, user := range m.users {
-		if user.Reinvited && user.ReinviteAt.Before(oneDayAgo) {
-			delete(m.users, key)
-			needSave = true
+		if user.Reinvited && user.ReinviteAt.Before(cutoff) {
+			m.remove(key)
+			m.appendJournal(journalEntry{Op: journalOpDelete, Key: key})
 			removedCount++
 		}
 	}
 
-	if needSave {
+	if removedCount > 0 {
 		m.log.Debug("Cleaned up old reinvited users", zap.Int("removed_count", removedCount))
-		m.saveToDisk()
+	}
+	if m.journalEntries >= max(m.minCompactCount, len(m.users)) {
+		m.compact()
 	}
 }
 
-// saveToDisk saves the kicked users data to a JSON file
-func (m *kickedUsersManager) saveToDisk() {
-	data, err := json.MarshalIndent(m.users, "", "  ")
+// Close flushes the journal into a snapshot and releases the journal file
+func (m *kickedUsersManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.journalEntries > 0 {
+		m.compact()
+	}
+	if m.journal != nil {
+		_ = m.journal.Close()
+		m.journal = nil
+	}
+}
+
+// put stores the user and keeps the pending channel index in sync
+func (m *kickedUsersManager) put(key string, user kickedUser) {
+	m.users[key] = user
+	if user.Reinvited {
+		m.unindex(key, user.ChannelID)
+		return
+	}
+	keys, ok := m.pending[user.ChannelID]
+	if !ok {
+		keys = make(map[string]struct{})
+		m.pending[user.ChannelID] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// remove deletes the user and its pending index entry
+func (m *kickedUsersManager) remove(key string) {
+	if user, ok := m.users[key]; ok {
+		m.unindex(key, user.ChannelID)
+		delete(m.users, key)
+	}
+}
+
+func (m *kickedUsersManager) unindex(key, channelID string) {
+	if keys, ok := m.pending[channelID]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(m.pending, channelID)
+		}
+	}
+}
+
+// appendJournal records a change on disk, falling back to a full snapshot if the
+// journal can't be written
+func (m *kickedUsersManager) appendJournal(entry journalEntry) {
+	data, err := json.Marshal(entry)
 	if err != nil {
-		m.log.Error("Failed to marshal kicked users data", zap.Error(err))
+		m.log.Error("Failed to marshal kicked users journal entry", zap.Error(err))
 		return
 	}
 
-	err = os.WriteFile(m.filePath, data, 0600)
+	if m.journal == nil {
+		m.journal, err = os.OpenFile(m.journalPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600) // #nosec G304 -- path is derived from the data directory
+		if err != nil {
+			m.log.Error("Failed to open kicked users journal", zap.Error(err), zap.String("path", m.journalPath))
+			m.compact()
+			return
+		}
+	}
+
+	if _, err := m.journal.Write(append(data, '\n')); err != nil {
+		m.log.Error("Failed to append kicked users journal",
+			zap.Error(&errs.StorageError{Op: "append", Path: m.journalPath, Err: err}))
+		m.compact()
+		return
+	}
+	m.journalEntries++
+}
+
+// compact writes a snapshot of all users and truncates the journal
+func (m *kickedUsersManager) compact() {
+	if err := m.saveSnapshot(); err != nil {
+		m.log.Error("Failed to save kicked users data", zap.Error(err))
+		return
+	}
+
+	if m.journal != nil {
+		_ = m.journal.Close()
+		m.journal = nil
+	}
+	if err := os.Remove(m.journalPath); err != nil && !os.IsNotExist(err) {
+		m.log.Error("Failed to truncate kicked users journal", zap.Error(err), zap.String("path", m.journalPath))
+		return
+	}
+	m.journalEntries = 0
+}
+
+// saveSnapshot atomically replaces the snapshot file by writing to a temporary file and
+// renaming it over the original
+func (m *kickedUsersManager) saveSnapshot() error {
+	data, err := json.MarshalIndent(m.users, "", "  ")
 	if err != nil {
-		m.log.Error("Failed to save kicked users data", zap.Error(err), zap.String("path", m.filePath))
-	} else {
-		m.log.Debug("Saved kicked users data to disk",
-			zap.String("path", m.filePath),
-			zap.Int("num_users", len(m.users)),
-		)
+		return err
+	}
+
+	if err := writeFileAtomic(m.filePath, data); err != nil {
+		return err
 	}
+
+	m.log.Debug("Saved kicked users data to disk",
+		zap.String("path", m.filePath),
+		zap.Int("num_users", len(m.users)),
+	)
+	return nil
 }
 
-// loadFromDisk loads kicked users data from the JSON file
+// writeFileAtomic writes data to a temporary file and renames it over path, so readers
+// never see a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tempFile := path + ".tmp"
+	f, err := os.OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // #nosec G304 -- path is derived from the data directory
+	if err != nil {
+		return &errs.StorageError{Op: "create", Path: tempFile, Err: err}
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return &errs.StorageError{Op: "write", Path: tempFile, Err: err}
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return &errs.StorageError{Op: "sync", Path: tempFile, Err: err}
+	}
+	if err := f.Close(); err != nil {
+		return &errs.StorageError{Op: "close", Path: tempFile, Err: err}
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return &errs.StorageError{Op: "rename", Path: path, Err: err}
+	}
+	return nil
+}
+
+// loadFromDisk loads the snapshot and replays any journal entries written after it
 func (m *kickedUsersManager) loadFromDisk() {
 	data, err := os.ReadFile(m.filePath)
+	switch {
+	case os.IsNotExist(err):
+		m.log.Debug("No kicked users file exists yet", zap.String("path", m.filePath))
+	case err != nil:
+		m.log.Error("Failed to read kicked users data", zap.Error(err), zap.String("path", m.filePath))
+	default:
+		users := make(map[string]kickedUser)
+		if err := json.Unmarshal(data, &users); err != nil {
+			m.log.Error("Failed to unmarshal kicked users data", zap.Error(err))
+		}
+		for key, user := range users {
+			m.put(key, user)
+		}
+	}
+
+	m.replayJournal()
+	m.log.Debug("Loaded kicked users data from disk",
+		zap.String("path", m.filePath),
+		zap.Int("num_users", len(m.users)),
+		zap.Int("journal_entries", m.journalEntries),
+	)
+}
+
+// replayJournal applies journal entries in order. A torn final line from a crash
+// mid-write is skipped.
+func (m *kickedUsersManager) replayJournal() {
+	data, err := os.ReadFile(m.journalPath)
 	if err != nil {
 		if !os.IsNotExist(err) {
-			m.log.Error("Failed to read kicked users data", zap.Error(err), zap.String("path", m.filePath))
-		} else {
-			m.log.Debug("No kicked users file exists yet", zap.String("path", m.filePath))
+			m.log.Error("Failed to read kicked users journal", zap.Error(err), zap.String("path", m.journalPath))
 		}
 		return
 	}
 
-	err = json.Unmarshal(data, &m.users)
-	if err != nil {
-		m.log.Error("Failed to unmarshal kicked users data", zap.Error(err))
-	} else {
-		m.log.Debug("Loaded kicked users data from disk",
-			zap.String("path", m.filePath),
-			zap.Int("num_users", len(m.users)),
-		)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			m.log.Warn("Skipping invalid kicked users journal entry", zap.Error(err))
+			continue
+		}
+		switch entry.Op {
+		case journalOpPut:
+			if entry.User != nil {
+				m.put(entry.Key, *entry.User)
+			}
+		case journalOpDelete:
+			m.remove(entry.Key)
+		}
+		m.journalEntries++
 	}
 }
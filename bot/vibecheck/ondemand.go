@@ -0,0 +1,179 @@
+package vibecheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.uber.org/zap"
+	"slackbot.arpa/tools/emoji"
+	"slackbot.arpa/tools/timeformat"
+)
+
+const defaultOnDemandCooldown = time.Hour
+
+// OnDemandConfig lets users vibecheck someone else by reacting to their message
+type OnDemandConfig struct {
+	// Reaction is the emoji name that requests a vibecheck of the message author, e.g.
+	// vibecheck; unset disables on-demand vibechecks
+	Reaction *string `json:"reaction" yaml:"reaction"`
+	// Cooldown is how long a user waits between requests, defaults to 1h
+	Cooldown *time.Duration `json:"cooldown" yaml:"cooldown"`
+}
+
+func (c OnDemandConfig) reaction() string {
+	if c.Reaction == nil {
+		return ""
+	}
+	return emoji.Name(*c.Reaction)
+}
+
+func (c OnDemandConfig) cooldown() time.Duration {
+	if c.Cooldown == nil || *c.Cooldown <= 0 {
+		return defaultOnDemandCooldown
+	}
+	return *c.Cooldown
+}
+
+// requesterCooldowns limits how often each user can vibecheck someone else, so the
+// reaction can't be used to harass
+type requesterCooldowns struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newRequesterCooldowns() *requesterCooldowns {
+	return &requesterCooldowns{last: make(map[string]time.Time)}
+}
+
+// Allow records a request and reports whether the requester's cooldown had passed, or
+// how much of it is left
+func (r *requesterCooldowns) Allow(userID string, now time.Time, cooldown time.Duration) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.last[userID]; ok {
+		if remaining := cooldown - now.Sub(last); remaining > 0 {
+			return false, remaining
+		}
+	}
+	// Forget expired requests so only recent requesters are kept
+	for id, t := range r.last {
+		if now.Sub(t) >= cooldown {
+			delete(r.last, id)
+		}
+	}
+	r.last[userID] = now
+	return true, 0
+}
+
+// handleReactionAddedEvent vibechecks the author of a message someone reacted to with the
+// on-demand reaction
+func (c *Vibecheck) handleReactionAddedEvent(ctx context.Context, ev *slackevents.ReactionAddedEvent) {
+	reaction := c.config.OnDemand.reaction()
+	if reaction == "" || ev.Item.Type != "message" || ev.ItemUser == "" || !c.slack.Emoji().Same(ctx, ev.Reaction, reaction) {
+		return
+	}
+	// The bot's own reactions, like the failed verdict, never request a vibecheck
+	if ev.User == c.slack.BotUserID() || ev.ItemUser == c.slack.BotUserID() {
+		return
+	}
+	channel := ev.Item.Channel
+	if c.preferred(ev.ItemUser, "") {
+		c.log.Info("Preferred user is exempt from on-demand vibechecks",
+			zap.String("channel", channel),
+			zap.String("user", ev.ItemUser),
+			zap.String("requester", ev.User),
+		)
+		return
+	}
+
+	msg, err := c.fetchMessage(ctx, channel, ev.Item.Timestamp)
+	if err != nil {
+		c.status.Error(err)
+		c.log.Error("Failed to fetch message for on-demand vibecheck",
+			zap.String("channel", channel),
+			zap.String("timestamp", ev.Item.Timestamp),
+			zap.Error(err),
+		)
+		return
+	}
+	if msg.BotID != "" || c.preferred(msg.User, msg.Username) {
+		return
+	}
+
+	if ok, remaining := c.cooldowns.Allow(ev.User, time.Now(), c.config.OnDemand.cooldown()); !ok {
+		c.log.Info("Requester is cooling down, skipping on-demand vibecheck",
+			zap.String("channel", channel),
+			zap.String("requester", ev.User),
+			zap.Duration("remaining", remaining),
+		)
+		message := fmt.Sprintf("You can request another vibecheck in %s", timeformat.Duration(remaining))
+		if _, err := c.slack.Client().PostEphemeralContext(ctx, channel, ev.User, slack.MsgOptionText(message, false)); err != nil {
+			c.status.Error(err)
+			c.log.Error("Failed to post cooldown notice", zap.String("channel", channel), zap.Error(err))
+		}
+		return
+	}
+
+	if c.dedupe.IsDupe(msg.User, channel, msg.Timestamp) {
+		return
+	}
+	c.log.Info("Vibecheck requested by reaction",
+		zap.String("channel", channel),
+		zap.String("user", msg.User),
+		zap.String("requester", ev.User),
+	)
+	target := &slackevents.MessageEvent{
+		User:      msg.User,
+		Username:  msg.Username,
+		Channel:   channel,
+		TimeStamp: msg.Timestamp,
+		Text:      msg.Text,
+	}
+	if msg.ThreadTimestamp != msg.Timestamp {
+		target.ThreadTimeStamp = msg.ThreadTimestamp
+	}
+	c.check(ctx, target, strings.TrimSpace(msg.Text), ev.User)
+}
+
+// fetchMessage looks up a message in the channel's history, or among thread replies
+// which aren't in the history
+func (c *Vibecheck) fetchMessage(ctx context.Context, channelID, ts string) (slack.Message, error) {
+	history, err := c.slack.Client().GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Latest:    ts,
+		Oldest:    ts,
+		Inclusive: true,
+		Limit:     1,
+	})
+	if err != nil {
+		return slack.Message{}, err
+	}
+	for _, m := range history.Messages {
+		if m.Timestamp == ts {
+			return m, nil
+		}
+	}
+
+	replies, _, _, err := c.slack.Client().GetConversationRepliesContext(ctx, &slack.GetConversationRepliesParameters{
+		ChannelID: channelID,
+		Timestamp: ts,
+		Latest:    ts,
+		Oldest:    ts,
+		Inclusive: true,
+		Limit:     1,
+	})
+	if err != nil {
+		return slack.Message{}, err
+	}
+	for _, m := range replies {
+		if m.Timestamp == ts {
+			return m, nil
+		}
+	}
+	return slack.Message{}, fmt.Errorf("message %s not found in %s", ts, channelID)
+}
@@ -3,6 +3,7 @@ package vibecheck
 import (
 	"fmt"
 
+	"slackbot.arpa/tools/emoji"
 	"slackbot.arpa/tools/random"
 )
 
@@ -29,13 +30,7 @@ func randomResponse(passed bool, c FileConfig) string {
 		emojis = withDefault(c.BadReactions, badEmojis)
 		texts = withDefault(c.BadText, badText)
 	}
-	e := random.String(emojis)
-	if e[0] != ':' {
-		e = ":" + e
-	}
-	if e[len(e)-1] != ':' {
-		e = e + ":"
-	}
+	e := emoji.Code(random.String(emojis))
 	t := random.String(texts)
 	return fmt.Sprintf("%s %s %s %s %s %s %s", e, e, e, t, e, e, e)
 }
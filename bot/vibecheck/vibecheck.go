@@ -12,15 +12,49 @@ import (
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"go.uber.org/zap"
-	"slackbot.arpa/tools/random"
+	"slackbot.arpa/bot/conversation"
+	"slackbot.arpa/bot/event"
+	"slackbot.arpa/bot/persona"
+	"slackbot.arpa/bot/retract"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/bot/status"
+	"slackbot.arpa/bot/subtype"
+	"slackbot.arpa/tools/emoji"
+	"slackbot.arpa/tools/timeformat"
 )
 
 const eventChannelSize = 100
 
 var pattern = regexp.MustCompile(`(?i).*vibe.*`)
 
+// Reply modes for the vibecheck verdict
+const (
+	ReplyModeChannel   = "channel"   // Post in the channel, or the thread if the message was threaded
+	ReplyModeThread    = "thread"    // Reply in a thread on the triggering message
+	ReplyModeEphemeral = "ephemeral" // Reply in a thread visible only to the author
+)
+
+// presence reports whether the bot can still post in a channel
+type presence interface {
+	Present(ctx context.Context, channelID string) bool
+}
+
+// silencer reports channels where fun features should stay quiet, e.g. during an incident
+type silencer interface {
+	Silenced(channelID string) bool
+}
+
+// claimer limits how many features respond to one message
+type claimer interface {
+	Claim(ctx context.Context, feature, channelID, ts string) bool
+}
+
 type slackService interface {
 	Client() *slack.Client
+	BotUserID() string
+	Emoji() *emoji.Catalog
+	Retry(ctx context.Context, op string, fn func() error) error
+	PostDM(ctx context.Context, userID, kind string, opts ...slack.MsgOption) (string, error)
 }
 
 type FileConfig struct {
@@ -29,12 +63,38 @@ type FileConfig struct {
 	BadReactions  []string       `json:"bad_reactions" yaml:"bad_reactions"`
 	BadText       []string       `json:"bad_text" yaml:"bad_text"`
 	BanDuration   *time.Duration `json:"ban_duration" yaml:"ban_duration"`
+	// Judgement selects how a vibecheck passes or fails
+	Judgement JudgementConfig `json:"judgement" yaml:"judgement"`
+	// ReplyMode is where the verdict is posted: channel (default), thread or ephemeral
+	ReplyMode *string `json:"reply_mode" yaml:"reply_mode"`
+	// Immunity configures tokens that automatically cancel a failed vibecheck
+	Immunity ImmunityConfig `json:"immunity" yaml:"immunity"`
+	// OnDemand lets users vibecheck someone else by reacting to their message
+	OnDemand OnDemandConfig `json:"on_demand" yaml:"on_demand"`
+	// Jail sends users who fail to a vibe jail channel instead of kicking them
+	Jail JailConfig `json:"jail" yaml:"jail"`
+	// WelcomeBack greets users reinvited when their ban expires
+	WelcomeBack WelcomeBackConfig `json:"welcome_back" yaml:"welcome_back"`
+	// Persona is the name and icon verdicts are posted with
+	Persona persona.Config `json:"persona" yaml:"persona"`
+	// OnEdit removes the reactions and verdict on a message edited to no longer mention
+	// vibes. Bans stand.
+	OnEdit retract.FileConfig `json:"on_edit" yaml:"on_edit"`
 }
 
 type Config struct {
-	PreferredUsers []string
-	DataDir        string
-	BanDuration    time.Duration
+	PreferredUsers  []string
+	DataDir         string
+	BanDuration     time.Duration
+	Judgement       JudgementConfig
+	ReplyMode       string // One of ReplyModeChannel, ReplyModeThread or ReplyModeEphemeral
+	Immunity        ImmunityConfig
+	OnDemand        OnDemandConfig
+	Jail            JailConfig
+	WelcomeBack     WelcomeBackConfig
+	Persona         persona.Config
+	MessageSubtypes []string // Subtypes handled besides plain messages, defaults to subtype.DefaultAllowed
+	OnEdit          retract.Config
 }
 
 // Vibecheck handles responding to messages to verify the users vibe
@@ -44,11 +104,22 @@ type Vibecheck struct {
 	slack       slackService
 	isConnected atomic.Bool
 	stopCh      chan struct{}
-	eventsCh    chan slackevents.EventsAPIEvent
+	eventsCh    chan event.Event
 	kickedUsers *kickedUsersManager
+	immunity    *immunityLedger
+	cooldowns   *requesterCooldowns
+	kicks       *scheduler.TaskGroup
 	ticker      *time.Ticker
 	dedupe      *messageDeduplicator
 	fileConfig  FileConfig
+	status      *status.Tracker
+	ai          aiService
+	judge       judge
+	subtypes    *subtype.Filter
+	silencer    silencer
+	presence    presence
+	claims      claimer
+	edits       *retract.Index
 }
 
 func NewVibecheck(log *zap.Logger, config Config, s slackService) *Vibecheck {
@@ -56,14 +127,87 @@ func NewVibecheck(log *zap.Logger, config Config, s slackService) *Vibecheck {
 		log:         log,
 		config:      config,
 		stopCh:      make(chan struct{}),
-		eventsCh:    make(chan slackevents.EventsAPIEvent, eventChannelSize),
+		eventsCh:    make(chan event.Event, eventChannelSize),
 		slack:       s,
 		kickedUsers: newKickedUsersManager(log, config.DataDir),
+		immunity:    newImmunityLedger(log, config.Immunity, config.DataDir),
+		cooldowns:   newRequesterCooldowns(),
+		kicks:       scheduler.NewTasks(scheduler.DefaultMaxPendingTasks).Group(),
 		ticker:      time.NewTicker(10 * time.Second),         // Check more frequently during debugging
 		dedupe:      newMessageDeduplicator(30 * time.Second), // Remember messages for 30 seconds
+		judge:       &randomJudge{passWeight: defaultPassWeight, wednesdayWeight: defaultWednesdayWeight},
+		subtypes:    subtype.NewFilter(config.MessageSubtypes),
+		edits:       retract.NewIndex(config.OnEdit, s),
 	}
 }
 
+// SetAI provides the language model used by the llm judgement strategy
+func (c *Vibecheck) SetAI(a aiService) {
+	c.ai = a
+}
+
+// SetTasks schedules kicks on the bot's shared delayed tasks. It must be called before
+// Start.
+func (c *Vibecheck) SetTasks(t *scheduler.Tasks) {
+	c.kicks = t.Group()
+}
+
+// SetSilencer pauses the feature in channels the silencer reports as silenced
+func (c *Vibecheck) SetSilencer(s silencer) {
+	c.silencer = s
+}
+
+// SetPresence skips channels the bot was removed from
+func (c *Vibecheck) SetPresence(p presence) {
+	c.presence = p
+}
+
+// SetClaimer has the feature claim a message before checking its author, so other
+// features replying to the same message don't pile on
+func (c *Vibecheck) SetClaimer(cl claimer) {
+	c.claims = cl
+}
+
+// SetStatusTracker sets the tracker that records the feature's activity
+func (c *Vibecheck) SetStatusTracker(t *status.Tracker) {
+	c.status = t
+}
+
+// GrantImmunity gives a user n immunity tokens, capped at the configured maximum, and
+// returns their new balance. Other features use this to reward users, e.g. for karma.
+func (c *Vibecheck) GrantImmunity(userID string, n int) int {
+	return c.immunity.Grant(userID, n)
+}
+
+// Ban is a user removed from a channel, or sent to vibe jail, for a failed vibecheck
+type Ban struct {
+	UserID     string
+	ChannelID  string
+	Punishment string // PunishmentKick or PunishmentJail
+	KickedAt   time.Time
+	ReinviteAt time.Time
+}
+
+// Bans returns the users waiting to be reinvited, soonest first
+func (c *Vibecheck) Bans() []Ban {
+	users := c.kickedUsers.Pending()
+	bans := make([]Ban, 0, len(users))
+	for _, u := range users {
+		punishment := PunishmentKick
+		if u.Jailed {
+			punishment = PunishmentJail
+		}
+		bans = append(bans, Ban{UserID: u.UserID, ChannelID: u.ChannelID, Punishment: punishment, KickedAt: u.KickedAt, ReinviteAt: u.ReinviteAt})
+	}
+	slices.SortFunc(bans, func(a, b Ban) int { return a.ReinviteAt.Compare(b.ReinviteAt) })
+	return bans
+}
+
+// ImmunityBalance returns how many immunity tokens a user holds
+func (c *Vibecheck) ImmunityBalance(userID string) int {
+	return c.immunity.Balance(userID)
+}
+
 // ProcessorType returns a description of the processor type
 func (c *Vibecheck) ProcessorType() string {
 	return "vibecheck"
@@ -71,6 +215,13 @@ func (c *Vibecheck) ProcessorType() string {
 
 // Start initializes the Vibecheck feature with a Slack client
 func (c *Vibecheck) Start(ctx context.Context) error {
+	j, err := newJudge(c.config.Judgement, c.ai)
+	if err != nil {
+		c.log.Error("Invalid vibecheck judgement, using random strategy", zap.Error(err))
+	} else {
+		c.judge = j
+	}
+
 	c.isConnected.Store(true)
 
 	// Start listening for events in a goroutine
@@ -91,21 +242,26 @@ func (c *Vibecheck) Stop(ctx context.Context) error {
 
 	c.ticker.Stop()
 	close(c.stopCh)
+	if dropped := c.kicks.Stop(); dropped > 0 {
+		c.log.Info("Cancelled pending kicks on shutdown", zap.Int("count", dropped))
+	}
+	c.kickedUsers.Close()
 	c.isConnected.Store(false)
 	return nil
 }
 
 // PushEvent adds an event to be processed by the Vibecheck feature
-func (c *Vibecheck) PushEvent(event slackevents.EventsAPIEvent) {
+func (c *Vibecheck) PushEvent(e event.Event) {
 	if !c.isConnected.Load() {
 		return
 	}
 
 	select {
-	case c.eventsCh <- event:
+	case c.eventsCh <- e:
 		// Event pushed successfully
 	default:
 		c.log.Warn("Vibecheck events channel full, dropping event.")
+		c.status.Dropped(e.Channel, status.DropQueueFull)
 	}
 }
 
@@ -117,30 +273,48 @@ func (c *Vibecheck) handleEvents(ctx context.Context) {
 			return
 		case <-ctx.Done():
 			return
-		case event := <-c.eventsCh:
-			c.processEvent(ctx, event)
+		case e := <-c.eventsCh:
+			c.processEvent(e.Context(ctx), e)
 		}
 	}
 }
 
 // processEvent handles a single Slack event
-func (c *Vibecheck) processEvent(ctx context.Context, event slackevents.EventsAPIEvent) {
-	switch event.Type {
-	case slackevents.CallbackEvent:
-		innerEvent := event.InnerEvent
-		switch ev := innerEvent.Data.(type) {
-		case *slackevents.MessageEvent:
-			// Ignore bot messages to prevent loops
-			if ev.BotID != "" || ev.User == "" {
-				return
-			}
-			c.handleMessageEvent(ctx, ev)
-		case *slackevents.MemberJoinedChannelEvent:
-			c.handleMemberJoinedEvent(ctx, ev)
+func (c *Vibecheck) processEvent(ctx context.Context, e event.Event) {
+	c.status.Event()
+	switch ev := e.Data().(type) {
+	case *slackevents.MessageEvent:
+		if ev.SubType == "message_changed" {
+			c.handleEdit(ctx, ev)
+			return
+		}
+		// Ignore bot messages to prevent loops, and system subtypes like channel joins
+		if !c.subtypes.Allow(ev) || !c.canPost(ctx, e) {
+			return
 		}
+		c.handleMessageEvent(ctx, ev)
+	case *slackevents.ReactionAddedEvent:
+		if c.canPost(ctx, e) {
+			c.handleReactionAddedEvent(ctx, ev)
+		}
+	case *slackevents.MemberJoinedChannelEvent:
+		c.handleMemberJoinedEvent(ctx, ev)
 	}
 }
 
+// canPost reports whether the feature should respond in the event's channel
+func (c *Vibecheck) canPost(ctx context.Context, e event.Event) bool {
+	if c.silencer != nil && c.silencer.Silenced(e.Channel) {
+		c.log.Debug("Channel silenced, skipping event", zap.String("channel", e.Channel), zap.String("event", e.Type))
+		return false
+	}
+	if c.presence != nil && !c.presence.Present(ctx, e.Channel) {
+		c.log.Debug("Bot was removed from channel, skipping event", zap.String("channel", e.Channel), zap.String("event", e.Type))
+		return false
+	}
+	return true
+}
+
 // handleMessageEvent processes a message event and responds if it matches a pattern
 func (c *Vibecheck) handleMessageEvent(ctx context.Context, ev *slackevents.MessageEvent) {
 	message := strings.TrimSpace(ev.Text)
@@ -166,68 +340,229 @@ func (c *Vibecheck) handleMessageEvent(ctx context.Context, ev *slackevents.Mess
 		c.log.Info("Message matched vibecheck pattern.",
 			zap.String("channel", ev.Channel),
 		)
-
-		weight := 0.8
-		if time.Now().Local().Weekday() == time.Wednesday {
-			weight = 0.2
+		if c.claims != nil && !c.claims.Claim(ctx, c.ProcessorType(), ev.Channel, ev.TimeStamp) {
+			c.status.Dropped(ev.Channel, status.DropClaimed)
+			return
 		}
+		c.check(ctx, ev, message, "")
+	}
+}
+
+// handleEdit undoes the verdict on a message edited so it no longer mentions vibes
+func (c *Vibecheck) handleEdit(ctx context.Context, ev *slackevents.MessageEvent) {
+	if ev.Message == nil || !c.edits.Responded(ev.Channel, ev.Message.Timestamp) || pattern.MatchString(ev.Message.Text) {
+		return
+	}
+	result, err := c.edits.Retract(ctx, ev.Channel, ev.Message.Timestamp)
+	if err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to retract verdict on edited message",
+			zap.String("channel", ev.Channel),
+			zap.String("ts", ev.Message.Timestamp),
+			zap.Error(err),
+		)
+	}
+	if result == (retract.Result{}) {
+		return
+	}
+	c.log.Info("Retracted verdict on edited message",
+		zap.String("channel", ev.Channel),
+		zap.String("ts", ev.Message.Timestamp),
+		zap.Int("reactions", result.Reactions),
+		zap.Int("replies", result.Replies),
+	)
+}
 
-		passed := random.Bool(weight)
-		reaction := "vibecheck"
-		if passed {
-			reaction = "ok"
+// check judges the author of a message, posting the verdict and banning them if they
+// fail. requester is set when someone else asked for the vibecheck.
+func (c *Vibecheck) check(ctx context.Context, ev *slackevents.MessageEvent, message, requester string) {
+	passed, err := c.judge.Judge(ctx, judgeRequest{
+		UserID:    ev.User,
+		ChannelID: ev.Channel,
+		Text:      message,
+		Time:      time.Now(),
+	})
+	if err != nil {
+		c.status.Error(err)
+		c.log.Warn("Vibecheck judgement failed, used random fallback",
+			zap.String("strategy", c.config.Judgement.Strategy),
+			zap.Error(err),
+		)
+	}
+	preferred := c.preferred(ev.User, ev.Username)
+	// Only checks the message's own text asked for are undone when it's edited
+	var edits *retract.Index
+	if requester == "" {
+		edits = c.edits
+	}
+	if !passed && !preferred && c.immunity.Consume(ev.User) {
+		c.useImmunity(ctx, ev, edits)
+		return
+	}
+
+	reaction := "vibecheck"
+	if passed {
+		reaction = "ok"
+	}
+	err = c.slack.Client().AddReactionContext(ctx, reaction, slack.NewRefToMessage(ev.Channel, ev.TimeStamp))
+	if err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to add reaction",
+			zap.String("channel", ev.Channel),
+			zap.String("user", ev.User),
+			zap.Error(err),
+		)
+	} else {
+		edits.Reacted(ev.Channel, ev.TimeStamp, reaction)
+	}
+
+	response := randomResponse(passed, c.fileConfig)
+	if passed {
+		if c.immunity.RecordPass(ev.User) {
+			response += fmt.Sprintf("\n🛡️ <@%s> earned a vibecheck immunity token (%d held)", ev.User, c.immunity.Balance(ev.User))
 		}
-		err := c.slack.Client().AddReactionContext(ctx, reaction, slack.NewRefToMessage(ev.Channel, ev.TimeStamp))
-		if err != nil {
-			c.log.Error("Failed to add reaction",
+	} else {
+		c.immunity.RecordFail(ev.User)
+	}
+	if requester != "" {
+		response += fmt.Sprintf("\n_Vibecheck requested by <@%s>_", requester)
+	}
+	if ts, err := c.postVerdict(ctx, ev, response); err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to post response",
+			zap.String("channel", ev.Channel),
+			zap.Error(err),
+		)
+	} else {
+		c.status.Posted()
+		edits.Replied(ev.Channel, ev.TimeStamp, ts)
+	}
+
+	kind := conversation.KindFromEventType(ev.ChannelType, ev.Channel)
+	if !passed && !preferred && c.config.Jail.punishment(ev.Channel) == PunishmentJail {
+		c.jail(ctx, ev)
+	} else if !passed && !preferred && !kind.CanKick() {
+		c.log.Info("Members can't be removed from this kind of conversation, skipping the ban",
+			zap.String("channel", ev.Channel),
+			zap.String("kind", string(kind)),
+		)
+	} else if !passed && !preferred {
+		c.scheduleKick(5*time.Second, ev.Channel, ev.User, func(ctx context.Context) {
+			if err := c.kick(ctx, kind, ev.Channel, ev.User); err != nil {
+				c.status.PostFailed(ev.Channel, err)
+				c.log.Error("Failed to kick user from channel",
+					zap.String("channel", ev.Channel),
+					zap.String("user", ev.User),
+					zap.Error(err),
+				)
+				return
+			}
+			// The ban is only saved once the user is out, so a kick dropped by Stop isn't
+			// followed by a reinvite
+			c.kickedUsers.AddKickedUser(ev.User, ev.Channel, c.config.BanDuration)
+			c.log.Info("User kicked from channel due to low vibe.",
 				zap.String("channel", ev.Channel),
 				zap.String("user", ev.User),
-				zap.Error(err),
 			)
-		}
+		})
+	}
+}
 
-		response := randomResponse(passed, c.fileConfig)
-		msgOptions := []slack.MsgOption{
-			slack.MsgOptionText(response, false),
-			slack.MsgOptionAsUser(true),
-		}
-		if ev.ThreadTimeStamp != "" {
-			msgOptions = append(msgOptions, slack.MsgOptionTS(ev.ThreadTimeStamp))
-		}
-		_, _, err = c.slack.Client().PostMessageContext(
-			ctx,
-			ev.Channel,
-			msgOptions...,
+// scheduleKick runs a kick after delay on the feature's group of the shared delayed
+// tasks, which is cancelled on Stop. A kick already pending for the user in the channel isn't repeated.
+func (c *Vibecheck) scheduleKick(delay time.Duration, channelID, userID string, fn func(ctx context.Context)) {
+	if !c.kicks.After(channelID+"/"+userID, delay, fn) {
+		c.log.Warn("Kick not scheduled, one is already pending or the limit was reached",
+			zap.String("channel", channelID),
+			zap.String("user", userID),
+			zap.Int("pending", c.kicks.Pending()),
 		)
-		if err != nil {
-			c.log.Error("Failed to post response",
-				zap.String("channel", ev.Channel),
-				zap.Error(err),
-			)
-		}
+		return
+	}
+	c.log.Debug("Kick scheduled",
+		zap.String("channel", channelID),
+		zap.String("user", userID),
+		zap.Duration("delay", delay),
+		zap.Int("pending", c.kicks.Pending()),
+	)
+}
 
-		if !passed && !slices.Contains(c.config.PreferredUsers, ev.User) && !slices.Contains(c.config.PreferredUsers, ev.Username) {
-			// Add user to the kicked users list with configured timeout
-			c.kickedUsers.AddKickedUser(ev.User, ev.Channel, c.config.BanDuration)
+// kick removes a user from a conversation, retrying transient Slack failures
+func (c *Vibecheck) kick(ctx context.Context, kind conversation.Kind, channelID, userID string) error {
+	return c.slack.Retry(ctx, "conversations.kick", func() error {
+		return conversation.Kick(ctx, c.slack.Client(), kind, channelID, userID)
+	})
+}
 
-			time.AfterFunc(5*time.Second, func() {
-				if err := c.slack.Client().KickUserFromConversationContext(ctx, ev.Channel, ev.User); err != nil {
-					c.log.Error("Failed to kick user from channel",
-						zap.String("channel", ev.Channel),
-						zap.String("user", ev.User),
-						zap.Error(err),
-					)
-				} else {
-					c.log.Info("User kicked from channel due to low vibe.",
-						zap.String("channel", ev.Channel),
-						zap.String("user", ev.User),
-					)
-				}
-			})
-		}
+// preferred reports whether a user is exempt from bans and on-demand vibechecks
+func (c *Vibecheck) preferred(userID, username string) bool {
+	return slices.Contains(c.config.PreferredUsers, userID) || (username != "" && slices.Contains(c.config.PreferredUsers, username))
+}
+
+// useImmunity announces that a spent immunity token cancelled a failed vibecheck. The
+// announcement always goes to the channel, or the thread if the message was threaded.
+// Edits to the message undo the announcement when edits is set.
+func (c *Vibecheck) useImmunity(ctx context.Context, ev *slackevents.MessageEvent, edits *retract.Index) {
+	c.log.Info("Immunity token cancelled failed vibecheck",
+		zap.String("channel", ev.Channel),
+		zap.String("user", ev.User),
+	)
+
+	if err := c.slack.Client().AddReactionContext(ctx, "shield", slack.NewRefToMessage(ev.Channel, ev.TimeStamp)); err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to add reaction",
+			zap.String("channel", ev.Channel),
+			zap.String("user", ev.User),
+			zap.Error(err),
+		)
+	} else {
+		edits.Reacted(ev.Channel, ev.TimeStamp, "shield")
+	}
+
+	message := fmt.Sprintf("🛡️ <@%s> failed the vibecheck but spent an immunity token (%d left)", ev.User, c.immunity.Balance(ev.User))
+	msgOptions := []slack.MsgOption{
+		slack.MsgOptionText(message, false),
+		c.config.Persona.MsgOption(),
+	}
+	if ev.ThreadTimeStamp != "" {
+		msgOptions = append(msgOptions, slack.MsgOptionTS(ev.ThreadTimeStamp))
+	}
+	if _, ts, err := c.slack.Client().PostMessageContext(ctx, ev.Channel, msgOptions...); err != nil {
+		c.status.PostFailed(ev.Channel, err)
+		c.log.Error("Failed to post immunity announcement",
+			zap.String("channel", ev.Channel),
+			zap.Error(err),
+		)
+	} else {
+		c.status.Posted()
+		edits.Replied(ev.Channel, ev.TimeStamp, ts)
 	}
 }
 
+// postVerdict posts the vibecheck response according to the configured reply mode,
+// returning its timestamp unless it was ephemeral
+func (c *Vibecheck) postVerdict(ctx context.Context, ev *slackevents.MessageEvent, response string) (string, error) {
+	msgOptions := []slack.MsgOption{
+		slack.MsgOptionText(response, false),
+		c.config.Persona.MsgOption(),
+	}
+
+	threadTS := ev.ThreadTimeStamp
+	if threadTS == "" && c.config.ReplyMode != ReplyModeChannel && c.config.ReplyMode != "" {
+		threadTS = ev.TimeStamp
+	}
+	if threadTS != "" {
+		msgOptions = append(msgOptions, slack.MsgOptionTS(threadTS))
+	}
+
+	if c.config.ReplyMode == ReplyModeEphemeral {
+		_, err := c.slack.Client().PostEphemeralContext(ctx, ev.Channel, ev.User, msgOptions...)
+		return "", err
+	}
+	_, ts, err := c.slack.Client().PostMessageContext(ctx, ev.Channel, msgOptions...)
+	return ts, err
+}
+
 // handleMemberJoinedEvent checks if a user rejoining a channel is still banned
 func (c *Vibecheck) handleMemberJoinedEvent(ctx context.Context, ev *slackevents.MemberJoinedChannelEvent) {
 	c.log.Debug("Member joined channel",
@@ -235,8 +570,9 @@ func (c *Vibecheck) handleMemberJoinedEvent(ctx context.Context, ev *slackevents
 		zap.String("channel", ev.Channel),
 	)
 
-	// Check if this user is still banned from this channel
-	if user, isBanned := c.kickedUsers.IsUserBanned(ev.User, ev.Channel); isBanned {
+	// Check if this user is still banned from this channel. Jailed users were never
+	// removed, so rejoining is fine.
+	if user, isBanned := c.kickedUsers.IsUserBanned(ev.User, ev.Channel); isBanned && !user.Jailed {
 		timeRemaining := time.Until(user.ReinviteAt)
 		c.log.Info("Banned user attempted to rejoin channel, kicking again",
 			zap.String("user", ev.User),
@@ -245,8 +581,10 @@ func (c *Vibecheck) handleMemberJoinedEvent(ctx context.Context, ev *slackevents
 		)
 
 		// Kick the user again
-		time.AfterFunc(2*time.Second, func() {
-			if err := c.slack.Client().KickUserFromConversationContext(ctx, ev.Channel, ev.User); err != nil {
+		kind := conversation.KindFromEventType(ev.ChannelType, ev.Channel)
+		c.scheduleKick(2*time.Second, ev.Channel, ev.User, func(ctx context.Context) {
+			if err := c.kick(ctx, kind, ev.Channel, ev.User); err != nil {
+				c.status.PostFailed(ev.Channel, err)
 				c.log.Error("Failed to re-kick banned user from channel",
 					zap.String("channel", ev.Channel),
 					zap.String("user", ev.User),
@@ -261,27 +599,22 @@ func (c *Vibecheck) handleMemberJoinedEvent(ctx context.Context, ev *slackevents
 		})
 
 		// Post a message about the remaining ban time
-		minutes := int(timeRemaining.Minutes())
-		seconds := int(timeRemaining.Seconds()) % 60
-		var timeMessage string
-		if minutes > 0 {
-			timeMessage = fmt.Sprintf("%d minutes and %d seconds", minutes, seconds)
-		} else {
-			timeMessage = fmt.Sprintf("%d seconds", seconds)
-		}
-
-		message := fmt.Sprintf("🚫 User is still banned for %s. Please wait before rejoining.", timeMessage)
+		message := fmt.Sprintf("🚫 User is still banned for %s, until %s. Please wait before rejoining.",
+			timeformat.Duration(timeRemaining), timeformat.Slack(user.ReinviteAt, timeformat.Time))
 		_, _, err := c.slack.Client().PostMessageContext(
 			ctx,
 			ev.Channel,
 			slack.MsgOptionText(message, false),
-			slack.MsgOptionAsUser(true),
+			c.config.Persona.MsgOption(),
 		)
 		if err != nil {
+			c.status.PostFailed(ev.Channel, err)
 			c.log.Error("Failed to post ban time remaining message",
 				zap.String("channel", ev.Channel),
 				zap.Error(err),
 			)
+		} else {
+			c.status.Posted()
 		}
 	}
 }
@@ -316,6 +649,17 @@ func (c *Vibecheck) processReinvites(ctx context.Context) {
 	}
 
 	for _, user := range usersToReinvite {
+		if user.Jailed {
+			c.release(ctx, user)
+			continue
+		}
+		if c.presence != nil && !c.presence.Present(ctx, user.ChannelID) {
+			c.log.Info("Bot was removed from channel, skipping reinvite",
+				zap.String("user_id", user.UserID),
+				zap.String("channel_id", user.ChannelID),
+			)
+			continue
+		}
 		c.log.Debug("Attempting to reinvite user",
 			zap.String("user_id", user.UserID),
 			zap.String("channel_id", user.ChannelID),
@@ -330,6 +674,8 @@ func (c *Vibecheck) processReinvites(ctx context.Context) {
 		)
 
 		if err != nil {
+			err = conversation.Error("conversations.invite", conversation.KindFromID(user.ChannelID), err)
+			c.status.PostFailed(user.ChannelID, err)
 			c.log.Error("Failed to reinvite user to channel",
 				zap.String("channel", user.ChannelID),
 				zap.String("user", user.UserID),
@@ -342,6 +688,7 @@ func (c *Vibecheck) processReinvites(ctx context.Context) {
 				zap.Time("kicked_at", user.KickedAt),
 				zap.Time("reinvited_at", time.Now()),
 			)
+			c.welcomeBack(ctx, user)
 		}
 	}
 
@@ -1,10 +1,24 @@
 package vibecheck
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
 	"go.uber.org/zap"
+	"slackbot.arpa/bot/scheduler"
+	"slackbot.arpa/tools/emoji"
 )
 
 func TestConfig_BanDuration(t *testing.T) {
@@ -89,4 +103,554 @@ func TestHandleMemberJoinedEvent_UserBanned(t *testing.T) {
 	if timeRemaining <= 0 {
 		t.Error("Expected positive time remaining for banned user")
 	}
-}
\ No newline at end of file
+}
+func TestKickedUsersManager_JournalReplayAndCompaction(t *testing.T) {
+	dir := t.TempDir()
+	logger := zap.NewNop()
+
+	manager := newKickedUsersManager(logger, dir)
+	manager.AddKickedUser("u1", "c1", 5*time.Minute)
+	manager.AddKickedUser("u2", "c1", -time.Minute)
+	manager.AddKickedUser("u3", "c2", 5*time.Minute)
+
+	if got := manager.GetUsersToReinvite(); len(got) != 1 || got[0].UserID != "u2" {
+		t.Fatalf("Expected only u2 to be reinvited, got %+v", got)
+	}
+	if got := manager.PendingInChannel("c1"); len(got) != 1 || got[0].UserID != "u1" {
+		t.Errorf("Expected u1 pending in c1, got %+v", got)
+	}
+
+	// Simulate a crash: changes exist only in the journal plus a torn final line
+	if _, err := manager.journal.WriteString(`{"op":"put","key":"u4:c3","us`); err != nil {
+		t.Fatalf("Failed to write torn journal entry: %v", err)
+	}
+
+	reloaded := newKickedUsersManager(logger, dir)
+	if _, banned := reloaded.IsUserBanned("u1", "c1"); !banned {
+		t.Error("Expected u1 to remain banned after replay")
+	}
+	if _, banned := reloaded.IsUserBanned("u3", "c2"); !banned {
+		t.Error("Expected u3 to remain banned after replay")
+	}
+	if len(reloaded.users) != 3 {
+		t.Errorf("Expected 3 users after replay, got %d", len(reloaded.users))
+	}
+	if got := reloaded.GetUsersToReinvite(); len(got) != 0 {
+		t.Errorf("Expected reinvited state to persist, got %+v", got)
+	}
+
+	// Startup compaction folds the journal into the snapshot
+	if _, err := os.Stat(filepath.Join(dir, kickedJournalFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected journal to be compacted on load, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, kickedUsersFile+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("Expected no leftover temp file, stat err = %v", err)
+	}
+}
+
+func TestKickedUsersManager_CleanupCompactsJournal(t *testing.T) {
+	dir := t.TempDir()
+	manager := newKickedUsersManager(zap.NewNop(), dir)
+	manager.minCompactCount = 2
+
+	manager.AddKickedUser("u1", "c1", -48*time.Hour)
+	manager.GetUsersToReinvite()
+	manager.CleanupReinvitedUsers()
+
+	if len(manager.users) != 0 {
+		t.Errorf("Expected reinvited user to be cleaned up, got %d users", len(manager.users))
+	}
+	if manager.journalEntries != 0 {
+		t.Errorf("Expected journal to be compacted, got %d entries", manager.journalEntries)
+	}
+	manager.Close()
+
+	reloaded := newKickedUsersManager(zap.NewNop(), dir)
+	if len(reloaded.users) != 0 {
+		t.Errorf("Expected empty snapshot after cleanup, got %d users", len(reloaded.users))
+	}
+}
+
+func TestNewJudge_Strategies(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  JudgementConfig
+		want    string
+		wantErr bool
+	}{
+		{"default", JudgementConfig{}, "*vibecheck.randomJudge", false},
+		{"streak", JudgementConfig{Strategy: StrategyStreak}, "*vibecheck.streakJudge", false},
+		{"webhook", JudgementConfig{Strategy: StrategyWebhook, WebhookURL: "http://localhost"}, "*vibecheck.fallbackJudge", false},
+		{"webhook without url", JudgementConfig{Strategy: StrategyWebhook}, "", true},
+		{"llm without ai", JudgementConfig{Strategy: StrategyLLM}, "", true},
+		{"unknown", JudgementConfig{Strategy: "coin"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := newJudge(tt.config, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newJudge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := fmt.Sprintf("%T", j); !tt.wantErr && got != tt.want {
+				t.Errorf("newJudge() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomJudge_WednesdayWeight(t *testing.T) {
+	j := &randomJudge{passWeight: 0.8, wednesdayWeight: 0.2}
+	wednesday := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	thursday := wednesday.AddDate(0, 0, 1)
+
+	if got := j.weight(wednesday); got != 0.2 {
+		t.Errorf("weight(Wednesday) = %v, want 0.2", got)
+	}
+	if got := j.weight(thursday); got != 0.8 {
+		t.Errorf("weight(Thursday) = %v, want 0.8", got)
+	}
+}
+
+func TestStreakJudge_ConsecutivePassesLowerOdds(t *testing.T) {
+	j := &streakJudge{
+		base:    &randomJudge{passWeight: 1, wednesdayWeight: 1},
+		penalty: 0.3,
+		min:     0.2,
+		streaks: map[string]int{"u1": 2},
+	}
+	now := time.Now()
+
+	if got := j.passWeight("u1", now); math.Abs(got-0.4) > 1e-9 {
+		t.Errorf("passWeight after 2 passes = %v, want 0.4", got)
+	}
+	j.streaks["u1"] = 10
+	if got := j.passWeight("u1", now); got != 0.2 {
+		t.Errorf("passWeight should be clamped to min, got %v", got)
+	}
+	if got := j.passWeight("u2", now); got != 1 {
+		t.Errorf("passWeight for a new user = %v, want 1", got)
+	}
+}
+
+func TestWebhookJudge(t *testing.T) {
+	var got judgeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"passed": false}`))
+	}))
+	defer server.Close()
+
+	j := &webhookJudge{url: server.URL, client: server.Client()}
+	passed, err := j.Judge(context.Background(), judgeRequest{UserID: "u1", ChannelID: "c1", Text: "nice vibes"})
+	if err != nil {
+		t.Fatalf("Judge() error = %v", err)
+	}
+	if passed {
+		t.Error("Judge() should use the webhook verdict")
+	}
+	if got.UserID != "u1" || got.Text != "nice vibes" {
+		t.Errorf("Webhook received %+v", got)
+	}
+}
+
+func TestFallbackJudge_UsesFallbackOnError(t *testing.T) {
+	j := &fallbackJudge{
+		primary:  &webhookJudge{url: "http://127.0.0.1:0", client: &http.Client{Timeout: time.Second}},
+		fallback: &randomJudge{passWeight: 1, wednesdayWeight: 1},
+	}
+	passed, err := j.Judge(context.Background(), judgeRequest{Text: "vibe"})
+	if err == nil {
+		t.Error("Judge() should report the primary error")
+	}
+	if !passed {
+		t.Error("Judge() should return the fallback verdict")
+	}
+}
+
+func TestParseVerdict(t *testing.T) {
+	for input, want := range map[string]bool{"PASS": true, " pass.\n": true, "Fail": false} {
+		got, err := parseVerdict(input)
+		if err != nil || got != want {
+			t.Errorf("parseVerdict(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := parseVerdict("maybe"); err == nil {
+		t.Error("parseVerdict should reject unexpected answers")
+	}
+}
+
+type mockSlack struct {
+	client *slack.Client
+}
+
+func (m *mockSlack) Client() *slack.Client { return m.client }
+
+func (m *mockSlack) BotUserID() string { return "UBOT" }
+
+func (m *mockSlack) Emoji() *emoji.Catalog { return nil }
+
+func (m *mockSlack) Retry(ctx context.Context, op string, fn func() error) error { return fn() }
+
+func (m *mockSlack) PostDM(ctx context.Context, userID, kind string, opts ...slack.MsgOption) (string, error) {
+	_, ts, err := m.client.PostMessageContext(ctx, userID, opts...)
+	return ts, err
+}
+
+func TestVibecheck_PostVerdict_ReplyModes(t *testing.T) {
+	type call struct {
+		method   string
+		threadTS string
+		user     string
+	}
+	var calls []call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		calls = append(calls, call{
+			method:   strings.TrimPrefix(r.URL.Path, "/"),
+			threadTS: r.FormValue("thread_ts"),
+			user:     r.FormValue("user"),
+		})
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1", "message_ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	client := slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+	ev := &slackevents.MessageEvent{User: "U1", Channel: "C1", TimeStamp: "100.1", Text: "vibe"}
+
+	tests := []struct {
+		mode string
+		want call
+	}{
+		{ReplyModeChannel, call{method: "chat.postMessage"}},
+		{ReplyModeThread, call{method: "chat.postMessage", threadTS: "100.1"}},
+		{ReplyModeEphemeral, call{method: "chat.postEphemeral", threadTS: "100.1", user: "U1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			calls = nil
+			c := &Vibecheck{config: Config{ReplyMode: tt.mode}, slack: &mockSlack{client: client}}
+			if _, err := c.postVerdict(context.Background(), ev, "ok"); err != nil {
+				t.Fatalf("postVerdict() error = %v", err)
+			}
+			if len(calls) != 1 || calls[0] != tt.want {
+				t.Errorf("postVerdict() calls = %+v, want %+v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestImmunityLedger(t *testing.T) {
+	dir := t.TempDir()
+	streak, maxTokens := 2, 1
+	config := ImmunityConfig{StreakLength: &streak, MaxTokens: &maxTokens}
+	l := newImmunityLedger(zap.NewNop(), config, dir)
+
+	if l.RecordPass("U1") {
+		t.Error("RecordPass() earned a token before the streak was reached")
+	}
+	if !l.RecordPass("U1") {
+		t.Error("RecordPass() should earn a token at the streak length")
+	}
+	l.RecordPass("U1")
+	if l.RecordPass("U1") {
+		t.Error("RecordPass() should not earn past max_tokens")
+	}
+
+	reloaded := newImmunityLedger(zap.NewNop(), config, dir)
+	if got := reloaded.Balance("U1"); got != 1 {
+		t.Fatalf("Balance() after reload = %d, want 1", got)
+	}
+	if !reloaded.Consume("U1") {
+		t.Error("Consume() should spend the held token")
+	}
+	if reloaded.Consume("U1") {
+		t.Error("Consume() should fail with no tokens")
+	}
+	if got := reloaded.Grant("U1", 5); got != 1 {
+		t.Errorf("Grant() = %d, want capped at 1", got)
+	}
+
+	disabled := newImmunityLedger(zap.NewNop(), ImmunityConfig{}, t.TempDir())
+	for range 10 {
+		if disabled.RecordPass("U1") {
+			t.Fatal("RecordPass() should not earn tokens when streak_length is unset")
+		}
+	}
+}
+
+func TestVibecheck_ImmunityCancelsFailure(t *testing.T) {
+	var methods []string
+	var reaction string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		methods = append(methods, method)
+		if method == "reactions.add" {
+			reaction = r.FormValue("name")
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := &Vibecheck{
+		log:         zap.NewNop(),
+		slack:       &mockSlack{client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))},
+		kickedUsers: newKickedUsersManager(zap.NewNop(), dir),
+		immunity:    newImmunityLedger(zap.NewNop(), ImmunityConfig{}, dir),
+		dedupe:      newMessageDeduplicator(time.Minute),
+		judge:       &randomJudge{},
+	}
+	if got := c.GrantImmunity("U1", 1); got != 1 {
+		t.Fatalf("GrantImmunity() = %d, want 1", got)
+	}
+
+	c.handleMessageEvent(context.Background(), &slackevents.MessageEvent{User: "U1", Channel: "C1", TimeStamp: "100.1", Text: "vibe"})
+
+	if reaction != "shield" || len(methods) != 2 || methods[1] != "chat.postMessage" {
+		t.Errorf("calls = %v with reaction %q, want shield reaction and announcement", methods, reaction)
+	}
+	if _, banned := c.kickedUsers.IsUserBanned("U1", "C1"); banned {
+		t.Error("user with an immunity token should not be banned")
+	}
+	if got := c.ImmunityBalance("U1"); got != 0 {
+		t.Errorf("ImmunityBalance() = %d, want 0", got)
+	}
+}
+
+func TestVibecheck_NoBanInGroupDM(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, strings.TrimPrefix(r.URL.Path, "/"))
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "G1", "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := &Vibecheck{
+		log:         zap.NewNop(),
+		slack:       &mockSlack{client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))},
+		kickedUsers: newKickedUsersManager(zap.NewNop(), dir),
+		immunity:    newImmunityLedger(zap.NewNop(), ImmunityConfig{}, dir),
+		dedupe:      newMessageDeduplicator(time.Minute),
+		judge:       &randomJudge{},
+	}
+
+	c.handleMessageEvent(context.Background(), &slackevents.MessageEvent{User: "U1", Channel: "G1", ChannelType: "mpim", TimeStamp: "100.1", Text: "vibe"})
+
+	if _, banned := c.kickedUsers.IsUserBanned("U1", "G1"); banned {
+		t.Error("failing a vibecheck in a group DM should not ban the user")
+	}
+	if slices.Contains(methods, "conversations.kick") {
+		t.Errorf("calls = %v, want no kick in a group DM", methods)
+	}
+}
+
+func TestVibecheck_OnDemandReaction(t *testing.T) {
+	var methods []string
+	var verdict string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		methods = append(methods, method)
+		switch method {
+		case "conversations.history":
+			ts := r.FormValue("latest")
+			_, _ = fmt.Fprintf(w, `{"ok": true, "messages": [{"type": "message", "user": "U2", "text": "hello", "ts": %q}]}`, ts)
+		case "chat.postMessage":
+			verdict = r.FormValue("text")
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	reaction := ":vibecheck:"
+	c := &Vibecheck{
+		log:         zap.NewNop(),
+		config:      Config{PreferredUsers: []string{"U3"}, OnDemand: OnDemandConfig{Reaction: &reaction}},
+		slack:       &mockSlack{client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))},
+		kickedUsers: newKickedUsersManager(zap.NewNop(), dir),
+		immunity:    newImmunityLedger(zap.NewNop(), ImmunityConfig{}, dir),
+		cooldowns:   newRequesterCooldowns(),
+		dedupe:      newMessageDeduplicator(time.Minute),
+		judge:       &randomJudge{passWeight: 1},
+	}
+	react := func(user, itemUser, name, ts string) {
+		c.handleReactionAddedEvent(context.Background(), &slackevents.ReactionAddedEvent{
+			User:     user,
+			ItemUser: itemUser,
+			Reaction: name,
+			Item:     slackevents.Item{Type: "message", Channel: "C1", Timestamp: ts},
+		})
+	}
+
+	react("U1", "U2", "thumbsup", "100.1")
+	react("UBOT", "U2", "vibecheck", "100.1")
+	react("U1", "U3", "vibecheck", "100.1")
+	if len(methods) != 0 {
+		t.Fatalf("calls = %v, want other reactions, the bot's own and preferred users ignored", methods)
+	}
+
+	react("U1", "U2", "vibecheck", "100.1")
+	want := []string{"conversations.history", "reactions.add", "chat.postMessage"}
+	if !slices.Equal(methods, want) {
+		t.Fatalf("calls = %v, want %v", methods, want)
+	}
+	if !strings.Contains(verdict, "requested by <@U1>") {
+		t.Errorf("verdict = %q, want the requester credited", verdict)
+	}
+
+	methods = nil
+	react("U1", "U2", "vibecheck", "200.1")
+	if want := []string{"conversations.history", "chat.postEphemeral"}; !slices.Equal(methods, want) {
+		t.Errorf("calls during cooldown = %v, want %v", methods, want)
+	}
+}
+
+func TestVibecheck_KickDroppedOnStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := &Vibecheck{
+		log:         zap.NewNop(),
+		config:      Config{BanDuration: 5 * time.Minute},
+		slack:       &mockSlack{client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))},
+		kickedUsers: newKickedUsersManager(zap.NewNop(), dir),
+		immunity:    newImmunityLedger(zap.NewNop(), ImmunityConfig{}, dir),
+		kicks:       scheduler.NewTasks(0).Group(),
+		dedupe:      newMessageDeduplicator(time.Minute),
+		judge:       &randomJudge{},
+	}
+
+	c.handleMessageEvent(context.Background(), &slackevents.MessageEvent{User: "U1", Channel: "C1", TimeStamp: "100.1", Text: "vibe"})
+	if c.kicks.Pending() != 1 {
+		t.Fatalf("Pending() = %d, want the kick scheduled", c.kicks.Pending())
+	}
+	if bans := c.Bans(); len(bans) != 0 {
+		t.Errorf("Bans() = %+v before the kick, want none", bans)
+	}
+
+	// A kick dropped on Stop leaves no ban behind to be reinvited from
+	if dropped := c.kicks.Stop(); dropped != 1 {
+		t.Errorf("Stop() = %d, want the pending kick dropped", dropped)
+	}
+	if bans := newKickedUsersManager(zap.NewNop(), dir).Pending(); len(bans) != 0 {
+		t.Errorf("saved bans = %+v, want none for a kick that never ran", bans)
+	}
+}
+
+func TestVibecheck_Jail(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		calls = append(calls, strings.TrimPrefix(r.URL.Path, "/")+" "+r.FormValue("channel")+" "+r.FormValue("text"))
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	jail := "CJAIL"
+	c := &Vibecheck{
+		log:         zap.NewNop(),
+		config:      Config{BanDuration: 5 * time.Minute, Jail: JailConfig{Channel: &jail, Channels: map[string]string{"C1": PunishmentJail}}},
+		slack:       &mockSlack{client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))},
+		kickedUsers: newKickedUsersManager(zap.NewNop(), dir),
+		immunity:    newImmunityLedger(zap.NewNop(), ImmunityConfig{}, dir),
+		kicks:       scheduler.NewTasks(0).Group(),
+		dedupe:      newMessageDeduplicator(time.Minute),
+		judge:       &randomJudge{},
+	}
+	defer c.kicks.Stop()
+
+	c.handleMessageEvent(context.Background(), &slackevents.MessageEvent{User: "U1", Channel: "C1", TimeStamp: "100.1", Text: "vibe"})
+
+	want := []string{
+		"conversations.invite CJAIL ",
+		"chat.postMessage CJAIL 🚔 <@U1> is sentenced to 5m in vibe jail for failing the vibecheck in <#C1>",
+	}
+	if len(calls) < 2 || !slices.Equal(calls[len(calls)-2:], want) {
+		t.Errorf("calls = %q, want the user invited to jail and sentenced", calls)
+	}
+	if c.kicks.Pending() != 0 {
+		t.Error("a jailed user should not be kicked")
+	}
+	bans := c.Bans()
+	if len(bans) != 1 || bans[0].Punishment != PunishmentJail {
+		t.Errorf("Bans() = %+v, want one jail sentence", bans)
+	}
+
+	// The sentence ends with a release announcement rather than a reinvite
+	calls = nil
+	c.kickedUsers.AddJailedUser("U1", "C1", -time.Minute)
+	c.processReinvites(context.Background())
+	if len(calls) != 1 || calls[0] != "chat.postMessage CJAIL 🔓 <@U1> served their time and is released from vibe jail" {
+		t.Errorf("calls = %q, want only the release announced", calls)
+	}
+}
+
+func TestVibecheck_WelcomeBack(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		calls = append(calls, strings.TrimPrefix(r.URL.Path, "/")+" "+r.FormValue("channel")+" "+r.FormValue("text"))
+		if r.URL.Path == "/conversations.invite" {
+			_, _ = w.Write([]byte(`{"ok": true, "channel": {"id": "C1"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1.1"}`))
+	}))
+	defer server.Close()
+
+	enabled, message := true, "{{.User}} is back in {{.Channel}}"
+	c := &Vibecheck{
+		log:         zap.NewNop(),
+		config:      Config{WelcomeBack: WelcomeBackConfig{Enabled: &enabled, Message: &message, DM: &enabled}},
+		slack:       &mockSlack{client: slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))},
+		kickedUsers: newKickedUsersManager(zap.NewNop(), t.TempDir()),
+	}
+	c.kickedUsers.AddKickedUser("U1", "C1", -time.Minute)
+	c.processReinvites(context.Background())
+
+	want := []string{
+		"conversations.invite C1 ",
+		"chat.postMessage C1 <@U1> is back in <#C1>",
+		"chat.postMessage U1 <@U1> is back in <#C1>",
+	}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want the user reinvited and welcomed back in the channel and a DM", calls)
+	}
+
+	// Disabled by default
+	calls = nil
+	c.config.WelcomeBack = WelcomeBackConfig{}
+	c.kickedUsers.AddKickedUser("U1", "C1", -time.Minute)
+	c.processReinvites(context.Background())
+	if len(calls) != 1 {
+		t.Errorf("calls = %q, want only the silent reinvite", calls)
+	}
+}
+
+// BenchmarkMessageDeduplicator_IsDupe measures checking recent messages, each seen more
+// than once the way Slack's retries arrive
+func BenchmarkMessageDeduplicator_IsDupe(b *testing.B) {
+	d := newMessageDeduplicator(time.Minute)
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("1700000000.%06d", i)
+	}
+
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		id := ids[i%len(ids)]
+		d.IsDupe("U1", "C1", id)
+		d.IsDupe("U1", "C1", id)
+		i++
+	}
+}
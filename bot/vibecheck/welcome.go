@@ -0,0 +1,100 @@
+package vibecheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultWelcomeBack is posted when a banned user is reinvited
+	DefaultWelcomeBack = "👋 Welcome back {{.User}}, vibes restored"
+	// WelcomeBackDMKind is the kind of the welcome back DM, held while the user is in Do
+	// Not Disturb
+	WelcomeBackDMKind = "welcome_back"
+)
+
+// WelcomeBackConfig greets users who are reinvited when their ban expires, instead of
+// reinviting them silently
+type WelcomeBackConfig struct {
+	Enabled *bool `json:"enabled" yaml:"enabled"`
+	// Message is the greeting template, which can use {{.User}} and {{.Channel}} mentions
+	Message *string `json:"message" yaml:"message"`
+	// DM also sends the greeting to the user directly
+	DM *bool `json:"dm" yaml:"dm"`
+}
+
+func (c WelcomeBackConfig) enabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+func (c WelcomeBackConfig) message() string {
+	if c.Message == nil || strings.TrimSpace(*c.Message) == "" {
+		return DefaultWelcomeBack
+	}
+	return *c.Message
+}
+
+// Check reports whether the greeting template parses and renders
+func (c WelcomeBackConfig) Check() error {
+	_, err := c.render("U0123456789", "C0123456789")
+	return err
+}
+
+func (c WelcomeBackConfig) render(userID, channelID string) (string, error) {
+	tmpl, err := template.New("welcome_back").Parse(c.message())
+	if err != nil {
+		return "", fmt.Errorf("parse welcome back template: %w", err)
+	}
+	var buf bytes.Buffer
+	data := struct{ User, Channel string }{User: "<@" + userID + ">", Channel: "<#" + channelID + ">"}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("execute welcome back template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// welcomeBack greets a user who was reinvited to the channel they were kicked from
+func (c *Vibecheck) welcomeBack(ctx context.Context, user kickedUser) {
+	if !c.config.WelcomeBack.enabled() {
+		return
+	}
+	message, err := c.config.WelcomeBack.render(user.UserID, user.ChannelID)
+	if err != nil {
+		c.log.Error("Failed to render welcome back message", zap.Error(err))
+		return
+	}
+
+	_, _, err = c.slack.Client().PostMessageContext(ctx, user.ChannelID,
+		slack.MsgOptionText(message, false),
+		c.config.Persona.MsgOption(),
+	)
+	if err != nil {
+		c.status.PostFailed(user.ChannelID, err)
+		c.log.Error("Failed to post welcome back message",
+			zap.String("channel", user.ChannelID),
+			zap.String("user", user.UserID),
+			zap.Error(err),
+		)
+	} else {
+		c.status.Posted()
+	}
+
+	if c.config.WelcomeBack.DM == nil || !*c.config.WelcomeBack.DM {
+		return
+	}
+	if _, err := c.slack.PostDM(ctx, user.UserID, WelcomeBackDMKind,
+		slack.MsgOptionText(message, false),
+		c.config.Persona.MsgOption(),
+	); err != nil {
+		c.log.Warn("Failed to DM welcome back message",
+			zap.String("user", user.UserID),
+			zap.Error(err),
+		)
+	}
+}
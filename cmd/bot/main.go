@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/urfave/cli/v3"
 	"go.uber.org/zap"
 	"slackbot.arpa/bot"
 	"slackbot.arpa/bot/config"
+	"slackbot.arpa/bot/errs"
 )
 
 var (
@@ -33,10 +37,20 @@ func init() {
 
 func main() {
 	if err := run(context.Background(), os.Args); err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitCode(err))
 	}
 }
 
+// exitCode prefers an explicit CLI exit code and otherwise maps the error category
+func exitCode(err error) int {
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return errs.ExitCode(err)
+}
+
 func run(rootCtx context.Context, args []string) error {
 	rootCtx, stop := signal.NotifyContext(rootCtx, os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	defer stop()
@@ -67,11 +81,14 @@ func run(rootCtx context.Context, args []string) error {
 
 	log := b.Logger()
 	log.Info("Server started.")
+	var startupErr error
 	select {
 	case <-rootCtx.Done():
-	case err := <-svcErr:
-		if err != nil {
-			log.Error("Error during server startup.", zap.Error(err))
+	case startupErr = <-svcErr:
+		if startupErr != nil {
+			log.Error("Error during server startup.",
+				zap.String("category", errs.Category(startupErr)),
+				zap.Error(startupErr))
 		}
 	}
 	stop()
@@ -98,7 +115,7 @@ func run(rootCtx context.Context, args []string) error {
 		log.Error("Error during server force shutdown.", zap.Error(err))
 	}
 	log.Info("Shutdown complete.")
-	return nil
+	return startupErr
 }
 
 func sleepContext(ctx context.Context, duration time.Duration) error {
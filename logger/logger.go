@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"fmt"
 	"os"
+	"sync"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -11,6 +13,7 @@ type LoggerOpts struct {
 	Level        string
 	IsProduction bool
 	JSONConsole  bool // Whether to use JSON encoding for the console output
+	Stderr       bool // Write to stderr instead of stdout, e.g. when stdout is command output
 }
 
 // Use zap WrapCore if interface is required
@@ -22,6 +25,10 @@ func NewZapLogger(opts LoggerOpts) (*zap.Logger, zap.AtomicLevel, error) {
 	if err != nil {
 		return nil, level, err
 	}
+	return zap.New(newCore(opts, level)), level, err
+}
+
+func newCore(opts LoggerOpts, level zapcore.LevelEnabler) zapcore.Core {
 	var ecfg zapcore.EncoderConfig
 	if opts.IsProduction {
 		ecfg = zap.NewProductionEncoderConfig()
@@ -30,42 +37,59 @@ func NewZapLogger(opts LoggerOpts) (*zap.Logger, zap.AtomicLevel, error) {
 	}
 	ecfg.EncodeTime = zapcore.ISO8601TimeEncoder
 
+	console := os.Stdout
+	if opts.Stderr {
+		console = os.Stderr
+	}
 	var cores []zapcore.Core
 	if opts.JSONConsole {
-		if consoleCore := consoleJSONEncoder(ecfg, level); consoleCore != nil {
+		if consoleCore := consoleJSONEncoder(ecfg, level, console); consoleCore != nil {
 			cores = append(cores, consoleCore)
 		}
 	} else {
-		if consoleCore := consoleEncoder(ecfg, level); consoleCore != nil {
+		if consoleCore := consoleEncoder(ecfg, level, console); consoleCore != nil {
 			cores = append(cores, consoleCore)
 		}
 	}
-	core := zapcore.NewTee(cores...)
-	return zap.New(core), level, err
+	return zapcore.NewTee(cores...)
 }
 
 // Core to write pretty output to the console
-func consoleEncoder(ecfg zapcore.EncoderConfig, level zap.AtomicLevel) zapcore.Core {
+func consoleEncoder(ecfg zapcore.EncoderConfig, level zapcore.LevelEnabler, w *os.File) zapcore.Core {
 	ecfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	consoleEncoder := zapcore.NewConsoleEncoder(ecfg)
-	return zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level)
+	return zapcore.NewCore(consoleEncoder, zapcore.AddSync(w), level)
 }
 
 // Core to write only JSON to the console
-func consoleJSONEncoder(ecfg zapcore.EncoderConfig, level zap.AtomicLevel) zapcore.Core {
+func consoleJSONEncoder(ecfg zapcore.EncoderConfig, level zapcore.LevelEnabler, w *os.File) zapcore.Core {
 	consoleEncoder := zapcore.NewJSONEncoder(ecfg)
-	return zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level)
+	return zapcore.NewCore(consoleEncoder, zapcore.AddSync(w), level)
 }
 
 type Logger struct {
-	logger *zap.Logger
-	level  zap.AtomicLevel
+	logger   *zap.Logger
+	level    zap.AtomicLevel
+	base     zapcore.Core // Accepts every level; filtered per logger by levelCore
+	features *featureLevels
 }
 
 // New wrapped Zap logger.
 func NewLogger(opts LoggerOpts) (Logger, error) {
-	logger, level, err := NewZapLogger(opts)
-	return Logger{logger, level}, err
+	if opts.Level == "none" {
+		return NewNoopLogger(), nil
+	}
+	level, err := zap.ParseAtomicLevel(opts.Level)
+	if err != nil {
+		return Logger{}, err
+	}
+	base := newCore(opts, zapcore.DebugLevel)
+	return Logger{
+		logger:   zap.New(levelCore{Core: base, level: level}),
+		level:    level,
+		base:     base,
+		features: &featureLevels{levels: make(map[string]zapcore.Level)},
+	}, nil
 }
 
 func NewNoopLogger() Logger {
@@ -77,8 +101,11 @@ func (l Logger) Get() *zap.Logger {
 	return l.logger
 }
 
-// Change the log level at runtime
+// Change the log level at runtime. A no-op logger ignores level changes.
 func (l Logger) SetLevel(level zapcore.Level) {
+	if l.base == nil {
+		return
+	}
 	l.level.SetLevel(level)
 }
 
@@ -88,6 +115,90 @@ func (l Logger) SetLevelStr(input string) error {
 	if err != nil {
 		return err
 	}
-	l.level.SetLevel(level.Level())
+	l.SetLevel(level.Level())
+	return nil
+}
+
+// Named returns a child logger for a feature. It logs at the feature's level when one
+// is set with SetFeatureLevels, otherwise at the global level.
+func (l Logger) Named(feature string) *zap.Logger {
+	if l.base == nil {
+		return l.logger.Named(feature)
+	}
+	enabler := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		if featureLevel, ok := l.features.get(feature); ok {
+			return lvl >= featureLevel
+		}
+		return l.level.Enabled(lvl)
+	})
+	return zap.New(levelCore{Core: l.base, level: enabler}).Named(feature)
+}
+
+// SetFeatureLevels replaces the per-feature levels at runtime, e.g. {"aichat": "debug"}.
+// Features without an entry follow the global level.
+func (l Logger) SetFeatureLevels(levels map[string]string) error {
+	parsed, err := ParseFeatureLevels(levels)
+	if err != nil {
+		return err
+	}
+	if l.features != nil {
+		l.features.set(parsed)
+	}
 	return nil
 }
+
+// ParseFeatureLevels validates a feature to level name mapping
+func ParseFeatureLevels(levels map[string]string) (map[string]zapcore.Level, error) {
+	parsed := make(map[string]zapcore.Level, len(levels))
+	for feature, input := range levels {
+		if input == "" {
+			return nil, fmt.Errorf("log level for %s: missing level", feature)
+		}
+		level, err := zapcore.ParseLevel(input)
+		if err != nil {
+			return nil, fmt.Errorf("log level for %s: %w", feature, err)
+		}
+		parsed[feature] = level
+	}
+	return parsed, nil
+}
+
+type featureLevels struct {
+	mu     sync.RWMutex
+	levels map[string]zapcore.Level
+}
+
+func (f *featureLevels) get(feature string) (zapcore.Level, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	level, ok := f.levels[feature]
+	return level, ok
+}
+
+func (f *featureLevels) set(levels map[string]zapcore.Level) {
+	f.mu.Lock()
+	f.levels = levels
+	f.mu.Unlock()
+}
+
+// levelCore filters a core with its own level so loggers sharing one output can log at
+// different levels
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
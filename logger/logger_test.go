@@ -157,7 +157,7 @@ func TestConsoleEncoder(t *testing.T) {
 	ecfg := zap.NewDevelopmentEncoderConfig()
 	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
 
-	core := consoleEncoder(ecfg, level)
+	core := consoleEncoder(ecfg, level, os.Stdout)
 	if core == nil {
 		t.Errorf("consoleEncoder() returned nil")
 	}
@@ -167,7 +167,7 @@ func TestConsoleJSONEncoder(t *testing.T) {
 	ecfg := zap.NewDevelopmentEncoderConfig()
 	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
 
-	core := consoleJSONEncoder(ecfg, level)
+	core := consoleJSONEncoder(ecfg, level, os.Stdout)
 	if core == nil {
 		t.Errorf("consoleJSONEncoder() returned nil")
 	}
@@ -240,6 +240,41 @@ func TestLoggerJSONOutput(t *testing.T) {
 	}
 }
 
+func TestLoggerStderrOutput(t *testing.T) {
+	// Capture stdout and stderr
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	outR, outW, _ := os.Pipe()
+	errR, errW, _ := os.Pipe()
+	os.Stdout, os.Stderr = outW, errW
+
+	defer func() {
+		os.Stdout, os.Stderr = oldStdout, oldStderr
+		_ = outW.Close()
+		_ = errW.Close()
+	}()
+
+	opts := LoggerOpts{Level: "info", IsProduction: false, JSONConsole: false, Stderr: true}
+	logger, err := NewLogger(opts)
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	logger.Get().Info("test stderr message")
+
+	_ = outW.Close()
+	_ = errW.Close()
+	var stdout, stderr bytes.Buffer
+	_, _ = io.Copy(&stdout, outR)
+	_, _ = io.Copy(&stderr, errR)
+
+	if !strings.Contains(stderr.String(), "test stderr message") {
+		t.Errorf("Logger should write to stderr, got: %s", stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Logger should not write to stdout, got: %s", stdout.String())
+	}
+}
+
 func TestLoggerLevels(t *testing.T) {
 	// Capture stdout
 	oldStdout := os.Stdout
@@ -312,3 +347,73 @@ func BenchmarkLoggerInfo(b *testing.B) {
 		zapLogger.Info("benchmark message")
 	}
 }
+
+func TestLogger_FeatureLevels(t *testing.T) {
+	var buf bytes.Buffer
+	base := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	l := Logger{
+		logger:   zap.New(levelCore{Core: base, level: level}),
+		level:    level,
+		base:     base,
+		features: &featureLevels{levels: make(map[string]zapcore.Level)},
+	}
+
+	if err := l.SetFeatureLevels(map[string]string{"aichat": "debug", "chat": "warn"}); err != nil {
+		t.Fatalf("SetFeatureLevels() error = %v", err)
+	}
+	aichat, chat, other := l.Named("aichat"), l.Named("chat"), l.Named("vibecheck")
+
+	aichat.Debug("aichat debug")
+	chat.Info("chat info")
+	chat.Warn("chat warn")
+	other.Debug("vibecheck debug")
+	other.Info("vibecheck info")
+
+	out := buf.String()
+	for _, want := range []string{"aichat debug", "chat warn", "vibecheck info", `"logger":"aichat"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"chat info", "vibecheck debug"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Did not expect %q in output:\n%s", unwanted, out)
+		}
+	}
+
+	// Existing child loggers follow runtime changes
+	buf.Reset()
+	if err := l.SetFeatureLevels(nil); err != nil {
+		t.Fatalf("SetFeatureLevels() error = %v", err)
+	}
+	l.SetLevel(zapcore.DebugLevel)
+	chat.Info("chat info")
+	other.Debug("vibecheck debug")
+	if out := buf.String(); !strings.Contains(out, "chat info") || !strings.Contains(out, "vibecheck debug") {
+		t.Errorf("Expected child loggers to follow the new levels:\n%s", out)
+	}
+}
+
+func TestParseFeatureLevels(t *testing.T) {
+	for _, levels := range []map[string]string{{"aichat": "loud"}, {"aichat": ""}} {
+		if _, err := ParseFeatureLevels(levels); err == nil {
+			t.Errorf("ParseFeatureLevels(%v) should fail", levels)
+		}
+	}
+	got, err := ParseFeatureLevels(map[string]string{"chat": "WARN"})
+	if err != nil || got["chat"] != zapcore.WarnLevel {
+		t.Errorf("ParseFeatureLevels() = %v, %v", got, err)
+	}
+}
+
+func TestNoopLogger_Levels(t *testing.T) {
+	l := NewNoopLogger()
+	l.SetLevel(zapcore.DebugLevel)
+	if err := l.SetFeatureLevels(map[string]string{"chat": "debug"}); err != nil {
+		t.Errorf("SetFeatureLevels() error = %v", err)
+	}
+	if l.Named("chat") == nil {
+		t.Error("Named() returned nil")
+	}
+}
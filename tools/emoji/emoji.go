@@ -0,0 +1,140 @@
+// Package emoji normalizes emoji names written in config, commands or events, with or
+// without colons, to the names Slack uses for reactions and message text
+package emoji
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skinTone is the suffix Slack adds to reactions with a skin tone, e.g. +1::skin-tone-2
+const skinTone = "::skin-tone-"
+
+var validName = regexp.MustCompile(`^[a-z0-9_+'\-]+(::skin-tone-[2-6])?$`)
+
+// aliases maps names Slack doesn't use, like Unicode names or the emoji themselves, to
+// Slack's names. Slack reports reactions by the first of an emoji's names, so its own
+// alternates, like thumbsup for +1, are mapped too.
+var aliases = map[string]string{
+	"thumbsup":               "+1",
+	"thumbs_up":              "+1",
+	"👍":                      "+1",
+	"thumbsdown":             "-1",
+	"thumbs_down":            "-1",
+	"👎":                      "-1",
+	"red_heart":              "heart",
+	"❤️":                     "heart",
+	"check_mark":             "heavy_check_mark",
+	"✅":                      "white_check_mark",
+	"cross_mark":             "x",
+	"❌":                      "x",
+	"face_with_tears_of_joy": "joy",
+	"😂":                      "joy",
+	"party_popper":           "tada",
+	"🎉":                      "tada",
+	"🔥":                      "fire",
+	"👀":                      "eyes",
+	"⛔":                      "no_entry",
+	"simple_smile":           "slightly_smiling_face",
+	"🙂":                      "slightly_smiling_face",
+}
+
+// Name returns the emoji name without colons, lowercased and with aliases mapped
+func Name(s string) string {
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(s), ":"))
+	if alias, ok := aliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// Code returns the emoji for message text, e.g. :tada:, or an empty string for no name
+func Code(s string) string {
+	name := Name(s)
+	if name == "" {
+		return ""
+	}
+	return ":" + name + ":"
+}
+
+// Base returns the name without a skin tone, so +1::skin-tone-2 matches +1
+func Base(s string) string {
+	name := Name(s)
+	if i := strings.Index(name, skinTone); i >= 0 {
+		return Name(name[:i])
+	}
+	return name
+}
+
+// Valid reports whether s normalizes to a name Slack could accept. It can't tell whether
+// the emoji exists, which depends on the workspace's custom emoji.
+func Valid(s string) bool {
+	return validName.MatchString(Name(s))
+}
+
+// ListFunc returns a workspace's custom emoji, keyed by name. Values are image URLs or
+// alias:name for aliases, like Slack's emoji.list.
+type ListFunc func(ctx context.Context) (map[string]string, error)
+
+// Catalog caches a workspace's custom emoji to check names and follow their aliases
+type Catalog struct {
+	list      ListFunc
+	ttl       time.Duration
+	mu        sync.Mutex
+	custom    map[string]string
+	fetchedAt time.Time
+}
+
+// NewCatalog lists custom emoji when first needed and again once ttl has passed
+func NewCatalog(list ListFunc, ttl time.Duration) *Catalog {
+	return &Catalog{list: list, ttl: ttl}
+}
+
+// Custom reports whether name is one of the workspace's custom emoji. A nil catalog has
+// no custom emoji.
+func (c *Catalog) Custom(ctx context.Context, name string) bool {
+	_, ok := c.emoji(ctx)[Base(name)]
+	return ok
+}
+
+// Resolve normalizes a name and follows custom emoji aliases to the emoji they point to
+func (c *Catalog) Resolve(ctx context.Context, name string) string {
+	name = Base(name)
+	custom := c.emoji(ctx)
+	// Bound the lookups in case aliases point at each other
+	for range 5 {
+		target, ok := strings.CutPrefix(custom[name], "alias:")
+		if !ok {
+			break
+		}
+		name = Base(target)
+	}
+	return name
+}
+
+// Same reports whether two names are the same emoji, ignoring colons, skin tones and
+// aliases
+func (c *Catalog) Same(ctx context.Context, a, b string) bool {
+	return c.Resolve(ctx, a) == c.Resolve(ctx, b)
+}
+
+// emoji returns the cached custom emoji, refreshing them when stale. The previous list is
+// kept if listing fails.
+func (c *Catalog) emoji(ctx context.Context) map[string]string {
+	if c == nil || c.list == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fetchedAt.IsZero() || time.Since(c.fetchedAt) >= c.ttl {
+		if custom, err := c.list(ctx); err == nil {
+			c.custom = custom
+		}
+		// Wait out the ttl before retrying a failed list too
+		c.fetchedAt = time.Now()
+	}
+	return c.custom
+}
@@ -0,0 +1,74 @@
+package emoji
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		in, name, code string
+		valid          bool
+	}{
+		{":tada:", "tada", ":tada:", true},
+		{" Tada ", "tada", ":tada:", true},
+		{"thumbsup", "+1", ":+1:", true},
+		{"👍", "+1", ":+1:", true},
+		{"+1::skin-tone-3", "+1::skin-tone-3", ":+1::skin-tone-3:", true},
+		{"no entry", "no entry", ":no entry:", false},
+		{"::", "", "", false},
+	}
+	for _, tt := range tests {
+		if got := Name(tt.in); got != tt.name {
+			t.Errorf("Name(%q) = %q, want %q", tt.in, got, tt.name)
+		}
+		if got := Code(tt.in); got != tt.code {
+			t.Errorf("Code(%q) = %q, want %q", tt.in, got, tt.code)
+		}
+		if got := Valid(tt.in); got != tt.valid {
+			t.Errorf("Valid(%q) = %v, want %v", tt.in, got, tt.valid)
+		}
+	}
+	if got := Base("thumbsup::skin-tone-2"); got != "+1" {
+		t.Errorf("Base() = %q, want +1", got)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	fail := false
+	c := NewCatalog(func(context.Context) (map[string]string, error) {
+		calls++
+		if fail {
+			return nil, errors.New("ratelimited")
+		}
+		return map[string]string{
+			"vibecheck": "https://emoji.example/vibecheck.png",
+			"vc":        "alias:vibecheck",
+		}, nil
+	}, time.Hour)
+
+	if !c.Custom(ctx, ":vc:") || c.Custom(ctx, "tada") {
+		t.Error("Custom() should only report the workspace's emoji")
+	}
+	if !c.Same(ctx, "vc", ":vibecheck:") || !c.Same(ctx, "thumbsup", "+1::skin-tone-4") {
+		t.Error("Same() should match aliases and skin tones")
+	}
+	if calls != 1 {
+		t.Errorf("listed %d times, want the list cached", calls)
+	}
+
+	c.fetchedAt = time.Now().Add(-2 * time.Hour)
+	fail = true
+	if got := c.Resolve(ctx, "vc"); got != "vibecheck" {
+		t.Errorf("Resolve() after a failed refresh = %q, want the cached alias followed", got)
+	}
+
+	var none *Catalog
+	if none.Custom(ctx, "vibecheck") || none.Resolve(ctx, ":tada:") != "tada" {
+		t.Error("A nil catalog should only normalize names")
+	}
+}
@@ -27,3 +27,35 @@ func Int(min, max int) int {
 func Float(min, max float64) float64 {
 	return min + rand.Float64()*(max-min) // #nosec G404
 }
+
+// WeightedString returns a random string from values where each value's chance is
+// proportional to its weight. Non-positive weights are never picked. Returns an empty
+// string when no value has a positive weight.
+func WeightedString(values []string, weights []float64) string {
+	var total float64
+	for i := range values {
+		if i < len(weights) && weights[i] > 0 {
+			total += weights[i]
+		}
+	}
+	if total <= 0 {
+		return ""
+	}
+	r := rand.Float64() * total // #nosec G404
+	for i, v := range values {
+		if i >= len(weights) || weights[i] <= 0 {
+			continue
+		}
+		r -= weights[i]
+		if r < 0 {
+			return v
+		}
+	}
+	// Floating point rounding may leave r at ~0; return the last eligible value
+	for i := len(values) - 1; i >= 0; i-- {
+		if i < len(weights) && weights[i] > 0 {
+			return values[i]
+		}
+	}
+	return ""
+}
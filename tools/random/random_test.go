@@ -144,3 +144,31 @@ func BenchmarkFloat(b *testing.B) {
 		Float(0.0, 1.0)
 	}
 }
+
+func TestWeightedString(t *testing.T) {
+	values := []string{"never", "rare", "common"}
+	weights := []float64{0, 1, 3}
+
+	counts := make(map[string]int)
+	iterations := 10000
+	for range iterations {
+		counts[WeightedString(values, weights)]++
+	}
+
+	if counts["never"] != 0 {
+		t.Errorf("WeightedString picked zero-weight value %d times", counts["never"])
+	}
+	ratio := float64(counts["common"]) / float64(iterations)
+	if math.Abs(ratio-0.75) > 0.05 {
+		t.Errorf("WeightedString common ratio = %f, expected ~0.75", ratio)
+	}
+}
+
+func TestWeightedStringNoPositiveWeights(t *testing.T) {
+	if got := WeightedString([]string{"a", "b"}, []float64{0, -1}); got != "" {
+		t.Errorf("WeightedString with no positive weights = %q, want empty string", got)
+	}
+	if got := WeightedString(nil, nil); got != "" {
+		t.Errorf("WeightedString with no values = %q, want empty string", got)
+	}
+}
@@ -0,0 +1,54 @@
+// Package timeformat formats times and durations for messages, so each reader sees times
+// in their own timezone instead of the server's.
+package timeformat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format is a Slack date format and the layout of its fallback text, which clients that
+// can't render dates show instead, in UTC
+type Format struct {
+	tokens   string
+	fallback string
+}
+
+var (
+	// Time is the time of day, e.g. 2:30 PM
+	Time = Format{tokens: "{time}", fallback: "15:04 MST"}
+	// Date is the date, e.g. Jan 2, 2006
+	Date = Format{tokens: "{date_short}", fallback: "Jan 2, 2006"}
+	// DateTime is the date and time, with today and yesterday written out, e.g. Today at 2:30 PM
+	DateTime = Format{tokens: "{date_short_pretty} at {time}", fallback: "2006-01-02 15:04 MST"}
+)
+
+// Slack renders t as a Slack date token, shown in each reader's timezone
+func Slack(t time.Time, f Format) string {
+	return fmt.Sprintf("<!date^%d^%s|%s>", t.Unix(), f.tokens, t.UTC().Format(f.fallback))
+}
+
+// In formats t in an IANA timezone such as a Slack user's tz, for text that isn't
+// rendered by Slack, e.g. an email or a model's prompt. An unknown or empty timezone
+// falls back to UTC.
+func In(t time.Time, tz, layout string) string {
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "" {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(layout)
+}
+
+// Duration renders a duration rounded to the second without zero units, e.g. 5m, 1h30m
+// or 4m30s
+func Duration(d time.Duration) string {
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package timeformat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlack(t *testing.T) {
+	at := time.Date(2024, 3, 5, 14, 30, 0, 0, time.FixedZone("MST", -7*60*60))
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{Time, "<!date^1709674200^{time}|21:30 UTC>"},
+		{Date, "<!date^1709674200^{date_short}|Mar 5, 2024>"},
+		{DateTime, "<!date^1709674200^{date_short_pretty} at {time}|2024-03-05 21:30 UTC>"},
+	}
+	for _, tt := range tests {
+		if got := Slack(at, tt.format); got != tt.want {
+			t.Errorf("Slack(%v) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestIn(t *testing.T) {
+	at := time.Date(2024, 3, 5, 21, 30, 0, 0, time.UTC)
+	if got := In(at, "America/New_York", "Jan 2 15:04 MST"); got != "Mar 5 16:30 EST" {
+		t.Errorf("In(America/New_York) = %q", got)
+	}
+	if got := In(at, "Nowhere/Unknown", "15:04 MST"); got != "21:30 UTC" {
+		t.Errorf("In() with an unknown timezone = %q, want UTC", got)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		5 * time.Minute:                   "5m",
+		90 * time.Minute:                  "1h30m",
+		2 * time.Hour:                     "2h",
+		4*time.Minute + 30*time.Second:    "4m30s",
+		45*time.Second + time.Millisecond: "45s",
+	}
+	for d, want := range tests {
+		if got := Duration(d); got != want {
+			t.Errorf("Duration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}